@@ -1,28 +1,55 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/evalgo-org/claude-tools/pkg/archive"
 	"github.com/evalgo-org/claude-tools/pkg/awk"
+	"github.com/evalgo-org/claude-tools/pkg/backup"
 	"github.com/evalgo-org/claude-tools/pkg/cat"
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/config"
 	"github.com/evalgo-org/claude-tools/pkg/cp"
 	"github.com/evalgo-org/claude-tools/pkg/db"
+	"github.com/evalgo-org/claude-tools/pkg/dedupe"
+	"github.com/evalgo-org/claude-tools/pkg/dos2unix"
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
 	"github.com/evalgo-org/claude-tools/pkg/find"
+	"github.com/evalgo-org/claude-tools/pkg/fixws"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
 	"github.com/evalgo-org/claude-tools/pkg/grep"
 	"github.com/evalgo-org/claude-tools/pkg/head"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
 	"github.com/evalgo-org/claude-tools/pkg/jq"
+	"github.com/evalgo-org/claude-tools/pkg/jsonc"
+	"github.com/evalgo-org/claude-tools/pkg/limit"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/loc"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
 	"github.com/evalgo-org/claude-tools/pkg/ls"
 	"github.com/evalgo-org/claude-tools/pkg/mkdir"
 	"github.com/evalgo-org/claude-tools/pkg/mv"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/parallel"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+	"github.com/evalgo-org/claude-tools/pkg/retry"
 	"github.com/evalgo-org/claude-tools/pkg/rm"
 	"github.com/evalgo-org/claude-tools/pkg/sed"
+	"github.com/evalgo-org/claude-tools/pkg/selftest"
+	"github.com/evalgo-org/claude-tools/pkg/shell"
 	"github.com/evalgo-org/claude-tools/pkg/sort"
 	"github.com/evalgo-org/claude-tools/pkg/tail"
 	"github.com/evalgo-org/claude-tools/pkg/touch"
 	"github.com/evalgo-org/claude-tools/pkg/tree"
 	"github.com/evalgo-org/claude-tools/pkg/uniq"
+	"github.com/evalgo-org/claude-tools/pkg/validate"
 	"github.com/evalgo-org/claude-tools/pkg/wc"
 )
 
@@ -33,17 +60,47 @@ func main() {
 		Long: `claude-tools provides cross-platform implementations of common Linux/Unix tools.
 Built in Go for consistent behavior across Windows, Linux, and macOS.`,
 		Version: "0.5.1",
+		// Flag and argument errors still print usage; runtime failures
+		// (including Ctrl+C) only print the error
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logging.Tool = cmd.Name()
+			if err := logging.Validate(); err != nil {
+				return err
+			}
+			if err := color.Validate(color.Mode); err != nil {
+				return err
+			}
+			return progress.Validate(progress.Mode)
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(&color.Mode, "color", color.Auto, "Colorize output (never, always, auto)")
+	rootCmd.PersistentFlags().Lookup("color").NoOptDefVal = color.Always
+	rootCmd.PersistentFlags().BoolVar(&glob.NoGlob, "no-glob", false, "Don't expand wildcard arguments (expansion is only done on Windows)")
+	rootCmd.PersistentFlags().IntVar(&lines.DefaultMaxLength, "max-line-bytes", 0, "Fail on input lines longer than N bytes (0 = unlimited)")
+	rootCmd.PersistentFlags().BoolVar(&lines.DecodeBOM, "strip-bom", false, "Drop byte order marks and decode UTF-16 input as UTF-8")
+	rootCmd.PersistentFlags().BoolVar(&lines.StripCR, "crlf", false, "Treat CRLF as the line terminator in text commands")
+	rootCmd.PersistentFlags().BoolVar(&logging.Verbose, "verbose", false, "Log debug messages to stderr (give it before the command name for commands with their own --verbose)")
+	rootCmd.PersistentFlags().BoolVar(&logging.Quiet, "quiet", false, "Only log errors to stderr (give it before the command name for commands with their own --quiet)")
+	rootCmd.PersistentFlags().StringVar(&logging.Format, "log-format", logging.FormatText, "Log message format (text, json)")
+	rootCmd.PersistentFlags().StringVar(&logging.Errors, "errors", logging.FormatText, "Error message format (text, json records with tool, path and code)")
+	rootCmd.PersistentFlags().StringVar(&progress.Mode, "progress", progress.Auto, "Show progress of long operations (never, auto, always, json)")
+	rootCmd.PersistentFlags().Lookup("progress").NoOptDefVal = progress.Always
+	rootCmd.PersistentFlags().BoolVar(&dryrun.Enabled, "dry-run", false, "Print the changes rm, mv, cp, touch, mkdir, sed -i, sort -o, dos2unix, fix-ws, dedupe, backup and restore would make without making them")
+	rootCmd.PersistentFlags().Bool("no-config", false, "Ignore config.yaml and "+config.ProjectFile+" files")
+
 	// Add subcommands - Phase 1
 	rootCmd.AddCommand(grep.Command())
 	rootCmd.AddCommand(find.Command())
 	rootCmd.AddCommand(cat.Command())
+	rootCmd.AddCommand(cat.LinesCommand())
 
 	// Add subcommands - Phase 2
 	rootCmd.AddCommand(head.Command())
 	rootCmd.AddCommand(tail.Command())
 	rootCmd.AddCommand(wc.Command())
+	rootCmd.AddCommand(loc.Command())
 	rootCmd.AddCommand(ls.Command())
 	rootCmd.AddCommand(sort.Command())
 	rootCmd.AddCommand(uniq.Command())
@@ -51,6 +108,9 @@ Built in Go for consistent behavior across Windows, Linux, and macOS.`,
 	// Add subcommands - Phase 3
 	rootCmd.AddCommand(tree.Command())
 	rootCmd.AddCommand(jq.Command())
+	rootCmd.AddCommand(jq.LintCommand())
+	rootCmd.AddCommand(jsonc.Command())
+	rootCmd.AddCommand(validate.Command())
 	rootCmd.AddCommand(sed.Command())
 	rootCmd.AddCommand(awk.Command())
 
@@ -65,8 +125,92 @@ Built in Go for consistent behavior across Windows, Linux, and macOS.`,
 	rootCmd.AddCommand(cp.Command())
 	rootCmd.AddCommand(mv.Command())
 	rootCmd.AddCommand(touch.Command())
+	rootCmd.AddCommand(backup.Command())
+	rootCmd.AddCommand(backup.RestoreCommand())
+	rootCmd.AddCommand(archive.Command())
+
+	// Add subcommands - Phase 7 (Text conversion)
+	rootCmd.AddCommand(dos2unix.Command())
+	rootCmd.AddCommand(dos2unix.Unix2DosCommand())
+	rootCmd.AddCommand(fixws.Command())
+	rootCmd.AddCommand(dedupe.Command())
+
+	// Add subcommands - Phase 8 (Interactive use)
+	rootCmd.AddCommand(shell.Command())
+
+	// Add subcommands - Phase 9 (Deployment checks)
+	rootCmd.AddCommand(selftest.Command())
+	rootCmd.AddCommand(selftest.BenchCommand())
+
+	// Add subcommands - Phase 10 (Sandboxing)
+	rootCmd.AddCommand(limit.Command())
+	rootCmd.AddCommand(limit.ExecCommand())
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	// Add subcommands - Phase 11 (Scripting)
+	rootCmd.AddCommand(retry.Command())
+	rootCmd.AddCommand(parallel.Command())
+
+	// Take --verbose and --quiet before the command name for logging, even
+	// where the command has flags of the same names
+	args := logging.LeadingFlags(os.Args[1:], func(name string) bool {
+		f := rootCmd.PersistentFlags().Lookup(name)
+		return f != nil && f.NoOptDefVal == ""
+	})
+
+	// Apply per-command defaults and aliases from the configuration files
+	if !config.Skip(args) {
+		cfg, errs := config.Load()
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
+		config.IgnorePatterns = cfg.Ignore
+		if cfg.Remote.Timeout > 0 {
+			input.Timeout = cfg.Remote.Timeout
+		}
+		input.Headers = cfg.Remote.Headers
+		args = cfg.Apply(rootCmd, args)
 	}
+	rootCmd.SetArgs(args)
+
+	// Machine-readable errors replace cobra's "Error:" line and usage text
+	logging.ScanErrors(args)
+	if logging.Errors == logging.FormatJSON {
+		rootCmd.SilenceErrors = true
+		rootCmd.SilenceUsage = true
+	}
+
+	// Cancel running commands on SIGINT/SIGTERM so they can clean up
+	ctx, stop := interrupt.Context(context.Background())
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	interrupted := ctx.Err() != nil
+	stop()
+
+	// Commands buffer their output; write out what is left, also when they
+	// stopped early because of an error or an interrupt
+	if flushErr := output.Flush(); flushErr != nil && err == nil {
+		err = fmt.Errorf("cannot write output: %w", flushErr)
+		if logging.Errors != logging.FormatJSON {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}
+
+	if err != nil && logging.Errors == logging.FormatJSON && !silent(err) {
+		logging.Tool = cmd.Name()
+		logging.Error(err)
+	}
+
+	if interrupted {
+		os.Exit(interrupt.ExitCode)
+	}
+	if err == nil && dryrun.Enabled && dryrun.Changes() > 0 {
+		os.Exit(dryrun.ExitCode)
+	}
+	os.Exit(exitcode.From(err))
+}
+
+// silent reports whether err only carries an exit status and has no
+// message to print
+func silent(err error) bool {
+	var e *exitcode.Error
+	return errors.As(err, &e) && e.Err == nil
 }
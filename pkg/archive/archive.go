@@ -0,0 +1,249 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// ListOptions holds archive list configuration
+type ListOptions struct {
+	Long bool
+	JSON bool
+}
+
+// record is an entry as archive list --json prints it
+type record struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Size   *int64 `json:"size"` // null when unknown
+	Mode   string `json:"mode"`
+	Time   string `json:"time"`
+	Target string `json:"target,omitempty"`
+}
+
+// Command returns the archive command
+func Command() *cobra.Command {
+	archiveCmd := &cobra.Command{
+		Use:   "archive",
+		Short: "List and read files inside archives",
+		Long: `List the entries of tar, zip, gzip, bzip2 and zstd files, and print the
+files in them, without unpacking the archive. Compressed tar files
+(.tar.gz, .tgz, .tar.bz2, .tar.zst) are read as the tar file they hold;
+any other compressed file counts as an archive of one file. The format
+is told from the contents, not the name. The archive may be - for
+standard input or a URL.
+
+Patterns select entries by their path in the archive, with * and ? not
+matching / and ** matching any number of directories; a directory's
+path selects everything below it.
+
+Examples:
+  claude-tools archive list -l artifacts.zip
+  claude-tools archive cat artifacts.zip 'logs/**/*.log' | claude-tools grep -i error
+  claude-tools archive cat release.tar.gz app/config.yaml`,
+	}
+
+	opts := &ListOptions{}
+	listCmd := &cobra.Command{
+		Use:   "list [flags] archive [pattern...]",
+		Short: "List the entries of an archive",
+		Long: `Print the path of each entry of an archive, or only of those the patterns
+select, in the order they are stored. Directories end with /.
+
+-l adds the mode, size and modification time of each entry, and the
+target of links; --json prints a JSON object per line with the name,
+type, size, mode, time and target of each.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(args[1:]); err != nil {
+				return err
+			}
+			cmd.SilenceErrors = true
+			return list(cmd.Context(), args[0], args[1:], opts)
+		},
+	}
+	listCmd.Flags().BoolVarP(&opts.Long, "long", "l", false, "Show mode, size and time")
+	listCmd.Flags().BoolVar(&opts.JSON, "json", false, "Print a JSON object per entry")
+
+	catCmd := &cobra.Command{
+		Use:   "cat archive [pattern...]",
+		Short: "Print files inside an archive",
+		Long: `Print the contents of the files in an archive that the patterns select,
+or of all of them, one after another in the order they are stored. A
+pattern that selects no file is an error, and the exit status is then 1.
+Only the part of the archive up to the last file printed is read when
+every pattern names a file.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(args[1:]); err != nil {
+				return err
+			}
+			cmd.SilenceErrors = true
+			return cat(cmd.Context(), args[0], args[1:])
+		},
+	}
+
+	archiveCmd.AddCommand(listCmd)
+	archiveCmd.AddCommand(catCmd)
+	return archiveCmd
+}
+
+// validate checks the syntax of patterns
+func validate(patterns []string) error {
+	for _, p := range patterns {
+		if !doublestar.ValidatePattern(p) {
+			return exitcode.New(2, fmt.Errorf("invalid pattern %q", p))
+		}
+	}
+	return nil
+}
+
+// selector matches entries against patterns and remembers which patterns
+// have matched
+type selector struct {
+	patterns []string
+	matched  []bool
+	exact    []bool // the pattern is the name of an entry matched
+}
+
+func newSelector(patterns []string) *selector {
+	s := &selector{matched: make([]bool, len(patterns)), exact: make([]bool, len(patterns))}
+	for _, p := range patterns {
+		s.patterns = append(s.patterns, cleanName(p))
+	}
+	return s
+}
+
+// match reports whether one of the patterns selects name, itself or as a
+// directory above it, or there are none
+func (s *selector) match(name string) bool {
+	if len(s.patterns) == 0 {
+		return true
+	}
+	ok := false
+	for i, p := range s.patterns {
+		if matchPath(p, name) {
+			s.matched[i] = true
+			s.exact[i] = s.exact[i] || p == name
+			ok = true
+		}
+	}
+	return ok
+}
+
+// matchPath reports whether pattern matches name or a directory above it
+func matchPath(pattern, name string) bool {
+	for {
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
+
+// done reports whether every pattern is the name of a file that has been
+// found without special characters, so that the rest of the archive
+// needn't be read
+func (s *selector) done() bool {
+	if len(s.patterns) == 0 {
+		return false
+	}
+	for i, p := range s.patterns {
+		if !s.exact[i] || strings.ContainsAny(p, `*?[{\`) {
+			return false
+		}
+	}
+	return true
+}
+
+// unmatched reports the patterns that selected nothing, and returns
+// whether there were any
+func (s *selector) unmatched(archive string) bool {
+	failed := false
+	for i, p := range s.patterns {
+		if !s.matched[i] {
+			logging.PathError("Cannot find", p, fmt.Errorf("not found in '%s'", input.Name(archive)))
+			failed = true
+		}
+	}
+	return failed
+}
+
+// list prints the entries of archive that patterns select
+func list(ctx context.Context, archive string, patterns []string, opts *ListOptions) error {
+	s := newSelector(patterns)
+	enc := json.NewEncoder(output.Stdout)
+	err := Walk(ctx, archive, func(e *Entry, _ io.Reader) error {
+		if !s.match(e.Name) {
+			return nil
+		}
+		var err error
+		switch {
+		case opts.JSON:
+			rec := record{Name: e.Name, Type: e.Type, Mode: e.Mode.String(), Time: e.ModTime.Format(time.RFC3339), Target: e.Target}
+			if e.Size >= 0 {
+				rec.Size = &e.Size
+			}
+			err = enc.Encode(rec)
+		case opts.Long:
+			_, err = fmt.Fprintln(output.Stdout, describe(e))
+		case e.Type == "directory":
+			_, err = fmt.Fprintln(output.Stdout, e.Name+"/")
+		default:
+			_, err = fmt.Fprintln(output.Stdout, e.Name)
+		}
+		return err
+	})
+	return finish(archive, err, s)
+}
+
+// cat prints the files in archive that patterns select
+func cat(ctx context.Context, archive string, patterns []string) error {
+	s := newSelector(patterns)
+	err := Walk(ctx, archive, func(e *Entry, contents io.Reader) error {
+		if e.Type != "file" || !s.match(e.Name) {
+			return nil
+		}
+		if _, err := io.Copy(output.Stdout, contents); err != nil {
+			return err
+		}
+		if s.done() {
+			return errStop
+		}
+		return nil
+	})
+	return finish(archive, err, s)
+}
+
+// finish reports an error reading archive and patterns that selected
+// nothing, and returns status 1 if there were either
+func finish(archive string, err error, s *selector) error {
+	if err != nil {
+		if interrupt.Interrupted(err) {
+			return err
+		}
+		logging.PathError("Cannot read", input.Name(archive), err)
+		return exitcode.Status(1)
+	}
+	if s.unmatched(archive) {
+		return exitcode.Status(1)
+	}
+	return nil
+}
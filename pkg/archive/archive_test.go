@@ -0,0 +1,203 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// files are the contents of the archives the tests read, in order
+var files = []struct{ name, content string }{
+	{"logs/a.log", "ERROR one\n"},
+	{"logs/sub/b.log", "ok two\n"},
+	{"config.yaml", "cfg\n"},
+}
+
+// writeTarGz writes files to a gzipped tar file in dir, with a ./ entry
+// and directory entries as tar makes them
+func writeTarGz(t *testing.T, dir string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, d := range []string{"./", "./logs/", "./logs/sub/"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: d, Typeflag: tar.TypeDir, Mode: 0755}))
+	}
+	for _, f := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "./" + f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.content))}))
+		_, err := tw.Write([]byte(f.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	name := filepath.Join(dir, "a.tar.gz")
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0644))
+	return name
+}
+
+// writeZip writes files to a zip file in dir
+func writeZip(t *testing.T, dir string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(f.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	name := filepath.Join(dir, "a.zip")
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0644))
+	return name
+}
+
+// names returns the names and types of the entries of an archive
+func names(t *testing.T, name string) []string {
+	var got []string
+	err := Walk(context.Background(), name, func(e *Entry, _ io.Reader) error {
+		got = append(got, e.Type+" "+e.Name)
+		return nil
+	})
+	require.NoError(t, err)
+	return got
+}
+
+// capture returns what fn prints to standard output
+func capture(t *testing.T, fn func() error) (string, error) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	err = fn()
+	require.NoError(t, output.Flush())
+	printed, readErr := os.ReadFile(out.Name())
+	require.NoError(t, readErr)
+	return string(printed), err
+}
+
+// TestWalkArchive tests that tar, zip, gzip and zstd files are told apart
+// by their contents and their entries read in order
+func TestWalkArchive(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, []string{
+		"directory logs", "directory logs/sub",
+		"file logs/a.log", "file logs/sub/b.log", "file config.yaml",
+	}, names(t, writeTarGz(t, dir)))
+	assert.Equal(t, []string{"file logs/a.log", "file logs/sub/b.log", "file config.yaml"}, names(t, writeZip(t, dir)))
+
+	// A compressed file that isn't a tar file is an archive of one file,
+	// named after it without .gz
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte("plain\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	plain := filepath.Join(dir, "notes.txt.gz")
+	require.NoError(t, os.WriteFile(plain, buf.Bytes(), 0644))
+	assert.Equal(t, []string{"file notes.txt"}, names(t, plain))
+
+	// A zstd file is told apart the same way, and one holding a tar file
+	// read as that tar file
+	buf.Reset()
+	zw, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte("plain\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	plain = filepath.Join(dir, "notes.txt.zst")
+	require.NoError(t, os.WriteFile(plain, buf.Bytes(), 0644))
+	assert.Equal(t, []string{"file notes.txt"}, names(t, plain))
+
+	gzipped, err := os.ReadFile(writeTarGz(t, dir))
+	require.NoError(t, err)
+	gr, err := gzip.NewReader(bytes.NewReader(gzipped))
+	require.NoError(t, err)
+	buf.Reset()
+	zw.Reset(&buf)
+	_, err = io.Copy(zw, gr)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	tarZst := filepath.Join(dir, "a.tar.zst")
+	require.NoError(t, os.WriteFile(tarZst, buf.Bytes(), 0644))
+	assert.Equal(t, []string{
+		"directory logs", "directory logs/sub",
+		"file logs/a.log", "file logs/sub/b.log", "file config.yaml",
+	}, names(t, tarZst))
+
+	other := filepath.Join(dir, "other.txt")
+	require.NoError(t, os.WriteFile(other, []byte("not an archive"), 0644))
+	err = Walk(context.Background(), other, func(*Entry, io.Reader) error { return nil })
+	assert.Error(t, err)
+}
+
+// TestCat tests printing the files that patterns select, by name, glob
+// or directory
+func TestCat(t *testing.T) {
+	dir := t.TempDir()
+	for _, archive := range []string{writeTarGz(t, dir), writeZip(t, dir)} {
+		printed, err := capture(t, func() error {
+			return cat(context.Background(), archive, []string{"**/*.log"})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ERROR one\nok two\n", printed, archive)
+
+		printed, err = capture(t, func() error {
+			return cat(context.Background(), archive, []string{"./config.yaml", "logs/sub"})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ok two\ncfg\n", printed, archive)
+	}
+
+	printed, err := capture(t, func() error {
+		return cat(context.Background(), writeZip(t, dir), []string{"config.yaml", "missing.txt"})
+	})
+	assert.Equal(t, 1, exitcode.From(err))
+	assert.Equal(t, "cfg\n", printed)
+}
+
+// TestList tests the listing of selected entries, with directories
+// marked
+func TestList(t *testing.T) {
+	archive := writeTarGz(t, t.TempDir())
+	printed, err := capture(t, func() error {
+		return list(context.Background(), archive, []string{"logs"}, &ListOptions{})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "logs/\nlogs/sub/\nlogs/a.log\nlogs/sub/b.log\n", printed)
+
+	printed, err = capture(t, func() error {
+		return list(context.Background(), archive, []string{"config.yaml"}, &ListOptions{JSON: true})
+	})
+	require.NoError(t, err)
+	assert.Contains(t, printed, `{"name":"config.yaml","type":"file","size":4,"mode":"-rw-r--r--",`)
+}
+
+// TestSelector_Done tests that reading stops early only once every
+// pattern has named a file
+func TestSelector_Done(t *testing.T) {
+	s := newSelector([]string{"a", "b/c"})
+	assert.True(t, s.match("a"))
+	assert.False(t, s.done())
+	assert.True(t, s.match("b/c"))
+	assert.True(t, s.done())
+
+	s = newSelector([]string{"*.log"})
+	assert.True(t, s.match("x.log"))
+	assert.False(t, s.done())
+}
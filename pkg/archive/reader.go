@@ -0,0 +1,254 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+)
+
+// Entry is a member of an archive
+type Entry struct {
+	Name    string // slash-separated, without a leading ./ or trailing /
+	Type    string // file, directory, link, hardlink, fifo, char or block
+	Size    int64  // -1 when unknown, for a compressed file that isn't an archive
+	Mode    fs.FileMode
+	ModTime time.Time
+	Target  string // what a link points to
+}
+
+// errStop ends the walk through an archive early without an error
+var errStop = errors.New("stop")
+
+// WalkFunc is called for each entry of an archive with a reader of its
+// contents, which is only valid until it returns
+type WalkFunc func(e *Entry, contents io.Reader) error
+
+// peekSize is how much of a stream is looked at to tell its format:
+// enough for the magic number of a tar header
+const peekSize = 512
+
+// Walk calls fn for each entry of the tar, zip, gzip, bzip2 or zstd file
+// name, which may be - or a URL, in the order they are stored. A
+// compressed tar file is read as the tar file it holds, and any other
+// compressed file as an archive of one entry. The walk ends at the first
+// error fn returns, which Walk returns.
+func Walk(ctx context.Context, name string, fn WalkFunc) error {
+	f, err := input.OpenContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(interrupt.Reader(ctx, f), peekSize)
+	magic, _ := r.Peek(peekSize)
+	switch {
+	case isZip(magic):
+		return walkZip(r, f, fn)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		entry := &Entry{Name: gz.Name, ModTime: gz.ModTime}
+		if entry.Name == "" {
+			entry.Name = strings.TrimSuffix(path.Base(filepath.ToSlash(name)), ".gz")
+		}
+		return walkCompressed(gz, entry, fn)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		entry := &Entry{Name: strings.TrimSuffix(path.Base(filepath.ToSlash(name)), ".bz2")}
+		return walkCompressed(bzip2.NewReader(r), entry, fn)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		entry := &Entry{Name: strings.TrimSuffix(path.Base(filepath.ToSlash(name)), ".zst")}
+		return walkCompressed(zr, entry, fn)
+	case isTar(magic):
+		return walkTar(r, fn)
+	}
+	return errors.New("not a tar, zip, gzip, bzip2 or zstd file")
+}
+
+// isZip reports whether a file starts as a zip file does, with a local
+// file header or, when it is empty, the end of its central directory
+func isZip(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06"))
+}
+
+// isTar reports whether a block is a tar header in the POSIX or GNU format
+func isTar(block []byte) bool {
+	return len(block) >= 262 && string(block[257:262]) == "ustar"
+}
+
+// walkCompressed walks what r decompresses to: a tar file, or a single
+// file described by entry
+func walkCompressed(r io.Reader, entry *Entry, fn WalkFunc) error {
+	br := bufio.NewReaderSize(r, peekSize)
+	if block, _ := br.Peek(peekSize); isTar(block) {
+		return walkTar(br, fn)
+	}
+	if entry.Name == "" {
+		entry.Name = "-"
+	}
+	entry.Type = "file"
+	entry.Size = -1
+	entry.Mode = 0644
+	return stopped(fn(entry, br))
+}
+
+// walkTar walks the entries of a tar file
+func walkTar(r io.Reader, fn WalkFunc) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// The directory a tar file was made of is no entry of its own
+		if cleanName(hdr.Name) == "." {
+			continue
+		}
+		info := hdr.FileInfo()
+		entry := &Entry{
+			Name:    cleanName(hdr.Name),
+			Type:    typeOf(info.Mode()),
+			Size:    hdr.Size,
+			Mode:    info.Mode(),
+			ModTime: hdr.ModTime,
+			Target:  hdr.Linkname,
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			entry.Type = "hardlink"
+		}
+		if err := fn(entry, tr); err != nil {
+			return stopped(err)
+		}
+	}
+}
+
+// walkZip walks the entries of a zip file, whose directory at the end is
+// read first. A zip file read from anything but a regular file is read
+// into memory.
+func walkZip(r io.Reader, f io.ReadCloser, fn WalkFunc) error {
+	var zr *zip.Reader
+	var err error
+	if file, ok := f.(*os.File); ok {
+		if info, statErr := file.Stat(); statErr == nil && info.Mode().IsRegular() {
+			zr, err = zip.NewReader(file, info.Size())
+		}
+	}
+	if zr == nil && err == nil {
+		data, readErr := io.ReadAll(r)
+		if readErr != nil {
+			return readErr
+		}
+		zr, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		info := zf.FileInfo()
+		entry := &Entry{
+			Name:    cleanName(zf.Name),
+			Type:    typeOf(info.Mode()),
+			Size:    int64(zf.UncompressedSize64),
+			Mode:    info.Mode(),
+			ModTime: zf.Modified,
+		}
+		contents, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		if entry.Type == "link" {
+			target, err := io.ReadAll(io.LimitReader(contents, 4096))
+			if err != nil {
+				contents.Close()
+				return err
+			}
+			entry.Target = string(target)
+		}
+		err = fn(entry, contents)
+		contents.Close()
+		if err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+// stopped returns err, or nil if it is errStop
+func stopped(err error) error {
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	return err
+}
+
+// cleanName returns the name of an entry cleaned, without a leading /
+// or trailing /
+func cleanName(name string) string {
+	name = strings.TrimLeft(path.Clean(name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// typeOf returns the kind of entry a mode describes, in the words tree -J
+// uses
+func typeOf(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "link"
+	case mode&fs.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&fs.ModeCharDevice != 0:
+		return "char"
+	case mode&fs.ModeDevice != 0:
+		return "block"
+	}
+	return "file"
+}
+
+// describe formats an entry as archive list -l prints it
+func describe(e *Entry) string {
+	size := "?"
+	if e.Size >= 0 {
+		size = fmt.Sprint(e.Size)
+	}
+	line := fmt.Sprintf("%s %10s %s %s", e.Mode, size, e.ModTime.Local().Format("2006-01-02 15:04"), e.Name)
+	if e.Type == "directory" {
+		line += "/"
+	}
+	if e.Target != "" {
+		line += " -> " + e.Target
+	}
+	return line
+}
@@ -0,0 +1,235 @@
+package awk
+
+// The parser turns a program into the tree below once, resolving every
+// variable to a slot, and the interpreter evaluates the tree per record.
+
+// program is a parsed awk program
+type program struct {
+	begin     []stmt
+	items     []*item
+	end       []stmt
+	functions map[string]*function
+
+	scalars map[string]int // global scalar slots by name
+	arrays  map[string]int // global array slots by name
+}
+
+// item is a pattern-action rule. A nil body prints the record.
+type item struct {
+	pattern  expr // nil matches every record
+	pattern2 expr // end of a range pattern, if any
+	body     []stmt
+}
+
+// function is a user-defined function
+type function struct {
+	name   string
+	params []string
+	array  []bool // whether each parameter is an array
+	body   []stmt
+}
+
+// expr is an expression node
+type expr interface{}
+
+// stmt is a statement node
+type stmt interface{}
+
+// scope tells where a variable lives
+type scope int
+
+const (
+	scopeGlobal  scope = iota
+	scopeLocal         // function parameter
+	scopeSpecial       // built-in variable such as NR or FS
+)
+
+// varExpr is a variable, scalar or array
+type varExpr struct {
+	name  string
+	scope scope
+	index int
+	array bool
+}
+
+type numExpr struct {
+	n float64
+}
+
+type strExpr struct {
+	s string
+}
+
+// regexExpr is a regex literal, which matches $0 when used as a value
+type regexExpr struct {
+	re *matcher
+}
+
+type fieldExpr struct {
+	index expr
+}
+
+// indexExpr is an array element; several subscripts are joined by SUBSEP
+type indexExpr struct {
+	array *varExpr
+	subs  []expr
+}
+
+// assignExpr assigns to a variable, element or field, with op tAssign or a
+// compound assignment such as tAddAssign
+type assignExpr struct {
+	target expr
+	op     token
+	value  expr
+}
+
+type incrExpr struct {
+	target expr
+	delta  float64
+	pre    bool
+}
+
+type condExpr struct {
+	cond, yes, no expr
+}
+
+// binaryExpr is an arithmetic, comparison or logical operation
+type binaryExpr struct {
+	op          token
+	left, right expr
+}
+
+// unaryExpr is tSub, tAdd or tNot applied to an operand
+type unaryExpr struct {
+	op      token
+	operand expr
+}
+
+type concatExpr struct {
+	parts []expr
+}
+
+// matchExpr is left ~ re or, negated, left !~ re. A regex literal on the
+// right is compiled once; any other expression is a dynamic regex.
+type matchExpr struct {
+	left   expr
+	static *matcher
+	re     expr
+	negate bool
+}
+
+type inExpr struct {
+	subs  []expr
+	array *varExpr
+}
+
+type builtinExpr struct {
+	fn   builtin
+	args []expr
+}
+
+// callExpr calls a user-defined function, resolved by name after parsing
+type callExpr struct {
+	name string
+	fn   *function
+	args []expr
+	line int
+}
+
+// getlineKind tells where getline reads from
+type getlineKind int
+
+const (
+	getlineMain    getlineKind = iota // getline [var]
+	getlineFile                       // getline [var] < file
+	getlineCommand                    // cmd | getline [var]
+)
+
+type getlineExpr struct {
+	kind   getlineKind
+	src    expr // file name or command
+	target expr // nil for $0
+}
+
+// groupExpr is a parenthesized list, only valid as the arguments of print
+// or on the left of in
+type groupExpr struct {
+	exprs []expr
+}
+
+// printStmt is print or printf with an optional redirection: tGreater,
+// tAppend or tPipe to dest
+type printStmt struct {
+	printf   bool
+	args     []expr
+	redirect token
+	dest     expr
+}
+
+type exprStmt struct {
+	e expr
+}
+
+type blockStmt struct {
+	body []stmt
+}
+
+type ifStmt struct {
+	cond            expr
+	then, otherwise []stmt
+}
+
+type whileStmt struct {
+	cond expr
+	body []stmt
+}
+
+type doStmt struct {
+	body []stmt
+	cond expr
+}
+
+type forStmt struct {
+	init stmt // nil if absent
+	cond expr // nil if absent
+	post stmt // nil if absent
+	body []stmt
+}
+
+type forInStmt struct {
+	key   expr
+	array *varExpr
+	body  []stmt
+}
+
+// control is how a statement ends other than by falling through
+type control int
+
+const (
+	ctlNone control = iota
+	ctlBreak
+	ctlContinue
+	ctlNext
+	ctlNextFile
+	ctlExit
+	ctlReturn
+)
+
+// controlStmt is break, continue, next or nextfile
+type controlStmt struct {
+	ctl control
+}
+
+type exitStmt struct {
+	status expr // nil keeps the current exit status
+}
+
+type returnStmt struct {
+	value expr // nil returns the uninitialized value
+}
+
+// deleteStmt deletes an element, or the whole array if subs is nil
+type deleteStmt struct {
+	array *varExpr
+	subs  []expr
+}
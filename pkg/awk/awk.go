@@ -1,532 +1,188 @@
 package awk
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
 )
 
 // Options holds awk configuration
 type Options struct {
 	FieldSeparator string
+	CSV            bool // fields are comma-separated values, quoted as needed
+	TSV            bool // fields are tab-separated values with escapes
 	Program        string
-}
-
-// Context holds awk execution context
-type Context struct {
-	NR     int      // Number of records (lines)
-	NF     int      // Number of fields
-	Fields []string // Current line fields
-	Line   string   // Current line
-	FS     string   // Field separator
+	ProgramFiles   []string
+	Assignments    []string
 }
 
 // Command returns the awk command
 func Command() *cobra.Command {
-	opts := &Options{
-		FieldSeparator: " ",
-	}
+	opts := &Options{}
 
 	cmd := &cobra.Command{
 		Use:   "awk [options] 'program' [file...]",
 		Short: "Pattern scanning and text processing",
 		Long: `Pattern scanning and text processing language.
-Simplified awk implementation with common features.
+
+The program is parsed once into a syntax tree and run over every record of
+the input files, or of standard input if there are none. An operand of the
+form var=value assigns the variable when it is reached.
 
 Program Syntax:
-  pattern { action }       Execute action when pattern matches
-  BEGIN { action }         Execute before processing input
-  END { action }           Execute after processing input
-  { action }               Execute for every line
+  pattern { action }       Run action for records matching pattern
+  pat1, pat2 { action }    Run action from a match of pat1 to one of pat2
+  BEGIN { action }         Run before reading input
+  END { action }           Run after reading input
+  function f(a, b) { ... } Define a function; extra parameters are locals
+
+--csv splits records into comma-separated fields that may be quoted:
+"a, b" is one field, "" inside quotes is a quote, and a quoted field may
+span lines. print then quotes its arguments and rebuilt records' fields
+where they hold OFS, a quote or a line break, so the output is CSV as well.
+--tsv does the same for tab-separated values, where \t, \n, \r and \\
+stand for a tab, a line break, a carriage return and a backslash.
+
+Patterns are expressions or /regex/ (extended syntax). Actions support
+if/else, while, do, for, for (k in array), break, continue, next, nextfile,
+exit, return, delete, getline, print and printf with > >> | redirections,
+and associative arrays including multiple subscripts.
 
 Special Variables:
-  $0     Whole line
-  $1,$2  Field 1, field 2, etc.
-  NR     Current line number
-  NF     Number of fields
-  FS     Field separator
+  $0, $1...  Record and fields; assigning a field rebuilds $0 with OFS
+  NR, FNR    Record number overall and in the current file
+  NF         Number of fields; assigning it truncates or extends the record
+  FS, OFS    Input and output field separators
+  RS, ORS    Input and output record separators; RS="" reads paragraphs
+  FILENAME, SUBSEP, CONVFMT, OFMT, RSTART, RLENGTH, ARGC, ARGV, ENVIRON
+
+Functions:
+  length substr index split sub gsub match sprintf tolower toupper
+  sin cos atan2 exp log sqrt int rand srand system close fflush
+
+for (k in array) visits keys in numeric order if they are all integers and
+in string order otherwise, so output doesn't depend on hashing.
 
 Examples:
-  awk '{print $1}'                Print first field
-  awk '{print $1, $3}'            Print fields 1 and 3
-  awk '/pattern/ {print $0}'      Print lines matching pattern
-  awk 'NR==5 {print}'             Print line 5
-  awk '{sum+=$1} END {print sum}' Sum first field`,
-		Args: cobra.MinimumNArgs(1),
+  awk '{print $1}'                     Print first field
+  awk -F, '{print $1, $3}' data.csv    Print fields 1 and 3 of a CSV file
+  awk --csv '$3 > 100' data.csv        Filter a CSV file with quoted fields
+  awk '/pattern/'                      Print lines matching pattern
+  awk 'NR==5'                          Print line 5
+  awk '{sum+=$1} END {print sum}'      Sum first field
+  awk '{n[$1]++} END {for (k in n) print k, n[k]}'   Count by first field
+  awk -v limit=10 '$2 > limit'         Compare against a variable`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts.Program = args[0]
-			files := args[1:]
-
-			if len(files) == 0 {
-				return processInput(os.Stdin, opts)
+			if len(opts.ProgramFiles) == 0 {
+				if len(args) == 0 {
+					return exitcode.New(2, errors.New("no program given"))
+				}
+				opts.Program, args = args[0], args[1:]
 			}
 
-			for _, file := range files {
-				if err := processFile(file, opts); err != nil {
-					return err
-				}
+			status, err := Run(cmd.Context(), opts, expandOperands(args))
+			if err != nil {
+				return err
+			}
+			if status != 0 {
+				cmd.SilenceErrors = true
+				return exitcode.Status(status)
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.FieldSeparator, "field-separator", "F", " ", "Field separator")
+	// Options end at the program so that operands are left alone
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().StringVarP(&opts.FieldSeparator, "field-separator", "F", "", "Use `FS` as the input field separator")
+	cmd.Flags().BoolVar(&opts.CSV, "csv", false, "Read and write comma-separated values with quoting")
+	cmd.Flags().BoolVar(&opts.TSV, "tsv", false, "Read and write tab-separated values with escapes")
+	cmd.Flags().StringArrayVarP(&opts.ProgramFiles, "file", "f", nil, "Read the program from `FILE` instead of the first operand (repeatable)")
+	cmd.Flags().StringArrayVarP(&opts.Assignments, "assign", "v", nil, "Assign `VAR=VALUE` before the program starts (repeatable)")
 
 	return cmd
 }
 
-// processFile processes a file
-func processFile(filename string, opts *Options) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("cannot open '%s': %w", filename, err)
-	}
-	defer file.Close()
-
-	return processInput(file, opts)
-}
-
-// processInput processes input stream
-func processInput(reader io.Reader, opts *Options) error {
-	program, err := parseProgram(opts.Program)
-	if err != nil {
-		return err
-	}
-
-	ctx := &Context{
-		FS: opts.FieldSeparator,
-	}
-
-	// Execute BEGIN
-	if program.Begin != nil {
-		if err := program.Begin.Execute(ctx); err != nil {
-			return err
-		}
-	}
-
-	// Process lines
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		ctx.NR++
-		ctx.Line = scanner.Text()
-		ctx.Fields = splitFields(ctx.Line, ctx.FS)
-		ctx.NF = len(ctx.Fields)
-
-		// Execute rules
-		for _, rule := range program.Rules {
-			if rule.Pattern == nil || rule.Pattern.Match(ctx) {
-				if err := rule.Action.Execute(ctx); err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	// Execute END
-	if program.End != nil {
-		if err := program.End.Execute(ctx); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-// Program represents awk program
-type Program struct {
-	Begin *Action
-	Rules []*Rule
-	End   *Action
-}
-
-// Rule represents pattern-action rule
-type Rule struct {
-	Pattern Pattern
-	Action  *Action
-}
-
-// Pattern interface
-type Pattern interface {
-	Match(ctx *Context) bool
-}
-
-// AlwaysPattern always matches
-type AlwaysPattern struct{}
-
-func (p *AlwaysPattern) Match(ctx *Context) bool {
-	return true
-}
-
-// RegexPattern matches regex
-type RegexPattern struct {
-	Regex *regexp.Regexp
-}
-
-func (p *RegexPattern) Match(ctx *Context) bool {
-	return p.Regex.MatchString(ctx.Line)
-}
-
-// LinePattern matches specific line
-type LinePattern struct {
-	LineNumber int
-}
-
-func (p *LinePattern) Match(ctx *Context) bool {
-	return ctx.NR == p.LineNumber
-}
-
-// Action represents action to execute
-type Action struct {
-	Statements []Statement
-	Variables  map[string]float64
-}
-
-// Execute executes action
-func (a *Action) Execute(ctx *Context) error {
-	if a.Variables == nil {
-		a.Variables = make(map[string]float64)
-	}
-
-	for _, stmt := range a.Statements {
-		if err := stmt.Execute(ctx, a.Variables); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// Statement interface
-type Statement interface {
-	Execute(ctx *Context, vars map[string]float64) error
-}
-
-// PrintStatement prints fields
-type PrintStatement struct {
-	Fields []FieldRef
-}
-
-func (s *PrintStatement) Execute(ctx *Context, vars map[string]float64) error {
-	if len(s.Fields) == 0 {
-		fmt.Println(ctx.Line)
-		return nil
-	}
-
-	parts := make([]string, len(s.Fields))
-	for i, field := range s.Fields {
-		parts[i] = field.GetValue(ctx, vars)
-	}
-	fmt.Println(strings.Join(parts, " "))
-	return nil
-}
-
-// AssignStatement assigns value to variable
-type AssignStatement struct {
-	Variable string
-	Expr     Expression
-}
-
-func (s *AssignStatement) Execute(ctx *Context, vars map[string]float64) error {
-	value := s.Expr.Evaluate(ctx, vars)
-	vars[s.Variable] = value
-	return nil
-}
-
-// Expression interface
-type Expression interface {
-	Evaluate(ctx *Context, vars map[string]float64) float64
-}
-
-// FieldExpression evaluates field value
-type FieldExpression struct {
-	FieldNum int
-}
-
-func (e *FieldExpression) Evaluate(ctx *Context, vars map[string]float64) float64 {
-	if e.FieldNum == 0 {
-		return 0
-	}
-	if e.FieldNum > 0 && e.FieldNum <= len(ctx.Fields) {
-		val, _ := strconv.ParseFloat(ctx.Fields[e.FieldNum-1], 64)
-		return val
-	}
-	return 0
-}
-
-// VariableExpression evaluates variable
-type VariableExpression struct {
-	Name string
-}
-
-func (e *VariableExpression) Evaluate(ctx *Context, vars map[string]float64) float64 {
-	return vars[e.Name]
-}
-
-// BinaryExpression evaluates binary operation
-type BinaryExpression struct {
-	Left  Expression
-	Op    string
-	Right Expression
-}
-
-func (e *BinaryExpression) Evaluate(ctx *Context, vars map[string]float64) float64 {
-	left := e.Left.Evaluate(ctx, vars)
-	right := e.Right.Evaluate(ctx, vars)
-
-	switch e.Op {
-	case "+":
-		return left + right
-	case "-":
-		return left - right
-	case "*":
-		return left * right
-	case "/":
-		if right != 0 {
-			return left / right
-		}
-		return 0
-	default:
-		return 0
-	}
-}
-
-// FieldRef references a field
-type FieldRef struct {
-	Field int
-	Var   string
-}
-
-func (f *FieldRef) GetValue(ctx *Context, vars map[string]float64) string {
-	if f.Var != "" {
-		return fmt.Sprintf("%v", vars[f.Var])
-	}
-	if f.Field == 0 {
-		return ctx.Line
-	}
-	if f.Field > 0 && f.Field <= len(ctx.Fields) {
-		return ctx.Fields[f.Field-1]
-	}
-	return ""
-}
-
-// parseProgram parses awk program
-func parseProgram(prog string) (*Program, error) {
-	prog = strings.TrimSpace(prog)
-
-	program := &Program{
-		Rules: make([]*Rule, 0),
-	}
-
-	// Parse BEGIN
-	if strings.HasPrefix(prog, "BEGIN") {
-		endIdx := strings.Index(prog, "}")
-		if endIdx == -1 {
-			return nil, fmt.Errorf("missing closing brace for BEGIN")
+// expandOperands expands wildcard file operands as glob.Expand does,
+// leaving var=value assignments alone
+func expandOperands(args []string) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		if name, _, ok := strings.Cut(arg, "="); ok && isName(name) {
+			result = append(result, arg)
+			continue
 		}
-		actionStr := prog[5 : endIdx+1]
-		action, err := parseAction(actionStr)
-		if err != nil {
-			return nil, err
-		}
-		program.Begin = action
-		prog = strings.TrimSpace(prog[endIdx+1:])
+		result = append(result, glob.Expand([]string{arg})...)
 	}
-
-	// Parse END
-	if idx := strings.Index(prog, "END"); idx >= 0 {
-		actionStr := prog[idx+3:]
-		action, err := parseAction(actionStr)
-		if err != nil {
-			return nil, err
-		}
-		program.End = action
-		prog = strings.TrimSpace(prog[:idx])
-	}
-
-	// Parse rules
-	if prog != "" {
-		rule, err := parseRule(prog)
-		if err != nil {
-			return nil, err
-		}
-		program.Rules = append(program.Rules, rule)
-	}
-
-	return program, nil
+	return result
 }
 
-// parseRule parses pattern-action rule
-func parseRule(ruleStr string) (*Rule, error) {
-	ruleStr = strings.TrimSpace(ruleStr)
-
-	rule := &Rule{}
-
-	// Parse pattern
-	if strings.HasPrefix(ruleStr, "/") {
-		endIdx := strings.Index(ruleStr[1:], "/")
-		if endIdx == -1 {
-			return nil, fmt.Errorf("missing closing / for pattern")
-		}
-		patternStr := ruleStr[1 : endIdx+1]
-		regex, err := regexp.Compile(patternStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid regex: %w", err)
-		}
-		rule.Pattern = &RegexPattern{Regex: regex}
-		ruleStr = strings.TrimSpace(ruleStr[endIdx+2:])
-	} else if strings.HasPrefix(ruleStr, "NR==") {
-		// Line number pattern
-		parts := strings.SplitN(ruleStr, " ", 2)
-		lineStr := strings.TrimPrefix(parts[0], "NR==")
-		lineNum, err := strconv.Atoi(lineStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid line number: %s", lineStr)
-		}
-		rule.Pattern = &LinePattern{LineNumber: lineNum}
-		if len(parts) > 1 {
-			ruleStr = strings.TrimSpace(parts[1])
-		} else {
-			ruleStr = ""
+// Run parses the program and runs it over the operands in args, returning
+// the exit status the program set
+func Run(ctx context.Context, opts *Options, args []string) (int, error) {
+	src := opts.Program
+	if len(opts.ProgramFiles) > 0 {
+		var b strings.Builder
+		for _, name := range opts.ProgramFiles {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return 0, exitcode.New(2, fmt.Errorf("cannot read program: %w", err))
+			}
+			b.Write(data)
+			b.WriteByte('\n')
 		}
-	} else {
-		rule.Pattern = &AlwaysPattern{}
+		src = b.String()
 	}
 
-	// Parse action
-	if ruleStr != "" {
-		action, err := parseAction(ruleStr)
-		if err != nil {
-			return nil, err
-		}
-		rule.Action = action
-	} else {
-		// Default action: print
-		rule.Action = &Action{
-			Statements: []Statement{&PrintStatement{}},
-		}
+	if opts.CSV && opts.TSV || (opts.CSV || opts.TSV) && opts.FieldSeparator != "" {
+		return 0, exitcode.New(2, errors.New("only one of --csv, --tsv and -F can be given"))
 	}
 
-	return rule, nil
-}
-
-// parseAction parses action block
-func parseAction(actionStr string) (*Action, error) {
-	actionStr = strings.TrimSpace(actionStr)
-	if !strings.HasPrefix(actionStr, "{") || !strings.HasSuffix(actionStr, "}") {
-		return nil, fmt.Errorf("action must be enclosed in braces")
-	}
-
-	actionStr = actionStr[1 : len(actionStr)-1]
-	actionStr = strings.TrimSpace(actionStr)
-
-	action := &Action{
-		Statements: make([]Statement, 0),
-	}
-
-	// Parse statements
-	if actionStr != "" {
-		stmt, err := parseStatement(actionStr)
-		if err != nil {
-			return nil, err
-		}
-		action.Statements = append(action.Statements, stmt)
+	prog, err := parse(src)
+	if err != nil {
+		return 0, exitcode.New(2, err)
 	}
 
-	return action, nil
-}
-
-// parseStatement parses statement
-func parseStatement(stmtStr string) (Statement, error) {
-	stmtStr = strings.TrimSpace(stmtStr)
-
-	// Print statement
-	if strings.HasPrefix(stmtStr, "print") {
-		return parsePrint(stmtStr)
+	in := newInterp(ctx, prog, args)
+	switch {
+	case opts.CSV:
+		in.format = csvFields
+		in.setSpecial(spFS, str(","))
+		in.setSpecial(spOFS, str(","))
+	case opts.TSV:
+		in.format = tsvFields
+		in.setSpecial(spFS, str("\t"))
+		in.setSpecial(spOFS, str("\t"))
 	}
-
-	// Assignment: var+=expr or var=expr
-	if strings.Contains(stmtStr, "+=") {
-		parts := strings.SplitN(stmtStr, "+=", 2)
-		varName := strings.TrimSpace(parts[0])
-		exprStr := strings.TrimSpace(parts[1])
-		expr, err := parseExpression(exprStr)
-		if err != nil {
-			return nil, err
+	if opts.FieldSeparator != "" {
+		fs := unescape(opts.FieldSeparator)
+		if fs == "t" {
+			fs = "\t"
 		}
-		return &AssignStatement{
-			Variable: varName,
-			Expr: &BinaryExpression{
-				Left:  &VariableExpression{Name: varName},
-				Op:    "+",
-				Right: expr,
-			},
-		}, nil
-	}
-
-	return nil, fmt.Errorf("unsupported statement: %s", stmtStr)
-}
-
-// parsePrint parses print statement
-func parsePrint(printStr string) (*PrintStatement, error) {
-	printStr = strings.TrimPrefix(printStr, "print")
-	printStr = strings.TrimSpace(printStr)
-
-	stmt := &PrintStatement{
-		Fields: make([]FieldRef, 0),
+		in.setSpecial(spFS, str(fs))
 	}
-
-	if printStr == "" || printStr == "$0" {
-		return stmt, nil
-	}
-
-	// Parse field list
-	fields := strings.Split(printStr, ",")
-	for _, field := range fields {
-		field = strings.TrimSpace(field)
-		if strings.HasPrefix(field, "$") {
-			fieldNum, err := strconv.Atoi(field[1:])
-			if err != nil {
-				return nil, fmt.Errorf("invalid field: %s", field)
-			}
-			stmt.Fields = append(stmt.Fields, FieldRef{Field: fieldNum})
-		} else {
-			stmt.Fields = append(stmt.Fields, FieldRef{Var: field})
+	for _, assignment := range opts.Assignments {
+		name, val, ok := strings.Cut(assignment, "=")
+		if !ok || !isName(name) {
+			return 0, exitcode.New(2, fmt.Errorf("invalid -v argument %q: expected VAR=VALUE", assignment))
 		}
+		in.setVar(name, val)
 	}
 
-	return stmt, nil
-}
-
-// parseExpression parses expression
-func parseExpression(exprStr string) (Expression, error) {
-	exprStr = strings.TrimSpace(exprStr)
-
-	// Field reference
-	if strings.HasPrefix(exprStr, "$") {
-		fieldNum, err := strconv.Atoi(exprStr[1:])
-		if err != nil {
-			return nil, fmt.Errorf("invalid field: %s", exprStr)
+	if err := in.run(); err != nil {
+		if interrupt.Interrupted(err) {
+			return 0, err
 		}
-		return &FieldExpression{FieldNum: fieldNum}, nil
-	}
-
-	// Variable
-	return &VariableExpression{Name: exprStr}, nil
-}
-
-// splitFields splits line into fields
-func splitFields(line, sep string) []string {
-	if sep == " " {
-		return strings.Fields(line)
+		return 0, exitcode.New(2, err)
 	}
-	return strings.Split(line, sep)
+	return in.exitCode, nil
 }
@@ -0,0 +1,253 @@
+package awk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// runAwk runs program over content and returns what was printed and the
+// exit status
+func runAwk(t *testing.T, program, content string, opts *Options) (string, int) {
+	tempDir := t.TempDir()
+	input := filepath.Join(tempDir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte(content), 0644))
+
+	out, err := os.Create(filepath.Join(tempDir, "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	if opts == nil {
+		opts = &Options{}
+	}
+	opts.Program = program
+	status, err := Run(context.Background(), opts, []string{input})
+	require.NoError(t, err)
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed), status
+}
+
+const people = "alice 30 paris\nbob 25 london\ncarol 35 paris\ndave 40 berlin\n"
+
+// TestRun_Programs tests programs against their expected output
+func TestRun_Programs(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		want    string
+	}{
+		{"print fields", `{print $1, $NF}`, "alice paris\nbob london\ncarol paris\ndave berlin\n"},
+		{"bare pattern", `$2 > 30`, "carol 35 paris\ndave 40 berlin\n"},
+		{"regex pattern", `/^b/ || /^d/ {print NR}`, "2\n4\n"},
+		{"range", `/bob/,/carol/`, "bob 25 london\ncarol 35 paris\n"},
+		{"aggregate", `{n[$3]++; s[$3] += $2} END {for (k in n) print k, n[k], s[k]}`, "berlin 1 40\nlondon 1 25\nparis 2 65\n"},
+		{"numeric keys in order", `{a[NR * 5] = $1} END {for (k in a) print k, a[k]}`, "5 alice\n10 bob\n15 carol\n20 dave\n"},
+		{"assign field", `{$2 = "-"; print; print NF}`, "alice - paris\n3\nbob - london\n3\ncarol - paris\n3\ndave - berlin\n3\n"},
+		{"set NF", `NR == 1 {NF = 2; print; NF = 4; $4 = "x"; print}`, "alice 30\nalice 30  x\n"},
+		{"OFS", `BEGIN {OFS = "-"} {$1 = $1} NR == 2`, "bob-25-london\n"},
+		{"next", `NR % 2 {next} {print $1}`, "bob\ndave\n"},
+		{"exit runs END", `NR == 2 {exit} END {print NR}`, "2\n"},
+		{"getline", `NR == 1 {getline; print $1; getline line; print line}`, "bob\ncarol 35 paris\n"},
+		{"functions", `function fib(n) {return n < 2 ? n : fib(n-1) + fib(n-2)} BEGIN {print fib(15)}`, "610\n"},
+		{"arrays by reference", `function add(a, k) {a[k]++} {add(seen, $3)} END {print length(seen), seen["paris"]}`, "3 2\n"},
+		{"locals", `function f(x,   i) {i = x * 2; return i} BEGIN {i = 1; print f(3), i}`, "6 1\n"},
+		{"loops", `BEGIN {for (i = 0; i < 3; i++) s = s i; while (j < 2) j++; do k++; while (k < 5); print s, j, k}`, "012 2 5\n"},
+		{"multiple subscripts", `BEGIN {a[1, 2] = 3; for (k in a) {split(k, p, SUBSEP); print p[1], p[2], a[k]}; print ((1, 2) in a)}`, "1 2 3\n1\n"},
+		{"delete", `BEGIN {a[1]; a[2]; delete a[1]; print length(a), (1 in a); delete a; print length(a)}`, "1 0\n0\n"},
+		{"printf", `BEGIN {printf "%-5s|%5.2f|%03d|%x|%c|%s%%\n", "ab", 3.14159, 7, 255, 65, "z"}`, "ab   | 3.14|007|ff|A|z%\n"},
+		{"sprintf star", `BEGIN {print sprintf("[%*d] [%.*f]", 4, 7, 1, 2.25)}`, "[   7] [2.2]\n"},
+		{"string functions", `BEGIN {print length("héllo"), substr("hello", 2, 3), index("hello", "ll"), toupper("ab")}`, "5 ell 3 AB\n"},
+		{"substr rounding", `BEGIN {print substr("hello", 0, 2) "|" substr("hello", -1, 3) "|" substr("hello", 1.5)}`, "h|h|ello\n"},
+		{"sub and gsub", `BEGIN {s = "banana"; n = gsub(/a/, "[&]", s); t = "a.b"; sub(".", "\\&", t); print n, s, t}`, "3 b[a]n[a]n[a] &.b\n"},
+		{"match", `BEGIN {print match("foobar", /o+/), RSTART, RLENGTH; print match("x", /y/), RLENGTH}`, "2 2 2\n0 -1\n"},
+		{"split", `BEGIN {n = split("a:b::c", p, ":"); print n, p[4]; n = split("  x  y ", q); print n, q[1]}`, "4 c\n2 x\n"},
+		{"dynamic regex", `BEGIN {re = "^a.c$"; print ("abc" ~ re), ("abd" ~ re), ("x" !~ "y")}`, "1 0 1\n"},
+		{"comparison", `BEGIN {print ("10" < "9"), (10 < 9), ("abc" < "abd"); x = "10"; y = 9; print (x > y), (x + 0 > y)}`, "1 0 1\n0 1\n"},
+		{"field comparison is numeric", `$2 == 30.0 {print $1}`, "alice\n"},
+		{"uninitialized", `BEGIN {print length(x), x + 0, (x == 0), (x == "")}`, "0 0 1 1\n"},
+		{"number output", `BEGIN {print 0.1 + 0.2, 1e6, 100000 * 100000, 1/3; OFMT = "%.2f"; print 1/3; CONVFMT = "%d"; x = 2.5 ""; print x}`, "0.3 1000000 10000000000 0.333333\n0.33\n2\n"},
+		{"arithmetic", `BEGIN {print 7 % 3, -7 % 3, 2^10, 2^3^2, -2^2, 1 - 1 "2"}`, "1 -1 1024 512 -4 02\n"},
+		{"increment", `BEGIN {x = 5; print x++, x, ++x, x--, --x}`, "5 6 7 7 5\n"},
+		{"compound assignment", `{s += $2; p *= 1} END {print s, p}`, "130 0\n"},
+		{"paragraph mode", `BEGIN {RS = ""} {print NR ": " $1 " " NF}`, "1: alice 12\n"},
+		{"regex with slash", `BEGIN {print ("a/b" ~ /a\/b/)}`, "1\n"},
+		{"getline var from file", `BEGIN {while ((getline line < ARGV[1]) > 0) n++; print n}`, "4\n"},
+		{"print to stdout", `NR == 1 {print "x" > "/dev/stdout"}`, "x\n"},
+		{"division not regex", `{a = $2 / 5 / 2} END {print a}`, "4\n"},
+		{"length of record", `NR == 2 {print length, length()}`, "13 13\n"},
+		{"nextfile", `{print FNR; nextfile}`, "1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, status := runAwk(t, tt.program, people, nil)
+			assert.Equal(t, tt.want, out)
+			assert.Equal(t, 0, status)
+		})
+	}
+}
+
+// TestRun_Options tests -F and -v
+func TestRun_Options(t *testing.T) {
+	out, _ := runAwk(t, `{print $2}`, "a,b,c\nd,e,f\n", &Options{FieldSeparator: ","})
+	assert.Equal(t, "b\ne\n", out)
+
+	out, _ = runAwk(t, `{print $2}`, "a\tb c\td\n", &Options{FieldSeparator: `\t`})
+	assert.Equal(t, "b c\n", out)
+
+	out, _ = runAwk(t, `{print NF}`, "a1b22c\n", &Options{FieldSeparator: "[0-9]+"})
+	assert.Equal(t, "3\n", out)
+
+	out, _ = runAwk(t, `$2 > limit {print $1}`, people, &Options{Assignments: []string{"limit=32"}})
+	assert.Equal(t, "carol\ndave\n", out)
+
+	out, _ = runAwk(t, `BEGIN {printf "%s", s}`, "", &Options{Assignments: []string{`s=a\tb\n`}})
+	assert.Equal(t, "a\tb\n", out)
+}
+
+// TestRun_ExitStatus tests that exit sets the status and END still runs
+func TestRun_ExitStatus(t *testing.T) {
+	out, status := runAwk(t, `BEGIN {exit 3} END {print "end"}`, people, nil)
+	assert.Equal(t, "end\n", out)
+	assert.Equal(t, 3, status)
+
+	out, status = runAwk(t, `function stop() {exit 4} NR == 2 {stop()} {print NR} END {print "done"}`, people, nil)
+	assert.Equal(t, "1\ndone\n", out)
+	assert.Equal(t, 4, status)
+}
+
+// TestRun_ProgramFile tests reading the program with -f
+func TestRun_ProgramFile(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "prog.awk")
+	require.NoError(t, os.WriteFile(program, []byte("# count cities\n{ n[$3]++ }\nEND {\n\tprint n[\"paris\"]\n}\n"), 0644))
+
+	out, _ := runAwk(t, "", people, &Options{ProgramFiles: []string{program}})
+	assert.Equal(t, "2\n", out)
+}
+
+// TestRun_RuntimeError tests that runtime errors stop the program
+func TestRun_RuntimeError(t *testing.T) {
+	_, err := Run(context.Background(), &Options{Program: `BEGIN {print 1 / 0}`}, nil)
+	assert.ErrorContains(t, err, "division by zero")
+}
+
+// TestParse_Errors tests that invalid programs are rejected with the line
+// of the problem
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		program string
+		want    string
+	}{
+		{`{print $1`, "line 1: unexpected end of program"},
+		{"BEGIN {\n x = 1 +* 2 }", "line 2: unexpected *"},
+		{`BEGIN {next}`, "next used outside a rule"},
+		{`BEGIN {break}`, "break"},
+		{`BEGIN {f()}`, "undefined function f"},
+		{`BEGIN {a[1] = 1; a = 2}`, "array a"},
+		{`function f(a) {a[1] = 1} BEGIN {f(1)}`, "expects an array"},
+		{`BEGIN {print substr("x")}`, "wrong number of arguments to substr"},
+		{`BEGIN {x = "unterminated}`, "unterminated string"},
+		{`/(/`, "invalid regex"},
+	}
+
+	for _, tt := range tests {
+		_, err := parse(tt.program)
+		if assert.Error(t, err, tt.program) {
+			assert.Contains(t, err.Error(), tt.want, tt.program)
+		}
+	}
+}
+
+// TestStrToNum tests converting strings to numbers by their numeric prefix
+func TestStrToNum(t *testing.T) {
+	tests := map[string]float64{
+		"42":       42,
+		" -3.5e2x": -350,
+		"+7":       7,
+		".5":       0.5,
+		"1e":       1,
+		"abc":      0,
+		"":         0,
+		"0x1A":     0,
+	}
+	for s, want := range tests {
+		assert.Equal(t, want, strToNum(s), s)
+	}
+
+	assert.True(t, looksNumeric(" 12 "))
+	assert.False(t, looksNumeric("12abc"))
+	assert.False(t, looksNumeric(""))
+}
+
+// TestRun_CSV tests that --csv splits quoted fields, reads records across
+// lines and quotes fields on output
+func TestRun_CSV(t *testing.T) {
+	content := "name,city,note\r\n\"Smith, Jo\",Paris,\"said \"\"hi\"\"\"\r\nLee,\"New\nYork\",\r\n"
+	csv := func() *Options { return &Options{CSV: true} }
+
+	out, _ := runAwk(t, `{print NF, $1}`, content, csv())
+	assert.Equal(t, "3,name\n3,\"Smith, Jo\"\n3,Lee\n", out)
+
+	out, _ = runAwk(t, `NR > 1 {print $3}`, content, csv())
+	assert.Equal(t, "\"said \"\"hi\"\"\"\n\n", out)
+
+	out, _ = runAwk(t, `NR == 3 {print $2, length($2)}`, content, csv())
+	assert.Equal(t, "\"New\nYork\",8\n", out)
+
+	// Assigning a field rebuilds $0 with quotes where needed
+	out, _ = runAwk(t, `NR == 2 {$2 = "Lyon, FR"; print}`, content, csv())
+	assert.Equal(t, "\"Smith, Jo\",\"Lyon, FR\",\"said \"\"hi\"\"\"\n", out)
+
+	// Quoting follows OFS
+	out, _ = runAwk(t, `BEGIN {OFS = ";"} NR == 2 {print $1, $2}`, content, csv())
+	assert.Equal(t, "Smith, Jo;Paris\n", out)
+}
+
+// TestRun_TSV tests that --tsv splits on tabs and unescapes fields
+func TestRun_TSV(t *testing.T) {
+	out, _ := runAwk(t, `{print $2; $1 = "x\ty"; print}`, "a b\tline\\none\\\\\n", &Options{TSV: true})
+	assert.Equal(t, "line\\none\\\\\nx\\ty\tline\\none\\\\\n", out)
+
+	out, _ = runAwk(t, `{print length($2)}`, "a b\tline\\none\n", &Options{TSV: true})
+	assert.Equal(t, "8\n", out)
+}
+
+// TestRun_CSVConflicts tests that --csv, --tsv and -F exclude each other
+func TestRun_CSVConflicts(t *testing.T) {
+	for _, opts := range []*Options{{CSV: true, TSV: true}, {CSV: true, FieldSeparator: ";"}, {TSV: true, FieldSeparator: ","}} {
+		opts.Program = "{print}"
+		_, err := Run(context.Background(), opts, nil)
+		assert.Equal(t, 2, exitcode.From(err))
+	}
+}
+
+// TestExpandOperands tests expanding wildcard file operands while leaving
+// assignments alone
+func TestExpandOperands(t *testing.T) {
+	old := glob.Force
+	defer func() { glob.Force = old }()
+	glob.Force = true
+
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"a.log", "b.log", "x=1.log"} {
+		require.NoError(t, os.WriteFile(name, nil, 0644))
+	}
+
+	assert.Equal(t, []string{"x=*.log", "a.log", "b.log", "x=1.log", "-"},
+		expandOperands([]string{"x=*.log", "[ab].log", "x=1.log", "-"}))
+}
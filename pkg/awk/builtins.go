@@ -0,0 +1,418 @@
+package awk
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// builtin is a built-in function
+type builtin int
+
+const (
+	bLength builtin = iota
+	bSubstr
+	bIndex
+	bSplit
+	bSub
+	bGsub
+	bMatch
+	bSprintf
+	bSin
+	bCos
+	bAtan2
+	bExp
+	bLog
+	bSqrt
+	bInt
+	bRand
+	bSrand
+	bTolower
+	bToupper
+	bSystem
+	bClose
+	bFflush
+)
+
+// builtins maps the names of built-in functions to them
+var builtins = map[string]builtin{
+	"length":  bLength,
+	"substr":  bSubstr,
+	"index":   bIndex,
+	"split":   bSplit,
+	"sub":     bSub,
+	"gsub":    bGsub,
+	"match":   bMatch,
+	"sprintf": bSprintf,
+	"sin":     bSin,
+	"cos":     bCos,
+	"atan2":   bAtan2,
+	"exp":     bExp,
+	"log":     bLog,
+	"sqrt":    bSqrt,
+	"int":     bInt,
+	"rand":    bRand,
+	"srand":   bSrand,
+	"tolower": bTolower,
+	"toupper": bToupper,
+	"system":  bSystem,
+	"close":   bClose,
+	"fflush":  bFflush,
+}
+
+// builtinArity gives the least and most arguments of each built-in
+// function, -1 for no limit
+var builtinArity = map[builtin][2]int{
+	bLength:  {0, 1},
+	bSubstr:  {2, 3},
+	bIndex:   {2, 2},
+	bSplit:   {2, 3},
+	bSub:     {2, 3},
+	bGsub:    {2, 3},
+	bMatch:   {2, 2},
+	bSprintf: {1, -1},
+	bSin:     {1, 1},
+	bCos:     {1, 1},
+	bAtan2:   {2, 2},
+	bExp:     {1, 1},
+	bLog:     {1, 1},
+	bSqrt:    {1, 1},
+	bInt:     {1, 1},
+	bRand:    {0, 0},
+	bSrand:   {0, 1},
+	bTolower: {1, 1},
+	bToupper: {1, 1},
+	bSystem:  {1, 1},
+	bClose:   {1, 1},
+	bFflush:  {0, 1},
+}
+
+// builtin calls a built-in function
+func (in *interp) builtin(e *builtinExpr) value {
+	switch e.fn {
+	case bLength:
+		if len(e.args) == 0 {
+			return num(float64(utf8.RuneCountInString(in.getRecord())))
+		}
+		if v, ok := e.args[0].(*varExpr); ok && v.array {
+			return num(float64(len(in.array(v))))
+		}
+		return num(float64(utf8.RuneCountInString(in.str(e.args[0]))))
+
+	case bSubstr:
+		return str(in.substr(e.args))
+
+	case bIndex:
+		s, t := in.str(e.args[0]), in.str(e.args[1])
+		i := strings.Index(s, t)
+		if i < 0 {
+			return num(0)
+		}
+		return num(float64(utf8.RuneCountInString(s[:i]) + 1))
+
+	case bSplit:
+		return num(float64(in.splitArray(e.args)))
+
+	case bSub, bGsub:
+		return num(float64(in.substitute(e.fn == bGsub, e.args)))
+
+	case bMatch:
+		s := in.str(e.args[0])
+		in.rstart, in.rlength = 0, -1
+		if loc := in.regexArg(e.args[1]).re.FindStringIndex(s); loc != nil {
+			in.rstart = utf8.RuneCountInString(s[:loc[0]]) + 1
+			in.rlength = utf8.RuneCountInString(s[loc[0]:loc[1]])
+		}
+		return num(float64(in.rstart))
+
+	case bSprintf:
+		args := make([]value, len(e.args)-1)
+		for i, arg := range e.args[1:] {
+			args[i] = in.eval(arg)
+		}
+		return str(sprintf(in.str(e.args[0]), args, in.convfmt))
+
+	case bSin:
+		return num(math.Sin(in.eval(e.args[0]).num()))
+	case bCos:
+		return num(math.Cos(in.eval(e.args[0]).num()))
+	case bAtan2:
+		return num(math.Atan2(in.eval(e.args[0]).num(), in.eval(e.args[1]).num()))
+	case bExp:
+		return num(math.Exp(in.eval(e.args[0]).num()))
+	case bLog:
+		return num(math.Log(in.eval(e.args[0]).num()))
+	case bSqrt:
+		return num(math.Sqrt(in.eval(e.args[0]).num()))
+	case bInt:
+		return num(math.Trunc(in.eval(e.args[0]).num()))
+
+	case bRand:
+		return num(in.random.Float64())
+
+	case bSrand:
+		prev := in.seed
+		if len(e.args) == 0 {
+			in.seed = float64(time.Now().Unix())
+		} else {
+			in.seed = in.eval(e.args[0]).num()
+		}
+		in.random = rand.New(rand.NewSource(int64(in.seed)))
+		return num(prev)
+
+	case bTolower:
+		return str(strings.ToLower(in.str(e.args[0])))
+	case bToupper:
+		return str(strings.ToUpper(in.str(e.args[0])))
+
+	case bSystem:
+		return num(float64(in.system(in.str(e.args[0]))))
+
+	case bClose:
+		return num(float64(in.closeStream(in.str(e.args[0]))))
+
+	case bFflush:
+		if len(e.args) == 0 {
+			return num(float64(in.flush("")))
+		}
+		return num(float64(in.flush(in.str(e.args[0]))))
+	}
+
+	in.fatal("unknown built-in function %d", e.fn)
+	return value{}
+}
+
+// str evaluates an expression as a string
+func (in *interp) str(e expr) string {
+	return in.toStr(in.eval(e))
+}
+
+// regexArg returns the regex a built-in function was given: a literal, or
+// a string used as a dynamic regex
+func (in *interp) regexArg(e expr) *matcher {
+	if re, ok := e.(*regexExpr); ok {
+		return re.re
+	}
+	return in.regex(in.str(e))
+}
+
+// substr returns the characters of s from position m, n of them or all
+// the rest, with positions rounded as POSIX specifies
+func (in *interp) substr(args []expr) string {
+	s := in.str(args[0])
+	runes := utf8.RuneCountInString(s)
+
+	start := math.RoundToEven(in.eval(args[1]).num())
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + math.RoundToEven(in.eval(args[2]).num())
+	}
+	if math.IsNaN(start) || math.IsNaN(end) {
+		return ""
+	}
+	start = math.Max(start, 1)
+	end = math.Min(end, float64(runes+1))
+	if end <= start {
+		return ""
+	}
+
+	if runes == len(s) {
+		return s[int(start)-1 : int(end)-1]
+	}
+	r := []rune(s)
+	return string(r[int(start)-1 : int(end)-1])
+}
+
+// splitArray runs split(s, a [, fs]), returning the number of elements
+func (in *interp) splitArray(args []expr) int {
+	s := in.str(args[0])
+	a := in.array(args[1].(*varExpr))
+
+	var parts []string
+	switch {
+	case len(args) < 3:
+		parts = in.splitFields(nil, s, in.fs)
+	default:
+		if re, ok := args[2].(*regexExpr); ok {
+			parts = in.splitRegex(nil, s, re.re)
+		} else {
+			parts = in.splitFields(nil, s, in.str(args[2]))
+		}
+	}
+
+	clear(a)
+	for i, part := range parts {
+		a[strconv.Itoa(i+1)] = &value{typ: typeStrnum, s: part}
+	}
+	return len(parts)
+}
+
+// substitute runs sub or, with global set, gsub, returning the number of
+// replacements
+func (in *interp) substitute(global bool, args []expr) int {
+	re := in.regexArg(args[0])
+	repl := in.str(args[1])
+
+	var target expr = &fieldExpr{index: &numExpr{0}}
+	if len(args) == 3 {
+		target = args[2]
+	}
+	s := in.str(target)
+
+	n := 1
+	if global {
+		n = -1
+	}
+	locs := re.re.FindAllStringIndex(s, n)
+	if len(locs) == 0 {
+		return 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(s[last:loc[0]])
+		matched := s[loc[0]:loc[1]]
+		// & stands for the match, \& for an ampersand and \\ for a
+		// backslash
+		for i := 0; i < len(repl); i++ {
+			switch c := repl[i]; {
+			case c == '\\' && i+1 < len(repl) && (repl[i+1] == '&' || repl[i+1] == '\\'):
+				i++
+				b.WriteByte(repl[i])
+			case c == '&':
+				b.WriteString(matched)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+
+	in.assignTo(target, str(b.String()))
+	return len(locs)
+}
+
+// sprintf formats args as printf does, converting numbers printed with %s
+// by convfmt
+func sprintf(format string, args []value, convfmt string) string {
+	var b strings.Builder
+	next := func() value {
+		if len(args) == 0 {
+			return value{}
+		}
+		v := args[0]
+		args = args[1:]
+		return v
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		start := i
+		i++
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+
+		// Flags, width and precision are collected into a spec for fmt,
+		// with * replaced by the argument it takes
+		spec := []byte{'%'}
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			spec = append(spec, format[i])
+			i++
+		}
+		if i < len(format) && format[i] == '*' {
+			width := int(next().num())
+			if width < 0 {
+				spec = append(spec, '-')
+				width = -width
+			}
+			spec = strconv.AppendInt(spec, int64(width), 10)
+			i++
+		} else {
+			for i < len(format) && isDigit(format[i]) {
+				spec = append(spec, format[i])
+				i++
+			}
+		}
+		precision := false
+		if i < len(format) && format[i] == '.' {
+			precision = true
+			spec = append(spec, '.')
+			i++
+			if i < len(format) && format[i] == '*' {
+				spec = strconv.AppendInt(spec, int64(max(int(next().num()), 0)), 10)
+				i++
+			} else {
+				for i < len(format) && isDigit(format[i]) {
+					spec = append(spec, format[i])
+					i++
+				}
+			}
+		}
+		for i < len(format) && strings.IndexByte("hlLqjzt", format[i]) >= 0 {
+			i++
+		}
+		if i >= len(format) {
+			b.WriteString(format[start:])
+			break
+		}
+
+		switch verb := format[i]; verb {
+		case 'd', 'i':
+			n := next().num()
+			if math.IsNaN(n) || math.IsInf(n, 0) {
+				fmt.Fprintf(&b, string(append(spec, 'v')), numToStr(n, convfmt))
+				break
+			}
+			fmt.Fprintf(&b, string(append(spec, 'd')), int64(n))
+
+		case 'o', 'x', 'X', 'u':
+			n := next().num()
+			u := uint64(int64(n))
+			if n >= 1<<63 {
+				u = uint64(n)
+			}
+			if verb == 'u' {
+				verb = 'd'
+			}
+			fmt.Fprintf(&b, string(append(spec, verb)), u)
+
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			if !precision {
+				spec = append(spec, ".6"...)
+			}
+			if verb == 'F' {
+				verb = 'f'
+			}
+			fmt.Fprintf(&b, string(append(spec, verb)), next().num())
+
+		case 'c':
+			v := next()
+			var s string
+			if v.typ == typeNum || v.typ == typeStrnum && looksNumeric(v.s) {
+				s = string(rune(int(v.num())))
+			} else if r, size := utf8.DecodeRuneInString(v.s); size > 0 {
+				s = string(r)
+			}
+			fmt.Fprintf(&b, string(append(spec, 's')), s)
+
+		case 's':
+			fmt.Fprintf(&b, string(append(spec, 's')), next().str(convfmt))
+
+		default:
+			b.WriteString(format[start : i+1])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package awk
+
+import "strings"
+
+// fieldFormat is how records are split into fields and how fields are
+// written by print and when $0 is rebuilt
+type fieldFormat int
+
+const (
+	// plainFields are separated by FS and written as they are
+	plainFields fieldFormat = iota
+	// csvFields are separated by commas and may be enclosed in double
+	// quotes, with "" standing for a quote, so that they can hold commas
+	// and newlines. Fields that need it are quoted on output.
+	csvFields
+	// tsvFields are separated by tabs, with the escapes \t, \n, \r and \\
+	// standing for the characters they can't hold
+	tsvFields
+)
+
+// tsvUnescaper and tsvEscaper convert between TSV fields and their values
+var (
+	tsvUnescaper = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\r`, "\r", `\\`, `\`)
+	tsvEscaper   = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`, `\`, `\\`)
+)
+
+// openQuote reports whether a CSV record ends inside a quoted field, in
+// which case the line break belongs to the field and the record goes on
+func openQuote(record string) bool {
+	return strings.Count(record, `"`)%2 == 1
+}
+
+// splitCSV appends the fields of a CSV record to dst. As with the lazy
+// quotes of encoding/csv, text after a closing quote is kept rather than
+// rejected.
+func splitCSV(dst []string, s string) []string {
+	if s == "" {
+		return dst
+	}
+	for {
+		if !strings.HasPrefix(s, `"`) {
+			i := strings.IndexByte(s, ',')
+			if i < 0 {
+				return append(dst, s)
+			}
+			dst = append(dst, s[:i])
+			s = s[i+1:]
+			continue
+		}
+
+		var b strings.Builder
+		i := 1
+		for i < len(s) {
+			if s[i] == '"' {
+				if i+1 < len(s) && s[i+1] == '"' {
+					b.WriteByte('"')
+					i += 2
+					continue
+				}
+				i++
+				break
+			}
+			b.WriteByte(s[i])
+			i++
+		}
+		rest := s[i:]
+		end := strings.IndexByte(rest, ',')
+		if end < 0 {
+			b.WriteString(rest)
+			return append(dst, b.String())
+		}
+		b.WriteString(rest[:end])
+		dst = append(dst, b.String())
+		s = rest[end+1:]
+	}
+}
+
+// splitTSV appends the fields of a TSV record to dst
+func splitTSV(dst []string, s string) []string {
+	if s == "" {
+		return dst
+	}
+	for _, field := range strings.Split(s, "\t") {
+		if strings.IndexByte(field, '\\') >= 0 {
+			field = tsvUnescaper.Replace(field)
+		}
+		dst = append(dst, field)
+	}
+	return dst
+}
+
+// formatField returns s as written as a field: quoted in CSV if it holds
+// OFS, a quote or a line break, and escaped in TSV
+func (in *interp) formatField(s string) string {
+	switch in.format {
+	case csvFields:
+		if strings.ContainsAny(s, "\"\r\n") || in.ofs != "" && strings.Contains(s, in.ofs) {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		}
+	case tsvFields:
+		return tsvEscaper.Replace(s)
+	}
+	return s
+}
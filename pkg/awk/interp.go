@@ -0,0 +1,1050 @@
+package awk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/regex"
+)
+
+// Built-in variables, by slot
+const (
+	spNF = iota
+	spNR
+	spFNR
+	spFS
+	spOFS
+	spORS
+	spRS
+	spSUBSEP
+	spCONVFMT
+	spOFMT
+	spRSTART
+	spRLENGTH
+	spFILENAME
+	spARGC
+)
+
+// specials maps the names of built-in scalar variables to their slots
+var specials = map[string]int{
+	"NF":       spNF,
+	"NR":       spNR,
+	"FNR":      spFNR,
+	"FS":       spFS,
+	"OFS":      spOFS,
+	"ORS":      spORS,
+	"RS":       spRS,
+	"SUBSEP":   spSUBSEP,
+	"CONVFMT":  spCONVFMT,
+	"OFMT":     spOFMT,
+	"RSTART":   spRSTART,
+	"RLENGTH":  spRLENGTH,
+	"FILENAME": spFILENAME,
+	"ARGC":     spARGC,
+}
+
+// maxDepth bounds recursion in user functions
+const maxDepth = 20000
+
+// array is an awk associative array. Elements are pointers so that an
+// update such as count[$1]++ looks the key up once.
+type array map[string]*value
+
+// frame holds the parameters of a function call
+type frame struct {
+	scalars []value
+	arrays  []array
+}
+
+// runtimeError carries a fatal error out of the interpreter
+type runtimeError struct {
+	err error
+}
+
+// controlPanic carries next, nextfile or exit out of a function call to
+// the rule that made it
+type controlPanic control
+
+// matcher is a compiled regex, with a shortcut for plain strings
+type matcher struct {
+	re        *regex.Regexp
+	literal   string
+	isLiteral bool
+}
+
+// compileRegex compiles an awk regex, which is always an extended one
+func compileRegex(src string) (*matcher, error) {
+	re, err := regex.Compile(src, regex.Extended, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex /%s/: %w", src, err)
+	}
+	m := &matcher{re: re}
+	if text, fold, ok := re.Literal(); ok && !fold {
+		m.literal, m.isLiteral = text, true
+	}
+	return m, nil
+}
+
+func (m *matcher) match(s string) bool {
+	if m.isLiteral {
+		return strings.Contains(s, m.literal)
+	}
+	return m.re.MatchString(s)
+}
+
+// interp runs a program
+type interp struct {
+	ctx  context.Context
+	prog *program
+
+	globals []value
+	arrays  []array
+	frame   *frame
+	depth   int
+	retval  value
+
+	// The current record is split into fields on first use, and rebuilt
+	// from them after a field is assigned
+	record   string
+	fields   []string
+	nf       int
+	split    bool
+	rebuild  bool
+	recordFS string // FS when the record was read
+	format   fieldFormat
+
+	nr, fnr         int
+	fs, ofs, ors    string
+	rs, subsep      string
+	convfmt, ofmt   string
+	rstart, rlength int
+	filename        string
+	argc            int
+	argv            array
+
+	regexes map[string]*matcher
+	inRange []bool
+
+	main    mainInput
+	inputs  map[string]*inStream
+	outputs map[string]*outStream
+
+	random   *rand.Rand
+	seed     float64
+	exitCode int
+}
+
+// newInterp prepares to run prog over the operands in args
+func newInterp(ctx context.Context, prog *program, args []string) *interp {
+	in := &interp{
+		ctx:     ctx,
+		prog:    prog,
+		globals: make([]value, len(prog.scalars)),
+		arrays:  make([]array, len(prog.arrays)),
+		fs:      " ",
+		ofs:     " ",
+		ors:     "\n",
+		rs:      "\n",
+		subsep:  "\x1c",
+		convfmt: "%.6g",
+		ofmt:    "%.6g",
+		rlength: -1,
+		argv:    make(array),
+		regexes: make(map[string]*matcher),
+		inRange: make([]bool, len(prog.items)),
+		inputs:  make(map[string]*inStream),
+		outputs: make(map[string]*outStream),
+		random:  rand.New(rand.NewSource(0)),
+	}
+	in.recordFS = in.fs
+	in.main.next = 1
+	for i := range in.arrays {
+		in.arrays[i] = make(array)
+	}
+
+	in.argv["0"] = &value{typ: typeStr, s: "awk"}
+	for i, arg := range args {
+		in.argv[strconv.Itoa(i+1)] = &value{typ: typeStrnum, s: arg}
+	}
+	in.argc = len(args) + 1
+	if i, ok := prog.arrays["ARGV"]; ok {
+		in.arrays[i] = in.argv
+	}
+	if i, ok := prog.arrays["ENVIRON"]; ok {
+		for _, kv := range os.Environ() {
+			if name, val, ok := strings.Cut(kv, "="); ok {
+				in.arrays[i][name] = &value{typ: typeStrnum, s: val}
+			}
+		}
+	}
+	return in
+}
+
+// setVar assigns a command-line variable, as given to -v or as an operand
+func (in *interp) setVar(name, val string) {
+	v := strnum(unescape(val))
+	if i, ok := specials[name]; ok {
+		in.setSpecial(i, v)
+	} else if i, ok := in.prog.scalars[name]; ok {
+		in.globals[i] = v
+	}
+}
+
+// fatal stops the program with an error
+func (in *interp) fatal(format string, args ...any) {
+	panic(runtimeError{fmt.Errorf(format, args...)})
+}
+
+// run runs BEGIN, the rules over every input record, and END
+func (in *interp) run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(runtimeError)
+			if !ok {
+				panic(r)
+			}
+			in.closeAll()
+			err = e.err
+		}
+	}()
+
+	exiting := in.action(in.prog.begin) == ctlExit
+	if !exiting && (len(in.prog.items) > 0 || len(in.prog.end) > 0) {
+		exiting = in.records()
+	}
+	// END runs even after exit, unless exit was called in END itself
+	in.action(in.prog.end)
+
+	return in.closeAll()
+}
+
+// records runs the rules over every input record, reporting whether exit
+// was called
+func (in *interp) records() bool {
+	for {
+		record, ok := in.nextRecord()
+		if !ok {
+			return false
+		}
+		in.nr++
+		in.fnr++
+		in.setRecord(record)
+
+		for i, it := range in.prog.items {
+			if !in.matches(i, it) {
+				continue
+			}
+			if it.body == nil {
+				in.printRecord()
+				continue
+			}
+			ctl := in.action(it.body)
+			if ctl == ctlNext {
+				break
+			}
+			if ctl == ctlNextFile {
+				in.main.close()
+				break
+			}
+			if ctl == ctlExit {
+				return true
+			}
+		}
+	}
+}
+
+// action runs the statements of a rule, returning how they ended, including
+// by next or exit inside a function
+func (in *interp) action(body []stmt) (ctl control) {
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(controlPanic)
+			if !ok {
+				panic(r)
+			}
+			in.frame, in.depth = nil, 0
+			ctl = control(c)
+		}
+	}()
+	return in.execute(body)
+}
+
+// matches reports whether a rule's pattern selects the current record
+func (in *interp) matches(i int, it *item) bool {
+	if it.pattern == nil {
+		return true
+	}
+	if it.pattern2 == nil {
+		return in.eval(it.pattern).bool()
+	}
+
+	// A range runs from a record matching the first pattern to the next one
+	// matching the second, which may be the same record
+	if !in.inRange[i] {
+		if !in.eval(it.pattern).bool() {
+			return false
+		}
+		in.inRange[i] = !in.eval(it.pattern2).bool()
+		return true
+	}
+	if in.eval(it.pattern2).bool() {
+		in.inRange[i] = false
+	}
+	return true
+}
+
+// execute runs statements until one ends other than by falling through
+func (in *interp) execute(body []stmt) control {
+	for _, s := range body {
+		if ctl := in.exec(s); ctl != ctlNone {
+			return ctl
+		}
+	}
+	return ctlNone
+}
+
+// loop maps how a loop body ended to whether the loop goes on and what the
+// loop statement returns
+func loop(ctl control) (bool, control) {
+	switch ctl {
+	case ctlNone, ctlContinue:
+		return true, ctlNone
+	case ctlBreak:
+		return false, ctlNone
+	}
+	return false, ctl
+}
+
+func (in *interp) exec(s stmt) control {
+	switch s := s.(type) {
+	case *exprStmt:
+		in.eval(s.e)
+
+	case *printStmt:
+		in.print(s)
+
+	case *ifStmt:
+		if in.eval(s.cond).bool() {
+			return in.execute(s.then)
+		}
+		return in.execute(s.otherwise)
+
+	case *whileStmt:
+		for in.eval(s.cond).bool() {
+			if more, ctl := loop(in.execute(s.body)); !more {
+				return ctl
+			}
+		}
+
+	case *doStmt:
+		for {
+			if more, ctl := loop(in.execute(s.body)); !more {
+				return ctl
+			}
+			if !in.eval(s.cond).bool() {
+				break
+			}
+		}
+
+	case *forStmt:
+		if s.init != nil {
+			in.exec(s.init)
+		}
+		for s.cond == nil || in.eval(s.cond).bool() {
+			if more, ctl := loop(in.execute(s.body)); !more {
+				return ctl
+			}
+			if s.post != nil {
+				in.exec(s.post)
+			}
+		}
+
+	case *forInStmt:
+		a := in.array(s.array)
+		for _, key := range sortedKeys(a) {
+			// Elements deleted by the body are skipped
+			if _, ok := a[key]; !ok {
+				continue
+			}
+			in.assignTo(s.key, strnum(key))
+			if more, ctl := loop(in.execute(s.body)); !more {
+				return ctl
+			}
+		}
+
+	case *blockStmt:
+		return in.execute(s.body)
+
+	case *controlStmt:
+		return s.ctl
+
+	case *exitStmt:
+		if s.status != nil {
+			in.exitCode = int(in.eval(s.status).num())
+		}
+		return ctlExit
+
+	case *returnStmt:
+		in.retval = value{}
+		if s.value != nil {
+			in.retval = in.eval(s.value)
+		}
+		return ctlReturn
+
+	case *deleteStmt:
+		a := in.array(s.array)
+		if s.subs == nil {
+			clear(a)
+		} else {
+			delete(a, in.subscript(s.subs))
+		}
+
+	default:
+		in.fatal("unknown statement %T", s)
+	}
+	return ctlNone
+}
+
+// sortedKeys returns the keys of a for for-in loops, in numeric order if
+// they are all integers and in string order otherwise, so that output
+// doesn't depend on hashing
+func sortedKeys(a array) []string {
+	keys := make([]string, 0, len(a))
+	numeric := true
+	for key := range a {
+		keys = append(keys, key)
+		if numeric {
+			_, numeric = parseInt(key)
+		}
+	}
+	if numeric {
+		sort.Slice(keys, func(i, j int) bool {
+			x, _ := parseInt(keys[i])
+			y, _ := parseInt(keys[j])
+			return x < y
+		})
+	} else {
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+// toStr converts a value to a string for concatenation and subscripts
+func (in *interp) toStr(v value) string {
+	if v.typ == typeNum {
+		return numToStr(v.n, in.convfmt)
+	}
+	return v.s
+}
+
+// toOutput converts a value to a string for print
+func (in *interp) toOutput(v value) string {
+	if v.typ == typeNum {
+		return numToStr(v.n, in.ofmt)
+	}
+	return v.s
+}
+
+func (in *interp) eval(e expr) value {
+	switch e := e.(type) {
+	case *numExpr:
+		return num(e.n)
+
+	case *strExpr:
+		return str(e.s)
+
+	case *fieldExpr:
+		return in.field(in.fieldIndex(e.index))
+
+	case *varExpr:
+		return in.scalar(e)
+
+	case *indexExpr:
+		return *in.element(e)
+
+	case *assignExpr:
+		return in.assign(e)
+
+	case *incrExpr:
+		return in.incr(e)
+
+	case *binaryExpr:
+		return in.binary(e)
+
+	case *unaryExpr:
+		v := in.eval(e.operand)
+		switch e.op {
+		case tNot:
+			return boolean(!v.bool())
+		case tSub:
+			return num(-v.num())
+		}
+		return num(v.num())
+
+	case *concatExpr:
+		if len(e.parts) == 2 {
+			return str(in.toStr(in.eval(e.parts[0])) + in.toStr(in.eval(e.parts[1])))
+		}
+		var b strings.Builder
+		for _, part := range e.parts {
+			b.WriteString(in.toStr(in.eval(part)))
+		}
+		return str(b.String())
+
+	case *condExpr:
+		if in.eval(e.cond).bool() {
+			return in.eval(e.yes)
+		}
+		return in.eval(e.no)
+
+	case *regexExpr:
+		return boolean(e.re.match(in.getRecord()))
+
+	case *matchExpr:
+		s := in.toStr(in.eval(e.left))
+		re := e.static
+		if re == nil {
+			re = in.regex(in.toStr(in.eval(e.re)))
+		}
+		return boolean(re.match(s) != e.negate)
+
+	case *inExpr:
+		_, ok := in.array(e.array)[in.subscript(e.subs)]
+		return boolean(ok)
+
+	case *builtinExpr:
+		return in.builtin(e)
+
+	case *callExpr:
+		return in.call(e)
+
+	case *getlineExpr:
+		return in.getline(e)
+	}
+
+	in.fatal("unexpected expression %T", e)
+	return value{}
+}
+
+// binary evaluates arithmetic, comparisons and the logical operators
+func (in *interp) binary(e *binaryExpr) value {
+	switch e.op {
+	case tAnd:
+		return boolean(in.eval(e.left).bool() && in.eval(e.right).bool())
+	case tOr:
+		return boolean(in.eval(e.left).bool() || in.eval(e.right).bool())
+	}
+
+	left, right := in.eval(e.left), in.eval(e.right)
+	var c int
+	switch e.op {
+	case tLess, tLessEqual, tEqual, tNotEqual, tGreater, tGreaterEqual:
+		if left.typ == typeNum && right.typ == typeNum {
+			c = cmpNum(left.n, right.n)
+		} else {
+			c = compare(left, right, in.convfmt)
+		}
+	default:
+		return num(in.arith(e.op, left.num(), right.num()))
+	}
+
+	switch e.op {
+	case tLess:
+		return boolean(c < 0)
+	case tLessEqual:
+		return boolean(c <= 0)
+	case tEqual:
+		return boolean(c == 0)
+	case tNotEqual:
+		return boolean(c != 0)
+	case tGreater:
+		return boolean(c > 0)
+	}
+	return boolean(c >= 0)
+}
+
+func cmpNum(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// arith applies an arithmetic operator or the operator of a compound
+// assignment
+func (in *interp) arith(op token, x, y float64) float64 {
+	switch op {
+	case tAdd, tAddAssign:
+		return x + y
+	case tSub, tSubAssign:
+		return x - y
+	case tMul, tMulAssign:
+		return x * y
+	case tDiv, tDivAssign:
+		if y == 0 {
+			in.fatal("division by zero")
+		}
+		return x / y
+	case tMod, tModAssign:
+		if y == 0 {
+			in.fatal("division by zero in %%")
+		}
+		return math.Mod(x, y)
+	case tPow, tPowAssign:
+		return math.Pow(x, y)
+	}
+	in.fatal("unexpected operator %d", op)
+	return 0
+}
+
+// scalar returns the value of a scalar variable
+func (in *interp) scalar(v *varExpr) value {
+	switch v.scope {
+	case scopeGlobal:
+		return in.globals[v.index]
+	case scopeLocal:
+		return in.frame.scalars[v.index]
+	}
+	return in.special(v.index)
+}
+
+// setScalar assigns to a scalar variable
+func (in *interp) setScalar(v *varExpr, val value) {
+	switch v.scope {
+	case scopeGlobal:
+		in.globals[v.index] = val
+	case scopeLocal:
+		in.frame.scalars[v.index] = val
+	default:
+		in.setSpecial(v.index, val)
+	}
+}
+
+// array returns an array variable
+func (in *interp) array(v *varExpr) array {
+	if v.scope == scopeLocal {
+		return in.frame.arrays[v.index]
+	}
+	return in.arrays[v.index]
+}
+
+// subscript evaluates subscripts to an array key
+func (in *interp) subscript(subs []expr) string {
+	if len(subs) == 1 {
+		return in.toStr(in.eval(subs[0]))
+	}
+	parts := make([]string, len(subs))
+	for i, sub := range subs {
+		parts[i] = in.toStr(in.eval(sub))
+	}
+	return strings.Join(parts, in.subsep)
+}
+
+// element returns an array element, creating it if needed as referring to
+// an element does in awk
+func (in *interp) element(e *indexExpr) *value {
+	a := in.array(e.array)
+	key := in.subscript(e.subs)
+	p := a[key]
+	if p == nil {
+		// A key taken from a field would keep the whole record alive
+		p = &value{}
+		a[strings.Clone(key)] = p
+	}
+	return p
+}
+
+// assign evaluates an assignment
+func (in *interp) assign(e *assignExpr) value {
+	switch t := e.target.(type) {
+	case *varExpr:
+		v := in.eval(e.value)
+		if e.op != tAssign {
+			v = num(in.arith(e.op, in.scalar(t).num(), v.num()))
+		}
+		in.setScalar(t, v)
+		return v
+
+	case *indexExpr:
+		p := in.element(t)
+		v := in.eval(e.value)
+		if e.op != tAssign {
+			v = num(in.arith(e.op, p.num(), v.num()))
+		}
+		*p = v
+		return v
+
+	case *fieldExpr:
+		i := in.fieldIndex(t.index)
+		v := in.eval(e.value)
+		if e.op != tAssign {
+			v = num(in.arith(e.op, in.field(i).num(), v.num()))
+		}
+		in.setField(i, in.toStr(v))
+		return v
+	}
+	in.fatal("cannot assign to %T", e.target)
+	return value{}
+}
+
+// assignTo stores v in a variable, element or field
+func (in *interp) assignTo(target expr, v value) {
+	switch t := target.(type) {
+	case *varExpr:
+		in.setScalar(t, v)
+	case *indexExpr:
+		*in.element(t) = v
+	case *fieldExpr:
+		in.setField(in.fieldIndex(t.index), in.toStr(v))
+	}
+}
+
+// incr evaluates ++ and --
+func (in *interp) incr(e *incrExpr) value {
+	var old float64
+	switch t := e.target.(type) {
+	case *varExpr:
+		old = in.scalar(t).num()
+		in.setScalar(t, num(old+e.delta))
+	case *indexExpr:
+		p := in.element(t)
+		old = p.num()
+		*p = num(old + e.delta)
+	case *fieldExpr:
+		i := in.fieldIndex(t.index)
+		old = in.field(i).num()
+		in.setField(i, numToStr(old+e.delta, in.convfmt))
+	}
+	if e.pre {
+		return num(old + e.delta)
+	}
+	return num(old)
+}
+
+// call calls a user-defined function
+func (in *interp) call(e *callExpr) value {
+	fn := e.fn
+	fr := &frame{
+		scalars: make([]value, len(fn.params)),
+		arrays:  make([]array, len(fn.params)),
+	}
+	for i, arg := range e.args {
+		if fn.array[i] {
+			fr.arrays[i] = in.array(arg.(*varExpr))
+		} else {
+			fr.scalars[i] = in.eval(arg)
+		}
+	}
+	for i := range fn.params {
+		if fn.array[i] && fr.arrays[i] == nil {
+			fr.arrays[i] = make(array)
+		}
+	}
+
+	if in.depth >= maxDepth {
+		in.fatal("function %s: calls nested too deeply", fn.name)
+	}
+	saved := in.frame
+	in.frame = fr
+	in.depth++
+	ctl := in.execute(fn.body)
+	in.frame = saved
+	in.depth--
+
+	switch ctl {
+	case ctlNext, ctlNextFile, ctlExit:
+		panic(controlPanic(ctl))
+	}
+	v := in.retval
+	in.retval = value{}
+	return v
+}
+
+// special returns a built-in variable
+func (in *interp) special(i int) value {
+	switch i {
+	case spNF:
+		in.splitRecord()
+		return num(float64(in.nf))
+	case spNR:
+		return num(float64(in.nr))
+	case spFNR:
+		return num(float64(in.fnr))
+	case spFS:
+		return str(in.fs)
+	case spOFS:
+		return str(in.ofs)
+	case spORS:
+		return str(in.ors)
+	case spRS:
+		return str(in.rs)
+	case spSUBSEP:
+		return str(in.subsep)
+	case spCONVFMT:
+		return str(in.convfmt)
+	case spOFMT:
+		return str(in.ofmt)
+	case spRSTART:
+		return num(float64(in.rstart))
+	case spRLENGTH:
+		return num(float64(in.rlength))
+	case spFILENAME:
+		return str(in.filename)
+	}
+	return num(float64(in.argc))
+}
+
+// setSpecial assigns a built-in variable
+func (in *interp) setSpecial(i int, v value) {
+	switch i {
+	case spNF:
+		in.setNF(int(v.num()))
+	case spNR:
+		in.nr = int(v.num())
+	case spFNR:
+		in.fnr = int(v.num())
+	case spFS:
+		in.fs = in.toStr(v)
+	case spOFS:
+		in.ofs = in.toStr(v)
+		in.rebuild = in.split
+	case spORS:
+		in.ors = in.toStr(v)
+	case spRS:
+		rs := in.toStr(v)
+		if len(rs) > 1 {
+			in.fatal("RS longer than one character is not supported")
+		}
+		in.rs = rs
+	case spSUBSEP:
+		in.subsep = in.toStr(v)
+	case spCONVFMT:
+		in.convfmt = in.toStr(v)
+	case spOFMT:
+		in.ofmt = in.toStr(v)
+	case spRSTART:
+		in.rstart = int(v.num())
+	case spRLENGTH:
+		in.rlength = int(v.num())
+	case spFILENAME:
+		in.filename = in.toStr(v)
+	case spARGC:
+		in.argc = int(v.num())
+	}
+}
+
+// fieldIndex evaluates the index of a field reference
+func (in *interp) fieldIndex(e expr) int {
+	if n, ok := e.(*numExpr); ok {
+		return int(n.n)
+	}
+	i := in.eval(e).num()
+	if i < 0 {
+		in.fatal("attempt to access field %d", int(i))
+	}
+	return int(i)
+}
+
+// setRecord makes s the current record
+func (in *interp) setRecord(s string) {
+	in.record = s
+	in.split = false
+	in.rebuild = false
+	in.recordFS = in.fs
+}
+
+// getRecord returns $0
+func (in *interp) getRecord() string {
+	if in.rebuild {
+		if in.format == plainFields {
+			in.record = strings.Join(in.fields, in.ofs)
+		} else {
+			formatted := make([]string, len(in.fields))
+			for i, field := range in.fields {
+				formatted[i] = in.formatField(field)
+			}
+			in.record = strings.Join(formatted, in.ofs)
+		}
+		in.rebuild = false
+	}
+	return in.record
+}
+
+// splitRecord splits the record into fields if it hasn't been
+func (in *interp) splitRecord() {
+	if in.split {
+		return
+	}
+	switch in.format {
+	case csvFields:
+		in.fields = splitCSV(in.fields[:0], in.record)
+	case tsvFields:
+		in.fields = splitTSV(in.fields[:0], in.record)
+	default:
+		in.fields = in.splitFields(in.fields[:0], in.record, in.recordFS)
+	}
+	in.nf = len(in.fields)
+	in.split = true
+}
+
+// field returns $i
+func (in *interp) field(i int) value {
+	if i == 0 {
+		return strnum(in.getRecord())
+	}
+	in.splitRecord()
+	if i > in.nf {
+		return value{}
+	}
+	return strnum(in.fields[i-1])
+}
+
+// setField assigns $i, rebuilding $0 if i isn't 0 and re-splitting the
+// record if it is
+func (in *interp) setField(i int, s string) {
+	if i == 0 {
+		in.setRecord(s)
+		return
+	}
+	in.splitRecord()
+	if i > in.nf {
+		in.setNF(i)
+	}
+	in.fields[i-1] = s
+	in.rebuild = true
+}
+
+// setNF truncates or extends the record to n fields
+func (in *interp) setNF(n int) {
+	if n < 0 {
+		in.fatal("NF set to negative value %d", n)
+	}
+	in.splitRecord()
+	for len(in.fields) < n {
+		in.fields = append(in.fields, "")
+	}
+	in.fields = in.fields[:n]
+	in.nf = n
+	in.rebuild = true
+}
+
+// splitFields appends the fields of s separated by fs to dst. A single
+// space separates by runs of blanks and newlines, ignoring them at the
+// ends; any other single character separates literally, the empty string
+// splits into characters, and anything longer is a regex.
+func (in *interp) splitFields(dst []string, s, fs string) []string {
+	switch {
+	case fs == " ":
+		for i := 0; i < len(s); {
+			for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+				i++
+			}
+			start := i
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != '\n' {
+				i++
+			}
+			if i > start {
+				dst = append(dst, s[start:i])
+			}
+		}
+		return dst
+
+	case s == "":
+		return dst
+
+	case fs == "":
+		for _, r := range s {
+			dst = append(dst, string(r))
+		}
+		return dst
+
+	case len(fs) == 1 && fs != `\` || utf8.RuneCountInString(fs) == 1 && fs[0] >= utf8.RuneSelf:
+		for {
+			i := strings.Index(s, fs)
+			if i < 0 {
+				return append(dst, s)
+			}
+			dst = append(dst, s[:i])
+			s = s[i+len(fs):]
+		}
+	}
+
+	return in.splitRegex(dst, s, in.regex(fs))
+}
+
+// splitRegex appends the parts of s separated by matches of re to dst
+func (in *interp) splitRegex(dst []string, s string, re *matcher) []string {
+	if s == "" {
+		return dst
+	}
+	start := 0
+	for _, loc := range re.re.FindAllStringIndex(s, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		dst = append(dst, s[start:loc[0]])
+		start = loc[1]
+	}
+	return append(dst, s[start:])
+}
+
+// regex compiles a dynamic regex, caching the result
+func (in *interp) regex(src string) *matcher {
+	if m, ok := in.regexes[src]; ok {
+		return m
+	}
+	m, err := compileRegex(src)
+	if err != nil {
+		in.fatal("%v", err)
+	}
+	if len(in.regexes) >= 1000 {
+		clear(in.regexes)
+	}
+	in.regexes[src] = m
+	return m
+}
+
+// print runs print and printf
+func (in *interp) print(s *printStmt) {
+	w := output.Stdout.WriteString
+	if s.dest != nil {
+		w = in.outputStream(s.redirect, in.toStr(in.eval(s.dest))).WriteString
+	}
+
+	if s.printf {
+		args := make([]value, len(s.args))
+		for i, arg := range s.args {
+			args[i] = in.eval(arg)
+		}
+		w(sprintf(in.toStr(args[0]), args[1:], in.convfmt))
+		return
+	}
+
+	if len(s.args) == 0 {
+		w(in.getRecord())
+	}
+	for i, arg := range s.args {
+		if i > 0 {
+			w(in.ofs)
+		}
+		w(in.formatField(in.toOutput(in.eval(arg))))
+	}
+	w(in.ors)
+}
+
+// printRecord prints $0, the action of a rule without one
+func (in *interp) printRecord() {
+	output.Stdout.WriteString(in.getRecord())
+	output.Stdout.WriteString(in.ors)
+}
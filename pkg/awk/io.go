@@ -0,0 +1,436 @@
+package awk
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// mainInput reads records from the operands in ARGV, or standard input if
+// none of them is a file
+type mainInput struct {
+	next   int // index of the next ARGV element
+	reader *lines.Reader
+	closer io.Closer
+	files  bool // whether a file operand has been opened
+	done   bool
+}
+
+// close ends the current file, as nextfile does
+func (m *mainInput) close() {
+	if m.closer != nil {
+		m.closer.Close()
+	}
+	m.reader, m.closer = nil, nil
+}
+
+// inStream is a file or command read by getline
+type inStream struct {
+	reader *lines.Reader
+	closer io.Closer
+	cmd    *exec.Cmd
+}
+
+// writer is buffered output that can be flushed
+type writer interface {
+	WriteString(s string) (int, error)
+	Flush() error
+}
+
+// stderr writes standard error unbuffered
+type stderr struct{}
+
+func (stderr) WriteString(s string) (int, error) {
+	return os.Stderr.WriteString(s)
+}
+
+func (stderr) Flush() error {
+	return nil
+}
+
+// outStream is a file or command written by print and printf
+type outStream struct {
+	w    writer
+	file *os.File // nil for standard output and standard error
+	pipe io.WriteCloser
+	cmd  *exec.Cmd
+}
+
+// WriteString writes s to the stream
+func (o *outStream) WriteString(s string) (int, error) {
+	return o.w.WriteString(s)
+}
+
+// shellCommand returns a command running cmdline with the system shell
+func (in *interp) shellCommand(cmdline string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(in.ctx, "cmd", "/C", cmdline)
+	}
+	return exec.CommandContext(in.ctx, "sh", "-c", cmdline)
+}
+
+// newReader returns a record reader for r that can be interrupted
+func (in *interp) newReader(r io.Reader) *lines.Reader {
+	return lines.NewReader(interrupt.Reader(in.ctx, r))
+}
+
+// readRecord reads the next record from r as RS says: up to a single
+// character, or up to a blank line if RS is empty
+func (in *interp) readRecord(r *lines.Reader) (string, bool) {
+	if in.format == csvFields && in.rs == "\n" {
+		return in.readCSVRecord(r)
+	}
+	if in.rs != "" {
+		r.SetDelimiter(in.rs[0])
+		if !r.Scan() {
+			in.readError(r)
+			return "", false
+		}
+		return r.Text(), true
+	}
+
+	// Paragraph mode: leading newlines are skipped and a record ends at
+	// one or more blank lines
+	r.SetDelimiter('\n')
+	for {
+		if !r.Scan() {
+			in.readError(r)
+			return "", false
+		}
+		if len(r.Bytes()) > 0 {
+			break
+		}
+	}
+	var b strings.Builder
+	b.Write(r.Bytes())
+	for r.Scan() && len(r.Bytes()) > 0 {
+		b.WriteByte('\n')
+		b.Write(r.Bytes())
+	}
+	in.readError(r)
+	return b.String(), true
+}
+
+// readCSVRecord reads a CSV record, which goes on past the end of a line
+// inside a quoted field. Line ends may be CRLF.
+func (in *interp) readCSVRecord(r *lines.Reader) (string, bool) {
+	r.SetDelimiter('\n')
+	if !r.Scan() {
+		in.readError(r)
+		return "", false
+	}
+	record := strings.TrimSuffix(r.Text(), "\r")
+	for openQuote(record) && r.Scan() {
+		record += "\n" + strings.TrimSuffix(r.Text(), "\r")
+	}
+	in.readError(r)
+	return record, true
+}
+
+// readError stops the program if r failed other than by reaching the end
+func (in *interp) readError(r *lines.Reader) {
+	if err := r.Err(); err != nil {
+		panic(runtimeError{err})
+	}
+}
+
+// nextRecord returns the next record of the main input, opening the next
+// operand as needed and applying var=value operands on the way
+func (in *interp) nextRecord() (string, bool) {
+	m := &in.main
+	for {
+		if m.reader != nil {
+			if record, ok := in.readRecord(m.reader); ok {
+				return record, true
+			}
+			m.close()
+		}
+		if m.done {
+			return "", false
+		}
+
+		if m.next >= in.argc {
+			m.done = true
+			if m.files {
+				return "", false
+			}
+			// Without file operands the input is standard input
+			m.files = true
+			in.filename = ""
+			in.fnr = 0
+			m.reader = in.newReader(os.Stdin)
+			continue
+		}
+
+		arg := ""
+		if v := in.argv[strconv.Itoa(m.next)]; v != nil {
+			arg = in.toStr(*v)
+		}
+		m.next++
+		if arg == "" {
+			continue
+		}
+		if name, val, ok := strings.Cut(arg, "="); ok && isName(name) {
+			in.setVar(name, val)
+			continue
+		}
+
+		m.files = true
+		f, err := input.Open(arg)
+		if err != nil {
+			logging.PathError("cannot open", arg, err)
+			in.exitCode = 2
+			continue
+		}
+		in.filename = arg
+		in.fnr = 0
+		m.reader, m.closer = in.newReader(f), f
+	}
+}
+
+// isName reports whether s is a valid variable name
+func isName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	_, keyword := keywords[s]
+	_, fn := builtins[s]
+	return !keyword && !fn
+}
+
+// getline runs the forms of getline, returning 1 for a record, 0 at the
+// end of input and -1 if the input can't be read
+func (in *interp) getline(e *getlineExpr) value {
+	var record string
+	switch e.kind {
+	case getlineMain:
+		var ok bool
+		if record, ok = in.nextRecord(); !ok {
+			return num(0)
+		}
+		in.nr++
+		in.fnr++
+
+	default:
+		s := in.inputStream(e.kind, in.str(e.src))
+		if s == nil {
+			return num(-1)
+		}
+		var ok bool
+		if record, ok = in.readRecord(s.reader); !ok {
+			return num(0)
+		}
+		if e.kind == getlineCommand {
+			in.nr++
+		}
+	}
+
+	if e.target == nil {
+		in.setRecord(record)
+	} else {
+		in.assignTo(e.target, strnum(record))
+	}
+	return num(1)
+}
+
+// inputStream returns the open stream for a getline source, opening it on
+// first use; nil if it can't be opened
+func (in *interp) inputStream(kind getlineKind, name string) *inStream {
+	if s, ok := in.inputs[name]; ok {
+		return s
+	}
+
+	s := &inStream{}
+	if kind == getlineCommand {
+		in.flushAll()
+		s.cmd = in.shellCommand(name)
+		s.cmd.Stdin = os.Stdin
+		s.cmd.Stderr = os.Stderr
+		stdout, err := s.cmd.StdoutPipe()
+		if err == nil {
+			err = s.cmd.Start()
+		}
+		if err != nil {
+			logging.Warn("cannot run", name+":", err)
+			return nil
+		}
+		s.reader, s.closer = in.newReader(stdout), stdout
+	} else {
+		f, err := input.Open(name)
+		if err != nil {
+			return nil
+		}
+		s.reader, s.closer = in.newReader(f), f
+	}
+	in.inputs[name] = s
+	return s
+}
+
+// outputStream returns the stream print writes to for a redirection,
+// opening it on first use
+func (in *interp) outputStream(redirect token, name string) *outStream {
+	if s, ok := in.outputs[name]; ok {
+		return s
+	}
+
+	s := &outStream{}
+	switch {
+	case name == "/dev/stdout" || name == "-":
+		s.w = output.Stdout
+	case name == "/dev/stderr":
+		s.w = stderr{}
+
+	case redirect == tPipe:
+		in.flushAll()
+		s.cmd = in.shellCommand(name)
+		s.cmd.Stdout = os.Stdout
+		s.cmd.Stderr = os.Stderr
+		pipe, err := s.cmd.StdinPipe()
+		if err == nil {
+			err = s.cmd.Start()
+		}
+		if err != nil {
+			in.fatal("cannot run %s: %v", name, err)
+		}
+		s.pipe = pipe
+		s.w = bufio.NewWriter(pipe)
+
+	default:
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if redirect == tAppend {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(name, flags, 0o644)
+		if err != nil {
+			in.fatal("cannot open %s for writing: %v", name, err)
+		}
+		s.file = f
+		s.w = bufio.NewWriter(f)
+	}
+	in.outputs[name] = s
+	return s
+}
+
+// closeStream runs close(name), returning a command's exit status, 0 for
+// a file and -1 if nothing by that name is open
+func (in *interp) closeStream(name string) int {
+	status := -1
+	if s, ok := in.outputs[name]; ok {
+		status = in.closeOutput(s)
+		delete(in.outputs, name)
+	}
+	if s, ok := in.inputs[name]; ok {
+		status = closeInput(s)
+		delete(in.inputs, name)
+	}
+	return status
+}
+
+// closeOutput flushes and closes an output stream
+func (in *interp) closeOutput(s *outStream) int {
+	err := s.w.Flush()
+	switch {
+	case s.cmd != nil:
+		s.pipe.Close()
+		return exitStatus(s.cmd.Wait())
+	case s.file != nil:
+		if cerr := s.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
+// closeInput closes a getline stream
+func closeInput(s *inStream) int {
+	// A command still writing gets SIGPIPE once its output is closed,
+	// rather than blocking Wait
+	s.closer.Close()
+	if s.cmd != nil {
+		return exitStatus(s.cmd.Wait())
+	}
+	return 0
+}
+
+// exitStatus converts the result of running a command to an awk status
+func exitStatus(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// flush runs fflush: of one stream, or of everything for ""
+func (in *interp) flush(name string) int {
+	if name == "" {
+		in.flushAll()
+		return 0
+	}
+	s, ok := in.outputs[name]
+	if !ok {
+		return -1
+	}
+	if s.w.Flush() != nil {
+		return -1
+	}
+	return 0
+}
+
+// flushAll writes out standard output and every output stream, before
+// another process may write to the same places
+func (in *interp) flushAll() {
+	for _, s := range in.outputs {
+		s.w.Flush()
+	}
+	output.Flush()
+}
+
+// system runs a shell command, returning its exit status
+func (in *interp) system(cmdline string) int {
+	in.flushAll()
+	cmd := in.shellCommand(cmdline)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return exitStatus(cmd.Run())
+}
+
+// closeAll closes every stream at the end of the program, returning the
+// first error writing output
+func (in *interp) closeAll() error {
+	var first error
+	output.Flush()
+	for name, s := range in.outputs {
+		if in.closeOutput(s) < 0 && s.cmd == nil && first == nil {
+			first = errors.New("cannot write to " + name)
+		}
+	}
+	for _, s := range in.inputs {
+		closeInput(s)
+	}
+	clear(in.outputs)
+	clear(in.inputs)
+	in.main.close()
+	return first
+}
@@ -0,0 +1,445 @@
+package awk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// token is the kind of a lexeme
+type token int
+
+const (
+	tEOF token = iota
+	tNewline
+	tLbrace
+	tRbrace
+	tLparen
+	tRparen
+	tLbracket
+	tRbracket
+	tSemicolon
+	tComma
+
+	tAdd
+	tSub
+	tMul
+	tDiv
+	tMod
+	tPow
+	tNot
+	tGreater
+	tLess
+	tPipe
+	tQuestion
+	tColon
+	tMatch
+	tNotMatch
+	tDollar
+	tAssign
+	tAddAssign
+	tSubAssign
+	tMulAssign
+	tDivAssign
+	tModAssign
+	tPowAssign
+	tEqual
+	tLessEqual
+	tGreaterEqual
+	tNotEqual
+	tIncr
+	tDecr
+	tAnd
+	tOr
+	tAppend
+
+	tNumber
+	tString
+	tRegex
+	tName
+	tFuncName // a name directly followed by '(', as user function calls must be
+	tBuiltin
+
+	tBEGIN
+	tEND
+	tFunction
+	tIf
+	tElse
+	tWhile
+	tFor
+	tDo
+	tBreak
+	tContinue
+	tNext
+	tNextfile
+	tExit
+	tReturn
+	tDelete
+	tGetline
+	tPrint
+	tPrintf
+	tIn
+)
+
+// keywords maps reserved words to their tokens
+var keywords = map[string]token{
+	"BEGIN":    tBEGIN,
+	"END":      tEND,
+	"function": tFunction,
+	"func":     tFunction,
+	"if":       tIf,
+	"else":     tElse,
+	"while":    tWhile,
+	"for":      tFor,
+	"do":       tDo,
+	"break":    tBreak,
+	"continue": tContinue,
+	"next":     tNext,
+	"nextfile": tNextfile,
+	"exit":     tExit,
+	"return":   tReturn,
+	"delete":   tDelete,
+	"getline":  tGetline,
+	"print":    tPrint,
+	"printf":   tPrintf,
+	"in":       tIn,
+}
+
+// operators maps operator spellings to their tokens, longest first where
+// one is a prefix of another
+var operators = []struct {
+	text string
+	tok  token
+}{
+	{"**=", tPowAssign}, {"&&", tAnd}, {"||", tOr}, {">>", tAppend}, {"==", tEqual},
+	{"<=", tLessEqual}, {">=", tGreaterEqual}, {"!=", tNotEqual}, {"!~", tNotMatch},
+	{"++", tIncr}, {"--", tDecr}, {"+=", tAddAssign}, {"-=", tSubAssign},
+	{"*=", tMulAssign}, {"/=", tDivAssign}, {"%=", tModAssign}, {"^=", tPowAssign},
+	{"**", tPow}, {"{", tLbrace}, {"}", tRbrace}, {"(", tLparen}, {")", tRparen},
+	{"[", tLbracket}, {"]", tRbracket}, {";", tSemicolon}, {",", tComma},
+	{"+", tAdd}, {"-", tSub}, {"*", tMul}, {"/", tDiv}, {"%", tMod}, {"^", tPow},
+	{"!", tNot}, {">", tGreater}, {"<", tLess}, {"|", tPipe}, {"?", tQuestion},
+	{":", tColon}, {"~", tMatch}, {"$", tDollar}, {"=", tAssign},
+}
+
+// lexeme is a token with its text and position
+type lexeme struct {
+	tok  token
+	text string // names, strings and regexes after escape processing
+	num  float64
+	line int
+}
+
+// String describes the lexeme for error messages
+func (l lexeme) String() string {
+	switch l.tok {
+	case tEOF:
+		return "end of program"
+	case tNewline:
+		return "newline"
+	case tString:
+		return strconv.Quote(l.text)
+	case tRegex:
+		return "/" + l.text + "/"
+	}
+	return l.text
+}
+
+// lex splits an awk program into lexemes. Whether a '/' starts a regex or
+// divides depends on the lexeme before it: after an operand it divides.
+func lex(src string) ([]lexeme, error) {
+	var lexemes []lexeme
+	line := 1
+	pos := 0
+
+	for {
+		// Blanks, comments and escaped newlines separate lexemes
+		for pos < len(src) {
+			switch c := src[pos]; {
+			case c == ' ' || c == '\t' || c == '\r':
+				pos++
+				continue
+			case c == '\\' && strings.HasPrefix(src[pos+1:], "\n"):
+				pos += 2
+				line++
+				continue
+			case c == '\\' && strings.HasPrefix(src[pos+1:], "\r\n"):
+				pos += 3
+				line++
+				continue
+			case c == '#':
+				for pos < len(src) && src[pos] != '\n' {
+					pos++
+				}
+				continue
+			}
+			break
+		}
+		if pos >= len(src) {
+			return append(lexemes, lexeme{tok: tEOF, line: line}), nil
+		}
+
+		start := pos
+		c := src[pos]
+		l := lexeme{line: line}
+		switch {
+		case c == '\n':
+			l.tok, l.text = tNewline, "\n"
+			pos++
+			line++
+
+		case isDigit(c) || c == '.' && pos+1 < len(src) && isDigit(src[pos+1]):
+			pos = scanNumber(src, pos)
+			text := src[start:pos]
+			n, err := parseNumber(text)
+			if err != nil {
+				return nil, fmt.Errorf("syntax error at line %d: invalid number %s", line, text)
+			}
+			l.tok, l.text, l.num = tNumber, text, n
+
+		case isNameStart(c):
+			for pos < len(src) && isNameChar(src[pos]) {
+				pos++
+			}
+			l.text = src[start:pos]
+			if tok, ok := keywords[l.text]; ok {
+				l.tok = tok
+			} else if _, ok := builtins[l.text]; ok {
+				l.tok = tBuiltin
+			} else if pos < len(src) && src[pos] == '(' {
+				l.tok = tFuncName
+			} else {
+				l.tok = tName
+			}
+
+		case c == '"':
+			text, end, err := scanString(src, pos+1)
+			if err != nil {
+				return nil, fmt.Errorf("syntax error at line %d: %w", line, err)
+			}
+			l.tok, l.text = tString, text
+			pos = end
+
+		case c == '/' && !endsOperand(lexemes):
+			text, end, err := scanRegex(src, pos+1)
+			if err != nil {
+				return nil, fmt.Errorf("syntax error at line %d: %w", line, err)
+			}
+			l.tok, l.text = tRegex, text
+			pos = end
+
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[pos:], op.text) {
+					l.tok, l.text = op.tok, op.text
+					pos += len(op.text)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("syntax error at line %d: unexpected character %q", line, c)
+			}
+		}
+		lexemes = append(lexemes, l)
+	}
+}
+
+// endsOperand reports whether the last lexeme can end an operand, making a
+// following '/' a division
+func endsOperand(lexemes []lexeme) bool {
+	if len(lexemes) == 0 {
+		return false
+	}
+	switch lexemes[len(lexemes)-1].tok {
+	case tNumber, tString, tName, tBuiltin, tRparen, tRbracket, tIncr, tDecr:
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool     { return c >= '0' && c <= '9' }
+func isNameStart(c byte) bool { return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isNameChar(c byte) bool  { return isNameStart(c) || isDigit(c) }
+
+// scanNumber returns the end of the numeric literal starting at pos
+func scanNumber(src string, pos int) int {
+	if strings.HasPrefix(src[pos:], "0x") || strings.HasPrefix(src[pos:], "0X") {
+		end := pos + 2
+		for end < len(src) && strings.IndexByte("0123456789abcdefABCDEF", src[end]) >= 0 {
+			end++
+		}
+		if end > pos+2 {
+			return end
+		}
+	}
+	for pos < len(src) && isDigit(src[pos]) {
+		pos++
+	}
+	if pos < len(src) && src[pos] == '.' {
+		pos++
+		for pos < len(src) && isDigit(src[pos]) {
+			pos++
+		}
+	}
+	if pos < len(src) && (src[pos] == 'e' || src[pos] == 'E') {
+		end := pos + 1
+		if end < len(src) && (src[end] == '+' || src[end] == '-') {
+			end++
+		}
+		if end < len(src) && isDigit(src[end]) {
+			for end < len(src) && isDigit(src[end]) {
+				end++
+			}
+			pos = end
+		}
+	}
+	return pos
+}
+
+// parseNumber converts a numeric literal from the program text
+func parseNumber(text string) (float64, error) {
+	if len(text) > 2 && (text[1] == 'x' || text[1] == 'X') {
+		n, err := strconv.ParseUint(text[2:], 16, 64)
+		return float64(n), err
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// scanString reads a string literal whose text starts at pos, returning its
+// value and the position after the closing quote
+func scanString(src string, pos int) (string, int, error) {
+	var b strings.Builder
+	for pos < len(src) {
+		c := src[pos]
+		switch {
+		case c == '"':
+			return b.String(), pos + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("newline in string")
+		case c == '\\' && pos+1 < len(src):
+			pos = unescapeAt(&b, src, pos+1)
+		default:
+			b.WriteByte(c)
+			pos++
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// scanRegex reads a regex literal whose text starts at pos. Only "\/" is
+// unescaped here, along with the control character escapes the regex
+// layer doesn't know; everything else is left to the regex layer.
+func scanRegex(src string, pos int) (string, int, error) {
+	var b strings.Builder
+	inBracket := false
+	for pos < len(src) {
+		c := src[pos]
+		switch {
+		case c == '\n':
+			return "", 0, fmt.Errorf("newline in regex")
+		case c == '\\' && pos+1 < len(src):
+			switch e := src[pos+1]; e {
+			case '/':
+				b.WriteByte('/')
+			case 'a':
+				b.WriteByte('\a')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'v':
+				b.WriteByte('\v')
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+			pos += 2
+			continue
+		case c == '[' && !inBracket:
+			inBracket = true
+			// A ] right after [ or [^ is a member, not the end
+			b.WriteByte(c)
+			pos++
+			if pos < len(src) && src[pos] == '^' {
+				b.WriteByte('^')
+				pos++
+			}
+			if pos < len(src) && src[pos] == ']' {
+				b.WriteByte(']')
+				pos++
+			}
+			continue
+		case c == ']' && inBracket:
+			inBracket = false
+		case c == '/' && !inBracket:
+			return b.String(), pos + 1, nil
+		}
+		b.WriteByte(c)
+		pos++
+	}
+	return "", 0, fmt.Errorf("unterminated regex")
+}
+
+// unescape processes the escape sequences of a string literal in s, as
+// done for -v assignments and -F
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for pos := 0; pos < len(s); {
+		if s[pos] == '\\' && pos+1 < len(s) {
+			pos = unescapeAt(&b, s, pos+1)
+			continue
+		}
+		b.WriteByte(s[pos])
+		pos++
+	}
+	return b.String()
+}
+
+// unescapeAt writes the escape sequence whose character is at s[pos] and
+// returns the position after it. Unknown escapes keep their backslash so
+// that strings used as dynamic regexes, such as "\.", work as written.
+func unescapeAt(b *strings.Builder, s string, pos int) int {
+	c := s[pos]
+	switch c {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case '\\':
+		b.WriteByte('\\')
+	case '"':
+		b.WriteByte('"')
+	case '/':
+		b.WriteByte('/')
+	case 'a':
+		b.WriteByte('\a')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'v':
+		b.WriteByte('\v')
+	case '\n':
+		// An escaped newline continues the string
+	default:
+		if c >= '0' && c <= '7' {
+			n, end := 0, pos
+			for end < len(s) && end < pos+3 && s[end] >= '0' && s[end] <= '7' {
+				n = n*8 + int(s[end]-'0')
+				end++
+			}
+			b.WriteByte(byte(n))
+			return end
+		}
+		b.WriteByte('\\')
+		b.WriteByte(c)
+	}
+	return pos + 1
+}
@@ -0,0 +1,968 @@
+package awk
+
+import (
+	"fmt"
+	"slices"
+)
+
+// varKind is what a name has been used as so far
+type varKind int
+
+const (
+	kindUnknown varKind = iota
+	kindScalar
+	kindArray
+)
+
+// varUse records a variable reference for resolution after parsing
+type varUse struct {
+	ref  *varExpr
+	fn   *function // function the reference is in, nil at the top level
+	kind varKind
+}
+
+// argUse records a bare name passed to a user function, which is a scalar
+// or an array depending on the function
+type argUse struct {
+	ref  *varExpr
+	fn   *function
+	call *callExpr
+	pos  int
+}
+
+// parser builds a program from lexemes by recursive descent, panicking
+// with a parseError on the first error
+type parser struct {
+	lexemes []lexeme
+	pos     int
+	prog    *program
+
+	fn        *function // function being parsed
+	inAction  bool      // in a rule's action, where next is allowed
+	loopDepth int
+	inPrint   bool // in print arguments, where > redirects
+
+	functions map[string]*function
+	calls     []*callExpr
+	uses      []varUse
+	argUses   []argUse
+}
+
+// parseError carries a syntax error out of the parser
+type parseError struct {
+	err error
+}
+
+// parse parses and resolves an awk program
+func parse(src string) (prog *program, err error) {
+	lexemes, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		lexemes:   lexemes,
+		prog:      &program{},
+		functions: make(map[string]*function),
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, e.err
+		}
+	}()
+
+	p.program()
+	p.resolve()
+	return p.prog, nil
+}
+
+// fail stops parsing with an error at the current lexeme
+func (p *parser) fail(format string, args ...any) {
+	panic(parseError{fmt.Errorf("syntax error at line %d: %s", p.tok().line, fmt.Sprintf(format, args...))})
+}
+
+// unexpected fails on the current lexeme
+func (p *parser) unexpected() {
+	p.fail("unexpected %s", p.tok())
+}
+
+func (p *parser) tok() lexeme {
+	return p.lexemes[p.pos]
+}
+
+// is reports whether the current lexeme is one of toks
+func (p *parser) is(toks ...token) bool {
+	return slices.Contains(toks, p.lexemes[p.pos].tok)
+}
+
+// peek returns the token n lexemes ahead
+func (p *parser) peek(n int) token {
+	if p.pos+n >= len(p.lexemes) {
+		return tEOF
+	}
+	return p.lexemes[p.pos+n].tok
+}
+
+func (p *parser) next() lexeme {
+	l := p.lexemes[p.pos]
+	if l.tok != tEOF {
+		p.pos++
+	}
+	return l
+}
+
+func (p *parser) expect(tok token) lexeme {
+	if !p.is(tok) {
+		p.unexpected()
+	}
+	return p.next()
+}
+
+// newlines skips optional newlines, allowed after { , && || do else and
+// the like
+func (p *parser) newlines() {
+	for p.is(tNewline) {
+		p.next()
+	}
+}
+
+// terminators skips newlines and semicolons between items and statements
+func (p *parser) terminators() {
+	for p.is(tNewline, tSemicolon) {
+		p.next()
+	}
+}
+
+// program parses the whole program
+func (p *parser) program() {
+	p.terminators()
+	for !p.is(tEOF) {
+		p.item()
+		p.terminators()
+	}
+}
+
+// item parses a function definition, BEGIN or END block, or rule
+func (p *parser) item() {
+	switch p.tok().tok {
+	case tFunction:
+		p.function()
+	case tBEGIN:
+		p.next()
+		p.prog.begin = append(p.prog.begin, p.block()...)
+	case tEND:
+		p.next()
+		p.prog.end = append(p.prog.end, p.block()...)
+	default:
+		it := &item{}
+		if !p.is(tLbrace) {
+			it.pattern = p.expr()
+			if p.is(tComma) {
+				p.next()
+				p.newlines()
+				it.pattern2 = p.expr()
+			}
+		}
+		// A pattern without an action prints the record; the action must
+		// start on the same line
+		if p.is(tLbrace) {
+			p.inAction = true
+			it.body = p.block()
+			p.inAction = false
+		}
+		p.prog.items = append(p.prog.items, it)
+	}
+}
+
+// function parses a function definition
+func (p *parser) function() {
+	p.next()
+	if !p.is(tName, tFuncName) {
+		p.fail("expected function name, got %s", p.tok())
+	}
+	name := p.next().text
+	if _, ok := p.functions[name]; ok {
+		p.fail("function %s redefined", name)
+	}
+	fn := &function{name: name}
+	p.functions[name] = fn
+
+	p.expect(tLparen)
+	for !p.is(tRparen) {
+		param := p.expect(tName).text
+		if param == name || slices.Contains(fn.params, param) {
+			p.fail("duplicate parameter %s", param)
+		}
+		if _, ok := specials[param]; ok {
+			p.fail("cannot use special variable %s as a parameter", param)
+		}
+		fn.params = append(fn.params, param)
+		if !p.is(tComma) {
+			break
+		}
+		p.next()
+		p.newlines()
+	}
+	p.expect(tRparen)
+	p.newlines()
+
+	p.fn = fn
+	fn.body = p.block()
+	p.fn = nil
+}
+
+// block parses statements in braces
+func (p *parser) block() []stmt {
+	p.expect(tLbrace)
+	body := []stmt{}
+	for {
+		p.terminators()
+		if p.is(tRbrace, tEOF) {
+			break
+		}
+		body = append(body, p.stmt())
+	}
+	p.expect(tRbrace)
+	return body
+}
+
+// body parses the body of an if, loop or else: a block or one statement
+func (p *parser) body() []stmt {
+	if p.is(tLbrace) {
+		return p.block()
+	}
+	return []stmt{p.stmt()}
+}
+
+// loopBody parses the body of a loop, where break and continue are allowed
+func (p *parser) loopBody() []stmt {
+	if p.is(tSemicolon) {
+		p.next()
+		return nil
+	}
+	p.newlines()
+	p.loopDepth++
+	body := p.body()
+	p.loopDepth--
+	return body
+}
+
+// stmt parses one statement with its terminator
+func (p *parser) stmt() stmt {
+	switch p.tok().tok {
+	case tLbrace:
+		return &blockStmt{body: p.block()}
+
+	case tSemicolon:
+		p.next()
+		return &blockStmt{}
+
+	case tIf:
+		p.next()
+		p.expect(tLparen)
+		s := &ifStmt{cond: p.expr()}
+		p.expect(tRparen)
+		p.newlines()
+		s.then = p.body()
+
+		// else may follow on a later line or after a semicolon
+		save := p.pos
+		p.terminators()
+		if p.is(tElse) {
+			p.next()
+			p.newlines()
+			s.otherwise = p.body()
+		} else {
+			p.pos = save
+		}
+		return s
+
+	case tWhile:
+		p.next()
+		p.expect(tLparen)
+		s := &whileStmt{cond: p.expr()}
+		p.expect(tRparen)
+		s.body = p.loopBody()
+		return s
+
+	case tDo:
+		p.next()
+		p.newlines()
+		p.loopDepth++
+		s := &doStmt{body: p.body()}
+		p.loopDepth--
+		p.terminators()
+		p.expect(tWhile)
+		p.expect(tLparen)
+		s.cond = p.expr()
+		p.expect(tRparen)
+		p.endSimple()
+		return s
+
+	case tFor:
+		return p.forStmt()
+	}
+
+	s := p.simpleStmt()
+	p.endSimple()
+	return s
+}
+
+// endSimple consumes the terminator of a simple statement, which may also
+// end at a closing brace
+func (p *parser) endSimple() {
+	switch {
+	case p.is(tSemicolon, tNewline):
+		p.next()
+	case p.is(tRbrace, tEOF):
+	default:
+		p.unexpected()
+	}
+}
+
+// atEndOfSimple reports whether a simple statement ends here
+func (p *parser) atEndOfSimple() bool {
+	return p.is(tSemicolon, tNewline, tRbrace, tEOF)
+}
+
+// forStmt parses both forms of for
+func (p *parser) forStmt() stmt {
+	p.next()
+	p.expect(tLparen)
+
+	if p.is(tName) && p.peek(1) == tIn && p.peek(2) == tName && p.peek(3) == tRparen {
+		key := p.varRef(p.next().text, kindScalar)
+		p.next()
+		array := p.varRef(p.next().text, kindArray)
+		p.next()
+		return &forInStmt{key: key, array: array, body: p.loopBody()}
+	}
+
+	s := &forStmt{}
+	if !p.is(tSemicolon) {
+		s.init = p.simpleStmt()
+	}
+	p.expect(tSemicolon)
+	p.newlines()
+	if !p.is(tSemicolon) {
+		s.cond = p.expr()
+	}
+	p.expect(tSemicolon)
+	p.newlines()
+	if !p.is(tRparen) {
+		s.post = p.simpleStmt()
+	}
+	p.expect(tRparen)
+	s.body = p.loopBody()
+	return s
+}
+
+// simpleStmt parses a statement that doesn't contain other statements
+func (p *parser) simpleStmt() stmt {
+	switch p.tok().tok {
+	case tPrint, tPrintf:
+		return p.printStmt()
+
+	case tNext, tNextfile:
+		if !p.inAction && p.fn == nil {
+			p.fail("%s used outside a rule", p.tok())
+		}
+		if p.next().tok == tNext {
+			return &controlStmt{ctl: ctlNext}
+		}
+		return &controlStmt{ctl: ctlNextFile}
+
+	case tBreak, tContinue:
+		if p.loopDepth == 0 {
+			p.fail("%s outside a loop", p.tok())
+		}
+		if p.next().tok == tBreak {
+			return &controlStmt{ctl: ctlBreak}
+		}
+		return &controlStmt{ctl: ctlContinue}
+
+	case tExit:
+		p.next()
+		s := &exitStmt{}
+		if !p.atEndOfSimple() {
+			s.status = p.expr()
+		}
+		return s
+
+	case tReturn:
+		if p.fn == nil {
+			p.fail("return outside a function")
+		}
+		p.next()
+		s := &returnStmt{}
+		if !p.atEndOfSimple() {
+			s.value = p.expr()
+		}
+		return s
+
+	case tDelete:
+		p.next()
+		s := &deleteStmt{array: p.varRef(p.expect(tName).text, kindArray)}
+		if p.is(tLbracket) {
+			s.subs = p.subscripts()
+		}
+		return s
+	}
+
+	return &exprStmt{e: p.expr()}
+}
+
+// atEndOfPrint reports whether print's arguments end here
+func (p *parser) atEndOfPrint() bool {
+	return p.atEndOfSimple() || p.is(tGreater, tAppend, tPipe)
+}
+
+// printStmt parses print or printf with an optional redirection
+func (p *parser) printStmt() stmt {
+	s := &printStmt{printf: p.next().tok == tPrintf}
+
+	if !p.atEndOfPrint() {
+		saved := p.inPrint
+		p.inPrint = true
+		s.args = p.exprList()
+		p.inPrint = saved
+
+		if len(s.args) == 1 {
+			if group, ok := s.args[0].(*groupExpr); ok {
+				s.args = group.exprs
+			}
+		}
+	}
+	if s.printf && len(s.args) == 0 {
+		p.fail("printf needs a format")
+	}
+
+	if p.is(tGreater, tAppend, tPipe) {
+		s.redirect = p.next().tok
+		saved := p.inPrint
+		p.inPrint = true
+		s.dest = p.concat()
+		p.inPrint = saved
+	}
+	return s
+}
+
+// exprList parses comma-separated expressions
+func (p *parser) exprList() []expr {
+	list := []expr{p.expr()}
+	for p.is(tComma) {
+		p.next()
+		p.newlines()
+		list = append(list, p.expr())
+	}
+	return list
+}
+
+// subscripts parses [expr, ...]
+func (p *parser) subscripts() []expr {
+	p.expect(tLbracket)
+	saved := p.inPrint
+	p.inPrint = false
+	subs := p.exprList()
+	p.inPrint = saved
+	p.expect(tRbracket)
+	return subs
+}
+
+// isLvalue reports whether e can be assigned to
+func isLvalue(e expr) bool {
+	switch e.(type) {
+	case *varExpr, *indexExpr, *fieldExpr:
+		return true
+	}
+	return false
+}
+
+// expr parses an expression, including assignments
+func (p *parser) expr() expr {
+	left := p.ternary()
+	switch p.tok().tok {
+	case tAssign, tAddAssign, tSubAssign, tMulAssign, tDivAssign, tModAssign, tPowAssign:
+		if !isLvalue(left) {
+			p.fail("cannot assign to this expression")
+		}
+		op := p.next().tok
+		p.newlines()
+		return &assignExpr{target: left, op: op, value: p.expr()}
+	}
+	return left
+}
+
+func (p *parser) ternary() expr {
+	cond := p.or()
+	if !p.is(tQuestion) {
+		return cond
+	}
+	p.next()
+	p.newlines()
+	yes := p.expr()
+	p.newlines()
+	p.expect(tColon)
+	p.newlines()
+	return &condExpr{cond: cond, yes: yes, no: p.expr()}
+}
+
+func (p *parser) or() expr {
+	left := p.and()
+	for p.is(tOr) {
+		p.next()
+		p.newlines()
+		left = &binaryExpr{op: tOr, left: left, right: p.and()}
+	}
+	return left
+}
+
+func (p *parser) and() expr {
+	left := p.in()
+	for p.is(tAnd) {
+		p.next()
+		p.newlines()
+		left = &binaryExpr{op: tAnd, left: left, right: p.in()}
+	}
+	return left
+}
+
+func (p *parser) in() expr {
+	left := p.match()
+	for p.is(tIn) {
+		p.next()
+		subs := []expr{left}
+		if group, ok := left.(*groupExpr); ok {
+			subs = group.exprs
+		}
+		left = &inExpr{subs: subs, array: p.varRef(p.expect(tName).text, kindArray)}
+	}
+	return left
+}
+
+func (p *parser) match() expr {
+	left := p.comparison()
+	for p.is(tMatch, tNotMatch) {
+		negate := p.next().tok == tNotMatch
+		right := p.comparison()
+		m := &matchExpr{left: left, negate: negate}
+		if re, ok := right.(*regexExpr); ok {
+			m.static = re.re
+		} else {
+			m.re = right
+		}
+		left = m
+	}
+	return left
+}
+
+func (p *parser) comparison() expr {
+	left := p.concat()
+	for {
+		switch p.tok().tok {
+		case tLess, tLessEqual, tEqual, tNotEqual, tGreaterEqual:
+		case tGreater:
+			if p.inPrint {
+				return left
+			}
+		case tPipe:
+			if p.peek(1) != tGetline {
+				return left
+			}
+			p.next()
+			p.next()
+			left = &getlineExpr{kind: getlineCommand, src: left, target: p.getlineTarget()}
+			continue
+		default:
+			return left
+		}
+		op := p.next().tok
+		left = &binaryExpr{op: op, left: left, right: p.concat()}
+	}
+}
+
+// startsConcat reports whether the current lexeme starts an operand that
+// is concatenated to the one before. A leading - or ! would make it a
+// binary operation instead.
+func (p *parser) startsConcat() bool {
+	switch p.tok().tok {
+	case tNumber, tString, tRegex, tName, tFuncName, tBuiltin, tDollar, tLparen, tIncr, tDecr:
+		return true
+	}
+	return false
+}
+
+func (p *parser) concat() expr {
+	left := p.additive()
+	if !p.startsConcat() {
+		return left
+	}
+	parts := []expr{left}
+	for p.startsConcat() {
+		parts = append(parts, p.additive())
+	}
+	return &concatExpr{parts: parts}
+}
+
+func (p *parser) additive() expr {
+	left := p.multiplicative()
+	for p.is(tAdd, tSub) {
+		op := p.next().tok
+		left = &binaryExpr{op: op, left: left, right: p.multiplicative()}
+	}
+	return left
+}
+
+func (p *parser) multiplicative() expr {
+	left := p.unary()
+	for p.is(tMul, tDiv, tMod) {
+		op := p.next().tok
+		left = &binaryExpr{op: op, left: left, right: p.unary()}
+	}
+	return left
+}
+
+func (p *parser) unary() expr {
+	if p.is(tNot, tSub, tAdd) {
+		op := p.next().tok
+		return &unaryExpr{op: op, operand: p.unary()}
+	}
+	return p.power()
+}
+
+// power parses ^, which is right associative and binds tighter than unary
+// minus on its left but not on its right: -2^2 is -4 and 2^-1 is 0.5
+func (p *parser) power() expr {
+	base := p.postfix()
+	if !p.is(tPow) {
+		return base
+	}
+	p.next()
+	return &binaryExpr{op: tPow, left: base, right: p.unary()}
+}
+
+func (p *parser) postfix() expr {
+	e := p.primary()
+	if p.is(tIncr, tDecr) && isLvalue(e) {
+		delta := 1.0
+		if p.next().tok == tDecr {
+			delta = -1
+		}
+		return &incrExpr{target: e, delta: delta}
+	}
+	return e
+}
+
+func (p *parser) primary() expr {
+	l := p.tok()
+	switch l.tok {
+	case tNumber:
+		p.next()
+		return &numExpr{n: l.num}
+
+	case tString:
+		p.next()
+		return &strExpr{s: l.text}
+
+	case tRegex:
+		p.next()
+		re, err := compileRegex(l.text)
+		if err != nil {
+			p.fail("%v", err)
+		}
+		return &regexExpr{re: re}
+
+	case tDollar:
+		p.next()
+		if p.is(tIncr, tDecr) {
+			return &fieldExpr{index: p.primary()}
+		}
+		if p.is(tSub) {
+			p.next()
+			return &fieldExpr{index: &unaryExpr{op: tSub, operand: p.primary()}}
+		}
+		return &fieldExpr{index: p.primary()}
+
+	case tIncr, tDecr:
+		p.next()
+		target := p.primary()
+		if !isLvalue(target) {
+			p.fail("%s needs a variable", l)
+		}
+		delta := 1.0
+		if l.tok == tDecr {
+			delta = -1
+		}
+		return &incrExpr{target: target, delta: delta, pre: true}
+
+	case tNot, tSub, tAdd:
+		return p.unary()
+
+	case tLparen:
+		p.next()
+		saved := p.inPrint
+		p.inPrint = false
+		list := p.exprList()
+		p.inPrint = saved
+		p.expect(tRparen)
+		if len(list) == 1 {
+			return list[0]
+		}
+		// (a, b) groups subscripts for in, or print's arguments
+		if p.is(tIn) || p.inPrint && p.atEndOfPrint() {
+			return &groupExpr{exprs: list}
+		}
+		p.fail("unexpected list in parentheses")
+
+	case tGetline:
+		p.next()
+		e := &getlineExpr{kind: getlineMain, target: p.getlineTarget()}
+		if p.is(tLess) {
+			p.next()
+			e.kind = getlineFile
+			e.src = p.postfix()
+		}
+		return e
+
+	case tBuiltin:
+		return p.builtinCall()
+
+	case tFuncName:
+		p.next()
+		call := &callExpr{name: l.text, line: l.line}
+		p.expect(tLparen)
+		for i := 0; !p.is(tRparen); i++ {
+			p.newlines()
+			if p.is(tName) && (p.peek(1) == tComma || p.peek(1) == tRparen) {
+				ref := &varExpr{name: p.next().text}
+				p.argUses = append(p.argUses, argUse{ref: ref, fn: p.fn, call: call, pos: i})
+				call.args = append(call.args, ref)
+			} else {
+				call.args = append(call.args, p.callArg())
+			}
+			if !p.is(tComma) {
+				break
+			}
+			p.next()
+		}
+		p.newlines()
+		p.expect(tRparen)
+		p.calls = append(p.calls, call)
+		return call
+
+	case tName:
+		p.next()
+		if p.is(tLbracket) {
+			return &indexExpr{array: p.varRef(l.text, kindArray), subs: p.subscripts()}
+		}
+		return p.varRef(l.text, kindScalar)
+	}
+
+	p.unexpected()
+	return nil
+}
+
+// callArg parses a function argument, where > compares
+func (p *parser) callArg() expr {
+	saved := p.inPrint
+	p.inPrint = false
+	e := p.expr()
+	p.inPrint = saved
+	return e
+}
+
+// getlineTarget parses the optional variable getline reads into
+func (p *parser) getlineTarget() expr {
+	switch {
+	case p.is(tName):
+		name := p.next().text
+		if p.is(tLbracket) {
+			return &indexExpr{array: p.varRef(name, kindArray), subs: p.subscripts()}
+		}
+		return p.varRef(name, kindScalar)
+	case p.is(tDollar):
+		p.next()
+		return &fieldExpr{index: p.primary()}
+	}
+	return nil
+}
+
+// builtinCall parses a call of a built-in function
+func (p *parser) builtinCall() expr {
+	l := p.next()
+	e := &builtinExpr{fn: builtins[l.text]}
+
+	// length without parentheses is the length of $0
+	if e.fn == bLength && !p.is(tLparen) {
+		return e
+	}
+
+	p.expect(tLparen)
+	for i := 0; !p.is(tRparen); i++ {
+		p.newlines()
+		switch {
+		case e.fn == bSplit && i == 1:
+			e.args = append(e.args, p.varRef(p.expect(tName).text, kindArray))
+		case e.fn == bLength && i == 0 && p.is(tName) && p.peek(1) == tRparen:
+			// length(x) takes a scalar or, as in gawk, an array
+			e.args = append(e.args, p.varRef(p.next().text, kindUnknown))
+		default:
+			e.args = append(e.args, p.callArg())
+		}
+		if !p.is(tComma) {
+			break
+		}
+		p.next()
+	}
+	p.newlines()
+	p.expect(tRparen)
+
+	arity := builtinArity[e.fn]
+	if len(e.args) < arity[0] || arity[1] >= 0 && len(e.args) > arity[1] {
+		p.fail("wrong number of arguments to %s", l.text)
+	}
+	if (e.fn == bSub || e.fn == bGsub) && len(e.args) == 3 && !isLvalue(e.args[2]) {
+		p.fail("%s needs a variable to change", l.text)
+	}
+	return e
+}
+
+// varRef returns a variable to be resolved once the whole program is known
+func (p *parser) varRef(name string, kind varKind) *varExpr {
+	ref := &varExpr{name: name}
+	p.uses = append(p.uses, varUse{ref: ref, fn: p.fn, kind: kind})
+	return ref
+}
+
+// resolve binds calls to functions and variables to slots. Whether a name
+// is a scalar or an array follows from how it is used, including through
+// the parameters of the functions it is passed to.
+func (p *parser) resolve() {
+	fail := func(format string, args ...any) {
+		panic(parseError{fmt.Errorf(format, args...)})
+	}
+
+	globals := make(map[string]*varKind)
+	for name := range specials {
+		kind := kindScalar
+		globals[name] = &kind
+	}
+	for _, name := range []string{"ENVIRON", "ARGV"} {
+		kind := kindArray
+		globals[name] = &kind
+	}
+	params := make(map[*function][]varKind)
+	for _, fn := range p.functions {
+		params[fn] = make([]varKind, len(fn.params))
+	}
+
+	kindOf := func(fn *function, name string) *varKind {
+		if fn != nil {
+			if i := slices.Index(fn.params, name); i >= 0 {
+				return &params[fn][i]
+			}
+		}
+		if _, ok := p.functions[name]; ok {
+			fail("function %s used as a variable", name)
+		}
+		kind, ok := globals[name]
+		if !ok {
+			kind = new(varKind)
+			globals[name] = kind
+		}
+		return kind
+	}
+	use := func(kind *varKind, name string, as varKind) bool {
+		switch {
+		case as == kindUnknown || *kind == as:
+			return false
+		case *kind == kindUnknown:
+			*kind = as
+			return true
+		case as == kindArray:
+			fail("cannot use scalar %s as an array", name)
+		default:
+			fail("cannot use array %s as a scalar", name)
+		}
+		return false
+	}
+
+	for _, call := range p.calls {
+		fn, ok := p.functions[call.name]
+		if !ok {
+			fail("syntax error at line %d: calling undefined function %s", call.line, call.name)
+		}
+		if len(call.args) > len(fn.params) {
+			fail("syntax error at line %d: %s called with %d arguments, accepts %d", call.line, call.name, len(call.args), len(fn.params))
+		}
+		call.fn = fn
+	}
+	for _, u := range p.uses {
+		use(kindOf(u.fn, u.ref.name), u.ref.name, u.kind)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, a := range p.argUses {
+			caller, param := kindOf(a.fn, a.ref.name), &params[a.call.fn][a.pos]
+			if use(caller, a.ref.name, *param) || use(param, a.call.fn.params[a.pos], *caller) {
+				changed = true
+			}
+		}
+	}
+	for _, call := range p.calls {
+		for i, arg := range call.args {
+			if _, bare := arg.(*varExpr); !bare && params[call.fn][i] == kindArray {
+				fail("syntax error at line %d: %s expects an array as argument %d", call.line, call.name, i+1)
+			}
+		}
+	}
+
+	// Assign slots; names never used as arrays are scalars
+	p.prog.functions = p.functions
+	p.prog.scalars = make(map[string]int)
+	p.prog.arrays = make(map[string]int)
+	for name, kind := range globals {
+		if _, ok := specials[name]; ok {
+			continue
+		}
+		if *kind == kindArray {
+			p.prog.arrays[name] = len(p.prog.arrays)
+		} else {
+			p.prog.scalars[name] = len(p.prog.scalars)
+		}
+	}
+	for fn, kinds := range params {
+		fn.array = make([]bool, len(kinds))
+		for i, kind := range kinds {
+			fn.array[i] = kind == kindArray
+		}
+	}
+
+	bind := func(ref *varExpr, fn *function) {
+		if fn != nil {
+			if i := slices.Index(fn.params, ref.name); i >= 0 {
+				ref.scope, ref.index, ref.array = scopeLocal, i, fn.array[i]
+				return
+			}
+		}
+		if i, ok := specials[ref.name]; ok {
+			ref.scope, ref.index = scopeSpecial, i
+			return
+		}
+		if i, ok := p.prog.arrays[ref.name]; ok {
+			ref.scope, ref.index, ref.array = scopeGlobal, i, true
+			return
+		}
+		ref.scope, ref.index = scopeGlobal, p.prog.scalars[ref.name]
+	}
+	for _, u := range p.uses {
+		bind(u.ref, u.fn)
+	}
+	for _, a := range p.argUses {
+		bind(a.ref, a.fn)
+	}
+}
@@ -0,0 +1,243 @@
+package awk
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// valueType is the type of an awk value
+type valueType uint8
+
+const (
+	typeNone   valueType = iota // uninitialized: both "" and 0
+	typeNum                     // number
+	typeStr                     // string
+	typeStrnum                  // input such as a field: a number if it looks like one
+)
+
+// value is an awk scalar. Input strings are kept as strings and only parsed
+// when used as numbers.
+type value struct {
+	typ valueType
+	s   string
+	n   float64
+}
+
+func num(n float64) value {
+	return value{typ: typeNum, n: n}
+}
+
+func str(s string) value {
+	return value{typ: typeStr, s: s}
+}
+
+func strnum(s string) value {
+	return value{typ: typeStrnum, s: s}
+}
+
+func boolean(b bool) value {
+	if b {
+		return value{typ: typeNum, n: 1}
+	}
+	return value{typ: typeNum}
+}
+
+// num returns v as a number
+func (v value) num() float64 {
+	switch v.typ {
+	case typeNum:
+		return v.n
+	case typeStr, typeStrnum:
+		return strToNum(v.s)
+	}
+	return 0
+}
+
+// str returns v as a string, formatting numbers with convfmt
+func (v value) str(convfmt string) string {
+	if v.typ == typeNum {
+		return numToStr(v.n, convfmt)
+	}
+	return v.s
+}
+
+// isNum reports whether v compares as a number
+func (v value) isNum() bool {
+	switch v.typ {
+	case typeNum, typeNone:
+		return true
+	case typeStrnum:
+		return looksNumeric(v.s)
+	}
+	return false
+}
+
+// bool returns v's truth value: nonzero numbers and nonempty strings are
+// true
+func (v value) bool() bool {
+	switch v.typ {
+	case typeNum:
+		return v.n != 0
+	case typeStr:
+		return v.s != ""
+	case typeStrnum:
+		if looksNumeric(v.s) {
+			return strToNum(v.s) != 0
+		}
+		return v.s != ""
+	}
+	return false
+}
+
+// numToStr formats n as awk does: integers exactly, anything else with
+// format
+func numToStr(n float64, format string) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e16 {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	switch {
+	case math.IsNaN(n):
+		if math.Signbit(n) {
+			return "-nan"
+		}
+		return "nan"
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	}
+	if format == "%.6g" {
+		return strconv.FormatFloat(n, 'g', 6, 64)
+	}
+	// The format is a printf one, which may convert to an integer
+	return sprintf(format, []value{num(n)}, "%.6g")
+}
+
+// strToNum converts the longest numeric prefix of s, after leading blanks,
+// to a number: "12abc" is 12 and "abc" is 0
+func strToNum(s string) float64 {
+	// Plain integers are the common case
+	if n, ok := parseInt(s); ok {
+		return n
+	}
+
+	i := 0
+	for i < len(s) && isBlank(s[i]) {
+		i++
+	}
+	end := scanNumeric(s, i)
+	if end == i {
+		return 0
+	}
+	// Out of range numbers become ±Inf, which ParseFloat returns with its
+	// error
+	n, _ := strconv.ParseFloat(s[i:end], 64)
+	return n
+}
+
+// parseInt quickly converts a string that is nothing but a short decimal
+// integer
+func parseInt(s string) (float64, bool) {
+	if len(s) == 0 || len(s) > 15 {
+		return 0, false
+	}
+	neg := false
+	i := 0
+	if s[0] == '-' || s[0] == '+' {
+		neg = s[0] == '-'
+		i = 1
+		if len(s) == 1 {
+			return 0, false
+		}
+	}
+	n := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return float64(n), true
+}
+
+// scanNumeric returns the end of the decimal number starting at s[i], or i
+// if there is none
+func scanNumeric(s string, i int) int {
+	start := i
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return start
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+// looksNumeric reports whether s is a number with optional surrounding
+// blanks, which makes input compare numerically
+func looksNumeric(s string) bool {
+	if _, ok := parseInt(s); ok {
+		return true
+	}
+	i := 0
+	for i < len(s) && isBlank(s[i]) {
+		i++
+	}
+	end := scanNumeric(s, i)
+	if end == i {
+		return false
+	}
+	for end < len(s) && isBlank(s[end]) {
+		end++
+	}
+	return end == len(s)
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// compare compares two values as numbers if both look like numbers, and as
+// strings otherwise
+func compare(a, b value, convfmt string) int {
+	if a.isNum() && b.isNum() {
+		x, y := a.num(), b.num()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.str(convfmt), b.str(convfmt))
+}
@@ -0,0 +1,173 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// Options holds backup configuration
+type Options struct {
+	Dest      string // directory the snapshots are kept in
+	Name      string // what snapshot file names start with
+	Include   []string
+	Exclude   []string
+	Gitignore bool
+	Keep      int // snapshots to keep, the newest; 0 keeps all
+}
+
+// Command returns the backup command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "backup [flags] path...",
+		Short: "Take a compressed snapshot of files",
+		Long: `Write the files and directories at paths to a new snapshot, a
+zstd-compressed tar file named after --name and the time it was taken
+in UTC, such as backup-20240501-120000.000` + Extension + `, in the
+--dest directory, and print its path. restore puts the files back.
+
+Files are stored under their paths as given, with a leading / or ../
+dropped, along with directories and symbolic links; their modes and
+modification times are kept. A file that can't be read is reported and
+left out, and the exit status is then 1. The snapshot only takes its
+name once it is complete.
+
+--exclude leaves out the paths a pattern matches, directories with all
+below them, and --include stores only the files one matches. A pattern
+without a slash matches the name of a file at any depth, and one with a
+slash its path below the path given, with ** for any number of
+directories. Snapshots in --dest are never stored in another.
+
+--keep N removes the oldest snapshots of the same --name in --dest
+after writing a new one, so that N are left.
+
+Examples:
+  backup --dest /backups ~/notes ~/.config
+  backup --exclude node_modules --exclude '*.log' --keep 7 --dest /backups src
+  backup --include '**/*.go' --include go.mod --name code --dest /backups .`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			r := rules{include: opts.Include, exclude: opts.Exclude}
+			if err := r.validate(); err != nil {
+				return exitcode.New(2, err)
+			}
+			if opts.Keep < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --keep %d", opts.Keep))
+			}
+			if opts.Name == "" || strings.ContainsAny(opts.Name, `/\`) {
+				return exitcode.New(2, fmt.Errorf("invalid --name %q", opts.Name))
+			}
+			if info, err := os.Stat(opts.Dest); err != nil {
+				return fmt.Errorf("cannot access '%s': %w", opts.Dest, err)
+			} else if !info.IsDir() {
+				return fmt.Errorf("'%s' is not a directory", opts.Dest)
+			}
+			cmd.SilenceErrors = true
+
+			roots := glob.Expand(args)
+			file := filepath.Join(opts.Dest, snapshotName(opts.Name, time.Now()))
+			a := &archiver{
+				rules:  r,
+				walker: &walk.Walker{Gitignore: opts.Gitignore},
+				skip:   keptSnapshot(opts),
+			}
+			if dryrun.Enabled {
+				for _, root := range roots {
+					if err := a.add(ctx, root); err != nil {
+						if ctx.Err() != nil {
+							return err
+						}
+						logging.PathError("Cannot back up", root, err)
+						a.failed = true
+					}
+				}
+				dryrun.Report("create '%s' with %d entries", file, a.entries)
+			} else {
+				if progress.Enabled() {
+					a.bar = progress.New("backup", progress.Bytes, progress.Size(ctx, roots...))
+				}
+				err := create(ctx, file, roots, a)
+				a.bar.Finish()
+				if err != nil {
+					logging.Error(err)
+					return exitcode.Status(1)
+				}
+				fmt.Fprintln(output.Stdout, file)
+			}
+
+			if opts.Keep > 0 {
+				if err := prune(opts); err != nil {
+					logging.Error(err)
+					a.failed = true
+				}
+			}
+			if a.failed || a.walker.Failed() {
+				return exitcode.Status(1)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Dest, "dest", ".", "Write snapshots to `DIR`")
+	cmd.Flags().StringVar(&opts.Name, "name", "backup", "Start snapshot file names with `NAME`")
+	cmd.Flags().StringArrayVar(&opts.Include, "include", nil, "Only store files matching `PATTERN` (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.Exclude, "exclude", nil, "Leave out paths matching `PATTERN` (repeatable)")
+	cmd.Flags().BoolVar(&opts.Gitignore, "gitignore", false, "Leave out files ignored by .gitignore, and .git itself")
+	cmd.Flags().IntVar(&opts.Keep, "keep", 0, "Keep only the newest `N` snapshots (0 = all)")
+
+	return cmd
+}
+
+// keptSnapshot returns whether a path is one of the snapshots in
+// --dest, which a new snapshot shouldn't hold if --dest is being backed up
+func keptSnapshot(opts *Options) func(path string) bool {
+	dest, err := filepath.Abs(opts.Dest)
+	return func(path string) bool {
+		if err != nil || !isSnapshot(filepath.Base(path), opts.Name) {
+			return false
+		}
+		abs, err := filepath.Abs(path)
+		return err == nil && filepath.Dir(abs) == dest
+	}
+}
+
+// prune removes the oldest snapshots in --dest so that --keep are left
+func prune(opts *Options) error {
+	files, err := snapshots(opts.Dest, opts.Name)
+	if err != nil {
+		return fmt.Errorf("cannot list snapshots in '%s': %w", opts.Dest, err)
+	}
+	if dryrun.Enabled {
+		// The snapshot that would have been taken counts as the newest
+		files = append(files, "")
+	}
+	var errs []error
+	for _, file := range files[:max(len(files)-opts.Keep, 0)] {
+		if dryrun.Enabled {
+			dryrun.Report("remove '%s'", file)
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			errs = append(errs, fmt.Errorf("cannot remove '%s': %w", file, err))
+			continue
+		}
+		logging.Info(fmt.Sprintf("Removed old snapshot '%s'", file))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,202 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/config"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// makeFiles creates files with the given contents below dir
+func makeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+// snapshot backs up roots, relative to dir, to a snapshot in dir with the
+// given rules and returns its path
+func snapshot(t *testing.T, dir string, r rules, roots ...string) string {
+	t.Chdir(dir)
+	file := filepath.Join(dir, snapshotName("backup", time.Now()))
+	a := &archiver{rules: r, walker: &walk.Walker{}, skip: func(string) bool { return false }}
+	require.NoError(t, create(context.Background(), file, roots, a))
+	assert.False(t, a.failed)
+	return file
+}
+
+// TestEntryName tests that stored names can't lead outside the directory
+// restored to
+func TestEntryName(t *testing.T) {
+	for path, want := range map[string]string{
+		"src/a.go":          "src/a.go",
+		"./src//b/../a.go":  "src/a.go",
+		"/etc/hosts":        "etc/hosts",
+		"../../x/y":         "x/y",
+		"..":                ".",
+		".":                 ".",
+		"dir/../../outside": "outside",
+	} {
+		assert.Equal(t, want, entryName(filepath.FromSlash(path)), path)
+	}
+}
+
+// TestMatch tests that patterns without a slash match names at any depth
+// and those with one the path below the root
+func TestMatch(t *testing.T) {
+	assert.True(t, match([]string{"*.log"}, "a/b/c.log"))
+	assert.True(t, match([]string{"node_modules"}, "web/node_modules"))
+	assert.False(t, match([]string{"a/*.go"}, "b/a/x.go"))
+	assert.True(t, match([]string{"**/*.go"}, "b/a/x.go"))
+	assert.False(t, match(nil, "x"))
+}
+
+// TestCreateRestore tests that files restored from a snapshot have the
+// contents, modes and times they had, and that --exclude and --include
+// decide what is stored
+func TestCreateRestore(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{
+		"src/main.go":           "package main\n",
+		"src/run.sh":            "#!/bin/sh\n",
+		"src/app.log":           "log\n",
+		"src/node_modules/x.js": "x\n",
+	})
+	require.NoError(t, os.Chmod(filepath.Join(dir, "src", "run.sh"), 0755))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "src", "main.go"), mtime, mtime))
+
+	file := snapshot(t, dir, rules{exclude: []string{"node_modules", "*.log"}}, "src")
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd}), "zstd-compressed")
+	out := filepath.Join(dir, "out")
+	require.NoError(t, restore(context.Background(), file, nil, &RestoreOptions{Directory: out}))
+
+	data, err = os.ReadFile(filepath.Join(out, "src", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(data))
+	info, err := os.Stat(filepath.Join(out, "src", "main.go"))
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(info.ModTime()))
+	info, err = os.Stat(filepath.Join(out, "src", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.NoFileExists(t, filepath.Join(out, "src", "app.log"))
+	assert.NoDirExists(t, filepath.Join(out, "src", "node_modules"))
+
+	file = snapshot(t, dir, rules{include: []string{"*.js"}}, "src")
+	out = filepath.Join(dir, "js")
+	require.NoError(t, restore(context.Background(), file, nil, &RestoreOptions{Directory: out}))
+	assert.FileExists(t, filepath.Join(out, "src", "node_modules", "x.js"))
+	assert.NoFileExists(t, filepath.Join(out, "src", "main.go"))
+}
+
+// TestRestore_Paths tests restoring only some paths, and that a path the
+// snapshot doesn't hold is an error
+func TestRestore_Paths(t *testing.T) {
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{"a/x": "x", "a/y/z": "z", "b": "b"})
+	file := snapshot(t, dir, rules{}, "a", "b")
+
+	out := filepath.Join(dir, "out")
+	require.NoError(t, restore(context.Background(), file, []string{"a/y"}, &RestoreOptions{Directory: out}))
+	assert.FileExists(t, filepath.Join(out, "a", "y", "z"))
+	assert.NoFileExists(t, filepath.Join(out, "a", "x"))
+	assert.NoFileExists(t, filepath.Join(out, "b"))
+
+	err := restore(context.Background(), file, []string{"c"}, &RestoreOptions{Directory: out})
+	assert.Equal(t, 1, exitcode.From(err))
+}
+
+// TestRestore_Outside tests that entries leading outside the directory
+// restored to, by name or through a link, are refused
+func TestRestore_Outside(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.Mkdir(outside, 0755))
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	tw := tar.NewWriter(zw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside}))
+	for _, name := range []string{"link/x", "../x", "ok"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 1}))
+		_, err := tw.Write([]byte("!"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+	file := filepath.Join(dir, "evil"+Extension)
+	require.NoError(t, os.WriteFile(file, buf.Bytes(), 0644))
+
+	out := filepath.Join(dir, "out")
+	err = restore(context.Background(), file, nil, &RestoreOptions{Directory: out})
+	assert.Equal(t, 1, exitcode.From(err))
+	assert.NoFileExists(t, filepath.Join(outside, "x"))
+	assert.NoFileExists(t, filepath.Join(dir, "x"))
+	assert.FileExists(t, filepath.Join(out, "ok"))
+}
+
+// TestPrune tests that --keep removes the oldest snapshots of the same
+// name only
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var names []string
+	for i := range 4 {
+		names = append(names, snapshotName("backup", start.Add(time.Duration(i)*time.Hour)))
+	}
+	for _, name := range append(names, snapshotName("other", start), "backup-notes"+Extension) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	require.NoError(t, prune(&Options{Dest: dir, Name: "backup", Keep: 2}))
+	left, err := snapshots(dir, "backup")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, names[2]), filepath.Join(dir, names[3])}, left)
+	assert.FileExists(t, filepath.Join(dir, snapshotName("other", start)))
+	assert.FileExists(t, filepath.Join(dir, "backup-notes"+Extension))
+}
+
+// TestCommand_ConfigIgnore tests that a snapshot holds every file, even
+// names the configuration tells find and tree to skip, but not itself
+// when --dest is below a path backed up
+func TestCommand_ConfigIgnore(t *testing.T) {
+	defer func(saved []string) { config.IgnorePatterns = saved }(config.IgnorePatterns)
+	config.IgnorePatterns = []string{"node_modules", "*.log"}
+
+	dir := t.TempDir()
+	makeFiles(t, dir, map[string]string{"node_modules/m.js": "m", "app.log": "log"})
+	t.Chdir(dir)
+	cmd := Command()
+	cmd.SetArgs([]string{"--dest", dir, "."})
+	require.NoError(t, cmd.Execute())
+	require.NoError(t, output.Flush())
+
+	files, err := snapshots(dir, "backup")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	out := filepath.Join(t.TempDir(), "out")
+	require.NoError(t, restore(context.Background(), files[0], nil, &RestoreOptions{Directory: out}))
+	assert.FileExists(t, filepath.Join(out, "node_modules", "m.js"))
+	assert.FileExists(t, filepath.Join(out, "app.log"))
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
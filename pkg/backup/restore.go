@@ -0,0 +1,253 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/archive"
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// RestoreOptions holds restore configuration
+type RestoreOptions struct {
+	Directory string // directory restored into
+	Name      string // the snapshots to pick the newest of when given a directory
+	Verbose   bool
+}
+
+// RestoreCommand returns the restore command
+func RestoreCommand() *cobra.Command {
+	opts := &RestoreOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "restore [flags] snapshot [path...]",
+		Short: "Restore files from a backup snapshot",
+		Long: `Restore the files in a snapshot written by backup into the --directory
+directory, the current one by default, replacing files of the same
+names. With paths, only those files and what is below those directories
+are restored. When snapshot is a directory, the newest snapshot called
+--name in it is restored.
+
+Modes and modification times are restored with the files. Entries that
+would land outside the directory, whether by their names or through
+symbolic links, are refused.
+
+Examples:
+  restore /backups/backup-20240501-120000.000` + Extension + `
+  restore --directory /tmp/notes /backups home/me/notes/todo.md`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Name == "" || strings.ContainsAny(opts.Name, `/\`) {
+				return exitcode.New(2, fmt.Errorf("invalid --name %q", opts.Name))
+			}
+			snapshot := args[0]
+			if info, err := os.Stat(snapshot); err == nil && info.IsDir() {
+				files, err := snapshots(snapshot, opts.Name)
+				if err != nil {
+					return fmt.Errorf("cannot list snapshots in '%s': %w", snapshot, err)
+				}
+				if len(files) == 0 {
+					return fmt.Errorf("no snapshots called '%s' in '%s'", opts.Name, snapshot)
+				}
+				snapshot = files[len(files)-1]
+			}
+			paths := make([]string, len(args)-1)
+			for i, p := range args[1:] {
+				paths[i] = entryName(p)
+			}
+			cmd.SilenceErrors = true
+			return restore(cmd.Context(), snapshot, paths, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Directory, "directory", "C", ".", "Restore into `DIR`")
+	cmd.Flags().StringVar(&opts.Name, "name", "backup", "Restore the newest snapshot called `NAME` when given a directory")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Print each path restored")
+
+	return cmd
+}
+
+// restore extracts the entries of snapshot that paths select, or all of
+// them, and returns status 1 if any could not be or paths named one the
+// snapshot doesn't hold
+func restore(ctx context.Context, snapshot string, paths []string, opts *RestoreOptions) error {
+	// Everything is created through root, which refuses to go outside the
+	// directory by .. or by links. It is only made once there is something
+	// to restore into it.
+	var root *os.Root
+	openRoot := func() error {
+		if root != nil {
+			return nil
+		}
+		if err := os.MkdirAll(opts.Directory, 0755); err != nil {
+			return err
+		}
+		var err error
+		root, err = os.OpenRoot(opts.Directory)
+		return err
+	}
+	defer func() {
+		if root != nil {
+			root.Close()
+		}
+	}()
+
+	failed := false
+	found := make([]bool, len(paths))
+	var dirs []*archive.Entry // to set times on once their contents are in
+	err := archive.Walk(ctx, snapshot, func(e *archive.Entry, contents io.Reader) error {
+		if !fs.ValidPath(e.Name) {
+			logging.PathError("Cannot restore", e.Name, errors.New("path outside the directory"))
+			failed = true
+			return nil
+		}
+		if !selected(e.Name, paths, found) {
+			return nil
+		}
+		target := filepath.Join(opts.Directory, filepath.FromSlash(e.Name))
+		if dryrun.Enabled {
+			dryrun.Report("restore '%s'", target)
+			return nil
+		}
+		if err := openRoot(); err != nil {
+			return &fatalError{fmt.Errorf("cannot access '%s': %w", opts.Directory, err)}
+		}
+		if err := extract(root, contents, e); err != nil {
+			if interrupt.Interrupted(err) || ctx.Err() != nil {
+				return err
+			}
+			logging.PathError("Cannot restore", target, err)
+			failed = true
+			return nil
+		}
+		if e.Type == "directory" {
+			dirs = append(dirs, e)
+		}
+		if opts.Verbose {
+			fmt.Fprintln(output.Stdout, target)
+		}
+		return nil
+	})
+	var fatal *fatalError
+	switch {
+	case err == nil:
+	case interrupt.Interrupted(err) || ctx.Err() != nil:
+		return err
+	case errors.As(err, &fatal):
+		logging.Error(fatal.err)
+		return exitcode.Status(1)
+	default:
+		logging.Error(fmt.Errorf("cannot read '%s': %w", snapshot, err))
+		failed = true
+	}
+
+	// Deepest first, so that setting a directory's time doesn't touch
+	// its parent's
+	for _, e := range slices.Backward(dirs) {
+		if err := root.Chtimes(e.Name, e.ModTime, e.ModTime); err != nil {
+			logging.PathWarn("Cannot set times on", filepath.Join(opts.Directory, e.Name), err)
+		}
+	}
+	for i, p := range paths {
+		if !found[i] {
+			logging.PathError("Cannot restore", p, errors.New("not found in snapshot"))
+			failed = true
+		}
+	}
+	if failed {
+		return exitcode.Status(1)
+	}
+	return nil
+}
+
+// selected reports whether the entry called name is one of paths or
+// below one, or paths are empty, and marks the paths that select it found
+func selected(name string, paths []string, found []bool) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	ok := false
+	for i, p := range paths {
+		if p == "." || name == p || strings.HasPrefix(name, p+"/") {
+			found[i] = true
+			ok = true
+		}
+	}
+	return ok
+}
+
+// extract creates the entry e describes in root, with contents
+func extract(root *os.Root, contents io.Reader, e *archive.Entry) error {
+	if dir := path.Dir(e.Name); dir != "." {
+		if err := root.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	switch e.Type {
+	case "directory":
+		if err := root.MkdirAll(e.Name, 0755); err != nil {
+			return err
+		}
+		return root.Chmod(e.Name, e.Mode.Perm())
+
+	case "link":
+		if err := removeExisting(root, e.Name); err != nil {
+			return err
+		}
+		return root.Symlink(e.Target, e.Name)
+
+	case "file":
+		// A file or link already there is replaced rather than written
+		// through
+		if err := removeExisting(root, e.Name); err != nil {
+			return err
+		}
+		f, err := root.OpenFile(e.Name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, contents); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if err := root.Chmod(e.Name, e.Mode.Perm()); err != nil {
+			return err
+		}
+		return root.Chtimes(e.Name, time.Now(), e.ModTime)
+	}
+	return fmt.Errorf("unsupported entry type %s", e.Type)
+}
+
+// removeExisting removes what is at name in root, unless it is a
+// directory or nothing is
+func removeExisting(root *os.Root, name string) error {
+	info, err := root.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("'%s' is a directory", name)
+	}
+	return root.Remove(name)
+}
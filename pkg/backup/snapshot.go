@@ -0,0 +1,297 @@
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// Extension is the suffix of snapshot files
+const Extension = ".tar.zst"
+
+// stampLayout is the UTC time in snapshot names, which sort as the times
+// they were taken do
+const stampLayout = "20060102-150405.000"
+
+// snapshotName returns the file name of the snapshot called prefix taken
+// at t
+func snapshotName(prefix string, t time.Time) string {
+	return prefix + "-" + t.UTC().Format(stampLayout) + Extension
+}
+
+// isSnapshot reports whether name is the file name of a snapshot called
+// prefix
+func isSnapshot(name, prefix string) bool {
+	stamp, ok := strings.CutPrefix(name, prefix+"-")
+	if !ok {
+		return false
+	}
+	stamp, ok = strings.CutSuffix(stamp, Extension)
+	if !ok {
+		return false
+	}
+	_, err := time.Parse(stampLayout, stamp)
+	return err == nil
+}
+
+// snapshots returns the paths of the snapshots called prefix in dir,
+// oldest first
+func snapshots(dir, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isSnapshot(entry.Name(), prefix) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// entryName returns the name a path is stored under: slash-separated and
+// relative, without a volume or the leading / and ../ that would restore
+// it outside the directory restored to
+func entryName(p string) string {
+	name := filepath.ToSlash(filepath.Clean(strings.TrimPrefix(p, filepath.VolumeName(p))))
+	for {
+		switch {
+		case strings.HasPrefix(name, "/"):
+			name = name[1:]
+		case strings.HasPrefix(name, "../"):
+			name = name[3:]
+		case name == "..", name == "":
+			return "."
+		default:
+			return name
+		}
+	}
+}
+
+// rules decide which paths below a root a snapshot holds. A pattern
+// without a slash matches the base name at any depth, and one with a
+// slash the path relative to the root, in doublestar syntax.
+type rules struct {
+	include []string // if any, only files matching one are stored
+	exclude []string // paths matching one are left out, directories with all below them
+}
+
+// validate checks the syntax of every pattern
+func (r rules) validate() error {
+	for _, p := range slices.Concat(r.include, r.exclude) {
+		if !doublestar.ValidatePattern(p) {
+			return fmt.Errorf("invalid pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// match reports whether rel, a slash-separated path relative to a root,
+// matches one of patterns
+func match(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		target := rel
+		if !strings.Contains(p, "/") {
+			target = path.Base(rel)
+		}
+		if ok, _ := doublestar.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// archiver writes the files below roots to a snapshot
+type archiver struct {
+	tw     *tar.Writer // nil to only count what would be stored
+	rules  rules
+	walker *walk.Walker
+	skip   func(path string) bool // whether a path is a snapshot being written or kept
+	bar    *progress.Bar
+
+	entries int
+	failed  bool // a file could not be read
+}
+
+// add stores root and, if it is a directory, what is below it that the
+// rules select
+func (a *archiver) add(ctx context.Context, root string) error {
+	return a.walker.Walk(ctx, root, func(p string, entry fs.DirEntry, depth int) error {
+		rel := filepath.ToSlash(filepath.Base(root))
+		if depth > 0 {
+			r, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(r)
+		}
+		if a.skip(p) {
+			return nil
+		}
+		if match(a.rules.exclude, rel) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			// With --include, directories are only made again to hold
+			// the files restored into them
+			if len(a.rules.include) > 0 {
+				return nil
+			}
+		} else if len(a.rules.include) > 0 && !match(a.rules.include, rel) {
+			return nil
+		}
+
+		if err := a.write(ctx, p, entry); err != nil {
+			var fatal *fatalError
+			if interrupt.Interrupted(err) || ctx.Err() != nil || errors.As(err, &fatal) {
+				return err
+			}
+			logging.PathError("Cannot back up", p, err)
+			a.failed = true
+		}
+		return nil
+	})
+}
+
+// fatalError is an error that leaves the snapshot unusable, rather than
+// one file missing from it
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// write stores the entry at p
+func (a *archiver) write(ctx context.Context, p string, entry fs.DirEntry) error {
+	name := entryName(p)
+	if name == "." {
+		return nil
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+
+	var target string
+	var file *os.File
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		name += "/"
+	case mode&fs.ModeSymlink != 0:
+		if target, err = os.Readlink(p); err != nil {
+			return err
+		}
+	case mode.IsRegular():
+		// Open the file first, so that one that can't be read is left
+		// out before anything of it is written
+		if file, err = os.Open(p); err != nil {
+			return err
+		}
+		defer file.Close()
+	default:
+		return errors.New("not a regular file, directory or link")
+	}
+	a.entries++
+	if a.tw == nil {
+		return nil
+	}
+
+	hdr, err := tar.FileInfoHeader(info, target)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Format = tar.FormatPAX
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return &fatalError{err}
+	}
+	if file == nil {
+		return nil
+	}
+	a.bar.Describe(p)
+	n, err := io.CopyN(a.tw, interrupt.Reader(ctx, a.bar.Reader(file)), hdr.Size)
+	if err == io.EOF {
+		err = fmt.Errorf("'%s' shrank from %d to %d bytes while it was read", p, hdr.Size, n)
+	}
+	if err != nil {
+		return &fatalError{err}
+	}
+	return nil
+}
+
+// create writes a snapshot of roots to file, which only takes its name
+// once complete. Files that can't be read are reported and left out.
+func create(ctx context.Context, file string, roots []string, a *archiver) error {
+	// Leave out the temporary file the snapshot is written to
+	dir, tmpPrefix := filepath.Dir(file), output.TempPrefix(file)
+	skip := a.skip
+	a.skip = func(p string) bool {
+		return skip(p) || strings.HasPrefix(filepath.Base(p), tmpPrefix) && sameFile(filepath.Dir(p), dir)
+	}
+
+	return output.WriteFile(ctx, file, 0o600, func(w io.Writer) error {
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("cannot write snapshot: %w", err)
+		}
+		a.tw = tar.NewWriter(zw)
+		for _, root := range roots {
+			if err := a.add(ctx, root); err != nil {
+				if ctx.Err() != nil || interrupt.Interrupted(err) {
+					return err
+				}
+				var fatal *fatalError
+				var pathErr *fs.PathError
+				if errors.As(err, &fatal) || !errors.As(err, &pathErr) || pathErr.Path != root {
+					return fmt.Errorf("cannot write snapshot: %w", err)
+				}
+				// A root that doesn't exist is left out like a file below it
+				logging.PathError("Cannot back up", root, pathErr.Err)
+				a.failed = true
+			}
+		}
+		if err := a.tw.Close(); err != nil {
+			return fmt.Errorf("cannot write snapshot: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("cannot write snapshot: %w", err)
+		}
+		return nil
+	})
+}
+
+// sameFile reports whether a and b name the same file, by path
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -2,19 +2,40 @@ package cat
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/follow"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
 )
 
 // Options holds cat configuration
 type Options struct {
 	NumberLines     bool
+	NumberNonblank  bool
+	ShowAll         bool
 	ShowNonPrinting bool
+	ShowEnds        bool
+	ShowTabs        bool
 	SqueezeBlank    bool
+	Range           string
+	Output          string // file to write to, "" for standard output
+	Follow          bool   // keep reading the last file as it grows
+
+	first, last int // the lines --range selects; last is 0 for no limit
 }
 
 // Command returns the cat command
@@ -24,116 +45,251 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "cat [flags] [files...]",
 		Short: "Concatenate and display file contents",
-		Long:  `Concatenate files and print on the standard output. Compatible with common cat flags.`,
-		Args:  cobra.MinimumNArgs(0),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			files := args
+		Long: `Concatenate files and print on the standard output. Compatible with common cat flags.
 
-			// If no files specified, read from stdin
-			if len(files) == 0 {
-				return catReader(os.Stdin, opts, false)
-			}
+A file named - is standard input, read at that point among the other files,
+as in cat header.txt - footer.txt; with no files standard input is read.
+Given more than once, - reads on from where the previous one stopped, so
+from a terminal each ends with its own Ctrl+D.
 
-			// Process each file
-			for _, file := range files {
-				if err := catFile(file, opts); err != nil {
-					eve.Logger.Error("Failed to cat file", file, ":", err)
-				}
-			}
+-A is -v, -E and -T together. --range START:END prints only lines START to
+END of each file, counting from 1; either end may be left out, as in 10:
+for everything from line 10 on.
+
+A file may also be an http:// or https:// URL, whose content is streamed
+as it downloads, as in cat https://example.com/notes.txt. Other schemes,
+such as s3://, are reported as unsupported. Process substitution, as in
+cat <(sort a.txt), works wherever the shell provides it.
 
-			return nil
+-o FILE writes the output to FILE instead, replacing it, which works the
+same in every shell; FILE may not also be one of the inputs.
+
+-f prints the last file and then keeps printing whatever is appended to it
+until interrupted, like tail -f from the first line. When the file is
+truncated it is printed again from the start, and when it is replaced, as
+log rotation does, the new file is followed; line numbers carry on across
+both.`,
+		Args: cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files := glob.Expand(args)
+			if err := opts.prepare(); err != nil {
+				return exitcode.New(2, err)
+			}
+			var err error
+			if opts.Output == "" {
+				err = Concat(cmd.Context(), output.Stdout, files, opts)
+			} else {
+				err = concatToFile(cmd.Context(), opts.Output, files, opts)
+			}
+			if errors.Is(err, ErrUnreadable) {
+				return exitcode.Failed(cmd, true)
+			}
+			// Following only ends with an interrupt, which isn't an error
+			if opts.Follow && interrupt.Interrupted(err) {
+				return nil
+			}
+			return err
 		},
 	}
 
+	cmd.SetFlagErrorFunc(exitcode.Usage)
+
 	cmd.Flags().BoolVarP(&opts.NumberLines, "number", "n", false, "Number all output lines")
-	cmd.Flags().BoolVarP(&opts.ShowNonPrinting, "show-all", "A", false, "Show non-printing characters")
+	cmd.Flags().BoolVarP(&opts.NumberNonblank, "number-nonblank", "b", false, "Number nonempty output lines, overriding -n")
+	cmd.Flags().BoolVarP(&opts.ShowAll, "show-all", "A", false, "Show non-printing characters, line ends and tabs (-vET)")
+	cmd.Flags().BoolVarP(&opts.ShowNonPrinting, "show-nonprinting", "v", false, "Show control characters with ^ and bytes above 127 with M-")
+	cmd.Flags().BoolVarP(&opts.ShowEnds, "show-ends", "E", false, "Print $ at the end of each line")
+	cmd.Flags().BoolVarP(&opts.ShowTabs, "show-tabs", "T", false, "Show tabs as ^I")
 	cmd.Flags().BoolVarP(&opts.SqueezeBlank, "squeeze-blank", "s", false, "Squeeze multiple blank lines")
+	cmd.Flags().StringVar(&opts.Range, "range", "", "Print only lines `START:END` of each file")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Write to `FILE` instead of standard output")
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "Keep printing data appended to the last file")
 
 	return cmd
 }
 
-// catFile reads and displays a file
-func catFile(filename string, opts *Options) error {
-	file, err := os.Open(filename)
+// prepare checks the options and fills in what -A and --range imply
+func (opts *Options) prepare() error {
+	if opts.ShowAll {
+		opts.ShowNonPrinting, opts.ShowEnds, opts.ShowTabs = true, true, true
+	}
+	if opts.Range != "" {
+		first, last, err := parseRange(opts.Range)
+		if err != nil {
+			return err
+		}
+		opts.first, opts.last = first, last
+	}
+	return nil
+}
+
+// ErrUnreadable is returned by Concat once all files are written when some
+// of them could not be read. Each of those has been reported already.
+var ErrUnreadable = errors.New("some files could not be read")
+
+// Concat writes files to w one after the other, as the cat command does,
+// reading standard input if there are none. Files that can't be read are
+// reported and skipped, and Concat then returns ErrUnreadable; opts.Output
+// is ignored.
+func Concat(ctx context.Context, w io.Writer, files []string, opts *Options) error {
+	if err := opts.prepare(); err != nil {
+		return err
+	}
+
+	// If no files specified, read from stdin
+	if len(files) == 0 {
+		return catReader(ctx, w, os.Stdin, opts, false)
+	}
+
+	// Process each file
+	failed := false
+	for i, file := range files {
+		following := opts.Follow && i == len(files)-1 && !input.IsStdin(file) && !input.IsRemote(file)
+		if err := catFile(ctx, w, file, opts, following); err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			var werr *writeError
+			if errors.As(err, &werr) {
+				return werr.err
+			}
+			logging.PathError("Failed to cat file", file, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return ErrUnreadable
+	}
+	return nil
+}
+
+// concatToFile writes files to the file name, replacing it
+func concatToFile(ctx context.Context, name string, files []string, opts *Options) error {
+	// Creating the output truncates it, so it mustn't be one of the inputs
+	if outInfo, err := os.Stat(name); err == nil {
+		for _, file := range files {
+			if input.IsStdin(file) {
+				continue
+			}
+			if info, err := os.Stat(file); err == nil && os.SameFile(info, outInfo) {
+				return exitcode.New(2, fmt.Errorf("input file '%s' is the output file", file))
+			}
+		}
+	}
+
+	out, err := os.Create(name)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return fmt.Errorf("failed to create output: %w", err)
 	}
-	defer file.Close()
+	defer out.Close()
 
-	return catReader(file, opts, true)
+	w := bufio.NewWriterSize(out, 64*1024)
+	concatErr := Concat(ctx, w, files, opts)
+	if concatErr != nil && !errors.Is(concatErr, ErrUnreadable) {
+		return concatErr
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return concatErr
 }
 
-// catReader reads and displays content from a reader
-func catReader(file *os.File, opts *Options, showFilename bool) error {
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-	lastLineBlank := false
+// writeError is a failure to write the output, which ends cat rather than
+// skipping to the next file
+type writeError struct {
+	err error
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		isBlank := strings.TrimSpace(line) == ""
+func (e *writeError) Error() string {
+	return e.err.Error()
+}
 
-		// Handle squeeze blank option
-		if opts.SqueezeBlank && isBlank && lastLineBlank {
-			continue
+// catFile reads and displays a file, and with following what is appended
+// to it after that
+func catFile(ctx context.Context, w io.Writer, filename string, opts *Options, following bool) error {
+	var file io.ReadCloser
+	if following {
+		f, err := follow.Open(ctx, filename)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
 		}
-		lastLineBlank = isBlank
+		// Show what has been printed before waiting for more
+		if fw, ok := w.(interface{ Flush() error }); ok {
+			f.Idle = func() { fw.Flush() }
+		}
+		file = f
+	} else {
+		f, err := input.OpenContext(ctx, filename)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		file = f
+	}
+	defer file.Close()
 
-		lineNum++
+	return catReader(ctx, w, file, opts, true)
+}
 
-		// Build output line
-		output := ""
+// catReader reads content from a reader and writes it to w
+func catReader(ctx context.Context, w io.Writer, file io.Reader, opts *Options, showFilename bool) error {
+	reader := lines.NewReader(interrupt.Reader(ctx, file))
+	pipeline := opts.Pipeline()
+	inputLine := 0
+	var text []byte
 
-		// Add line numbers if requested
-		if opts.NumberLines {
-			output = fmt.Sprintf("%6d  ", lineNum)
+	for reader.Scan() {
+		inputLine++
+		if opts.last > 0 && inputLine > opts.last {
+			break
 		}
 
-		// Process line content
-		if opts.ShowNonPrinting {
-			output += showNonPrintingChars(line)
-		} else {
-			output += line
+		line := Line{Text: reader.Bytes(), Terminated: reader.Terminated(), Number: inputLine}
+		if !pipeline.Apply(&line) {
+			continue
 		}
 
-		fmt.Println(output)
+		// Reproduce a missing final newline exactly
+		text = append(text[:0], line.Text...)
+		if line.Terminated {
+			text = append(text, '\n')
+		}
+		if _, err := w.Write(text); err != nil {
+			return &writeError{fmt.Errorf("failed to write output: %w", err)}
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := reader.Err(); err != nil {
 		return fmt.Errorf("error reading file: %w", err)
 	}
 
 	return nil
 }
 
-// showNonPrintingChars replaces non-printing characters with visible representations
-func showNonPrintingChars(line string) string {
-	var result strings.Builder
-
-	for _, r := range line {
-		switch r {
-		case '\t':
-			result.WriteString("^I")
-		case '\r':
-			result.WriteString("^M")
-		case '\n':
-			result.WriteString("$\n")
-		default:
-			if r < 32 || r == 127 {
-				// Control characters
-				if r == 127 {
-					result.WriteString("^?")
-				} else {
-					result.WriteString(fmt.Sprintf("^%c", r+64))
-				}
-			} else if r > 127 {
-				// Non-ASCII
-				result.WriteString(fmt.Sprintf("M-%c", r-128))
-			} else {
-				result.WriteRune(r)
-			}
-		}
+// parseRange parses START:END, where either end may be empty
+func parseRange(s string) (int, int, error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q: expected START:END", s)
 	}
 
-	return result.String()
+	first, last := 1, 0
+	if from != "" {
+		n, err := strconv.Atoi(from)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid range start %q", from)
+		}
+		first = n
+	}
+	if to != "" {
+		n, err := strconv.Atoi(to)
+		if err != nil || n < first {
+			return 0, 0, fmt.Errorf("invalid range end %q", to)
+		}
+		last = n
+	}
+	return first, last, nil
 }
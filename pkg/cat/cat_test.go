@@ -0,0 +1,212 @@
+package cat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+)
+
+// runCat runs catReader over content and returns what it printed
+func runCat(t *testing.T, content string, opts *Options) string {
+	var out strings.Builder
+	require.NoError(t, catReader(context.Background(), &out, strings.NewReader(content), opts, false))
+	return out.String()
+}
+
+// TestCatReader tests the display and numbering options
+func TestCatReader(t *testing.T) {
+	content := "a\tb\r\n\n\nc\x01\xc3\xa9\x7f\nlast"
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"plain", Options{}, content},
+		{"show all", Options{ShowNonPrinting: true, ShowEnds: true, ShowTabs: true}, "a^Ib^M$\n$\n$\nc^AM-CM-)^?$\nlast"},
+		{"show ends", Options{ShowEnds: true}, "a\tb\r$\n$\n$\nc\x01é\x7f$\nlast"},
+		{"show tabs", Options{ShowTabs: true}, "a^Ib\r\n\n\nc\x01é\x7f\nlast"},
+		{"number nonblank", Options{NumberNonblank: true, NumberLines: true}, "     1  a\tb\r\n\n\n     2  c\x01é\x7f\n     3  last"},
+		{"squeeze", Options{SqueezeBlank: true, NumberLines: true}, "     1  a\tb\r\n     2  \n     3  c\x01é\x7f\n     4  last"},
+		{"range", Options{first: 2, last: 4, NumberLines: true}, "     2  \n     3  \n     4  c\x01é\x7f\n"},
+		{"open range", Options{first: 4}, "c\x01é\x7f\nlast"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, runCat(t, content, &tt.opts))
+		})
+	}
+}
+
+// TestParseRange tests parsing --range
+func TestParseRange(t *testing.T) {
+	for s, want := range map[string][2]int{"3:7": {3, 7}, "5:": {5, 0}, ":2": {1, 2}, ":": {1, 0}, "4:4": {4, 4}} {
+		first, last, err := parseRange(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, [2]int{first, last}, s)
+	}
+
+	for _, s := range []string{"3", "0:2", "5:2", "a:b", "-1:"} {
+		_, _, err := parseRange(s)
+		assert.Error(t, err, s)
+	}
+}
+
+// TestConcatToFile tests writing the output to a file, and that the output
+// file can't be one of the inputs
+func TestConcatToFile(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	require.NoError(t, os.WriteFile(a, []byte("one\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("two\n"), 0644))
+
+	out := filepath.Join(dir, "out.txt")
+	require.NoError(t, os.WriteFile(out, []byte("old contents\n"), 0644))
+	require.NoError(t, concatToFile(context.Background(), out, []string{a, b}, &Options{NumberLines: true}))
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "     1  one\n     1  two\n", string(got))
+
+	err = concatToFile(context.Background(), a, []string{b, a}, &Options{})
+	assert.ErrorContains(t, err, "is the output file")
+	got, err = os.ReadFile(a)
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", string(got))
+}
+
+// TestConcat_URL tests reading a URL among the files
+func TestConcat_URL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "remote\n")
+	}))
+	defer server.Close()
+
+	local := filepath.Join(t.TempDir(), "local.txt")
+	require.NoError(t, os.WriteFile(local, []byte("local\n"), 0644))
+
+	var out strings.Builder
+	require.NoError(t, Concat(context.Background(), &out, []string{local, server.URL + "/notes.txt?v=1"}, &Options{NumberLines: true}))
+	assert.Equal(t, "     1  local\n     1  remote\n", out.String())
+}
+
+// TestConcat_Follow tests printing what is appended to the last file,
+// numbered on from what was there before
+func TestConcat_Follow(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(name, []byte("a\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Concat(ctx, w, []string{name}, &Options{NumberLines: true, Follow: true})
+	}()
+
+	printed := bufio.NewReader(r)
+	line, err := printed.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "     1  a\n", line)
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("b\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	line, err = printed.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "     2  b\n", line)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+// TestConcat_Stdin tests reading standard input between files
+func TestConcat_Stdin(t *testing.T) {
+	dir := t.TempDir()
+	header := filepath.Join(dir, "header.txt")
+	footer := filepath.Join(dir, "footer.txt")
+	body := filepath.Join(dir, "body.txt")
+	require.NoError(t, os.WriteFile(header, []byte("<html>\n"), 0644))
+	require.NoError(t, os.WriteFile(footer, []byte("</html>\n"), 0644))
+	require.NoError(t, os.WriteFile(body, []byte("body\n"), 0644))
+
+	in, err := os.Open(body)
+	require.NoError(t, err)
+	defer in.Close()
+	stdin := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = stdin }()
+
+	var out strings.Builder
+	require.NoError(t, Concat(context.Background(), &out, []string{header, "-", footer, "-"}, &Options{}))
+	assert.Equal(t, "<html>\nbody\n</html>\n", out.String())
+}
+
+// TestPrintLines tests printing a range of lines, with ranges in and
+// across read blocks and an unterminated last line
+func TestPrintLines(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 50000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	content := b.String() + "end"
+
+	run := func(spec string, number bool) string {
+		first, last, err := parseLineRange(spec)
+		require.NoError(t, err, spec)
+		var out strings.Builder
+		require.NoError(t, printLines(context.Background(), &out, strings.NewReader(content), first, last, number))
+		return out.String()
+	}
+
+	assert.Equal(t, "line 2\nline 3\n", run("2:3", false))
+	assert.Equal(t, "line 42000\n", run("42000", false))
+	assert.Equal(t, " 49999\tline 49999\n 50000\tline 50000\n 50001\tend", run("49999:", true))
+	assert.Equal(t, "line 1\n", run(":1", false))
+	assert.Equal(t, "end", run("50001:60000", false))
+	assert.Empty(t, run("60000:", false))
+
+	for _, spec := range []string{"0", "x", "5:2", "-3"} {
+		_, _, err := parseLineRange(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestExpandOne tests that lines expands a wildcard FILE that names one
+// file, and rejects one naming several or none
+func TestExpandOne(t *testing.T) {
+	old := glob.Force
+	defer func() { glob.Force = old }()
+	glob.Force = true
+
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "a.txt", "b.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	name, err := expandOne(filepath.Join(dir, "*.go"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "main.go"), name)
+
+	_, err = expandOne(filepath.Join(dir, "*.txt"))
+	assert.ErrorContains(t, err, "matches 2 files")
+
+	name, err = expandOne(filepath.Join(dir, "*.md"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "*.md"), name, "no match is passed through")
+}
@@ -0,0 +1,162 @@
+package cat
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Line is a line of text on its way through a Pipeline
+type Line struct {
+	Text       []byte // the line without its terminator
+	Terminated bool   // whether the line ended in a newline in the input
+	Number     int    // position in the input, counting from 1
+}
+
+// A LineFilter transforms a line in place, replacing its Text, or returns
+// false to drop it. Filters may keep state from line to line, such as a
+// count, so a filter belongs to a single input; Text set by a filter is
+// only valid until the filter is called again.
+type LineFilter func(l *Line) bool
+
+// Pipeline applies its filters in order
+type Pipeline []LineFilter
+
+// Apply passes l through the filters, stopping at the first that drops it,
+// and reports whether the line is kept
+func (p Pipeline) Apply(l *Line) bool {
+	for _, filter := range p {
+		if !filter(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pipeline returns the filters cat applies for opts: squeezing, then
+// numbering, then --range, then making characters visible and marking
+// line ends. Lines are numbered before --range selects from them, so the
+// numbers are those of the whole input.
+func (opts *Options) Pipeline() Pipeline {
+	var p Pipeline
+	if opts.SqueezeBlank {
+		p = append(p, Squeeze())
+	}
+	if opts.NumberNonblank {
+		p = append(p, Number(true))
+	} else if opts.NumberLines {
+		p = append(p, Number(false))
+	}
+	if opts.first > 1 || opts.last > 0 {
+		p = append(p, Range(opts.first, opts.last))
+	}
+	if opts.ShowNonPrinting || opts.ShowTabs {
+		p = append(p, Visible(opts.ShowNonPrinting, opts.ShowTabs))
+	}
+	if opts.ShowEnds {
+		p = append(p, ShowEnds())
+	}
+	return p
+}
+
+// Squeeze drops blank lines that follow a blank line, counting lines of
+// only white space as blank
+func Squeeze() LineFilter {
+	lastBlank := false
+	return func(l *Line) bool {
+		blank := len(bytes.TrimSpace(l.Text)) == 0
+		if blank && lastBlank {
+			return false
+		}
+		lastBlank = blank
+		return true
+	}
+}
+
+// Number puts a line number, right-aligned in six columns, and two spaces
+// before each line, or with nonblank only before lines that aren't empty
+func Number(nonblank bool) LineFilter {
+	n := 0
+	var buf []byte
+	var scratch [20]byte
+	return func(l *Line) bool {
+		if nonblank && len(l.Text) == 0 {
+			return true
+		}
+		n++
+		buf = buf[:0]
+		digits := strconv.AppendInt(scratch[:0], int64(n), 10)
+		for range 6 - len(digits) {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, digits...)
+		buf = append(buf, ' ', ' ')
+		buf = append(buf, l.Text...)
+		l.Text = buf
+		return true
+	}
+}
+
+// Range keeps only the lines numbered first to last in the input; a last
+// of 0 means no limit
+func Range(first, last int) LineFilter {
+	return func(l *Line) bool {
+		return l.Number >= first && (last == 0 || l.Number <= last)
+	}
+}
+
+// Visible shows control characters in ^ notation and bytes above 127 as M-
+// followed by the notation of the byte without its high bit, as GNU cat -v
+// does, and with tabs set shows tabs as ^I
+func Visible(nonprinting, tabs bool) LineFilter {
+	var buf []byte
+	return func(l *Line) bool {
+		buf = appendVisible(buf[:0], l.Text, nonprinting, tabs)
+		l.Text = buf
+		return true
+	}
+}
+
+// ShowEnds marks the end of each terminated line with $
+func ShowEnds() LineFilter {
+	var buf []byte
+	return func(l *Line) bool {
+		if l.Terminated {
+			buf = append(append(buf[:0], l.Text...), '$')
+			l.Text = buf
+		}
+		return true
+	}
+}
+
+// appendVisible appends line to dst with tabs shown as ^I if tabs is set
+// and, if nonprinting is, control characters and bytes above 127 escaped
+func appendVisible(dst, line []byte, nonprinting, tabs bool) []byte {
+	for _, c := range line {
+		if c == '\t' {
+			if tabs {
+				dst = append(dst, '^', 'I')
+			} else {
+				dst = append(dst, c)
+			}
+			continue
+		}
+		if !nonprinting {
+			dst = append(dst, c)
+			continue
+		}
+
+		if c >= 128 {
+			dst = append(dst, 'M', '-')
+			c -= 128
+		}
+		switch {
+		case c < 32:
+			dst = append(dst, '^', c+64)
+		case c == 127:
+			dst = append(dst, '^', '?')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,63 @@
+package cat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// apply runs the lines of text through p and returns what is kept, with
+// the lines that were terminated ending in a newline again
+func apply(p Pipeline, text string) string {
+	var out strings.Builder
+	parts := strings.SplitAfter(text, "\n")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		l := Line{Text: []byte(strings.TrimSuffix(part, "\n")), Terminated: strings.HasSuffix(part, "\n"), Number: i + 1}
+		if p.Apply(&l) {
+			out.Write(l.Text)
+			if l.Terminated {
+				out.WriteByte('\n')
+			}
+		}
+	}
+	return out.String()
+}
+
+// TestFilters tests each filter on its own
+func TestFilters(t *testing.T) {
+	text := "a\n\n \n\nb\tc\x7f\nend"
+
+	tests := []struct {
+		name   string
+		filter LineFilter
+		want   string
+	}{
+		{"squeeze", Squeeze(), "a\n\nb\tc\x7f\nend"},
+		{"number", Number(false), "     1  a\n     2  \n     3   \n     4  \n     5  b\tc\x7f\n     6  end"},
+		{"number nonblank", Number(true), "     1  a\n\n     2   \n\n     3  b\tc\x7f\n     4  end"},
+		{"range", Range(2, 3), "\n \n"},
+		{"range open", Range(5, 0), "b\tc\x7f\nend"},
+		{"visible", Visible(true, false), "a\n\n \n\nb\tc^?\nend"},
+		{"tabs", Visible(false, true), "a\n\n \n\nb^Ic\x7f\nend"},
+		{"ends", ShowEnds(), "a$\n$\n $\n$\nb\tc\x7f$\nend"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, apply(Pipeline{tt.filter}, text))
+		})
+	}
+}
+
+// TestOptions_Pipeline tests that filters combine in cat's order: lines
+// are numbered after squeezing and before a range selects from them
+func TestOptions_Pipeline(t *testing.T) {
+	opts := &Options{SqueezeBlank: true, NumberLines: true, ShowEnds: true, first: 3, last: 6}
+	assert.Equal(t, "     3  b$\n     4  c$\n", apply(opts.Pipeline(), "a\n\n\n\nb\nc\n"))
+
+	assert.Empty(t, (&Options{}).Pipeline())
+}
@@ -0,0 +1,142 @@
+package cat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// LinesCommand returns the lines command, which prints a range of lines of
+// one file
+func LinesCommand() *cobra.Command {
+	number := false
+
+	cmd := &cobra.Command{
+		Use:   "lines [flags] FILE START[:END]",
+		Short: "Print a range of lines of a file",
+		Long: `Print lines START to END of FILE, counting from 1, or only line START
+when there is no END. Either end of START:END may be left out: 100: prints
+from line 100 to the end and :20 the first 20 lines. FILE may be - for
+standard input.
+
+  claude-tools lines main.go 100:120
+
+The lines before START are skipped with a fast scan for line ends and
+reading stops after END, so the time taken depends on where the range is
+rather than on the size of the file. Lines are printed exactly as they are.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			first, last, err := parseLineRange(args[1])
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+
+			name, err := expandOne(args[0])
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+			file, err := input.OpenContext(cmd.Context(), name)
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer file.Close()
+
+			return printLines(cmd.Context(), output.Stdout, file, first, last, number)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&number, "number", "n", false, "Print the line number before each line")
+
+	return cmd
+}
+
+// expandOne expands a wildcard operand as glob.Expand does, failing
+// unless it names exactly one file
+func expandOne(arg string) (string, error) {
+	names := glob.Expand([]string{arg})
+	if len(names) != 1 {
+		return "", fmt.Errorf("'%s' matches %d files, expected one", arg, len(names))
+	}
+	return names[0], nil
+}
+
+// parseLineRange parses START[:END], where a single number stands for that
+// line alone
+func parseLineRange(s string) (int, int, error) {
+	if !strings.Contains(s, ":") {
+		s += ":" + s
+	}
+	return parseRange(s)
+}
+
+// printLines writes lines first to last of r to w, or to the end if last
+// is 0, optionally numbered. Lines before first are only scanned for
+// their ends, and reading stops after last.
+func printLines(ctx context.Context, w io.Writer, r io.Reader, first, last int, number bool) error {
+	r = interrupt.Reader(ctx, r)
+	buf := make([]byte, 64*1024)
+	line := 1
+	lineStart := true // the next byte starts a line
+
+	for {
+		n, err := r.Read(buf)
+		chunk := buf[:n]
+
+		// Skip whole chunks that end before first
+		if line < first {
+			if count := bytes.Count(chunk, []byte{'\n'}); line+count < first {
+				line += count
+				chunk = nil
+			}
+		}
+
+		for len(chunk) > 0 {
+			end := bytes.IndexByte(chunk, '\n')
+			if line < first {
+				if end < 0 {
+					break
+				}
+				chunk = chunk[end+1:]
+				line++
+				continue
+			}
+
+			part := chunk
+			if end >= 0 {
+				part = chunk[:end+1]
+			}
+			chunk = chunk[len(part):]
+			if number && lineStart {
+				fmt.Fprintf(w, "%6d\t", line)
+			}
+			if _, err := w.Write(part); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+
+			lineStart = end >= 0
+			if lineStart {
+				if line == last {
+					return nil
+				}
+				line++
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading file: %w", err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package color
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+// Supported values of the --color flag
+const (
+	Never  = "never"
+	Auto   = "auto"
+	Always = "always"
+)
+
+// Mode controls coloring for every command. Set from the global --color flag.
+var Mode = Auto
+
+// SGR parameters shared by the commands, following the GNU coreutils and
+// grep defaults so output looks familiar
+const (
+	Match     = "01;31"
+	Filename  = "35"
+	LineNum   = "32"
+	Separator = "36"
+
+	Dir        = "01;34"
+	Symlink    = "01;36"
+	Executable = "01;32"
+	Pipe       = "40;33"
+	Socket     = "01;35"
+	Device     = "40;33;01"
+
+	Changed = "01;33"
+	Added   = "32"
+	Removed = "31"
+
+	JSONNull   = "1;30"
+	JSONScalar = "0;39"
+	JSONString = "0;32"
+	JSONDelim  = "1;39"
+	JSONKey    = "34;1"
+)
+
+const reset = "\x1b[m"
+
+// Validate checks that mode is one of never, auto or always
+func Validate(mode string) error {
+	switch mode {
+	case Never, Auto, Always:
+		return nil
+	default:
+		return fmt.Errorf("invalid --color value %q (want never, always or auto)", mode)
+	}
+}
+
+// Enabled reports whether output written to f should be colored under the
+// global Mode
+func Enabled(f *os.File) bool {
+	enabled, _ := Resolve(Mode, f)
+	return enabled
+}
+
+// Resolve decides whether to color output written to f.
+//
+// In auto mode a non-zero CLICOLOR_FORCE turns color on, while NO_COLOR or
+// TERM=dumb turn it off; otherwise f must be a terminal. On Windows the
+// console is switched into VT mode so escape sequences are interpreted.
+func Resolve(mode string, f *os.File) (bool, error) {
+	if err := Validate(mode); err != nil {
+		return false, err
+	}
+
+	switch mode {
+	case Never:
+		return false, nil
+	case Always:
+		enableVT(f)
+		return true, nil
+	}
+
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		enableVT(f)
+		return true, nil
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false, nil
+	}
+	if !isTerminal(f) {
+		return false, nil
+	}
+	return enableVT(f), nil
+}
+
+// Terminal reports whether f is a terminal that interprets escape
+// sequences, such as those that redraw a progress line. TERM=dumb
+// terminals don't count; a Windows console is switched into VT mode.
+func Terminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" || !isTerminal(f) {
+		return false
+	}
+	return enableVT(f)
+}
+
+// Interactive reports whether f is a terminal of any kind, including ones
+// that don't interpret escape sequences
+func Interactive(f *os.File) bool {
+	return isTerminal(f)
+}
+
+// Width returns the number of columns to lay output out in: COLUMNS if it
+// is set to a number, else the width of the terminal f is, else 0
+func Width(f *os.File) int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if f == nil || !isTerminal(f) {
+		return 0
+	}
+	return terminalWidth(f)
+}
+
+// Paint wraps s in the SGR sequence when enabled is set
+func Paint(enabled bool, s, sgr string) string {
+	if !enabled || s == "" || sgr == "" {
+		return s
+	}
+	return "\x1b[" + sgr + "m" + s + reset
+}
+
+// ForMode returns the SGR parameters used to show a file of the given mode,
+// or "" for regular files
+func ForMode(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return Symlink
+	case mode.IsDir():
+		return Dir
+	case mode&fs.ModeNamedPipe != 0:
+		return Pipe
+	case mode&fs.ModeSocket != 0:
+		return Socket
+	case mode&fs.ModeDevice != 0:
+		return Device
+	case mode&0111 != 0:
+		return Executable
+	default:
+		return ""
+	}
+}
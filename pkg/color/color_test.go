@@ -0,0 +1,122 @@
+package color
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// regularFile returns an open regular file, which is never a terminal
+func regularFile(t *testing.T) *os.File {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// TestResolve_Modes tests the explicit never/always modes and validation
+func TestResolve_Modes(t *testing.T) {
+	f := regularFile(t)
+
+	enabled, err := Resolve(Never, f)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	enabled, err = Resolve(Always, f)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	_, err = Resolve("sometimes", f)
+	assert.Error(t, err)
+}
+
+// TestResolve_AutoEnvironment tests NO_COLOR and CLICOLOR_FORCE in auto mode
+func TestResolve_AutoEnvironment(t *testing.T) {
+	f := regularFile(t)
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+	enabled, err := Resolve(Auto, f)
+	require.NoError(t, err)
+	assert.False(t, enabled, "a regular file is not a terminal")
+
+	t.Setenv("CLICOLOR_FORCE", "1")
+	enabled, _ = Resolve(Auto, f)
+	assert.True(t, enabled)
+
+	t.Setenv("CLICOLOR_FORCE", "0")
+	t.Setenv("NO_COLOR", "1")
+	enabled, _ = Resolve(Auto, f)
+	assert.False(t, enabled)
+
+	// An explicit --color=always wins over NO_COLOR
+	enabled, _ = Resolve(Always, f)
+	assert.True(t, enabled)
+}
+
+// TestPaint tests wrapping text in SGR sequences
+func TestPaint(t *testing.T) {
+	assert.Equal(t, "\x1b[01;31mhit\x1b[m", Paint(true, "hit", Match))
+	assert.Equal(t, "hit", Paint(false, "hit", Match))
+	assert.Equal(t, "hit", Paint(true, "hit", ""))
+	assert.Equal(t, "", Paint(true, "", Match))
+}
+
+// TestForMode tests the file type color mapping
+func TestForMode(t *testing.T) {
+	assert.Equal(t, Dir, ForMode(fs.ModeDir|0755))
+	assert.Equal(t, Symlink, ForMode(fs.ModeSymlink|0777))
+	assert.Equal(t, Executable, ForMode(0755))
+	assert.Equal(t, Pipe, ForMode(fs.ModeNamedPipe|0644))
+	assert.Equal(t, "", ForMode(0644))
+}
+
+// TestScheme_For tests classifying files by type and suffix, with and
+// without LS_COLORS entries
+func TestScheme_For(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits are not supported on Windows")
+	}
+	dir := t.TempDir()
+	write := func(name string, mode fs.FileMode) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, nil, mode))
+		require.NoError(t, os.Chmod(path, mode))
+		return path
+	}
+	info := func(path string) fs.FileInfo {
+		fi, err := os.Lstat(path)
+		require.NoError(t, err)
+		return fi
+	}
+
+	archive := write("backup.TAR.gz", 0644)
+	script := write("run.sh", 0755)
+	plain := write("notes.txt", 0644)
+	readme := write("README", 0644)
+	link := filepath.Join(dir, "link")
+	broken := filepath.Join(dir, "broken")
+	require.NoError(t, os.Symlink(archive, link))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing"), broken))
+
+	defaults := ParseScheme(defaultSuffixes)
+	assert.Equal(t, Dir, defaults.For(dir, info(dir)))
+	assert.Equal(t, "01;31", defaults.For(archive, info(archive)))
+	assert.Equal(t, Executable, defaults.For(script, info(script)))
+	assert.Equal(t, "", defaults.For(plain, info(plain)))
+	assert.Equal(t, Symlink, defaults.For(link, info(link)))
+	assert.Equal(t, "40;31;01", defaults.For(broken, info(broken)))
+
+	custom := ParseScheme("di=01;33:ln=target:or=:fi=37:*.txt=04:*.gz=35:bogus")
+	assert.Equal(t, "01;33", custom.For(dir, info(dir)))
+	assert.Equal(t, "35", custom.For(link, info(link)))
+	assert.Equal(t, "", custom.For(broken, info(broken)))
+	assert.Equal(t, "04", custom.For(plain, info(plain)))
+	assert.Equal(t, "37", custom.For(readme, info(readme)))
+	assert.Equal(t, Executable, custom.For(script, info(script)))
+}
@@ -0,0 +1,150 @@
+package color
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Scheme holds the colors of file names by type and by name suffix, in
+// the form of the LS_COLORS variable used by GNU ls and dircolors
+type Scheme struct {
+	types    map[string]string // two-letter keys such as di, ln and ex
+	suffixes []suffixColor     // *.ext entries, later ones taking precedence
+}
+
+type suffixColor struct {
+	suffix string // lower case
+	sgr    string
+}
+
+// defaultTypes are the type colors of dircolors' default database
+var defaultTypes = map[string]string{
+	"di": Dir,
+	"ln": Symlink,
+	"pi": Pipe,
+	"so": Socket,
+	"bd": Device,
+	"cd": Device,
+	"or": "40;31;01",
+	"su": "37;41",
+	"sg": "30;43",
+	"tw": "30;42",
+	"ow": "34;42",
+	"st": "37;44",
+	"ex": Executable,
+}
+
+// defaultSuffixes color common kinds of files when LS_COLORS is not set:
+// archives red, images and video magenta, audio cyan, and the Windows
+// executables, which have no executable bit, green
+const defaultSuffixes = "" +
+	"*.tar=01;31:*.tgz=01;31:*.gz=01;31:*.bz2=01;31:*.xz=01;31:*.zst=01;31:" +
+	"*.zip=01;31:*.7z=01;31:*.rar=01;31:*.jar=01;31:*.deb=01;31:*.rpm=01;31:" +
+	"*.jpg=01;35:*.jpeg=01;35:*.png=01;35:*.gif=01;35:*.bmp=01;35:*.svg=01;35:" +
+	"*.webp=01;35:*.ico=01;35:*.tif=01;35:*.tiff=01;35:" +
+	"*.mp4=01;35:*.mkv=01;35:*.avi=01;35:*.mov=01;35:*.webm=01;35:" +
+	"*.mp3=00;36:*.flac=00;36:*.wav=00;36:*.ogg=00;36:*.m4a=00;36:" +
+	"*.exe=01;32:*.com=01;32:*.bat=01;32:*.cmd=01;32:*.ps1=01;32"
+
+// ParseScheme parses a value of LS_COLORS, such as "di=01;34:*.zip=01;31",
+// on top of the default type colors. Suffix colors replace the default
+// ones entirely, and entries that can't be parsed are skipped.
+func ParseScheme(value string) *Scheme {
+	s := &Scheme{types: map[string]string{}}
+	for k, v := range defaultTypes {
+		s.types[k] = v
+	}
+	for _, entry := range strings.Split(value, ":") {
+		key, sgr, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(key, "*"); ok {
+			s.suffixes = append(s.suffixes, suffixColor{strings.ToLower(suffix), sgr})
+		} else {
+			s.types[key] = sgr
+		}
+	}
+	return s
+}
+
+// LSColors returns the scheme given by LS_COLORS, or the default one when
+// it is unset
+var LSColors = sync.OnceValue(func() *Scheme {
+	if value, ok := os.LookupEnv("LS_COLORS"); ok {
+		return ParseScheme(value)
+	}
+	return ParseScheme(defaultSuffixes)
+})
+
+// For returns the SGR parameters to show the file at path with, whose
+// info is that of the file itself rather than of what a link points to,
+// or "" for none. Types are checked first, then for regular files that
+// aren't executable the name's suffix, ignoring case.
+func (s *Scheme) For(path string, info fs.FileInfo) string {
+	mode := info.Mode()
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		// The target's own name decides its suffix color
+		resolved, err := filepath.EvalSymlinks(path)
+		var target fs.FileInfo
+		if err == nil {
+			target, err = os.Stat(resolved)
+		}
+		if err != nil {
+			if sgr := s.types["or"]; sgr != "" || s.types["ln"] == "target" {
+				return sgr
+			}
+			return s.types["ln"]
+		}
+		if s.types["ln"] == "target" {
+			return s.For(resolved, target)
+		}
+		return s.types["ln"]
+	case mode.IsDir():
+		switch {
+		case mode&fs.ModeSticky != 0 && mode&0002 != 0:
+			return s.pick("tw", "di")
+		case mode&0002 != 0:
+			return s.pick("ow", "di")
+		case mode&fs.ModeSticky != 0:
+			return s.pick("st", "di")
+		}
+		return s.types["di"]
+	case mode&fs.ModeNamedPipe != 0:
+		return s.types["pi"]
+	case mode&fs.ModeSocket != 0:
+		return s.types["so"]
+	case mode&fs.ModeCharDevice != 0:
+		return s.types["cd"]
+	case mode&fs.ModeDevice != 0:
+		return s.types["bd"]
+	case mode&fs.ModeSetuid != 0:
+		return s.pick("su", "ex")
+	case mode&fs.ModeSetgid != 0:
+		return s.pick("sg", "ex")
+	case mode&0111 != 0:
+		return s.types["ex"]
+	}
+
+	name := strings.ToLower(info.Name())
+	for i := len(s.suffixes) - 1; i >= 0; i-- {
+		if strings.HasSuffix(name, s.suffixes[i].suffix) {
+			return s.suffixes[i].sgr
+		}
+	}
+	return s.types["fi"]
+}
+
+// pick returns the color of the first key that has one
+func (s *Scheme) pick(keys ...string) string {
+	for _, k := range keys {
+		if sgr := s.types[k]; sgr != "" {
+			return sgr
+		}
+	}
+	return ""
+}
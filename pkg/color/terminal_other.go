@@ -0,0 +1,22 @@
+//go:build !windows
+
+package color
+
+import "os"
+
+// isTerminal reports whether f is a character device such as a tty
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// enableVT is a no-op: Unix terminals interpret escape sequences natively
+func enableVT(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package color
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// isTerminal reports whether f is attached to a console. The NUL device is
+// a character device too, so the console mode is queried instead.
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(f.Fd()), &mode) == nil
+}
+
+// enableVT turns on virtual terminal processing for the console behind f.
+// It returns false on consoles too old to support it (before Windows 10).
+func enableVT(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	handle := windows.Handle(f.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
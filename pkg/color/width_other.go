@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package color
+
+import "os"
+
+// terminalWidth is unknown on platforms without terminal size queries
+func terminalWidth(f *os.File) int {
+	return 0
+}
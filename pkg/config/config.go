@@ -0,0 +1,355 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile is the per-project configuration file, looked up from the
+// working directory upwards
+const ProjectFile = ".claude-tools.yaml"
+
+// IgnorePatterns holds the merged ignore patterns of the loaded
+// configuration. Directory-walking commands skip entries whose name matches.
+var IgnorePatterns []string
+
+// Config is the contents of a configuration file.
+//
+//	trust:
+//	  - ~/src
+//	defaults:
+//	  grep: --color=always -n
+//	  ls: [--human-readable]
+//	aliases:
+//	  ll: ls -l -a
+//	ignore:
+//	  - node_modules
+//	  - "*.min.js"
+//	remote:
+//	  timeout: 1m
+//	  headers:
+//	    https://artifacts.example.com/:
+//	      Authorization: Bearer ${ARTIFACTS_TOKEN}
+type Config struct {
+	Trust    []string        `yaml:"trust"` // directories whose project files are trusted; only read from the user file
+	Defaults map[string]Args `yaml:"defaults"`
+	Aliases  map[string]Args `yaml:"aliases"`
+	Ignore   []string        `yaml:"ignore"`
+	Remote   Remote          `yaml:"remote"`
+}
+
+// Remote configures reading file operands that are URLs
+type Remote struct {
+	// Timeout is how long a fetch may go without data; 0 keeps the default
+	Timeout time.Duration `yaml:"timeout"`
+	// Headers are HTTP request headers by the URL prefix they apply to
+	Headers map[string]map[string]string `yaml:"headers"`
+}
+
+// Args is a list of command-line words, written in YAML either as a
+// sequence or as a single string split like a shell would
+type Args []string
+
+// UnmarshalYAML accepts both a string and a sequence of strings
+func (a *Args) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		words, err := SplitWords(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		*a = words
+		return nil
+	case yaml.SequenceNode:
+		var words []string
+		if err := node.Decode(&words); err != nil {
+			return err
+		}
+		*a = words
+		return nil
+	default:
+		return fmt.Errorf("line %d: expected a string or a list of strings", node.Line)
+	}
+}
+
+// UserPath returns the location of the user configuration file:
+// $XDG_CONFIG_HOME/claude-tools/config.yaml, or ~/.config/claude-tools/config.yaml
+func UserPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "claude-tools", "config.yaml"), nil
+}
+
+// ProjectPath finds the nearest ProjectFile in dir or one of its parents.
+// It returns "" if there is none.
+func ProjectPath(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Load reads the user configuration and the project configuration for the
+// working directory and merges them, the project file taking precedence.
+//
+// A project file comes with whatever repository was checked out, so only
+// its ignore patterns are used unless its directory, or one above it, is
+// listed under trust in the user file; its defaults and aliases could
+// otherwise turn a harmless command into a destructive one, and its remote
+// headers could send the user's tokens to any host. Missing files
+// are not an error. A file that can't be read or parsed is left out, and
+// it and the settings of an untrusted project file are reported in the
+// returned errors, while the rest of the configuration still applies.
+func Load() (*Config, []error) {
+	cfg := &Config{}
+	var errs []error
+
+	if path, err := UserPath(); err == nil {
+		if file, err := readFile(path); err != nil {
+			errs = append(errs, err)
+		} else if file != nil {
+			cfg.Merge(file)
+			cfg.Trust = file.Trust
+		}
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		if path := ProjectPath(wd); path != "" {
+			file, err := readFile(path)
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case file != nil && !cfg.trusts(filepath.Dir(path)):
+				if len(file.Defaults) > 0 || len(file.Aliases) > 0 || file.Remote.Timeout != 0 || len(file.Remote.Headers) > 0 {
+					errs = append(errs, fmt.Errorf("ignoring the defaults, aliases and remote settings of '%s': its directory is not listed under trust in the user configuration", path))
+				}
+				cfg.Merge(&Config{Ignore: file.Ignore})
+			case file != nil:
+				file.Trust = nil
+				cfg.Merge(file)
+			}
+		}
+	}
+
+	return cfg, errs
+}
+
+// readFile parses a configuration file. It returns nil if there is none.
+func readFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config '%s': %w", path, err)
+	}
+
+	var file Config
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid config '%s': %w", path, err)
+	}
+	return &file, nil
+}
+
+// trusts reports whether dir is, or lies below, one of the trusted
+// directories. A leading ~ stands for the home directory; relative entries
+// never match.
+func (c *Config) trusts(dir string) bool {
+	home, _ := os.UserHomeDir()
+	for _, entry := range c.Trust {
+		if rest, ok := strings.CutPrefix(entry, "~"); ok && home != "" && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+			entry = home + rest
+		}
+		if !filepath.IsAbs(entry) {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Clean(entry), dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge layers other on top of c. Default flags are appended so that later
+// files override earlier ones, aliases are replaced by name, and ignore
+// patterns are combined. A remote timeout replaces the earlier one, and
+// headers are replaced by URL prefix.
+func (c *Config) Merge(other *Config) {
+	for name, args := range other.Defaults {
+		if c.Defaults == nil {
+			c.Defaults = make(map[string]Args)
+		}
+		c.Defaults[name] = append(c.Defaults[name], args...)
+	}
+	for name, args := range other.Aliases {
+		if c.Aliases == nil {
+			c.Aliases = make(map[string]Args)
+		}
+		c.Aliases[name] = args
+	}
+	c.Ignore = append(c.Ignore, other.Ignore...)
+	if other.Remote.Timeout != 0 {
+		c.Remote.Timeout = other.Remote.Timeout
+	}
+	for prefix, headers := range other.Remote.Headers {
+		if c.Remote.Headers == nil {
+			c.Remote.Headers = make(map[string]map[string]string)
+		}
+		c.Remote.Headers[prefix] = headers
+	}
+}
+
+// Apply rewrites command-line arguments (without the program name):
+// an alias in command position is expanded, and the configured default
+// flags of the resulting command are inserted ahead of the user's
+// arguments so that explicit flags win.
+func (c *Config) Apply(root *cobra.Command, args []string) []string {
+	args = c.expandAlias(root, args)
+
+	cmd, rest, err := root.Find(args)
+	if err != nil || cmd == root {
+		return args
+	}
+
+	path := strings.TrimPrefix(cmd.CommandPath(), root.Name()+" ")
+	defaults := c.Defaults[path]
+	if len(defaults) == 0 {
+		return args
+	}
+
+	out := append(strings.Fields(path), defaults...)
+	return append(out, rest...)
+}
+
+// expandAlias replaces the first non-flag argument with its alias
+// definition. Aliases never shadow built-in commands and are not expanded
+// recursively.
+func (c *Config) expandAlias(root *cobra.Command, args []string) []string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return args
+		}
+		if strings.HasPrefix(arg, "-") {
+			// Skip the separate value of a global flag like --max-line-bytes N
+			if name, ok := strings.CutPrefix(arg, "--"); ok && !strings.Contains(name, "=") {
+				if f := root.PersistentFlags().Lookup(name); f != nil && f.NoOptDefVal == "" {
+					i++
+				}
+			}
+			continue
+		}
+
+		expansion, ok := c.Aliases[arg]
+		if !ok || len(expansion) == 0 {
+			return args
+		}
+		if cmd, _, err := root.Find([]string{arg}); err == nil && cmd != root {
+			return args
+		}
+
+		out := append([]string{}, args[:i]...)
+		out = append(out, expansion...)
+		return append(out, args[i+1:]...)
+	}
+	return args
+}
+
+// Skip reports whether --no-config was given. It has to be checked before
+// cobra parses the arguments, since the configuration rewrites them.
+func Skip(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == "--no-config" || arg == "--no-config=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignored reports whether a file or directory name matches one of the
+// configured ignore patterns
+func Ignored(name string) bool {
+	for _, pattern := range IgnorePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitWords splits s into words on whitespace, honoring single and double
+// quotes and backslash escapes outside single quotes
+func SplitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
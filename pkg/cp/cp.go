@@ -1,13 +1,23 @@
 package cp
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/filecopy"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+	"github.com/evalgo-org/claude-tools/pkg/report"
 )
 
 // Options holds cp configuration
@@ -16,6 +26,13 @@ type Options struct {
 	Preserve  bool
 	Verbose   bool
 	Force     bool
+	Direct    bool
+	Jobs      int
+	Report    string // "json" to print a record of each file copied
+
+	progress *progress.Bar
+	jobs     *jobs
+	report   *report.Writer
 }
 
 // Command returns the cp command
@@ -29,13 +46,28 @@ func Command() *cobra.Command {
 
 If the last argument names an existing directory, cp copies each source
 into that directory. Otherwise, if only two files are given, it copies
-the first onto the second.`,
+the first onto the second.
+
+--report json prints a JSON object per line for each file copied and
+each directory created, with the action ("copy" or "mkdir"), source,
+destination, bytes written, duration in seconds and any error, in place
+of -v output.`,
 		Args: cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			args = glob.Expand(args)
 			sources := args[:len(args)-1]
 			dest := args[len(args)-1]
 
-			return copyFiles(sources, dest, opts)
+			rep, err := report.New(opts.Report)
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+			if rep.Enabled() && input.IsStdin(dest) {
+				return exitcode.New(2, fmt.Errorf("--report cannot be used when copying to standard output"))
+			}
+			opts.report = rep
+
+			return copyFiles(cmd.Context(), sources, dest, opts)
 		},
 	}
 
@@ -43,12 +75,15 @@ the first onto the second.`,
 	cmd.Flags().BoolVarP(&opts.Preserve, "preserve", "p", false, "Preserve file attributes (mode, timestamps)")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Explain what is being done")
 	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Overwrite existing files without prompting")
+	cmd.Flags().BoolVar(&opts.Direct, "direct", false, "Write around the page cache (O_DIRECT) where supported")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 1, "Copy up to `N` files at once")
+	cmd.Flags().StringVar(&opts.Report, "report", "", "Print a record of each file copied in `FORMAT` (json)")
 
 	return cmd
 }
 
 // copyFiles copies source files to destination
-func copyFiles(sources []string, dest string, opts *Options) error {
+func copyFiles(ctx context.Context, sources []string, dest string, opts *Options) (err error) {
 	// Check if destination is a directory
 	destInfo, destErr := os.Stat(dest)
 	isDestDir := destErr == nil && destInfo.IsDir()
@@ -58,10 +93,38 @@ func copyFiles(sources []string, dest string, opts *Options) error {
 		return fmt.Errorf("target '%s' is not a directory", dest)
 	}
 
+	if progress.Shown(opts.Verbose) {
+		opts.progress = progress.New("cp", progress.Bytes, progress.Size(ctx, sources...))
+		defer opts.progress.Finish()
+	}
+
+	if opts.Jobs > 1 {
+		opts.jobs = newJobs(opts.Jobs)
+		defer func() {
+			if werr := opts.jobs.wait(); err == nil {
+				err = werr
+			}
+		}()
+	}
+
 	for _, src := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Standard input can only be copied to a named file
+		if input.IsStdin(src) {
+			if isDestDir {
+				return fmt.Errorf("cannot copy standard input into directory '%s'", dest)
+			}
+			if err := copyFile(ctx, src, dest, opts); err != nil {
+				return err
+			}
+			continue
+		}
+
 		srcInfo, err := os.Stat(src)
 		if err != nil {
-			eve.Logger.Error("Failed to stat", src, ":", err)
 			return err
 		}
 
@@ -76,58 +139,122 @@ func copyFiles(sources []string, dest string, opts *Options) error {
 			if !opts.Recursive {
 				return fmt.Errorf("'%s' is a directory (use -r to copy directories)", src)
 			}
+			if input.IsStdin(dest) {
+				return fmt.Errorf("cannot copy directory '%s' to standard output", src)
+			}
 
-			if err := copyDir(src, targetPath, opts); err != nil {
+			if err := copyDir(ctx, src, targetPath, opts); err != nil {
 				return err
 			}
+			if opts.Verbose && !dryrun.Enabled && !opts.report.Enabled() {
+				fmt.Printf("'%s' -> '%s'\n", src, targetPath)
+			}
 		} else {
-			if err := copyFile(src, targetPath, opts); err != nil {
+			err := opts.jobs.run(func() error {
+				if err := copyFile(ctx, src, targetPath, opts); err != nil {
+					return err
+				}
+				if opts.Verbose && !dryrun.Enabled && !opts.report.Enabled() {
+					fmt.Printf("'%s' -> '%s'\n", src, targetPath)
+				}
+				return nil
+			})
+			if err != nil {
 				return err
 			}
 		}
-
-		if opts.Verbose {
-			fmt.Printf("'%s' -> '%s'\n", src, targetPath)
-		}
 	}
 
 	return nil
 }
 
 // copyFile copies a single file
-func copyFile(src, dest string, opts *Options) error {
+func copyFile(ctx context.Context, src, dest string, opts *Options) (err error) {
+	// "-" as the destination writes to standard output
+	if input.IsStdin(dest) {
+		srcFile, err := input.Open(src)
+		if err != nil {
+			return fmt.Errorf("failed to open source '%s': %w", src, err)
+		}
+		defer srcFile.Close()
+
+		if _, err := io.Copy(os.Stdout, interrupt.Reader(ctx, srcFile)); err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			return fmt.Errorf("failed to copy contents: %w", err)
+		}
+		return nil
+	}
+
+	var copied int64
+	op := opts.report.Start("copy", input.Name(src), dest)
+	defer func() { op.Done(copied, err) }()
+
 	// Check if destination exists
 	if _, err := os.Stat(dest); err == nil && !opts.Force {
 		return fmt.Errorf("'%s' already exists (use -f to overwrite)", dest)
 	}
 
-	// Open source file
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source '%s': %w", src, err)
+	if dryrun.Enabled {
+		dryrun.Report("copy '%s' to '%s'", input.Name(src), dest)
+		if info, err := os.Stat(src); err == nil && info.Mode().IsRegular() {
+			copied = info.Size()
+		}
+		return nil
 	}
-	defer srcFile.Close()
 
-	// Get source file info
-	srcInfo, err := srcFile.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat source: %w", err)
+	// Open source file; standard input gets the default file mode
+	var srcFile *os.File
+	var srcInfo os.FileInfo
+	if input.IsStdin(src) {
+		srcFile = os.Stdin
+	} else {
+		var err error
+		srcFile, err = os.Open(src)
+		if err != nil {
+			return fmt.Errorf("failed to open source '%s': %w", src, err)
+		}
+		defer srcFile.Close()
+
+		// Get source file info
+		srcInfo, err = srcFile.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat source: %w", err)
+		}
+	}
+
+	mode := os.FileMode(0666)
+	if srcInfo != nil {
+		mode = srcInfo.Mode()
 	}
 
 	// Create destination file
-	destFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcInfo.Mode())
+	destFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
 	if err != nil {
 		return fmt.Errorf("failed to create destination '%s': %w", dest, err)
 	}
 	defer destFile.Close()
 
-	// Copy contents
-	if _, err := io.Copy(destFile, srcFile); err != nil {
+	var size int64
+	if srcInfo != nil && srcInfo.Mode().IsRegular() {
+		size = srcInfo.Size()
+	}
+
+	// Copy contents, removing the partial destination if interrupted
+	opts.progress.Describe(input.Name(src))
+	copied, err = filecopy.Copy(destFile, interrupt.Reader(ctx, opts.progress.Reader(srcFile)), size, opts.Direct)
+	if err != nil {
+		destFile.Close()
+		os.Remove(dest)
+		if interrupt.Interrupted(err) {
+			return err
+		}
 		return fmt.Errorf("failed to copy contents: %w", err)
 	}
 
 	// Preserve timestamps if requested
-	if opts.Preserve {
+	if opts.Preserve && srcInfo != nil {
 		if err := os.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
 			return fmt.Errorf("failed to preserve timestamps: %w", err)
 		}
@@ -137,7 +264,7 @@ func copyFile(src, dest string, opts *Options) error {
 }
 
 // copyDir recursively copies a directory
-func copyDir(src, dest string, opts *Options) error {
+func copyDir(ctx context.Context, src, dest string, opts *Options) error {
 	// Get source directory info
 	srcInfo, err := os.Stat(src)
 	if err != nil {
@@ -145,8 +272,17 @@ func copyDir(src, dest string, opts *Options) error {
 	}
 
 	// Create destination directory
-	if err := os.MkdirAll(dest, srcInfo.Mode()); err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+	if _, statErr := os.Stat(dest); statErr != nil {
+		op := opts.report.Start("mkdir", src, dest)
+		if dryrun.Enabled {
+			dryrun.Report("create directory '%s'", dest)
+		} else {
+			err = os.MkdirAll(dest, srcInfo.Mode())
+		}
+		op.Done(0, err)
+		if err != nil {
+			return fmt.Errorf("failed to create destination directory: %w", err)
+		}
 	}
 
 	// Read source directory
@@ -157,26 +293,108 @@ func copyDir(src, dest string, opts *Options) error {
 
 	// Copy each entry
 	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		srcPath := filepath.Join(src, entry.Name())
 		destPath := filepath.Join(dest, entry.Name())
 
 		if entry.IsDir() {
-			if err := copyDir(srcPath, destPath, opts); err != nil {
+			if err := copyDir(ctx, srcPath, destPath, opts); err != nil {
 				return err
 			}
 		} else {
-			if err := copyFile(srcPath, destPath, opts); err != nil {
+			err := opts.jobs.run(func() error {
+				return copyFile(ctx, srcPath, destPath, opts)
+			})
+			if err != nil {
 				return err
 			}
 		}
 	}
 
-	// Preserve directory timestamps if requested
-	if opts.Preserve {
-		if err := os.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
-			return fmt.Errorf("failed to preserve directory timestamps: %w", err)
+	// Preserve directory timestamps if requested, once the files in it
+	// have been created
+	if opts.Preserve && !dryrun.Enabled {
+		return opts.jobs.after(func() error {
+			if err := os.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+				return fmt.Errorf("failed to preserve directory timestamps: %w", err)
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
+
+// jobs runs file copies concurrently, up to a limit. A nil *jobs runs
+// each copy in the caller, one at a time, as cp does by default.
+type jobs struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu    sync.Mutex
+	err   error
+	final []func() error
+}
+
+func newJobs(n int) *jobs {
+	return &jobs{slots: make(chan struct{}, n)}
+}
+
+// run starts fn once a slot is free. It returns the first error of an
+// earlier copy, if any, rather than starting more.
+func (j *jobs) run(fn func() error) error {
+	if j == nil {
+		return fn()
+	}
+	j.slots <- struct{}{}
+	if err := j.failed(); err != nil {
+		<-j.slots
+		return err
+	}
+	j.wg.Go(func() {
+		defer func() { <-j.slots }()
+		if err := fn(); err != nil {
+			j.mu.Lock()
+			if j.err == nil {
+				j.err = err
+			}
+			j.mu.Unlock()
 		}
+	})
+	return nil
+}
+
+// after runs fn once all copies have finished; a directory's timestamps
+// would otherwise change as files are still being created in it
+func (j *jobs) after(fn func() error) error {
+	if j == nil {
+		return fn()
 	}
+	j.mu.Lock()
+	j.final = append(j.final, fn)
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *jobs) failed() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
 
+// wait waits for the running copies and returns the first error
+func (j *jobs) wait() error {
+	j.wg.Wait()
+	if err := j.failed(); err != nil {
+		return err
+	}
+	for _, fn := range j.final {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -1,13 +1,20 @@
 package cp
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/report"
 )
 
 // TestCopyFile_Simple tests basic file copying
@@ -28,7 +35,7 @@ func TestCopyFile_Simple(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyFile(srcFile, destFile, opts)
+	err = copyFile(context.Background(), srcFile, destFile, opts)
 	require.NoError(t, err)
 
 	// Verify content
@@ -59,7 +66,7 @@ func TestCopyFile_PreserveTimestamps(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyFile(srcFile, destFile, opts)
+	err = copyFile(context.Background(), srcFile, destFile, opts)
 	require.NoError(t, err)
 
 	// Verify timestamps
@@ -87,7 +94,7 @@ func TestCopyFile_ExistingFile_WithoutForce(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyFile(srcFile, destFile, opts)
+	err = copyFile(context.Background(), srcFile, destFile, opts)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "already exists")
 }
@@ -112,7 +119,7 @@ func TestCopyFile_ExistingFile_WithForce(t *testing.T) {
 		Force:     true,
 	}
 
-	err = copyFile(srcFile, destFile, opts)
+	err = copyFile(context.Background(), srcFile, destFile, opts)
 	require.NoError(t, err)
 
 	// Verify content was overwritten
@@ -145,7 +152,7 @@ func TestCopyFiles_MultipleToDirectory(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyFiles([]string{src1, src2}, destDir, opts)
+	err = copyFiles(context.Background(), []string{src1, src2}, destDir, opts)
 	require.NoError(t, err)
 
 	// Verify files were copied
@@ -183,7 +190,7 @@ func TestCopyFiles_MultipleToNonDirectory(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyFiles([]string{src1, src2}, destFile, opts)
+	err = copyFiles(context.Background(), []string{src1, src2}, destFile, opts)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not a directory")
 }
@@ -216,7 +223,7 @@ func TestCopyDir_Recursive(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyDir(srcDir, destDir, opts)
+	err = copyDir(context.Background(), srcDir, destDir, opts)
 	require.NoError(t, err)
 
 	// Verify structure was copied
@@ -248,7 +255,7 @@ func TestCopyFiles_DirectoryWithoutRecursive(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyFiles([]string{srcDir}, destDir, opts)
+	err = copyFiles(context.Background(), []string{srcDir}, destDir, opts)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "is a directory")
 	assert.Contains(t, err.Error(), "use -r")
@@ -271,7 +278,7 @@ func TestCopyDir_PreservePermissions(t *testing.T) {
 		Force:     false,
 	}
 
-	err = copyDir(srcDir, destDir, opts)
+	err = copyDir(context.Background(), srcDir, destDir, opts)
 	require.NoError(t, err)
 
 	// Verify permissions
@@ -282,3 +289,132 @@ func TestCopyDir_PreservePermissions(t *testing.T) {
 
 	assert.Equal(t, srcInfo.Mode().Perm(), destInfo.Mode().Perm())
 }
+
+// TestCopyFile_Interrupted tests that a canceled copy leaves no partial file
+func TestCopyFile_Interrupted(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srcFile := filepath.Join(tempDir, "source.txt")
+	destFile := filepath.Join(tempDir, "dest.txt")
+
+	err := os.WriteFile(srcFile, []byte("content"), 0644)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = copyFile(ctx, srcFile, destFile, &Options{})
+	assert.Error(t, err)
+
+	_, err = os.Stat(destFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestCopyFiles_Stdin tests copying standard input to a named file
+func TestCopyFiles_Stdin(t *testing.T) {
+	tempDir := t.TempDir()
+
+	stdinFile := filepath.Join(tempDir, "stdin.txt")
+	require.NoError(t, os.WriteFile(stdinFile, []byte("from stdin"), 0644))
+	in, err := os.Open(stdinFile)
+	require.NoError(t, err)
+	defer in.Close()
+
+	stdin := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = stdin }()
+
+	destFile := filepath.Join(tempDir, "dest.txt")
+	err = copyFiles(context.Background(), []string{"-"}, destFile, &Options{})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(destFile)
+	require.NoError(t, err)
+	assert.Equal(t, "from stdin", string(content))
+
+	// Standard input has no name to use inside a directory
+	err = copyFiles(context.Background(), []string{"-"}, tempDir, &Options{})
+	assert.Error(t, err)
+}
+
+// TestCopyFiles_Jobs tests copying a tree several files at a time,
+// preserving directory timestamps
+func TestCopyFiles_Jobs(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src")
+	modTime := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, dir := range []string{"a", "b", "b/c"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(src, dir), 0755))
+		for i := range 5 {
+			name := filepath.Join(src, dir, fmt.Sprintf("f%d", i))
+			require.NoError(t, os.WriteFile(name, []byte(name), 0644))
+		}
+	}
+	for _, dir := range []string{"b/c", "b", "a", "."} {
+		require.NoError(t, os.Chtimes(filepath.Join(src, dir), modTime, modTime))
+	}
+
+	dest := filepath.Join(tempDir, "dest")
+	opts := &Options{Recursive: true, Preserve: true, Jobs: 4}
+	require.NoError(t, copyFiles(context.Background(), []string{src}, dest, opts))
+
+	for _, dir := range []string{"a", "b", "b/c"} {
+		for i := range 5 {
+			name := filepath.Join(src, dir, fmt.Sprintf("f%d", i))
+			content, err := os.ReadFile(filepath.Join(dest, dir, fmt.Sprintf("f%d", i)))
+			require.NoError(t, err)
+			assert.Equal(t, name, string(content))
+		}
+		info, err := os.Stat(filepath.Join(dest, dir))
+		require.NoError(t, err)
+		assert.Equal(t, modTime.Unix(), info.ModTime().Unix(), dir)
+	}
+}
+
+// TestCopyFiles_JobsError tests that a failed copy is reported when
+// copying several files at a time
+func TestCopyFiles_JobsError(t *testing.T) {
+	tempDir := t.TempDir()
+	var sources []string
+	for i := range 4 {
+		name := filepath.Join(tempDir, fmt.Sprintf("f%d", i))
+		require.NoError(t, os.WriteFile(name, []byte("x"), 0644))
+		sources = append(sources, name)
+	}
+	dest := filepath.Join(tempDir, "dest")
+	require.NoError(t, os.Mkdir(dest, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "f2"), []byte("old"), 0644))
+
+	err := copyFiles(context.Background(), sources, dest, &Options{Jobs: 2})
+	assert.ErrorContains(t, err, "already exists")
+}
+
+// TestCopyFiles_Report tests the --report records of a recursive copy
+func TestCopyFiles_Report(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	require.NoError(t, os.Mkdir(srcDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("hello"), 0644))
+	destDir := filepath.Join(tempDir, "dest")
+
+	var out bytes.Buffer
+	opts := &Options{Recursive: true, report: report.NewTo(&out)}
+	require.NoError(t, copyFiles(context.Background(), []string{srcDir}, destDir, opts))
+
+	var records []report.Record
+	for line := range strings.Lines(out.String()) {
+		var r report.Record
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	require.Len(t, records, 2)
+	assert.Equal(t, report.Record{Action: "mkdir", Source: srcDir, Destination: destDir}, withoutTime(records[0]))
+	assert.Equal(t, report.Record{Action: "copy", Source: filepath.Join(srcDir, "a.txt"), Destination: filepath.Join(destDir, "a.txt"), Bytes: 5}, withoutTime(records[1]))
+}
+
+// withoutTime clears the duration of a record, which varies from run to run
+func withoutTime(r report.Record) report.Record {
+	r.Seconds = 0
+	return r
+}
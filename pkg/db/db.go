@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,11 @@ import (
 
 	_ "github.com/lib/pq"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
 )
 
 // DBConfig represents database configuration from .claude-project.json
@@ -25,28 +31,39 @@ type DBConfig struct {
 
 // ClaudeProject represents .claude-project.json structure
 type ClaudeProject struct {
-	Database DBConfig `json:"database"`
+	Database DBConfig               `json:"database"`
+	Queries  map[string]*NamedQuery `json:"queries"`
 }
 
 // LoadConfig loads database configuration from .claude-project.json
 func LoadConfig() (*DBConfig, error) {
+	project, _, err := loadProject()
+	if err != nil {
+		return nil, err
+	}
+	return &project.Database, nil
+}
+
+// loadProject reads the nearest .claude-project.json, returning it with
+// the directory it is in
+func loadProject() (*ClaudeProject, string, error) {
 	// Look for .claude-project.json in current directory or parents
 	configPath, err := findClaudeProjectFile()
 	if err != nil {
-		return nil, fmt.Errorf("failed to find .claude-project.json: %w", err)
+		return nil, "", fmt.Errorf("failed to find .claude-project.json: %w", err)
 	}
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+		return nil, "", fmt.Errorf("failed to read config: %w", err)
 	}
 
 	var project ClaudeProject
 	if err := json.Unmarshal(data, &project); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+		return nil, "", fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	return &project.Database, nil
+	return &project, filepath.Dir(configPath), nil
 }
 
 // findClaudeProjectFile searches for .claude-project.json in current and parent directories
@@ -73,7 +90,7 @@ func findClaudeProjectFile() (string, error) {
 }
 
 // Connect establishes a database connection
-func Connect(config *DBConfig) (*sql.DB, error) {
+func Connect(ctx context.Context, config *DBConfig) (*sql.DB, error) {
 	// Use defaults if not specified
 	user := config.User
 	if user == "" {
@@ -94,7 +111,7 @@ func Connect(config *DBConfig) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -102,9 +119,10 @@ func Connect(config *DBConfig) (*sql.DB, error) {
 	return db, nil
 }
 
-// Query executes a SQL query and returns results
-func Query(db *sql.DB, query string, format string) error {
-	rows, err := db.Query(query)
+// Query executes a SQL query, with args bound to its $n parameters, and
+// prints the results
+func Query(ctx context.Context, db *sql.DB, query string, format string, args ...any) error {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
@@ -224,46 +242,46 @@ func printCSV(rows *sql.Rows, columns []string) error {
 }
 
 // ListTables lists all tables in the database
-func ListTables(db *sql.DB) error {
+func ListTables(ctx context.Context, db *sql.DB) error {
 	query := `
 		SELECT table_name
 		FROM information_schema.tables
 		WHERE table_schema = 'public'
 		ORDER BY table_name;
 	`
-	return Query(db, query, "table")
+	return Query(ctx, db, query, "table")
 }
 
 // GetRules retrieves rules by category
-func GetRules(db *sql.DB, category string) error {
+func GetRules(ctx context.Context, db *sql.DB, category string) error {
 	query := fmt.Sprintf(`
 		SELECT rule_id, title, category, priority
 		FROM rules
 		WHERE category = '%s'
 		ORDER BY priority DESC, rule_id;
 	`, category)
-	return Query(db, query, "table")
+	return Query(ctx, db, query, "table")
 }
 
 // GetConfigs retrieves CI configs by type
-func GetConfigs(db *sql.DB, configType string) error {
+func GetConfigs(ctx context.Context, db *sql.DB, configType string) error {
 	query := fmt.Sprintf(`
 		SELECT config_name, config_type, notes
 		FROM ci_config
 		WHERE config_type = '%s'
 		ORDER BY config_name;
 	`, configType)
-	return Query(db, query, "table")
+	return Query(ctx, db, query, "table")
 }
 
 // ListProjects lists all tracked projects
-func ListProjects(db *sql.DB) error {
+func ListProjects(ctx context.Context, db *sql.DB) error {
 	query := `
 		SELECT project_id, project_name, project_type, project_path
 		FROM project_metadata
 		ORDER BY project_id;
 	`
-	return Query(db, query, "table")
+	return Query(ctx, db, query, "table")
 }
 
 // Command returns the db command for claude-tools
@@ -277,6 +295,8 @@ Reads database configuration from .claude-project.json in current or parent dire
 
 Examples:
   claude-tools db query "SELECT * FROM rules"
+  claude-tools db query --name recent-errors --param since=1h
+  claude-tools db queries
   claude-tools db tables
   claude-tools db rules --category metarules
   claude-tools db configs --type nixpacks
@@ -285,31 +305,204 @@ Examples:
 
 	// Query subcommand
 	queryCmd := &cobra.Command{
-		Use:   "query <sql>",
+		Use:   "query <sql> | --name <query>",
 		Short: "Execute a SQL query",
-		Long: `Execute a custom SQL query against the database.
+		Long: `Execute a custom SQL query against the database, or with --name one of
+the project's named queries (see "db queries"). --param sets a parameter
+of a named query as name=value; values are bound as query arguments.
+
+--watch runs the query again every interval until interrupted. On a
+terminal the screen is redrawn each time, with the rows that are new
+since the last run highlighted; otherwise, or with --diff, the result is
+printed once and then only the rows added (+) and removed (-) by each run.
 
 Examples:
   claude-tools db query "SELECT * FROM rules WHERE priority > 3"
-  claude-tools db query "SELECT config_name FROM ci_config" --format json`,
-		Args: cobra.ExactArgs(1),
+  claude-tools db query "SELECT config_name FROM ci_config" --format json
+  claude-tools db query --name recent-errors --param since=1h
+  claude-tools db query "SELECT id, state FROM jobs WHERE state <> 'done'" --watch 5s`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			params, _ := cmd.Flags().GetStringArray("param")
+			format, _ := cmd.Flags().GetString("format")
+			interval, _ := cmd.Flags().GetDuration("watch")
+			diff, _ := cmd.Flags().GetBool("diff")
+
+			switch {
+			case interval < 0:
+				return exitcode.New(2, fmt.Errorf("invalid --watch interval %s", interval))
+			case diff && interval == 0:
+				return exitcode.New(2, fmt.Errorf("--diff requires --watch"))
+			case interval > 0 && format != "table":
+				return exitcode.New(2, fmt.Errorf("--watch only works with the table format"))
+			}
+
+			var query string
+			var queryArgs []any
+			switch {
+			case name != "" && len(args) > 0:
+				return exitcode.New(2, fmt.Errorf("give either SQL or --name, not both"))
+			case name != "":
+				var err error
+				if query, queryArgs, err = namedQuery(name, params); err != nil {
+					return err
+				}
+			case len(params) > 0:
+				return exitcode.New(2, fmt.Errorf("--param requires --name"))
+			case len(args) == 0:
+				return exitcode.New(2, fmt.Errorf("missing SQL query"))
+			default:
+				query = args[0]
+			}
+
 			config, err := LoadConfig()
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			conn, err := Connect(config)
+			conn, err := Connect(cmd.Context(), config)
 			if err != nil {
 				return fmt.Errorf("failed to connect: %w", err)
 			}
 			defer conn.Close()
 
-			format, _ := cmd.Flags().GetString("format")
-			return Query(conn, args[0], format)
+			if interval > 0 {
+				opts := WatchOptions{
+					Interval: interval,
+					Diff:     diff || !color.Terminal(os.Stdout),
+					Color:    color.Enabled(os.Stdout),
+				}
+				// Watching only ends with an interrupt, which isn't an error
+				err := Watch(cmd.Context(), conn, os.Stdout, query, opts, queryArgs...)
+				if err != nil && !interrupt.Interrupted(err) {
+					return err
+				}
+				return nil
+			}
+			return Query(cmd.Context(), conn, query, format, queryArgs...)
 		},
 	}
 	queryCmd.Flags().StringP("format", "f", "table", "Output format (table, json, csv)")
+	queryCmd.Flags().StringP("name", "n", "", "Run the named query `NAME` from the project")
+	queryCmd.Flags().StringArrayP("param", "p", nil, "Set a parameter of the named query, as `NAME=VALUE`")
+	queryCmd.Flags().DurationP("watch", "w", 0, "Run the query again every `INTERVAL`, such as 5s")
+	queryCmd.Flags().Bool("diff", false, "With --watch, print the rows added and removed instead of redrawing")
+
+	// Queries subcommand
+	queriesCmd := &cobra.Command{
+		Use:   "queries",
+		Short: "List the project's named queries",
+		Long: `List the named queries of the project with their parameters.
+
+Named queries are kept in the "queries" object of .claude-project.json:
+
+  "queries": {
+    "recent-errors": {
+      "description": "Errors logged within a period",
+      "sql": "SELECT * FROM errors WHERE logged_at > now() - CAST(:since AS interval)",
+      "params": {"since": "1h"}
+    }
+  }
+
+or as .sql files in a queries directory next to it, named after the file.
+Leading comments describe the query, and "-- @param since 1h" lines give
+defaults. Parameters are written :name in the SQL; those without a
+default must be given with --param. A query in .claude-project.json
+replaces a file of the same name.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			queries, err := LoadQueries()
+			if err != nil {
+				return fmt.Errorf("failed to load queries: %w", err)
+			}
+			printQueries(os.Stdout, queries)
+			return nil
+		},
+	}
+
+	// Load subcommand
+	loadCmd := &cobra.Command{
+		Use:   "load <file>",
+		Short: "Load a CSV or JSON file into a table",
+		Long: `Load the rows of a CSV file with a header row, or of a JSON array of
+objects or JSON Lines file, into a table, so local data can be queried
+with SQL. The table is named after the file unless --table is given.
+
+With --create the table is created if it doesn't exist, with a column for
+each CSV column or JSON key, typed by what all of its values fit: boolean,
+bigint, double precision, date, timestamptz, jsonb or text. Empty CSV
+fields and JSON nulls are NULL. Rows are sent with COPY in a single
+transaction, so either the whole file is loaded or nothing is.
+
+Examples:
+  claude-tools db load events.csv --table raw_events --create
+  claude-tools db load export.jsonl --table staging.users`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			table, _ := cmd.Flags().GetString("table")
+			create, _ := cmd.Flags().GetBool("create")
+			format, _ := cmd.Flags().GetString("format")
+			if format != "" && format != "csv" && format != "json" {
+				return exitcode.New(2, fmt.Errorf("invalid format %q: expected csv or json", format))
+			}
+			if table == "" {
+				base := filepath.Base(args[0])
+				table = strings.TrimSuffix(base, filepath.Ext(base))
+			}
+
+			data, err := ReadDataset(cmd.Context(), args[0], format)
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[0], err)
+			}
+			data.InferTypes()
+
+			var conn *sql.DB
+			if !dryrun.Enabled {
+				config, err := LoadConfig()
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+				if conn, err = Connect(cmd.Context(), config); err != nil {
+					return fmt.Errorf("failed to connect: %w", err)
+				}
+				defer conn.Close()
+			}
+
+			rows, err := Load(cmd.Context(), conn, data, table, create)
+			if err != nil {
+				return err
+			}
+			if !dryrun.Enabled {
+				printLoaded(os.Stdout, rows, table)
+			}
+			return nil
+		},
+	}
+	loadCmd.Flags().StringP("table", "t", "", "Table to load into (default: the file's name)")
+	loadCmd.Flags().Bool("create", false, "Create the table if it doesn't exist, with inferred column types")
+	loadCmd.Flags().StringP("format", "f", "", "Input format: csv or json (default: by file extension)")
+
+	// Local subcommand
+	localCmd := &cobra.Command{
+		Use:   "local <sql>",
+		Short: "Query CSV and JSON files with SQL",
+		Long: `Run SQL over local files, without a database server. Files are named
+by string literals where tables would be, and each is imported into a
+table of an in-memory SQLite database before the query runs, with column
+types inferred as db load does. CSV files need a header row; JSON files
+hold an array of objects or one object per line.
+
+Examples:
+  claude-tools db local "SELECT status, count(*) FROM 'requests.csv' GROUP BY status"
+  claude-tools db local "SELECT u.name, o.total FROM 'users.json' u JOIN 'orders.csv' o ON o.user_id = u.id"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			return QueryLocal(cmd.Context(), args[0], format)
+		},
+	}
+	localCmd.Flags().StringP("format", "f", "table", "Output format (table, json, csv)")
 
 	// Tables subcommand
 	tablesCmd := &cobra.Command{
@@ -321,13 +514,13 @@ Examples:
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			conn, err := Connect(config)
+			conn, err := Connect(cmd.Context(), config)
 			if err != nil {
 				return fmt.Errorf("failed to connect: %w", err)
 			}
 			defer conn.Close()
 
-			return ListTables(conn)
+			return ListTables(cmd.Context(), conn)
 		},
 	}
 
@@ -349,14 +542,14 @@ Examples:
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			conn, err := Connect(config)
+			conn, err := Connect(cmd.Context(), config)
 			if err != nil {
 				return fmt.Errorf("failed to connect: %w", err)
 			}
 			defer conn.Close()
 
 			category, _ := cmd.Flags().GetString("category")
-			return GetRules(conn, category)
+			return GetRules(cmd.Context(), conn, category)
 		},
 	}
 	rulesCmd.Flags().StringP("category", "c", "metarules", "Rule category to query")
@@ -379,14 +572,14 @@ Examples:
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			conn, err := Connect(config)
+			conn, err := Connect(cmd.Context(), config)
 			if err != nil {
 				return fmt.Errorf("failed to connect: %w", err)
 			}
 			defer conn.Close()
 
 			configType, _ := cmd.Flags().GetString("type")
-			return GetConfigs(conn, configType)
+			return GetConfigs(cmd.Context(), conn, configType)
 		},
 	}
 	configsCmd.Flags().StringP("type", "t", "github-actions", "Config type to query")
@@ -401,17 +594,20 @@ Examples:
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			conn, err := Connect(config)
+			conn, err := Connect(cmd.Context(), config)
 			if err != nil {
 				return fmt.Errorf("failed to connect: %w", err)
 			}
 			defer conn.Close()
 
-			return ListProjects(conn)
+			return ListProjects(cmd.Context(), conn)
 		},
 	}
 
 	dbCmd.AddCommand(queryCmd)
+	dbCmd.AddCommand(queriesCmd)
+	dbCmd.AddCommand(loadCmd)
+	dbCmd.AddCommand(localCmd)
 	dbCmd.AddCommand(tablesCmd)
 	dbCmd.AddCommand(rulesCmd)
 	dbCmd.AddCommand(configsCmd)
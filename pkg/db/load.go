@@ -0,0 +1,363 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+)
+
+// Dataset is a file's records as columns and rows of optional text, ready
+// for COPY. A nil cell is NULL.
+type Dataset struct {
+	Columns []string
+	Rows    [][]*string
+	Types   []string // PostgreSQL types, filled in by InferTypes
+}
+
+// ReadDataset reads a CSV file with a header row, or JSON: an array of
+// objects or one object per line. format is "csv", "json", or "" to go by
+// the file's extension. Empty CSV fields and JSON nulls are NULL; nested
+// JSON values are kept as JSON text.
+func ReadDataset(ctx context.Context, filename, format string) (*Dataset, error) {
+	if format == "" {
+		format = "csv"
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".json", ".jsonl", ".ndjson":
+			format = "json"
+		}
+	}
+
+	file, err := input.OpenContext(ctx, filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open '%s': %w", filename, err)
+	}
+	defer file.Close()
+	r := textenc.NewDecoder(interrupt.Reader(ctx, file))
+
+	switch format {
+	case "csv":
+		return readCSV(r)
+	case "json":
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("invalid format %q: expected csv or json", format)
+}
+
+// readCSV reads CSV records, the first naming the columns
+func readCSV(r io.Reader) (*Dataset, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Dataset{Columns: make([]string, len(header))}
+	for i, name := range header {
+		d.Columns[i] = columnName(name, i)
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) > len(header) {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %d fields, but the header has %d", line, len(record), len(header))
+		}
+		row := make([]*string, len(header))
+		for i, field := range record {
+			if field != "" {
+				row[i] = &field
+			}
+		}
+		d.Rows = append(d.Rows, row)
+	}
+}
+
+// readJSON reads an array of objects or a stream of them, taking the
+// columns in the order their keys first appear
+func readJSON(r io.Reader) (*Dataset, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var objects []map[string]any
+	var order []string
+	index := map[string]int{}
+
+	for {
+		// Key order is lost in a map, so take it from the tokens
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var values []json.RawMessage
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			if err := json.Unmarshal(raw, &values); err != nil {
+				return nil, err
+			}
+		} else {
+			values = []json.RawMessage{raw}
+		}
+		for _, value := range values {
+			keys, err := objectKeys(value)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				if _, ok := index[k]; !ok {
+					index[k] = len(order)
+					order = append(order, k)
+				}
+			}
+			vd := json.NewDecoder(bytes.NewReader(value))
+			vd.UseNumber()
+			var obj map[string]any
+			if err := vd.Decode(&obj); err != nil || obj == nil {
+				return nil, fmt.Errorf("expected an object, found %.40s", value)
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	d := &Dataset{Columns: order}
+	for _, obj := range objects {
+		row := make([]*string, len(order))
+		for k, v := range obj {
+			if v == nil {
+				continue
+			}
+			var text string
+			switch v := v.(type) {
+			case string:
+				text = v
+			case json.Number:
+				text = v.String()
+			case bool:
+				text = strconv.FormatBool(v)
+			default:
+				data, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				text = string(data)
+			}
+			row[index[k]] = &text
+		}
+		d.Rows = append(d.Rows, row)
+	}
+	return d, nil
+}
+
+// objectKeys returns the keys of a JSON object in order, or none for
+// other values
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// columnName returns the name for header field i, making up one for an
+// empty field
+func columnName(name string, i int) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("column_%d", i+1)
+}
+
+// Inferred column types, from the most specific to text, which fits
+// anything
+const (
+	typeBoolean   = "boolean"
+	typeBigint    = "bigint"
+	typeDouble    = "double precision"
+	typeDate      = "date"
+	typeTimestamp = "timestamptz"
+	typeJSON      = "jsonb"
+	typeText      = "text"
+)
+
+// timestampLayouts are the forms of timestamps recognized
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// InferTypes picks the narrowest type that fits every value of each
+// column. NULLs fit any type; a column of nothing but NULLs is text.
+func (d *Dataset) InferTypes() {
+	d.Types = make([]string, len(d.Columns))
+	for c := range d.Columns {
+		typ := ""
+		for _, row := range d.Rows {
+			if row[c] == nil {
+				continue
+			}
+			typ = widen(typ, typeOf(*row[c]))
+			if typ == typeText {
+				break
+			}
+		}
+		if typ == "" {
+			typ = typeText
+		}
+		d.Types[c] = typ
+	}
+}
+
+// typeOf returns the narrowest type of a single value
+func typeOf(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false":
+		return typeBoolean
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return typeBigint
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "nNxX") {
+		return typeDouble
+	}
+	if _, err := time.Parse(time.DateOnly, s); err == nil {
+		return typeDate
+	}
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return typeTimestamp
+		}
+	}
+	if (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) && json.Valid([]byte(s)) {
+		return typeJSON
+	}
+	return typeText
+}
+
+// widen returns the type that fits values of both types
+func widen(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case a == typeBigint && b == typeDouble, a == typeDouble && b == typeBigint:
+		return typeDouble
+	case a == typeDate && b == typeTimestamp, a == typeTimestamp && b == typeDate:
+		return typeTimestamp
+	}
+	return typeText
+}
+
+// quoteTable quotes a table name, which may be qualified by its schema
+func quoteTable(table string) string {
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
+	}
+	return pq.QuoteIdentifier(table)
+}
+
+// CreateStatement returns the CREATE TABLE statement for the dataset
+func (d *Dataset) CreateStatement(table string) string {
+	columns := make([]string, len(d.Columns))
+	for i, name := range d.Columns {
+		columns[i] = "  " + pq.QuoteIdentifier(name) + " " + d.Types[i]
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)", quoteTable(table), strings.Join(columns, ",\n"))
+}
+
+// Load copies the dataset into table in a single transaction, creating
+// the table first if create is set, and returns the number of rows loaded.
+// Nothing is loaded if any row fails.
+func Load(ctx context.Context, db *sql.DB, d *Dataset, table string, create bool) (int, error) {
+	if dryrun.Enabled {
+		if create {
+			dryrun.Report("%s", d.CreateStatement(table))
+		}
+		dryrun.Report("load %d rows into %s", len(d.Rows), table)
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if create {
+		if _, err := tx.ExecContext(ctx, d.CreateStatement(table)); err != nil {
+			return 0, fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+
+	copyIn := pq.CopyIn(table, d.Columns...)
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		copyIn = pq.CopyInSchema(schema, name, d.Columns...)
+	}
+	stmt, err := tx.PrepareContext(ctx, copyIn)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start COPY: %w", err)
+	}
+	values := make([]any, len(d.Columns))
+	for i, row := range d.Rows {
+		for c, cell := range row {
+			values[c] = nil
+			if cell != nil {
+				values[c] = *cell
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("row %d: %w", i+1, err)
+		}
+	}
+	// The COPY is only sent, and checked, when it is flushed
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return 0, fmt.Errorf("COPY failed: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, fmt.Errorf("COPY failed: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return len(d.Rows), nil
+}
+
+// printLoaded reports a finished load
+func printLoaded(w io.Writer, rows int, table string) {
+	fmt.Fprintf(w, "loaded %d rows into %s\n", rows, table)
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cells returns the rows of a dataset with NULL as "<nil>"
+func cells(d *Dataset) [][]string {
+	var rows [][]string
+	for _, row := range d.Rows {
+		var r []string
+		for _, c := range row {
+			if c == nil {
+				r = append(r, "<nil>")
+			} else {
+				r = append(r, *c)
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// TestReadDataset_CSV tests reading a header and rows, with empty fields
+// as NULL and types inferred from every value
+func TestReadDataset_CSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.csv")
+	require.NoError(t, os.WriteFile(path, []byte(`id,ok,score,day,at,note,
+1,true,2,2024-01-02,2024-01-02T03:04:05Z,"a, b",x
+2,false,2.5,,2024-01-03,,
+`), 0644))
+
+	d, err := ReadDataset(context.Background(), path, "")
+	require.NoError(t, err)
+	d.InferTypes()
+
+	assert.Equal(t, []string{"id", "ok", "score", "day", "at", "note", "column_7"}, d.Columns)
+	assert.Equal(t, []string{"bigint", "boolean", "double precision", "date", "timestamptz", "text", "text"}, d.Types)
+	assert.Equal(t, [][]string{
+		{"1", "true", "2", "2024-01-02", "2024-01-02T03:04:05Z", "a, b", "x"},
+		{"2", "false", "2.5", "<nil>", "2024-01-03", "<nil>", "<nil>"},
+	}, cells(d))
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "raw"."events" (
+  "id" bigint,
+  "ok" boolean,
+  "score" double precision,
+  "day" date,
+  "at" timestamptz,
+  "note" text,
+  "column_7" text
+)`, d.CreateStatement("raw.events"))
+}
+
+// TestReadDataset_JSON tests reading an array and JSON Lines, taking the
+// columns in the order keys first appear
+func TestReadDataset_JSON(t *testing.T) {
+	dir := t.TempDir()
+	array := filepath.Join(dir, "users.json")
+	require.NoError(t, os.WriteFile(array, []byte(`[{"id": 1, "name": "ann"}, {"name": "bob", "tags": ["x"], "id": 20000000000}]`), 0644))
+	lines := filepath.Join(dir, "users.jsonl")
+	require.NoError(t, os.WriteFile(lines, []byte("{\"id\": 1, \"admin\": null}\n{\"id\": 1.5, \"admin\": true}\n"), 0644))
+
+	d, err := ReadDataset(context.Background(), array, "")
+	require.NoError(t, err)
+	d.InferTypes()
+	assert.Equal(t, []string{"id", "name", "tags"}, d.Columns)
+	assert.Equal(t, []string{"bigint", "text", "jsonb"}, d.Types)
+	assert.Equal(t, [][]string{{"1", "ann", "<nil>"}, {"20000000000", "bob", `["x"]`}}, cells(d))
+
+	d, err = ReadDataset(context.Background(), lines, "")
+	require.NoError(t, err)
+	d.InferTypes()
+	assert.Equal(t, []string{"double precision", "boolean"}, d.Types)
+
+	require.NoError(t, os.WriteFile(array, []byte(`[1, 2]`), 0644))
+	_, err = ReadDataset(context.Background(), array, "json")
+	assert.ErrorContains(t, err, "expected an object")
+}
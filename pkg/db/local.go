@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteTypes maps the inferred types to SQLite column affinities. Dates,
+// timestamps and JSON stay text, which SQLite's functions work on.
+var sqliteTypes = map[string]string{
+	typeBoolean: "INTEGER",
+	typeBigint:  "INTEGER",
+	typeDouble:  "REAL",
+}
+
+// QueryLocal runs sql against an in-memory SQLite database into which the
+// files it names are imported first. A file is named by a string literal
+// where a table would be, after FROM or JOIN or in a FROM list: 'data.csv'.
+func QueryLocal(ctx context.Context, sql, format string) error {
+	rewritten, files := fileRefs(sql)
+
+	db, err := openLocal(ctx, files)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return Query(ctx, db, rewritten, format)
+}
+
+// openLocal creates the in-memory database with a table for each file,
+// named after the file as written
+func openLocal(ctx context.Context, files []string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite: %w", err)
+	}
+	// Each connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite: %w", err)
+	}
+
+	for _, file := range files {
+		data, err := ReadDataset(ctx, file, "")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		data.InferTypes()
+		if err := importDataset(ctx, db, file, data); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return db, nil
+}
+
+// importDataset creates a temporary table and inserts the rows in one
+// transaction
+func importDataset(ctx context.Context, db *sql.DB, table string, d *Dataset) error {
+	columns := make([]string, len(d.Columns))
+	marks := make([]string, len(d.Columns))
+	for i, name := range d.Columns {
+		typ := sqliteTypes[d.Types[i]]
+		if typ == "" {
+			typ = "TEXT"
+		}
+		columns[i] = quoteIdent(name) + " " + typ
+		marks[i] = "?"
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	create := fmt.Sprintf("CREATE TEMP TABLE %s (%s)", quoteIdent(table), strings.Join(columns, ", "))
+	if _, err := tx.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (%s)", quoteIdent(table), strings.Join(marks, ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	values := make([]any, len(d.Columns))
+	for _, row := range d.Rows {
+		for c, cell := range row {
+			switch {
+			case cell == nil:
+				values[c] = nil
+			case d.Types[c] == typeBoolean:
+				values[c] = strings.EqualFold(*cell, "true")
+			default:
+				values[c] = *cell
+			}
+		}
+		if _, err := insert.ExecContext(ctx, values...); err != nil {
+			return fmt.Errorf("failed to import: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// quoteIdent quotes a SQL identifier with double quotes
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// fileRefs replaces the string literals that stand for tables in sql by
+// quoted identifiers, returning the rewritten SQL and the files named, each
+// once. A literal stands for a table right after FROM or JOIN, or after a
+// comma that follows one such table and its optional alias.
+func fileRefs(sql string) (string, []string) {
+	var b strings.Builder
+	var files []string
+	const (
+		other   = iota
+		table   // a table may come next
+		listing // after a file and maybe its alias, where a comma continues the list
+	)
+	state, words := other, 0
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			b.WriteByte(c)
+			i++
+
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := len(sql)
+			if k := strings.IndexByte(sql[i:], '\n'); k >= 0 {
+				end = i + k
+			}
+			b.WriteString(sql[i:end])
+			i = end
+
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := len(sql)
+			if k := strings.Index(sql[i+2:], "*/"); k >= 0 {
+				end = i + k + 4
+			}
+			b.WriteString(sql[i:end])
+			i = end
+
+		case c == '\'' || c == '"':
+			// Quotes inside are doubled
+			j := i + 1
+			for j < len(sql) {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			end := min(j+1, len(sql))
+			text := sql[i:end]
+			if c == '\'' && state == table {
+				name := strings.ReplaceAll(strings.Trim(text, "'"), "''", "'")
+				if !slices.Contains(files, name) {
+					files = append(files, name)
+				}
+				text = quoteIdent(name)
+				state, words = listing, 0
+			} else {
+				state = other
+			}
+			b.WriteString(text)
+			i = end
+
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			word := strings.ToUpper(sql[i:j])
+			switch {
+			case word == "FROM" || word == "JOIN":
+				state = table
+			case state == listing && words < 2:
+				// An alias, maybe after AS
+				words++
+			default:
+				state = other
+			}
+			b.WriteString(sql[i:j])
+			i = j
+
+		case c == ',' && state == listing:
+			state = table
+			b.WriteByte(c)
+			i++
+
+		default:
+			state = other
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), files
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFileRefs tests finding the files a query names where tables would
+// be, and leaving other strings alone
+func TestFileRefs(t *testing.T) {
+	sql, files := fileRefs(`SELECT 'a.csv', x FROM 'a.csv' a, 'b.json' AS b
+JOIN 'dir/it''s.csv' ON true -- FROM 'c.csv'
+WHERE name = 'a.csv' AND y IN (SELECT y FROM 'a.csv')`)
+	assert.Equal(t, `SELECT 'a.csv', x FROM "a.csv" a, "b.json" AS b
+JOIN "dir/it's.csv" ON true -- FROM 'c.csv'
+WHERE name = 'a.csv' AND y IN (SELECT y FROM "a.csv")`, sql)
+	assert.Equal(t, []string{"a.csv", "b.json", "dir/it's.csv"}, files)
+}
+
+// TestOpenLocal tests importing files into SQLite with typed columns
+func TestOpenLocal(t *testing.T) {
+	dir := t.TempDir()
+	people := filepath.Join(dir, "people.csv")
+	require.NoError(t, os.WriteFile(people, []byte("id,name,age\n1,ann,30\n2,bob,25\n3,cy,\n"), 0644))
+
+	db, err := openLocal(context.Background(), []string{people})
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	var avg float64
+	row := db.QueryRow(`SELECT count(*), avg(age) FROM ` + quoteIdent(people) + ` WHERE age > 9`)
+	require.NoError(t, row.Scan(&count, &avg))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 27.5, avg)
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+)
+
+// NamedQuery is a query kept in the project so it can be run by name.
+// Parameters are written :name in the SQL and bound as query arguments,
+// never pasted into the text.
+type NamedQuery struct {
+	Description string            `json:"description"`
+	SQL         string            `json:"sql"`
+	Params      map[string]string `json:"params"` // default values; others are required
+}
+
+// queriesDir is the directory next to .claude-project.json holding one
+// query per .sql file
+const queriesDir = "queries"
+
+// LoadQueries returns the named queries of the project: those in the
+// queries directory, named after their files, then those under "queries"
+// in .claude-project.json, which replace files of the same name
+func LoadQueries() (map[string]*NamedQuery, error) {
+	project, dir, err := loadProject()
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := readQueryDir(filepath.Join(dir, queriesDir))
+	if err != nil {
+		return nil, err
+	}
+	for name, q := range project.Queries {
+		queries[name] = q
+	}
+	return queries, nil
+}
+
+// readQueryDir reads the .sql files in dir, which need not exist. Leading
+// comment lines describe the query, except for those of the form
+// "-- @param name default", which give a parameter its default.
+func readQueryDir(dir string) (map[string]*NamedQuery, error) {
+	queries := map[string]*NamedQuery{}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read query: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".sql")
+		queries[name] = parseQueryFile(string(data))
+	}
+	return queries, nil
+}
+
+// parseQueryFile splits a .sql file into its header comments and the SQL
+func parseQueryFile(text string) *NamedQuery {
+	q := &NamedQuery{Params: map[string]string{}}
+	var description []string
+	rest := text
+	for rest != "" {
+		line, after, _ := strings.Cut(rest, "\n")
+		comment, ok := strings.CutPrefix(strings.TrimSpace(line), "--")
+		if !ok {
+			break
+		}
+		rest = after
+		comment = strings.TrimSpace(comment)
+		if param, ok := strings.CutPrefix(comment, "@param "); ok {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), " ")
+			q.Params[name] = strings.TrimSpace(value)
+			continue
+		}
+		if comment != "" {
+			description = append(description, comment)
+		}
+	}
+	q.Description = strings.Join(description, " ")
+	q.SQL = strings.TrimSpace(rest)
+	return q
+}
+
+// Bind rewrites the :name parameters of the query as PostgreSQL's $1, $2,
+// ... and returns the arguments to pass with it. Values come from given,
+// then from the defaults; a parameter with neither, or a given one the
+// query doesn't use, is an error.
+func (q *NamedQuery) Bind(given map[string]string) (string, []any, error) {
+	sql, names := placeholders(q.SQL)
+
+	for name := range given {
+		if !slices.Contains(names, name) {
+			return "", nil, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+	args := make([]any, len(names))
+	for i, name := range names {
+		value, ok := given[name]
+		if !ok {
+			if value, ok = q.Params[name]; !ok {
+				return "", nil, fmt.Errorf("missing parameter %q", name)
+			}
+		}
+		args[i] = value
+	}
+	return sql, args, nil
+}
+
+// ParamNames returns the parameters of the query in the order they first
+// appear
+func (q *NamedQuery) ParamNames() []string {
+	_, names := placeholders(q.SQL)
+	return names
+}
+
+// placeholders replaces each :name in sql by $n, the same name getting the
+// same number, and returns the names in order. Casts (::), strings,
+// quoted identifiers and comments are left alone.
+func placeholders(sql string) (string, []string) {
+	var b strings.Builder
+	var names []string
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			// A doubled quote inside reads as two strings, which is as good
+			end := len(sql)
+			if k := strings.IndexByte(sql[i+1:], c); k >= 0 {
+				end = i + k + 2
+			}
+			b.WriteString(sql[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := len(sql)
+			if k := strings.IndexByte(sql[i:], '\n'); k >= 0 {
+				end = i + k
+			}
+			b.WriteString(sql[i:end])
+			i = end
+		case c == ':' && strings.HasPrefix(sql[i:], "::"):
+			b.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < len(sql) && isIdentStart(sql[i+1]):
+			j := i + 2
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			name := sql[i+1 : j]
+			n := slices.Index(names, name)
+			if n < 0 {
+				names = append(names, name)
+				n = len(names) - 1
+			}
+			b.WriteString("$" + strconv.Itoa(n+1))
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), names
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// namedQuery looks up a named query and binds the NAME=VALUE params to it
+func namedQuery(name string, params []string) (string, []any, error) {
+	given := map[string]string{}
+	for _, param := range params {
+		k, v, ok := strings.Cut(param, "=")
+		if !ok || k == "" {
+			return "", nil, exitcode.New(2, fmt.Errorf("invalid --param %q: expected NAME=VALUE", param))
+		}
+		given[k] = v
+	}
+
+	queries, err := LoadQueries()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load queries: %w", err)
+	}
+	q, ok := queries[name]
+	if !ok {
+		return "", nil, exitcode.New(2, fmt.Errorf("no query named %q", name))
+	}
+	sql, args, err := q.Bind(given)
+	if err != nil {
+		return "", nil, exitcode.New(2, fmt.Errorf("query %q: %w", name, err))
+	}
+	return sql, args, nil
+}
+
+// printQueries lists queries by name with their parameters, defaults and
+// descriptions
+func printQueries(w io.Writer, queries map[string]*NamedQuery) {
+	for _, name := range slices.Sorted(maps.Keys(queries)) {
+		q := queries[name]
+		line := name
+		for _, param := range q.ParamNames() {
+			if value, ok := q.Params[param]; ok {
+				line += fmt.Sprintf(" [%s=%s]", param, value)
+			} else {
+				line += " " + param
+			}
+		}
+		if q.Description != "" {
+			line += "\n    " + q.Description
+		}
+		fmt.Fprintln(w, line)
+	}
+}
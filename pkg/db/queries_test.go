@@ -0,0 +1,77 @@
+package db
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPlaceholders tests numbering :name parameters while leaving casts,
+// strings, quoted identifiers and comments alone
+func TestPlaceholders(t *testing.T) {
+	sql, names := placeholders(`SELECT ':x', "a:b", ts::date -- :note
+FROM logs WHERE level = :level AND ts > :since AND :level <> ''`)
+	assert.Equal(t, `SELECT ':x', "a:b", ts::date -- :note
+FROM logs WHERE level = $1 AND ts > $2 AND $1 <> ''`, sql)
+	assert.Equal(t, []string{"level", "since"}, names)
+}
+
+// TestNamedQuery_Bind tests given values, defaults and parameter errors
+func TestNamedQuery_Bind(t *testing.T) {
+	q := &NamedQuery{
+		SQL:    "SELECT * FROM errors WHERE app = :app AND logged_at > now() - CAST(:since AS interval)",
+		Params: map[string]string{"since": "1h"},
+	}
+
+	sql, args, err := q.Bind(map[string]string{"app": "api"})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM errors WHERE app = $1 AND logged_at > now() - CAST($2 AS interval)", sql)
+	assert.Equal(t, []any{"api", "1h"}, args)
+
+	_, args, err = q.Bind(map[string]string{"app": "api", "since": "1d"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"api", "1d"}, args)
+
+	_, _, err = q.Bind(nil)
+	assert.ErrorContains(t, err, `missing parameter "app"`)
+
+	_, _, err = q.Bind(map[string]string{"app": "api", "limit": "5"})
+	assert.ErrorContains(t, err, `unknown parameter "limit"`)
+}
+
+// TestLoadQueries tests reading queries from .sql files and from
+// .claude-project.json, which takes precedence
+func TestLoadQueries(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".claude-project.json"), []byte(`{
+  "queries": {
+    "slow-jobs": {"sql": "SELECT * FROM jobs WHERE took > :limit", "params": {"limit": "5s"}}
+  }
+}`), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "queries"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "queries", "recent-errors.sql"), []byte(`-- Errors logged within a period
+-- @param since 1 hour
+SELECT * FROM errors
+WHERE logged_at > now() - CAST(:since AS interval) AND app = :app
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "queries", "slow-jobs.sql"), []byte("SELECT 1"), 0644))
+	t.Chdir(dir)
+
+	queries, err := LoadQueries()
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, "SELECT * FROM jobs WHERE took > :limit", queries["slow-jobs"].SQL)
+
+	errs := queries["recent-errors"]
+	assert.Equal(t, "Errors logged within a period", errs.Description)
+	assert.Equal(t, map[string]string{"since": "1 hour"}, errs.Params)
+	assert.Equal(t, "SELECT * FROM errors\nWHERE logged_at > now() - CAST(:since AS interval) AND app = :app", errs.SQL)
+
+	var buf bytes.Buffer
+	printQueries(&buf, queries)
+	assert.Equal(t, "recent-errors [since=1 hour] app\n    Errors logged within a period\nslow-jobs [limit=5s]\n", buf.String())
+}
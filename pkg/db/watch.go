@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\x1b[H\x1b[2J"
+
+// WatchOptions controls db query --watch
+type WatchOptions struct {
+	Interval time.Duration
+	Diff     bool // print rows added and removed instead of redrawing
+	Color    bool // highlight changed rows
+}
+
+// Watch runs a query every interval until ctx is cancelled. Each result is
+// either drawn in full on a cleared screen, with the rows that weren't in
+// the previous result highlighted, or with Diff, printed in full once and
+// then as the rows added (+) and removed (-) since. A failing run is
+// reported and the next one tried.
+func Watch(ctx context.Context, db *sql.DB, w io.Writer, query string, opts WatchOptions, args ...any) error {
+	var prev [][]string
+	first := true
+	for {
+		columns, rows, err := fetchRows(ctx, db, query, args)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			logging.Error(err)
+		case opts.Diff && !first:
+			writeDiff(w, prev, rows, opts.Color)
+			prev = rows
+		default:
+			if !opts.Diff {
+				fmt.Fprint(w, clearScreen)
+				fmt.Fprintf(w, "Every %s: %s    %s\n\n", opts.Interval, firstLine(query), time.Now().Format(time.TimeOnly))
+			}
+			changed := changedRows(prev, rows)
+			if first {
+				changed = nil
+			}
+			writeTable(w, columns, rows, changed, opts.Color)
+			prev, first = rows, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// fetchRows runs query and returns its columns and rows as table text
+func fetchRows(ctx context.Context, db *sql.DB, query string, args []any) ([]string, [][]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+
+	var result [][]string
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(columns))
+		for i, val := range values {
+			if val == nil {
+				row[i] = "NULL"
+			} else {
+				row[i] = fmt.Sprintf("%v", val)
+			}
+		}
+		result = append(result, row)
+	}
+	return columns, result, rows.Err()
+}
+
+// changedRows marks the rows of cur that prev didn't have, counting
+// duplicates, so that a second copy of a row is new too
+func changedRows(prev, cur [][]string) []bool {
+	seen := map[string]int{}
+	for _, row := range prev {
+		seen[rowKey(row)]++
+	}
+	changed := make([]bool, len(cur))
+	for i, row := range cur {
+		k := rowKey(row)
+		if seen[k] > 0 {
+			seen[k]--
+		} else {
+			changed[i] = true
+		}
+	}
+	return changed
+}
+
+// writeTable writes rows as printTable does, highlighting those changed
+func writeTable(w io.Writer, columns []string, rows [][]string, changed []bool, enabled bool) {
+	fmt.Fprintln(w, strings.Join(columns, " | "))
+	fmt.Fprintln(w, strings.Repeat("-", len(columns)*20))
+	for i, row := range rows {
+		line := strings.Join(row, " | ")
+		if changed != nil && changed[i] {
+			line = color.Paint(enabled, line, color.Changed)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// writeDiff writes the rows removed from prev and those added in cur,
+// under the time of the run, or nothing if the result is the same
+func writeDiff(w io.Writer, prev, cur [][]string, enabled bool) {
+	removed := changedRows(cur, prev)
+	added := changedRows(prev, cur)
+	if !slices.Contains(removed, true) && !slices.Contains(added, true) {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", time.Now().Format(time.TimeOnly))
+	for i, row := range prev {
+		if removed[i] {
+			fmt.Fprintln(w, color.Paint(enabled, "- "+strings.Join(row, " | "), color.Removed))
+		}
+	}
+	for i, row := range cur {
+		if added[i] {
+			fmt.Fprintln(w, color.Paint(enabled, "+ "+strings.Join(row, " | "), color.Added))
+		}
+	}
+}
+
+// rowKey joins the cells of a row so that no two different rows share one
+func rowKey(row []string) string {
+	return strings.Join(row, "\x00")
+}
+
+// firstLine returns the first line of a query, for the watch header
+func firstLine(query string) string {
+	line, _, more := strings.Cut(strings.TrimSpace(query), "\n")
+	if more {
+		line += " ..."
+	}
+	return line
+}
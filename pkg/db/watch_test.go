@@ -0,0 +1,43 @@
+package db
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestChangedRows tests marking the rows that are new since the last run,
+// duplicates included
+func TestChangedRows(t *testing.T) {
+	prev := [][]string{{"1", "queued"}, {"2", "running"}, {"3", "running"}}
+	cur := [][]string{{"1", "running"}, {"2", "running"}, {"3", "running"}, {"3", "running"}}
+	assert.Equal(t, []bool{true, false, false, true}, changedRows(prev, cur))
+	assert.Equal(t, []bool{false, false, false}, changedRows(prev, prev))
+}
+
+// TestWriteTable tests highlighting changed rows
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	rows := [][]string{{"1", "done"}, {"2", "failed"}}
+	writeTable(&buf, []string{"id", "state"}, rows, []bool{false, true}, true)
+	assert.Equal(t, "id | state\n"+
+		"----------------------------------------\n"+
+		"1 | done\n"+
+		"\x1b[01;33m2 | failed\x1b[m\n", buf.String())
+}
+
+// TestWriteDiff tests printing the rows added and removed, and nothing
+// for an unchanged result
+func TestWriteDiff(t *testing.T) {
+	var buf bytes.Buffer
+	prev := [][]string{{"1", "queued"}, {"2", "running"}}
+	cur := [][]string{{"2", "running"}, {"1", "done"}}
+	writeDiff(&buf, prev, cur, false)
+	assert.Regexp(t, regexp.MustCompile(`^\n\d\d:\d\d:\d\d\n- 1 \| queued\n\+ 1 \| done\n$`), buf.String())
+
+	buf.Reset()
+	writeDiff(&buf, cur, cur, false)
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,228 @@
+package dedupe
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/largest"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// Options holds dedupe configuration
+type Options struct {
+	MinSize   int64
+	Gitignore bool
+	JSON      bool
+	Link      bool // replace duplicates with hard links to the file kept
+	Delete    bool // remove duplicates
+	Yes       bool // act without asking
+}
+
+// Report is what dedupe --json prints
+type Report struct {
+	Groups      []Group `json:"groups"`
+	Duplicates  int     `json:"duplicates"`
+	WastedBytes int64   `json:"wasted_bytes"`
+}
+
+// Command returns the dedupe command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "dedupe [flags] [dir...]",
+		Short: "Find files with the same contents",
+		Long: `Find files with the same contents in directories, the current one if
+none are given, and print each group of them with a blank line between
+groups, largest files first. Files are compared by size and then by the
+SHA-256 of their contents. Names of a file that are already hard links
+to each other count as one, and empty files are left out unless
+--min-size is 0.
+
+--link replaces each duplicate with a hard link to the first file of
+its group in path order, so that they share one copy on disk; --delete
+removes the duplicates instead. Both act on every name a duplicate has,
+and both ask for confirmation first, which --yes skips and which
+standard input must be a terminal for otherwise. --dry-run prints what
+they would do.
+
+--json prints the groups as a JSON document with their size and hash,
+the number of duplicates and the bytes they take up.
+
+Examples:
+  dedupe ~/Downloads
+  dedupe --min-size 1048576 --json /data > dupes.json
+  dedupe --link --yes build/cache`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if opts.Link && opts.Delete {
+				return exitcode.New(2, errors.New("--link and --delete cannot be used together"))
+			}
+			if opts.MinSize < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --min-size %d", opts.MinSize))
+			}
+			if opts.JSON {
+				// Keep standard output to the report
+				dryrun.Output = os.Stderr
+			}
+			roots := glob.Expand(args)
+			if len(roots) == 0 {
+				roots = []string{"."}
+			}
+
+			groups, err := scan(ctx, roots, opts.MinSize, &walk.Walker{Gitignore: opts.Gitignore})
+			if err != nil {
+				return err
+			}
+			if err := write(output.Stdout, groups, opts); err != nil {
+				return err
+			}
+			if err := output.Stdout.Flush(); err != nil {
+				return err
+			}
+			if !opts.JSON {
+				logging.Info(summary(groups))
+			}
+			if len(groups) == 0 || !(opts.Link || opts.Delete) {
+				return nil
+			}
+			if !opts.Yes && !dryrun.Enabled {
+				ok, err := confirm(os.Stdin, os.Stderr, groups, opts)
+				if err != nil || !ok {
+					return err
+				}
+			}
+			cmd.SilenceErrors = true
+			return apply(groups, opts)
+		},
+	}
+
+	cmd.Flags().Int64Var(&opts.MinSize, "min-size", 1, "Only consider files of at least `BYTES`")
+	cmd.Flags().BoolVar(&opts.Gitignore, "gitignore", false, "Skip files ignored by .gitignore, and .git itself")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "Print the groups as JSON")
+	cmd.Flags().BoolVar(&opts.Link, "link", false, "Replace duplicates with hard links to the first file of their group")
+	cmd.Flags().BoolVar(&opts.Delete, "delete", false, "Remove duplicates, keeping the first file of each group")
+	cmd.Flags().BoolVarP(&opts.Yes, "yes", "y", false, "Don't ask before --link or --delete")
+
+	return cmd
+}
+
+// write prints the groups, as JSON with --json
+func write(w io.Writer, groups []Group, opts *Options) error {
+	if opts.JSON {
+		report := Report{Groups: groups, WastedBytes: wasted(groups)}
+		if report.Groups == nil {
+			report.Groups = []Group{}
+		}
+		for _, g := range groups {
+			report.Duplicates += len(g.Files) - 1
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	for i, g := range groups {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, strings.Join(g.Files, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summary describes how many duplicates there are
+func summary(groups []Group) string {
+	n := 0
+	for _, g := range groups {
+		n += len(g.Files) - 1
+	}
+	return fmt.Sprintf("%d duplicate files in %d groups, taking up %s", n, len(groups), largest.FormatSize(wasted(groups)))
+}
+
+// confirm asks on out whether to go ahead, and reads the answer from in,
+// which must be a terminal
+func confirm(in *os.File, out io.Writer, groups []Group, opts *Options) (bool, error) {
+	if !color.Interactive(in) {
+		return false, exitcode.New(2, errors.New("standard input is not a terminal to confirm on; use --yes"))
+	}
+	verb := "Delete"
+	if opts.Link {
+		verb = "Hard-link"
+	}
+	fmt.Fprintf(out, "%s %s? [y/N] ", verb, summary(groups))
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// apply links or deletes the duplicates of each group, and returns status
+// 1 if any could not be
+func apply(groups []Group, opts *Options) error {
+	failed := false
+	for _, g := range groups {
+		keep := g.Files[0]
+		var dups []string
+		for _, dup := range g.Files[1:] {
+			dups = append(append(dups, dup), g.links[dup]...)
+		}
+		for _, dup := range dups {
+			switch {
+			case dryrun.Enabled && opts.Link:
+				dryrun.Report("link '%s' to '%s'", dup, keep)
+			case dryrun.Enabled:
+				dryrun.Report("remove '%s'", dup)
+			case opts.Link:
+				if err := link(keep, dup); err != nil {
+					logging.PathError("Cannot link", dup, err)
+					failed = true
+				}
+			default:
+				if err := os.Remove(dup); err != nil {
+					logging.PathError("Cannot remove", dup, err)
+					failed = true
+				}
+			}
+		}
+	}
+	if failed {
+		return exitcode.Status(1)
+	}
+	return nil
+}
+
+// link replaces dup with a hard link to keep, through a temporary link
+// renamed over it so that dup is never missing
+func link(keep, dup string) error {
+	tmp := filepath.Join(filepath.Dir(dup), fmt.Sprintf(".%s.dedupe-%d", filepath.Base(dup), os.Getpid()))
+	if err := os.Link(keep, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
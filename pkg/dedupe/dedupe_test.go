@@ -0,0 +1,104 @@
+package dedupe
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// writeFiles creates files under dir with the given contents
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+// TestScan tests grouping by contents, files of the same size that
+// differ, large files that differ after the first block, empty files,
+// and hard links counting once
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("x", headSize+10)
+	writeFiles(t, dir, map[string]string{
+		"a/1":     "hello",
+		"b/2":     "hello",
+		"b/3":     "world",
+		"a/big":   big + "1",
+		"b/big":   big + "1",
+		"c/big":   big + "2",
+		"a/empty": "",
+		"b/empty": "",
+	})
+	require.NoError(t, os.Link(filepath.Join(dir, "b/2"), filepath.Join(dir, "c/2")))
+
+	groups, err := scan(t.Context(), []string{dir, filepath.Join(dir, "a")}, 1, &walk.Walker{})
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, int64(len(big)+1), groups[0].Size)
+	assert.Equal(t, []string{filepath.Join(dir, "a/big"), filepath.Join(dir, "b/big")}, groups[0].Files)
+	assert.Equal(t, []string{filepath.Join(dir, "a/1"), filepath.Join(dir, "b/2")}, groups[1].Files)
+	assert.Equal(t, []string{filepath.Join(dir, "c/2")}, groups[1].links[filepath.Join(dir, "b/2")])
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", groups[1].Hash)
+
+	groups, err = scan(t.Context(), []string{dir}, 0, &walk.Walker{})
+	require.NoError(t, err)
+	assert.Len(t, groups, 3)
+}
+
+// TestApply tests --link and --delete keeping the first file of each
+// group, and acting on every name of a duplicate
+func TestApply(t *testing.T) {
+	for _, opts := range []*Options{{Link: true}, {Delete: true}} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"a": "same", "b": "same", "c": "same"})
+		require.NoError(t, os.Link(filepath.Join(dir, "c"), filepath.Join(dir, "d")))
+		groups, err := scan(t.Context(), []string{dir}, 1, &walk.Walker{})
+		require.NoError(t, err)
+
+		require.NoError(t, apply(groups, opts))
+		keep, err := os.Stat(filepath.Join(dir, "a"))
+		require.NoError(t, err)
+		for _, name := range []string{"b", "c", "d"} {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if opts.Delete {
+				assert.True(t, os.IsNotExist(err), name)
+				continue
+			}
+			require.NoError(t, err)
+			assert.True(t, os.SameFile(keep, info), name)
+		}
+	}
+}
+
+// TestWrite tests the plain listing and the JSON report
+func TestWrite(t *testing.T) {
+	groups := []Group{
+		{Size: 10, Hash: "h1", Files: []string{"a", "b", "c"}},
+		{Size: 2, Hash: "h2", Files: []string{"d", "e"}},
+	}
+	var out bytes.Buffer
+	require.NoError(t, write(&out, groups, &Options{}))
+	assert.Equal(t, "a\nb\nc\n\nd\ne\n", out.String())
+
+	out.Reset()
+	require.NoError(t, write(&out, groups, &Options{JSON: true}))
+	var report Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 3, report.Duplicates)
+	assert.Equal(t, int64(22), report.WastedBytes)
+	assert.Equal(t, groups[1].Files, report.Groups[1].Files)
+
+	out.Reset()
+	require.NoError(t, write(&out, nil, &Options{JSON: true}))
+	assert.Contains(t, out.String(), `"groups": []`)
+}
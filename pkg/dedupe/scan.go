@@ -0,0 +1,180 @@
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// headSize is how much of a file is hashed first, so that files of the
+// same size that differ early aren't read to the end
+const headSize = 64 * 1024
+
+// Group is files with the same contents
+type Group struct {
+	Size  int64    `json:"size"`
+	Hash  string   `json:"sha256"`
+	Files []string `json:"files"` // sorted; the first is the one --link and --delete keep
+
+	links map[string][]string // the other names of files that are hard-linked
+}
+
+// file is a regular file found by the walk
+type file struct {
+	path  string
+	info  fs.FileInfo
+	links []string // other names it has, as hard links
+}
+
+// scan walks roots and returns the groups of files of at least minSize
+// bytes that have the same contents, largest first. Names of a file that
+// is already hard-linked count once.
+func scan(ctx context.Context, roots []string, minSize int64, walker *walk.Walker) ([]Group, error) {
+	bySize := map[int64][]file{}
+	seen := map[string]bool{}
+	for _, root := range roots {
+		err := walker.Walk(ctx, root, func(path string, entry fs.DirEntry, depth int) error {
+			// Roots inside other roots give the same files twice
+			if !entry.Type().IsRegular() || seen[filepath.Clean(path)] {
+				return nil
+			}
+			seen[filepath.Clean(path)] = true
+			info, err := entry.Info()
+			if err != nil {
+				logging.PathError("Cannot stat", path, err)
+				return nil
+			}
+			if info.Size() >= minSize {
+				bySize[info.Size()] = append(bySize[info.Size()], file{path: path, info: info})
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			logging.PathError("Failed to search", root, err)
+		}
+	}
+
+	var groups []Group
+	for size, files := range bySize {
+		files = distinct(files)
+		if len(files) < 2 {
+			continue
+		}
+		byHead, err := hashAll(ctx, files, headSize)
+		if err != nil {
+			return nil, err
+		}
+		for head, same := range byHead {
+			if len(same) < 2 {
+				continue
+			}
+			byHash := map[string][]file{head: same}
+			if size > headSize {
+				if byHash, err = hashAll(ctx, same, -1); err != nil {
+					return nil, err
+				}
+			}
+			for hash, same := range byHash {
+				if len(same) < 2 {
+					continue
+				}
+				g := Group{Size: size, Hash: hash, links: map[string][]string{}}
+				for _, f := range same {
+					g.Files = append(g.Files, f.path)
+					if len(f.links) > 0 {
+						g.links[f.path] = f.links
+					}
+				}
+				slices.Sort(g.Files)
+				groups = append(groups, g)
+			}
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b Group) int {
+		if a.Size != b.Size {
+			if a.Size > b.Size {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Files[0], b.Files[0])
+	})
+	return groups, nil
+}
+
+// distinct merges the names of files that are hard links to a file
+// earlier in files into its links
+func distinct(files []file) []file {
+	var out []file
+	for _, f := range files {
+		i := slices.IndexFunc(out, func(o file) bool { return os.SameFile(o.info, f.info) })
+		if i < 0 {
+			out = append(out, f)
+		} else {
+			out[i].links = append(out[i].links, f.path)
+		}
+	}
+	return out
+}
+
+// hashAll groups files by the hash of their first limit bytes, or of all
+// of them if limit is negative. Files that can't be read are reported and
+// left out.
+func hashAll(ctx context.Context, files []file, limit int64) (map[string][]file, error) {
+	byHash := map[string][]file{}
+	for _, f := range files {
+		hash, err := hashFile(ctx, f.path, limit)
+		if err != nil {
+			if interrupt.Interrupted(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			logging.PathError("Cannot read", f.path, err)
+			continue
+		}
+		byHash[hash] = append(byHash[hash], f)
+	}
+	return byHash, nil
+}
+
+// hashFile returns the SHA-256 of the first limit bytes of a file, or of
+// all of it if limit is negative, in hex
+func hashFile(ctx context.Context, path string, limit int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = interrupt.Reader(ctx, f)
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// wasted returns the bytes the duplicates in groups take up
+func wasted(groups []Group) int64 {
+	var n int64
+	for _, g := range groups {
+		n += g.Size * int64(len(g.Files)-1)
+	}
+	return n
+}
@@ -0,0 +1,199 @@
+package dos2unix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+)
+
+// Options holds dos2unix/unix2dos configuration
+type Options struct {
+	ToDOS     bool // unix2dos direction
+	KeepDate  bool
+	KeepBOM   bool
+	RemoveBOM bool
+	AddBOM    bool
+	Force     bool
+	Verbose   bool
+}
+
+// Command returns the dos2unix command
+func Command() *cobra.Command {
+	return newCommand(false)
+}
+
+// Unix2DosCommand returns the unix2dos command
+func Unix2DosCommand() *cobra.Command {
+	return newCommand(true)
+}
+
+// newCommand builds either direction of the conversion command
+func newCommand(toDOS bool) *cobra.Command {
+	opts := &Options{ToDOS: toDOS}
+
+	use, short, format := "dos2unix", "Convert CRLF line endings to LF", "Unix"
+	if toDOS {
+		use, short, format = "unix2dos", "Convert LF line endings to CRLF", "DOS"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use + " [flags] [files...]",
+		Short: short,
+		Long: fmt.Sprintf(`Convert text files to %s line endings, editing them in place.
+With no files, or when file is -, convert standard input to standard output.
+
+UTF-16 input (as written by PowerShell and Notepad) is converted to UTF-8.
+Binary files are skipped unless -f is given.`, format),
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if opts.KeepBOM && opts.RemoveBOM {
+				return fmt.Errorf("cannot specify both -b and -r")
+			}
+
+			files := glob.Expand(args)
+			if len(files) == 0 {
+				files = []string{input.Stdin}
+			}
+
+			for _, file := range files {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				if input.IsStdin(file) {
+					if err := convertStream(ctx, os.Stdin, os.Stdout, opts); err != nil {
+						return err
+					}
+					continue
+				}
+
+				if err := convertFile(ctx, file, opts); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.KeepDate, "keep-date", "k", false, "Keep the modification time of converted files")
+	cmd.Flags().BoolVarP(&opts.KeepBOM, "keep-bom", "b", false, "Keep a byte order mark (default for unix2dos)")
+	cmd.Flags().BoolVarP(&opts.RemoveBOM, "remove-bom", "r", false, "Remove a byte order mark (default for dos2unix)")
+	cmd.Flags().BoolVarP(&opts.AddBOM, "add-bom", "m", false, "Add a UTF-8 byte order mark")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Convert binary files too")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Explain what is being done")
+
+	return cmd
+}
+
+// convertStream converts a whole stream and writes the result
+func convertStream(ctx context.Context, in io.Reader, out io.Writer, opts *Options) error {
+	data, err := io.ReadAll(interrupt.Reader(ctx, in))
+	if err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+
+	converted, err := convert(data, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := out.Write(converted); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// convertFile converts a file in place via a temp file and rename
+func convertFile(ctx context.Context, filename string, opts *Options) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("cannot stat '%s': %w", filename, err)
+	}
+	if !info.Mode().IsRegular() {
+		logging.PathWarn("Skipping", filename, errors.New("not a regular file"))
+		return nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot read '%s': %w", filename, err)
+	}
+
+	if !opts.Force && textenc.IsBinary(data) {
+		logging.PathWarn("Skipping", filename, errors.New("binary file"))
+		return nil
+	}
+
+	converted, err := convert(data, opts)
+	if err != nil {
+		return fmt.Errorf("cannot convert '%s': %w", filename, err)
+	}
+
+	format := "Unix"
+	if opts.ToDOS {
+		format = "DOS"
+	}
+
+	if dryrun.Enabled {
+		if !bytes.Equal(data, converted) {
+			dryrun.Report("convert '%s' to %s format", filename, format)
+		}
+		return nil
+	}
+
+	if opts.Verbose {
+		fmt.Printf("converting file '%s' to %s format\n", filename, format)
+	}
+
+	if err := output.ReplaceFile(ctx, filename, info.Mode(), converted); err != nil {
+		return err
+	}
+
+	if opts.KeepDate {
+		if err := os.Chtimes(filename, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to preserve timestamps: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// convert decodes data to UTF-8, rewrites line endings and applies the
+// byte order mark policy
+func convert(data []byte, opts *Options) ([]byte, error) {
+	bom := textenc.DetectBOM(data)
+
+	decoded, err := io.ReadAll(textenc.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ToDOS {
+		decoded = textenc.ToCRLF(decoded)
+	} else {
+		decoded = textenc.ToLF(decoded)
+	}
+
+	keepBOM := opts.KeepBOM || (opts.ToDOS && !opts.RemoveBOM)
+	if opts.AddBOM || (bom != textenc.NoBOM && keepBOM) {
+		decoded = append(textenc.UTF8.Bytes(), decoded...)
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,104 @@
+package dos2unix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConvertFile_ToUnix tests in-place CRLF to LF conversion
+func TestConvertFile_ToUnix(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "dos.txt")
+	require.NoError(t, os.WriteFile(file, []byte("\xEF\xBB\xBFone\r\ntwo\r\n"), 0640))
+
+	err := convertFile(context.Background(), file, &Options{})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(content))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+// TestConvertFile_ToDOS tests in-place LF to CRLF conversion with -k
+func TestConvertFile_ToDOS(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "unix.txt")
+	require.NoError(t, os.WriteFile(file, []byte("one\ntwo\r\nthree"), 0644))
+
+	past := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(file, past, past))
+
+	err := convertFile(context.Background(), file, &Options{ToDOS: true, KeepDate: true})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "one\r\ntwo\r\nthree", string(content))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(past))
+}
+
+// TestConvertFile_UTF16 tests that UTF-16 input is rewritten as UTF-8
+func TestConvertFile_UTF16(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "utf16.txt")
+	require.NoError(t, os.WriteFile(file, []byte("\xFF\xFEh\x00i\x00\r\x00\n\x00"), 0644))
+
+	err := convertFile(context.Background(), file, &Options{})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", string(content))
+}
+
+// TestConvertFile_SkipsBinary tests that binary files are left alone unless forced
+func TestConvertFile_SkipsBinary(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "data.bin")
+	data := []byte("a\x00b\r\n")
+	require.NoError(t, os.WriteFile(file, data, 0644))
+
+	require.NoError(t, convertFile(context.Background(), file, &Options{}))
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, data, content)
+
+	require.NoError(t, convertFile(context.Background(), file, &Options{Force: true}))
+	content, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "a\x00b\n", string(content))
+}
+
+// TestConvert_BOMPolicy tests the default and explicit byte order mark handling
+func TestConvert_BOMPolicy(t *testing.T) {
+	withBOM := []byte("\xEF\xBB\xBFx\n")
+
+	out, err := convert(withBOM, &Options{ToDOS: true})
+	require.NoError(t, err)
+	assert.Equal(t, "\xEF\xBB\xBFx\r\n", string(out))
+
+	out, err = convert(withBOM, &Options{ToDOS: true, RemoveBOM: true})
+	require.NoError(t, err)
+	assert.Equal(t, "x\r\n", string(out))
+
+	out, err = convert(withBOM, &Options{KeepBOM: true})
+	require.NoError(t, err)
+	assert.Equal(t, "\xEF\xBB\xBFx\n", string(out))
+
+	out, err = convert([]byte("x\r\n"), &Options{AddBOM: true})
+	require.NoError(t, err)
+	assert.Equal(t, "\xEF\xBB\xBFx\n", string(out))
+}
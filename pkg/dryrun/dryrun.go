@@ -0,0 +1,40 @@
+package dryrun
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Enabled makes commands that modify the file system print each operation
+// instead of performing it. Set from the global --dry-run flag.
+var Enabled = false
+
+// ExitCode is the exit status of a successful dry run that found something
+// to change; 0 means there was nothing to do. It follows the convention of
+// `terraform plan -detailed-exitcode`, leaving 1 for errors.
+const ExitCode = 2
+
+// Output is where planned operations are printed
+var Output io.Writer = os.Stdout
+
+var (
+	mu      sync.Mutex
+	changes int
+)
+
+// Report prints a planned operation as "would <operation>" and counts it
+func Report(format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	changes++
+	fmt.Fprintf(Output, "would "+format+"\n", args...)
+}
+
+// Changes returns the number of operations reported so far
+func Changes() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return changes
+}
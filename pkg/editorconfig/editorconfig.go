@@ -0,0 +1,172 @@
+// Package editorconfig reads the settings .editorconfig files give a file,
+// as described at https://editorconfig.org
+package editorconfig
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// FileName is the name of the files settings are read from
+const FileName = ".editorconfig"
+
+// section is a [glob] of an .editorconfig file and the settings under it
+type section struct {
+	glob       string // doublestar pattern matched against the path below the file's directory
+	properties map[string]string
+}
+
+// file is a parsed .editorconfig file
+type file struct {
+	root     bool
+	sections []section
+}
+
+// parse reads an .editorconfig file. Property names, and the values of
+// the properties the specification defines, are lower-cased.
+func parse(r io.Reader) (*file, error) {
+	f := &file{}
+	var current *section
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			f.sections = append(f.sections, section{glob: pattern(line[1 : len(line)-1]), properties: map[string]string{}})
+			current = &f.sections[len(f.sections)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if known[key] {
+			value = strings.ToLower(value)
+		}
+		switch {
+		case current != nil:
+			current.properties[key] = value
+		case key == "root":
+			f.root = value == "true"
+		}
+	}
+	return f, scanner.Err()
+}
+
+// known are the properties whose values are case-insensitive
+var known = map[string]bool{
+	"root":                     true,
+	"indent_style":             true,
+	"indent_size":              true,
+	"tab_width":                true,
+	"end_of_line":              true,
+	"charset":                  true,
+	"trim_trailing_whitespace": true,
+	"insert_final_newline":     true,
+}
+
+// pattern returns a section's glob as a doublestar pattern: one without a
+// slash matches a name at any depth, and one with a slash is relative to
+// the directory of the file
+func pattern(glob string) string {
+	if strings.Contains(glob, "/") {
+		return strings.TrimPrefix(glob, "/")
+	}
+	return "**/" + glob
+}
+
+// Resolver finds the settings of files, reading each .editorconfig file
+// once
+type Resolver struct {
+	files map[string]*file // by directory; nil where there is none
+}
+
+// Properties returns the settings the .editorconfig files in the
+// directories above path give it. Files nearer to path, and sections
+// later in a file, take precedence; the search stops at a file with
+// root = true.
+func (r *Resolver) Properties(path string) (map[string]string, error) {
+	if r.files == nil {
+		r.files = map[string]*file{}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files from path's directory up, nearest first
+	var files []*file
+	var dirs []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		f, err := r.read(dir)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			files = append(files, f)
+			dirs = append(dirs, dir)
+			if f.root {
+				break
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	properties := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], abs)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range files[i].sections {
+			if matched, _ := doublestar.Match(s.glob, rel); matched {
+				for k, v := range s.properties {
+					properties[k] = v
+				}
+			}
+		}
+	}
+	// "unset" removes a setting made further up
+	for k, v := range properties {
+		if v == "unset" {
+			delete(properties, k)
+		}
+	}
+	return properties, nil
+}
+
+// read returns the parsed .editorconfig file in dir, or nil
+func (r *Resolver) read(dir string) (*file, error) {
+	if f, ok := r.files[dir]; ok {
+		return f, nil
+	}
+	fh, err := os.Open(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		r.files[dir] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	f, err := parse(fh)
+	if err != nil {
+		return nil, err
+	}
+	r.files[dir] = f
+	return f, nil
+}
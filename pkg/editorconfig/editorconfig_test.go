@@ -0,0 +1,51 @@
+package editorconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProperties tests nearer files and later sections taking precedence,
+// globs with and without a slash, unset, and root = true ending the search
+func TestProperties(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write("outside/.editorconfig", "[*]\nindent_style = tab\n")
+	write("outside/repo/.editorconfig", `root = true
+
+# Defaults
+[*]
+indent_style = space
+indent_size = 4
+end_of_line = LF
+
+[*.{go,mod}]
+indent_style = tab
+
+[docs/*.md]
+trim_trailing_whitespace = false
+`)
+	write("outside/repo/docs/.editorconfig", "[*.md]\nindent_size = unset\n")
+
+	var r Resolver
+	props, err := r.Properties(filepath.Join(dir, "outside/repo/main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"indent_style": "tab", "indent_size": "4", "end_of_line": "lf"}, props)
+
+	props, err = r.Properties(filepath.Join(dir, "outside/repo/docs/a.md"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"indent_style": "space", "end_of_line": "lf", "trim_trailing_whitespace": "false"}, props)
+
+	// docs/*.md is relative to the file's directory
+	props, err = r.Properties(filepath.Join(dir, "outside/repo/sub/docs/a.md"))
+	require.NoError(t, err)
+	assert.NotContains(t, props, "trim_trailing_whitespace")
+}
@@ -0,0 +1,69 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// Error is a command error that carries the process exit status to use
+// instead of the default 1
+type Error struct {
+	Code int
+	Err  error // nil when there is nothing to report
+}
+
+// Error returns the wrapped error's message
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New wraps err so the command exits with code
+func New(code int, err error) error {
+	return &Error{Code: code, Err: err}
+}
+
+// Status returns an error that only sets the exit status, for outcomes such
+// as "no lines matched" that are not worth a message. Commands returning it
+// should set SilenceErrors on their cobra.Command.
+func Status(code int) error {
+	return &Error{Code: code}
+}
+
+// From returns the exit status for err: 0 for nil, the carried code for an
+// *Error, and 1 otherwise
+func From(err error) int {
+	if err == nil {
+		return 0
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return 1
+}
+
+// Usage is a cobra flag error function that exits with status 2, the GNU
+// convention for usage errors
+func Usage(_ *cobra.Command, err error) error {
+	return New(2, err)
+}
+
+// Failed returns status 1 without a message if failed is set, for commands
+// that already reported each operand they could not process and kept going
+func Failed(cmd *cobra.Command, failed bool) error {
+	if !failed {
+		return nil
+	}
+	cmd.SilenceErrors = true
+	return Status(1)
+}
@@ -0,0 +1,59 @@
+package exitcode_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/cat"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/head"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/sort"
+	"github.com/evalgo-org/claude-tools/pkg/tail"
+	"github.com/evalgo-org/claude-tools/pkg/wc"
+)
+
+// TestFailed tests that the file-reading commands process every operand
+// and then exit with 1 if one could not be read, and with 2 on usage errors
+func TestFailed(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("b\na\n"), 0644))
+	missing := filepath.Join(dir, "missing.txt")
+
+	devNull, err := os.Create(filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	commands := map[string]func() *cobra.Command{
+		"cat":  cat.Command,
+		"head": head.Command,
+		"tail": tail.Command,
+		"wc":   wc.Command,
+		"sort": sort.Command,
+	}
+	for name, command := range commands {
+		run := func(args ...string) int {
+			cmd := command()
+			cmd.SetArgs(args)
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+			return exitcode.From(cmd.Execute())
+		}
+
+		assert.Equal(t, 0, run(input), name)
+		assert.Equal(t, 1, run(missing, input), name)
+		assert.Equal(t, 1, run(input, missing), name)
+		assert.Equal(t, 2, run("--no-such-flag", input), name)
+	}
+	require.NoError(t, output.Flush())
+}
@@ -0,0 +1,89 @@
+package filecopy
+
+import (
+	"io"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+// BufferSize is the size of the buffer each copy reads and writes with.
+// Fewer, larger writes matter most on spinning disks and network shares,
+// where every call costs a seek or a round trip.
+const BufferSize = 1 << 20
+
+// alignment is the boundary direct writes need their buffer and offsets on
+const alignment = 4096
+
+// buffers holds copy buffers for reuse, so copying many files doesn't
+// allocate a megabyte for each one
+var buffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, BufferSize+alignment)
+		return &buf
+	},
+}
+
+// aligned returns the BufferSize bytes of buf that start on an alignment
+// boundary
+func aligned(buf []byte) []byte {
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % alignment); rem != 0 {
+		off = alignment - rem
+	}
+	return buf[off : off+BufferSize]
+}
+
+// Copy copies src to dst, which is expected to be a new or truncated file,
+// and returns the number of bytes written. size is the expected length of
+// the copy, or 0 if unknown; the destination is preallocated to it where
+// the file system allows, so it isn't extended a megabyte at a time. With
+// direct, the data bypasses the page cache where the platform supports
+// it, which keeps a large copy from evicting everything else; it is
+// silently ignored elsewhere.
+func Copy(dst *os.File, src io.Reader, size int64, direct bool) (int64, error) {
+	extended := false
+	if size > 0 {
+		extended = preallocate(dst, size)
+	}
+	if direct {
+		direct = setDirect(dst, true) == nil
+	}
+
+	bufp := buffers.Get().(*[]byte)
+	defer buffers.Put(bufp)
+	buf := aligned(*bufp)
+
+	var written int64
+	for {
+		// Reading whole buffers keeps every write but the last one a
+		// multiple of the alignment, as direct writes require
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if direct && n < len(buf) {
+				if err := setDirect(dst, false); err != nil {
+					return written, err
+				}
+				direct = false
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	// The source may have shrunk since its size was taken
+	if extended && written != size {
+		if err := dst.Truncate(written); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
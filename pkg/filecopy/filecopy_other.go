@@ -0,0 +1,20 @@
+//go:build !linux
+
+package filecopy
+
+import (
+	"errors"
+	"os"
+)
+
+// preallocate sets the length of f to size up front, which lets file
+// systems such as SMB shares allocate it in one step. It reports whether
+// f was extended, so that Copy can trim it if less was written.
+func preallocate(f *os.File, size int64) bool {
+	return f.Truncate(size) == nil
+}
+
+// setDirect always fails: writes go through the page cache
+func setDirect(f *os.File, on bool) error {
+	return errors.ErrUnsupported
+}
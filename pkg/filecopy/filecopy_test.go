@@ -0,0 +1,61 @@
+package filecopy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCopy tests copies shorter than, equal to and longer than the buffer,
+// with and without a size and with a size that turns out wrong
+func TestCopy(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*BufferSize+1000)/16)
+
+	tests := []struct {
+		name   string
+		length int
+		size   int64
+		direct bool
+	}{
+		{"empty", 0, 0, false},
+		{"small", 100, 100, false},
+		{"one buffer", BufferSize, BufferSize, false},
+		{"several buffers", len(data), int64(len(data)), false},
+		{"unknown size", len(data), 0, false},
+		{"shrunk", 5000, 9000, false},
+		{"direct", len(data), int64(len(data)), true},
+		{"direct small", 100, 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := os.Create(filepath.Join(t.TempDir(), "dst"))
+			require.NoError(t, err)
+			defer dst.Close()
+
+			n, err := Copy(dst, bytes.NewReader(data[:tt.length]), tt.size, tt.direct)
+			require.NoError(t, err)
+			assert.Equal(t, int64(tt.length), n)
+			require.NoError(t, dst.Close())
+
+			got, err := os.ReadFile(dst.Name())
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data[:tt.length], got), "copy differs")
+		})
+	}
+}
+
+// TestAligned tests that copy buffers start on an alignment boundary
+func TestAligned(t *testing.T) {
+	for range 4 {
+		bufp := buffers.Get().(*[]byte)
+		buf := aligned(*bufp)
+		assert.Len(t, buf, BufferSize)
+		assert.Zero(t, uintptr(unsafe.Pointer(&buf[0]))%alignment)
+	}
+}
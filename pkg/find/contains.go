@@ -0,0 +1,99 @@
+package find
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// containsTest matches regular files with a line that match accepts. Files
+// that cannot be read are reported and do not match, and find then exits
+// with status 1 as for unreadable directories.
+func containsTest(match func(line []byte) bool) predicate {
+	return func(entry fs.DirEntry, path string, opts *Options) bool {
+		if !isRegular(entry, path, opts) {
+			return false
+		}
+		found, err := fileContains(path, match)
+		if err != nil {
+			logging.PathError("Failed to read", path, err)
+			opts.unreadable.Store(true)
+			return false
+		}
+		return found
+	}
+}
+
+// newContainsTest compiles --contains, a Go regular expression, or
+// --contains-fixed, a plain string
+func newContainsTest(flag, pattern string) (predicate, error) {
+	if flag == "contains-fixed" {
+		text := []byte(pattern)
+		return containsTest(func(line []byte) bool {
+			return bytes.Contains(line, text)
+		}), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s pattern: %w", flag, err)
+	}
+	return containsTest(re.Match), nil
+}
+
+// isRegular reports whether an entry is a regular file, or with -L a link
+// to one
+func isRegular(entry fs.DirEntry, path string, opts *Options) bool {
+	switch {
+	case entry.Type().IsRegular():
+		return true
+	case entry.Type()&fs.ModeSymlink != 0 && opts.Follow:
+		info, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		return info.Mode().IsRegular()
+	}
+	return false
+}
+
+// fileContains reports whether a line of the file at path passes match,
+// reading no further than the first one that does. Lines are passed
+// without their newline.
+func fileContains(path string, match func(line []byte) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64*1024)
+	var long []byte // a line longer than the buffer, gathered in pieces
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			long = append(long, chunk...)
+			continue
+		}
+		line := chunk
+		if len(long) > 0 {
+			line = append(long, chunk...)
+			long = long[:0]
+		}
+		if len(line) > 0 && match(bytes.TrimSuffix(line, []byte("\n"))) {
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
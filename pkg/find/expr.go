@@ -0,0 +1,455 @@
+package find
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// predicate tests one entry of the walk
+type predicate func(entry fs.DirEntry, path string, opts *Options) bool
+
+// term is an element of the expression in command-line order: a test flag
+// with its value, an operator, or a parenthesis
+type term struct {
+	flag  string // flag name without dashes, or "(", ")" or "!"
+	value string
+	pos   int // number of operands before it on the command line
+}
+
+// termFlag is a test or operator flag that records where it appeared, as
+// its position among the other tests is what gives the expression its
+// meaning. Tests also set their Options field.
+type termFlag struct {
+	name  string
+	value *string // the field of a test with a value
+	set   *bool   // the field of a test without one; nil for operators
+	opts  *Options
+	flags *pflag.FlagSet
+}
+
+func (f *termFlag) String() string {
+	switch {
+	case f.value != nil:
+		return *f.value
+	case f.set != nil:
+		return strconv.FormatBool(*f.set)
+	}
+	return "false"
+}
+
+func (f *termFlag) Set(value string) error {
+	if f.value != nil {
+		*f.value = value
+	} else {
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		if f.set != nil {
+			*f.set = on
+		}
+		if !on {
+			return nil
+		}
+	}
+	f.opts.terms = append(f.opts.terms, term{flag: f.name, value: value, pos: len(f.flags.Args())})
+	return nil
+}
+
+func (f *termFlag) Type() string {
+	if f.value == nil {
+		return "bool"
+	}
+	return "string"
+}
+
+// addTermFlags defines the tests and the operators combining them
+func addTermFlags(flags *pflag.FlagSet, opts *Options) {
+	test := func(name, short string, value *string, usage string) {
+		flags.VarP(&termFlag{name: name, value: value, opts: opts, flags: flags}, name, short, usage)
+	}
+	boolean := func(name, short string, set *bool, usage string) {
+		flags.VarPF(&termFlag{name: name, set: set, opts: opts, flags: flags}, name, short, usage).NoOptDefVal = "true"
+	}
+
+	test("name", "n", &opts.Name, "Find by name pattern (case-sensitive)")
+	test("iname", "", &opts.IName, "Find by name pattern (case-insensitive)")
+	test("path", "", &opts.Path, "Find by pattern matched against the whole path; * also matches /")
+	test("ipath", "", &opts.IPath, "Like --path, but case-insensitive")
+	test("regex", "", &opts.Regex, "Find by regular expression matching the whole path")
+	test("iregex", "", &opts.IRegex, "Like --regex, but case-insensitive")
+	test("type", "t", &opts.Type, "Find by type (f=file, d=directory, l=symlink)")
+	boolean("empty", "", &opts.Empty, "Find empty files and directories")
+	test("contains", "", &opts.Contains, "Find regular files with a line matching the regular expression `PATTERN`")
+	test("contains-fixed", "", &opts.ContainsFixed, "Find regular files with a line containing the string `TEXT`")
+	test("changed-within", "", &opts.ChangedWithin, "Find entries modified within `DURATION` (e.g. 2h, 1d) or since a date")
+	test("changed-before", "", &opts.ChangedBefore, "Find entries modified more than `DURATION` ago or before a date")
+
+	boolean("and", "a", nil, "Match only if the tests on both sides do (the default between tests)")
+	boolean("or", "o", nil, "Match if the tests on either side do")
+	boolean("not", "", nil, "Negate the test that follows (also \"!\")")
+}
+
+// splitArgs separates the paths among the operands from the parentheses and
+// "!" that belong to the expression, and puts those among the flag terms
+// in command-line order
+func splitArgs(args []string, terms []term) ([]string, []term) {
+	var paths []string
+	var merged []term
+	next := 0
+	for i := 0; i <= len(args); i++ {
+		for next < len(terms) && terms[next].pos <= i {
+			merged = append(merged, terms[next])
+			next++
+		}
+		if i == len(args) {
+			break
+		}
+		switch args[i] {
+		case "(", ")", "!":
+			merged = append(merged, term{flag: args[i]})
+		default:
+			paths = append(paths, args[i])
+		}
+	}
+	return paths, merged
+}
+
+// compileExpr builds the predicate entries must pass. Terms from the
+// command line are combined as written, with --and implied between tests;
+// otherwise every test set in opts must pass.
+func compileExpr(opts *Options) (predicate, error) {
+	if len(opts.terms) == 0 {
+		return fieldsExpr(opts)
+	}
+
+	p := &exprParser{terms: opts.terms}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("invalid expression: unexpected %s", p.terms[p.pos])
+	}
+	return expr, nil
+}
+
+// fieldsExpr ANDs the tests set in opts, cheapest first
+func fieldsExpr(opts *Options) (predicate, error) {
+	var tests []predicate
+	for _, t := range []term{
+		{flag: "type", value: opts.Type},
+		{flag: "path", value: opts.Path},
+		{flag: "ipath", value: opts.IPath},
+		{flag: "regex", value: opts.Regex},
+		{flag: "iregex", value: opts.IRegex},
+		{flag: "name", value: opts.Name},
+		{flag: "iname", value: opts.IName},
+		{flag: "changed-within", value: opts.ChangedWithin},
+		{flag: "changed-before", value: opts.ChangedBefore},
+	} {
+		if t.value == "" {
+			continue
+		}
+		test, err := newTest(t)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+	// --empty and the content tests go last as they read from disk
+	if opts.Empty {
+		tests = append(tests, isEmptyEntry)
+	}
+	for _, t := range []term{
+		{flag: "contains-fixed", value: opts.ContainsFixed},
+		{flag: "contains", value: opts.Contains},
+	} {
+		if t.value == "" {
+			continue
+		}
+		test, err := newTest(t)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+
+	if len(tests) == 0 {
+		return nil, nil
+	}
+	expr := tests[0]
+	for _, test := range tests[1:] {
+		expr = and(expr, test)
+	}
+	return expr, nil
+}
+
+// String describes a term for error messages
+func (t term) String() string {
+	switch t.flag {
+	case "(", ")", "!":
+		return fmt.Sprintf("%q", t.flag)
+	}
+	return "--" + t.flag
+}
+
+// exprParser parses terms by recursive descent. From loosest to tightest:
+// --or, --and (or nothing), then --not and "!".
+type exprParser struct {
+	terms []term
+	pos   int
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.terms)
+}
+
+func (p *exprParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.terms[p.pos].flag
+}
+
+func (p *exprParser) or() (predicate, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = or(left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) and() (predicate, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "", "or", ")":
+			return left, nil
+		case "and":
+			p.pos++
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = and(left, right)
+	}
+}
+
+func (p *exprParser) unary() (predicate, error) {
+	if p.done() {
+		return nil, fmt.Errorf("invalid expression: missing test at the end")
+	}
+	t := p.terms[p.pos]
+	p.pos++
+
+	switch t.flag {
+	case "not", "!":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(entry fs.DirEntry, path string, opts *Options) bool {
+			return !operand(entry, path, opts)
+		}, nil
+
+	case "(":
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("invalid expression: unmatched \"(\"")
+		}
+		p.pos++
+		return expr, nil
+
+	case ")", "and", "or":
+		return nil, fmt.Errorf("invalid expression: unexpected %s", t)
+	}
+	return newTest(t)
+}
+
+func and(left, right predicate) predicate {
+	return func(entry fs.DirEntry, path string, opts *Options) bool {
+		return left(entry, path, opts) && right(entry, path, opts)
+	}
+}
+
+func or(left, right predicate) predicate {
+	return func(entry fs.DirEntry, path string, opts *Options) bool {
+		return left(entry, path, opts) || right(entry, path, opts)
+	}
+}
+
+// newTest returns the predicate for a test flag
+func newTest(t term) (predicate, error) {
+	switch t.flag {
+	case "name", "iname":
+		pattern := t.value
+		fold := t.flag == "iname"
+		if fold {
+			pattern = strings.ToLower(pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid --%s pattern %q: %w", t.flag, t.value, err)
+		}
+		return func(entry fs.DirEntry, _ string, _ *Options) bool {
+			name := entry.Name()
+			if fold {
+				name = strings.ToLower(name)
+			}
+			matched, _ := filepath.Match(pattern, name)
+			return matched
+		}, nil
+
+	case "path", "ipath", "regex", "iregex":
+		re, err := compilePath(t.flag, t.value)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ fs.DirEntry, path string, _ *Options) bool {
+			return re.MatchString(filepath.ToSlash(path))
+		}, nil
+
+	case "type":
+		return typeTest(t.value), nil
+
+	case "empty":
+		return isEmptyEntry, nil
+
+	case "contains", "contains-fixed":
+		return newContainsTest(t.flag, t.value)
+
+	case "changed-within", "changed-before":
+		cutoff, err := parseTime(t.value, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s value %q: %w", t.flag, t.value, err)
+		}
+		return changedTest(cutoff, t.flag == "changed-within"), nil
+	}
+	return nil, fmt.Errorf("invalid expression: unexpected %s", t)
+}
+
+// typeTest matches entries of a type given as f, d or l
+func typeTest(kind string) predicate {
+	return func(entry fs.DirEntry, _ string, _ *Options) bool {
+		info, err := entry.Info()
+		if err != nil {
+			return false
+		}
+		switch kind {
+		case "f":
+			return info.Mode().IsRegular()
+		case "d":
+			return info.IsDir()
+		case "l":
+			return info.Mode()&os.ModeSymlink != 0
+		}
+		return true
+	}
+}
+
+// timeLayouts are the date forms --changed-within and --changed-before take
+var timeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// parseTime returns the time a --changed-* value stands for: a duration
+// back from now, which may also count days ("d") or weeks ("w"), or a date
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if d, err := parseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("not a duration such as 2h or 1d, or a date such as 2024-01-01")
+}
+
+// parseDuration parses a Go duration, or a whole number of days or weeks
+func parseDuration(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.ParseUint(n, 10, 32)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && d < 0 {
+		return 0, fmt.Errorf("negative duration")
+	}
+	return d, err
+}
+
+// changedTest matches entries modified at or after cutoff, or before it
+// if within is false
+func changedTest(cutoff time.Time, within bool) predicate {
+	return func(entry fs.DirEntry, _ string, _ *Options) bool {
+		info, err := entry.Info()
+		if err != nil {
+			return false
+		}
+		return info.ModTime().Before(cutoff) != within
+	}
+}
+
+// isEmptyEntry matches empty regular files and directories
+func isEmptyEntry(entry fs.DirEntry, path string, opts *Options) bool {
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		empty, err := isEmpty(path, opts)
+		return err == nil && empty
+	}
+	return info.Mode().IsRegular() && info.Size() == 0
+}
+
+// compilePath compiles a whole-path pattern. Like GNU find, it must match
+// the entire path as printed (e.g. "./src/a.go" below "."), with "/" as the
+// separator on every platform.
+func compilePath(flag, pattern string) (*regexp.Regexp, error) {
+	expr := pattern
+	if flag == "path" || flag == "ipath" {
+		expr = "(?s)" + globExpr(expr)
+	}
+	if flag == "ipath" || flag == "iregex" {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s pattern: %w", flag, err)
+	}
+	return re, nil
+}
@@ -1,22 +1,65 @@
 package find
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync/atomic"
+	"syscall"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/largest"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
 )
 
 // Options holds find configuration
 type Options struct {
-	Name     string
-	IName    string
-	Type     string
-	MaxDepth int
-	MinDepth int
+	Name       string
+	IName      string
+	Path       string
+	IPath      string
+	Regex      string
+	IRegex     string
+	Type       string
+	ExcludeDir []string // directory names not descended into
+	Prune      []string // path patterns not printed or descended into
+	MaxDepth   int
+	MinDepth   int
+	Empty      bool // only zero-byte files and empty directories
+
+	Contains      string // regular files with a line matching a regular expression
+	ContainsFixed string // regular files with a line containing a string
+
+	ChangedWithin string // modified within a duration of now, or since a date
+	ChangedBefore string // modified longer ago than a duration, or before a date
+
+	Delete     bool // remove matches instead of printing them
+	DepthFirst bool // list directories after their contents
+	Follow     bool
+	Gitignore  bool // honor .gitignore and .ignore files
+	Jobs       int  // directories searched concurrently; 0 means one per CPU
+	Unordered  bool // print matches as they are found
+	TopSizes   int  // print only the N largest matches, directories by the size of their contents
+	Print0     bool // end printed paths in NUL rather than newline
+
+	terms   []term           // tests and operators in command-line order
+	expr    predicate        // compiled tests; nil matches everything
+	prune   []*regexp.Regexp // compiled --prune
+	removed map[string]bool  // paths --delete would have removed under --dry-run
+	top     *largest.Tracker // sizes seen by --top-sizes
+
+	unreadable atomic.Bool // a file --contains had to read could not be
 }
 
 // Command returns the find command
@@ -26,111 +69,401 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "find [path...] [flags]",
 		Short: "Find files and directories",
-		Long:  `Find files and directories by name, type, or other criteria.`,
-		Args:  cobra.MinimumNArgs(0),
+		Long: `Find files and directories by name, type, or other criteria.
+
+Tests such as --name, --path and --type must all match unless combined
+with --or, --not (or "!") and parentheses, which bind in that order from
+loosest to tightest as in GNU find:
+
+  find . \( --name '*.go' --or --name '*.md' \) --not --path '*vendor*'
+
+Directories are searched concurrently (see --jobs), and matches are still
+printed in the same order as a sequential search would print them. With
+--unordered they are printed as soon as they are found instead, which gives
+the first results sooner on large or slow file systems.
+
+--contains and --contains-fixed match regular files by their contents, a
+line at a time, reading each file only as far as its first matching line.
+Put them after cheaper tests so that only the files passing those are read:
+
+  find . --name '*.go' --contains 'TODO|FIXME'
+  find src --type f --contains-fixed 'os.Exit('
+
+--changed-within and --changed-before compare modification times with a
+duration back from now (30m, 2h, 1d, 2w, or any Go duration such as 1h30m)
+or a date (2024-01-01, "2024-01-01 12:00", or RFC 3339) in local time:
+
+  find . --changed-within 2h --type f
+  find /var/log --changed-before 2024-01-01
+
+Depths count from the starting points as in GNU find: they are at depth 0
+and listed like any other match, --maxdepth 1 adds only the entries
+directly inside them, --mindepth 1 leaves them out, and the walk never goes
+deeper than --maxdepth. --depth lists every directory after its contents.
+
+--top-sizes N prints the N largest matches instead of all of them, with
+directories ranked by the total size of the regular files below them.
+--maxdepth then only limits which entries are ranked; the walk still goes
+all the way down so that directory totals are complete:
+
+  find . --maxdepth 2 --top-sizes 10
+  find /var --type f --top-sizes 5
+
+--delete removes every match instead of printing it. It implies --depth, so
+"find . --empty --delete" also removes directories that only become empty
+as the walk goes; "." itself is never removed. Searches with --depth or
+--delete are sequential.`,
+		Args: cobra.MinimumNArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			paths := args
+			paths, terms := splitArgs(args, opts.terms)
+			opts.terms = terms
 			if len(paths) == 0 {
 				paths = []string{"."}
 			}
 
+			if err := compilePatterns(opts); err != nil {
+				return err
+			}
+
+			if opts.Delete && opts.Follow {
+				return fmt.Errorf("--delete cannot be used with -L")
+			}
+			if opts.TopSizes < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --top-sizes %d", opts.TopSizes))
+			}
+			if opts.TopSizes > 0 {
+				if opts.Delete {
+					return exitcode.New(2, fmt.Errorf("--top-sizes cannot be used with --delete"))
+				}
+				opts.top = largest.New(opts.TopSizes)
+			}
+			opts.removed = map[string]bool{}
+
+			walker := &walk.Walker{Ignore: true, Gitignore: opts.Gitignore, FollowLinks: opts.Follow, Jobs: opts.Jobs}
+			failed := false
 			for _, path := range paths {
-				if err := findPath(path, opts, 0); err != nil {
-					eve.Logger.Error("Failed to search path", path, ":", err)
+				ok, err := findPath(cmd.Context(), path, opts, walker)
+				if !ok {
+					failed = true
+				}
+				if err != nil {
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					logging.PathError("Failed to search path", path, err)
+					failed = true
+				}
+			}
+
+			if opts.top != nil {
+				if err := opts.top.Write(output.Stdout); err != nil {
+					return err
 				}
 			}
 
+			// Like GNU find, exit 1 when some of the tree could not be searched
+			if failed || walker.Failed() || opts.unreadable.Load() {
+				cmd.SilenceErrors = true
+				return exitcode.Status(1)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "Find by name pattern (case-sensitive)")
-	cmd.Flags().StringVar(&opts.IName, "iname", "", "Find by name pattern (case-insensitive)")
-	cmd.Flags().StringVarP(&opts.Type, "type", "t", "", "Find by type (f=file, d=directory, l=symlink)")
-	cmd.Flags().IntVar(&opts.MaxDepth, "maxdepth", -1, "Maximum depth to search")
-	cmd.Flags().IntVar(&opts.MinDepth, "mindepth", 0, "Minimum depth to search")
+	addTermFlags(cmd.Flags(), opts)
+	cmd.Flags().StringArrayVar(&opts.ExcludeDir, "exclude-dir", nil, "Don't descend into directories whose name matches `GLOB` (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.Prune, "prune", nil, "Skip paths matching `GLOB` like --path, without descending into them (repeatable)")
+	cmd.Flags().IntVar(&opts.MaxDepth, "maxdepth", -1, "Descend at most `N` levels below the starting points (1 lists only their entries)")
+	cmd.Flags().IntVar(&opts.MinDepth, "mindepth", 0, "Don't list entries less than `N` levels below the starting points")
+	cmd.Flags().BoolVar(&opts.DepthFirst, "depth", false, "List the contents of each directory before the directory itself")
+	cmd.Flags().BoolVar(&opts.Delete, "delete", false, "Delete matches, directories after their contents")
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "L", false, "Follow symbolic links to directories")
+	cmd.Flags().BoolVar(&opts.Gitignore, "gitignore", false, "Skip paths ignored by .gitignore and .ignore files")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Search up to `N` directories concurrently (default: number of CPUs)")
+	cmd.Flags().BoolVar(&opts.Unordered, "unordered", false, "Print matches as soon as they are found rather than in walk order")
+	cmd.Flags().BoolVarP(&opts.Print0, "print0", "0", false, "End each path with a NUL byte instead of a newline, for xargs -0 and sort -z")
+	cmd.Flags().IntVar(&opts.TopSizes, "top-sizes", 0, "Print only the `N` largest matches, directories by the size of their contents")
 
 	return cmd
 }
 
-// findPath recursively searches a path
-func findPath(root string, opts *Options, depth int) error {
-	// Check depth constraints
-	if opts.MaxDepth >= 0 && depth > opts.MaxDepth {
-		return nil
+// visit is a directory whose action waits until its contents are done
+type visit struct {
+	path  string
+	entry fs.DirEntry
+	depth int
+}
+
+// findPath searches root and the tree below it, reporting whether every
+// match could be acted on. As in GNU find, root is at depth 0 and the
+// entries directly inside it at depth 1.
+func findPath(ctx context.Context, root string, opts *Options, w *walk.Walker) (bool, error) {
+	// Options built in code rather than by Command may not be compiled yet
+	if opts.expr == nil {
+		if err := compilePatterns(opts); err != nil {
+			return false, err
+		}
+	}
+	if opts.top != nil {
+		return true, topSizes(ctx, root, opts, w)
+	}
+	if opts.Delete || opts.DepthFirst {
+		return postOrder(ctx, root, opts, w)
 	}
 
-	entries, err := os.ReadDir(root)
-	if err != nil {
-		return fmt.Errorf("failed to read directory: %w", err)
+	err := w.WalkParallel(ctx, root, opts.Unordered, func(path string, entry fs.DirEntry, depth int) (string, error) {
+		path = displayPath(root, path)
+		if skip, err := excluded(entry, path, opts, depth); skip {
+			return "", err
+		}
+
+		var match string
+		if shouldPrint(entry, path, opts, depth) {
+			match = path
+		}
+		return match, descend(entry, opts, depth)
+	}, func(path string) error {
+		return printPath(path, opts)
+	})
+	return true, err
+}
+
+// postOrder acts on the matches below root with every directory after its
+// contents, as --depth asks and --delete needs, reporting whether all of
+// them could be removed. The walk is sequential so that the order holds.
+func postOrder(ctx context.Context, root string, opts *Options, w *walk.Walker) (bool, error) {
+	ok := true
+	act := func(path string, entry fs.DirEntry, depth int) {
+		if !shouldPrint(entry, path, opts, depth) {
+			return
+		}
+		if !opts.Delete {
+			printPath(path, opts)
+			return
+		}
+		// Like GNU find, leave the current directory in place
+		if depth == 0 && filepath.Clean(path) == "." {
+			return
+		}
+		if err := remove(path, opts); err != nil {
+			logging.PathError("Failed to delete", path, err)
+			ok = false
+		}
 	}
 
-	for _, entry := range entries {
-		fullPath := filepath.Join(root, entry.Name())
+	// The walk visits directories before their contents. A directory is
+	// acted on once the walk has left it instead, which is when an entry at
+	// the same depth or above comes along.
+	var pending []visit
+	leave := func(depth int) {
+		for len(pending) > 0 && pending[len(pending)-1].depth >= depth {
+			v := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			act(v.path, v.entry, v.depth)
+		}
+	}
 
-		// Check if this entry matches our criteria
-		if shouldPrint(entry, fullPath, opts, depth) {
-			fmt.Println(fullPath)
+	err := w.Walk(ctx, root, func(path string, entry fs.DirEntry, depth int) error {
+		path = displayPath(root, path)
+		leave(depth)
+		if skip, err := excluded(entry, path, opts, depth); skip {
+			return err
 		}
 
-		// Recurse into directories
 		if entry.IsDir() {
-			if err := findPath(fullPath, opts, depth+1); err != nil {
-				eve.Logger.Error("Failed to search directory", fullPath, ":", err)
-			}
+			pending = append(pending, visit{path, entry, depth})
+		} else {
+			act(path, entry, depth)
 		}
+		return descend(entry, opts, depth)
+	})
+	if err == nil {
+		leave(0)
 	}
+	return ok, err
+}
+
+// excluded reports whether an entry is pruned, in which case it is neither
+// listed nor descended into, with the error that tells the walk so
+func excluded(entry fs.DirEntry, path string, opts *Options, depth int) (bool, error) {
+	switch {
+	case !pruned(entry, path, opts):
+		return false, nil
+	case depth == 0:
+		return true, fs.SkipAll
+	case entry.IsDir():
+		return true, fs.SkipDir
+	}
+	return true, nil
+}
+
+// descend tells the walk not to go below --maxdepth
+func descend(entry fs.DirEntry, opts *Options, depth int) error {
+	if entry.IsDir() && opts.MaxDepth >= 0 && depth >= opts.MaxDepth {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// printPath prints a match, ended as --print0 says
+func printPath(path string, opts *Options) error {
+	end := "\n"
+	if opts.Print0 {
+		end = "\x00"
+	}
+	_, err := io.WriteString(output.Stdout, path+end)
+	return err
+}
 
+// remove deletes a match, or reports what would be deleted under --dry-run.
+// Like GNU find, directories must be empty by then.
+func remove(path string, opts *Options) error {
+	if !dryrun.Enabled {
+		return os.Remove(path)
+	}
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		if empty, err := isEmpty(path, opts); err != nil || !empty {
+			return &fs.PathError{Op: "remove", Path: path, Err: syscall.ENOTEMPTY}
+		}
+	}
+	dryrun.Report("remove '%s'", path)
+	opts.removed[filepath.Clean(path)] = true
 	return nil
 }
 
+// isEmpty reports whether the directory at path has no entries, counting
+// those --dry-run pretended to delete as gone
+func isEmpty(path string, opts *Options) (bool, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if !opts.removed[filepath.Join(path, entry.Name())] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// displayPath returns path, found below root, the way GNU find prints and
+// matches it: starting with root as given, so "./a" below "." and
+// "./src/a" below "./src", where the walk has "a" and "src/a"
+func displayPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return root
+	}
+	if os.IsPathSeparator(root[len(root)-1]) {
+		return root + rel
+	}
+	return root + string(filepath.Separator) + rel
+}
+
+// pruned reports whether an entry is excluded by --exclude-dir or --prune,
+// which also keeps the walk out of it
+func pruned(entry fs.DirEntry, path string, opts *Options) bool {
+	if entry.IsDir() {
+		for _, pattern := range opts.ExcludeDir {
+			if matched, _ := filepath.Match(pattern, entry.Name()); matched {
+				return true
+			}
+		}
+	}
+	for _, re := range opts.prune {
+		if re.MatchString(filepath.ToSlash(path)) {
+			return true
+		}
+	}
+	return false
+}
+
 // shouldPrint determines if an entry should be printed
 func shouldPrint(entry os.DirEntry, path string, opts *Options, depth int) bool {
-	// Check minimum depth
 	if depth < opts.MinDepth {
 		return false
 	}
+	return opts.expr == nil || opts.expr(entry, path, opts)
+}
 
-	// Check type filter
-	if opts.Type != "" {
-		info, err := entry.Info()
+// compilePatterns checks the --exclude-dir patterns and compiles --prune
+// and the expression
+func compilePatterns(opts *Options) error {
+	opts.prune = nil
+
+	for _, pattern := range opts.ExcludeDir {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --exclude-dir pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range opts.Prune {
+		re, err := regexp.Compile("^(?s:" + globExpr(pattern) + ")$")
 		if err != nil {
-			return false
+			return fmt.Errorf("invalid --prune pattern %q: %w", pattern, err)
 		}
+		opts.prune = append(opts.prune, re)
+	}
+
+	expr, err := compileExpr(opts)
+	if err != nil {
+		return err
+	}
+	opts.expr = expr
+	return nil
+}
 
-		switch opts.Type {
-		case "f":
-			if !info.Mode().IsRegular() {
-				return false
+// globExpr translates a shell pattern into a regular expression in which,
+// as for find -path, "*" and "?" match "/" too
+func globExpr(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				b.WriteString(`\\`)
 			}
-		case "d":
-			if !info.IsDir() {
-				return false
+		case '[':
+			end := classEnd(pattern, i)
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
 			}
-		case "l":
-			if info.Mode()&os.ModeSymlink == 0 {
-				return false
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
 			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
 		}
 	}
+	return b.String()
+}
 
-	// Check name filter (case-sensitive)
-	if opts.Name != "" {
-		matched, err := filepath.Match(opts.Name, entry.Name())
-		if err != nil || !matched {
-			return false
-		}
+// classEnd returns the index of the "]" closing the bracket expression that
+// starts at pattern[start], or -1 if it is not closed. A "]" right after
+// "[" or "[!" is a member of the class.
+func classEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '!' {
+		i++
 	}
-
-	// Check name filter (case-insensitive)
-	if opts.IName != "" {
-		pattern := strings.ToLower(opts.IName)
-		name := strings.ToLower(entry.Name())
-		matched, err := filepath.Match(pattern, name)
-		if err != nil || !matched {
-			return false
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		if pattern[i] == ']' {
+			return i
 		}
 	}
-
-	return true
+	return -1
 }
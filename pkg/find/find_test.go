@@ -0,0 +1,379 @@
+package find
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/largest"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// matches reports whether a regular file at path passes the filters
+func matches(t *testing.T, opts *Options, path string) bool {
+	require.NoError(t, compilePatterns(opts))
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, filepath.Base(path))
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+
+	return shouldPrint(fakeEntry{info}, path, opts, 0)
+}
+
+// fakeEntry adapts os.FileInfo to fs.DirEntry
+type fakeEntry struct{ os.FileInfo }
+
+func (e fakeEntry) Type() os.FileMode          { return e.Mode().Type() }
+func (e fakeEntry) Info() (os.FileInfo, error) { return e.FileInfo, nil }
+
+// TestShouldPrint_Path tests --path and --ipath, whose wildcards cross
+// directory boundaries
+func TestShouldPrint_Path(t *testing.T) {
+	assert.True(t, matches(t, &Options{Path: "src/*.go"}, "src/a/b.go"))
+	assert.False(t, matches(t, &Options{Path: "src/*.go"}, "lib/src/b.go"))
+	assert.True(t, matches(t, &Options{Path: "*/test?/[!.]*"}, "x/tests/a.txt"))
+	assert.False(t, matches(t, &Options{Path: "*/test?/[!.]*"}, "x/tests/.a"))
+	assert.True(t, matches(t, &Options{Path: `a\*b`}, "a*b"))
+	assert.False(t, matches(t, &Options{Path: `a\*b`}, "axb"))
+	assert.True(t, matches(t, &Options{IPath: "SRC/*"}, "src/Main.go"))
+}
+
+// TestShouldPrint_Regex tests that --regex must match the whole path
+func TestShouldPrint_Regex(t *testing.T) {
+	opts := &Options{Regex: `.*/testdata/.*\.json`}
+	assert.True(t, matches(t, opts, "pkg/jq/testdata/in.json"))
+	assert.False(t, matches(t, opts, "pkg/jq/testdata/in.json.bak"))
+
+	assert.False(t, matches(t, &Options{Regex: "a"}, "ab"))
+	assert.True(t, matches(t, &Options{IRegex: "README\\.MD"}, "readme.md"))
+
+	// Combined with the name filter, both must match
+	assert.False(t, matches(t, &Options{Regex: ".*\\.json", Name: "x*"}, "dir/in.json"))
+}
+
+// TestCompilePatterns_Invalid tests reporting a bad expression
+func TestCompilePatterns_Invalid(t *testing.T) {
+	err := compilePatterns(&Options{Regex: "("})
+	assert.ErrorContains(t, err, "invalid --regex pattern")
+}
+
+// TestDisplayPath tests that paths keep the starting point as given
+func TestDisplayPath(t *testing.T) {
+	sep := string(filepath.Separator)
+	assert.Equal(t, "."+sep+"a", displayPath(".", "a"))
+	assert.Equal(t, "."+sep+"src"+sep+"a", displayPath("."+sep+"src", filepath.Join("src", "a")))
+	assert.Equal(t, "dir"+sep+"a", displayPath("dir"+sep, filepath.Join("dir", "a")))
+	assert.Equal(t, ".", displayPath(".", "."))
+}
+
+// TestFindPath_DotRoot tests that below "." the printed paths, and the
+// ones --regex matches, start with "./" as in GNU find
+func TestFindPath_DotRoot(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Join("pkg", "testdata"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("pkg", "testdata", "in.json"), nil, 0644))
+	require.NoError(t, os.WriteFile("top.json", nil, 0644))
+
+	opts := &Options{Regex: `.*/testdata/.*\.json`, MaxDepth: -1}
+	require.NoError(t, compilePatterns(opts))
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	_, err = findPath(context.Background(), ".", opts, &walk.Walker{})
+	require.NoError(t, output.Flush())
+	os.Stdout = stdout
+	require.NoError(t, err)
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "."+string(filepath.Separator)+filepath.Join("pkg", "testdata", "in.json")+"\n", string(printed))
+}
+
+// makeEmptyTree creates a tree where "a/b" only becomes empty once its
+// empty file is gone, next to a directory that stays
+func makeEmptyTree(t *testing.T) string {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "keep"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "empty.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keep", "data.txt"), []byte("x"), 0644))
+	return root
+}
+
+// TestFindPath_EmptyDelete tests that --delete acts on directories after
+// their contents, so nested directories empty out in one pass
+func TestFindPath_EmptyDelete(t *testing.T) {
+	root := makeEmptyTree(t)
+
+	opts := &Options{MaxDepth: -1, Empty: true, Delete: true, removed: map[string]bool{}}
+	ok, err := findPath(context.Background(), root, opts, &walk.Walker{})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, output.Flush())
+
+	assert.NoDirExists(t, filepath.Join(root, "a"))
+	assert.FileExists(t, filepath.Join(root, "keep", "data.txt"))
+}
+
+// TestFindPath_DeleteDryRun tests that --dry-run reports the same removals
+// without making them
+func TestFindPath_DeleteDryRun(t *testing.T) {
+	root := makeEmptyTree(t)
+
+	var out bytes.Buffer
+	dryrun.Enabled, dryrun.Output = true, &out
+	defer func() { dryrun.Enabled, dryrun.Output = false, os.Stdout }()
+
+	opts := &Options{MaxDepth: -1, Empty: true, Delete: true, removed: map[string]bool{}}
+	ok, err := findPath(context.Background(), root, opts, &walk.Walker{})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, output.Flush())
+
+	assert.Equal(t, fmt.Sprintf("would remove '%s'\nwould remove '%s'\nwould remove '%s'\n",
+		filepath.Join(root, "a", "b", "empty.txt"), filepath.Join(root, "a", "b"), filepath.Join(root, "a")), out.String())
+	assert.FileExists(t, filepath.Join(root, "a", "b", "empty.txt"))
+}
+
+// runFind runs findPath on root and returns the printed paths relative to
+// it, with "/" separators
+func runFind(t *testing.T, root string, opts *Options) []string {
+	var paths []string
+	for _, line := range strings.Fields(findOutput(t, root, opts)) {
+		rel, err := filepath.Rel(root, line)
+		require.NoError(t, err)
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths
+}
+
+// findOutput searches root and returns what was printed
+func findOutput(t *testing.T, root string, opts *Options) string {
+	require.NoError(t, compilePatterns(opts))
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	ok, err := findPath(context.Background(), root, opts, &walk.Walker{})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed)
+}
+
+// TestFindPath_Prune tests that excluded directories are neither printed
+// nor descended into
+func TestFindPath_Prune(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git/objects", "node_modules/pkg", "src/vendor/lib", "lib/vendor"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src", "main.go"), nil, 0644))
+
+	paths := runFind(t, root, &Options{MaxDepth: -1, ExcludeDir: []string{".git", "node_*"}})
+	assert.Equal(t, []string{".", "lib", "lib/vendor", "src", "src/main.go", "src/vendor", "src/vendor/lib"}, paths)
+
+	paths = runFind(t, root, &Options{MaxDepth: -1, ExcludeDir: []string{".git", "node_*"}, Prune: []string{"*/src/vendor"}})
+	assert.Equal(t, []string{".", "lib", "lib/vendor", "src", "src/main.go"}, paths)
+
+	assert.Error(t, compilePatterns(&Options{ExcludeDir: []string{"["}}))
+}
+
+// parseExpr parses command-line arguments as Command does, returning the
+// options and the paths among the operands
+func parseExpr(t *testing.T, args ...string) (*Options, []string) {
+	opts := &Options{MaxDepth: -1}
+	flags := pflag.NewFlagSet("find", pflag.ContinueOnError)
+	addTermFlags(flags, opts)
+	require.NoError(t, flags.Parse(args))
+
+	paths, terms := splitArgs(flags.Args(), opts.terms)
+	opts.terms = terms
+	return opts, paths
+}
+
+// TestShouldPrint_Expression tests combining tests with --or, --not, "!"
+// and parentheses, in command-line order
+func TestShouldPrint_Expression(t *testing.T) {
+	opts, paths := parseExpr(t, ".", "(", "--name", "*.go", "--or", "--name", "*.md", ")", "--not", "--path", "*vendor*")
+	assert.Equal(t, []string{"."}, paths)
+	assert.True(t, matches(t, opts, "src/a.go"))
+	assert.True(t, matches(t, opts, "README.md"))
+	assert.False(t, matches(t, opts, "vendor/x/b.go"))
+	assert.False(t, matches(t, opts, "a.txt"))
+
+	// --and binds tighter than --or
+	opts, _ = parseExpr(t, "--name", "a*", "--or", "--name", "*.go", "--and", "--path", "src/*")
+	assert.True(t, matches(t, opts, "lib/a.txt"))
+	assert.True(t, matches(t, opts, "src/b.go"))
+	assert.False(t, matches(t, opts, "lib/b.go"))
+
+	opts, _ = parseExpr(t, "!", "--name", "*.go", "-t", "f")
+	assert.True(t, matches(t, opts, "a.txt"))
+	assert.False(t, matches(t, opts, "a.go"))
+}
+
+// TestCompileExpr_Invalid tests that malformed expressions are rejected
+func TestCompileExpr_Invalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"(", "--name", "a"},
+		{"--name", "a", ")"},
+		{"--or", "--name", "a"},
+		{"--name", "a", "--or"},
+		{"--not"},
+	} {
+		opts, _ := parseExpr(t, args...)
+		assert.Error(t, compilePatterns(opts), strings.Join(args, " "))
+	}
+}
+
+// TestFindPath_Depth tests that depths count from the starting point, which
+// is listed too, that --maxdepth stops the walk and that --depth lists
+// directories last
+func TestFindPath_Depth(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "c", "deep.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "top.txt"), nil, 0644))
+
+	assert.Equal(t, []string{".", "a", "top.txt"}, runFind(t, root, &Options{MaxDepth: 1}))
+	assert.Equal(t, []string{"a", "top.txt"}, runFind(t, root, &Options{MaxDepth: 1, MinDepth: 1}))
+	assert.Equal(t, []string{"a/b"}, runFind(t, root, &Options{MaxDepth: 2, MinDepth: 2}))
+	assert.Equal(t, []string{"."}, runFind(t, root, &Options{MaxDepth: 0}))
+	assert.Equal(t, []string{"a/b/c/deep.txt", "a/b/c", "a/b", "a", "top.txt", "."}, runFind(t, root, &Options{MaxDepth: -1, DepthFirst: true}))
+	assert.Equal(t, []string{"a/b", "a", "."}, runFind(t, root, &Options{MaxDepth: 2, DepthFirst: true, Type: "d"}))
+
+	// A file named as the starting point is at depth 0
+	out := runFind(t, filepath.Join(root, "top.txt"), &Options{MaxDepth: 0})
+	assert.Equal(t, []string{"."}, out)
+}
+
+// TestFindPath_DotDepth tests that "." is listed like GNU find lists it,
+// with --maxdepth and after its contents with --depth, but never deleted
+func TestFindPath_DotDepth(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.Mkdir("a", 0755))
+	sep := string(filepath.Separator)
+
+	assert.Equal(t, ".\n."+sep+"a\n", findOutput(t, ".", &Options{MaxDepth: 1}))
+	assert.Equal(t, "."+sep+"a\n.\n", findOutput(t, ".", &Options{MaxDepth: -1, DepthFirst: true}))
+
+	opts := &Options{MaxDepth: -1, Empty: true, Delete: true, removed: map[string]bool{}}
+	assert.Empty(t, findOutput(t, ".", opts))
+	assert.NoDirExists(t, "a")
+	assert.DirExists(t, ".")
+}
+
+// TestShouldPrint_Changed tests --changed-within and --changed-before with
+// durations and dates
+func TestShouldPrint_Changed(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "old.log")
+	recent := filepath.Join(root, "recent.log")
+	require.NoError(t, os.WriteFile(old, nil, 0644))
+	require.NoError(t, os.WriteFile(recent, nil, 0644))
+	stamp := time.Date(2023, 6, 1, 12, 0, 0, 0, time.Local)
+	require.NoError(t, os.Chtimes(old, stamp, stamp))
+	hourAgo := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(recent, hourAgo, hourAgo))
+
+	check := func(opts *Options, path string) bool {
+		require.NoError(t, compilePatterns(opts))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		return shouldPrint(fakeEntry{info}, path, opts, 0)
+	}
+
+	assert.True(t, check(&Options{ChangedWithin: "2h"}, recent))
+	assert.False(t, check(&Options{ChangedWithin: "30m"}, recent))
+	assert.False(t, check(&Options{ChangedWithin: "1w"}, old))
+	assert.True(t, check(&Options{ChangedBefore: "1d"}, old))
+	assert.False(t, check(&Options{ChangedBefore: "1d"}, recent))
+	assert.True(t, check(&Options{ChangedBefore: "2024-01-01"}, old))
+	assert.True(t, check(&Options{ChangedWithin: "2023-06-01 11:00"}, old))
+	assert.False(t, check(&Options{ChangedWithin: "2023-06-01T12:00:01"}, old))
+
+	// Together they give a range
+	opts, _ := parseExpr(t, "--changed-within", "2023-01-01", "--changed-before", "2023-12-31")
+	assert.True(t, check(opts, old))
+	assert.False(t, check(opts, recent))
+
+	for _, value := range []string{"yesterday", "-2h", "1.5d", "2024-13-01"} {
+		assert.ErrorContains(t, compilePatterns(&Options{ChangedWithin: value}), "invalid --changed-within value", value)
+	}
+}
+
+// TestFindPath_Contains tests --contains and --contains-fixed, which only
+// match regular files and read them a line at a time
+func TestFindPath_Contains(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "todo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n// TODO: split\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.go"), []byte("package b\n"), 0644))
+	long := strings.Repeat("x", 200*1024) + "needle"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "long.txt"), []byte(long), 0644))
+
+	paths := runFind(t, root, &Options{MaxDepth: -1, Contains: `TODO|needle$`})
+	assert.Equal(t, []string{"a.go", "long.txt"}, paths)
+
+	paths = runFind(t, root, &Options{MaxDepth: -1, Name: "*.go", ContainsFixed: "package"})
+	assert.Equal(t, []string{"a.go", "b.go"}, paths)
+
+	opts, _ := parseExpr(t, "--not", "--contains-fixed", "TODO", "--type", "f")
+	assert.Equal(t, []string{"b.go", "long.txt"}, runFind(t, root, opts))
+
+	assert.Error(t, compilePatterns(&Options{Contains: "("}))
+}
+
+// TestFindPath_TopSizes tests that --top-sizes ranks the matches within
+// --maxdepth while directories total everything below them
+func TestFindPath_TopSizes(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "big"), make([]byte, 500), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "one"), make([]byte, 10), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "mid"), make([]byte, 300), 0644))
+
+	top := func(opts *Options) []string {
+		require.NoError(t, compilePatterns(opts))
+		opts.top = largest.New(opts.TopSizes)
+		_, err := findPath(context.Background(), root, opts, &walk.Walker{})
+		require.NoError(t, err)
+
+		var ranked []string
+		for _, e := range opts.top.Largest() {
+			rel, err := filepath.Rel(root, e.Path)
+			require.NoError(t, err)
+			ranked = append(ranked, fmt.Sprintf("%s %d", filepath.ToSlash(rel), e.Size))
+		}
+		return ranked
+	}
+
+	assert.Equal(t, []string{"a 510", "a/b 500", "a/b/big 500"}, top(&Options{MaxDepth: -1, TopSizes: 3}))
+	assert.Equal(t, []string{"a 510", "mid 300"}, top(&Options{MaxDepth: 1, TopSizes: 5}))
+	assert.Equal(t, []string{"a/b/big 500", "mid 300", "a/one 10"}, top(&Options{MaxDepth: -1, Type: "f", TopSizes: 5}))
+}
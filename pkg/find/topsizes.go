@@ -0,0 +1,53 @@
+package find
+
+import (
+	"context"
+	"io/fs"
+	"os"
+
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// topSizes walks the whole tree below root for --top-sizes, adding every
+// regular file to the totals of the directories above it and ranking the
+// matches within --maxdepth. Nothing is printed until every starting
+// point has been walked.
+func topSizes(ctx context.Context, root string, opts *Options, w *walk.Walker) error {
+	return w.WalkParallel(ctx, root, true, func(path string, entry fs.DirEntry, depth int) (string, error) {
+		if skip, err := excluded(entry, path, opts, depth); skip {
+			return "", err
+		}
+
+		within := opts.MaxDepth < 0 || depth <= opts.MaxDepth
+		rank := within && (depth > 0 || !entry.IsDir()) && shouldPrint(entry, path, opts, depth)
+		if entry.IsDir() {
+			if rank {
+				opts.top.Dir(path)
+			}
+			return "", nil
+		}
+		if size, ok := regularSize(entry, path, opts); ok {
+			opts.top.File(root, path, size, rank)
+		}
+		return "", nil
+	}, func(string) error { return nil })
+}
+
+// regularSize returns the size of a regular file, or with -L of the file
+// a link points to, and false for anything else
+func regularSize(entry fs.DirEntry, path string, opts *Options) (int64, bool) {
+	var info fs.FileInfo
+	var err error
+	switch {
+	case entry.Type().IsRegular():
+		info, err = entry.Info()
+	case entry.Type()&fs.ModeSymlink != 0 && opts.Follow:
+		info, err = os.Stat(path)
+	default:
+		return 0, false
+	}
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	return info.Size(), true
+}
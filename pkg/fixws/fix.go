@@ -0,0 +1,118 @@
+package fixws
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Settings says what fix does to a file
+type Settings struct {
+	EOL          string // "lf" or "crlf"; "" for the one the file uses most
+	Trim         bool   // remove white space at the end of lines
+	FinalNewline bool   // end a file that isn't empty with a line break
+	Indent       string // "tab" or "space" to convert indentation to; "" to leave it
+	TabWidth     int    // columns a tab stands for
+}
+
+// fix returns data with settings applied, and what was changed, in the
+// words --check prints
+func fix(data []byte, s Settings) ([]byte, []string) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	hasFinal := data[len(data)-1] == '\n'
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+
+	// The lines that end in a line break, and how many of those are CRLF
+	ends := len(lines)
+	if !hasFinal {
+		ends--
+	}
+	crlf := 0
+	for _, line := range lines[:ends] {
+		if bytes.HasSuffix(line, []byte("\r")) {
+			crlf++
+		}
+	}
+	eol := s.EOL
+	if eol == "" {
+		eol = "lf"
+		if crlf*2 > ends {
+			eol = "crlf"
+		}
+	}
+	sep := []byte("\n")
+	if eol == "crlf" {
+		sep = []byte("\r\n")
+	}
+
+	var changed struct{ eol, trim, final, indent bool }
+	if (eol == "lf" && crlf > 0) || (eol == "crlf" && crlf < ends) {
+		changed.eol = true
+	}
+
+	out := make([]byte, 0, len(data)+len(lines))
+	for i, line := range lines {
+		ended := i < ends
+		if ended {
+			line = bytes.TrimSuffix(line, []byte("\r"))
+		}
+		if s.Trim {
+			trimmed := bytes.TrimRight(line, " \t")
+			changed.trim = changed.trim || len(trimmed) < len(line)
+			line = trimmed
+		}
+		if s.Indent != "" {
+			indented := reindent(line, s.Indent, s.TabWidth)
+			changed.indent = changed.indent || !bytes.Equal(indented, line)
+			line = indented
+		}
+		out = append(out, line...)
+		if ended || s.FinalNewline {
+			out = append(out, sep...)
+		}
+	}
+	if !hasFinal && s.FinalNewline {
+		changed.final = true
+	}
+
+	var changes []string
+	if changed.eol {
+		changes = append(changes, "line endings not "+strings.ToUpper(eol))
+	}
+	if changed.trim {
+		changes = append(changes, "trailing whitespace")
+	}
+	if changed.final {
+		changes = append(changes, "no final newline")
+	}
+	if changed.indent {
+		changes = append(changes, "indentation not "+s.Indent+"s")
+	}
+	return out, changes
+}
+
+// reindent returns line with its indentation made of tabs or of spaces.
+// With tabs, columns that don't fill a tab stay spaces.
+func reindent(line []byte, style string, width int) []byte {
+	n := 0
+	col := 0
+	for ; n < len(line) && (line[n] == ' ' || line[n] == '\t'); n++ {
+		if line[n] == '\t' {
+			col += width - col%width
+		} else {
+			col++
+		}
+	}
+	var indent []byte
+	if style == "tab" {
+		indent = append(bytes.Repeat([]byte("\t"), col/width), bytes.Repeat([]byte(" "), col%width)...)
+	} else {
+		indent = bytes.Repeat([]byte(" "), col)
+	}
+	if bytes.Equal(indent, line[:n]) {
+		return line
+	}
+	return append(indent, line[n:]...)
+}
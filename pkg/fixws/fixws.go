@@ -0,0 +1,226 @@
+package fixws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/editorconfig"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// Options holds fix-ws configuration
+type Options struct {
+	Settings
+	Check          bool // report files that need fixing instead of fixing them
+	NoEditorconfig bool
+	Verbose        bool
+
+	set map[string]bool // the flags given, which take precedence over .editorconfig
+}
+
+// Command returns the fix-ws command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "fix-ws [flags] [file|dir...]",
+		Short: "Fix line endings, trailing whitespace and indentation",
+		Long: `Fix the white space of text files in place: make line endings
+consistent, remove white space at the end of lines, end files with a
+line break, and with --indent convert indentation to tabs or spaces.
+Directories stand for the files below them that .gitignore doesn't
+exclude. With no files, or when file is -, fix standard input to
+standard output. Binary and UTF-16 files are skipped.
+
+Line endings become those the file uses most, or LF or CRLF with --eol.
+Only the indentation at the start of lines is converted, counting a tab
+as --tab-width columns; with --indent tab, columns that don't fill a
+tab stay spaces.
+
+Settings come from .editorconfig files too: end_of_line,
+trim_trailing_whitespace, insert_final_newline, indent_style and
+tab_width or indent_size, for the files their sections match. Flags
+given on the command line win over them, and --no-editorconfig ignores
+them.
+
+--check changes nothing: it prints each file that needs fixing with
+what is wrong and exits with status 1 if there were any, for CI.
+
+Examples:
+  fix-ws .
+  fix-ws --check --eol lf 'src/**/*.go'
+  fix-ws --indent space --tab-width 4 script.py`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if opts.EOL != "" && opts.EOL != "lf" && opts.EOL != "crlf" {
+				return exitcode.New(2, fmt.Errorf("invalid --eol %q: must be lf or crlf", opts.EOL))
+			}
+			if opts.Indent != "" && opts.Indent != "tab" && opts.Indent != "space" {
+				return exitcode.New(2, fmt.Errorf("invalid --indent %q: must be tab or space", opts.Indent))
+			}
+			if opts.TabWidth < 1 {
+				return exitcode.New(2, fmt.Errorf("invalid --tab-width %d", opts.TabWidth))
+			}
+			opts.set = map[string]bool{}
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				opts.set[f.Name] = true
+			})
+			cmd.SilenceErrors = true
+
+			files, err := (&walk.Walker{Gitignore: true}).ExpandDirs(ctx, glob.Expand(args), nil)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				files = []string{input.Stdin}
+			}
+			return fixFiles(ctx, files, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.EOL, "eol", "", "Make line endings `STYLE`: lf or crlf (default: the file's most common)")
+	cmd.Flags().BoolVar(&opts.Trim, "trim-trailing", true, "Remove white space at the end of lines")
+	cmd.Flags().BoolVar(&opts.FinalNewline, "final-newline", true, "End files with a line break")
+	cmd.Flags().StringVar(&opts.Indent, "indent", "", "Convert indentation to `STYLE`: tab or space")
+	cmd.Flags().IntVar(&opts.TabWidth, "tab-width", 4, "Columns a tab stands for in indentation")
+	cmd.Flags().BoolVar(&opts.Check, "check", false, "Report files that need fixing, and change nothing")
+	cmd.Flags().BoolVar(&opts.NoEditorconfig, "no-editorconfig", false, "Ignore .editorconfig files")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Print each file fixed and what was fixed")
+
+	return cmd
+}
+
+// fixFiles fixes, or with --check reports, each file, and returns status
+// 1 if --check found any to fix or a file could not be read
+func fixFiles(ctx context.Context, files []string, opts *Options) error {
+	resolver := &editorconfig.Resolver{}
+	failed := false
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := opts.Settings
+		if !opts.NoEditorconfig && !input.IsStdin(file) {
+			properties, err := resolver.Properties(file)
+			if err != nil {
+				logging.PathWarn("Cannot read "+editorconfig.FileName+" for", file, err)
+			}
+			s = settingsFor(properties, opts)
+		}
+
+		changes, err := fixFile(ctx, file, s, opts)
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			logging.Error(err)
+			failed = true
+			continue
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if opts.Check {
+			failed = true
+		}
+		if opts.Check || opts.Verbose {
+			fmt.Fprintf(output.Stdout, "%s: %s\n", file, strings.Join(changes, ", "))
+		}
+	}
+	if failed {
+		return exitcode.Status(1)
+	}
+	return nil
+}
+
+// settingsFor returns the settings for a file from its .editorconfig
+// properties, overridden by the flags given
+func settingsFor(properties map[string]string, opts *Options) Settings {
+	s := opts.Settings
+	if v := properties["end_of_line"]; (v == "lf" || v == "crlf") && !opts.set["eol"] {
+		s.EOL = v
+	}
+	if v, ok := properties["trim_trailing_whitespace"]; ok && !opts.set["trim-trailing"] {
+		s.Trim = v == "true"
+	}
+	if v, ok := properties["insert_final_newline"]; ok && !opts.set["final-newline"] {
+		s.FinalNewline = v == "true"
+	}
+	if v := properties["indent_style"]; (v == "tab" || v == "space") && !opts.set["indent"] {
+		s.Indent = v
+	}
+	if !opts.set["tab-width"] {
+		for _, key := range []string{"indent_size", "tab_width"} {
+			if n, err := strconv.Atoi(properties[key]); err == nil && n > 0 {
+				s.TabWidth = n
+			}
+		}
+	}
+	return s
+}
+
+// fixFile fixes a file in place, or standard input to standard output,
+// and returns what was changed or with --check would be
+func fixFile(ctx context.Context, file string, s Settings, opts *Options) ([]string, error) {
+	if input.IsStdin(file) {
+		data, err := io.ReadAll(interrupt.Reader(ctx, os.Stdin))
+		if err != nil {
+			return nil, fmt.Errorf("error reading input: %w", err)
+		}
+		fixed, changes := fix(data, s)
+		if !opts.Check {
+			if _, err := output.Stdout.Write(fixed); err != nil {
+				return nil, err
+			}
+		}
+		return changes, nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat '%s': %w", file, err)
+	}
+	if !info.Mode().IsRegular() {
+		logging.PathWarn("Skipping", file, errors.New("not a regular file"))
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read '%s': %w", file, err)
+	}
+	if bom := textenc.DetectBOM(data); bom == textenc.UTF16LE || bom == textenc.UTF16BE {
+		logging.PathWarn("Skipping", file, errors.New("UTF-16 file"))
+		return nil, nil
+	}
+	if textenc.IsBinary(data) {
+		return nil, nil
+	}
+
+	fixed, changes := fix(data, s)
+	if opts.Check || bytes.Equal(fixed, data) {
+		return changes, nil
+	}
+	if dryrun.Enabled {
+		dryrun.Report("fix %s in '%s'", strings.Join(changes, ", "), file)
+		return nil, nil
+	}
+	return changes, output.ReplaceFile(ctx, file, info.Mode(), fixed)
+}
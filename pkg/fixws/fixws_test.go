@@ -0,0 +1,83 @@
+package fixws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// defaults are the settings fix-ws has without flags or .editorconfig
+var defaults = Settings{Trim: true, FinalNewline: true, TabWidth: 4}
+
+// TestFix tests each kind of fix and what it is reported as
+func TestFix(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		s        Settings
+		changes  []string
+	}{
+		{"a\nb\n", "a\nb\n", defaults, nil},
+		{"", "", defaults, nil},
+		{"a \t\nb\n", "a\nb\n", defaults, []string{"trailing whitespace"}},
+		{"a\nb", "a\nb\n", defaults, []string{"no final newline"}},
+		{"a\nb", "a\nb", Settings{TabWidth: 4}, nil},
+		// The file's most common line ending wins, or --eol's
+		{"a\r\nb\r\nc\n", "a\r\nb\r\nc\r\n", defaults, []string{"line endings not CRLF"}},
+		{"a\r\nb\nc\n", "a\nb\nc\n", defaults, []string{"line endings not LF"}},
+		{"a\nb\n", "a\r\nb\r\n", Settings{EOL: "crlf", TabWidth: 4}, []string{"line endings not CRLF"}},
+		{"a  \r\nb", "a\r\nb\r\n", defaults, []string{"trailing whitespace", "no final newline"}},
+		// Indentation
+		{"\tx\n\t\ty\n", "    x\n        y\n", Settings{Indent: "space", TabWidth: 4}, []string{"indentation not spaces"}},
+		{"      x\n  \ty\n", "\t  x\n\ty\n", Settings{Indent: "tab", TabWidth: 4}, []string{"indentation not tabs"}},
+		{"\tx = 1\t# tab\n", "\tx = 1\t# tab\n", Settings{Indent: "tab", TabWidth: 4}, nil},
+	} {
+		got, changes := fix([]byte(tc.in), tc.s)
+		assert.Equal(t, tc.want, string(got), "%q", tc.in)
+		assert.Equal(t, tc.changes, changes, "%q", tc.in)
+	}
+}
+
+// TestSettingsFor tests .editorconfig properties giving way to the flags
+// given
+func TestSettingsFor(t *testing.T) {
+	properties := map[string]string{
+		"end_of_line":              "crlf",
+		"trim_trailing_whitespace": "false",
+		"indent_style":             "tab",
+		"indent_size":              "2",
+		"tab_width":                "8",
+	}
+	s := settingsFor(properties, &Options{Settings: defaults})
+	assert.Equal(t, Settings{EOL: "crlf", Trim: false, FinalNewline: true, Indent: "tab", TabWidth: 8}, s)
+
+	opts := &Options{Settings: Settings{EOL: "lf", Trim: true, FinalNewline: true, TabWidth: 4}, set: map[string]bool{"eol": true, "tab-width": true}}
+	s = settingsFor(properties, opts)
+	assert.Equal(t, Settings{EOL: "lf", Trim: false, FinalNewline: true, Indent: "tab", TabWidth: 4}, s)
+}
+
+// TestFixFiles tests fixing files in place with .editorconfig settings,
+// and --check leaving them alone
+func TestFixFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n\n[*.md]\ntrim_trailing_whitespace = false\n"), 0o644))
+	txt := filepath.Join(dir, "a.txt")
+	md := filepath.Join(dir, "b.md")
+	require.NoError(t, os.WriteFile(txt, []byte("x  \ny"), 0o644))
+	require.NoError(t, os.WriteFile(md, []byte("line break  \n"), 0o644))
+
+	err := fixFiles(t.Context(), []string{txt, md}, &Options{Settings: defaults, Check: true})
+	require.Error(t, err)
+	data, _ := os.ReadFile(txt)
+	assert.Equal(t, "x  \ny", string(data))
+
+	require.NoError(t, fixFiles(t.Context(), []string{txt, md}, &Options{Settings: defaults}))
+	data, _ = os.ReadFile(txt)
+	assert.Equal(t, "x\ny\n", string(data))
+	data, _ = os.ReadFile(md)
+	assert.Equal(t, "line break  \n", string(data))
+
+	require.NoError(t, fixFiles(t.Context(), []string{txt, md}, &Options{Settings: defaults, Check: true}))
+}
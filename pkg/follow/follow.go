@@ -0,0 +1,161 @@
+package follow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// Interval is how long a follower waits before checking a file it has
+// caught up with for new data
+var Interval = 250 * time.Millisecond
+
+// File reads a file as it grows. Where a plain read would return io.EOF,
+// Read waits for more data instead. A file that is truncated is read again
+// from the start, and one that is replaced, as log rotation does, is
+// reopened under its name, so reading carries on across both.
+type File struct {
+	// Idle, if set, is called each time the reader has caught up and is
+	// about to wait, for example to flush buffered output
+	Idle func()
+
+	// Interval, if set, replaces the package Interval for this file
+	Interval time.Duration
+
+	// Done, if set, is asked each time the reader has caught up whether
+	// following should end. Once it returns true, Read returns what is left
+	// in the file and then io.EOF.
+	Done func() bool
+
+	ctx    context.Context
+	name   string
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+
+	caughtUp bool // the last read found no new data
+}
+
+// Open opens name for following. Reads fail with ctx.Err() once ctx is
+// canceled, which is how following ends.
+func Open(ctx context.Context, name string) (*File, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := FromFile(ctx, name, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// FromFile follows file, already opened as name, from its current
+// position, for callers that have read part of it first
+func FromFile(ctx context.Context, name string, file *os.File) (*File, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// Pipes can't say where they are, but aren't truncated either
+		offset = 0
+	}
+	return &File{ctx: ctx, name: name, file: file, info: info, offset: offset}, nil
+}
+
+// Name returns the name the file was opened with
+func (f *File) Name() string {
+	return f.name
+}
+
+// Read reads the next data of the file, waiting for it if necessary
+func (f *File) Read(p []byte) (int, error) {
+	for {
+		if err := f.ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		// Asked before reading, so that whatever was written before the
+		// answer is still read
+		done := f.caughtUp && f.Done != nil && f.Done()
+
+		n, err := f.file.Read(p)
+		f.offset += int64(n)
+		if n > 0 {
+			f.caughtUp = false
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if done {
+			return 0, io.EOF
+		}
+
+		changed, err := f.check()
+		if err != nil {
+			return 0, err
+		}
+		if changed {
+			continue
+		}
+
+		f.caughtUp = true
+		if f.Idle != nil {
+			f.Idle()
+		}
+		interval := f.Interval
+		if interval == 0 {
+			interval = Interval
+		}
+		select {
+		case <-f.ctx.Done():
+			return 0, f.ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// check looks for the file having been truncated or replaced since it was
+// last read to the end, and if so starts over with its new contents
+func (f *File) check() (bool, error) {
+	info, err := os.Stat(f.name)
+	if err != nil {
+		// Rotated away and not yet recreated: keep the old file until a new
+		// one appears
+		return false, nil
+	}
+
+	if !os.SameFile(info, f.info) {
+		file, err := os.Open(f.name)
+		if err != nil {
+			return false, nil
+		}
+		logging.Warn(fmt.Sprintf("'%s' has been replaced; following the new file", f.name))
+		f.file.Close()
+		f.file, f.info, f.offset = file, info, 0
+		return true, nil
+	}
+
+	if info.Size() < f.offset {
+		logging.Warn(fmt.Sprintf("%s: file truncated", f.name))
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		f.offset = 0
+		return true, nil
+	}
+	return false, nil
+}
+
+// Close closes the file being followed
+func (f *File) Close() error {
+	return f.file.Close()
+}
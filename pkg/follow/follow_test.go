@@ -0,0 +1,159 @@
+package follow
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	Interval = 10 * time.Millisecond
+}
+
+// readN reads exactly n bytes from f, failing the test if that takes long
+func readN(t *testing.T, f *File, n int) string {
+	t.Helper()
+	buf := make([]byte, n)
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(f, buf)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for data")
+	}
+	return string(buf)
+}
+
+// appendTo appends data to the file name
+func appendTo(t *testing.T, name, data string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+// TestFile_Appended tests reading data appended after the end was reached
+func TestFile_Appended(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(name, []byte("one\n"), 0644))
+
+	f, err := Open(context.Background(), name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	idle := make(chan struct{}, 1)
+	f.Idle = func() {
+		select {
+		case idle <- struct{}{}:
+		default:
+		}
+	}
+
+	assert.Equal(t, "one\n", readN(t, f, 4))
+	go func() {
+		<-idle
+		appendTo(t, name, "two\n")
+	}()
+	assert.Equal(t, "two\n", readN(t, f, 4))
+}
+
+// TestFile_Truncated tests starting over when the file is truncated
+func TestFile_Truncated(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(name, []byte("first line\n"), 0644))
+
+	f, err := Open(context.Background(), name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, "first line\n", readN(t, f, 11))
+	require.NoError(t, os.WriteFile(name, []byte("new\n"), 0644))
+	assert.Equal(t, "new\n", readN(t, f, 4))
+}
+
+// TestFile_Replaced tests following a new file put in place of the old one
+func TestFile_Replaced(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "log")
+	require.NoError(t, os.WriteFile(name, []byte("old\n"), 0644))
+
+	f, err := Open(context.Background(), name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, "old\n", readN(t, f, 4))
+	require.NoError(t, os.Rename(name, filepath.Join(dir, "log.1")))
+	require.NoError(t, os.WriteFile(name, []byte("rotated\n"), 0644))
+	assert.Equal(t, "rotated\n", readN(t, f, 8))
+}
+
+// TestFile_Canceled tests that canceling the context ends a waiting read
+func TestFile_Canceled(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(name, nil, 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := Open(ctx, name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = f.Read(make([]byte, 10))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestFile_Done tests that once Done reports true, what was written before
+// is still read and then reading ends
+func TestFile_Done(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log")
+	require.NoError(t, os.WriteFile(name, []byte("start\n"), 0644))
+
+	f, err := FromFile(context.Background(), name, openAt(t, name, 6))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var done atomic.Bool
+	f.Done = done.Load
+	f.Idle = func() {
+		if !done.Load() {
+			appendTo(t, name, "last\n")
+			done.Store(true)
+		}
+	}
+
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "last\n", string(data))
+}
+
+// openAt opens name positioned at offset
+func openAt(t *testing.T, name string, offset int64) *os.File {
+	file, err := os.Open(name)
+	require.NoError(t, err)
+	_, err = file.Seek(offset, io.SeekStart)
+	require.NoError(t, err)
+	return file
+}
+
+// TestAlive tests telling running processes from ones that have exited
+func TestAlive(t *testing.T) {
+	assert.True(t, Alive(os.Getpid()))
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	require.NoError(t, cmd.Run())
+	assert.False(t, Alive(cmd.Process.Pid))
+}
@@ -0,0 +1,12 @@
+//go:build unix
+
+package follow
+
+import "golang.org/x/sys/unix"
+
+// Alive reports whether the process pid is still running
+func Alive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	// EPERM means it exists but belongs to someone else
+	return err == nil || err == unix.EPERM
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package follow
+
+import "golang.org/x/sys/windows"
+
+// stillActive is the exit code of a process that hasn't exited
+const stillActive = 259
+
+// Alive reports whether the process pid is still running
+func Alive(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,170 @@
+package gitignore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Files are the per-directory ignore files that are read, in order: git's
+// own and the tool-neutral .ignore used by ripgrep and others
+var Files = []string{".gitignore", ".ignore"}
+
+// Pattern is one line of an ignore file
+type Pattern struct {
+	base    string // slash-separated directory the pattern is relative to
+	glob    string // doublestar pattern matched against the path below base
+	negate  bool   // "!pattern" re-includes what earlier patterns excluded
+	dirOnly bool   // "pattern/" only matches directories
+}
+
+// Matcher decides whether paths are ignored by an ordered list of patterns,
+// where the last matching pattern wins
+type Matcher struct {
+	patterns []Pattern
+}
+
+// Parse reads patterns in gitignore syntax from r. base is the directory
+// the patterns are relative to, normally the one holding the ignore file.
+func Parse(r io.Reader, base string) ([]Pattern, error) {
+	base = filepath.ToSlash(filepath.Clean(base))
+
+	var patterns []Pattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := parseLine(scanner.Text(), base); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// ReadDir reads the ignore files in dir. Missing files are not an error.
+func ReadDir(dir string) ([]Pattern, error) {
+	var patterns []Pattern
+	for _, name := range Files {
+		file, err := os.Open(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return patterns, err
+		}
+		p, err := Parse(file, dir)
+		file.Close()
+		if err != nil {
+			return patterns, err
+		}
+		patterns = append(patterns, p...)
+	}
+	return patterns, nil
+}
+
+// parseLine converts one line of an ignore file into a pattern
+func parseLine(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are dropped unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+
+	p := Pattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to base; otherwise
+	// it matches a name at any depth
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+
+	// Braces are literal in gitignore but alternatives in doublestar
+	line = strings.NewReplacer("{", "\\{", "}", "\\}").Replace(line)
+
+	p.glob = line
+	return p, true
+}
+
+// New returns a matcher for patterns, in order of increasing precedence
+func New(patterns ...Pattern) *Matcher {
+	return &Matcher{patterns: patterns}
+}
+
+// Add returns a matcher with patterns appended, taking precedence over the
+// existing ones. The receiver is not modified.
+func (m *Matcher) Add(patterns ...Pattern) *Matcher {
+	if len(patterns) == 0 {
+		return m
+	}
+	all := make([]Pattern, 0, len(m.patterns)+len(patterns))
+	all = append(all, m.patterns...)
+	return &Matcher{patterns: append(all, patterns...)}
+}
+
+// Len returns the number of patterns
+func (m *Matcher) Len() int {
+	return len(m.patterns)
+}
+
+// Match reports whether name is ignored. name must be in the same form as
+// the base directories the patterns were read with (e.g. both absolute).
+func (m *Matcher) Match(name string, isDir bool) bool {
+	name = filepath.ToSlash(filepath.Clean(name))
+
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		p := &m.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := relative(p.base, name)
+		if !ok {
+			continue
+		}
+		if matched, _ := doublestar.Match(p.glob, rel); matched {
+			return !p.negate
+		}
+	}
+	return false
+}
+
+// relative returns name relative to base if it lies below it
+func relative(base, name string) (string, bool) {
+	if base == "." {
+		return name, !path.IsAbs(name) && name != "." && !strings.HasPrefix(name, "../")
+	}
+
+	// Only roots such as "/" and "C:/" keep a trailing slash after Clean
+	prefix := base
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+		return "", false
+	}
+	return name[len(prefix):], true
+}
@@ -0,0 +1,95 @@
+package gitignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parse builds a matcher from gitignore text rooted at /repo
+func parse(t *testing.T, text string) *Matcher {
+	patterns, err := Parse(strings.NewReader(text), "/repo")
+	require.NoError(t, err)
+	return New(patterns...)
+}
+
+// TestMatch_Names tests unanchored patterns, which match at any depth
+func TestMatch_Names(t *testing.T) {
+	m := parse(t, "*.log\n# comment\n\n")
+
+	assert.True(t, m.Match("/repo/a.log", false))
+	assert.True(t, m.Match("/repo/x/y/a.log", false))
+	assert.False(t, m.Match("/repo/a.txt", false))
+	assert.False(t, m.Match("/other/a.log", false))
+}
+
+// TestMatch_Anchored tests that a slash ties a pattern to the ignore file's
+// directory
+func TestMatch_Anchored(t *testing.T) {
+	m := parse(t, "/build\ndocs/*.md\n")
+
+	assert.True(t, m.Match("/repo/build", true))
+	assert.False(t, m.Match("/repo/src/build", true))
+	assert.True(t, m.Match("/repo/docs/a.md", false))
+	assert.False(t, m.Match("/repo/docs/sub/a.md", false))
+}
+
+// TestMatch_DirOnlyAndDoubleStar tests trailing slashes and "**"
+func TestMatch_DirOnlyAndDoubleStar(t *testing.T) {
+	m := parse(t, "out/\na/**/z\n")
+
+	assert.True(t, m.Match("/repo/src/out", true))
+	assert.False(t, m.Match("/repo/src/out", false))
+	assert.True(t, m.Match("/repo/a/z", false))
+	assert.True(t, m.Match("/repo/a/b/c/z", false))
+}
+
+// TestMatch_Negation tests that the last matching pattern wins
+func TestMatch_Negation(t *testing.T) {
+	m := parse(t, "*.log\n!keep.log\n")
+	assert.True(t, m.Match("/repo/a.log", false))
+	assert.False(t, m.Match("/repo/keep.log", false))
+
+	// Patterns added later, as from a subdirectory, take precedence
+	more, err := Parse(strings.NewReader("keep.log\n"), "/repo/sub")
+	require.NoError(t, err)
+	m = m.Add(more...)
+	assert.True(t, m.Match("/repo/sub/keep.log", false))
+	assert.False(t, m.Match("/repo/keep.log", false))
+}
+
+// TestMatch_Escapes tests escaped leading characters, trailing spaces and
+// braces, which are not special in gitignore
+func TestMatch_Escapes(t *testing.T) {
+	m := parse(t, "\\#hash\n\\!bang\nspace\\ \ntrail   \n{a,b}\n")
+
+	assert.True(t, m.Match("/repo/#hash", false))
+	assert.True(t, m.Match("/repo/!bang", false))
+	assert.True(t, m.Match("/repo/space ", false))
+	assert.True(t, m.Match("/repo/trail", false))
+	assert.True(t, m.Match("/repo/{a,b}", false))
+	assert.False(t, m.Match("/repo/a", false))
+}
+
+// TestReadDir tests reading both ignore files from a directory
+func TestReadDir(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("*.o\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".ignore"), []byte("vendor/\n"), 0644))
+
+	patterns, err := ReadDir(tempDir)
+	require.NoError(t, err)
+	m := New(patterns...)
+
+	assert.True(t, m.Match(filepath.Join(tempDir, "x.o"), false))
+	assert.True(t, m.Match(filepath.Join(tempDir, "vendor"), true))
+	assert.False(t, m.Match(filepath.Join(tempDir, "x.go"), false))
+
+	patterns, err = ReadDir(filepath.Join(tempDir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, patterns)
+}
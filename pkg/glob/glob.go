@@ -0,0 +1,74 @@
+package glob
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// NoGlob disables argument expansion. Set from the global --no-glob flag.
+var NoGlob = false
+
+// Force turns expansion on outside Windows too, as the tests of commands
+// that expand their arguments do
+var Force = false
+
+// Enabled reports whether wildcard arguments should be expanded.
+//
+// Unix shells expand globs before the program runs, so expansion is only
+// on by default on Windows, where cmd.exe and PowerShell pass them through.
+func Enabled() bool {
+	return (runtime.GOOS == "windows" || Force) && !NoGlob
+}
+
+// Expand expands wildcard arguments the invoking shell left unexpanded.
+//
+// Patterns support *, ?, [...], {a,b} and ** for any number of directories.
+// Arguments without wildcards, "-", URLs, arguments naming an existing
+// path and patterns that match nothing are passed through unchanged, as a
+// POSIX shell would. Matches are sorted for deterministic output.
+func Expand(args []string) []string {
+	if !Enabled() {
+		return args
+	}
+	return ExpandAll(args)
+}
+
+// ExpandAll performs the expansion regardless of platform, for callers that
+// play the part of the shell themselves
+func ExpandAll(args []string) []string {
+	result := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		// The ? of a URL's query is no wildcard
+		if !HasMeta(arg) || strings.Contains(arg, "://") {
+			result = append(result, arg)
+			continue
+		}
+
+		// A file literally named "*.go" wins over the pattern
+		if _, err := os.Lstat(arg); err == nil {
+			result = append(result, arg)
+			continue
+		}
+
+		matches, err := doublestar.FilepathGlob(arg)
+		if err != nil || len(matches) == 0 {
+			result = append(result, arg)
+			continue
+		}
+
+		sort.Strings(matches)
+		result = append(result, matches...)
+	}
+
+	return result
+}
+
+// HasMeta reports whether arg contains glob metacharacters
+func HasMeta(arg string) bool {
+	return arg != "-" && strings.ContainsAny(arg, "*?[{")
+}
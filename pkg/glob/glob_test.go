@@ -0,0 +1,66 @@
+package glob
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTree creates a small directory tree for glob tests
+func setupTree(t *testing.T) string {
+	tempDir := t.TempDir()
+
+	for _, name := range []string{"a.go", "b.go", "c.txt", "sub/d.go", "sub/deep/e.md", "f.md"} {
+		path := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+	}
+
+	return tempDir
+}
+
+// TestExpand_Star tests single-directory wildcards
+func TestExpand_Star(t *testing.T) {
+	dir := setupTree(t)
+
+	result := ExpandAll([]string{filepath.Join(dir, "*.go")})
+	assert.Equal(t, []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")}, result)
+}
+
+// TestExpand_DoubleStar tests recursive ** patterns
+func TestExpand_DoubleStar(t *testing.T) {
+	dir := setupTree(t)
+
+	result := ExpandAll([]string{filepath.Join(dir, "**", "*.md")})
+	assert.Equal(t, []string{filepath.Join(dir, "f.md"), filepath.Join(dir, "sub", "deep", "e.md")}, result)
+}
+
+// TestExpand_PassThrough tests arguments that must not be expanded
+func TestExpand_PassThrough(t *testing.T) {
+	dir := setupTree(t)
+
+	noMatch := filepath.Join(dir, "*.rs")
+	args := []string{"-", "plain.txt", noMatch}
+	assert.Equal(t, args, ExpandAll(args))
+}
+
+// TestExpand_LiteralWins tests that an existing file with a wildcard name is kept
+func TestExpand_LiteralWins(t *testing.T) {
+	dir := setupTree(t)
+
+	literal := filepath.Join(dir, "[x].go")
+	require.NoError(t, os.WriteFile(literal, []byte("x"), 0644))
+
+	assert.Equal(t, []string{literal}, ExpandAll([]string{literal}))
+}
+
+// TestExpand_PreservesOrder tests that expansion happens in argument order
+func TestExpand_PreservesOrder(t *testing.T) {
+	dir := setupTree(t)
+
+	result := ExpandAll([]string{"first", filepath.Join(dir, "*.txt"), "last"})
+	assert.Equal(t, []string{"first", filepath.Join(dir, "c.txt"), "last"}, result)
+}
@@ -1,18 +1,36 @@
 package grep
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
-	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/mmap"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/regex"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
 )
 
 // Options holds grep configuration
 type Options struct {
+	ExtendedRegexp  bool // -E: POSIX extended syntax
+	PerlRegexp      bool // -P: Perl syntax
+	BasicRegexp     bool // -G: POSIX basic syntax, the default
 	CaseInsensitive bool
 	Recursive       bool
 	LineNumbers     bool
@@ -21,6 +39,23 @@ type Options struct {
 	Invert          bool
 	FilesOnly       bool
 	Count           bool
+	MaxCount        int
+	Quiet           bool
+	Dereference     bool // -R: follow symbolic links while recursing
+	Gitignore       bool
+	NullData        bool // -z: input and output records end in NUL
+	Multiline       bool // -U: matches may span lines
+	Null            bool // -Z: file names end in NUL
+	Replace         string
+	Write           bool   // rewrite files with the replacement applied
+	NoMmap          bool   // read large files instead of mapping them
+	Jobs            int    // files searched at once; 0 means one per CPU
+	Unordered       bool   // print each file's output as soon as it is searched
+	Stats           string // "text" or "json" to print a summary after the matches
+
+	replace bool   // --replace was given; an empty template deletes matches
+	color   bool   // resolved from the global --color mode for stdout
+	stats   *stats // counts for --stats; nil without it
 }
 
 // Command returns the grep command
@@ -30,39 +65,126 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "grep [flags] pattern [files...]",
 		Short: "Search for patterns in files",
-		Long:  `Search for patterns in files using regular expressions. Compatible with common grep flags.`,
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Search for patterns in files using regular expressions. Compatible with common grep flags.
+
+Patterns are POSIX basic regular expressions by default, as in GNU grep:
+\( \) \{ \} \| \+ and \? are operators and the bare characters are
+literal. -E selects extended syntax, where they are not escaped, and -P
+Perl syntax with lookaround and back-references.
+
+With --replace, selected lines are printed with every match replaced by the
+template, in which $1, ${1} and ${name} refer to capture groups. Adding
+--write rewrites the files in place instead of printing them.
+
+With -U, each file is searched as a whole so that a match may span lines:
+. also matches a newline, ^ and $ match at every line, and every line a
+match touches is printed. "grep -U -P 'func \w+\([^)]*\n[^)]*\)'" finds
+function signatures split across lines.
+
+--stats prints a summary after the matches: the files searched and those
+with a match, the lines matched, the bytes read and the time taken, which
+shows where a search of a big tree spends its time. --stats=json prints
+it as a JSON object instead.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			pattern := args[0]
-			files := args[1:]
+			ctx := cmd.Context()
+
+			opts.color = color.Enabled(os.Stdout)
+			opts.replace = cmd.Flags().Changed("replace")
+
+			if conflicting(opts.BasicRegexp, opts.ExtendedRegexp, opts.PerlRegexp) {
+				return exitcode.New(2, fmt.Errorf("conflicting matchers specified"))
+			}
+			if opts.Write && !opts.replace {
+				return exitcode.New(2, fmt.Errorf("--write requires --replace"))
+			}
+			if opts.Write && opts.Invert {
+				return exitcode.New(2, fmt.Errorf("--write cannot be used with -v"))
+			}
+			if opts.Multiline && (opts.Invert || opts.replace) {
+				return exitcode.New(2, fmt.Errorf("--multiline cannot be used with -v or --replace"))
+			}
+			switch opts.Stats {
+			case "":
+			case "text", "json":
+				opts.stats = &stats{start: time.Now()}
+			default:
+				return exitcode.New(2, fmt.Errorf("invalid --stats format %q: expected text or json", opts.Stats))
+			}
+
+			m, err := compilePattern(args[0], opts)
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+
+			files := glob.Expand(args[1:])
+
+			if opts.Write && len(files) == 0 {
+				return exitcode.New(2, fmt.Errorf("--write requires file arguments"))
+			}
 
 			// If no files specified, read from stdin
 			if len(files) == 0 {
-				return grepReader(os.Stdin, pattern, opts, "<stdin>")
+				matched, err := grepReader(ctx, output.Stdout, os.Stdin, m, opts, "<stdin>")
+				if err != nil {
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					return exitcode.New(2, err)
+				}
+				opts.stats.file(matched, nil)
+				if err := writeStats(opts); err != nil {
+					return err
+				}
+				return matchStatus(cmd, opts, matched, false)
 			}
 
 			// If recursive, expand directories
-			if opts.Recursive {
-				expanded, err := expandDirs(files)
+			walker := &walk.Walker{Ignore: true, Gitignore: opts.Gitignore, FollowLinks: opts.Dereference}
+			if opts.Recursive || opts.Dereference {
+				expanded, err := expandDirs(ctx, files, walker)
 				if err != nil {
-					return fmt.Errorf("failed to expand directories: %w", err)
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					return exitcode.New(2, fmt.Errorf("failed to expand directories: %w", err))
 				}
 				files = expanded
 			}
 
 			// Process each file
-			for _, file := range files {
-				if err := grepFile(file, pattern, opts); err != nil {
-					eve.Logger.Error("Failed to grep file", file, ":", err)
-				}
+			search := grepFile
+			if opts.Write {
+				search = writeFile
+			}
+			if opts.stats != nil {
+				search = opts.stats.count(search)
+			}
+			anyMatched, failed, err := searchFiles(ctx, files, search, m, opts)
+			if err != nil {
+				return err
+			}
+			if err := writeStats(opts); err != nil {
+				return err
+			}
+			if anyMatched && opts.Quiet {
+				return nil
 			}
 
-			return nil
+			return matchStatus(cmd, opts, anyMatched, failed || walker.Failed())
 		},
 	}
 
+	// Usage errors exit with 2 like GNU grep, not 1 which means "no match"
+	cmd.SetFlagErrorFunc(exitcode.Usage)
+
+	cmd.Flags().BoolVarP(&opts.ExtendedRegexp, "extended-regexp", "E", false, "Patterns are extended regular expressions")
+	cmd.Flags().BoolVarP(&opts.PerlRegexp, "perl-regexp", "P", false, "Patterns are Perl regular expressions")
+	cmd.Flags().BoolVarP(&opts.BasicRegexp, "basic-regexp", "G", false, "Patterns are basic regular expressions (default)")
 	cmd.Flags().BoolVarP(&opts.CaseInsensitive, "ignore-case", "i", false, "Case insensitive search")
 	cmd.Flags().BoolVarP(&opts.Recursive, "recursive", "r", false, "Search recursively in directories")
+	cmd.Flags().BoolVarP(&opts.Dereference, "dereference-recursive", "R", false, "Like -r, but follow symbolic links to directories")
+	cmd.Flags().BoolVar(&opts.Gitignore, "gitignore", false, "With -r, skip files ignored by .gitignore and .ignore files")
 	cmd.Flags().BoolVarP(&opts.LineNumbers, "line-number", "n", false, "Show line numbers")
 	cmd.Flags().IntVarP(&opts.ContextBefore, "before-context", "B", 0, "Show N lines before match")
 	cmd.Flags().IntVarP(&opts.ContextAfter, "after-context", "A", 0, "Show N lines after match")
@@ -70,56 +192,345 @@ func Command() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Invert, "invert-match", "v", false, "Invert match (show non-matching lines)")
 	cmd.Flags().BoolVarP(&opts.FilesOnly, "files-with-matches", "l", false, "Show only filenames with matches")
 	cmd.Flags().BoolVarP(&opts.Count, "count", "c", false, "Show count of matching lines")
+	cmd.Flags().IntVarP(&opts.MaxCount, "max-count", "m", -1, "Stop reading a file after N matching lines (-1 = unlimited)")
+	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Print nothing; exit 0 on the first match")
+	cmd.Flags().BoolVarP(&opts.NullData, "null-data", "z", false, "Input and output lines are terminated by NUL instead of newline")
+	cmd.Flags().BoolVarP(&opts.Null, "null", "Z", false, "Print a NUL byte after file names")
+	cmd.Flags().BoolVarP(&opts.Multiline, "multiline", "U", false, "Let matches span lines, printing every line a match touches")
+	cmd.Flags().StringVar(&opts.Replace, "replace", "", "Print selected lines with matches replaced by `TEMPLATE` ($1 for capture groups)")
+	cmd.Flags().BoolVar(&opts.Write, "write", false, "With --replace, rewrite the files in place (-l lists the files changed)")
+	cmd.Flags().BoolVar(&opts.NoMmap, "no-mmap", false, "Read large files instead of memory-mapping them")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Search up to `N` files at once (default: number of CPUs)")
+	cmd.Flags().BoolVar(&opts.Unordered, "unordered", false, "Print the results of each file as soon as it is searched rather than in file order")
+	cmd.Flags().StringVar(&opts.Stats, "stats", "", "Print a summary of files, lines and bytes searched after the matches: `text` or json")
+	cmd.Flags().Lookup("stats").NoOptDefVal = "text"
 
 	return cmd
 }
 
-// grepFile searches for pattern in a file
-func grepFile(filename, pattern string, opts *Options) error {
+// matchStatus maps the outcome of a search to GNU grep's exit codes:
+// 0 if a line was selected, 1 if none was, and 2 if an error occurred
+// (unless -q found a match, which wins over errors)
+func matchStatus(cmd *cobra.Command, opts *Options, matched, failed bool) error {
+	if failed && !(matched && opts.Quiet) {
+		cmd.SilenceErrors = true
+		return exitcode.Status(2)
+	}
+	if !matched {
+		cmd.SilenceErrors = true
+		return exitcode.Status(1)
+	}
+	return nil
+}
+
+// writeStats prints the --stats summary, if asked for
+func writeStats(opts *Options) error {
+	if opts.stats == nil {
+		return nil
+	}
+	return opts.stats.write(output.Stdout, opts.Stats)
+}
+
+// conflicting reports whether more than one of the dialect flags is set
+func conflicting(flags ...bool) bool {
+	set := 0
+	for _, f := range flags {
+		if f {
+			set++
+		}
+	}
+	return set > 1
+}
+
+// matcher holds the compiled search pattern
+type matcher struct {
+	re     *regex.Regexp // applied to a single line
+	prefix []byte        // literal every match starts with, if any
+
+	// A pattern without operators is searched for as plain bytes, and with
+	// -i in text folded to lower case, so the regex engine is not needed
+	literal []byte
+	fold    bool
+	folded  []byte // scratch buffer for folding lines
+}
+
+// compilePattern compiles the search pattern in the selected dialect with
+// the case flag applied
+func compilePattern(pattern string, opts *Options) (*matcher, error) {
+	syntax := regex.Basic
+	if opts.ExtendedRegexp {
+		syntax = regex.Extended
+	} else if opts.PerlRegexp {
+		syntax = regex.Perl
+	}
+	compile := regex.Compile
+	if opts.Multiline {
+		compile = regex.CompileMultiline
+	}
+	re, err := compile(pattern, syntax, opts.CaseInsensitive)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+
+	m := &matcher{re: re}
+	if prefix := re.LiteralPrefix(); prefix != "" {
+		m.prefix = []byte(prefix)
+	}
+	if text, fold, ok := re.Literal(); ok && text != "" && (!fold || asciiFoldable(text)) {
+		m.literal = []byte(text)
+		if fold {
+			m.literal = foldASCII(nil, m.literal)
+			m.fold = true
+		}
+		m.prefix = m.literal
+	}
+
+	return m, nil
+}
+
+// asciiFoldable reports whether matching s ignoring case only needs ASCII
+// case folding. Go's regexp folds k and s to the Kelvin sign and the long
+// s too, so patterns with those letters still go to the regex engine.
+func asciiFoldable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20
+		if s[i] >= utf8.RuneSelf || c == 'k' || c == 's' {
+			return false
+		}
+	}
+	return true
+}
+
+// foldASCII appends src with ASCII letters in lower case to dst[:0]. The
+// result has the same length as src, so offsets into it apply to src.
+func foldASCII(dst, src []byte) []byte {
+	dst = append(dst[:0], src...)
+	for i, c := range dst {
+		if 'A' <= c && c <= 'Z' {
+			dst[i] = c + 'a' - 'A'
+		}
+	}
+	return dst
+}
+
+// clone returns a copy of m with its own scratch buffer, for use by
+// another goroutine
+func (m *matcher) clone() *matcher {
+	c := *m
+	c.folded = nil
+	return &c
+}
+
+// matches reports whether line contains a match
+func (m *matcher) matches(line []byte) bool {
+	switch {
+	case m.fold:
+		m.folded = foldASCII(m.folded, line)
+		return bytes.Contains(m.folded, m.literal)
+	case m.literal != nil:
+		return bytes.Contains(line, m.literal)
+	default:
+		return m.re.Match(line)
+	}
+}
+
+// findAll returns the byte ranges of the matches in line
+func (m *matcher) findAll(line []byte) [][]int {
+	if m.literal == nil {
+		return m.re.FindAllIndex(line, -1)
+	}
+
+	text := line
+	if m.fold {
+		m.folded = foldASCII(m.folded, line)
+		text = m.folded
+	}
+	var locs [][]int
+	for at := 0; ; {
+		i := bytes.Index(text[at:], m.literal)
+		if i < 0 {
+			return locs
+		}
+		at += i
+		locs = append(locs, []int{at, at + len(m.literal)})
+		at += len(m.literal)
+	}
+}
+
+// grepFile searches for re in a file, reporting whether any line was selected
+func grepFile(ctx context.Context, w io.Writer, filename string, m *matcher, opts *Options) (bool, error) {
+	if input.IsStdin(filename) {
+		return grepReader(ctx, w, os.Stdin, m, opts, "(standard input)")
+	}
+	if input.IsRemote(filename) {
+		r, err := input.OpenContext(ctx, filename)
+		if err != nil {
+			return false, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer r.Close()
+		return grepReader(ctx, w, r, m, opts, filename)
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return false, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	return grepReader(file, pattern, opts, filename)
+	// Large files are searched in place through a mapping, saving the copy
+	// into a read buffer
+	if !opts.NoMmap {
+		if mapping, err := mmap.Map(file); err == nil {
+			defer mapping.Close()
+			var matched bool
+			err := mmap.Guard(func() (err error) {
+				if opts.Multiline {
+					matched, err = grepMultiline(ctx, w, mapping.Bytes(), m, opts, filename)
+				} else {
+					matched, err = grepChunks(ctx, w, lines.NewChunkReaderBytes(mapping.Bytes()), m, opts, filename)
+				}
+				return err
+			})
+			return matched, err
+		}
+	}
+
+	return grepReader(ctx, w, file, m, opts, filename)
 }
 
-// grepReader searches for pattern in a reader
-func grepReader(reader *os.File, pattern string, opts *Options, filename string) error {
-	// Compile regex
-	flags := ""
-	if opts.CaseInsensitive {
-		flags = "(?i)"
+// grepReader searches for m in a reader, reporting whether any line was
+// selected
+func grepReader(ctx context.Context, w io.Writer, reader io.Reader, m *matcher, opts *Options, filename string) (bool, error) {
+	if opts.Multiline {
+		data, err := io.ReadAll(interrupt.Reader(ctx, reader))
+		if err != nil {
+			return false, fmt.Errorf("error reading file: %w", err)
+		}
+		return grepMultiline(ctx, w, data, m, opts, filename)
 	}
-	re, err := regexp.Compile(flags + pattern)
-	if err != nil {
-		return fmt.Errorf("invalid regex pattern: %w", err)
+	return grepChunks(ctx, w, lines.NewChunkReader(interrupt.Reader(ctx, reader)), m, opts, filename)
+}
+
+// grepChunks searches for m in the input of chunks. Input comes in large
+// chunks that are split into lines by hand; when the pattern starts with a
+// literal, the chunk is searched for it first so runs of non-matching lines
+// cost a single byte scan.
+func grepChunks(ctx context.Context, w io.Writer, chunks *lines.ChunkReader, m *matcher, opts *Options, filename string) (bool, error) {
+	delim := byte('\n')
+	eol := "\n"
+	if opts.NullData {
+		chunks.SetDelimiter(0)
+		delim = 0
+		eol = "\x00"
 	}
 
-	scanner := bufio.NewScanner(reader)
+	// Every selected line contains the pattern's literal prefix, so lines
+	// without it can be skipped with a plain byte search. Inverted matching
+	// needs to see every line.
+	prefix := m.prefix
+	if opts.Invert {
+		prefix = nil
+	}
+
+	// With -i, a literal is searched for in a folded copy of the chunk
+	var folded []byte
+
+	// Match offsets are only needed to highlight or replace matches
+	needLocs := opts.color || opts.replace
+
 	lineNum := 0
 	matchCount := 0
 	foundMatch := false
+	scanned := 0
+	defer func() { opts.stats.add(matchCount, scanned) }()
 
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		matches := re.MatchString(line)
+	// Where matches are dense, searching ahead costs more than it saves, so
+	// after a candidate that skipped no lines the following lines are
+	// checked directly, in growing bursts
+	burst, direct := 0, 0
 
-		// Invert logic if requested
-		if opts.Invert {
-			matches = !matches
+	for opts.MaxCount < 0 || matchCount < opts.MaxCount {
+		chunk, err := chunks.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return foundMatch, fmt.Errorf("error reading file: %w", err)
 		}
+		scanned += len(chunk)
+		if err := ctx.Err(); err != nil {
+			return foundMatch, err
+		}
+		if m.fold && prefix != nil {
+			folded = foldASCII(folded, chunk)
+		}
+
+		for len(chunk) > 0 && (opts.MaxCount < 0 || matchCount < opts.MaxCount) {
+			// Skip ahead to the start of the line holding the next candidate
+			if prefix != nil && direct == 0 {
+				hay := chunk
+				if m.fold {
+					hay = folded[len(folded)-len(chunk):]
+				}
+				at := bytes.Index(hay, prefix)
+				if at < 0 {
+					lineNum += bytes.Count(chunk, []byte{delim})
+					break
+				}
+				lineStart := bytes.LastIndexByte(chunk[:at], delim) + 1
+				lineNum += bytes.Count(chunk[:lineStart], []byte{delim})
+				chunk = chunk[lineStart:]
+
+				if lineStart == 0 {
+					burst = min(2*burst+1, 4096)
+				} else {
+					burst = 0
+				}
+				direct = burst + 1
+			}
+			if direct > 0 {
+				direct--
+			}
+
+			line := chunk
+			if i := bytes.IndexByte(chunk, delim); i >= 0 {
+				line, chunk = chunk[:i], chunk[i+1:]
+			} else {
+				chunk = nil
+			}
+			lineNum++
+
+			var locs [][]int
+			var matches bool
+			if needLocs {
+				locs = m.findAll(line)
+				matches = len(locs) > 0
+			} else {
+				matches = m.matches(line)
+			}
+
+			// Invert logic if requested; selected lines then have no matches
+			if opts.Invert {
+				matches = !matches
+				locs = nil
+			}
+
+			if !matches {
+				continue
+			}
 
-		if matches {
 			matchCount++
 			foundMatch = true
 
+			// Quiet mode: the first match settles it
+			if opts.Quiet {
+				return true, nil
+			}
+
 			// Files-only mode: just record that we found a match
 			if opts.FilesOnly {
-				fmt.Println(filename)
-				return nil
+				fmt.Fprint(w, paint(filename, color.Filename, opts)+nameEnd(opts, "\n"))
+				return true, nil
 			}
 
 			// Count mode: just count
@@ -130,56 +541,95 @@ func grepReader(reader *os.File, pattern string, opts *Options, filename string)
 			// Regular output
 			prefix := ""
 			if filename != "<stdin>" {
-				prefix = filename + ":"
+				prefix = paint(filename, color.Filename, opts) + nameEnd(opts, paint(":", color.Separator, opts))
 			}
 			if opts.LineNumbers {
-				prefix += fmt.Sprintf("%d:", lineNum)
+				prefix += paint(fmt.Sprintf("%d", lineNum), color.LineNum, opts) + paint(":", color.Separator, opts)
 			}
 
-			fmt.Printf("%s%s\n", prefix, line)
-		}
-	}
+			text := highlight(string(line), locs, opts)
+			if opts.replace && len(locs) > 0 {
+				text = string(replaceLine(line, m, opts))
+			}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file: %w", err)
+			fmt.Fprint(w, prefix, text, eol)
+		}
 	}
 
 	// Print count if requested
 	if opts.Count && foundMatch {
 		prefix := ""
 		if filename != "<stdin>" {
-			prefix = filename + ":"
+			prefix = paint(filename, color.Filename, opts) + nameEnd(opts, paint(":", color.Separator, opts))
 		}
-		fmt.Printf("%s%d\n", prefix, matchCount)
+		fmt.Fprintf(w, "%s%d\n", prefix, matchCount)
 	}
 
-	return nil
+	return foundMatch, nil
 }
 
-// expandDirs recursively expands directories to file list
-func expandDirs(paths []string) ([]string, error) {
+// nameEnd returns what follows a file name in output: a NUL byte with -Z,
+// otherwise sep
+func nameEnd(opts *Options, sep string) string {
+	if opts.Null {
+		return "\x00"
+	}
+	return sep
+}
+
+// paint wraps s in the given SGR sequence when color is enabled
+func paint(s, sgr string, opts *Options) string {
+	return color.Paint(opts.color, s, sgr)
+}
+
+// highlight colors the matched byte ranges of line
+func highlight(line string, locs [][]int, opts *Options) string {
+	if !opts.color || len(locs) == 0 {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		// Empty matches (e.g. "x*") have nothing to highlight
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(line[last:loc[0]])
+		b.WriteString(paint(line[loc[0]:loc[1]], color.Match, opts))
+		last = loc[1]
+	}
+	b.WriteString(line[last:])
+
+	return b.String()
+}
+
+// expandDirs recursively expands directories to file list. Operands that
+// cannot be read are kept so searching them reports the error.
+func expandDirs(ctx context.Context, paths []string, w *walk.Walker) ([]string, error) {
 	var files []string
 
 	for _, path := range paths {
-		info, err := os.Stat(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		if input.IsStdin(path) || input.IsRemote(path) {
+			files = append(files, path)
+			continue
 		}
 
-		if info.IsDir() {
-			err := filepath.Walk(path, func(walkPath string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if !info.IsDir() {
-					files = append(files, walkPath)
-				}
+		err := w.Walk(ctx, path, func(walkPath string, d fs.DirEntry, depth int) error {
+			// Like GNU grep -r, skip links found while recursing; with -R
+			// the walker has already replaced them by their targets
+			if depth > 0 && d.Type()&fs.ModeSymlink != 0 {
 				return nil
-			})
-			if err != nil {
-				return nil, fmt.Errorf("failed to walk directory %s: %w", path, err)
 			}
-		} else {
+			if !d.IsDir() {
+				files = append(files, walkPath)
+			}
+			return nil
+		})
+		if interrupt.Interrupted(err) {
+			return nil, err
+		}
+		if err != nil {
 			files = append(files, path)
 		}
 	}
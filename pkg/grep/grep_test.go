@@ -0,0 +1,392 @@
+package grep
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// runCommand runs grep with args under the global --color mode and
+// returns what it printed to stdout, which is a file and so never a
+// terminal, and the error it returned
+func runCommand(t *testing.T, mode string, args ...string) (string, error) {
+	defer func(saved string) { color.Mode = saved }(color.Mode)
+	color.Mode = mode
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	cmd := Command()
+	cmd.SetArgs(args)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	runErr := cmd.Execute()
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed), runErr
+}
+
+// TestCommand_Color tests that --color=always highlights the file name,
+// line number and matches with GNU grep's colors, and that never and auto
+// on output that is not a terminal print plain text
+func TestCommand_Color(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("one foo two foo\nbar\n"), 0644))
+
+	printed, err := runCommand(t, color.Always, "-n", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"\x1b[35m"+input+"\x1b[m\x1b[36m:\x1b[m\x1b[32m1\x1b[m\x1b[36m:\x1b[m"+
+			"one \x1b[01;31mfoo\x1b[m two \x1b[01;31mfoo\x1b[m\n",
+		printed)
+
+	plain := input + ":1:one foo two foo\n"
+	for _, mode := range []string{color.Never, color.Auto} {
+		printed, err = runCommand(t, mode, "-n", "foo", input)
+		require.NoError(t, err)
+		assert.Equal(t, plain, printed, mode)
+	}
+}
+
+// TestCommand_ExitStatus tests GNU grep's exit statuses: 0 when a line is
+// selected, 1 when none is and 2 on errors, even with matches in other
+// files, unless -q already found one
+func TestCommand_ExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("foo\nbar\nfoo again\n"), 0644))
+	missing := filepath.Join(dir, "missing.txt")
+
+	printed, err := runCommand(t, color.Never, "foo", input)
+	assert.Equal(t, 0, exitcode.From(err))
+	assert.Equal(t, input+":foo\n"+input+":foo again\n", printed)
+
+	printed, err = runCommand(t, color.Never, "baz", input)
+	assert.Equal(t, 1, exitcode.From(err))
+	assert.Equal(t, "", printed)
+
+	_, err = runCommand(t, color.Never, "foo", input, missing)
+	assert.Equal(t, 2, exitcode.From(err))
+	_, err = runCommand(t, color.Never, "-E", "(", input)
+	assert.Equal(t, 2, exitcode.From(err))
+	_, err = runCommand(t, color.Never, "--no-such-flag", "foo", input)
+	assert.Equal(t, 2, exitcode.From(err))
+
+	printed, err = runCommand(t, color.Never, "-q", "foo", input, missing)
+	assert.Equal(t, 0, exitcode.From(err))
+	assert.Equal(t, "", printed)
+	_, err = runCommand(t, color.Never, "-q", "baz", input)
+	assert.Equal(t, 1, exitcode.From(err))
+}
+
+// TestCommand_MaxCount tests that -m stops reading a file after N selected
+// lines, counting them for -c
+func TestCommand_MaxCount(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("foo 1\nbar\nfoo 2\nfoo 3\n"), 0644))
+
+	printed, err := runCommand(t, color.Never, "-m", "2", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t, input+":foo 1\n"+input+":foo 2\n", printed)
+
+	printed, err = runCommand(t, color.Never, "-c", "-m", "2", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t, input+":2\n", printed)
+}
+
+// TestCommand_NullData tests that -z reads and prints NUL-terminated
+// records, so a record may span lines
+func TestCommand_NullData(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("one\nfoo\x00bar\x00foo two\x00"), 0644))
+
+	printed, err := runCommand(t, color.Never, "-z", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t, input+":one\nfoo\x00"+input+":foo two\x00", printed)
+}
+
+// TestCommand_Null tests that -Z ends file names with NUL instead of the
+// newline of -l and the colon before lines and counts
+func TestCommand_Null(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("foo\nbar\n"), 0644))
+
+	printed, err := runCommand(t, color.Never, "-lZ", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t, input+"\x00", printed)
+
+	printed, err = runCommand(t, color.Never, "-Z", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t, input+"\x00foo\n", printed)
+
+	printed, err = runCommand(t, color.Never, "-cZ", "foo", input)
+	require.NoError(t, err)
+	assert.Equal(t, input+"\x001\n", printed)
+}
+
+// runGrep searches content for pattern and returns what was printed
+func runGrep(t *testing.T, content, pattern string, opts *Options) (string, bool) {
+	tempDir := t.TempDir()
+	input := filepath.Join(tempDir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte(content), 0644))
+
+	m, err := compilePattern(pattern, opts)
+	require.NoError(t, err)
+
+	out, err := os.Create(filepath.Join(tempDir, "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	file, err := os.Open(input)
+	require.NoError(t, err)
+	defer file.Close()
+
+	matched, err := grepReader(context.Background(), output.Stdout, file, m, opts, "<stdin>")
+	require.NoError(t, err)
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed), matched
+}
+
+// TestGrepReader_Anchors tests that ^ and $ still apply per line when
+// whole chunks are searched at once
+func TestGrepReader_Anchors(t *testing.T) {
+	content := "foo bar\nbar foo\nfoo\nxfoo\n"
+
+	out, _ := runGrep(t, content, "^foo", &Options{MaxCount: -1, LineNumbers: true})
+	assert.Equal(t, "1:foo bar\n3:foo\n", out)
+
+	out, _ = runGrep(t, content, "foo$", &Options{MaxCount: -1, LineNumbers: true})
+	assert.Equal(t, "2:bar foo\n3:foo\n4:xfoo\n", out)
+}
+
+// TestGrepReader_CrossLineCandidate tests that a chunk match spanning lines
+// does not select either line
+func TestGrepReader_CrossLineCandidate(t *testing.T) {
+	out, matched := runGrep(t, "a\nb\nab\n", `a\sb`, &Options{MaxCount: -1})
+	assert.Equal(t, "", out)
+	assert.False(t, matched)
+
+	out, _ = runGrep(t, "a\nb\na b\n", `a\sb`, &Options{MaxCount: -1, LineNumbers: true})
+	assert.Equal(t, "3:a b\n", out)
+}
+
+// TestGrepReader_LineNumbersAcrossChunks tests line numbering over input
+// larger than a single chunk, including a very long line
+func TestGrepReader_LineNumbersAcrossChunks(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 100000; i++ {
+		b.WriteString("filler line\n")
+	}
+	b.WriteString(strings.Repeat("x", 3<<20) + "\n")
+	b.WriteString("needle\n")
+
+	out, _ := runGrep(t, b.String(), "needle", &Options{MaxCount: -1, LineNumbers: true})
+	assert.Equal(t, "100002:needle\n", out)
+}
+
+// TestGrepReader_InvertAndMaxCount tests -v and -m with -c
+func TestGrepReader_InvertAndMaxCount(t *testing.T) {
+	content := "a\nb\na\nc\na"
+
+	out, _ := runGrep(t, content, "a", &Options{MaxCount: -1, Invert: true})
+	assert.Equal(t, "b\nc\n", out)
+
+	out, _ = runGrep(t, content, "a", &Options{MaxCount: 2, Count: true})
+	assert.Equal(t, "2\n", out)
+
+	out, _ = runGrep(t, content, "a", &Options{MaxCount: -1, Count: true})
+	assert.Equal(t, "3\n", out)
+}
+
+// TestGrepReader_Replace tests that --replace prints selected lines with
+// capture references expanded
+func TestGrepReader_Replace(t *testing.T) {
+	opts := &Options{MaxCount: -1, ExtendedRegexp: true, Replace: "${2}_$1", replace: true}
+	out, _ := runGrep(t, "foo-bar\nnone\nbaz-qux x-y\n", `(\w+)-(\w+)`, opts)
+	assert.Equal(t, "bar_foo\nqux_baz y_x\n", out)
+
+	// An empty template deletes the matches
+	out, _ = runGrep(t, "a1b2\n", `\d`, &Options{MaxCount: -1, replace: true})
+	assert.Equal(t, "ab\n", out)
+}
+
+// TestGrepReader_Syntax tests that the same pattern reads differently in
+// the basic, extended and Perl dialects
+func TestGrepReader_Syntax(t *testing.T) {
+	content := "a|b\nb\naa\n"
+
+	out, _ := runGrep(t, content, "a|b", &Options{MaxCount: -1})
+	assert.Equal(t, "a|b\n", out)
+
+	out, _ = runGrep(t, content, "a|b", &Options{MaxCount: -1, ExtendedRegexp: true})
+	assert.Equal(t, "a|b\nb\naa\n", out)
+
+	out, _ = runGrep(t, content, `\(a\)\1`, &Options{MaxCount: -1})
+	assert.Equal(t, "aa\n", out)
+
+	out, _ = runGrep(t, content, `a(?!a)`, &Options{MaxCount: -1, PerlRegexp: true})
+	assert.Equal(t, "a|b\naa\n", out)
+}
+
+// TestGrepReader_Multiline tests that with -U a match may span lines and
+// prints each line it touches once
+func TestGrepReader_Multiline(t *testing.T) {
+	content := "package x\n\nfunc f(a int,\n\tb int) {\n}\nfunc g() {}\n"
+	opts := &Options{MaxCount: -1, Multiline: true, LineNumbers: true, PerlRegexp: true}
+	out, matched := runGrep(t, content, `func \w+\([^)]*\n[^)]*\)`, opts)
+	assert.True(t, matched)
+	assert.Equal(t, "3:func f(a int,\n4:\tb int) {\n", out)
+
+	// Lines touched by several matches are printed once, and ^ and $
+	// anchor at each line
+	out, _ = runGrep(t, content, `^func.*$`, &Options{MaxCount: -1, Multiline: true, LineNumbers: true})
+	assert.Equal(t, "3:func f(a int,\n4:\tb int) {\n5:}\n6:func g() {}\n", out)
+	out, _ = runGrep(t, content, `int,$`, &Options{MaxCount: -1, Multiline: true, Count: true})
+	assert.Equal(t, "1\n", out)
+
+	// -m and -c count lines
+	out, _ = runGrep(t, content, `([^)]*)`, &Options{MaxCount: 1, Multiline: true, Count: true})
+	assert.Equal(t, "2\n", out)
+
+	_, matched = runGrep(t, content, `int\)\n\n`, &Options{MaxCount: -1, Multiline: true, PerlRegexp: true})
+	assert.False(t, matched)
+}
+
+// TestReplaceAll tests per-line replacement for --write, including -m and a
+// missing final newline
+func TestReplaceAll(t *testing.T) {
+	m, err := compilePattern("^a", &Options{})
+	require.NoError(t, err)
+
+	out, count := replaceAll([]byte("ab\nba\nac"), m, &Options{MaxCount: -1, Replace: "X"})
+	assert.Equal(t, "Xb\nba\nXc", string(out))
+	assert.Equal(t, 2, count)
+
+	out, count = replaceAll([]byte("ab\nac\n"), m, &Options{MaxCount: 1, Replace: "X"})
+	assert.Equal(t, "Xb\nac\n", string(out))
+	assert.Equal(t, 1, count)
+}
+
+// TestWriteFile tests rewriting a file in place, keeping its mode
+func TestWriteFile(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "input.txt")
+	require.NoError(t, os.WriteFile(file, []byte("old value\nother\n"), 0600))
+
+	m, err := compilePattern("old", &Options{})
+	require.NoError(t, err)
+
+	matched, err := writeFile(context.Background(), output.Stdout, file, m, &Options{MaxCount: -1, Replace: "new"})
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "new value\nother\n", string(content))
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+// TestGrepReader_Literal tests the plain-string search, with and without
+// case folding, against what the regex engine would find
+func TestGrepReader_Literal(t *testing.T) {
+	content := "an ERROR here\nno problem\nerror, Error and eRRoR\nterror\n"
+
+	out, _ := runGrep(t, content, "error", &Options{MaxCount: -1, LineNumbers: true})
+	assert.Equal(t, "3:error, Error and eRRoR\n4:terror\n", out)
+
+	out, _ = runGrep(t, content, "error", &Options{MaxCount: -1, CaseInsensitive: true, Count: true})
+	assert.Equal(t, "3\n", out)
+
+	out, _ = runGrep(t, content, "ERROR", &Options{MaxCount: -1, CaseInsensitive: true, Invert: true})
+	assert.Equal(t, "no problem\n", out)
+
+	out, _ = runGrep(t, content, "error", &Options{MaxCount: -1, CaseInsensitive: true, replace: true, Replace: "<$0>"})
+	assert.Equal(t, "an <ERROR> here\n<error>, <Error> and <eRRoR>\nt<error>\n", out)
+
+	// Letters that also fold to non-ASCII runes are left to the regex
+	// engine: K matches the Kelvin sign
+	out, _ = runGrep(t, "K\nx\n", "k", &Options{MaxCount: -1, CaseInsensitive: true})
+	assert.Equal(t, "K\n", out)
+}
+
+// TestMatcher_FindAll tests match offsets from the literal search
+func TestMatcher_FindAll(t *testing.T) {
+	m, err := compilePattern("Ab", &Options{CaseInsensitive: true})
+	require.NoError(t, err)
+	require.True(t, m.fold)
+	assert.Equal(t, [][]int{{0, 2}, {3, 5}}, m.findAll([]byte("aB-ab")))
+
+	m, err = compilePattern("aa", &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, [][]int{{0, 2}, {2, 4}}, m.findAll([]byte("aaaaa")))
+}
+
+// TestGrepFile_Mapped tests that a mapped file, spanning several chunks,
+// gives the same output as reading it
+func TestGrepFile_Mapped(t *testing.T) {
+	var content strings.Builder
+	for i := range 60000 {
+		fmt.Fprintf(&content, "line %d of filler text\n", i)
+		if i%9999 == 0 {
+			content.WriteString("a NEEDLE here\n")
+		}
+	}
+	content.WriteString("needle at the end")
+	path := filepath.Join(t.TempDir(), "big.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content.String()), 0644))
+
+	search := func(opts *Options) string {
+		m, err := compilePattern("needle", opts)
+		require.NoError(t, err)
+
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		require.NoError(t, err)
+		defer out.Close()
+		stdout := os.Stdout
+		os.Stdout = out
+		defer func() { os.Stdout = stdout }()
+
+		matched, err := grepFile(context.Background(), output.Stdout, path, m, opts)
+		require.NoError(t, err)
+		assert.True(t, matched)
+		require.NoError(t, output.Flush())
+
+		printed, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		return string(printed)
+	}
+
+	mapped := search(&Options{MaxCount: -1, LineNumbers: true, CaseInsensitive: true})
+	read := search(&Options{MaxCount: -1, LineNumbers: true, CaseInsensitive: true, NoMmap: true})
+	assert.Equal(t, read, mapped)
+	assert.Equal(t, 8, strings.Count(mapped, "\n"))
+	assert.Contains(t, mapped, ":60008:needle at the end\n")
+}
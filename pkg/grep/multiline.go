@@ -0,0 +1,118 @@
+package grep
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+)
+
+// grepMultiline searches the whole of data at once for -U, so that a
+// match may span lines, and prints every line a match touches. Lines
+// touched by more than one match are printed once. Like other lines, -c
+// counts them and -m stops after the match that reaches the limit.
+func grepMultiline(ctx context.Context, w io.Writer, data []byte, m *matcher, opts *Options, filename string) (bool, error) {
+	delim := byte('\n')
+	eol := "\n"
+	if opts.NullData {
+		delim = 0
+		eol = "\x00"
+	}
+
+	selected := 0 // lines selected so far
+	defer func() { opts.stats.add(selected, len(data)) }()
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	locs := m.findAll(data)
+	if len(locs) == 0 {
+		return false, nil
+	}
+
+	// Quiet mode and files-only mode: the first match settles it
+	if opts.Quiet {
+		return true, nil
+	}
+	if opts.FilesOnly {
+		fmt.Fprint(w, paint(filename, color.Filename, opts)+nameEnd(opts, "\n"))
+		return true, nil
+	}
+
+	namePrefix := ""
+	if filename != "<stdin>" {
+		namePrefix = paint(filename, color.Filename, opts) + nameEnd(opts, paint(":", color.Separator, opts))
+	}
+
+	lineNum := 1 // number of the line starting at pos
+	pos := 0     // start of the first line not printed yet
+	for i := 0; i < len(locs) && (opts.MaxCount < 0 || selected < opts.MaxCount); {
+		if err := ctx.Err(); err != nil {
+			return true, err
+		}
+
+		// The lines from the one the match starts in to the one it ends
+		// in, extended by the matches that begin in them
+		start := max(pos, bytes.LastIndexByte(data[:locs[i][0]], delim)+1)
+		end := lineEnd(data, locs[i], delim)
+		group := i
+		for i++; i < len(locs) && locs[i][0] < end; i++ {
+			end = max(end, lineEnd(data, locs[i], delim))
+		}
+
+		lineNum += bytes.Count(data[pos:start], []byte{delim})
+		for lineStart := start; lineStart < end; lineNum++ {
+			line := data[lineStart:end]
+			if j := bytes.IndexByte(line, delim); j >= 0 {
+				line = line[:j]
+			}
+			selected++
+
+			if !opts.Count {
+				prefix := namePrefix
+				if opts.LineNumbers {
+					prefix += paint(fmt.Sprintf("%d", lineNum), color.LineNum, opts) + paint(":", color.Separator, opts)
+				}
+				text := highlight(string(line), clip(locs[group:i], lineStart, len(line)), opts)
+				fmt.Fprint(w, prefix, text, eol)
+			}
+			lineStart += len(line) + 1
+		}
+		pos = min(end+1, len(data))
+	}
+
+	// Print count if requested
+	if opts.Count {
+		fmt.Fprintf(w, "%s%d\n", namePrefix, selected)
+	}
+	return true, nil
+}
+
+// lineEnd returns the offset of the delimiter ending the last line loc
+// touches, or the end of data if that line is unterminated. A match that
+// ends with a delimiter ends on the line that delimiter terminates.
+func lineEnd(data []byte, loc []int, delim byte) int {
+	from := loc[1]
+	if loc[1] > loc[0] && data[loc[1]-1] == delim {
+		return loc[1] - 1
+	}
+	if i := bytes.IndexByte(data[from:], delim); i >= 0 {
+		return from + i
+	}
+	return len(data)
+}
+
+// clip returns the parts of locs within the line of length n at offset
+// start, relative to the line
+func clip(locs [][]int, start, n int) [][]int {
+	var clipped [][]int
+	for _, loc := range locs {
+		from, to := max(loc[0]-start, 0), min(loc[1]-start, n)
+		if from < to {
+			clipped = append(clipped, []int{from, to})
+		}
+	}
+	return clipped
+}
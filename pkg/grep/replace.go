@@ -0,0 +1,99 @@
+package grep
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// replaceLine substitutes the template for every match in line. $1, ${1}
+// and ${name} refer to capture groups, $$ is a literal dollar sign.
+func replaceLine(line []byte, m *matcher, opts *Options) []byte {
+	return m.re.ReplaceAll(line, []byte(opts.Replace))
+}
+
+// replaceAll applies the replacement to each line of data, honoring -m,
+// and returns the result with the number of lines that matched
+func replaceAll(data []byte, m *matcher, opts *Options) ([]byte, int) {
+	delim := byte('\n')
+	if opts.NullData {
+		delim = 0
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+	count := 0
+	for len(data) > 0 {
+		line, rest := data, []byte(nil)
+		if i := bytes.IndexByte(data, delim); i >= 0 {
+			line, rest = data[:i], data[i:]
+		}
+		data = rest
+
+		if (opts.MaxCount < 0 || count < opts.MaxCount) && m.re.Match(line) {
+			count++
+			line = replaceLine(line, m, opts)
+		}
+		out.Write(line)
+
+		// Keep the delimiter, and a missing final one missing
+		if len(data) > 0 {
+			out.WriteByte(delim)
+			data = data[1:]
+		}
+	}
+
+	return out.Bytes(), count
+}
+
+// writeFile rewrites filename with the replacement applied, reporting
+// whether any line matched. The file is only touched if its contents change.
+func writeFile(ctx context.Context, w io.Writer, filename string, m *matcher, opts *Options) (bool, error) {
+	if input.IsStdin(filename) {
+		return false, fmt.Errorf("cannot rewrite standard input")
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("not a regular file")
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	replaced, count := replaceAll(data, m, opts)
+	opts.stats.add(count, len(data))
+	if count == 0 {
+		return false, nil
+	}
+	if bytes.Equal(data, replaced) {
+		return true, nil
+	}
+
+	if dryrun.Enabled {
+		dryrun.Report("edit '%s'", filename)
+		return true, nil
+	}
+
+	if err := output.ReplaceFile(ctx, filename, info.Mode(), replaced); err != nil {
+		return true, err
+	}
+
+	if opts.FilesOnly {
+		fmt.Fprint(w, paint(filename, color.Filename, opts)+nameEnd(opts, "\n"))
+	}
+
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package grep
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// searchFunc searches one file, writing what it prints to w
+type searchFunc func(ctx context.Context, w io.Writer, filename string, m *matcher, opts *Options) (bool, error)
+
+// result is the outcome of searching one file
+type result struct {
+	out     bytes.Buffer
+	matched bool
+	err     error
+	done    chan struct{}
+}
+
+// searchFiles searches files, reporting whether any line was selected and
+// whether any file could not be searched. Several files are searched at
+// once; the output of each is collected and printed whole, in the order of
+// files or, with --unordered, as soon as the file has been searched.
+func searchFiles(ctx context.Context, files []string, search searchFunc, m *matcher, opts *Options) (bool, bool, error) {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	// A dry run's reports of what would change only read well in order
+	if jobs == 1 || len(files) == 1 || dryrun.Enabled {
+		return searchSequential(ctx, files, search, m, opts)
+	}
+
+	// Stopping early, for -q or an interrupt, ends the searches under way
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Searches may only run so far ahead of the file printed next, which
+	// bounds the output held in memory
+	window := make(chan struct{}, 4*jobs)
+	results := make([]*result, len(files))
+	for i := range results {
+		results[i] = &result{done: make(chan struct{})}
+	}
+	finished := make(chan int, len(files))
+
+	var next atomic.Int64
+	for range min(jobs, len(files)) {
+		wg.Go(func() {
+			m := m.clone()
+			for {
+				select {
+				case window <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				i := int(next.Add(1)) - 1
+				if i >= len(files) {
+					<-window
+					return
+				}
+				r := results[i]
+				r.matched, r.err = search(ctx, &r.out, files[i], m, opts)
+				close(r.done)
+				finished <- i
+			}
+		})
+	}
+
+	anyMatched, failed := false, false
+	for n := range files {
+		var r *result
+		i := n
+		if opts.Unordered {
+			select {
+			case i = <-finished:
+			case <-ctx.Done():
+				return anyMatched, failed, ctx.Err()
+			}
+			r = results[i]
+		} else {
+			r = results[i]
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				return anyMatched, failed, ctx.Err()
+			}
+		}
+
+		output.Stdout.Write(r.out.Bytes())
+		r.out = bytes.Buffer{}
+		<-window
+
+		if r.err != nil {
+			if interrupt.Interrupted(r.err) {
+				return anyMatched, failed, r.err
+			}
+			logging.PathError("Failed to grep file", files[i], r.err)
+			failed = true
+			continue
+		}
+		if r.matched {
+			anyMatched = true
+			// With -q the answer is known after the first match
+			if opts.Quiet {
+				return true, failed, nil
+			}
+		}
+	}
+	return anyMatched, failed, nil
+}
+
+// searchSequential searches files one after the other, printing as it goes
+func searchSequential(ctx context.Context, files []string, search searchFunc, m *matcher, opts *Options) (bool, bool, error) {
+	anyMatched, failed := false, false
+	for _, file := range files {
+		matched, err := search(ctx, output.Stdout, file, m, opts)
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return anyMatched, failed, err
+			}
+			logging.PathError("Failed to grep file", file, err)
+			failed = true
+			continue
+		}
+		if matched {
+			anyMatched = true
+			// With -q the answer is known after the first match
+			if opts.Quiet {
+				return true, failed, nil
+			}
+		}
+	}
+	return anyMatched, failed, nil
+}
@@ -0,0 +1,108 @@
+package grep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// searchAll runs searchFiles over files and returns what it printed
+func searchAll(t *testing.T, files []string, pattern string, opts *Options) (string, bool, bool) {
+	m, err := compilePattern(pattern, opts)
+	require.NoError(t, err)
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	matched, failed, err := searchFiles(context.Background(), files, grepFile, m, opts)
+	require.NoError(t, err)
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed), matched, failed
+}
+
+// TestSearchFiles tests that searching several files at once prints the
+// same as searching them one by one, in file order unless unordered
+func TestSearchFiles(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := range 40 {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.txt", i))
+		content := strings.Repeat(fmt.Sprintf("match %d\nother\n", i), i%5)
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+		files = append(files, name)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+	files = slices.Insert(files, 7, missing)
+
+	want, matched, failed := searchAll(t, files, "match", &Options{MaxCount: -1, LineNumbers: true, Jobs: 1})
+	assert.True(t, matched)
+	assert.True(t, failed)
+
+	got, matched, failed := searchAll(t, files, "match", &Options{MaxCount: -1, LineNumbers: true, Jobs: 4})
+	assert.Equal(t, want, got)
+	assert.True(t, matched)
+	assert.True(t, failed)
+
+	got, _, _ = searchAll(t, files, "match", &Options{MaxCount: -1, LineNumbers: true, Jobs: 4, Unordered: true})
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	slices.Sort(wantLines)
+	slices.Sort(gotLines)
+	assert.Equal(t, wantLines, gotLines)
+
+	_, matched, _ = searchAll(t, files, "match", &Options{MaxCount: -1, Quiet: true, Jobs: 4})
+	assert.True(t, matched)
+	_, matched, _ = searchAll(t, files[:7], "nothing", &Options{MaxCount: -1, Jobs: 4})
+	assert.False(t, matched)
+}
+
+// TestSearchFiles_Stats tests the --stats counts of a concurrent search,
+// in which files that fail to open are not counted as searched
+func TestSearchFiles_Stats(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "missing.txt")}
+	for i, content := range []string{"match\nother\nmatch\n", "other\n", "match"} {
+		name := filepath.Join(dir, fmt.Sprintf("f%d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+		files = append(files, name)
+	}
+
+	opts := &Options{MaxCount: -1, Count: true, Jobs: 4, Stats: "json"}
+	opts.stats = &stats{start: time.Now()}
+	m, err := compilePattern("match", opts)
+	require.NoError(t, err)
+	_, _, err = searchFiles(context.Background(), files, opts.stats.count(grepFile), m, opts)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, opts.stats.write(&out, "json"))
+	var sum summary
+	require.NoError(t, json.Unmarshal(out.Bytes(), &sum))
+	assert.Equal(t, int64(3), sum.FilesSearched)
+	assert.Equal(t, int64(2), sum.FilesMatched)
+	assert.Equal(t, int64(3), sum.LinesMatched)
+	assert.Equal(t, int64(29), sum.BytesScanned)
+
+	out.Reset()
+	require.NoError(t, opts.stats.write(&out, "text"))
+	assert.Contains(t, out.String(), "\n3 files searched\n2 files matched\n3 lines matched\n29 bytes scanned\n")
+}
@@ -0,0 +1,79 @@
+package grep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// stats counts what a search covered, for --stats. Files are searched
+// concurrently, so the counters are atomic. Methods on a nil *stats do
+// nothing, which is how searches without --stats skip the counting.
+type stats struct {
+	start    time.Time
+	searched atomic.Int64 // files searched without an error
+	matched  atomic.Int64 // files with a selected line
+	lines    atomic.Int64 // selected lines
+	bytes    atomic.Int64 // bytes read from the files
+}
+
+// summary is the --stats=json form of the counts
+type summary struct {
+	FilesSearched  int64   `json:"files_searched"`
+	FilesMatched   int64   `json:"files_matched"`
+	LinesMatched   int64   `json:"lines_matched"`
+	BytesScanned   int64   `json:"bytes_scanned"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// add counts selected lines and scanned bytes
+func (s *stats) add(lines, bytes int) {
+	if s == nil {
+		return
+	}
+	s.lines.Add(int64(lines))
+	s.bytes.Add(int64(bytes))
+}
+
+// file counts the outcome of searching one file
+func (s *stats) file(matched bool, err error) {
+	if s == nil || err != nil {
+		return
+	}
+	s.searched.Add(1)
+	if matched {
+		s.matched.Add(1)
+	}
+}
+
+// count wraps search so that every file it searches is counted
+func (s *stats) count(search searchFunc) searchFunc {
+	return func(ctx context.Context, w io.Writer, filename string, m *matcher, opts *Options) (bool, error) {
+		matched, err := search(ctx, w, filename, m, opts)
+		s.file(matched, err)
+		return matched, err
+	}
+}
+
+// write prints the summary as text or, if format is "json", as a JSON
+// object on one line
+func (s *stats) write(w io.Writer, format string) error {
+	sum := summary{
+		FilesSearched:  s.searched.Load(),
+		FilesMatched:   s.matched.Load(),
+		LinesMatched:   s.lines.Load(),
+		BytesScanned:   s.bytes.Load(),
+		ElapsedSeconds: time.Since(s.start).Seconds(),
+	}
+	if format == "json" {
+		return json.NewEncoder(w).Encode(sum)
+	}
+
+	elapsed := time.Duration(sum.ElapsedSeconds * float64(time.Second)).Round(time.Millisecond)
+	_, err := fmt.Fprintf(w, "\n%d files searched\n%d files matched\n%d lines matched\n%d bytes scanned\n%s elapsed\n",
+		sum.FilesSearched, sum.FilesMatched, sum.LinesMatched, sum.BytesScanned, elapsed)
+	return err
+}
@@ -1,20 +1,37 @@
 package head
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
 )
 
 // Options holds head configuration
 type Options struct {
-	Lines int
-	Bytes int
-	Quiet bool
+	Lines          int
+	Bytes          int
+	Quiet          bool
+	ZeroTerminated bool // lines end in NUL rather than newline
+}
+
+// delim returns the byte lines end in
+func (opts *Options) delim() byte {
+	if opts.ZeroTerminated {
+		return 0
+	}
+	return '\n'
 }
 
 // Command returns the head command
@@ -26,97 +43,223 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "head [flags] [files...]",
 		Short: "Output the first part of files",
-		Long:  `Print the first N lines (default 10) of each file to standard output. With no files, or when file is -, read standard input.`,
-		Args:  cobra.ArbitraryArgs,
+		Long: `Print the first N lines (default 10) of each file to standard output. With no files, or when file is -, read standard input.
+
+A count with a leading "-" prints all but the last N lines or bytes
+instead, as in GNU head: "head -n -2" drops the last two lines.`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			files := args
+			ctx := cmd.Context()
+			files := glob.Expand(args)
+
+			stdin := &stdin{}
 
 			// If no files specified, read from stdin
 			if len(files) == 0 {
-				return headReader(os.Stdin, opts, "", len(files) > 1)
+				return stdin.head(ctx, opts, "", false)
 			}
 
 			// Process each file
+			failed := false
 			for i, file := range files {
-				if file == "-" {
-					if err := headReader(os.Stdin, opts, "standard input", len(files) > 1); err != nil {
-						eve.Logger.Error("Failed to read stdin:", err)
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				if input.IsStdin(file) {
+					if err := stdin.head(ctx, opts, "standard input", len(files) > 1); err != nil {
+						logging.Error("Failed to read stdin:", err)
+						failed = true
 					}
 				} else {
-					if err := headFile(file, opts, len(files) > 1); err != nil {
-						eve.Logger.Error("Failed to read file", file, ":", err)
+					if err := headFile(ctx, file, opts, len(files) > 1); err != nil {
+						logging.PathError("Failed to read file", file, err)
+						failed = true
 					}
 				}
 
 				// Add blank line between files (except after last)
 				if i < len(files)-1 && len(files) > 1 {
-					fmt.Println()
+					fmt.Fprintln(output.Stdout)
 				}
 			}
 
-			return nil
+			return exitcode.Failed(cmd, failed)
 		},
 	}
 
-	cmd.Flags().IntVarP(&opts.Lines, "lines", "n", 10, "Print the first N lines")
-	cmd.Flags().IntVarP(&opts.Bytes, "bytes", "c", 0, "Print the first N bytes")
+	cmd.SetFlagErrorFunc(exitcode.Usage)
+
+	cmd.Flags().IntVarP(&opts.Lines, "lines", "n", 10, "Print the first N lines; with a leading \"-\", all but the last N")
+	cmd.Flags().IntVarP(&opts.Bytes, "bytes", "c", 0, "Print the first N bytes; with a leading \"-\", all but the last N")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Never print headers giving file names")
+	cmd.Flags().BoolVarP(&opts.ZeroTerminated, "zero-terminated", "z", false, "Lines are terminated by NUL instead of newline")
 
 	return cmd
 }
 
 // headFile reads and displays the first part of a file
-func headFile(filename string, opts *Options, multipleFiles bool) error {
-	file, err := os.Open(filename)
+func headFile(ctx context.Context, filename string, opts *Options, multipleFiles bool) error {
+	file, err := input.OpenContext(ctx, filename)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	return headReader(file, opts, filename, multipleFiles)
+	_, err = headReader(ctx, file, opts, filename, multipleFiles)
+	return err
+}
+
+// stdin is standard input, which head stops using as soon as it has the
+// lines or bytes it needs rather than when it exits
+type stdin struct {
+	closed bool
+}
+
+// head reads and displays the first part of standard input. A seekable
+// input, such as a redirected file, is then positioned just past what was
+// printed, so that `{ head -n 1; cat; } < file` prints every line once and
+// "-" given twice continues where the first one stopped. A pipe is closed,
+// so that the command writing to it stops with SIGPIPE even while head
+// goes on with other files; later reads of "-" find it empty.
+func (s *stdin) head(ctx context.Context, opts *Options, filename string, multipleFiles bool) error {
+	if s.closed {
+		_, err := headReader(ctx, strings.NewReader(""), opts, filename, multipleFiles)
+		return err
+	}
+
+	// Decoding a BOM changes byte counts, so the position would be wrong
+	start, seekErr := os.Stdin.Seek(0, io.SeekCurrent)
+	seekable := seekErr == nil && !lines.DecodeBOM
+
+	consumed, err := headReader(ctx, os.Stdin, opts, filename, multipleFiles)
+	switch {
+	case seekable:
+		if _, serr := os.Stdin.Seek(start+consumed, io.SeekStart); serr != nil && err == nil {
+			err = fmt.Errorf("failed to reposition input: %w", serr)
+		}
+	case seekErr != nil:
+		os.Stdin.Close()
+		s.closed = true
+	}
+	return err
 }
 
-// headReader reads and displays the first part from a reader
-func headReader(reader io.Reader, opts *Options, filename string, multipleFiles bool) error {
+// headReader reads and displays the first part from a reader, returning
+// the number of bytes of input it printed
+func headReader(ctx context.Context, reader io.Reader, opts *Options, filename string, multipleFiles bool) (int64, error) {
+	reader = interrupt.Reader(ctx, reader)
+
 	// Print header if multiple files and not quiet
 	if multipleFiles && !opts.Quiet && filename != "" {
-		fmt.Printf("==> %s <==\n", filename)
+		fmt.Fprintf(output.Stdout, "==> %s <==\n", filename)
 	}
 
-	// Handle byte mode
-	if opts.Bytes > 0 {
+	// Handle byte mode; a negative count leaves out the end
+	switch {
+	case opts.Bytes > 0:
 		return headBytes(reader, opts.Bytes)
+	case opts.Bytes < 0:
+		return headBytesAllBut(reader, -opts.Bytes)
+	case opts.Lines < 0:
+		return headLinesAllBut(reader, -opts.Lines, opts.delim())
 	}
 
-	// Handle line mode (default)
-	scanner := bufio.NewScanner(reader)
+	// Handle line mode (default); reading stops as soon as the last line
+	// wanted is complete
+	scanner := lines.NewReader(reader)
+	scanner.SetDelimiter(opts.delim())
 	lineCount := 0
 
-	for scanner.Scan() && lineCount < opts.Lines {
-		fmt.Println(scanner.Text())
+	for lineCount < opts.Lines && scanner.Scan() {
+		line := scanner.Text()
+		if scanner.Terminated() {
+			line += string(opts.delim())
+		}
+		fmt.Fprint(output.Stdout, line)
 		lineCount++
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading input: %w", err)
+		return scanner.Consumed(), fmt.Errorf("error reading input: %w", err)
 	}
 
-	return nil
+	return scanner.Consumed(), nil
 }
 
 // headBytes reads and displays the first N bytes
-func headBytes(reader io.Reader, n int) error {
+func headBytes(reader io.Reader, n int) (int64, error) {
 	buf := make([]byte, n)
 	bytesRead, err := io.ReadFull(reader, buf)
 
 	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		return fmt.Errorf("error reading bytes: %w", err)
+		return int64(bytesRead), fmt.Errorf("error reading bytes: %w", err)
 	}
 
 	// Write exactly the bytes we read
-	if _, err := os.Stdout.Write(buf[:bytesRead]); err != nil {
-		return fmt.Errorf("error writing output: %w", err)
+	if _, err := output.Stdout.Write(buf[:bytesRead]); err != nil {
+		return int64(bytesRead), fmt.Errorf("error writing output: %w", err)
+	}
+
+	return int64(bytesRead), nil
+}
+
+// heldLine is a line headLinesAllBut has read but not printed yet, with
+// the input consumed up to its end
+type heldLine struct {
+	text string
+	end  int64
+}
+
+// headLinesAllBut displays all but the last n lines. Every line is held
+// back until n more have been read after it.
+func headLinesAllBut(reader io.Reader, n int, delim byte) (int64, error) {
+	scanner := lines.NewReader(reader)
+	scanner.SetDelimiter(delim)
+	held := make([]heldLine, n)
+	var printed int64
+
+	for i := 0; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if scanner.Terminated() {
+			line += string(delim)
+		}
+		slot := &held[i%n]
+		if i >= n {
+			fmt.Fprint(output.Stdout, slot.text)
+			printed = slot.end
+		}
+		*slot = heldLine{line, scanner.Consumed()}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return printed, fmt.Errorf("error reading input: %w", err)
 	}
+	return printed, nil
+}
+
+// headBytesAllBut displays all but the last n bytes, holding back the
+// last n bytes read so far
+func headBytesAllBut(reader io.Reader, n int) (int64, error) {
+	var held []byte
+	chunk := make([]byte, 64*1024)
+	var printed int64
 
-	return nil
+	for {
+		read, err := reader.Read(chunk)
+		held = append(held, chunk[:read]...)
+		if extra := len(held) - n; extra > 0 {
+			if _, err := output.Stdout.Write(held[:extra]); err != nil {
+				return printed, fmt.Errorf("error writing output: %w", err)
+			}
+			printed += int64(extra)
+			held = held[:copy(held, held[extra:])]
+		}
+		if err == io.EOF {
+			return printed, nil
+		}
+		if err != nil {
+			return printed, fmt.Errorf("error reading bytes: %w", err)
+		}
+	}
 }
@@ -0,0 +1,141 @@
+package head
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// withStdin runs fn with standard input reading content from a file and
+// returns what it printed and the rest of standard input
+func withStdin(t *testing.T, content string, fn func()) (string, string) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	require.NoError(t, os.WriteFile(in, []byte(content), 0644))
+	f, err := os.Open(in)
+	require.NoError(t, err)
+	defer f.Close()
+	out, err := os.Create(filepath.Join(dir, "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	origStdin, origStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = f, out
+	defer func() { os.Stdin, os.Stdout = origStdin, origStdout }()
+
+	fn()
+	require.NoError(t, output.Flush())
+
+	rest, err := io.ReadAll(f)
+	require.NoError(t, err)
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed), string(rest)
+}
+
+// TestStdin_Seekable tests that a seekable standard input is left just
+// past the lines head printed
+func TestStdin_Seekable(t *testing.T) {
+	content := "one\ntwo\nthree\n" + strings.Repeat("more\n", 20000)
+
+	printed, rest := withStdin(t, content, func() {
+		s := &stdin{}
+		require.NoError(t, s.head(context.Background(), &Options{Lines: 1}, "", false))
+		require.NoError(t, s.head(context.Background(), &Options{Lines: 1}, "", false))
+		assert.False(t, s.closed)
+	})
+	assert.Equal(t, "one\ntwo\n", printed)
+	assert.Equal(t, content[len("one\ntwo\n"):], rest)
+
+	printed, rest = withStdin(t, content, func() {
+		s := &stdin{}
+		require.NoError(t, s.head(context.Background(), &Options{Bytes: 5}, "", false))
+	})
+	assert.Equal(t, "one\nt", printed)
+	assert.Equal(t, content[5:], rest)
+}
+
+// TestStdin_Pipe tests that a pipe is closed once head is done with it
+func TestStdin_Pipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer w.Close()
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	origStdin, origStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = r, out
+	defer func() { os.Stdin, os.Stdout = origStdin, origStdout }()
+
+	// Writing more than the pipe holds only ends once head closes it
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.Write([]byte(strings.Repeat("line\n", 1<<20)))
+		done <- err
+	}()
+
+	s := &stdin{}
+	require.NoError(t, s.head(context.Background(), &Options{Lines: 3}, "", false))
+	require.NoError(t, output.Flush())
+	assert.True(t, s.closed)
+	assert.Error(t, <-done)
+
+	// Standard input given again reads as empty
+	require.NoError(t, s.head(context.Background(), &Options{Lines: 3}, "", false))
+	require.NoError(t, output.Flush())
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "line\nline\nline\n", string(printed))
+}
+
+// TestHeadReader_AllBut tests that negative counts print all but the last
+// lines or bytes, leaving a seekable standard input at the first of them
+func TestHeadReader_AllBut(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    *Options
+		want    string
+	}{
+		{"lines", "1\n2\n3\n4\n", &Options{Lines: -2}, "1\n2\n"},
+		{"unterminated", "1\n2\n3", &Options{Lines: -1}, "1\n2\n"},
+		{"too few lines", "1\n2\n", &Options{Lines: -5}, ""},
+		{"bytes", "abcdef", &Options{Bytes: -2}, "abcd"},
+		{"too few bytes", "ab", &Options{Bytes: -3}, ""},
+		{"many bytes", strings.Repeat("x", 200000) + "tail", &Options{Bytes: -4}, strings.Repeat("x", 200000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			printed, rest := withStdin(t, tt.content, func() {
+				s := &stdin{}
+				require.NoError(t, s.head(context.Background(), tt.opts, "", false))
+			})
+			assert.Equal(t, tt.want, printed)
+			assert.Equal(t, tt.content[len(tt.want):], rest)
+		})
+	}
+}
+
+// TestHeadReader_ZeroTerminated tests -z, where lines end in NUL
+func TestHeadReader_ZeroTerminated(t *testing.T) {
+	content := "a\nb\x00c\x00d"
+	printed, _ := withStdin(t, content, func() {
+		require.NoError(t, (&stdin{}).head(context.Background(), &Options{Lines: 1, ZeroTerminated: true}, "", false))
+	})
+	assert.Equal(t, "a\nb\x00", printed)
+
+	printed, _ = withStdin(t, content, func() {
+		require.NoError(t, (&stdin{}).head(context.Background(), &Options{Lines: -1, ZeroTerminated: true}, "", false))
+	})
+	assert.Equal(t, "a\nb\x00c\x00", printed)
+}
@@ -0,0 +1,33 @@
+package input
+
+import (
+	"io"
+	"os"
+)
+
+// Stdin is the file operand that stands for standard input (or, where a
+// command writes to a named file, standard output)
+const Stdin = "-"
+
+// IsStdin reports whether a file operand refers to standard input
+func IsStdin(name string) bool {
+	return name == Stdin
+}
+
+// Open opens a file operand for reading. "-" yields standard input, which
+// is left open when the returned reader is closed.
+func Open(name string) (io.ReadCloser, error) {
+	if IsStdin(name) {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+// Name returns the name to show for a file operand in messages and
+// headers, "standard input" for "-"
+func Name(name string) string {
+	if IsStdin(name) {
+		return "standard input"
+	}
+	return name
+}
@@ -0,0 +1,197 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fetcher opens the resource a URL names for reading
+type Fetcher func(ctx context.Context, url string) (io.ReadCloser, error)
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]Fetcher{
+		"http":  fetchHTTP,
+		"https": fetchHTTP,
+		"sftp":  fetchSFTP,
+	}
+)
+
+// Timeout is how long fetching a URL may go without progress: while
+// connecting and waiting for a response, then for each read of the body.
+// Time spent writing what was read doesn't count. 0 means no limit.
+var Timeout = 30 * time.Second
+
+// Headers are extra HTTP request headers by the URL prefix they are sent
+// for, such as an Authorization header for one host. Values may refer to
+// environment variables as $NAME or ${NAME}, so secrets can stay out of
+// configuration files. Where prefixes overlap, the longest one wins.
+var Headers map[string]map[string]string
+
+// Register makes file operands that are URLs with scheme, such as "s3",
+// open through f
+func Register(scheme string, f Fetcher) {
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	fetchers[strings.ToLower(scheme)] = f
+}
+
+// Scheme returns the scheme of a file operand that is a URL, as in
+// https://example.com/file.txt, or "" for a path. It takes two letters at
+// least, so that a Windows drive such as C: is never one.
+func Scheme(name string) string {
+	scheme, _, ok := strings.Cut(name, "://")
+	if !ok || len(scheme) < 2 {
+		return ""
+	}
+	for i, c := range scheme {
+		letter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !letter && (i == 0 || !(c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.')) {
+			return ""
+		}
+	}
+	return strings.ToLower(scheme)
+}
+
+// IsRemote reports whether a file operand is a URL rather than a path
+func IsRemote(name string) bool {
+	return Scheme(name) != ""
+}
+
+// OpenContext opens a file operand for reading as Open does, fetching URLs
+// through the fetcher registered for their scheme. Fetching stops when ctx
+// is cancelled.
+func OpenContext(ctx context.Context, name string) (io.ReadCloser, error) {
+	scheme := Scheme(name)
+	if scheme == "" {
+		return Open(name)
+	}
+
+	fetchersMu.RLock()
+	fetch, ok := fetchers[scheme]
+	fetchersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s:// URLs are not supported", scheme)
+	}
+	if Timeout <= 0 {
+		return fetch(ctx, name)
+	}
+
+	timeout := fmt.Errorf("timed out after %s without data", Timeout)
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(Timeout, func() { cancel(timeout) })
+	r, err := fetch(ctx, name)
+	timer.Stop()
+	if err != nil {
+		if context.Cause(ctx) == timeout {
+			err = timeout
+		}
+		cancel(nil)
+		return nil, err
+	}
+	return &idleReader{r: r, ctx: ctx, cancel: cancel, timer: timer, timeout: timeout}, nil
+}
+
+// idleReader cancels a fetch when a read of it takes longer than Timeout
+type idleReader struct {
+	r       io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	timeout error
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.timer.Reset(Timeout)
+	n, err := r.r.Read(p)
+	r.timer.Stop()
+	if err != nil && err != io.EOF && context.Cause(r.ctx) == r.timeout {
+		err = r.timeout
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	r.cancel(nil)
+	return r.r.Close()
+}
+
+// headersFor returns the configured headers for url, those of longer
+// prefixes replacing those of shorter ones
+func headersFor(url string) http.Header {
+	prefixes := make([]string, 0, len(Headers))
+	for prefix := range Headers {
+		if matchesPrefix(url, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	slices.SortFunc(prefixes, func(a, b string) int { return len(a) - len(b) })
+
+	header := http.Header{}
+	for _, prefix := range prefixes {
+		for k, v := range Headers[prefix] {
+			header.Set(k, os.ExpandEnv(v))
+		}
+	}
+	return header
+}
+
+// matchesPrefix reports whether url starts with prefix at a boundary, so
+// that https://example.com doesn't match https://example.com.evil.org
+func matchesPrefix(url, prefix string) bool {
+	rest, ok := strings.CutPrefix(url, prefix)
+	if !ok {
+		return false
+	}
+	return rest == "" || strings.HasSuffix(prefix, "/") || strings.ContainsRune("/?#", rune(rest[0]))
+}
+
+// httpClient follows redirects without carrying configured headers along
+var httpClient = &http.Client{CheckRedirect: checkRedirect}
+
+// checkRedirect replaces the configured headers of the original URL with
+// those of the URL redirected to, so that a token for one host or prefix
+// is never sent to another. Go itself only drops a few standard headers,
+// and only when the host changes.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	for k := range headersFor(via[0].URL.String()) {
+		req.Header.Del(k)
+	}
+	for k, v := range headersFor(req.URL.String()) {
+		req.Header[k] = v
+	}
+	return nil
+}
+
+// fetchHTTP streams the body of a GET request, failing for any status
+// but 200
+func fetchHTTP(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headersFor(url) {
+		req.Header[k] = v
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
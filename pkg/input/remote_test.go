@@ -0,0 +1,220 @@
+package input
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScheme tests telling URLs from paths, Windows drives included
+func TestScheme(t *testing.T) {
+	assert.Equal(t, "https", Scheme("https://example.com/a.txt"))
+	assert.Equal(t, "s3", Scheme("S3://bucket/key"))
+	assert.Equal(t, "git+ssh", Scheme("git+ssh://host/repo"))
+	assert.Equal(t, "", Scheme(`C:\logs\app.log`))
+	assert.Equal(t, "", Scheme("c://x"))
+	assert.Equal(t, "", Scheme("dir/file://x"))
+	assert.Equal(t, "", Scheme("notes.txt"))
+}
+
+// TestOpenContext tests fetching URLs, failing statuses and schemes with
+// no fetcher, and that registered fetchers are used
+func TestOpenContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "remote\n")
+	}))
+	defer server.Close()
+
+	read := func(name string) (string, error) {
+		r, err := OpenContext(context.Background(), name)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		return string(data), err
+	}
+
+	got, err := read(server.URL + "/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "remote\n", got)
+
+	_, err = read(server.URL + "/missing.txt")
+	assert.ErrorContains(t, err, "404 Not Found")
+
+	_, err = read("nosuch://bucket/key")
+	assert.ErrorContains(t, err, "nosuch:// URLs are not supported")
+
+	Register("mem", func(_ context.Context, url string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(strings.TrimPrefix(url, "mem://"))), nil
+	})
+	got, err = read("mem://hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", got)
+}
+
+// TestOpenContext_Headers tests that configured headers are sent for the
+// URLs under their prefix only, with environment variables expanded
+func TestOpenContext_Headers(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_TOKEN", "secret")
+	old := Headers
+	defer func() { Headers = old }()
+	Headers = map[string]map[string]string{
+		server.URL + "/private":  {"Authorization": "Bearer ${TEST_TOKEN}"},
+		server.URL + "/private/": {"Authorization": "Token inner"},
+	}
+
+	for _, path := range []string{"/private", "/private/a.txt", "/privateer", "/public"} {
+		r, err := OpenContext(context.Background(), server.URL+path)
+		require.NoError(t, err)
+		r.Close()
+	}
+	assert.Equal(t, []string{"Bearer secret", "Token inner", "", ""}, got)
+}
+
+// TestOpenContext_Timeout tests that a fetch stalling for longer than
+// Timeout fails, both before the response and while reading the body
+func TestOpenContext_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			io.WriteString(w, "partial\n")
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	old := Timeout
+	defer func() { Timeout = old }()
+	Timeout = 50 * time.Millisecond
+
+	_, err := OpenContext(context.Background(), server.URL+"/headers")
+	assert.ErrorContains(t, err, "timed out after 50ms")
+
+	r, err := OpenContext(context.Background(), server.URL+"/body")
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	assert.Equal(t, "partial\n", string(data))
+	assert.ErrorContains(t, err, "timed out after 50ms")
+}
+
+// TestOpenContext_Redirect tests that configured headers are not sent
+// along when a redirect leads to another host, and are kept when it stays
+// under their prefix
+func TestOpenContext_Redirect(t *testing.T) {
+	var other []string
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = append(other, r.Header.Get("Authorization")+r.Header.Get("X-Token"))
+	}))
+	defer otherServer.Close()
+
+	var same []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, otherServer.URL+"/file.txt", http.StatusFound)
+		case "/moved":
+			http.Redirect(w, r, "/file.txt", http.StatusFound)
+		default:
+			same = append(same, r.Header.Get("Authorization")+r.Header.Get("X-Token"))
+		}
+	}))
+	defer server.Close()
+
+	old := Headers
+	defer func() { Headers = old }()
+	Headers = map[string]map[string]string{
+		server.URL: {"Authorization": "Bearer secret", "X-Token": "token"},
+	}
+
+	for _, path := range []string{"/away", "/moved"} {
+		r, err := OpenContext(context.Background(), server.URL+path)
+		require.NoError(t, err)
+		r.Close()
+	}
+	assert.Equal(t, []string{""}, other)
+	assert.Equal(t, []string{"Bearer secrettoken"}, same)
+}
+
+// TestSSHArgs tests the ssh command line and remote path for sftp:// URLs
+func TestSSHArgs(t *testing.T) {
+	args, path, err := sshArgs("sftp://deploy@build.example.com:2222/var/log/app's.log")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-p", "2222", "-s", "--", "deploy@build.example.com", "sftp"}, args)
+	assert.Equal(t, "/var/log/app's.log", path)
+
+	args, path, err = sshArgs("sftp://host/~/notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-s", "--", "host", "sftp"}, args)
+	assert.Equal(t, "notes.txt", path)
+
+	_, _, err = sshArgs("sftp://host")
+	assert.Error(t, err)
+}
+
+// TestOpenContext_SFTP tests reading sftp:// URLs through an ssh on PATH
+// that serves SFTP from this test binary
+func TestOpenContext_SFTP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh is a shell script")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\nINPUT_SFTP_SERVER=1 exec '" + os.Args[0] + "' -test.run='^TestSFTPServer$'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.log"), []byte("remote\n"), 0644))
+
+	r, err := OpenContext(context.Background(), "sftp://host"+filepath.ToSlash(filepath.Join(dir, "app.log")))
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "remote\n", string(data))
+	require.NoError(t, r.Close())
+
+	_, err = OpenContext(context.Background(), "sftp://host"+filepath.ToSlash(filepath.Join(dir, "missing.log")))
+	assert.Error(t, err)
+}
+
+// TestSFTPServer is the SFTP server the fake ssh of TestOpenContext_SFTP
+// runs, serving stdin and stdout
+func TestSFTPServer(t *testing.T) {
+	if os.Getenv("INPUT_SFTP_SERVER") == "" {
+		t.Skip("run by TestOpenContext_SFTP")
+	}
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{os.Stdin, os.Stdout})
+	require.NoError(t, err)
+	server.Serve()
+	os.Exit(0)
+}
@@ -0,0 +1,112 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// fetchSFTP streams the file an sftp:// URL names over the SFTP subsystem
+// of the system ssh client, so that keys, agents and ~/.ssh/config apply
+// as they do for ssh itself. Paths are absolute; sftp://host/~/file is
+// relative to the home directory.
+func fetchSFTP(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	args, path, err := sshArgs(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("sftp:// URLs are read with ssh: %w", err)
+	}
+
+	r := &sftpReader{cmd: cmd, stderr: stderr}
+	r.client, err = sftp.NewClientPipe(stdout, stdin)
+	if err != nil {
+		return nil, r.fail(err)
+	}
+	r.file, err = r.client.Open(path)
+	if err != nil {
+		return nil, r.fail(err)
+	}
+	return r, nil
+}
+
+// sshArgs returns the ssh arguments that start the SFTP subsystem on the
+// host rawURL names, and the path of the file there
+func sshArgs(rawURL string) ([]string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Hostname() == "" || u.Path == "" || u.Path == "/" {
+		return nil, "", fmt.Errorf("%s: expected sftp://[user@]host[:port]/path", rawURL)
+	}
+
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	path := u.Path
+	if rest, ok := strings.CutPrefix(path, "/~/"); ok {
+		path = rest
+	}
+
+	// Never prompt, as the prompt would be mixed with the protocol
+	args := []string{"-o", "BatchMode=yes"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "-s", "--", host, "sftp"), path, nil
+}
+
+// sftpReader is a file read over SFTP, which stops ssh when closed
+type sftpReader struct {
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	client *sftp.Client
+	file   *sftp.File
+}
+
+func (r *sftpReader) Read(p []byte) (int, error) {
+	return r.file.Read(p)
+}
+
+func (r *sftpReader) Close() error {
+	r.file.Close()
+	r.client.Close()
+	r.cmd.Wait()
+	return nil
+}
+
+// fail stops ssh and returns err, or what ssh reported if it exited
+// before the file could be opened
+func (r *sftpReader) fail(err error) error {
+	if r.client != nil {
+		r.client.Close()
+	} else {
+		r.cmd.Process.Kill()
+	}
+	if werr := r.cmd.Wait(); werr != nil {
+		if msg := strings.TrimSpace(r.stderr.String()); msg != "" {
+			return fmt.Errorf("ssh: %s", msg)
+		}
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package interrupt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ExitCode is the conventional exit status for a process stopped by SIGINT
+const ExitCode = 130
+
+// GracePeriod is how long a command may keep running after the first
+// signal to flush output and remove temp files before the process exits
+const GracePeriod = 3 * time.Second
+
+// Context returns a context that is canceled on SIGINT or SIGTERM.
+//
+// After the first signal, commands observe ctx.Err() and unwind. A second
+// signal, or the command not returning within GracePeriod (for example
+// while blocked reading a terminal), exits the process immediately.
+func Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(sigs)
+			return
+		}
+
+		select {
+		case <-sigs:
+		case <-time.After(GracePeriod):
+		}
+		os.Exit(ExitCode)
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// Interrupted reports whether err was caused by context cancellation
+func Interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Reader wraps r so that reads fail with ctx.Err() once ctx is canceled
+func Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, r: r}
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
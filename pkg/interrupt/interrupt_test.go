@@ -0,0 +1,36 @@
+package interrupt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReader_PassesThrough tests reading with a live context
+func TestReader_PassesThrough(t *testing.T) {
+	data, err := io.ReadAll(Reader(context.Background(), strings.NewReader("hello")))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+// TestReader_Canceled tests that reads stop once the context is canceled
+func TestReader_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := io.ReadAll(Reader(ctx, strings.NewReader("hello")))
+	assert.Error(t, err)
+	assert.True(t, Interrupted(err))
+}
+
+// TestInterrupted_Wrapped tests detection through wrapped errors
+func TestInterrupted_Wrapped(t *testing.T) {
+	assert.True(t, Interrupted(fmt.Errorf("copy failed: %w", context.Canceled)))
+	assert.True(t, !Interrupted(fmt.Errorf("copy failed")))
+	assert.True(t, !Interrupted(nil))
+}
@@ -0,0 +1,1015 @@
+package jq
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evalgo-org/claude-tools/pkg/regex"
+)
+
+// builtin runs a built-in function with its arguments unevaluated, as
+// functions like select and map take filters rather than values
+type builtin func(v any, args []node, sc *scope, emit emitter) error
+
+// builtins maps name/arity to the functions; filled in by init as they
+// refer back to eval
+var builtins map[string]builtin
+
+// funcKey identifies a function by its name and number of arguments
+func funcKey(name string, arity int) string {
+	return name + "/" + strconv.Itoa(arity)
+}
+
+func init() {
+	builtins = map[string]builtin{
+		"empty/0": func(any, []node, *scope, emitter) error { return nil },
+		"not/0":   unary(func(v any) (any, error) { return !truthy(v), nil }),
+		"error/0": unary(func(v any) (any, error) { return nil, &jqError{v} }),
+		"error/1": withValues(func(_ any, args []any) (any, error) { return nil, &jqError{args[0]} }),
+		"type/0":  unary(func(v any) (any, error) { return typeName(v), nil }),
+
+		"length/0":         unary(length),
+		"utf8bytelength/0": unary(utf8ByteLength),
+		"keys/0":           unary(keys),
+		"keys_unsorted/0":  unary(keys),
+		"has/1":            withValues(has),
+		"in/1":             withValues(func(v any, args []any) (any, error) { return has(args[0], []any{v}) }),
+		"contains/1":       withValues(func(v any, args []any) (any, error) { return contains(v, args[0]) }),
+		"add/0":            unary(add),
+		"any/0":            unary(func(v any) (any, error) { return anyAll(v, true) }),
+		"all/0":            unary(func(v any) (any, error) { return anyAll(v, false) }),
+		"flatten/0":        unary(func(v any) (any, error) { return flatten(v, math.MaxInt) }),
+		"flatten/1":        withValues(flattenDepth),
+		"reverse/0":        unary(reverse),
+		"sort/0":           unary(func(v any) (any, error) { return sortBy(v, nil) }),
+		"unique/0":         unary(func(v any) (any, error) { return uniqueBy(v, nil) }),
+		"min/0":            unary(func(v any) (any, error) { return extreme(v, nil, -1) }),
+		"max/0":            unary(func(v any) (any, error) { return extreme(v, nil, 1) }),
+		"first/0":          unary(func(v any) (any, error) { return indexValue(v, 0.0) }),
+		"last/0":           unary(func(v any) (any, error) { return indexValue(v, -1.0) }),
+		"to_entries/0":     unary(toEntries),
+		"from_entries/0":   unary(fromEntries),
+		"tostring/0":       unary(func(v any) (any, error) { return toString(v), nil }),
+		"tonumber/0":       unary(toNumber),
+		"tojson/0":         unary(func(v any) (any, error) { return toJSON(v), nil }),
+		"fromjson/0":       unary(fromJSON),
+		"env/0":            unary(func(any) (any, error) { return environ(), nil }),
+		"now/0":            unary(func(any) (any, error) { return float64(time.Now().UnixMicro()) / 1e6, nil }),
+		"todate/0":         unary(toDate),
+		"fromdate/0":       unary(fromDate),
+
+		"range/1": generator(func(_ any, args []any, emit emitter) error { return rangeValues(0.0, args[0], 1.0, emit) }),
+		"range/2": generator(func(_ any, args []any, emit emitter) error { return rangeValues(args[0], args[1], 1.0, emit) }),
+		"range/3": generator(func(_ any, args []any, emit emitter) error { return rangeValues(args[0], args[1], args[2], emit) }),
+
+		"floor/0": math1("floor", math.Floor),
+		"ceil/0":  math1("ceil", math.Ceil),
+		"round/0": math1("round", math.Round),
+		"fabs/0":  math1("fabs", math.Abs),
+		"sqrt/0":  math1("sqrt", math.Sqrt),
+
+		"ascii_downcase/0": strings1("ascii_downcase", asciiLower),
+		"ascii_upcase/0":   strings1("ascii_upcase", asciiUpper),
+		"ltrimstr/1":       withValues(trimStr(strings.TrimPrefix)),
+		"rtrimstr/1":       withValues(trimStr(strings.TrimSuffix)),
+		"startswith/1":     withValues(hasAffix("startswith", strings.HasPrefix)),
+		"endswith/1":       withValues(hasAffix("endswith", strings.HasSuffix)),
+		"split/1":          withValues(split),
+		"join/1":           withValues(join),
+		"test/1":           withValues(test),
+		"test/2":           withValues(test),
+
+		"arrays/0":    selectType("array"),
+		"objects/0":   selectType("object"),
+		"strings/0":   selectType("string"),
+		"numbers/0":   selectType("number"),
+		"booleans/0":  selectType("boolean"),
+		"nulls/0":     selectType("null"),
+		"iterables/0": selectType("array", "object"),
+		"scalars/0":   selectType("null", "boolean", "number", "string"),
+		"values/0": func(v any, _ []node, _ *scope, emit emitter) error {
+			if v == nil {
+				return nil
+			}
+			return emit(v)
+		},
+
+		"select/1": func(v any, args []node, sc *scope, emit emitter) error {
+			return eval(args[0], v, sc, func(c any) error {
+				if truthy(c) {
+					return emit(v)
+				}
+				return nil
+			})
+		},
+		"map/1": func(v any, args []node, sc *scope, emit emitter) error {
+			return eval(&array{body: &pipe{left: &iterate{term: &identity{}}, right: args[0]}}, v, sc, emit)
+		},
+		"map_values/1": mapValues,
+		"with_entries/1": func(v any, args []node, sc *scope, emit emitter) error {
+			entries, err := toEntries(v)
+			if err != nil {
+				return err
+			}
+			var mapped []any
+			err = iterateValue(entries, func(e any) error {
+				return eval(args[0], e, sc, func(x any) error {
+					mapped = append(mapped, x)
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+			obj, err := fromEntries(nonNil(mapped))
+			if err != nil {
+				return err
+			}
+			return emit(obj)
+		},
+		"recurse/0": func(v any, _ []node, _ *scope, emit emitter) error {
+			return recurseValues(v, emit)
+		},
+		"recurse/1": recurseWith,
+		"sort_by/1": byKeys(sortBy),
+		"group_by/1": byKeys(func(v any, keys []any) (any, error) {
+			return groupBy(v, keys)
+		}),
+		"unique_by/1": byKeys(uniqueBy),
+		"min_by/1":    byKeys(func(v any, keys []any) (any, error) { return extreme(v, keys, -1) }),
+		"max_by/1":    byKeys(func(v any, keys []any) (any, error) { return extreme(v, keys, 1) }),
+		"any/1":       anyAllWith(true),
+		"all/1":       anyAllWith(false),
+		"first/1": func(v any, args []node, sc *scope, emit emitter) error {
+			return limit(1, args[0], v, sc, emit)
+		},
+		"last/1": func(v any, args []node, sc *scope, emit emitter) error {
+			var last any
+			found := false
+			err := eval(args[0], v, sc, func(x any) error {
+				last, found = x, true
+				return nil
+			})
+			if err != nil || !found {
+				return err
+			}
+			return emit(last)
+		},
+		"limit/2": func(v any, args []node, sc *scope, emit emitter) error {
+			return eval(args[0], v, sc, func(n any) error {
+				count, ok := toInt(n)
+				if !ok {
+					return valueError("limit requires a number")
+				}
+				return limit(count, args[1], v, sc, emit)
+			})
+		},
+		"path/1": func(v any, args []node, sc *scope, emit emitter) error {
+			return evalPaths(args[0], []any{}, v, sc, func(p []any, _ any) error {
+				return emit(p)
+			})
+		},
+		"paths/0": func(v any, _ []node, _ *scope, emit emitter) error {
+			return recursePaths([]any{}, v, func(p []any, _ any) error {
+				if len(p) == 0 {
+					return nil
+				}
+				return emit(p)
+			})
+		},
+		"getpath/1": withValues(func(v any, args []any) (any, error) {
+			path, ok := args[0].([]any)
+			if !ok {
+				return nil, valueError("path must be specified as an array")
+			}
+			return getPath(v, path)
+		}),
+		"setpath/2": withValues(func(v any, args []any) (any, error) {
+			path, ok := args[0].([]any)
+			if !ok {
+				return nil, valueError("path must be specified as an array")
+			}
+			return setPath(v, path, args[1])
+		}),
+		"delpaths/1": withValues(func(v any, args []any) (any, error) {
+			list, ok := args[0].([]any)
+			if !ok {
+				return nil, valueError("paths must be specified as an array")
+			}
+			paths := make([][]any, len(list))
+			for i, p := range list {
+				if paths[i], ok = p.([]any); !ok {
+					return nil, valueError("path must be specified as an array")
+				}
+			}
+			return deletePaths(v, paths)
+		}),
+		"del/1": func(v any, args []node, sc *scope, emit emitter) error {
+			paths, err := collectPaths(args[0], v, sc)
+			if err != nil {
+				return err
+			}
+			result, err := deletePaths(v, paths)
+			if err != nil {
+				return err
+			}
+			return emit(result)
+		},
+		"isempty/1": func(v any, args []node, sc *scope, emit emitter) error {
+			empty := true
+			err := limit(1, args[0], v, sc, func(any) error {
+				empty = false
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return emit(empty)
+		},
+	}
+}
+
+// unary adapts a function of the input alone
+func unary(f func(v any) (any, error)) builtin {
+	return func(v any, _ []node, _ *scope, emit emitter) error {
+		result, err := f(v)
+		if err != nil {
+			return err
+		}
+		return emit(result)
+	}
+}
+
+// withValues adapts a function whose arguments are values, calling it for
+// every combination of the arguments' outputs
+func withValues(f func(v any, args []any) (any, error)) builtin {
+	return generator(func(v any, args []any, emit emitter) error {
+		result, err := f(v, args)
+		if err != nil {
+			return err
+		}
+		return emit(result)
+	})
+}
+
+// generator adapts a function of values with any number of outputs, as
+// withValues does
+func generator(f func(v any, args []any, emit emitter) error) builtin {
+	return func(v any, args []node, sc *scope, emit emitter) error {
+		values := make([]any, len(args))
+		var product func(i int) error
+		product = func(i int) error {
+			if i == len(args) {
+				return f(v, slices.Clone(values), emit)
+			}
+			return eval(args[i], v, sc, func(x any) error {
+				values[i] = x
+				return product(i + 1)
+			})
+		}
+		return product(0)
+	}
+}
+
+// math1 adapts a function of a number
+func math1(name string, f func(float64) float64) builtin {
+	return unary(func(v any) (any, error) {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, valueError(fmt.Sprintf("%s number required for %s", describe(v), name))
+		}
+		return f(n), nil
+	})
+}
+
+// strings1 adapts a function of a string
+func strings1(name string, f func(string) string) builtin {
+	return unary(func(v any) (any, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, valueError(fmt.Sprintf("%s cannot be used with %s, as it is not a string", describe(v), name))
+		}
+		return f(s), nil
+	})
+}
+
+// selectType passes on inputs of the given types
+func selectType(types ...string) builtin {
+	return func(v any, _ []node, _ *scope, emit emitter) error {
+		if slices.Contains(types, typeName(v)) {
+			return emit(v)
+		}
+		return nil
+	}
+}
+
+// limit outputs the first n outputs of f and stops it there
+func limit(n int, f node, v any, sc *scope, emit emitter) error {
+	if n <= 0 {
+		return nil
+	}
+	done := &stop{}
+	count := 0
+	err := eval(f, v, sc, func(x any) error {
+		if err := emit(x); err != nil {
+			return err
+		}
+		if count++; count == n {
+			return done
+		}
+		return nil
+	})
+	if err == done {
+		return nil
+	}
+	return err
+}
+
+// byKeys adapts a function of an array and the key f gives each element,
+// as sort_by and the like are
+func byKeys(f func(v any, keys []any) (any, error)) builtin {
+	return func(v any, args []node, sc *scope, emit emitter) error {
+		items, ok := v.([]any)
+		if !ok {
+			return valueError(describe(v) + " cannot be sorted, as it is not an array")
+		}
+		keys := make([]any, len(items))
+		for i, item := range items {
+			// Every output of f is part of the key, as in jq
+			key := []any{}
+			err := eval(args[0], item, sc, func(x any) error {
+				key = append(key, x)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			keys[i] = key
+		}
+		result, err := f(v, keys)
+		if err != nil {
+			return err
+		}
+		return emit(result)
+	}
+}
+
+// mapValues applies f to every value of an object or array, keeping the
+// first output and dropping values with none
+func mapValues(v any, args []node, sc *scope, emit emitter) error {
+	first := func(x any) (any, bool, error) {
+		var result any
+		found := false
+		err := limit(1, args[0], x, sc, func(r any) error {
+			result, found = r, true
+			return nil
+		})
+		return result, found, err
+	}
+
+	switch v := v.(type) {
+	case []any:
+		mapped := []any{}
+		for _, item := range v {
+			r, ok, err := first(item)
+			if err != nil {
+				return err
+			}
+			if ok {
+				mapped = append(mapped, r)
+			}
+		}
+		return emit(mapped)
+	case map[string]any:
+		mapped := make(map[string]any, len(v))
+		for _, k := range sortedKeys(v) {
+			r, ok, err := first(v[k])
+			if err != nil {
+				return err
+			}
+			if ok {
+				mapped[k] = r
+			}
+		}
+		return emit(mapped)
+	}
+	return valueError("cannot iterate over " + describe(v))
+}
+
+// recurseWith outputs v and then recursively what f gives for it
+func recurseWith(v any, args []node, sc *scope, emit emitter) error {
+	if err := emit(v); err != nil {
+		return err
+	}
+	return eval(args[0], v, sc, func(x any) error {
+		return recurseWith(x, args, sc, emit)
+	})
+}
+
+// anyAllWith runs any(f) or all(f), stopping at the first output that
+// decides it
+func anyAllWith(isAny bool) builtin {
+	return func(v any, args []node, sc *scope, emit emitter) error {
+		done := &stop{}
+		result := !isAny
+		err := iterateValue(v, func(item any) error {
+			return eval(args[0], item, sc, func(x any) error {
+				if truthy(x) == isAny {
+					result = isAny
+					return done
+				}
+				return nil
+			})
+		})
+		if err != nil && err != done {
+			return err
+		}
+		return emit(result)
+	}
+}
+
+func length(v any) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0.0, nil
+	case bool:
+		return nil, valueError(describe(v) + " has no length")
+	case float64:
+		return math.Abs(v), nil
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	}
+	return nil, valueError(describe(v) + " has no length")
+}
+
+func utf8ByteLength(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, valueError(describe(v) + " only strings have UTF-8 byte length")
+	}
+	return float64(len(s)), nil
+}
+
+// keys returns the sorted keys of an object or the indexes of an array
+func keys(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		result := make([]any, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			result = append(result, k)
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i := range v {
+			result[i] = float64(i)
+		}
+		return result, nil
+	}
+	return nil, valueError(describe(v) + " has no keys")
+}
+
+func has(v any, args []any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		if k, ok := args[0].(string); ok {
+			_, found := v[k]
+			return found, nil
+		}
+	case []any:
+		if i, ok := args[0].(float64); ok {
+			return i >= 0 && int(i) < len(v), nil
+		}
+	}
+	return nil, valueError(fmt.Sprintf("cannot check whether %s has a %s key", typeName(v), typeName(args[0])))
+}
+
+// contains reports whether b is within a: substrings, subsets of arrays
+// and objects, recursively
+func contains(a, b any) (any, error) {
+	if typeName(a) != typeName(b) {
+		return nil, valueError(fmt.Sprintf("%s and %s cannot have their containment checked", describe(a), describe(b)))
+	}
+	return containsValue(a, b), nil
+}
+
+func containsValue(a, b any) bool {
+	switch a := a.(type) {
+	case string:
+		return strings.Contains(a, b.(string))
+	case []any:
+		for _, wanted := range b.([]any) {
+			if !slices.ContainsFunc(a, func(x any) bool {
+				return typeName(x) == typeName(wanted) && containsValue(x, wanted)
+			}) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		for k, wanted := range b.(map[string]any) {
+			x, ok := a[k]
+			if !ok || typeName(x) != typeName(wanted) || !containsValue(x, wanted) {
+				return false
+			}
+		}
+		return true
+	}
+	return compare(a, b) == 0
+}
+
+// add adds up the elements of an array, or the values of an object
+func add(v any) (any, error) {
+	var sum any
+	err := iterateValue(v, func(x any) error {
+		var err error
+		sum, err = arith(tAdd, sum, x)
+		return err
+	})
+	return sum, err
+}
+
+func anyAll(v any, isAny bool) (any, error) {
+	result := !isAny
+	err := iterateValue(v, func(x any) error {
+		if truthy(x) == isAny {
+			result = isAny
+		}
+		return nil
+	})
+	return result, err
+}
+
+func flattenDepth(v any, args []any) (any, error) {
+	depth, ok := toInt(args[0])
+	if !ok || depth < 0 {
+		return nil, valueError("flatten depth must not be negative")
+	}
+	return flatten(v, depth)
+}
+
+func flatten(v any, depth int) (any, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, valueError("cannot flatten " + describe(v))
+	}
+	flat := []any{}
+	for _, item := range items {
+		if inner, ok := item.([]any); ok && depth > 0 {
+			f, _ := flatten(inner, depth-1)
+			flat = append(flat, f.([]any)...)
+			continue
+		}
+		flat = append(flat, item)
+	}
+	return flat, nil
+}
+
+func reverse(v any) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return []any{}, nil
+	case string:
+		runes := []rune(v)
+		slices.Reverse(runes)
+		return string(runes), nil
+	case []any:
+		r := slices.Clone(v)
+		slices.Reverse(r)
+		return nonNil(r), nil
+	}
+	return nil, valueError("cannot reverse " + describe(v))
+}
+
+// sortBy sorts an array by keys, or by the elements themselves if keys is
+// nil; equal elements keep their order
+func sortBy(v any, keys []any) (any, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, valueError(describe(v) + " cannot be sorted, as it is not an array")
+	}
+	order := sortedOrder(items, keys)
+	sorted := make([]any, len(items))
+	for i, j := range order {
+		sorted[i] = items[j]
+	}
+	return sorted, nil
+}
+
+// sortedOrder returns the indexes of items in sorted order
+func sortedOrder(items, keys []any) []int {
+	if keys == nil {
+		keys = items
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		return compare(keys[i], keys[j])
+	})
+	return order
+}
+
+// groupBy sorts an array by keys and groups elements with equal keys
+func groupBy(v any, keys []any) (any, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, valueError("cannot group " + describe(v))
+	}
+	groups := []any{}
+	var group []any
+	var prev any
+	for n, i := range sortedOrder(items, keys) {
+		if n > 0 && compare(keys[i], prev) != 0 {
+			groups = append(groups, group)
+			group = nil
+		}
+		group = append(group, items[i])
+		prev = keys[i]
+	}
+	if group != nil {
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// uniqueBy sorts an array by keys, keeping the first element of each key
+func uniqueBy(v any, keys []any) (any, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, valueError(describe(v) + " cannot be sorted, as it is not an array")
+	}
+	if keys == nil {
+		keys = items
+	}
+	unique := []any{}
+	var prev any
+	for n, i := range sortedOrder(items, keys) {
+		if n == 0 || compare(keys[i], prev) != 0 {
+			unique = append(unique, items[i])
+		}
+		prev = keys[i]
+	}
+	return unique, nil
+}
+
+// extreme returns the element with the smallest (sign -1) or largest
+// (sign 1) key, or null for an empty array
+func extreme(v any, keys []any, sign int) (any, error) {
+	items, ok := v.([]any)
+	if !ok {
+		return nil, valueError(describe(v) + " cannot have its extremes found, as it is not an array")
+	}
+	if keys == nil {
+		keys = items
+	}
+	best := -1
+	for i := range items {
+		if best < 0 {
+			best = i
+			continue
+		}
+		// Later elements win ties for max, as in jq
+		c := compare(keys[i], keys[best])
+		if sign < 0 && c < 0 || sign > 0 && c >= 0 {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, nil
+	}
+	return items[best], nil
+}
+
+// rangeValues outputs from, from+by and so on up to but not including
+// upto, or down to it if by is negative
+func rangeValues(from, upto, by any, emit emitter) error {
+	start, ok1 := from.(float64)
+	end, ok2 := upto.(float64)
+	step, ok3 := by.(float64)
+	if !ok1 || !ok2 || !ok3 {
+		return valueError("range bounds must be numeric")
+	}
+	for x := start; step > 0 && x < end || step < 0 && x > end; x += step {
+		if err := emit(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toDate formats seconds since the epoch as an ISO 8601 time in UTC
+func toDate(v any) (any, error) {
+	secs, ok := v.(float64)
+	if !ok {
+		return nil, valueError("todate requires a number, not " + describe(v))
+	}
+	return time.Unix(int64(math.Floor(secs)), 0).UTC().Format("2006-01-02T15:04:05Z"), nil
+}
+
+// fromDate parses an ISO 8601 time as seconds since the epoch
+func fromDate(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, valueError("fromdate requires a string, not " + describe(v))
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, valueError(fmt.Sprintf("date %q does not match format \"%%Y-%%m-%%dT%%H:%%M:%%SZ\"", s))
+	}
+	return float64(t.Unix()), nil
+}
+
+func toEntries(v any) (any, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, valueError(describe(v) + " has no keys")
+	}
+	entries := make([]any, 0, len(obj))
+	for _, k := range sortedKeys(obj) {
+		entries = append(entries, map[string]any{"key": k, "value": obj[k]})
+	}
+	return entries, nil
+}
+
+// fromEntries builds an object from {key, value} objects, also accepting
+// the spellings k, name, Name, Key and v, Value
+func fromEntries(v any) (any, error) {
+	obj := map[string]any{}
+	err := iterateValue(v, func(e any) error {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			return valueError("cannot use " + describe(e) + " as an object entry")
+		}
+		var key any
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if k, ok := entry[name]; ok && truthy(k) {
+				key = k
+				break
+			}
+		}
+		var value any
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if x, ok := entry[name]; ok {
+				value = x
+				break
+			}
+		}
+		switch k := key.(type) {
+		case string:
+			obj[k] = value
+		case float64, bool:
+			obj[toString(k)] = value
+		default:
+			return valueError("cannot use " + describe(key) + " as object key")
+		}
+		return nil
+	})
+	return obj, err
+}
+
+func toNumber(v any) (any, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, valueError(describe(v) + " cannot be parsed as a number")
+		}
+		return n, nil
+	}
+	return nil, valueError(describe(v) + " cannot be parsed as a number")
+}
+
+// toJSON encodes v compactly
+func toJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+func fromJSON(v any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, valueError(describe(v) + " cannot be parsed as JSON")
+	}
+	var result any
+	if err := json.Unmarshal([]byte(s), &result); err != nil {
+		return nil, valueError(fmt.Sprintf("%s cannot be parsed as JSON: %v", describe(v), err))
+	}
+	return result, nil
+}
+
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+func asciiUpper(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, s)
+}
+
+// trimStr adapts ltrimstr and rtrimstr, which leave anything that isn't
+// a string alone
+func trimStr(trim func(s, affix string) string) func(v any, args []any) (any, error) {
+	return func(v any, args []any) (any, error) {
+		s, ok := v.(string)
+		affix, ok2 := args[0].(string)
+		if !ok || !ok2 {
+			return v, nil
+		}
+		return trim(s, affix), nil
+	}
+}
+
+func hasAffix(name string, has func(s, affix string) bool) func(v any, args []any) (any, error) {
+	return func(v any, args []any) (any, error) {
+		s, ok := v.(string)
+		affix, ok2 := args[0].(string)
+		if !ok || !ok2 {
+			return nil, valueError(name + "() requires string inputs")
+		}
+		return has(s, affix), nil
+	}
+}
+
+func split(v any, args []any) (any, error) {
+	s, ok := v.(string)
+	sep, ok2 := args[0].(string)
+	if !ok || !ok2 {
+		return nil, valueError("split input and separator must be strings")
+	}
+	return splitString(s, sep), nil
+}
+
+func join(v any, args []any) (any, error) {
+	sep, ok := args[0].(string)
+	if !ok {
+		return nil, valueError("join separator must be a string")
+	}
+	var b strings.Builder
+	n := 0
+	err := iterateValue(v, func(x any) error {
+		if n > 0 {
+			b.WriteString(sep)
+		}
+		n++
+		switch x := x.(type) {
+		case nil:
+		case string:
+			b.WriteString(x)
+		case float64, bool:
+			b.WriteString(toString(x))
+		default:
+			return valueError("cannot join with " + describe(x))
+		}
+		return nil
+	})
+	return b.String(), err
+}
+
+// test matches a regex against a string, with flags such as "i" for
+// ignoring case and "x" for extended syntax
+func test(v any, args []any) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, valueError(describe(v) + " cannot be matched, as it is not a string")
+	}
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, valueError(describe(args[0]) + " cannot be matched, as it is not a string")
+	}
+	ignoreCase := false
+	if len(args) > 1 && args[1] != nil {
+		flags, ok := args[1].(string)
+		if !ok {
+			return nil, valueError(describe(args[1]) + " is not a string")
+		}
+		for _, f := range flags {
+			switch f {
+			case 'i':
+				ignoreCase = true
+			case 'x':
+				pattern = "(?x)" + pattern
+			case 'g', 'n', 'p', 's', 'l':
+			default:
+				return nil, valueError(flags + " is not a valid modifier string")
+			}
+		}
+	}
+	re, err := regex.Compile(pattern, regex.Perl, ignoreCase)
+	if err != nil {
+		return nil, valueError(fmt.Sprintf("%s (at offset 0) is not a valid regex: %v", pattern, err))
+	}
+	return re.MatchString(s), nil
+}
+
+// format applies one of the @ formats to v
+func format(name string, v any) (string, error) {
+	switch name {
+	case "text":
+		return toString(v), nil
+	case "json":
+		return toJSON(v), nil
+	case "csv", "tsv":
+		items, ok := v.([]any)
+		if !ok {
+			return "", valueError(describe(v) + " cannot be " + name + "-formatted, only an array can be")
+		}
+		fields := make([]string, len(items))
+		for i, item := range items {
+			switch item := item.(type) {
+			case nil:
+			case float64, bool:
+				fields[i] = toString(item)
+			case string:
+				if name == "csv" {
+					fields[i] = `"` + strings.ReplaceAll(item, `"`, `""`) + `"`
+				} else {
+					fields[i] = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(item)
+				}
+			default:
+				return "", valueError(describe(item) + " is not valid in a csv row")
+			}
+		}
+		if name == "csv" {
+			return strings.Join(fields, ","), nil
+		}
+		return strings.Join(fields, "\t"), nil
+	case "html":
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "'", "&#39;", `"`, "&quot;").Replace(toString(v)), nil
+	case "uri":
+		return escapeURI(toString(v)), nil
+	case "sh":
+		quote := func(x any) (string, error) {
+			switch x := x.(type) {
+			case string:
+				return "'" + strings.ReplaceAll(x, "'", `'\''`) + "'", nil
+			case []any, map[string]any:
+				return "", valueError(describe(x) + " can not be escaped for shell")
+			}
+			return toString(x), nil
+		}
+		items, ok := v.([]any)
+		if !ok {
+			return quote(v)
+		}
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			q, err := quote(item)
+			if err != nil {
+				return "", err
+			}
+			quoted[i] = q
+		}
+		return strings.Join(quoted, " "), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(toString(v))), nil
+	case "base64d":
+		s := strings.TrimRight(toString(v), "=")
+		data, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return "", valueError(describe(v) + " is not valid base64 data")
+		}
+		return string(data), nil
+	}
+	return "", valueError(name + " is not a valid format")
+}
+
+// escapeURI percent-encodes every byte but the unreserved characters
+func escapeURI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isNameChar(c) || strings.IndexByte("-.~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
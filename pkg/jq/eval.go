@@ -0,0 +1,513 @@
+package jq
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"strings"
+)
+
+// emitter receives the outputs of a filter one at a time. Filters are
+// generators: each output is passed on as soon as it is produced, so
+// `.[] | select(...) | .name` never collects the elements in between.
+type emitter func(v any) error
+
+// scope holds the variables bound by "as", reduce and foreach
+type scope struct {
+	name   string
+	value  any
+	parent *scope
+}
+
+func (s *scope) bind(name string, value any) *scope {
+	return &scope{name: name, value: value, parent: s}
+}
+
+func (s *scope) lookup(name string) (any, bool) {
+	for ; s != nil; s = s.parent {
+		if s.name == name {
+			return s.value, true
+		}
+	}
+	return nil, false
+}
+
+// jqError is an error raised by a filter, which try and ? catch. Its
+// value is what catch receives.
+type jqError struct {
+	value any
+}
+
+func (e *jqError) Error() string {
+	if s, ok := e.value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(e.value)
+	return string(data) + " (not a string)"
+}
+
+// valueError returns a catchable error with a message
+func valueError(msg string) error {
+	return &jqError{msg}
+}
+
+// downstream wraps an error returned by the emitter of try or //, which
+// come from filters after them and so must not be caught by them
+type downstream struct {
+	err error
+}
+
+func (d *downstream) Error() string {
+	return d.err.Error()
+}
+
+// guard wraps the errors of emit as downstream
+func guard(emit emitter) emitter {
+	return func(v any) error {
+		if err := emit(v); err != nil {
+			return &downstream{err}
+		}
+		return nil
+	}
+}
+
+// stop ends a generator early, as limit and first do once they have what
+// they need. Each use has its own so that nested ones don't mix.
+type stop struct{}
+
+func (*stop) Error() string {
+	return "stopped"
+}
+
+// eval runs n with input v, passing each output to emit
+func eval(n node, v any, sc *scope, emit emitter) error {
+	switch n := n.(type) {
+	case *identity:
+		return emit(v)
+
+	case *recurse:
+		return recurseValues(v, emit)
+
+	case *literal:
+		return emit(n.value)
+
+	case *varRef:
+		if value, ok := sc.lookup(n.name); ok {
+			return emit(value)
+		}
+		if n.name == "ENV" {
+			return emit(environ())
+		}
+		return valueError(fmt.Sprintf("$%s is not defined", n.name))
+
+	case *formatter:
+		s, err := format(n.name, v)
+		if err != nil {
+			return err
+		}
+		return emit(s)
+
+	case *index:
+		return eval(n.term, v, sc, func(t any) error {
+			return eval(n.key, v, sc, func(k any) error {
+				r, err := indexValue(t, k)
+				if err != nil {
+					return err
+				}
+				return emit(r)
+			})
+		})
+
+	case *slice:
+		return eval(n.term, v, sc, func(t any) error {
+			return evalOptional(n.from, v, sc, func(from any) error {
+				return evalOptional(n.to, v, sc, func(to any) error {
+					r, err := sliceValue(t, from, to)
+					if err != nil {
+						return err
+					}
+					return emit(r)
+				})
+			})
+		})
+
+	case *iterate:
+		return eval(n.term, v, sc, func(t any) error {
+			return iterateValue(t, emit)
+		})
+
+	case *try:
+		err := eval(n.body, v, sc, guard(emit))
+		if d, ok := err.(*downstream); ok {
+			return d.err
+		}
+		e, ok := err.(*jqError)
+		if !ok {
+			return err
+		}
+		if n.catch == nil {
+			return nil
+		}
+		return eval(n.catch, e.value, sc, emit)
+
+	case *pipe:
+		return eval(n.left, v, sc, func(x any) error {
+			return eval(n.right, x, sc, emit)
+		})
+
+	case *comma:
+		if err := eval(n.left, v, sc, emit); err != nil {
+			return err
+		}
+		return eval(n.right, v, sc, emit)
+
+	case *binary:
+		// Like jq, the right operand is the outer loop
+		return eval(n.right, v, sc, func(r any) error {
+			return eval(n.left, v, sc, func(l any) error {
+				if n.op >= tEqual && n.op <= tGreaterEqual {
+					return emit(compareOp(n.op, l, r))
+				}
+				result, err := arith(n.op, l, r)
+				if err != nil {
+					return err
+				}
+				return emit(result)
+			})
+		})
+
+	case *and:
+		return eval(n.left, v, sc, func(l any) error {
+			if !truthy(l) {
+				return emit(false)
+			}
+			return eval(n.right, v, sc, func(r any) error {
+				return emit(truthy(r))
+			})
+		})
+
+	case *or:
+		return eval(n.left, v, sc, func(l any) error {
+			if truthy(l) {
+				return emit(true)
+			}
+			return eval(n.right, v, sc, func(r any) error {
+				return emit(truthy(r))
+			})
+		})
+
+	case *alternative:
+		// The truthy outputs of the left side, or the right side if there
+		// are none; errors on the left count as no output
+		found := false
+		guarded := guard(emit)
+		err := eval(n.left, v, sc, func(x any) error {
+			if !truthy(x) {
+				return nil
+			}
+			found = true
+			return guarded(x)
+		})
+		if d, ok := err.(*downstream); ok {
+			return d.err
+		}
+		if _, ok := err.(*jqError); !ok && err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+		return eval(n.right, v, sc, emit)
+
+	case *negate:
+		return eval(n.operand, v, sc, func(x any) error {
+			num, ok := x.(float64)
+			if !ok {
+				return valueError(describe(x) + " cannot be negated")
+			}
+			return emit(-num)
+		})
+
+	case *ifThen:
+		return eval(n.cond, v, sc, func(c any) error {
+			switch {
+			case truthy(c):
+				return eval(n.then, v, sc, emit)
+			case n.otherwise != nil:
+				return eval(n.otherwise, v, sc, emit)
+			}
+			return emit(v)
+		})
+
+	case *bind:
+		return eval(n.source, v, sc, func(x any) error {
+			return eval(n.body, v, sc.bind(n.name, x), emit)
+		})
+
+	case *reduce:
+		return eval(n.init, v, sc, func(state any) error {
+			err := eval(n.source, v, sc, func(x any) error {
+				var last any
+				err := eval(n.update, state, sc.bind(n.name, x), func(u any) error {
+					last = u
+					return nil
+				})
+				state = last
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return emit(state)
+		})
+
+	case *foreach:
+		return eval(n.init, v, sc, func(state any) error {
+			return eval(n.source, v, sc, func(x any) error {
+				inner := sc.bind(n.name, x)
+				return eval(n.update, state, inner, func(u any) error {
+					state = u
+					if n.extract == nil {
+						return emit(u)
+					}
+					return eval(n.extract, u, inner, emit)
+				})
+			})
+		})
+
+	case *str:
+		return evalParts(n, 0, "", v, sc, emit)
+
+	case *array:
+		items := []any{}
+		if n.body != nil {
+			err := eval(n.body, v, sc, func(x any) error {
+				items = append(items, x)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return emit(items)
+
+	case *object:
+		return evalEntries(n.entries, map[string]any{}, v, sc, emit)
+
+	case *call:
+		return builtins[funcKey(n.name, len(n.args))](v, n.args, sc, emit)
+
+	case *assign:
+		return evalAssign(n, v, sc, emit)
+	}
+	return fmt.Errorf("cannot evaluate %T", n)
+}
+
+// evalOptional runs n, or outputs null if n is nil
+func evalOptional(n node, v any, sc *scope, emit emitter) error {
+	if n == nil {
+		return emit(nil)
+	}
+	return eval(n, v, sc, emit)
+}
+
+// evalParts builds the strings of an interpolation from part i on, one
+// for every combination of the parts' outputs
+func evalParts(s *str, i int, prefix string, v any, sc *scope, emit emitter) error {
+	if i == len(s.parts) {
+		return emit(prefix)
+	}
+	if lit, ok := s.parts[i].(*literal); ok {
+		return evalParts(s, i+1, prefix+lit.value.(string), v, sc, emit)
+	}
+	return eval(s.parts[i], v, sc, func(x any) error {
+		var text string
+		if s.format != "" {
+			formatted, err := format(s.format, x)
+			if err != nil {
+				return err
+			}
+			text = formatted
+		} else {
+			text = toString(x)
+		}
+		return evalParts(s, i+1, prefix+text, v, sc, emit)
+	})
+}
+
+// evalEntries builds the objects of a construction from its remaining
+// entries, one for every combination of their keys' and values' outputs
+func evalEntries(entries []entry, obj map[string]any, v any, sc *scope, emit emitter) error {
+	if len(entries) == 0 {
+		return emit(obj)
+	}
+	e := entries[0]
+	return eval(e.key, v, sc, func(k any) error {
+		key, ok := k.(string)
+		if !ok {
+			return valueError(fmt.Sprintf("object keys must be strings, not %s", describe(k)))
+		}
+		return eval(e.value, v, sc, func(x any) error {
+			// Each combination gets its own object
+			next := maps.Clone(obj)
+			next[key] = x
+			return evalEntries(entries[1:], next, v, sc, emit)
+		})
+	})
+}
+
+// recurseValues outputs v and everything inside it, depth first
+func recurseValues(v any, emit emitter) error {
+	if err := emit(v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			if err := recurseValues(item, emit); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			if err := recurseValues(v[k], emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexValue returns t[k]: an object's key, an array's element counting
+// from the end if negative, or null for what isn't there
+func indexValue(t, k any) (any, error) {
+	switch t := t.(type) {
+	case nil:
+		switch k.(type) {
+		case nil, string, float64:
+			return nil, nil
+		}
+	case map[string]any:
+		if key, ok := k.(string); ok {
+			return t[key], nil
+		}
+	case []any:
+		if i, ok := toInt(k); ok {
+			if i < 0 {
+				i += len(t)
+			}
+			if i < 0 || i >= len(t) {
+				return nil, nil
+			}
+			return t[i], nil
+		}
+	}
+
+	if key, ok := k.(string); ok {
+		return nil, valueError(fmt.Sprintf("cannot index %s with %q", typeName(t), key))
+	}
+	return nil, valueError(fmt.Sprintf("cannot index %s with %s", typeName(t), typeName(k)))
+}
+
+// sliceValue returns t[from:to] of an array or a string, whose indexes
+// count characters
+func sliceValue(t, from, to any) (any, error) {
+	var length int
+	var runes []rune
+	switch t := t.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		length = len(t)
+	case string:
+		runes = []rune(t)
+		length = len(runes)
+	default:
+		return nil, valueError(fmt.Sprintf("cannot slice %s", typeName(t)))
+	}
+
+	start, end, err := sliceBounds(length, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := t.(string); ok {
+		return string(runes[start:end]), nil
+	}
+	return t.([]any)[start:end:end], nil
+}
+
+// sliceBounds returns the indexes from:to selects of something length
+// long, with either bound null for the start or the end
+func sliceBounds(length int, from, to any) (int, int, error) {
+	bound := func(b any, def int, round func(float64) float64) (int, error) {
+		if b == nil {
+			return def, nil
+		}
+		n, ok := b.(float64)
+		if !ok {
+			return 0, valueError("start and end indices of a slice must be numbers")
+		}
+		i := int(math.Max(math.Min(round(n), float64(length)), float64(-length)))
+		if i < 0 {
+			i += length
+		}
+		return i, nil
+	}
+	start, err := bound(from, 0, math.Floor)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := bound(to, length, math.Ceil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, max(end, start), nil
+}
+
+// iterateValue outputs the elements of an array or the values of an
+// object, in key order
+func iterateValue(t any, emit emitter) error {
+	switch t := t.(type) {
+	case []any:
+		for _, item := range t {
+			if err := emit(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		for _, k := range sortedKeys(t) {
+			if err := emit(t[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return valueError("cannot iterate over " + describe(t))
+}
+
+// toString returns a string as it is and anything else as JSON
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// environ returns the environment as an object, for $ENV and env
+func environ() map[string]any {
+	env := map[string]any{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			env[k] = v
+		}
+	}
+	return env
+}
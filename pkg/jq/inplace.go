@@ -0,0 +1,216 @@
+package jq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+)
+
+// keyOrder records the order of the keys of the objects in a JSON value,
+// which decoding into a map loses, so that a rewritten file can keep it
+type keyOrder struct {
+	keys   []string
+	fields map[string]*keyOrder // the values of an object's keys
+	items  []*keyOrder          // the elements of an array
+}
+
+// keysOf returns the keys of obj: those the input had in its order, then
+// any new ones sorted. A nil order sorts them all.
+func (o *keyOrder) keysOf(obj map[string]any) []string {
+	if o == nil {
+		return sortedKeys(obj)
+	}
+	keys := make([]string, 0, len(obj))
+	for _, k := range o.keys {
+		if _, ok := obj[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	var added []string
+	for k := range obj {
+		if _, ok := o.fields[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	slices.Sort(added)
+	return append(keys, added...)
+}
+
+// field returns the order of the value of key k
+func (o *keyOrder) field(k string) *keyOrder {
+	if o == nil {
+		return nil
+	}
+	return o.fields[k]
+}
+
+// item returns the order of the i'th element
+func (o *keyOrder) item(i int) *keyOrder {
+	if o == nil || i >= len(o.items) {
+		return nil
+	}
+	return o.items[i]
+}
+
+// readOrder reads the key order of the next value from dec's tokens
+func readOrder(dec *json.Decoder) (*keyOrder, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		o := &keyOrder{fields: map[string]*keyOrder{}}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			k := key.(string)
+			if _, seen := o.fields[k]; !seen {
+				o.keys = append(o.keys, k)
+			}
+			if o.fields[k], err = readOrder(dec); err != nil {
+				return nil, err
+			}
+		}
+		_, err := dec.Token()
+		return o, err
+	case json.Delim('['):
+		o := &keyOrder{}
+		for dec.More() {
+			item, err := readOrder(dec)
+			if err != nil {
+				return nil, err
+			}
+			o.items = append(o.items, item)
+		}
+		_, err := dec.Token()
+		return o, err
+	}
+	return nil, nil
+}
+
+// processInPlace runs the filter over the values in filename and replaces
+// the file with its outputs. Nothing is written unless the filter succeeds
+// for every value.
+func processInPlace(ctx context.Context, filename string, prog node, opts *Options) error {
+	if input.IsStdin(filename) {
+		return fmt.Errorf("cannot edit standard input in place")
+	}
+	if input.IsRemote(filename) {
+		return fmt.Errorf("cannot edit '%s' in place", filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("cannot open '%s': %w", filename, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot stat '%s': %w", filename, err)
+	}
+	original, err := io.ReadAll(interrupt.Reader(ctx, file))
+	if err != nil {
+		return fmt.Errorf("cannot read '%s': %w", filename, err)
+	}
+	file.Close()
+
+	data, err := io.ReadAll(textenc.NewDecoder(bytes.NewReader(original)))
+	if err != nil {
+		return fmt.Errorf("cannot read '%s': %w", filename, err)
+	}
+	values, orders, err := decodeAll(data, !opts.SortKeys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, inputError(err))
+	}
+	if opts.SlurpMode {
+		values = []any{nonNil(values)}
+		orders = []*keyOrder{{items: orders}}
+	}
+
+	enc := newEncoder(opts, false)
+	var edited bytes.Buffer
+	outputs := 0
+	for i, value := range values {
+		err := eval(prog, value, nil, func(result any) error {
+			outputs++
+			if s, ok := result.(string); ok && opts.RawOutput {
+				edited.WriteString(s + "\n")
+				return nil
+			}
+			text, err := enc.encode(result, orders[i])
+			if err != nil {
+				return fmt.Errorf("cannot encode JSON: %w", err)
+			}
+			edited.WriteString(text + "\n")
+			return nil
+		})
+		var jqErr *jqError
+		if errors.As(err, &jqErr) {
+			return exitcode.New(5, fmt.Errorf("%s: %w", filename, err))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// An empty file is more likely a mistake in the filter than the wish
+	if outputs == 0 {
+		return exitcode.New(5, fmt.Errorf("%s: filter produced no output, leaving the file unchanged", filename))
+	}
+
+	if bytes.Equal(edited.Bytes(), original) {
+		return nil
+	}
+	if dryrun.Enabled {
+		dryrun.Report("edit '%s'", filename)
+		return nil
+	}
+
+	return output.ReplaceFile(ctx, filename, info.Mode(), edited.Bytes())
+}
+
+// decodeAll decodes every value in data, with the key order of each if
+// keepOrder is set
+func decodeAll(data []byte, keepOrder bool) ([]any, []*keyOrder, error) {
+	var values []any
+	var orders []*keyOrder
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return values, orders, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, nil, err
+		}
+		var order *keyOrder
+		if keepOrder {
+			if order, err = readOrder(json.NewDecoder(bytes.NewReader(raw))); err != nil {
+				return nil, nil, err
+			}
+		}
+		values = append(values, value)
+		orders = append(orders, order)
+	}
+}
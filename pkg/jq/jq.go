@@ -1,15 +1,24 @@
 package jq
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
 )
 
 // Options holds jq configuration
@@ -19,8 +28,13 @@ type Options struct {
 	SortKeys    bool
 	TabIndent   bool
 	ColorOutput bool
+	Monochrome  bool
 	NullInput   bool
 	SlurpMode   bool
+	InPlace     bool
+	Validate    bool // check the files instead of running a filter
+
+	color bool // resolved from -C/-M and the global --color mode
 }
 
 // Command returns the jq command
@@ -30,29 +44,104 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "jq [filter] [file...]",
 		Short: "Process JSON data with filters",
-		Long: `Process JSON data using a simple filter syntax.
-Supports basic JSON querying, filtering, and transformation.
+		Long: `Process JSON data with jq filters.
+
+A filter takes each input value and produces any number of outputs, which
+are written as they are produced: '.[] | select(.ok) | .name' passes one
+element at a time through select and .name. When a filter starts with .[]
+and the input is an array, its elements are decoded one at a time too, so
+memory stays flat however long the array is.
 
 Filter Syntax:
-  .              Identity (passthrough)
-  .key           Get object key
-  .[0]           Get array element
-  .[]            Array/object values iterator
-  .key1.key2     Nested access
-  keys           Get object keys
-  length         Get array/object/string length
-  type           Get value type`,
-		Args: cobra.MinimumNArgs(1),
+  .  .key  .[0]  .[-1]  .[2:4]  .["key"]   Identity, indexing and slices
+  .[]  ..                                 Iterate values, recurse into all
+  a | b    a, b                           Pipe, and outputs of both
+  [...]  {key: ., "k": .x, (.k): .v}      Construct arrays and objects
+  + - * / %   == != < <= > >=             Arithmetic and comparison
+  and  or  not  a // b                    Logic and alternative
+  if A then B elif C then D else E end    Conditionals
+  try A catch B   A?                      Catch errors
+  . as $x | ...   reduce/foreach .[] as $x (init; update)
+  "text \(.x)"   @csv @tsv @json @html @uri @sh @base64 @base64d
+
+Functions:
+  length keys has in contains add any all flatten reverse sort unique
+  min max first last to_entries from_entries with_entries tostring
+  tonumber tojson fromjson type select map map_values recurse sort_by
+  group_by unique_by min_by max_by limit isempty empty error
+  ascii_downcase ascii_upcase ltrimstr rtrimstr startswith endswith
+  split join test floor ceil round sqrt env range now todate fromdate
+  path paths getpath setpath del delpaths arrays objects strings ...
+
+Assignments change the values at the paths on their left: .a.b = 1 sets
+it, .items[] |= . + 1 updates each item, and += -= *= /= %= //= apply
+their operator; del(.a, .b[0]) removes paths. With -i the outputs replace
+the file they came from, written to a temporary file that is renamed over
+it. Object keys keep the order the file had them in, with new keys after
+them, unless -S sorts them.
+
+--validate takes only files, and checks them rather than running a
+filter: for syntax errors, for objects with the same key twice, and that
+a file ending in .jsonl or .ndjson holds one JSON value on each line and
+any other file exactly one value. Problems are printed as
+FILE:LINE:COLUMN: message and make the exit status 1. The jsonlint
+command does the same.
+
+With -n the filter runs once with null as its input and no input is read,
+so range(), now and object construction can build JSON from nothing.
+
+Examples:
+  jq '.[] | select(.age > 30) | .name' people.json
+  jq -i '.version = "2.0"' package.json
+  jq -n '[range(3) | {id: ., created: (now | todate)}]'
+  jq -r '.items[] | [.id, .title] | @tsv' data.json
+  jq '[.[] | .size] | add' files.json
+  jq 'group_by(.status) | map({status: .[0].status, count: length})'
+  jq --validate config/*.json events.jsonl`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.Validate {
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			filter := args[0]
-			files := args[1:]
+			ctx := cmd.Context()
+			if opts.Validate {
+				cmd.SilenceErrors = true
+				return validateFiles(ctx, args, output.Stdout)
+			}
+			prog, err := parse(args[0])
+			if err != nil {
+				return exitcode.New(3, err)
+			}
+
+			opts.resolveColor()
+			files := glob.Expand(args[1:])
 
-			if len(files) == 0 || opts.NullInput {
-				return processInput(os.Stdin, filter, opts)
+			if opts.InPlace {
+				if len(files) == 0 || opts.NullInput {
+					return exitcode.New(2, errors.New("-i needs files to edit and cannot be used with -n"))
+				}
+				for _, file := range files {
+					if err := processInPlace(ctx, file, prog, opts); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			if opts.NullInput {
+				if len(files) > 0 {
+					logging.Warn("ignoring files with -n")
+				}
+				return run(prog, nil, opts)
+			}
+			if len(files) == 0 {
+				return processInput(interrupt.Reader(ctx, os.Stdin), prog, opts)
 			}
 
 			for _, file := range files {
-				if err := processFile(file, filter, opts); err != nil {
+				if err := processFile(ctx, file, prog, opts); err != nil {
 					return err
 				}
 			}
@@ -64,326 +153,358 @@ Filter Syntax:
 	cmd.Flags().BoolVarP(&opts.RawOutput, "raw-output", "r", false, "Output raw strings")
 	cmd.Flags().BoolVarP(&opts.SortKeys, "sort-keys", "S", false, "Sort object keys")
 	cmd.Flags().BoolVar(&opts.TabIndent, "tab", false, "Use tabs for indentation")
-	cmd.Flags().BoolVarP(&opts.ColorOutput, "color-output", "C", false, "Colorize output")
-	cmd.Flags().BoolVarP(&opts.NullInput, "null-input", "n", false, "Don't read input")
+	cmd.Flags().BoolVarP(&opts.ColorOutput, "color-output", "C", false, "Colorize output even when not writing to a terminal")
+	cmd.Flags().BoolVarP(&opts.Monochrome, "monochrome-output", "M", false, "Don't colorize output")
+	cmd.Flags().BoolVarP(&opts.NullInput, "null-input", "n", false, "Run the filter once with null as its input, reading nothing")
 	cmd.Flags().BoolVarP(&opts.SlurpMode, "slurp", "s", false, "Read entire input into array")
+	cmd.Flags().BoolVarP(&opts.InPlace, "in-place", "i", false, "Replace each file with the filter's output")
+	cmd.Flags().BoolVar(&opts.Validate, "validate", false, "Check files for syntax errors and duplicate keys instead of filtering")
 
 	return cmd
 }
 
+// resolveColor decides from -C, -M and the global --color mode whether
+// output is colored
+func (opts *Options) resolveColor() {
+	switch {
+	case opts.Monochrome:
+		opts.color = false
+	case opts.ColorOutput:
+		opts.color = true
+	default:
+		opts.color = color.Enabled(os.Stdout)
+	}
+}
+
+// Filter runs filter over each JSON value read from r and writes the
+// results to standard output as the jq command does, for commands that
+// produce JSON to query, such as jsonc --jq
+func Filter(ctx context.Context, filter string, r io.Reader, opts *Options) error {
+	prog, err := parse(filter)
+	if err != nil {
+		return exitcode.New(3, err)
+	}
+	opts.resolveColor()
+	return processInput(interrupt.Reader(ctx, r), prog, opts)
+}
+
 // processFile processes a JSON file
-func processFile(filename string, filter string, opts *Options) error {
-	file, err := os.Open(filename)
+func processFile(ctx context.Context, filename string, prog node, opts *Options) error {
+	file, err := input.OpenContext(ctx, filename)
 	if err != nil {
 		return fmt.Errorf("cannot open '%s': %w", filename, err)
 	}
 	defer file.Close()
 
-	return processInput(file, filter, opts)
+	return processInput(interrupt.Reader(ctx, file), prog, opts)
 }
 
-// processInput processes JSON from input
-func processInput(reader io.Reader, filter string, opts *Options) error {
+// processInput runs the filter over each JSON value in the input. The
+// values may span lines or share them, as in a JSON Lines file.
+func processInput(reader io.Reader, prog node, opts *Options) error {
+	// JSON written by Windows editors often carries a BOM or is UTF-16
+	dec := json.NewDecoder(textenc.NewDecoder(reader))
+
 	if opts.SlurpMode {
-		return processSlurp(reader, filter, opts)
+		return processSlurp(dec, prog, opts)
 	}
 
-	scanner := bufio.NewScanner(reader)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // 1MB max
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
+	rest, stream := streamed(prog)
+	for {
+		var err error
+		if stream {
+			err = processStream(dec, prog, rest, opts)
+		} else {
+			var data interface{}
+			if err = dec.Decode(&data); err == nil {
+				err = run(prog, data, opts)
+			}
 		}
-
-		var data interface{}
-		if err := json.Unmarshal([]byte(line), &data); err != nil {
-			return fmt.Errorf("invalid JSON: %w", err)
+		if err == io.EOF {
+			return nil
 		}
-
-		result, err := applyFilter(data, filter)
 		if err != nil {
-			return err
-		}
-
-		if err := outputResult(result, opts); err != nil {
-			return err
+			return inputError(err)
 		}
 	}
-
-	return scanner.Err()
 }
 
 // processSlurp reads all JSON into array
-func processSlurp(reader io.Reader, filter string, opts *Options) error {
-	var items []interface{}
-	scanner := bufio.NewScanner(reader)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
+func processSlurp(dec *json.Decoder, prog node, opts *Options) error {
+	items := []interface{}{}
+	for {
 		var data interface{}
-		if err := json.Unmarshal([]byte(line), &data); err != nil {
-			return fmt.Errorf("invalid JSON: %w", err)
+		err := dec.Decode(&data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return inputError(err)
 		}
 		items = append(items, data)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
-	}
+	return run(prog, items, opts)
+}
 
-	result, err := applyFilter(items, filter)
-	if err != nil {
-		return err
+// inputError reports input that isn't JSON as such
+func inputError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("invalid JSON: %w", err)
 	}
-
-	return outputResult(result, opts)
+	return err
 }
 
-// applyFilter applies a filter to JSON data
-func applyFilter(data interface{}, filter string) (interface{}, error) {
-	filter = strings.TrimSpace(filter)
-
-	// Identity filter
-	if filter == "." {
-		return data, nil
+// streamed returns what is left of the filter after a leading .[], so
+// that the elements of a top-level array can be run through it one at a
+// time as they are decoded, rather than after the whole array is in memory
+func streamed(prog node) (node, bool) {
+	switch n := prog.(type) {
+	case *iterate:
+		if _, ok := n.term.(*identity); ok {
+			return &identity{}, true
+		}
+		if rest, ok := streamed(n.term); ok {
+			return &iterate{term: rest}, true
+		}
+	case *index:
+		// A computed key would see the whole array as its input
+		if _, ok := n.key.(*literal); !ok {
+			return nil, false
+		}
+		if rest, ok := streamed(n.term); ok {
+			return &index{term: rest, key: n.key}, true
+		}
+	case *pipe:
+		if rest, ok := streamed(n.left); ok {
+			if _, ok := rest.(*identity); ok {
+				return n.right, true
+			}
+			return &pipe{left: rest, right: n.right}, true
+		}
 	}
+	return nil, false
+}
 
-	// Built-in functions
-	switch filter {
-	case "keys":
-		return getKeys(data)
-	case "length":
-		return getLength(data)
-	case "type":
-		return getType(data), nil
+// processStream reads the next value for a filter starting with .[]: an
+// array's elements go through rest as they are read, and anything else is
+// read whole and goes through the filter
+func processStream(dec *json.Decoder, prog, rest node, opts *Options) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
 	}
-
-	// Array iterator
-	if filter == ".[]" {
-		return iterateArray(data)
+	if tok != json.Delim('[') {
+		data, err := finishValue(dec, tok)
+		if err != nil {
+			return err
+		}
+		return run(prog, data, opts)
 	}
 
-	// Path-based access
-	if strings.HasPrefix(filter, ".") {
-		return accessPath(data, filter[1:])
+	for dec.More() {
+		var item interface{}
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		if err := run(rest, item, opts); err != nil {
+			return err
+		}
 	}
-
-	return nil, fmt.Errorf("unsupported filter: %s", filter)
+	_, err = dec.Token()
+	return err
 }
 
-// accessPath accesses nested JSON path
-func accessPath(data interface{}, path string) (interface{}, error) {
-	if path == "" {
-		return data, nil
+// finishValue decodes the rest of a value whose first token has been read
+func finishValue(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	if tok != json.Delim('{') {
+		if _, ok := tok.(json.Delim); ok {
+			return nil, fmt.Errorf("invalid JSON: unexpected %v", tok)
+		}
+		return tok, nil
 	}
 
-	parts := parsePath(path)
-	current := data
-
-	for _, part := range parts {
-		var err error
-		current, err = accessPart(current, part)
+	obj := map[string]interface{}{}
+	for dec.More() {
+		key, err := dec.Token()
 		if err != nil {
 			return nil, err
 		}
-	}
-
-	return current, nil
-}
-
-// parsePath parses dot-separated path
-func parsePath(path string) []string {
-	var parts []string
-	var current strings.Builder
-	inBracket := false
-
-	for i := 0; i < len(path); i++ {
-		ch := path[i]
-
-		if ch == '[' {
-			if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
-			}
-			inBracket = true
-			current.WriteByte(ch)
-		} else if ch == ']' {
-			current.WriteByte(ch)
-			parts = append(parts, current.String())
-			current.Reset()
-			inBracket = false
-		} else if ch == '.' && !inBracket {
-			if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
-			}
-		} else {
-			current.WriteByte(ch)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
 		}
+		obj[key.(string)] = value
 	}
-
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
+	if _, err := dec.Token(); err != nil {
+		return nil, err
 	}
-
-	return parts
+	return obj, nil
 }
 
-// accessPart accesses single path part
-func accessPart(data interface{}, part string) (interface{}, error) {
-	// Array index access: [0], [1], etc.
-	if strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]") {
-		indexStr := part[1 : len(part)-1]
-		index, err := strconv.Atoi(indexStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid array index: %s", part)
-		}
+// run evaluates the filter for one input, writing each output as it is
+// produced
+func run(prog node, data interface{}, opts *Options) error {
+	err := eval(prog, data, nil, func(result interface{}) error {
+		return outputSingle(result, opts)
+	})
+	var jqErr *jqError
+	if errors.As(err, &jqErr) {
+		return exitcode.New(5, err)
+	}
+	return err
+}
 
-		arr, ok := data.([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("not an array")
+// outputSingle outputs single result
+func outputSingle(result interface{}, opts *Options) error {
+	// Raw output for strings
+	if opts.RawOutput {
+		if str, ok := result.(string); ok {
+			fmt.Fprintln(output.Stdout, str)
+			return nil
 		}
+	}
 
-		if index < 0 || index >= len(arr) {
-			return nil, fmt.Errorf("index out of bounds: %d", index)
+	if opts.color {
+		text, err := newEncoder(opts, true).encode(result, nil)
+		if err != nil {
+			return fmt.Errorf("cannot encode JSON: %w", err)
 		}
+		fmt.Fprintln(output.Stdout, text)
+		return nil
+	}
 
-		return arr[index], nil
+	// Handle nil
+	if result == nil {
+		fmt.Fprintln(output.Stdout, "null")
+		return nil
 	}
 
-	// Object key access
-	obj, ok := data.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("not an object")
+	// JSON output
+	var data []byte
+	var err error
+
+	if opts.Compact {
+		data, err = json.Marshal(result)
+	} else if opts.TabIndent {
+		data, err = json.MarshalIndent(result, "", "\t")
+	} else {
+		data, err = json.MarshalIndent(result, "", "  ")
 	}
 
-	val, exists := obj[part]
-	if !exists {
-		return nil, nil
+	if err != nil {
+		return fmt.Errorf("cannot encode JSON: %w", err)
 	}
 
-	return val, nil
+	fmt.Fprintln(output.Stdout, string(data))
+	return nil
 }
 
-// iterateArray returns array/object values
-func iterateArray(data interface{}) (interface{}, error) {
-	switch v := data.(type) {
-	case []interface{}:
-		return v, nil
-	case map[string]interface{}:
-		values := make([]interface{}, 0, len(v))
-		for _, val := range v {
-			values = append(values, val)
-		}
-		return values, nil
-	default:
-		return nil, fmt.Errorf("cannot iterate over %T", data)
-	}
+// encoder renders values like json.MarshalIndent, or json.Marshal with
+// -c, optionally wrapping each token in jq's default colors
+type encoder struct {
+	indent  string
+	compact bool
+	color   bool
 }
 
-// getKeys returns object keys
-func getKeys(data interface{}) (interface{}, error) {
-	obj, ok := data.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("keys only works on objects")
+// newEncoder returns an encoder for the output options
+func newEncoder(opts *Options, color bool) *encoder {
+	e := &encoder{indent: "  ", compact: opts.Compact, color: color}
+	if opts.TabIndent {
+		e.indent = "\t"
 	}
+	return e
+}
 
-	keys := make([]string, 0, len(obj))
-	for k := range obj {
-		keys = append(keys, k)
+// encode renders v, with the keys of its objects in the order given by
+// order, or sorted if it is nil
+func (e *encoder) encode(v interface{}, order *keyOrder) (string, error) {
+	var b strings.Builder
+	if err := e.write(&b, v, order, 0); err != nil {
+		return "", err
 	}
+	return b.String(), nil
+}
 
-	return keys, nil
+// paint wraps a token in a color if colors are on
+func (e *encoder) paint(s, sgr string) string {
+	return color.Paint(e.color, s, sgr)
 }
 
-// getLength returns length of array/object/string
-func getLength(data interface{}) (interface{}, error) {
-	switch v := data.(type) {
-	case []interface{}:
-		return len(v), nil
-	case map[string]interface{}:
-		return len(v), nil
-	case string:
-		return len(v), nil
-	default:
-		return nil, fmt.Errorf("length not supported for %T", data)
+// write appends the encoding of v at the given nesting depth
+func (e *encoder) write(b *strings.Builder, v interface{}, order *keyOrder, depth int) error {
+	newline := func(d int) {
+		if !e.compact {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat(e.indent, d))
+		}
 	}
-}
 
-// getType returns JSON type
-func getType(data interface{}) string {
-	switch data.(type) {
-	case nil:
-		return "null"
-	case bool:
-		return "boolean"
-	case float64:
-		return "number"
-	case string:
-		return "string"
-	case []interface{}:
-		return "array"
+	switch val := v.(type) {
 	case map[string]interface{}:
-		return "object"
-	default:
-		return "unknown"
-	}
-}
+		if len(val) == 0 {
+			b.WriteString(e.paint("{}", color.JSONDelim))
+			return nil
+		}
 
-// outputResult outputs filtered result
-func outputResult(result interface{}, opts *Options) error {
-	// Handle array iterator results
-	if arr, ok := result.([]interface{}); ok && !opts.SlurpMode {
-		for _, item := range arr {
-			if err := outputSingle(item, opts); err != nil {
+		b.WriteString(e.paint("{", color.JSONDelim))
+		for i, k := range order.keysOf(val) {
+			if i > 0 {
+				b.WriteString(e.paint(",", color.JSONDelim))
+			}
+			newline(depth + 1)
+			key, err := json.Marshal(k)
+			if err != nil {
+				return err
+			}
+			b.WriteString(e.paint(string(key), color.JSONKey))
+			b.WriteString(e.paint(":", color.JSONDelim))
+			if !e.compact {
+				b.WriteByte(' ')
+			}
+			if err := e.write(b, val[k], order.field(k), depth+1); err != nil {
 				return err
 			}
 		}
+		newline(depth)
+		b.WriteString(e.paint("}", color.JSONDelim))
 		return nil
-	}
-
-	return outputSingle(result, opts)
-}
 
-// outputSingle outputs single result
-func outputSingle(result interface{}, opts *Options) error {
-	// Raw output for strings
-	if opts.RawOutput {
-		if str, ok := result.(string); ok {
-			fmt.Println(str)
+	case []interface{}:
+		if len(val) == 0 {
+			b.WriteString(e.paint("[]", color.JSONDelim))
 			return nil
 		}
-	}
-
-	// Handle nil
-	if result == nil {
-		fmt.Println("null")
+		b.WriteString(e.paint("[", color.JSONDelim))
+		for i, item := range val {
+			if i > 0 {
+				b.WriteString(e.paint(",", color.JSONDelim))
+			}
+			newline(depth + 1)
+			if err := e.write(b, item, order.item(i), depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		b.WriteString(e.paint("]", color.JSONDelim))
 		return nil
 	}
 
-	// JSON output
-	var output []byte
-	var err error
-
-	if opts.Compact {
-		output, err = json.Marshal(result)
-	} else if opts.TabIndent {
-		output, err = json.MarshalIndent(result, "", "\t")
-	} else {
-		output, err = json.MarshalIndent(result, "", "  ")
-	}
-
+	encoded, err := json.Marshal(v)
 	if err != nil {
-		return fmt.Errorf("cannot encode JSON: %w", err)
+		return err
 	}
 
-	fmt.Println(string(output))
+	sgr := color.JSONScalar
+	switch v.(type) {
+	case nil:
+		sgr = color.JSONNull
+	case string:
+		sgr = color.JSONString
+	}
+	b.WriteString(e.paint(string(encoded), sgr))
 	return nil
 }
@@ -0,0 +1,278 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// evalAll runs filter over the JSON input and returns its outputs as
+// compact JSON
+func evalAll(t *testing.T, filter, input string) ([]string, error) {
+	prog, err := parse(filter)
+	require.NoError(t, err, filter)
+
+	var data any
+	require.NoError(t, json.Unmarshal([]byte(input), &data))
+
+	var outputs []string
+	err = eval(prog, data, nil, func(v any) error {
+		outputs = append(outputs, toJSON(v))
+		return nil
+	})
+	return outputs, err
+}
+
+const people = `[{"name": "alice", "age": 30, "tags": ["a", "b"]}, {"name": "bob", "age": 40, "tags": []}, {"name": "carol", "age": 35}]`
+
+// TestEval tests filters against their expected outputs
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{"pipeline", `.[] | select(.age > 30) | .name`, []string{`"bob"`, `"carol"`}},
+		{"field of each", `.[].name`, []string{`"alice"`, `"bob"`, `"carol"`}},
+		{"index and slice", `.[-1].name, .[1:][0].age, .[0].name[1:3]`, []string{`"carol"`, `40`, `"li"`}},
+		{"missing is null", `.[0].nope.deeper, .[7]`, []string{`null`, `null`}},
+		{"optional", `.[0].name.x?, (.[] | .tags[]?)`, []string{`"a"`, `"b"`}},
+		{"comma and product", `[(1, 2) + (10, 20)]`, []string{`[11,12,21,22]`}},
+		{"construct", `[.[] | {name, n: (.tags | length)}]`, []string{`[{"n":2,"name":"alice"},{"n":0,"name":"bob"},{"n":0,"name":"carol"}]`}},
+		{"object generators", `{k: (1, 2)} | .k`, []string{`1`, `2`}},
+		{"computed key", `.[0] | {(.name): .age}`, []string{`{"alice":30}`}},
+		{"map and add", `map(.age) | add`, []string{`105`}},
+		{"sort_by", `sort_by(-.age) | map(.name)`, []string{`["bob","carol","alice"]`}},
+		{"group_by", `group_by(.age > 32) | map(length)`, []string{`[1,2]`}},
+		{"min_by max_by", `(min_by(.age), max_by(.age)) | .name`, []string{`"alice"`, `"bob"`}},
+		{"alternative", `.[] | .tags // "none"`, []string{`["a","b"]`, `[]`, `"none"`}},
+		{"if", `.[] | if .age > 35 then "old" elif .age > 30 then "mid" else "young" end`, []string{`"young"`, `"old"`, `"mid"`}},
+		{"variables", `.[0] as $p | .[1] | [$p.name, .name]`, []string{`["alice","bob"]`}},
+		{"reduce", `reduce .[] as $p (0; . + $p.age)`, []string{`105`}},
+		{"foreach", `[foreach .[] as $p (0; . + 1; [$p.name, .])]`, []string{`[["alice",1],["bob",2],["carol",3]]`}},
+		{"limit stops", `[limit(2; .[] | .name)], first(.[] | .age)`, []string{`["alice","bob"]`, `30`}},
+		{"recurse", `[.. | numbers]`, []string{`[30,40,35]`}},
+		{"interpolation", `.[0] | "\(.name) is \(.age)"`, []string{`"alice is 30"`}},
+		{"formats", `.[0] | [.name, .age] | @csv, @tsv, @json`, []string{`"\"alice\",30"`, `"alice\t30"`, `"[\"alice\",30]"`}},
+		{"strings", `"a,b" | split(","), ascii_upcase, test("B"; "i"), startswith("a"), ltrimstr("a,")`, []string{`["a","b"]`, `"A,B"`, `true`, `true`, `"b"`}},
+		{"to and from entries", `.[0] | with_entries(select(.key != "tags")) | to_entries | map(.key)`, []string{`["age","name"]`}},
+		{"comparison order", `[null, true, false, 1, "a", [], {}] | sort`, []string{`[null,false,true,1,"a",[],{}]`}},
+		{"arithmetic", `[10 % 3, 7 / 2, "ab" * 2, ([1, 2, 2] - [2]), ({"a": {"b": 1}} * {"a": {"c": 2}})]`, []string{`[1,3.5,"abab",[1],{"a":{"b":1,"c":2}}]`}},
+		{"logic", `[true and (true, false), false or false, (null | not)]`, []string{`[true,false,false,true]`}},
+		{"try catch", `try error("boom") catch ., [.[] | try error(.name) catch .]`, []string{`"boom"`, `["alice","bob","carol"]`}},
+		{"unique and flatten", `[[1, [2]], [1]] | flatten, unique`, []string{`[1,2,1]`, `[[1],[1,[2]]]`}},
+		{"length", `map(.name | length), ("héllo" | length, utf8bytelength)`, []string{`[5,3,5]`, `5`, `6`}},
+		{"has and contains", `.[0] | has("age"), has("x"), (.tags | contains(["a"]))`, []string{`true`, `false`, `true`}},
+		{"isempty", `isempty(empty), isempty(.[])`, []string{`true`, `false`}},
+		{"assign", `.[0].age = 31 | .[0].age, (.[3].name = "dan" | length)`, []string{`31`, `4`}},
+		{"assign each output", `.[0] | .age = (1, 2) | .age`, []string{`1`, `2`}},
+		{"update", `map(.age |= . + 1) | map(.age)`, []string{`[31,41,36]`}},
+		{"update to empty deletes", `.[0].tags | (.[] | select(. == "a")) |= empty`, []string{`["b"]`}},
+		{"arithmetic assign", `.[0] | .age += 5 | .age -= 1 | .n //= 7 | [.age, .n]`, []string{`[34,7]`}},
+		{"slice assign", `[1, 2, 3] | .[1:] = ["x"]`, []string{`[1,"x"]`}},
+		{"del", `del(.[1:]) | map(.name), (.[0] | del(.tags[0], .age) | keys)`, []string{`["alice"]`, `["name","tags"]`}},
+		{"paths", `.[0].tags | [paths], [path(.[-1], ..)], getpath([1])`, []string{`[[0],[1]]`, `[[-1],[],[0],[1]]`, `"b"`}},
+		{"range", `[range(3)], [range(1; 6; 2)], [range(3; 0; -1)], [range(0; 3; 0)]`, []string{`[0,1,2]`, `[1,3,5]`, `[3,2,1]`, `[]`}},
+		{"dates", `0, 86400.5 | todate, (todate | fromdate)`, []string{`"1970-01-01T00:00:00Z"`, `0`, `"1970-01-02T00:00:00Z"`, `86400`}},
+		{"setpath delpaths", `null | setpath(["a", 1]; 1), ({"a": 1, "b": 2} | delpaths([["a"]]))`, []string{`{"a":[null,1]}`, `{"b":2}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evalAll(t, tt.filter, people)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestEval_Errors tests that errors stop a filter after the outputs before
+// them and can be caught
+func TestEval_Errors(t *testing.T) {
+	got, err := evalAll(t, `.[] | .name | ascii_upcase, .x`, people)
+	assert.ErrorContains(t, err, `cannot index string with "x"`)
+	assert.Equal(t, []string{`"ALICE"`}, got)
+
+	_, err = evalAll(t, `{} - 1`, `null`)
+	assert.ErrorContains(t, err, "cannot be subtracted")
+
+	_, err = evalAll(t, `1 / 0`, `null`)
+	assert.ErrorContains(t, err, "divisor is zero")
+
+	// An error after try is not caught by it
+	_, err = evalAll(t, `try (1, 2) | error("late")`, `null`)
+	assert.ErrorContains(t, err, "late")
+
+	got, err = evalAll(t, `(1, error("x"), 2) // 3`, `null`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`1`}, got)
+}
+
+// TestParse_Errors tests that invalid filters are rejected
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`.[`, "unexpected end of filter"},
+		{`.a |`, "unexpected end of filter"},
+		{`nosuch`, "nosuch/0 is not defined"},
+		{`select(1; 2)`, "select/2 is not defined"},
+		{`1 == 2 == 3`, "unexpected =="},
+		{`"abc`, "unterminated string"},
+		{`if . then 1`, "unexpected end of filter"},
+		{`{(.a)}`, "unexpected }"},
+		{`.a = .b = 1`, "unexpected ="},
+	}
+
+	for _, tt := range tests {
+		_, err := parse(tt.filter)
+		if assert.Error(t, err, tt.filter) {
+			assert.Contains(t, err.Error(), tt.want, tt.filter)
+		}
+	}
+}
+
+// TestStreamed tests which filters can take the elements of an array as
+// they are decoded
+func TestStreamed(t *testing.T) {
+	tests := map[string]bool{
+		`.[]`:                       true,
+		`.[] | select(.ok) | .name`: true,
+		`.[].name`:                  true,
+		`.[][0]`:                    true,
+		`.[][.k]`:                   false,
+		`.`:                         false,
+		`.a[]`:                      false,
+		`[.[]]`:                     false,
+		`.[], 1`:                    false,
+	}
+	for filter, want := range tests {
+		prog, err := parse(filter)
+		require.NoError(t, err)
+		_, ok := streamed(prog)
+		assert.Equal(t, want, ok, filter)
+	}
+}
+
+// TestProcessInput tests reading several values, values spanning lines and
+// streaming a top-level array
+func TestProcessInput(t *testing.T) {
+	run := func(filter, input string, opts *Options) string {
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		require.NoError(t, err)
+		defer out.Close()
+
+		stdout := os.Stdout
+		os.Stdout = out
+		defer func() { os.Stdout = stdout }()
+
+		prog, err := parse(filter)
+		require.NoError(t, err)
+		require.NoError(t, processInput(strings.NewReader(input), prog, opts))
+		require.NoError(t, output.Flush())
+
+		printed, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		return string(printed)
+	}
+
+	compact := &Options{Compact: true}
+	assert.Equal(t, "1\n2\n3\n", run(".a", `{"a": 1} {"a": 2}`+"\n"+`{"a":`+"\n"+`3}`, compact))
+	assert.Equal(t, "\"bob\"\n", run(".[] | select(.age > 35) | .name", people, compact))
+	assert.Equal(t, "1\n2\n", run(".[]", `{"x": 1, "y": 2}`, compact))
+	assert.Equal(t, "[1,2]\n", run(".", `[1, 2]`, compact))
+	assert.Equal(t, "2\n", run("length", `1 2`, &Options{SlurpMode: true}))
+	assert.Equal(t, "a\n", run(".[0]", `["a"]`, &Options{RawOutput: true}))
+}
+
+// TestEval_InvalidPath tests that assigning to something that isn't a
+// path is an error
+func TestEval_InvalidPath(t *testing.T) {
+	_, err := evalAll(t, `.[0].age + 1 = 3`, people)
+	assert.ErrorContains(t, err, "invalid path expression with result number (31)")
+}
+
+// TestProcessInPlace tests that -i rewrites a file with the filter's output,
+// keeping its mode and its key order unless -S is given
+func TestProcessInPlace(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "package.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"name": "x", "version": "1.0", "b": {"z": 1, "a": 2}}`), 0600))
+
+	prog, err := parse(`.version = "2.0" | .b.m = 3`)
+	require.NoError(t, err)
+	require.NoError(t, processInPlace(context.Background(), file, prog, &Options{Compact: true}))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"x","version":"2.0","b":{"z":1,"a":2,"m":3}}`+"\n", string(data))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// -S sorts the keys
+	prog, err = parse(`.`)
+	require.NoError(t, err)
+	require.NoError(t, processInPlace(context.Background(), file, prog, &Options{SortKeys: true}))
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"b\": {\n    \"a\": 2,\n    \"m\": 3,\n    \"z\": 1\n  },\n  \"name\": \"x\",\n  \"version\": \"2.0\"\n}\n", string(data))
+
+	// A failing filter or one with no output leaves the file alone
+	for _, filter := range []string{`error("no")`, `empty`} {
+		prog, err = parse(filter)
+		require.NoError(t, err)
+		err = processInPlace(context.Background(), file, prog, &Options{})
+		assert.Equal(t, 5, exitcode.From(err), filter)
+		after, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, data, after, filter)
+	}
+}
+
+// TestValidateValue tests finding duplicate keys at any depth, syntax
+// errors and extra values, with their lines and columns
+func TestValidateValue(t *testing.T) {
+	for src, want := range map[string][]problem{
+		`{"a": [{"b": 1}, {"b": 2}]}`:                      nil,
+		"{\n  \"a\": 1,\n  \"a\": {\"x\": 1, \"x\": 2}\n}": {{3, 3, `duplicate key "a"`}, {3, 17, `duplicate key "x"`}},
+		`{"a": }`: {{1, 7, "missing value after object key"}},
+		"[1, 2":   {{1, 6, "unexpected end of JSON input"}},
+		"{}\n[]":  {{2, 1, "more than one JSON value"}},
+		"  ":      {{1, 1, "no JSON value"}},
+	} {
+		assert.Equal(t, want, validateValue([]byte(src)), src)
+	}
+}
+
+// TestValidateFiles tests that JSON Lines files are checked line by line
+// and that problems make the status 1
+func TestValidateFiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	events := filepath.Join(dir, "events.jsonl")
+	require.NoError(t, os.WriteFile(good, []byte(`{"a": 1}`), 0644))
+	require.NoError(t, os.WriteFile(events, []byte("{\"a\": 1}\n\n{\"b\":\n{\"c\": 1, \"c\": 2}\n"), 0644))
+
+	var out strings.Builder
+	require.NoError(t, validateFiles(context.Background(), []string{good}, &out))
+	assert.Empty(t, out.String())
+
+	err := validateFiles(context.Background(), []string{good, events}, &out)
+	assert.Equal(t, 1, exitcode.From(err))
+	assert.Equal(t, events+":2:1: empty line\n"+
+		events+":3:6: unexpected end of JSON input\n"+
+		events+":4:10: duplicate key \"c\"\n", out.String())
+}
@@ -0,0 +1,375 @@
+package jq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// token is the kind of a lexeme
+type token int
+
+const (
+	tEOF token = iota
+	tDot
+	tRecurse // ..
+	tField   // .name
+	tName
+	tVar    // $name
+	tFormat // @name
+	tNumber
+	tString
+
+	tPipe
+	tComma
+	tLparen
+	tRparen
+	tLbracket
+	tRbracket
+	tLbrace
+	tRbrace
+	tColon
+	tSemicolon
+	tQuestion
+
+	tAdd
+	tSub
+	tMul
+	tDiv
+	tMod
+	tEqual
+	tNotEqual
+	tLess
+	tLessEqual
+	tGreater
+	tGreaterEqual
+	tAlt // //
+
+	tAssign    // =
+	tUpdate    // |=
+	tAddAssign // +=
+	tSubAssign // -=
+	tMulAssign // *=
+	tDivAssign // /=
+	tModAssign // %=
+	tAltAssign // //=
+
+	tAnd
+	tOr
+	tAs
+	tIf
+	tThen
+	tElif
+	tElse
+	tEnd
+	tReduce
+	tForeach
+	tTry
+	tCatch
+)
+
+// keywords maps reserved words to their tokens
+var keywords = map[string]token{
+	"and":     tAnd,
+	"or":      tOr,
+	"as":      tAs,
+	"if":      tIf,
+	"then":    tThen,
+	"elif":    tElif,
+	"else":    tElse,
+	"end":     tEnd,
+	"reduce":  tReduce,
+	"foreach": tForeach,
+	"try":     tTry,
+	"catch":   tCatch,
+}
+
+// operators maps operator spellings to their tokens, longest first where
+// one is a prefix of another
+var operators = []struct {
+	text string
+	tok  token
+}{
+	{"//=", tAltAssign}, {"==", tEqual}, {"!=", tNotEqual}, {"<=", tLessEqual},
+	{">=", tGreaterEqual}, {"|=", tUpdate}, {"+=", tAddAssign}, {"-=", tSubAssign},
+	{"*=", tMulAssign}, {"/=", tDivAssign}, {"%=", tModAssign}, {"=", tAssign},
+	{"//", tAlt}, {"|", tPipe}, {",", tComma}, {"(", tLparen}, {")", tRparen},
+	{"[", tLbracket}, {"]", tRbracket}, {"{", tLbrace}, {"}", tRbrace},
+	{":", tColon}, {";", tSemicolon}, {"?", tQuestion}, {"+", tAdd}, {"-", tSub},
+	{"*", tMul}, {"/", tDiv}, {"%", tMod}, {"<", tLess}, {">", tGreater},
+}
+
+// strPart is a piece of a string literal: literal text, or the source of
+// an interpolated \(...) expression
+type strPart struct {
+	text   string
+	interp bool
+}
+
+// lexeme is a token with its text and position
+type lexeme struct {
+	tok   token
+	text  string // names without their sigil, operators as written
+	num   float64
+	parts []strPart // of a string
+	pos   int
+}
+
+// String describes the lexeme for error messages
+func (l lexeme) String() string {
+	switch l.tok {
+	case tEOF:
+		return "end of filter"
+	case tField:
+		return "." + l.text
+	case tVar:
+		return "$" + l.text
+	case tFormat:
+		return "@" + l.text
+	case tString:
+		return "string"
+	}
+	return l.text
+}
+
+// lex splits a filter into lexemes
+func lex(src string) ([]lexeme, error) {
+	var lexemes []lexeme
+	pos := 0
+
+	for {
+		// Blanks and comments separate lexemes
+		for pos < len(src) {
+			switch c := src[pos]; {
+			case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+				pos++
+				continue
+			case c == '#':
+				for pos < len(src) && src[pos] != '\n' {
+					pos++
+				}
+				continue
+			}
+			break
+		}
+		if pos >= len(src) {
+			return append(lexemes, lexeme{tok: tEOF, pos: pos}), nil
+		}
+
+		start := pos
+		c := src[pos]
+		l := lexeme{pos: pos}
+		switch {
+		case c == '.' && strings.HasPrefix(src[pos:], ".."):
+			l.tok, l.text = tRecurse, ".."
+			pos += 2
+
+		case c == '.' && pos+1 < len(src) && isNameStart(src[pos+1]):
+			pos = scanName(src, pos+1)
+			l.tok, l.text = tField, src[start+1:pos]
+
+		case c == '.' && (pos+1 >= len(src) || !isDigit(src[pos+1])):
+			l.tok, l.text = tDot, "."
+			pos++
+
+		case isDigit(c) || c == '.':
+			pos = scanNumber(src, pos)
+			n, err := strconv.ParseFloat(src[start:pos], 64)
+			if err != nil {
+				return nil, fmt.Errorf("syntax error at position %d: invalid number %s", start+1, src[start:pos])
+			}
+			l.tok, l.text, l.num = tNumber, src[start:pos], n
+
+		case isNameStart(c):
+			pos = scanName(src, pos)
+			l.text = src[start:pos]
+			l.tok = tName
+			if tok, ok := keywords[l.text]; ok {
+				l.tok = tok
+			}
+
+		case (c == '$' || c == '@') && pos+1 < len(src) && isNameStart(src[pos+1]):
+			pos = scanName(src, pos+1)
+			l.tok, l.text = tVar, src[start+1:pos]
+			if c == '@' {
+				l.tok = tFormat
+			}
+
+		case c == '"':
+			parts, end, err := scanString(src, pos)
+			if err != nil {
+				return nil, err
+			}
+			l.tok, l.parts = tString, parts
+			pos = end
+
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[pos:], op.text) {
+					l.tok, l.text = op.tok, op.text
+					pos += len(op.text)
+					found = true
+					break
+				}
+			}
+			if !found {
+				r, _ := utf8.DecodeRuneInString(src[pos:])
+				return nil, fmt.Errorf("syntax error at position %d: unexpected %q", pos+1, r)
+			}
+		}
+		lexemes = append(lexemes, l)
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+// scanName returns the end of the name starting at pos
+func scanName(src string, pos int) int {
+	for pos < len(src) && isNameChar(src[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// scanNumber returns the end of the number starting at pos
+func scanNumber(src string, pos int) int {
+	for pos < len(src) && (isDigit(src[pos]) || src[pos] == '.') {
+		pos++
+	}
+	if pos < len(src) && (src[pos] == 'e' || src[pos] == 'E') {
+		exp := pos + 1
+		if exp < len(src) && (src[exp] == '+' || src[exp] == '-') {
+			exp++
+		}
+		if exp < len(src) && isDigit(src[exp]) {
+			pos = exp
+			for pos < len(src) && isDigit(src[pos]) {
+				pos++
+			}
+		}
+	}
+	return pos
+}
+
+// scanString reads the string literal starting at the quote at pos,
+// returning its parts and the position after the closing quote
+func scanString(src string, pos int) ([]strPart, int, error) {
+	start := pos
+	var parts []strPart
+	var b strings.Builder
+	pos++
+	for {
+		if pos >= len(src) {
+			return nil, 0, fmt.Errorf("syntax error at position %d: unterminated string", start+1)
+		}
+		c := src[pos]
+		switch {
+		case c == '"':
+			if b.Len() > 0 || len(parts) == 0 {
+				parts = append(parts, strPart{text: b.String()})
+			}
+			return parts, pos + 1, nil
+
+		case c == '\\' && pos+1 < len(src) && src[pos+1] == '(':
+			end, err := matchParen(src, pos+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			if b.Len() > 0 {
+				parts = append(parts, strPart{text: b.String()})
+				b.Reset()
+			}
+			parts = append(parts, strPart{text: src[pos+2 : end], interp: true})
+			pos = end + 1
+
+		case c == '\\':
+			if pos+1 >= len(src) {
+				return nil, 0, fmt.Errorf("syntax error at position %d: unterminated string", start+1)
+			}
+			n, err := unescape(&b, src, pos)
+			if err != nil {
+				return nil, 0, err
+			}
+			pos = n
+
+		default:
+			b.WriteByte(c)
+			pos++
+		}
+	}
+}
+
+// unescape writes the escape sequence at pos to b, returning the position
+// after it
+func unescape(b *strings.Builder, src string, pos int) (int, error) {
+	switch e := src[pos+1]; e {
+	case '"', '\\', '/':
+		b.WriteByte(e)
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'u':
+		if pos+6 > len(src) {
+			return 0, fmt.Errorf("syntax error at position %d: invalid escape", pos+1)
+		}
+		r, err := strconv.ParseUint(src[pos+2:pos+6], 16, 16)
+		if err != nil {
+			return 0, fmt.Errorf("syntax error at position %d: invalid escape", pos+1)
+		}
+		// A surrogate pair spells a character outside the BMP
+		if r >= 0xD800 && r < 0xDC00 && pos+12 <= len(src) && src[pos+6:pos+8] == `\u` {
+			if lo, err := strconv.ParseUint(src[pos+8:pos+12], 16, 16); err == nil && lo >= 0xDC00 && lo < 0xE000 {
+				b.WriteRune(rune((r-0xD800)<<10|(lo-0xDC00)) + 0x10000)
+				return pos + 12, nil
+			}
+		}
+		b.WriteRune(rune(r))
+		return pos + 6, nil
+	default:
+		return 0, fmt.Errorf("syntax error at position %d: invalid escape \\%c", pos+1, e)
+	}
+	return pos + 2, nil
+}
+
+// matchParen returns the position of the parenthesis closing the one at
+// pos, skipping over strings in between
+func matchParen(src string, pos int) (int, error) {
+	depth := 0
+	for i := pos; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		case '"':
+			_, end, err := scanString(src, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+	return 0, fmt.Errorf("syntax error at position %d: unterminated interpolation", pos)
+}
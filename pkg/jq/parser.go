@@ -0,0 +1,589 @@
+package jq
+
+import (
+	"fmt"
+	"slices"
+)
+
+// node is a filter in the syntax tree
+type node interface{}
+
+type (
+	identity  struct{}
+	recurse   struct{}
+	literal   struct{ value any }
+	varRef    struct{ name string }
+	formatter struct{ name string } // @csv and the like, applied to .
+
+	// index is term[key] or term.key; key sees the same input as term
+	index struct {
+		term, key node
+	}
+
+	// slice is term[from:to], either bound may be nil
+	slice struct {
+		term, from, to node
+	}
+
+	// iterate is term[]
+	iterate struct{ term node }
+
+	// try runs body, passing an error to catch if there is one
+	try struct {
+		body, catch node
+	}
+
+	pipe struct {
+		left, right node
+	}
+
+	comma struct {
+		left, right node
+	}
+
+	// binary is an arithmetic or comparison operator
+	binary struct {
+		op          token
+		left, right node
+	}
+
+	and struct {
+		left, right node
+	}
+
+	or struct {
+		left, right node
+	}
+
+	// alternative is left // right
+	alternative struct {
+		left, right node
+	}
+
+	negate struct{ operand node }
+
+	// ifThen has a nil otherwise when there is no else
+	ifThen struct {
+		cond, then, otherwise node
+	}
+
+	// bind is source as $name | body
+	bind struct {
+		source node
+		name   string
+		body   node
+	}
+
+	// reduce is reduce source as $name (init; update)
+	reduce struct {
+		source       node
+		name         string
+		init, update node
+	}
+
+	// foreach is foreach source as $name (init; update; extract), with a
+	// nil extract if it has only two clauses
+	foreach struct {
+		source                node
+		name                  string
+		init, update, extract node
+	}
+
+	// str is a string with interpolations, each formatted by format if
+	// it is set
+	str struct {
+		parts  []node
+		format string
+	}
+
+	array struct{ body node } // nil for []
+
+	object struct{ entries []entry }
+
+	call struct {
+		name string
+		args []node
+	}
+
+	// assign is path = value, path |= update, or path op= value with op
+	// the arithmetic or // operator it applies
+	assign struct {
+		op          token
+		path, value node
+	}
+)
+
+// assignOps maps the assignment operators to the operator they apply;
+// = and |= map to themselves
+var assignOps = map[token]token{
+	tAssign:    tAssign,
+	tUpdate:    tUpdate,
+	tAddAssign: tAdd,
+	tSubAssign: tSub,
+	tMulAssign: tMul,
+	tDivAssign: tDiv,
+	tModAssign: tMod,
+	tAltAssign: tAlt,
+}
+
+// entry is a key and value of an object construction
+type entry struct {
+	key, value node
+}
+
+// parser builds a syntax tree from lexemes by recursive descent,
+// panicking with a parseError on the first error
+type parser struct {
+	lexemes []lexeme
+	pos     int
+}
+
+// parseError carries a syntax error out of the parser
+type parseError struct {
+	err error
+}
+
+// parse parses a filter
+func parse(src string) (n node, err error) {
+	lexemes, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{lexemes: lexemes}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			n, err = nil, e.err
+		}
+	}()
+
+	n = p.pipe()
+	if !p.is(tEOF) {
+		p.unexpected()
+	}
+	return n, nil
+}
+
+// fail stops parsing with an error at the current lexeme
+func (p *parser) fail(format string, args ...any) {
+	panic(parseError{fmt.Errorf("syntax error at position %d: %s", p.tok().pos+1, fmt.Sprintf(format, args...))})
+}
+
+// unexpected fails on the current lexeme
+func (p *parser) unexpected() {
+	p.fail("unexpected %s", p.tok())
+}
+
+func (p *parser) tok() lexeme {
+	return p.lexemes[p.pos]
+}
+
+// is reports whether the current lexeme is one of toks
+func (p *parser) is(toks ...token) bool {
+	return slices.Contains(toks, p.lexemes[p.pos].tok)
+}
+
+func (p *parser) next() lexeme {
+	l := p.lexemes[p.pos]
+	if l.tok != tEOF {
+		p.pos++
+	}
+	return l
+}
+
+func (p *parser) expect(tok token) lexeme {
+	if !p.is(tok) {
+		p.unexpected()
+	}
+	return p.next()
+}
+
+// pipe parses the loosest level: a | b, and term as $x | body
+func (p *parser) pipe() node {
+	left := p.comma()
+	if p.is(tAs) {
+		p.next()
+		name := p.expect(tVar).text
+		p.expect(tPipe)
+		return &bind{source: left, name: name, body: p.pipe()}
+	}
+	if p.is(tPipe) {
+		p.next()
+		return &pipe{left: left, right: p.pipe()}
+	}
+	return left
+}
+
+func (p *parser) comma() node {
+	left := p.alternative()
+	for p.is(tComma) {
+		p.next()
+		left = &comma{left: left, right: p.alternative()}
+	}
+	return left
+}
+
+// alternative parses a // b, which groups to the right
+func (p *parser) alternative() node {
+	left := p.assignment()
+	if p.is(tAlt) {
+		p.next()
+		return &alternative{left: left, right: p.alternative()}
+	}
+	return left
+}
+
+// assignment parses the non-associative assignment operators, which bind
+// tighter than // and looser than or
+func (p *parser) assignment() node {
+	left := p.or()
+	if op, ok := assignOps[p.tok().tok]; ok {
+		p.next()
+		left = &assign{op: op, path: left, value: p.or()}
+		if _, ok := assignOps[p.tok().tok]; ok {
+			p.unexpected()
+		}
+	}
+	return left
+}
+
+func (p *parser) or() node {
+	left := p.and()
+	for p.is(tOr) {
+		p.next()
+		left = &or{left: left, right: p.and()}
+	}
+	return left
+}
+
+func (p *parser) and() node {
+	left := p.comparison()
+	for p.is(tAnd) {
+		p.next()
+		left = &and{left: left, right: p.comparison()}
+	}
+	return left
+}
+
+// comparison parses the non-associative comparison operators
+func (p *parser) comparison() node {
+	left := p.additive()
+	if p.is(tEqual, tNotEqual, tLess, tLessEqual, tGreater, tGreaterEqual) {
+		op := p.next().tok
+		left = &binary{op: op, left: left, right: p.additive()}
+		if p.is(tEqual, tNotEqual, tLess, tLessEqual, tGreater, tGreaterEqual) {
+			p.unexpected()
+		}
+	}
+	return left
+}
+
+func (p *parser) additive() node {
+	left := p.multiplicative()
+	for p.is(tAdd, tSub) {
+		op := p.next().tok
+		left = &binary{op: op, left: left, right: p.multiplicative()}
+	}
+	return left
+}
+
+func (p *parser) multiplicative() node {
+	left := p.unary()
+	for p.is(tMul, tDiv, tMod) {
+		op := p.next().tok
+		left = &binary{op: op, left: left, right: p.unary()}
+	}
+	return left
+}
+
+func (p *parser) unary() node {
+	if p.is(tSub) {
+		p.next()
+		return &negate{operand: p.unary()}
+	}
+	return p.postfix()
+}
+
+// postfix parses a term followed by indexes, iterations and ?
+func (p *parser) postfix() node {
+	term := p.primary()
+	for {
+		switch {
+		case p.is(tField):
+			term = &index{term: term, key: &literal{p.next().text}}
+		case p.is(tDot) && p.peekIs(1, tString):
+			p.next()
+			term = &index{term: term, key: p.stringLiteral("")}
+		case p.is(tDot) && p.peekIs(1, tLbracket):
+			p.next()
+		case p.is(tLbracket):
+			term = p.bracket(term)
+		case p.is(tQuestion):
+			p.next()
+			term = &try{body: term}
+		default:
+			return term
+		}
+	}
+}
+
+// peekIs reports whether the lexeme n ahead is tok
+func (p *parser) peekIs(n int, tok token) bool {
+	return p.pos+n < len(p.lexemes) && p.lexemes[p.pos+n].tok == tok
+}
+
+// bracket parses [], [key] or [from:to] after term
+func (p *parser) bracket(term node) node {
+	p.expect(tLbracket)
+	if p.is(tRbracket) {
+		p.next()
+		return &iterate{term: term}
+	}
+	if p.is(tColon) {
+		p.next()
+		to := p.pipe()
+		p.expect(tRbracket)
+		return &slice{term: term, to: to}
+	}
+	key := p.pipe()
+	if p.is(tColon) {
+		p.next()
+		s := &slice{term: term, from: key}
+		if !p.is(tRbracket) {
+			s.to = p.pipe()
+		}
+		p.expect(tRbracket)
+		return s
+	}
+	p.expect(tRbracket)
+	return &index{term: term, key: key}
+}
+
+func (p *parser) primary() node {
+	l := p.tok()
+	switch l.tok {
+	case tDot:
+		p.next()
+		if p.is(tString) {
+			return &index{term: &identity{}, key: p.stringLiteral("")}
+		}
+		return &identity{}
+
+	case tRecurse:
+		p.next()
+		return &recurse{}
+
+	case tField:
+		p.next()
+		return &index{term: &identity{}, key: &literal{l.text}}
+
+	case tNumber:
+		p.next()
+		return &literal{l.num}
+
+	case tString:
+		return p.stringLiteral("")
+
+	case tFormat:
+		p.next()
+		if p.is(tString) {
+			return p.stringLiteral(l.text)
+		}
+		return &formatter{name: l.text}
+
+	case tVar:
+		p.next()
+		return &varRef{name: l.text}
+
+	case tLparen:
+		p.next()
+		n := p.pipe()
+		p.expect(tRparen)
+		return n
+
+	case tLbracket:
+		p.next()
+		if p.is(tRbracket) {
+			p.next()
+			return &array{}
+		}
+		n := p.pipe()
+		p.expect(tRbracket)
+		return &array{body: n}
+
+	case tLbrace:
+		return p.object()
+
+	case tIf:
+		p.next()
+		return p.ifThen()
+
+	case tTry:
+		p.next()
+		t := &try{body: p.postfix()}
+		if p.is(tCatch) {
+			p.next()
+			t.catch = p.postfix()
+		}
+		return t
+
+	case tReduce:
+		p.next()
+		source := p.postfix()
+		p.expect(tAs)
+		name := p.expect(tVar).text
+		p.expect(tLparen)
+		init := p.pipe()
+		p.expect(tSemicolon)
+		update := p.pipe()
+		p.expect(tRparen)
+		return &reduce{source: source, name: name, init: init, update: update}
+
+	case tForeach:
+		p.next()
+		source := p.postfix()
+		p.expect(tAs)
+		f := &foreach{source: source, name: p.expect(tVar).text}
+		p.expect(tLparen)
+		f.init = p.pipe()
+		p.expect(tSemicolon)
+		f.update = p.pipe()
+		if p.is(tSemicolon) {
+			p.next()
+			f.extract = p.pipe()
+		}
+		p.expect(tRparen)
+		return f
+
+	case tName:
+		p.next()
+		switch l.text {
+		case "null":
+			return &literal{nil}
+		case "true":
+			return &literal{true}
+		case "false":
+			return &literal{false}
+		}
+		c := &call{name: l.text}
+		if p.is(tLparen) {
+			p.next()
+			for {
+				c.args = append(c.args, p.pipe())
+				if !p.is(tSemicolon) {
+					break
+				}
+				p.next()
+			}
+			p.expect(tRparen)
+		}
+		if _, ok := builtins[funcKey(c.name, len(c.args))]; !ok {
+			p.pos--
+			p.fail("%s/%d is not defined", c.name, len(c.args))
+		}
+		return c
+	}
+	p.unexpected()
+	return nil
+}
+
+// ifThen parses the rest of if cond then a elif ... else b end
+func (p *parser) ifThen() node {
+	n := &ifThen{cond: p.pipe()}
+	p.expect(tThen)
+	n.then = p.pipe()
+	switch {
+	case p.is(tElif):
+		p.next()
+		n.otherwise = p.ifThen()
+		return n
+	case p.is(tElse):
+		p.next()
+		n.otherwise = p.pipe()
+	}
+	p.expect(tEnd)
+	return n
+}
+
+// stringLiteral parses a string, parsing each interpolation as a filter
+func (p *parser) stringLiteral(format string) node {
+	l := p.expect(tString)
+	if len(l.parts) == 1 && !l.parts[0].interp {
+		return &literal{l.parts[0].text}
+	}
+
+	s := &str{format: format}
+	for _, part := range l.parts {
+		if !part.interp {
+			s.parts = append(s.parts, &literal{part.text})
+			continue
+		}
+		n, err := parse(part.text)
+		if err != nil {
+			p.fail("in interpolation: %v", err)
+		}
+		s.parts = append(s.parts, n)
+	}
+	return s
+}
+
+// object parses {key: value, ...} with its shorthands {name}, {$name}
+// and {"key"}
+func (p *parser) object() node {
+	p.expect(tLbrace)
+	o := &object{}
+	for !p.is(tRbrace) {
+		var e entry
+		l := p.tok()
+		switch {
+		case l.tok == tVar:
+			p.next()
+			e.key, e.value = &literal{l.text}, &varRef{name: l.text}
+		case l.tok == tString:
+			e.key = p.stringLiteral("")
+		case l.tok == tLparen:
+			p.next()
+			e.key = p.pipe()
+			p.expect(tRparen)
+		case l.tok == tName || l.tok >= tAnd:
+			// Keywords are fine as keys
+			p.next()
+			e.key = &literal{l.text}
+		default:
+			p.unexpected()
+		}
+
+		if p.is(tColon) {
+			p.next()
+			e.value = p.objectValue()
+		} else if e.value == nil {
+			if l.tok == tLparen {
+				p.unexpected()
+			}
+			e.value = &index{term: &identity{}, key: e.key}
+		}
+		o.entries = append(o.entries, e)
+
+		if !p.is(tComma) {
+			break
+		}
+		p.next()
+	}
+	p.expect(tRbrace)
+	return o
+}
+
+// objectValue parses the value of an object entry, which ends at a comma
+// unless it is in parentheses
+func (p *parser) objectValue() node {
+	left := p.alternative()
+	if p.is(tPipe) {
+		p.next()
+		return &pipe{left: left, right: p.objectValue()}
+	}
+	return left
+}
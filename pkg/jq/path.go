@@ -0,0 +1,468 @@
+package jq
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// A path is the list of keys leading to a value from the input: strings
+// for object keys, numbers for array indexes, and {"start", "end"} objects
+// for slices. Assignments, del and path(f) work on the paths a filter
+// refers to rather than on the values it outputs.
+
+// pathEmitter receives the paths of a path expression one at a time, with
+// the value at each
+type pathEmitter func(path []any, v any) error
+
+// pathFilters are the built-ins that output their input or nothing, which
+// keep the path they are given
+var pathFilters = map[string]bool{
+	"select/1": true, "values/0": true, "nulls/0": true, "booleans/0": true,
+	"numbers/0": true, "strings/0": true, "arrays/0": true, "objects/0": true,
+	"iterables/0": true, "scalars/0": true,
+}
+
+// evalPaths runs the path expression n over v, the value at path, passing
+// the path of each output to emit
+func evalPaths(n node, path []any, v any, sc *scope, emit pathEmitter) error {
+	switch n := n.(type) {
+	case *identity:
+		return emit(path, v)
+
+	case *recurse:
+		return recursePaths(path, v, emit)
+
+	case *index:
+		return evalPaths(n.term, path, v, sc, func(p []any, t any) error {
+			return eval(n.key, v, sc, func(k any) error {
+				r, err := indexValue(t, k)
+				if err != nil {
+					return err
+				}
+				return emit(appendPath(p, k), r)
+			})
+		})
+
+	case *slice:
+		return evalPaths(n.term, path, v, sc, func(p []any, t any) error {
+			return evalOptional(n.from, v, sc, func(from any) error {
+				return evalOptional(n.to, v, sc, func(to any) error {
+					r, err := sliceValue(t, from, to)
+					if err != nil {
+						return err
+					}
+					return emit(appendPath(p, map[string]any{"start": from, "end": to}), r)
+				})
+			})
+		})
+
+	case *iterate:
+		return evalPaths(n.term, path, v, sc, func(p []any, t any) error {
+			switch t := t.(type) {
+			case []any:
+				for i, item := range t {
+					if err := emit(appendPath(p, float64(i)), item); err != nil {
+						return err
+					}
+				}
+				return nil
+			case map[string]any:
+				for _, k := range sortedKeys(t) {
+					if err := emit(appendPath(p, k), t[k]); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			return valueError("cannot iterate over " + describe(t))
+		})
+
+	case *try:
+		if n.catch != nil {
+			break
+		}
+		err := evalPaths(n.body, path, v, sc, func(p []any, x any) error {
+			if err := emit(p, x); err != nil {
+				return &downstream{err}
+			}
+			return nil
+		})
+		if d, ok := err.(*downstream); ok {
+			return d.err
+		}
+		if _, ok := err.(*jqError); ok {
+			return nil
+		}
+		return err
+
+	case *pipe:
+		return evalPaths(n.left, path, v, sc, func(p []any, x any) error {
+			return evalPaths(n.right, p, x, sc, emit)
+		})
+
+	case *comma:
+		if err := evalPaths(n.left, path, v, sc, emit); err != nil {
+			return err
+		}
+		return evalPaths(n.right, path, v, sc, emit)
+
+	case *alternative:
+		found := false
+		err := evalPaths(n.left, path, v, sc, func(p []any, x any) error {
+			if !truthy(x) {
+				return nil
+			}
+			found = true
+			if err := emit(p, x); err != nil {
+				return &downstream{err}
+			}
+			return nil
+		})
+		if d, ok := err.(*downstream); ok {
+			return d.err
+		}
+		if _, ok := err.(*jqError); !ok && err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+		return evalPaths(n.right, path, v, sc, emit)
+
+	case *ifThen:
+		return eval(n.cond, v, sc, func(c any) error {
+			switch {
+			case truthy(c):
+				return evalPaths(n.then, path, v, sc, emit)
+			case n.otherwise != nil:
+				return evalPaths(n.otherwise, path, v, sc, emit)
+			}
+			return emit(path, v)
+		})
+
+	case *bind:
+		return eval(n.source, v, sc, func(x any) error {
+			return evalPaths(n.body, path, v, sc.bind(n.name, x), emit)
+		})
+
+	case *call:
+		key := funcKey(n.name, len(n.args))
+		switch {
+		case key == "empty/0":
+			return nil
+		case key == "recurse/0":
+			return recursePaths(path, v, emit)
+		case key == "getpath/1":
+			return eval(n.args[0], v, sc, func(p any) error {
+				keys, ok := p.([]any)
+				if !ok {
+					return valueError("path must be specified as an array")
+				}
+				r, err := getPath(v, keys)
+				if err != nil {
+					return err
+				}
+				return emit(append(slices.Clip(path), keys...), r)
+			})
+		case key == "first/1":
+			done := &stop{}
+			err := evalPaths(n.args[0], path, v, sc, func(p []any, x any) error {
+				if err := emit(p, x); err != nil {
+					return err
+				}
+				return done
+			})
+			if err == done {
+				return nil
+			}
+			return err
+		case pathFilters[key]:
+			return builtins[key](v, n.args, sc, func(any) error {
+				return emit(path, v)
+			})
+		}
+	}
+
+	// Anything else makes a new value, which has no path
+	return eval(n, v, sc, func(x any) error {
+		return valueError("invalid path expression with result " + describe(x))
+	})
+}
+
+// appendPath returns path with key added, leaving path itself alone as
+// other paths may share its array
+func appendPath(path []any, key any) []any {
+	return append(slices.Clip(path), key)
+}
+
+// recursePaths outputs the paths of v and everything inside it, depth
+// first as .. does
+func recursePaths(path []any, v any, emit pathEmitter) error {
+	if err := emit(path, v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case []any:
+		for i, item := range v {
+			if err := recursePaths(appendPath(path, float64(i)), item, emit); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			if err := recursePaths(appendPath(path, k), v[k], emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// collectPaths returns all the paths of the path expression n over v
+func collectPaths(n node, v any, sc *scope) ([][]any, error) {
+	var paths [][]any
+	err := evalPaths(n, nil, v, sc, func(p []any, _ any) error {
+		paths = append(paths, p)
+		return nil
+	})
+	return paths, err
+}
+
+// evalAssign runs an assignment. With = and op=, the value is computed
+// from the input, and each of its outputs gives one result; with |= the
+// update runs on the value at each path, and a path it outputs nothing
+// for is deleted.
+func evalAssign(n *assign, v any, sc *scope, emit emitter) error {
+	paths, err := collectPaths(n.path, v, sc)
+	if err != nil {
+		return err
+	}
+
+	if n.op == tUpdate {
+		result := v
+		var deleted [][]any
+		for _, p := range paths {
+			old, err := getPath(result, p)
+			if err != nil {
+				return err
+			}
+			var updated any
+			found := false
+			err = limit(1, n.value, old, sc, func(x any) error {
+				updated, found = x, true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if !found {
+				deleted = append(deleted, p)
+				continue
+			}
+			if result, err = setPath(result, p, updated); err != nil {
+				return err
+			}
+		}
+		result, err := deletePaths(result, deleted)
+		if err != nil {
+			return err
+		}
+		return emit(result)
+	}
+
+	return eval(n.value, v, sc, func(x any) error {
+		result := v
+		for _, p := range paths {
+			updated := x
+			if n.op != tAssign {
+				old, err := getPath(result, p)
+				if err != nil {
+					return err
+				}
+				if updated, err = applyOp(n.op, old, x); err != nil {
+					return err
+				}
+			}
+			var err error
+			if result, err = setPath(result, p, updated); err != nil {
+				return err
+			}
+		}
+		return emit(result)
+	})
+}
+
+// applyOp applies the operator of op= to the old value and the new one
+func applyOp(op token, old, x any) (any, error) {
+	if op == tAlt {
+		if truthy(old) {
+			return old, nil
+		}
+		return x, nil
+	}
+	return arith(op, old, x)
+}
+
+// getPath returns the value at path in v, null where it runs past what is
+// there
+func getPath(v any, path []any) (any, error) {
+	for _, key := range path {
+		if v == nil {
+			return nil, nil
+		}
+		var err error
+		if bounds, ok := key.(map[string]any); ok {
+			v, err = sliceValue(v, bounds["start"], bounds["end"])
+		} else {
+			v, err = indexValue(v, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// setPath returns v with the value at path replaced by x, creating the
+// objects and arrays on the way as needed. v itself is not changed, as
+// other values may share its objects and arrays.
+func setPath(v any, path []any, x any) (any, error) {
+	if len(path) == 0 {
+		return x, nil
+	}
+	key, rest := path[0], path[1:]
+
+	switch k := key.(type) {
+	case string:
+		var obj map[string]any
+		switch t := v.(type) {
+		case nil:
+			obj = map[string]any{}
+		case map[string]any:
+			obj = maps.Clone(t)
+		default:
+			return nil, valueError(fmt.Sprintf("cannot index %s with %q", typeName(v), k))
+		}
+		child, err := setPath(obj[k], rest, x)
+		if err != nil {
+			return nil, err
+		}
+		obj[k] = child
+		return obj, nil
+
+	case float64:
+		arr, ok := v.([]any)
+		if !ok && v != nil {
+			return nil, valueError(fmt.Sprintf("cannot index %s with number", typeName(v)))
+		}
+		i, _ := toInt(k)
+		if i < 0 {
+			if i += len(arr); i < 0 {
+				return nil, valueError("out of bounds negative array index")
+			}
+		}
+		var old any
+		if i < len(arr) {
+			old = arr[i]
+		}
+		child, err := setPath(old, rest, x)
+		if err != nil {
+			return nil, err
+		}
+		updated := make([]any, max(len(arr), i+1))
+		copy(updated, arr)
+		updated[i] = child
+		return updated, nil
+
+	case map[string]any:
+		arr, ok := v.([]any)
+		if !ok && v != nil {
+			return nil, valueError(fmt.Sprintf("cannot update a slice of %s", typeName(v)))
+		}
+		start, end, err := sliceBounds(len(arr), k["start"], k["end"])
+		if err != nil {
+			return nil, err
+		}
+		child, err := setPath(arr[start:end:end], rest, x)
+		if err != nil {
+			return nil, err
+		}
+		items, ok := child.([]any)
+		if !ok {
+			return nil, valueError("a slice of an array can only be assigned another array")
+		}
+		return nonNil(slices.Concat(arr[:start], items, arr[end:])), nil
+	}
+	return nil, valueError(fmt.Sprintf("invalid path component %s", describe(key)))
+}
+
+// deletePaths returns v without the values at paths. They are deleted
+// last first, so that deleting an element doesn't move the ones the other
+// paths refer to.
+func deletePaths(v any, paths [][]any) (any, error) {
+	sorted := slices.Clone(paths)
+	slices.SortFunc(sorted, func(a, b []any) int {
+		return compare(b, a)
+	})
+	for _, p := range sorted {
+		var err error
+		if v, err = deletePath(v, p); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// deletePath returns v without the value at path
+func deletePath(v any, path []any) (any, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if v == nil {
+		return nil, nil
+	}
+	key := path[0]
+
+	if len(path) > 1 {
+		child, err := getPath(v, path[:1])
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return v, nil
+		}
+		if child, err = deletePath(child, path[1:]); err != nil {
+			return nil, err
+		}
+		return setPath(v, path[:1], child)
+	}
+
+	switch t := v.(type) {
+	case map[string]any:
+		if k, ok := key.(string); ok {
+			obj := maps.Clone(t)
+			delete(obj, k)
+			return obj, nil
+		}
+	case []any:
+		switch k := key.(type) {
+		case float64:
+			i, _ := toInt(k)
+			if i < 0 {
+				i += len(t)
+			}
+			if i < 0 || i >= len(t) {
+				return t, nil
+			}
+			return nonNil(slices.Concat(t[:i], t[i+1:])), nil
+		case map[string]any:
+			start, end, err := sliceBounds(len(t), k["start"], k["end"])
+			if err != nil {
+				return nil, err
+			}
+			return nonNil(slices.Concat(t[:start], t[end:])), nil
+		}
+	}
+	return nil, valueError(fmt.Sprintf("cannot delete %s of %s", describe(key), typeName(v)))
+}
@@ -0,0 +1,205 @@
+package jq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/jsonpos"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+)
+
+// LintCommand returns jsonlint, which is jq --validate under a name of its
+// own for pre-commit hooks
+func LintCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "jsonlint [file...]",
+		Short: "Check JSON and JSON Lines files for errors",
+		Long: `Check JSON and JSON Lines files for syntax errors and duplicate object
+keys, as jq --validate does. With no files, or when file is -, read
+standard input.
+
+A file ending in .jsonl or .ndjson must hold one JSON value on each line;
+any other file exactly one JSON value. Each problem is printed as
+FILE:LINE:COLUMN: message, and the exit status is 1 if there were any.
+
+Examples:
+  jsonlint package.json tsconfig.json
+  jsonlint 'data/**/*.jsonl'`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceErrors = true
+			return validateFiles(cmd.Context(), args, output.Stdout)
+		},
+	}
+}
+
+// problem is something wrong with a JSON file, at a 1-based line and
+// column counted in characters
+type problem struct {
+	line, col int
+	msg       string
+}
+
+// validateFiles checks each file, printing its problems to w, and returns
+// status 1 if any file had one or could not be read
+func validateFiles(ctx context.Context, args []string, w io.Writer) error {
+	files := glob.Expand(args)
+	if len(files) == 0 {
+		files = []string{input.Stdin}
+	}
+
+	failed := false
+	for _, file := range files {
+		problems, err := validateFile(ctx, file)
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			logging.PathError("Cannot read", file, err)
+			failed = true
+			continue
+		}
+		for _, p := range problems {
+			if _, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", file, p.line, p.col, p.msg); err != nil {
+				return err
+			}
+			failed = true
+		}
+	}
+	if failed {
+		return exitcode.Status(1)
+	}
+	return nil
+}
+
+// validateFile reads a file, or standard input, and returns its problems
+func validateFile(ctx context.Context, file string) ([]problem, error) {
+	var r io.Reader = os.Stdin
+	if !input.IsStdin(file) {
+		f, err := input.OpenContext(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(textenc.NewDecoder(interrupt.Reader(ctx, r)))
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".jsonl", ".ndjson":
+		return validateLines(data), nil
+	}
+	return validateValue(data), nil
+}
+
+// validateLines checks that each line of data is one JSON value
+func validateLines(data []byte) []problem {
+	if len(data) == 0 {
+		return nil
+	}
+	var problems []problem
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	for i, line := range lines {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(bytes.TrimSpace(line)) == 0 {
+			problems = append(problems, problem{i + 1, 1, "empty line"})
+			continue
+		}
+		for _, p := range validateValue(line) {
+			p.line = i + 1
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// validateValue checks that data is exactly one JSON value, with no
+// object that has the same key twice. Checking stops at a syntax error.
+func validateValue(data []byte) []problem {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// The open objects and arrays, with the keys seen in each object
+	type container struct {
+		keys      map[string]bool // nil for arrays
+		expectKey bool
+	}
+	var stack []*container
+	var problems []problem
+	values := 0
+
+	for {
+		before := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF && len(stack) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == io.EOF {
+			if values == 0 {
+				problems = append(problems, problem{1, 1, "no JSON value"})
+			}
+			return problems
+		}
+		if err != nil {
+			return append(problems, syntaxProblem(data, dec, err))
+		}
+
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		} else if values++; values > 1 {
+			line, col := jsonpos.Position(data, jsonpos.SkipSpace(data, before))
+			return append(problems, problem{line, col, "more than one JSON value"})
+		}
+
+		if top != nil && top.keys != nil && top.expectKey {
+			if key, ok := tok.(string); ok {
+				if top.keys[key] {
+					line, col := jsonpos.Position(data, jsonpos.SkipSpace(data, before))
+					problems = append(problems, problem{line, col, fmt.Sprintf("duplicate key %q", key)})
+				}
+				top.keys[key] = true
+				top.expectKey = false
+				continue
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &container{keys: map[string]bool{}, expectKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &container{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+		// A value ended, so a key comes next in the object holding it
+		if len(stack) > 0 {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+}
+
+// syntaxProblem describes a decoding error where it happened
+func syntaxProblem(data []byte, dec *json.Decoder, err error) problem {
+	line, col, msg := jsonpos.Error(data, dec, err)
+	return problem{line, col, msg}
+}
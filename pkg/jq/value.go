@@ -0,0 +1,299 @@
+package jq
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Values are what encoding/json decodes into: nil, bool, float64, string,
+// []any and map[string]any.
+
+// typeName returns the jq name of v's type
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+// truthy reports whether v counts as true: anything but null and false
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+// typeOrder ranks types in jq's sort order
+func typeOrder(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []any:
+		return 5
+	}
+	return 6
+}
+
+// compare orders values as jq does: null < false < true < numbers <
+// strings < arrays < objects, arrays element by element and objects by
+// their sorted keys and then their values
+func compare(a, b any) int {
+	if ta, tb := typeOrder(a), typeOrder(b); ta != tb {
+		return ta - tb
+	}
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case []any:
+		b := b.([]any)
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return len(a) - len(b)
+	case map[string]any:
+		b := b.(map[string]any)
+		ka, kb := sortedKeys(a), sortedKeys(b)
+		if c := slices.Compare(ka, kb); c != 0 {
+			return c
+		}
+		for _, k := range ka {
+			if c := compare(a[k], b[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(obj map[string]any) []string {
+	return slices.Sorted(maps.Keys(obj))
+}
+
+// toInt returns an integer argument such as an index or a count
+func toInt(v any) (int, bool) {
+	n, ok := v.(float64)
+	if !ok || math.IsNaN(n) {
+		return 0, false
+	}
+	return int(math.Floor(math.Max(math.Min(n, math.MaxInt32), math.MinInt32))), true
+}
+
+// describe renders a value for error messages, shortened if it is long
+func describe(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return typeName(v)
+	}
+	s := string(data)
+	if len(s) > 11 {
+		s = s[:10] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", typeName(v), s)
+}
+
+// arith applies an arithmetic operator
+func arith(op token, a, b any) (any, error) {
+	na, aNum := a.(float64)
+	nb, bNum := b.(float64)
+	switch op {
+	case tAdd:
+		switch {
+		case a == nil:
+			return b, nil
+		case b == nil:
+			return a, nil
+		case aNum && bNum:
+			return na + nb, nil
+		}
+		switch a := a.(type) {
+		case string:
+			if b, ok := b.(string); ok {
+				return a + b, nil
+			}
+		case []any:
+			if b, ok := b.([]any); ok {
+				return append(slices.Clip(a), b...), nil
+			}
+		case map[string]any:
+			if b, ok := b.(map[string]any); ok {
+				merged := maps.Clone(a)
+				maps.Copy(merged, b)
+				return merged, nil
+			}
+		}
+		return nil, opError(a, b, "cannot be added")
+
+	case tSub:
+		if aNum && bNum {
+			return na - nb, nil
+		}
+		if a, ok := a.([]any); ok {
+			if b, ok := b.([]any); ok {
+				var kept []any
+				for _, item := range a {
+					if !slices.ContainsFunc(b, func(x any) bool { return compare(item, x) == 0 }) {
+						kept = append(kept, item)
+					}
+				}
+				return nonNil(kept), nil
+			}
+		}
+		return nil, opError(a, b, "cannot be subtracted")
+
+	case tMul:
+		if aNum && bNum {
+			return na * nb, nil
+		}
+		if s, ok := a.(string); ok && bNum {
+			return repeat(s, nb), nil
+		}
+		if s, ok := b.(string); ok && aNum {
+			return repeat(s, na), nil
+		}
+		if a, ok := a.(map[string]any); ok {
+			if b, ok := b.(map[string]any); ok {
+				return deepMerge(a, b), nil
+			}
+		}
+		return nil, opError(a, b, "cannot be multiplied")
+
+	case tDiv:
+		if aNum && bNum {
+			if nb == 0 {
+				return nil, opError(a, b, "cannot be divided because the divisor is zero")
+			}
+			return na / nb, nil
+		}
+		if a, ok := a.(string); ok {
+			if b, ok := b.(string); ok {
+				return splitString(a, b), nil
+			}
+		}
+		return nil, opError(a, b, "cannot be divided")
+
+	case tMod:
+		if aNum && bNum {
+			ia, ib := int64(na), int64(nb)
+			if ib == 0 {
+				return nil, opError(a, b, "cannot be divided because the divisor is zero")
+			}
+			if ib < 0 {
+				ib = -ib
+			}
+			return float64(ia % ib), nil
+		}
+		return nil, opError(a, b, "cannot be divided")
+	}
+	return nil, fmt.Errorf("unknown operator")
+}
+
+// opError is the error for an operator applied to the wrong types
+func opError(a, b any, what string) error {
+	return valueError(fmt.Sprintf("%s and %s %s", describe(a), describe(b), what))
+}
+
+// nonNil returns an empty array rather than nil, which would encode as null
+func nonNil(a []any) []any {
+	if a == nil {
+		return []any{}
+	}
+	return a
+}
+
+// repeat repeats s n times; null if n isn't positive
+func repeat(s string, n float64) any {
+	if n <= 0 {
+		return nil
+	}
+	count := int(math.Ceil(n))
+	if count > 1 && len(s) > math.MaxInt32/count {
+		return s
+	}
+	return strings.Repeat(s, count)
+}
+
+// deepMerge merges b into a, recursively for keys that are objects in both
+func deepMerge(a, b map[string]any) map[string]any {
+	merged := maps.Clone(a)
+	for k, vb := range b {
+		if oa, ok := merged[k].(map[string]any); ok {
+			if ob, ok := vb.(map[string]any); ok {
+				merged[k] = deepMerge(oa, ob)
+				continue
+			}
+		}
+		merged[k] = vb
+	}
+	return merged
+}
+
+// splitString splits s at every sep, as an array of strings
+func splitString(s, sep string) []any {
+	if s == "" {
+		return []any{}
+	}
+	parts := strings.Split(s, sep)
+	result := make([]any, len(parts))
+	for i, p := range parts {
+		result[i] = p
+	}
+	return result
+}
+
+// compareOp applies a comparison operator
+func compareOp(op token, a, b any) bool {
+	c := compare(a, b)
+	switch op {
+	case tEqual:
+		return c == 0
+	case tNotEqual:
+		return c != 0
+	case tLess:
+		return c < 0
+	case tLessEqual:
+		return c <= 0
+	case tGreater:
+		return c > 0
+	}
+	return c >= 0
+}
@@ -0,0 +1,163 @@
+package jsonc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/jq"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+)
+
+// Options holds jsonc configuration
+type Options struct {
+	Filter    string // jq filter to run over the result
+	Compact   bool
+	RawOutput bool // with Filter, print strings without quotes
+}
+
+// Command returns the jsonc command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "jsonc [flags] [file...]",
+		Short: "Convert JSONC and JSON5 to strict JSON",
+		Long: `Convert JSON with comments (JSONC), as in tsconfig.json and VS Code
+settings, and JSON5 to strict JSON that any JSON tool reads. With no
+files, or when file is -, read standard input.
+
+Comments and trailing commas are removed. From JSON5, unquoted object
+keys are quoted, single-quoted strings become double-quoted, escapes
+such as \x41 and line continuations in strings are rewritten, and
+numbers such as 0x1F, +1, .5 and 5. become 31, 1, 0.5 and 5. Infinity
+and NaN have no JSON equivalent and are an error.
+
+The layout of the input is kept: a removed comment leaves its line
+behind, so line numbers in errors about the output point to the input.
+-c prints each document compact on one line instead.
+
+--jq FILTER runs a jq filter over the result, as the jq command would,
+with -r printing strings without quotes.
+
+Examples:
+  jsonc tsconfig.json > tsconfig.strict.json
+  jsonc --jq '.compilerOptions.paths' tsconfig.json
+  jsonc -c ~/.config/Code/User/settings.json | jq -r '."editor.fontSize"'`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			files := glob.Expand(args)
+			if len(files) == 0 {
+				files = []string{input.Stdin}
+			}
+			if opts.RawOutput && opts.Filter == "" {
+				return exitcode.New(2, errors.New("-r can only be used with --jq"))
+			}
+			for _, file := range files {
+				if err := convertFile(ctx, file, output.Stdout, opts); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Filter, "jq", "", "Run the jq `FILTER` over the converted JSON")
+	cmd.Flags().BoolVarP(&opts.Compact, "compact", "c", false, "Compact output")
+	cmd.Flags().BoolVarP(&opts.RawOutput, "raw-output", "r", false, "With --jq, output raw strings")
+
+	return cmd
+}
+
+// convertFile converts a file, or standard input, and writes the result
+func convertFile(ctx context.Context, file string, w io.Writer, opts *Options) error {
+	var r io.Reader = os.Stdin
+	if !input.IsStdin(file) {
+		f, err := input.OpenContext(ctx, file)
+		if err != nil {
+			return fmt.Errorf("cannot open '%s': %w", file, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Settings files written on Windows often carry a BOM or are UTF-16
+	src, err := io.ReadAll(textenc.NewDecoder(interrupt.Reader(ctx, r)))
+	if err != nil {
+		return err
+	}
+	data, err := convert(src)
+	if err != nil {
+		return fmt.Errorf("%s:%w", file, err)
+	}
+
+	if opts.Filter != "" {
+		return jq.Filter(ctx, opts.Filter, bytes.NewReader(data), &jq.Options{Compact: opts.Compact, RawOutput: opts.RawOutput})
+	}
+	if opts.Compact {
+		return writeCompact(w, data)
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// convert normalizes src and checks that the result is JSON: one or more
+// values, as in a JSON Lines file
+func convert(src []byte) ([]byte, error) {
+	data, err := Normalize(src)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var v json.RawMessage
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			line := bytes.Count(data[:dec.InputOffset()], []byte("\n")) + 1
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line = bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			}
+			return nil, fmt.Errorf("%d: invalid JSON: %w", line, err)
+		}
+	}
+}
+
+// writeCompact writes each value of data on a line of its own
+func writeCompact(w io.Writer, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var v json.RawMessage
+		if err := dec.Decode(&v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package jsonc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalize tests removing comments and trailing commas while keeping
+// the lines of the input, and leaving comment-like text in strings alone
+func TestNormalize(t *testing.T) {
+	src := `{
+  // Compiler settings
+  "compilerOptions": {
+    "strict": true, /* for now */
+    "paths": ["src/*", "lib/*",], // trailing
+  },
+  "url": "http://example.com/*x*/",
+}
+`
+	want := `{
+
+  "compilerOptions": {
+    "strict": true,
+    "paths": ["src/*", "lib/*"]
+  },
+  "url": "http://example.com/*x*/"
+}
+`
+	got, err := convert([]byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+}
+
+// TestNormalize_JSON5 tests unquoted keys, single-quoted strings, JSON5
+// escapes and numbers
+func TestNormalize_JSON5(t *testing.T) {
+	for src, want := range map[string]string{
+		`{unquoted: 'it\'s "quoted"', $id: 1}`: `{"unquoted": "it's \"quoted\"", "$id": 1}`,
+		`[0x1F, +1, .5, 5., -0XFF, 1.5e+3]`:    `[31, 1, 0.5, 5, -255, 1.5e+3]`,
+		`"\x41\v\0"`:                           `"\u0041\u000b\u0000"`,
+		"'line \\\ncontinued'":                 `"line continued"`,
+		`{a: {b: [null, true, false,],},}`:     `{"a": {"b": [null, true, false]}}`,
+	} {
+		got, err := convert([]byte(src))
+		require.NoError(t, err, src)
+		assert.Equal(t, want, string(got), src)
+	}
+}
+
+// TestConvert_Errors tests what can't be made JSON, with the line and
+// column it is at
+func TestConvert_Errors(t *testing.T) {
+	for src, want := range map[string]string{
+		"{\n  \"a\": NaN\n}":       "2:8: NaN has no equivalent in JSON",
+		"[-Infinity]":              "1:3: Infinity has no equivalent in JSON",
+		"{\"a\": 1 /* open":        "1:9: comment not terminated",
+		"[\"abc\n\"]":              "1:2: string not terminated",
+		"{\"a\": yes}":             "1:7: unexpected \"yes\"",
+		"{\n\"a\": 1\n\"b\": 2\n}": "3: invalid JSON",
+	} {
+		_, err := convert([]byte(src))
+		require.Error(t, err, src)
+		assert.Contains(t, err.Error(), want, src)
+	}
+}
@@ -0,0 +1,311 @@
+package jsonc
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError is input that can't be made strict JSON, with where it is
+type SyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// normalizer rewrites JSONC or JSON5 text as strict JSON, keeping its
+// layout: comments are removed but the lines they were on stay, so that
+// line numbers of the output are those of the input
+type normalizer struct {
+	src   []byte
+	pos   int
+	out   []byte
+	comma int    // offset in out of a comma that a closing bracket would make trailing, or -1
+	stack []byte // the brackets of the open objects and arrays
+	key   bool   // whether an object key comes next
+}
+
+// Normalize returns src, a JSONC or JSON5 document, as strict JSON. It
+// removes comments and trailing commas, quotes unquoted object keys,
+// turns single-quoted strings into double-quoted ones and rewrites JSON5
+// numbers such as 0x1F, +1 and .5. Whether the result is otherwise valid
+// JSON is not checked.
+func Normalize(src []byte) ([]byte, error) {
+	n := &normalizer{src: src, out: make([]byte, 0, len(src)), comma: -1}
+	if err := n.run(); err != nil {
+		return nil, err
+	}
+	return n.out, nil
+}
+
+// run rewrites the whole of src
+func (n *normalizer) run() error {
+	for n.pos < len(n.src) {
+		c := n.src[n.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			n.out = append(n.out, c)
+			n.pos++
+		case c == '/':
+			if err := n.comment(); err != nil {
+				return err
+			}
+		case c == '{' || c == '[':
+			n.value()
+			n.stack = append(n.stack, c)
+			n.key = c == '{'
+			n.out = append(n.out, c)
+			n.pos++
+		case c == '}' || c == ']':
+			if n.comma >= 0 {
+				n.out = append(n.out[:n.comma], n.out[n.comma+1:]...)
+				n.comma = -1
+			}
+			if len(n.stack) > 0 {
+				n.stack = n.stack[:len(n.stack)-1]
+			}
+			n.out = append(n.out, c)
+			n.pos++
+		case c == ',':
+			n.comma = len(n.out)
+			n.key = len(n.stack) > 0 && n.stack[len(n.stack)-1] == '{'
+			n.out = append(n.out, c)
+			n.pos++
+		case c == ':':
+			n.comma = -1
+			n.out = append(n.out, c)
+			n.pos++
+		case c == '"' || c == '\'':
+			n.value()
+			if err := n.string(c); err != nil {
+				return err
+			}
+		case c == '-' || c == '+' || c == '.' || ('0' <= c && c <= '9'):
+			n.value()
+			if err := n.number(); err != nil {
+				return err
+			}
+		case isIdentStart(rune(c)) || c >= utf8.RuneSelf:
+			if err := n.identifier(); err != nil {
+				return err
+			}
+		default:
+			return n.errorf("unexpected character %q", c)
+		}
+	}
+	return nil
+}
+
+// value notes that a token other than a comma came, so that a comma
+// before it wasn't trailing
+func (n *normalizer) value() {
+	n.comma = -1
+	n.key = false
+}
+
+// comment skips a // or /* */ comment, keeping the line breaks in it and
+// dropping the blanks before it
+func (n *normalizer) comment() error {
+	rest := n.src[n.pos:]
+	var end int
+	switch {
+	case bytes.HasPrefix(rest, []byte("//")):
+		end = bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		if end > 0 && rest[end-1] == '\r' {
+			end--
+		}
+	case bytes.HasPrefix(rest, []byte("/*")):
+		end = bytes.Index(rest[2:], []byte("*/"))
+		if end < 0 {
+			return n.errorf("comment not terminated")
+		}
+		end += 4
+	default:
+		return n.errorf("unexpected character '/'")
+	}
+
+	n.out = bytes.TrimRight(n.out, " \t")
+	if n.comma >= len(n.out) {
+		n.comma = -1
+	}
+	n.out = append(n.out, bytes.Repeat([]byte("\n"), bytes.Count(rest[:end], []byte("\n")))...)
+	n.pos += end
+	return nil
+}
+
+// string copies a string quoted with quote as a double-quoted JSON string
+func (n *normalizer) string(quote byte) error {
+	start := n.pos
+	n.out = append(n.out, '"')
+	n.pos++
+	for n.pos < len(n.src) {
+		c := n.src[n.pos]
+		switch {
+		case c == quote:
+			n.out = append(n.out, '"')
+			n.pos++
+			return nil
+		case c == '"':
+			// Only in single-quoted strings
+			n.out = append(n.out, '\\', '"')
+			n.pos++
+		case c == '\n':
+			n.pos = start
+			return n.errorf("string not terminated")
+		case c == '\\':
+			if err := n.escape(); err != nil {
+				return err
+			}
+		default:
+			n.out = append(n.out, c)
+			n.pos++
+		}
+	}
+	n.pos = start
+	return n.errorf("string not terminated")
+}
+
+// escape copies an escape sequence, rewriting those only JSON5 has
+func (n *normalizer) escape() error {
+	if n.pos+1 >= len(n.src) {
+		return n.errorf("string not terminated")
+	}
+	c := n.src[n.pos+1]
+	switch c {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+		n.out = append(n.out, '\\', c)
+	case '\'':
+		n.out = append(n.out, '\'')
+	case '0':
+		n.out = append(n.out, `\u0000`...)
+	case 'v':
+		n.out = append(n.out, `\u000b`...)
+	case 'x':
+		hex := n.src[n.pos+2 : min(n.pos+4, len(n.src))]
+		if _, err := strconv.ParseUint(string(hex), 16, 8); err != nil || len(hex) < 2 {
+			return n.errorf("invalid escape \\x%s", hex)
+		}
+		n.out = append(n.out, `\u00`...)
+		n.out = append(n.out, hex...)
+		n.pos += 2
+	case '\n':
+		// A line continuation: the string goes on, without the break
+	case '\r':
+		if n.pos+2 < len(n.src) && n.src[n.pos+2] == '\n' {
+			n.pos++
+		}
+	default:
+		// JSON5 lets any other character stand for itself
+		n.out = append(n.out, c)
+	}
+	n.pos += 2
+	return nil
+}
+
+// number copies a number, rewriting hexadecimal numbers, a leading "+"
+// and a decimal point without digits on one side
+func (n *normalizer) number() error {
+	start := n.pos
+	end := start
+	for end < len(n.src) && strings.IndexByte("0123456789abcdefABCDEFxX.+-", n.src[end]) >= 0 {
+		if end > start && (n.src[end] == '+' || n.src[end] == '-') && n.src[end-1] != 'e' && n.src[end-1] != 'E' {
+			break
+		}
+		end++
+	}
+	text := string(n.src[start:end])
+	sign, digits := "", strings.TrimPrefix(text, "+")
+	if rest, ok := strings.CutPrefix(digits, "-"); ok {
+		sign, digits = "-", rest
+	}
+
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		v, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil {
+			return n.errorf("invalid number %q", text)
+		}
+		digits = strconv.FormatUint(v, 10)
+	case digits == "" && end < len(n.src) && isIdentStart(rune(n.src[end])):
+		// -Infinity or +NaN, which identifier reports
+		n.pos = end
+		return n.identifier()
+	case digits == "":
+		return n.errorf("invalid number %q", text)
+	default:
+		if strings.HasPrefix(digits, ".") {
+			digits = "0" + digits
+		}
+		mantissa, exp, hasExp := strings.Cut(digits, "e")
+		if !hasExp {
+			mantissa, exp, hasExp = strings.Cut(digits, "E")
+		}
+		mantissa = strings.TrimSuffix(mantissa, ".")
+		if hasExp {
+			digits = mantissa + "e" + exp
+		} else {
+			digits = mantissa
+		}
+	}
+	n.out = append(n.out, sign+digits...)
+	n.pos = end
+	return nil
+}
+
+// identifier copies true, false or null, or quotes an unquoted key
+func (n *normalizer) identifier() error {
+	start := n.pos
+	end := start
+	for end < len(n.src) {
+		r, size := utf8.DecodeRune(n.src[end:])
+		if !isIdentPart(r) {
+			break
+		}
+		end += size
+	}
+	if end == start {
+		return n.errorf("unexpected character %q", n.src[start])
+	}
+	word := string(n.src[start:end])
+
+	switch {
+	case n.key:
+		n.value()
+		n.out = strconv.AppendQuote(n.out, word)
+	case word == "true" || word == "false" || word == "null":
+		n.value()
+		n.out = append(n.out, word...)
+	case word == "Infinity" || word == "NaN":
+		return n.errorf("%s has no equivalent in JSON", word)
+	default:
+		return n.errorf("unexpected %q", word)
+	}
+	n.pos = end
+	return nil
+}
+
+// isIdentStart reports whether r may start an unquoted key
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || r >= utf8.RuneSelf
+}
+
+// isIdentPart reports whether r may be part of an unquoted key
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || ('0' <= r && r <= '9')
+}
+
+// errorf returns a SyntaxError at the current position
+func (n *normalizer) errorf(format string, args ...any) error {
+	before := n.src[:n.pos]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return &SyntaxError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,45 @@
+package jsonpos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// SkipSpace returns the offset of the first byte at or after offset that
+// isn't white space or the comma or colon between tokens
+func SkipSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// Position returns the line and column of offset in data, counting from 1
+// and columns in characters
+func Position(data []byte, offset int64) (int, int) {
+	before := data[:min(offset, int64(len(data)))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return line, col
+}
+
+// Error returns the line and column in data where dec failed with err,
+// and the message to report
+func Error(data []byte, dec *json.Decoder, err error) (int, int, string) {
+	offset := SkipSpace(data, dec.InputOffset())
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// The offset is just past the byte that was wrong
+		offset = max(syntaxErr.Offset-1, 0)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		offset = int64(len(bytes.TrimRight(data, " \t\r\n")))
+		err = errors.New("unexpected end of JSON input")
+	}
+	line, col := Position(data, offset)
+	return line, col, err.Error()
+}
@@ -0,0 +1,38 @@
+package jsonpos
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPosition tests lines and columns, which count characters
+func TestPosition(t *testing.T) {
+	data := []byte("{\n  \"näme\": 1,\n  \"b\": 2\n}")
+	line, col := Position(data, int64(bytes.Index(data, []byte("1"))))
+	assert.Equal(t, []int{2, 11}, []int{line, col})
+	line, col = Position(data, 1000)
+	assert.Equal(t, []int{4, 2}, []int{line, col})
+	assert.Equal(t, int64(bytes.Index(data, []byte("2"))), SkipSpace(data, int64(bytes.Index(data, []byte(": 2")))))
+}
+
+// TestError tests where syntax errors and truncated input are reported
+func TestError(t *testing.T) {
+	decode := func(text string) (int, int, string) {
+		data := []byte(text)
+		dec := json.NewDecoder(bytes.NewReader(data))
+		var v any
+		err := dec.Decode(&v)
+		return Error(data, dec, err)
+	}
+
+	line, col, msg := decode("{\n  \"a\": x\n}")
+	assert.Equal(t, []int{2, 8}, []int{line, col})
+	assert.Contains(t, msg, "invalid character 'x'")
+
+	line, col, msg = decode("{\"a\": [1,\n")
+	assert.Equal(t, []int{1, 10}, []int{line, col})
+	assert.Equal(t, "unexpected end of JSON input", msg)
+}
@@ -0,0 +1,134 @@
+package largest
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Entry is a file, or a directory with the total size of the files below
+// it
+type Entry struct {
+	Path string
+	Size int64
+	Dir  bool
+}
+
+// Tracker ranks the largest files and directories a walk comes across,
+// for --top-sizes. It keeps the N largest files in a heap, so memory
+// doesn't grow with the tree, and a running total for every directory.
+// It is safe for concurrent use.
+type Tracker struct {
+	n int
+
+	mu     sync.Mutex
+	files  smallestFirst
+	totals map[string]int64 // directory -> bytes of the files below it
+	dirs   []string         // directories to rank
+}
+
+// New returns a Tracker of the n largest entries
+func New(n int) *Tracker {
+	return &Tracker{n: n, totals: map[string]int64{}}
+}
+
+// File counts a file of size bytes found below root toward the totals of
+// the directories it is in, up to root itself, and ranks it if rank is
+// set
+func (t *Tracker) File(root, path string, size int64, rank bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		t.totals[dir] += size
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	if !rank {
+		return
+	}
+	e := Entry{Path: path, Size: size}
+	switch {
+	case len(t.files) < t.n:
+		heap.Push(&t.files, e)
+	case size > t.files[0].Size:
+		t.files[0] = e
+		heap.Fix(&t.files, 0)
+	}
+}
+
+// Dir ranks a directory by the total size of the files below it
+func (t *Tracker) Dir(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirs = append(t.dirs, path)
+}
+
+// Largest returns the n largest entries ranked, largest first, with ties
+// in order of their paths
+func (t *Tracker) Largest() []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := append([]Entry(nil), t.files...)
+	for _, dir := range t.dirs {
+		entries = append(entries, Entry{Path: dir, Size: t.totals[filepath.Clean(dir)], Dir: true})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Size != entries[j].Size {
+			return entries[i].Size > entries[j].Size
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	return entries[:min(t.n, len(entries))]
+}
+
+// Write prints the largest entries with their sizes in human-readable
+// form, marking directories with a trailing "/"
+func (t *Tracker) Write(w io.Writer) error {
+	for _, e := range t.Largest() {
+		path := e.Path
+		if e.Dir {
+			path += "/"
+		}
+		if _, err := fmt.Fprintf(w, "%7s  %s\n", FormatSize(e.Size), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatSize formats a size in bytes with a binary unit, as tree -h does
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// smallestFirst is a heap of files whose root is the smallest, the one
+// to drop when a larger file comes along
+type smallestFirst []Entry
+
+func (h smallestFirst) Len() int           { return len(h) }
+func (h smallestFirst) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h smallestFirst) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *smallestFirst) Push(x any)        { *h = append(*h, x.(Entry)) }
+func (h *smallestFirst) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,47 @@
+package largest
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTracker_Largest tests that only the largest files are kept and that
+// directories total the files below them up to the root
+func TestTracker_Largest(t *testing.T) {
+	top := New(3)
+	root := filepath.FromSlash("r")
+	for _, f := range []struct {
+		path string
+		size int64
+	}{
+		{"r/a/x", 10}, {"r/a/b/y", 40}, {"r/z", 30}, {"r/a/b/w", 5}, {"r/v", 20},
+	} {
+		top.File(root, filepath.FromSlash(f.path), f.size, true)
+	}
+	top.Dir(filepath.FromSlash("r/a"))
+	top.Dir(filepath.FromSlash("r/a/b"))
+
+	assert.Equal(t, []Entry{
+		{Path: filepath.FromSlash("r/a"), Size: 55, Dir: true},
+		{Path: filepath.FromSlash("r/a/b"), Size: 45, Dir: true},
+		{Path: filepath.FromSlash("r/a/b/y"), Size: 40},
+	}, top.Largest())
+}
+
+// TestTracker_Write tests the sizes and directory markers printed
+func TestTracker_Write(t *testing.T) {
+	top := New(5)
+	top.File("r", filepath.Join("r", "d", "f"), 1536, true)
+	top.File("r", filepath.Join("r", "g"), 12, false)
+	top.Dir(filepath.Join("r", "d"))
+
+	var buf bytes.Buffer
+	require.NoError(t, top.Write(&buf))
+	want := "  1.5KB  " + filepath.Join("r", "d") + "/\n" +
+		"  1.5KB  " + filepath.Join("r", "d", "f") + "\n"
+	assert.Equal(t, want, buf.String())
+}
@@ -0,0 +1,32 @@
+package limit
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+)
+
+// execName is the hidden command limit runs itself as to start a command
+// under rlimits
+const execName = "limit-exec"
+
+// ExecCommand returns the hidden command that sets limits on its own
+// process and then executes a command in its place. It is how limit
+// starts commands on Unix and not meant to be run by hand.
+func ExecCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                execName + " name=value... -- command [args...]",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := execLimited(args)
+			var exitErr *exitcode.Error
+			if err != nil && !errors.As(err, &exitErr) {
+				err = exitcode.New(statusFailed, err)
+			}
+			return err
+		},
+	}
+}
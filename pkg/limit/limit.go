@@ -0,0 +1,220 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// Exit statuses of limit itself, as GNU timeout and env use them
+const (
+	statusTimeout  = 124 // --timeout ran out
+	statusFailed   = 125 // limit could not run the command
+	statusNotFound = 127 // the command wasn't found
+)
+
+// Options holds limit configuration
+type Options struct {
+	CPU     string // CPU time, as a duration or seconds
+	Mem     string // address space, as a size
+	FSize   string // largest file the command may write, as a size
+	NoFile  int    // open files; 0 leaves it alone
+	Timeout string // wall-clock time, after which the command is killed
+}
+
+// Limits are the parsed limits, zero where not set
+type Limits struct {
+	CPU     time.Duration
+	Mem     uint64
+	FSize   uint64
+	NoFile  uint64
+	Timeout time.Duration
+}
+
+// Command returns the limit command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "limit [flags] -- command [args...]",
+		Short: "Run a command with limits on CPU time, memory and more",
+		Long: `Run a command with limits on the resources it may use, for running
+untrusted build steps with caps.
+
+On Unix the limits are resource limits (rlimits), set as hard limits in
+the command's process before it starts, so neither the command nor what it
+runs can raise them; every process it starts gets the same limits of its
+own. On Windows the command runs in a Job Object, whose CPU time and
+memory limits hold for each process in the job; --fsize and --nofile are
+not available there.
+
+--cpu takes a duration (30s, 2m) or whole seconds, --mem and --fsize a
+size in bytes with an optional K, M, G or T suffix (powers of 1024).
+--timeout kills the command when the wall-clock time runs out, even if it
+is waiting rather than using CPU.
+
+The exit status is that of the command, or 128 plus the number of the
+signal that ended it, 124 if --timeout ran out, 125 if limit itself
+failed and 127 if the command wasn't found. A command ended for going
+over its CPU time or file size limit is reported on stderr.
+
+Examples:
+  limit --cpu 30s --mem 512M -- make test
+  limit --timeout 10m --nofile 256 -- ./untrusted-build.sh`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limits, err := parseLimits(opts)
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+			cmd.SilenceErrors = true
+			status := run(cmd.Context(), args, limits)
+			if status != 0 {
+				return exitcode.Status(status)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.CPU, "cpu", "", "Limit CPU time to `DURATION` (e.g. 30s, or seconds)")
+	cmd.Flags().StringVar(&opts.Mem, "mem", "", "Limit memory (address space) to `SIZE` (e.g. 512M)")
+	cmd.Flags().StringVar(&opts.FSize, "fsize", "", "Limit the size of files written to `SIZE` (Unix)")
+	cmd.Flags().IntVar(&opts.NoFile, "nofile", 0, "Limit open files to `N` (Unix)")
+	cmd.Flags().StringVar(&opts.Timeout, "timeout", "", "Kill the command after `DURATION` of wall-clock time")
+
+	return cmd
+}
+
+// parseLimits checks and converts the limits of opts
+func parseLimits(opts *Options) (*Limits, error) {
+	limits := &Limits{}
+	var err error
+	if opts.CPU != "" {
+		if limits.CPU, err = parseTime(opts.CPU); err != nil {
+			return nil, fmt.Errorf("invalid --cpu %q: %w", opts.CPU, err)
+		}
+	}
+	if opts.Timeout != "" {
+		if limits.Timeout, err = parseTime(opts.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid --timeout %q: %w", opts.Timeout, err)
+		}
+	}
+	if opts.Mem != "" {
+		if limits.Mem, err = parseSize(opts.Mem); err != nil {
+			return nil, fmt.Errorf("invalid --mem %q: %w", opts.Mem, err)
+		}
+	}
+	if opts.FSize != "" {
+		if limits.FSize, err = parseSize(opts.FSize); err != nil {
+			return nil, fmt.Errorf("invalid --fsize %q: %w", opts.FSize, err)
+		}
+	}
+	if opts.NoFile < 0 {
+		return nil, fmt.Errorf("invalid --nofile %d", opts.NoFile)
+	}
+	limits.NoFile = uint64(opts.NoFile)
+	return limits, checkSupported(limits)
+}
+
+// parseTime parses a positive Go duration or a whole number of seconds
+func parseTime(value string) (time.Duration, error) {
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		value = strconv.FormatUint(n, 10) + "s"
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New("not a duration such as 30s or 2m")
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+// parseSize parses a positive number of bytes with an optional K, M, G
+// or T suffix, each 1024 times the one before; "B" or "iB" may follow
+func parseSize(value string) (uint64, error) {
+	s := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "B"), "I")
+	shift := 0
+	if i := strings.IndexAny(s, "KMGT"); i >= 0 && i == len(s)-1 {
+		shift = 10 * (strings.IndexByte("KMGT", s[i]) + 1)
+		s = s[:i]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("not a size such as 4096, 512M or 2G")
+	}
+	if n == 0 {
+		return 0, errors.New("must be positive")
+	}
+	if n > math.MaxUint64>>shift {
+		return 0, errors.New("too large")
+	}
+	return n << shift, nil
+}
+
+// run runs the command in args under limits and returns the status limit
+// exits with
+func run(ctx context.Context, args []string, limits *Limits) int {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		logging.PathError("Cannot run", args[0], err)
+		return statusNotFound
+	}
+
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+	cmd, err := command(ctx, args, limits)
+	if err != nil {
+		logging.PathError("Cannot run", args[0], err)
+		return statusFailed
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.WaitDelay = time.Second
+
+	wait, err := start(cmd, limits)
+	if err != nil {
+		logging.PathError("Cannot run", args[0], err)
+		return statusFailed
+	}
+	err = wait()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logging.Error(args[0]+":", "timed out after", limits.Timeout)
+		return statusTimeout
+	}
+	return exitStatus(args[0], err, limits)
+}
+
+// exitStatus converts the result of waiting for the command to the status
+// limit exits with, reporting limits the command went over
+func exitStatus(name string, err error, limits *Limits) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		logging.PathError("Failed to run", name, err)
+		return statusFailed
+	}
+	if reason := exceeded(exitErr, limits); reason != "" {
+		logging.Error(name+":", reason)
+	}
+	if code := exitErr.ExitCode(); code >= 0 {
+		return code
+	}
+	return 128 + signal(exitErr)
+}
@@ -0,0 +1,46 @@
+//go:build !unix && !windows
+
+package limit
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// checkSupported only accepts --timeout, which needs no help from the
+// system
+func checkSupported(limits *Limits) error {
+	if limits.CPU > 0 || limits.Mem > 0 || limits.FSize > 0 || limits.NoFile > 0 {
+		return errors.New("only --timeout is supported on this system")
+	}
+	return nil
+}
+
+// command runs args directly
+func command(ctx context.Context, args []string, limits *Limits) (*exec.Cmd, error) {
+	return exec.CommandContext(ctx, args[0], args[1:]...), nil
+}
+
+// start starts the command
+func start(cmd *exec.Cmd, limits *Limits) (func() error, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Wait, nil
+}
+
+// execLimited is only used on Unix
+func execLimited(args []string) error {
+	return errors.New(execName + " is not supported on this system")
+}
+
+// exceeded is never known here
+func exceeded(exitErr *exec.ExitError, limits *Limits) string {
+	return ""
+}
+
+// signal is unknown here
+func signal(exitErr *exec.ExitError) int {
+	return 0
+}
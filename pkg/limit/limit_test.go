@@ -0,0 +1,38 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseSize tests sizes with and without binary suffixes
+func TestParseSize(t *testing.T) {
+	for value, want := range map[string]uint64{
+		"4096": 4096, "512M": 512 << 20, "2g": 2 << 30, "1KiB": 1024, "3KB": 3072, "1T": 1 << 40,
+	} {
+		got, err := parseSize(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+	for _, value := range []string{"", "0", "-1", "1.5G", "12X", "M", "99999999999T"} {
+		_, err := parseSize(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// TestParseLimits tests durations, whole seconds and invalid limits
+func TestParseLimits(t *testing.T) {
+	limits, err := parseLimits(&Options{CPU: "90", Timeout: "1m30s", Mem: "1G"})
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, limits.CPU)
+	assert.Equal(t, 90*time.Second, limits.Timeout)
+	assert.Equal(t, uint64(1<<30), limits.Mem)
+
+	for _, opts := range []Options{{CPU: "0"}, {CPU: "-5s"}, {Timeout: "soon"}, {Mem: "lots"}, {NoFile: -1}} {
+		_, err := parseLimits(&opts)
+		assert.Error(t, err, "%+v", opts)
+	}
+}
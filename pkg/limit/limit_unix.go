@@ -0,0 +1,161 @@
+//go:build unix
+
+package limit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+)
+
+// checkSupported accepts every limit on Unix
+func checkSupported(limits *Limits) error {
+	return nil
+}
+
+// command runs args through this executable's limit-exec, which sets the
+// limits in the new process and then replaces itself with the command:
+// the limits then hold from the command's first instruction, which
+// setting them from outside once it runs could not promise
+func command(ctx context.Context, args []string, limits *Limits) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	helper := append([]string{execName}, rlimitArgs(limits)...)
+	helper = append(append(helper, "--"), args...)
+	return exec.CommandContext(ctx, self, helper...), nil
+}
+
+// start starts the command, which limits itself
+func start(cmd *exec.Cmd, limits *Limits) (func() error, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Wait, nil
+}
+
+// rlimit is a resource limit as limit-exec receives it, "name=value"
+type rlimit struct {
+	name     string
+	resource int
+}
+
+var rlimits = []rlimit{
+	{"cpu", unix.RLIMIT_CPU},
+	{"as", unix.RLIMIT_AS},
+	{"fsize", unix.RLIMIT_FSIZE},
+	{"nofile", unix.RLIMIT_NOFILE},
+}
+
+// rlimitArgs returns the limits to set as limit-exec arguments. CPU time
+// is in whole seconds, rounded up.
+func rlimitArgs(limits *Limits) []string {
+	var args []string
+	add := func(name string, value uint64) {
+		if value > 0 {
+			args = append(args, name+"="+strconv.FormatUint(value, 10))
+		}
+	}
+	add("cpu", uint64((limits.CPU+999_999_999)/1_000_000_000))
+	add("as", limits.Mem)
+	add("fsize", limits.FSize)
+	add("nofile", limits.NoFile)
+	return args
+}
+
+// execLimited sets the limits given as "name=value" arguments before "--"
+// and executes the command after it in place of this process
+func execLimited(args []string) error {
+	sep := -1
+	for i, arg := range args {
+		if arg == "--" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || sep == len(args)-1 {
+		return fmt.Errorf("usage: %s name=value... -- command [args...]", execName)
+	}
+
+	for _, arg := range args[:sep] {
+		name, value, _ := strings.Cut(arg, "=")
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid limit %q", arg)
+		}
+		if err := setLimit(name, n); err != nil {
+			return err
+		}
+	}
+
+	argv := args[sep+1:]
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return exitcode.New(statusNotFound, err)
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// setLimit sets a hard limit on a resource. CPU time gets a soft limit
+// one second below it, so that going over is signaled with SIGXCPU before
+// the kernel kills the process outright.
+func setLimit(name string, value uint64) error {
+	for _, r := range rlimits {
+		if r.name != name {
+			continue
+		}
+		var limit unix.Rlimit
+		setField(&limit.Cur, value)
+		setField(&limit.Max, value)
+		if r.resource == unix.RLIMIT_CPU {
+			setField(&limit.Max, value+1)
+		}
+		if err := unix.Setrlimit(r.resource, &limit); err != nil {
+			return fmt.Errorf("cannot set %s limit to %d: %w", name, value, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown limit %q", name)
+}
+
+// setField sets a field of unix.Rlimit, which is signed on some systems
+func setField[T int64 | uint64](field *T, value uint64) {
+	*field = T(value)
+}
+
+// exceeded describes the limit a command went over, judging by the signal
+// that ended it, or returns ""
+func exceeded(exitErr *exec.ExitError, limits *Limits) string {
+	switch syscall.Signal(signal(exitErr)) {
+	case syscall.SIGXCPU:
+		return "CPU time limit exceeded"
+	case syscall.SIGXFSZ:
+		return "file size limit exceeded"
+	case syscall.SIGKILL:
+		if limits.CPU > 0 {
+			return "killed, possibly for exceeding the CPU time limit"
+		}
+	}
+	return ""
+}
+
+// signal returns the number of the signal that ended the command, or 0
+func signal(exitErr *exec.ExitError) int {
+	var status syscall.WaitStatus
+	if s, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+		status = s
+	}
+	if !status.Signaled() {
+		return 0
+	}
+	return int(status.Signal())
+}
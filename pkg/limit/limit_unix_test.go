@@ -0,0 +1,18 @@
+//go:build unix
+
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRlimitArgs tests the limits passed to limit-exec, with CPU time
+// rounded up to whole seconds
+func TestRlimitArgs(t *testing.T) {
+	args := rlimitArgs(&Limits{CPU: 1500 * time.Millisecond, Mem: 1 << 20, NoFile: 64, Timeout: time.Minute})
+	assert.Equal(t, []string{"cpu=2", "as=1048576", "nofile=64"}, args)
+	assert.Empty(t, rlimitArgs(&Limits{}))
+}
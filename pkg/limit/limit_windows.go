@@ -0,0 +1,136 @@
+//go:build windows
+
+package limit
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// errNotEnoughQuota is the exit code of a process Windows ends for going
+// over the CPU time limit of its job
+const errNotEnoughQuota = 1816
+
+// checkSupported rejects the limits Job Objects don't have
+func checkSupported(limits *Limits) error {
+	if limits.FSize > 0 || limits.NoFile > 0 {
+		return errors.New("--fsize and --nofile are not supported on Windows")
+	}
+	return nil
+}
+
+// command runs args directly; start puts it in a job before it runs
+func command(ctx context.Context, args []string, limits *Limits) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_SUSPENDED}
+	return cmd, nil
+}
+
+// start starts the command suspended, assigns it to a Job Object with
+// the limits and only then lets it run. Closing the job when the command
+// is done also ends whatever it left running.
+func start(cmd *exec.Cmd, limits *Limits) (func() error, error) {
+	job, err := newJob(limits)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	if err := assign(job, cmd.Process.Pid); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	return func() error {
+		defer windows.CloseHandle(job)
+		return cmd.Wait()
+	}, nil
+}
+
+// newJob creates a Job Object with the CPU time and memory limits of each
+// process in it
+func newJob(limits *Limits) (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if limits.CPU > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_TIME
+		info.BasicLimitInformation.PerProcessUserTimeLimit = int64(limits.CPU / 100) // in 100ns units
+	}
+	if limits.Mem > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = uintptr(limits.Mem)
+	}
+	_, err = windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	return job, nil
+}
+
+// assign puts the suspended process pid in job and resumes its threads
+func assign(job windows.Handle, pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	err = windows.AssignProcessToJobObject(job, process)
+	windows.CloseHandle(process)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(snapshot)
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != uint32(pid) {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return err
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execLimited is only used on Unix
+func execLimited(args []string) error {
+	return errors.New(execName + " is not used on Windows")
+}
+
+// exceeded describes the limit a command went over, judging by its exit
+// code, or returns ""
+func exceeded(exitErr *exec.ExitError, limits *Limits) string {
+	if exitErr.ExitCode() == errNotEnoughQuota && limits.CPU > 0 {
+		return "CPU time limit exceeded"
+	}
+	return ""
+}
+
+// signal is 0: processes on Windows always have an exit code
+func signal(exitErr *exec.ExitError) int {
+	return 0
+}
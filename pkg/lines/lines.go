@@ -0,0 +1,308 @@
+package lines
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+)
+
+// DefaultMaxLength caps the length of a single record for readers created
+// with NewReader. Zero means unlimited. Set from the global --max-line-bytes flag.
+var DefaultMaxLength = 0
+
+// DecodeBOM makes NewReader drop a UTF-8 BOM and transcode UTF-16 input to
+// UTF-8. Set from the global --strip-bom flag.
+var DecodeBOM = false
+
+// StripCR makes NewReader treat CRLF as a line terminator, removing the
+// '\r' from each record. Set from the global --crlf flag.
+var StripCR = false
+
+// ErrTooLong is returned when a record exceeds the configured maximum length
+var ErrTooLong = errors.New("line too long")
+
+// Reader reads delimiter-terminated records from a stream.
+//
+// Unlike bufio.Scanner it has no fixed token limit, keeps every byte of the
+// record (including a trailing '\r'), and reports whether the final record
+// was terminated so callers can reproduce input without a trailing newline.
+type Reader struct {
+	br         *bufio.Reader
+	delim      byte
+	maxLength  int
+	stripCR    bool
+	buf        []byte
+	record     []byte
+	terminated bool
+	consumed   int64
+	err        error
+}
+
+// NewReader returns a Reader splitting r on '\n'
+func NewReader(r io.Reader) *Reader {
+	if DecodeBOM {
+		r = textenc.NewDecoder(r)
+	}
+	return &Reader{
+		br:        bufio.NewReaderSize(r, 64*1024),
+		delim:     '\n',
+		maxLength: DefaultMaxLength,
+		stripCR:   StripCR,
+	}
+}
+
+// SetDelimiter changes the record terminator (e.g. 0 for NUL-separated input)
+func (r *Reader) SetDelimiter(delim byte) {
+	r.delim = delim
+}
+
+// SetStripCR controls whether a '\r' before the delimiter is removed
+func (r *Reader) SetStripCR(strip bool) {
+	r.stripCR = strip
+}
+
+// SetMaxLength caps the record length in bytes; zero means unlimited
+func (r *Reader) SetMaxLength(n int) {
+	r.maxLength = n
+}
+
+// Scan advances to the next record, returning false at EOF or on error
+func (r *Reader) Scan() bool {
+	if r.err != nil {
+		return false
+	}
+
+	r.buf = r.buf[:0]
+	r.record = nil
+
+	for {
+		chunk, err := r.br.ReadSlice(r.delim)
+		r.consumed += int64(len(chunk))
+
+		// Fast path: the whole record fit in the read buffer
+		if err == nil && len(r.buf) == 0 {
+			r.record = chunk[:len(chunk)-1]
+			r.terminated = true
+			return r.finish()
+		}
+
+		r.buf = append(r.buf, chunk...)
+
+		switch {
+		case err == nil:
+			r.record = r.buf[:len(r.buf)-1]
+			r.terminated = true
+			return r.finish()
+		case errors.Is(err, bufio.ErrBufferFull):
+			if r.maxLength > 0 && len(r.buf) > r.maxLength {
+				r.err = fmt.Errorf("%w: exceeds %d bytes", ErrTooLong, r.maxLength)
+				return false
+			}
+			continue
+		case errors.Is(err, io.EOF):
+			r.err = io.EOF
+			if len(r.buf) == 0 {
+				return false
+			}
+			r.record = r.buf
+			r.terminated = false
+			return r.finish()
+		default:
+			r.err = err
+			return false
+		}
+	}
+}
+
+// finish enforces the maximum length on the current record and
+// applies CRLF stripping
+func (r *Reader) finish() bool {
+	if r.stripCR && r.terminated && len(r.record) > 0 && r.record[len(r.record)-1] == '\r' {
+		r.record = r.record[:len(r.record)-1]
+	}
+
+	if r.maxLength > 0 && len(r.record) > r.maxLength {
+		r.err = fmt.Errorf("%w: exceeds %d bytes", ErrTooLong, r.maxLength)
+		r.record = nil
+		return false
+	}
+	return true
+}
+
+// Bytes returns the current record without its terminator. The slice is
+// only valid until the next call to Scan.
+func (r *Reader) Bytes() []byte {
+	return r.record
+}
+
+// Text returns the current record without its terminator
+func (r *Reader) Text() string {
+	return string(r.record)
+}
+
+// Terminated reports whether the current record ended with the delimiter.
+// Only the last record of an input can be unterminated.
+func (r *Reader) Terminated() bool {
+	return r.terminated
+}
+
+// Consumed returns the number of bytes of input the records scanned so far
+// took up, terminators included. The Reader reads ahead, so this is less
+// than what it has read from the underlying reader. With DecodeBOM, bytes
+// are counted after decoding.
+func (r *Reader) Consumed() int64 {
+	return r.consumed
+}
+
+// Err returns the first non-EOF error encountered
+func (r *Reader) Err() error {
+	if errors.Is(r.err, io.EOF) {
+		return nil
+	}
+	return r.err
+}
+
+// DefaultChunkSize is the amount of input ChunkReader reads at a time
+const DefaultChunkSize = 1 << 20
+
+// ChunkReader reads input in large blocks that always end on a record
+// boundary, so callers can search many records with a single call and only
+// split out the records of interest. It applies the same DefaultMaxLength,
+// DecodeBOM and StripCR settings as NewReader.
+type ChunkReader struct {
+	r         io.Reader
+	delim     byte
+	maxLength int
+	stripCR   bool
+	buf       []byte
+	start     int // first unreturned byte in buf
+	scan      int // buf[start:scan] is known to hold no delimiter
+	end       int // end of valid data in buf
+	err       error
+	inMemory  bool // buf holds the whole input
+}
+
+// NewChunkReader returns a ChunkReader splitting r on '\n'
+func NewChunkReader(r io.Reader) *ChunkReader {
+	if DecodeBOM {
+		r = textenc.NewDecoder(r)
+	}
+	return &ChunkReader{
+		r:         r,
+		delim:     '\n',
+		maxLength: DefaultMaxLength,
+		stripCR:   StripCR,
+		buf:       make([]byte, DefaultChunkSize),
+	}
+}
+
+// NewChunkReaderBytes returns a ChunkReader splitting data, such as a
+// memory-mapped file, on '\n'. Blocks are slices of data itself, of about
+// DefaultChunkSize bytes so callers still get to check for cancellation,
+// and data is never modified. With DecodeBOM set the input is transcoded
+// through a copy like any other reader's.
+func NewChunkReaderBytes(data []byte) *ChunkReader {
+	if DecodeBOM {
+		return NewChunkReader(bytes.NewReader(data))
+	}
+	return &ChunkReader{
+		delim:     '\n',
+		maxLength: DefaultMaxLength,
+		stripCR:   StripCR,
+		buf:       data,
+		end:       len(data),
+		err:       io.EOF,
+		inMemory:  true,
+	}
+}
+
+// SetDelimiter changes the record terminator
+func (c *ChunkReader) SetDelimiter(delim byte) {
+	c.delim = delim
+}
+
+// Next returns the next block of complete records, each ending in the
+// delimiter except possibly the final record of the input. The block is
+// only valid until the next call. At the end of input it returns io.EOF.
+func (c *ChunkReader) Next() ([]byte, error) {
+	for {
+		end := c.end
+		if c.inMemory {
+			end = min(c.end, c.scan+DefaultChunkSize)
+		}
+
+		// Hand out everything up to the last delimiter seen so far
+		if i := bytes.LastIndexByte(c.buf[c.scan:end], c.delim); i >= 0 {
+			chunk := c.buf[c.start : c.scan+i+1]
+			c.start = c.scan + i + 1
+			c.scan = c.start
+			return c.finish(chunk)
+		}
+		c.scan = end
+
+		if c.maxLength > 0 && c.scan-c.start > c.maxLength {
+			return nil, fmt.Errorf("%w: exceeds %d bytes", ErrTooLong, c.maxLength)
+		}
+		if c.scan < c.end {
+			continue
+		}
+
+		if c.err != nil {
+			// Unterminated final record
+			if c.start < c.end {
+				chunk := c.buf[c.start:c.end]
+				c.start, c.scan = c.end, c.end
+				return c.finish(chunk)
+			}
+			return nil, c.err
+		}
+
+		c.fill()
+	}
+}
+
+// fill moves pending bytes to the front of the buffer, growing it when a
+// single record fills it, and reads more input
+func (c *ChunkReader) fill() {
+	if c.start > 0 {
+		c.end = copy(c.buf, c.buf[c.start:c.end])
+		c.scan -= c.start
+		c.start = 0
+	}
+	if c.end == len(c.buf) {
+		grown := make([]byte, 2*len(c.buf))
+		copy(grown, c.buf[:c.end])
+		c.buf = grown
+	}
+
+	n, err := c.r.Read(c.buf[c.end:])
+	c.end += n
+	if err != nil {
+		c.err = err
+	}
+}
+
+// finish applies CRLF stripping to a chunk and checks record lengths
+func (c *ChunkReader) finish(chunk []byte) ([]byte, error) {
+	if c.stripCR && c.delim == '\n' {
+		chunk = bytes.ReplaceAll(chunk, []byte("\r\n"), []byte("\n"))
+	}
+
+	if c.maxLength > 0 {
+		for rest := chunk; len(rest) > 0; {
+			i := bytes.IndexByte(rest, c.delim)
+			if i < 0 {
+				i = len(rest)
+			}
+			if i > c.maxLength {
+				return nil, fmt.Errorf("%w: exceeds %d bytes", ErrTooLong, c.maxLength)
+			}
+			rest = rest[min(i+1, len(rest)):]
+		}
+	}
+	return chunk, nil
+}
@@ -0,0 +1,181 @@
+package lines
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect reads all records and their terminated flags
+func collect(r *Reader) ([]string, []bool) {
+	var records []string
+	var terminated []bool
+	for r.Scan() {
+		records = append(records, r.Text())
+		terminated = append(terminated, r.Terminated())
+	}
+	return records, terminated
+}
+
+// TestReader_Basic tests splitting terminated lines
+func TestReader_Basic(t *testing.T) {
+	r := NewReader(strings.NewReader("a\nb\n\nc\n"))
+	records, terminated := collect(r)
+	require.NoError(t, r.Err())
+	assert.Equal(t, []string{"a", "b", "", "c"}, records)
+	assert.Equal(t, []bool{true, true, true, true}, terminated)
+}
+
+// TestReader_NoTrailingNewline tests that the last record is reported unterminated
+func TestReader_NoTrailingNewline(t *testing.T) {
+	r := NewReader(strings.NewReader("a\nb"))
+	records, terminated := collect(r)
+	require.NoError(t, r.Err())
+	assert.Equal(t, []string{"a", "b"}, records)
+	assert.Equal(t, []bool{true, false}, terminated)
+}
+
+// TestReader_PreservesCR tests that CRLF input keeps its carriage returns
+func TestReader_PreservesCR(t *testing.T) {
+	r := NewReader(strings.NewReader("a\r\nb\r\n"))
+	records, _ := collect(r)
+	assert.Equal(t, []string{"a\r", "b\r"}, records)
+}
+
+// TestReader_LongLine tests lines far beyond bufio.Scanner's 64KB limit
+func TestReader_LongLine(t *testing.T) {
+	long := strings.Repeat("x", 1<<20)
+	r := NewReader(strings.NewReader(long + "\nshort\n"))
+	records, _ := collect(r)
+	require.NoError(t, r.Err())
+	require.Equal(t, 2, len(records))
+	assert.Equal(t, long, records[0])
+	assert.Equal(t, "short", records[1])
+}
+
+// TestReader_MaxLength tests the configurable length cap
+func TestReader_MaxLength(t *testing.T) {
+	r := NewReader(strings.NewReader("ok\n" + strings.Repeat("x", 200*1024) + "\n"))
+	r.SetMaxLength(1024)
+	records, _ := collect(r)
+	assert.Equal(t, []string{"ok"}, records)
+	assert.True(t, errors.Is(r.Err(), ErrTooLong))
+}
+
+// TestReader_Delimiter tests NUL-separated records
+func TestReader_Delimiter(t *testing.T) {
+	r := NewReader(strings.NewReader("a b\x00c\nd\x00"))
+	r.SetDelimiter(0)
+	records, _ := collect(r)
+	assert.Equal(t, []string{"a b", "c\nd"}, records)
+}
+
+// TestReader_Empty tests empty input
+func TestReader_Empty(t *testing.T) {
+	r := NewReader(strings.NewReader(""))
+	records, _ := collect(r)
+	require.NoError(t, r.Err())
+	assert.Equal(t, 0, len(records))
+}
+
+// TestReader_StripCR tests treating CRLF as the line terminator
+func TestReader_StripCR(t *testing.T) {
+	r := NewReader(strings.NewReader("a\r\nb\rc\r\nd\r"))
+	r.SetStripCR(true)
+	records, _ := collect(r)
+	assert.Equal(t, []string{"a", "b\rc", "d\r"}, records)
+}
+
+// TestReader_Consumed tests counting the input taken up by the records
+// scanned so far
+func TestReader_Consumed(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	r := NewReader(strings.NewReader("ab\r\n" + long + "\nlast"))
+	r.SetStripCR(true)
+
+	var consumed []int64
+	for r.Scan() {
+		consumed = append(consumed, r.Consumed())
+	}
+	assert.Equal(t, []int64{4, int64(5 + len(long)), int64(9 + len(long))}, consumed)
+}
+
+// TestChunkReader tests that chunks end on record boundaries across reads
+func TestChunkReader(t *testing.T) {
+	long := strings.Repeat("y", 3*DefaultChunkSize)
+	input := "a\nb\n" + long + "\nc"
+
+	r := NewChunkReader(&smallReader{s: input, n: 7})
+	var got strings.Builder
+	for {
+		chunk, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if len(chunk) > 0 && chunk[len(chunk)-1] != '\n' {
+			assert.Equal(t, "c", string(chunk), "only the final record may be unterminated")
+		}
+		got.Write(chunk)
+	}
+	assert.Equal(t, input, got.String())
+}
+
+// TestChunkReaderBytes tests that in-memory input is handed out in bounded
+// blocks of its own bytes
+func TestChunkReaderBytes(t *testing.T) {
+	line := strings.Repeat("z", 1000) + "\n"
+	input := []byte(strings.Repeat(line, 3000) + strings.Repeat("y", 3*DefaultChunkSize) + "\nc")
+
+	r := NewChunkReaderBytes(input)
+	var got []byte
+	chunks := 0
+	for {
+		chunk, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if chunks == 0 {
+			assert.LessOrEqual(t, len(chunk), DefaultChunkSize)
+			assert.Same(t, &input[0], &chunk[0], "blocks are not copied")
+		}
+		chunks++
+		got = append(got, chunk...)
+	}
+	assert.Equal(t, input, got)
+	assert.Greater(t, chunks, 3)
+}
+
+// TestChunkReader_StripCRAndMaxLength tests the shared global settings
+func TestChunkReader_StripCRAndMaxLength(t *testing.T) {
+	r := NewChunkReader(strings.NewReader("a\r\nb\r\n"))
+	r.stripCR = true
+	chunk, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(chunk))
+
+	r = NewChunkReader(strings.NewReader("ok\n" + strings.Repeat("x", 100) + "\n"))
+	r.maxLength = 10
+	_, err = r.Next()
+	assert.True(t, errors.Is(err, ErrTooLong))
+}
+
+// smallReader returns at most n bytes per Read call
+type smallReader struct {
+	s string
+	n int
+}
+
+func (r *smallReader) Read(p []byte) (int, error) {
+	if len(r.s) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), r.n)], r.s)
+	r.s = r.s[n:]
+	return n, nil
+}
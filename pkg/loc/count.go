@@ -0,0 +1,169 @@
+package loc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+)
+
+// Counts holds the line counts of a file or of a language
+type Counts struct {
+	Files   int64 `json:"files"`
+	Blank   int64 `json:"blank"`
+	Comment int64 `json:"comment"`
+	Code    int64 `json:"code"`
+}
+
+// add adds other to c
+func (c *Counts) add(other Counts) {
+	c.Files += other.Files
+	c.Blank += other.Blank
+	c.Comment += other.Comment
+	c.Code += other.Code
+}
+
+// scanner classifies the lines of a file, carrying comments and strings
+// that span lines from one line to the next
+type scanner struct {
+	lang     *language
+	blockEnd string // the end of the block comment the scanner is in
+	quote    string // the delimiter of the string the scanner is in
+}
+
+// countReader counts the blank, comment and code lines of r as lang
+func countReader(ctx context.Context, r io.Reader, lang *language) (Counts, error) {
+	counts := Counts{Files: 1}
+	s := &scanner{lang: lang}
+	br := bufio.NewReaderSize(interrupt.Reader(ctx, r), 64*1024)
+	var long []byte // a line longer than the buffer, gathered in pieces
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			long = append(long, chunk...)
+			continue
+		}
+		line := chunk
+		if len(long) > 0 {
+			line = append(long, chunk...)
+			long = long[:0]
+		}
+		if len(line) > 0 {
+			code, comment := s.line(string(bytes.TrimRight(line, "\r\n")))
+			switch {
+			case code:
+				counts.Code++
+			case comment:
+				counts.Comment++
+			default:
+				counts.Blank++
+			}
+		}
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, err
+		}
+	}
+}
+
+// line reports whether a line holds code, outside comments, and whether
+// it holds a comment. A line with neither is blank. Strings are code, so
+// a comment marker inside one doesn't start a comment.
+func (s *scanner) line(text string) (code, comment bool) {
+	// Lines inside a comment or string are that, even if empty
+	code, comment = s.quote != "", s.blockEnd != ""
+	i := 0
+	for i < len(text) {
+		if s.blockEnd != "" {
+			comment = true
+			j := strings.Index(text[i:], s.blockEnd)
+			if j < 0 {
+				return code, comment
+			}
+			i += j + len(s.blockEnd)
+			s.blockEnd = ""
+			continue
+		}
+		if s.quote != "" {
+			code = true
+			i = s.endString(text, i)
+			continue
+		}
+
+		rest := text[i:]
+		if c := rest[0]; c == ' ' || c == '\t' || c == '\f' || c == '\v' {
+			i++
+			continue
+		}
+		// Block comments first, for Lua's --[[ which starts with --
+		if start, end, ok := s.startBlock(rest); ok {
+			comment = true
+			s.blockEnd = end
+			i += len(start)
+			continue
+		}
+		for _, marker := range s.lang.line {
+			if strings.HasPrefix(rest, marker) {
+				return code, true
+			}
+		}
+		code = true
+		if q := s.startString(rest); q != "" {
+			s.quote = q
+			i += len(q)
+			continue
+		}
+		i++
+	}
+
+	// Only raw and triple-quoted strings go on to the next line
+	if s.quote != "" && s.quote != "`" && len(s.quote) < 3 {
+		s.quote = ""
+	}
+	return code, comment
+}
+
+// startBlock returns the block comment that starts rest, if any
+func (s *scanner) startBlock(rest string) (start, end string, ok bool) {
+	for _, block := range s.lang.block {
+		if strings.HasPrefix(rest, block[0]) {
+			return block[0], block[1], true
+		}
+	}
+	return "", "", false
+}
+
+// startString returns the string delimiter that starts rest, if any;
+// the delimiters are listed longest first
+func (s *scanner) startString(rest string) string {
+	for _, q := range s.lang.quotes {
+		if strings.HasPrefix(rest, q) {
+			return q
+		}
+	}
+	return ""
+}
+
+// endString returns the offset just past the end of the string the
+// scanner is in, or the end of text if the string goes on. Backslashes
+// escape the next character except in raw ` strings.
+func (s *scanner) endString(text string, i int) int {
+	for i < len(text) {
+		if text[i] == '\\' && s.quote != "`" {
+			i += 2
+			continue
+		}
+		if q := s.quote; strings.HasPrefix(text[i:], q) {
+			s.quote = ""
+			return i + len(q)
+		}
+		i++
+	}
+	return len(text)
+}
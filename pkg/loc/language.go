@@ -0,0 +1,167 @@
+package loc
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// language describes the comment and string syntax of a language, enough
+// to tell code from comments a line at a time
+type language struct {
+	name   string
+	line   []string    // starts of comments that run to the end of the line
+	block  [][2]string // start and end of comments that may span lines
+	quotes []string    // string delimiters; "`" and triple quotes may span lines
+}
+
+var (
+	cStyle     = language{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, quotes: []string{`"`, `'`}}
+	hashStyle  = language{line: []string{"#"}, quotes: []string{`"`, `'`}}
+	dashStyle  = language{line: []string{"--"}, quotes: []string{`"`, `'`}}
+	markup     = language{block: [][2]string{{"<!--", "-->"}}}
+	plainText  = language{}
+	tripleHash = language{line: []string{"#"}, quotes: []string{`"""`, `'''`, `"`, `'`}}
+)
+
+// named returns a copy of syntax under a language name
+func named(name string, syntax language) *language {
+	syntax.name = name
+	return &syntax
+}
+
+// with returns a copy of syntax with other string delimiters
+func with(syntax language, quotes ...string) language {
+	syntax.quotes = quotes
+	return syntax
+}
+
+// extensions maps lower-case file extensions to their languages
+var extensions = map[string]*language{}
+
+// filenames maps file names without a telling extension to their languages
+var filenames = map[string]*language{}
+
+// interpreters maps the programs named by a #! line to their languages
+var interpreters = map[string]*language{}
+
+func init() {
+	add := func(lang *language, exts ...string) {
+		for _, ext := range exts {
+			extensions[ext] = lang
+		}
+	}
+	shell := named("Shell", hashStyle)
+	python := named("Python", tripleHash)
+	ruby := named("Ruby", language{line: []string{"#"}, block: [][2]string{{"=begin", "=end"}}, quotes: []string{`"`, `'`}})
+	perl := named("Perl", hashStyle)
+	javascript := named("JavaScript", with(cStyle, "`", `"`, `'`))
+	makefile := named("Makefile", language{line: []string{"#"}})
+	dockerfile := named("Dockerfile", language{line: []string{"#"}})
+
+	add(named("Go", with(cStyle, "`", `"`, `'`)), ".go")
+	add(named("C", cStyle), ".c")
+	add(named("C Header", cStyle), ".h")
+	add(named("C++", cStyle), ".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx")
+	add(named("C#", cStyle), ".cs")
+	add(named("Objective-C", cStyle), ".m", ".mm")
+	add(named("Java", with(cStyle, `"""`, `"`, `'`)), ".java")
+	add(named("Kotlin", with(cStyle, `"""`, `"`, `'`)), ".kt", ".kts")
+	add(named("Scala", with(cStyle, `"""`, `"`, `'`)), ".scala")
+	add(named("Swift", with(cStyle, `"""`, `"`)), ".swift")
+	add(named("Groovy", cStyle), ".groovy", ".gradle")
+	add(named("Dart", with(cStyle, `"""`, `'''`, `"`, `'`)), ".dart")
+	add(named("Rust", with(cStyle, `"`)), ".rs")
+	add(named("Zig", with(cStyle, `"`, `'`)), ".zig")
+	add(javascript, ".js", ".mjs", ".cjs", ".jsx")
+	add(named("TypeScript", with(cStyle, "`", `"`, `'`)), ".ts", ".mts", ".cts", ".tsx")
+	add(named("PHP", language{line: []string{"//", "#"}, block: cStyle.block, quotes: cStyle.quotes}), ".php")
+	add(named("Protocol Buffers", cStyle), ".proto")
+	add(named("CSS", language{block: cStyle.block, quotes: cStyle.quotes}), ".css")
+	add(named("SCSS", cStyle), ".scss", ".sass", ".less")
+	add(named("Terraform", language{line: []string{"#", "//"}, block: cStyle.block, quotes: []string{`"`}}), ".tf", ".tfvars", ".hcl")
+	add(python, ".py", ".pyw", ".pyi")
+	add(ruby, ".rb", ".rake", ".gemspec")
+	add(perl, ".pl", ".pm")
+	add(shell, ".sh", ".bash", ".zsh", ".ksh")
+	add(named("PowerShell", language{line: []string{"#"}, block: [][2]string{{"<#", "#>"}}, quotes: []string{`"`, `'`}}), ".ps1", ".psm1", ".psd1")
+	add(named("R", hashStyle), ".r")
+	add(named("Elixir", with(hashStyle, `"""`, `"`, `'`)), ".ex", ".exs")
+	add(named("Erlang", language{line: []string{"%"}, quotes: []string{`"`}}), ".erl", ".hrl")
+	add(named("YAML", hashStyle), ".yaml", ".yml")
+	add(named("TOML", with(hashStyle, `"""`, `'''`, `"`, `'`)), ".toml")
+	add(named("INI", language{line: []string{";", "#"}}), ".ini", ".cfg")
+	add(named("SQL", language{line: dashStyle.line, block: cStyle.block, quotes: []string{`'`, `"`}}), ".sql")
+	add(named("Lua", language{line: []string{"--"}, block: [][2]string{{"--[[", "]]"}}, quotes: []string{`"`, `'`}}), ".lua")
+	add(named("Haskell", language{line: []string{"--"}, block: [][2]string{{"{-", "-}"}}, quotes: []string{`"`}}), ".hs")
+	add(named("HTML", markup), ".html", ".htm")
+	add(named("XML", markup), ".xml", ".xsd", ".xsl", ".svg", ".plist")
+	add(named("Vue", language{line: []string{"//"}, block: [][2]string{{"<!--", "-->"}, {"/*", "*/"}}, quotes: []string{`"`, `'`, "`"}}), ".vue")
+	add(named("Markdown", markup), ".md", ".markdown")
+	add(named("JSON", with(plainText, `"`)), ".json")
+	add(named("Text", plainText), ".txt")
+	add(makefile, ".mk", ".mak")
+	add(dockerfile, ".dockerfile")
+
+	for _, name := range []string{"Makefile", "makefile", "GNUmakefile"} {
+		filenames[name] = makefile
+	}
+	filenames["Dockerfile"] = dockerfile
+	filenames["Containerfile"] = dockerfile
+	filenames["Rakefile"] = ruby
+	filenames["Gemfile"] = ruby
+	filenames["CMakeLists.txt"] = named("CMake", hashStyle)
+
+	for _, name := range []string{"sh", "bash", "zsh", "ksh", "dash"} {
+		interpreters[name] = shell
+	}
+	for _, name := range []string{"python", "python2", "python3"} {
+		interpreters[name] = python
+	}
+	interpreters["ruby"] = ruby
+	interpreters["perl"] = perl
+	interpreters["node"] = javascript
+}
+
+// detect returns the language of the file at path by its name, or for
+// files without an extension by the interpreter of a #! line, or nil
+func detect(path string) *language {
+	base := filepath.Base(path)
+	if lang := filenames[base]; lang != nil {
+		return lang
+	}
+	if ext := filepath.Ext(base); ext != "" && ext != base {
+		return extensions[strings.ToLower(ext)]
+	}
+	return shebang(path)
+}
+
+// shebang returns the language of a script by its #! line, as in
+// "#!/bin/sh" or "#!/usr/bin/env python3"
+func shebang(path string) *language {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	first, err := bufio.NewReaderSize(f, 256).ReadSlice('\n')
+	if err != nil && len(first) == 0 {
+		return nil
+	}
+	rest, ok := bytes.CutPrefix(first, []byte("#!"))
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(string(rest))
+	if len(fields) == 0 {
+		return nil
+	}
+	program := filepath.Base(fields[0])
+	if program == "env" && len(fields) > 1 {
+		program = fields[1]
+	}
+	return interpreters[program]
+}
@@ -0,0 +1,201 @@
+package loc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// Options holds loc configuration
+type Options struct {
+	Format    string // "text" or "json"
+	Gitignore bool   // honor .gitignore and .ignore files
+	Jobs      int    // directories searched concurrently; 0 means one per CPU
+}
+
+// Stats holds the counts of each language found
+type Stats struct {
+	mu        sync.Mutex
+	languages map[string]*Counts
+	failed    atomic.Bool // a file could not be read
+}
+
+// row is a line of --format json: a language, or the total of all
+type row struct {
+	Language string `json:"language,omitempty"`
+	Total    bool   `json:"total,omitempty"`
+	Counts
+}
+
+// Command returns the loc command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "loc [path...]",
+		Short: "Count lines of code, comments and blank lines per language",
+		Long: `Count files, blank lines, comment lines and code lines per language in
+the files and directories given, or the current directory.
+
+Languages are recognized by file extension or name (Makefile, Dockerfile),
+and scripts without an extension by their #! line; other files are left
+out. Each line is classified with the comment and string syntax of its
+language: a line with any code on it is code, even with a comment after
+it, a line with only comments is a comment line, and a line with neither
+is blank. Comment markers inside strings don't start comments, and block
+comments and raw or triple-quoted strings carry over to the lines after.
+
+--format json prints a JSON object per line for each language, with
+"language", "files", "blank", "comment" and "code", and a last one with
+"total": true. Languages are listed with the most code first.
+
+Examples:
+  loc
+  loc --gitignore --format json src`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Format != "text" && opts.Format != "json" {
+				return exitcode.New(2, fmt.Errorf("invalid format %q: expected text or json", opts.Format))
+			}
+			paths := glob.Expand(args)
+			if len(paths) == 0 {
+				paths = []string{"."}
+			}
+
+			stats := &Stats{languages: map[string]*Counts{}}
+			walker := &walk.Walker{Gitignore: opts.Gitignore, Jobs: opts.Jobs}
+			failed := false
+			for _, path := range paths {
+				if err := countPath(cmd.Context(), path, walker, stats); err != nil {
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					logging.PathError("Failed to count", path, err)
+					failed = true
+				}
+			}
+			if err := stats.write(output.Stdout, opts.Format); err != nil {
+				return err
+			}
+
+			if failed || walker.Failed() || stats.failed.Load() {
+				cmd.SilenceErrors = true
+				return exitcode.Status(1)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "Output format: text or json (one object per line)")
+	cmd.Flags().BoolVar(&opts.Gitignore, "gitignore", false, "Skip paths ignored by .gitignore and .ignore files")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Count up to `N` directories concurrently (default: number of CPUs)")
+
+	return cmd
+}
+
+// countPath counts the files of known languages at or below root. Files
+// that cannot be read are reported and marked in stats.
+func countPath(ctx context.Context, root string, w *walk.Walker, stats *Stats) error {
+	return w.WalkParallel(ctx, root, true, func(path string, entry fs.DirEntry, depth int) (string, error) {
+		if !entry.Type().IsRegular() {
+			return "", nil
+		}
+		lang := detect(path)
+		if lang == nil {
+			return "", nil
+		}
+		counts, err := countFile(ctx, path, lang)
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return "", err
+			}
+			logging.PathError("Failed to read", path, err)
+			stats.failed.Store(true)
+			return "", nil
+		}
+		stats.add(lang.name, counts)
+		return "", nil
+	}, func(string) error { return nil })
+}
+
+// countFile counts the lines of the file at path as lang
+func countFile(ctx context.Context, path string, lang *language) (Counts, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Counts{}, err
+	}
+	defer f.Close()
+	return countReader(ctx, f, lang)
+}
+
+// add adds the counts of a file to its language
+func (s *Stats) add(name string, counts Counts) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.languages[name] == nil {
+		s.languages[name] = &Counts{}
+	}
+	s.languages[name].add(counts)
+}
+
+// rows returns a row for each language, the most code first, and the
+// total
+func (s *Stats) rows() ([]row, row) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]row, 0, len(s.languages))
+	total := row{Total: true}
+	for name, counts := range s.languages {
+		rows = append(rows, row{Language: name, Counts: *counts})
+		total.add(*counts)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Code != rows[j].Code {
+			return rows[i].Code > rows[j].Code
+		}
+		return rows[i].Language < rows[j].Language
+	})
+	return rows, total
+}
+
+// write prints a table of the languages and their total, or with format
+// "json" an object per line for each
+func (s *Stats) write(w io.Writer, format string) error {
+	rows, total := s.rows()
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for _, r := range append(rows, total) {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "%-20s %8s %10s %10s %10s\n", "Language", "Files", "Blank", "Comment", "Code"); err != nil {
+		return err
+	}
+	total.Language = "Total"
+	for _, r := range append(rows, total) {
+		if _, err := fmt.Fprintf(w, "%-20s %8d %10d %10d %10d\n", r.Language, r.Files, r.Blank, r.Comment, r.Code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package loc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// count counts text as the language of files with ext
+func count(t *testing.T, ext, text string) Counts {
+	counts, err := countReader(context.Background(), strings.NewReader(text), extensions[ext])
+	require.NoError(t, err)
+	return counts
+}
+
+// TestCountReader tests classifying lines with comments and strings that
+// span lines, and comment markers inside strings
+func TestCountReader(t *testing.T) {
+	goSource := `package main
+
+// main prints
+func main() {
+	/* a block
+	   comment
+
+	*/
+	s := "// not a comment" // trailing
+	r := ` + "`raw\n/* still raw */\n`" + `
+	/* one */ x := 1
+}
+`
+	assert.Equal(t, Counts{Files: 1, Blank: 1, Comment: 5, Code: 8}, count(t, ".go", goSource))
+
+	python := "#!/usr/bin/env python3\n\ndef f():\n    \"\"\"Doc\n\n    string\"\"\"\n    return '#'  # hash\r\n"
+	assert.Equal(t, Counts{Files: 1, Blank: 1, Comment: 1, Code: 5}, count(t, ".py", python))
+
+	lua := "--[[ block\nstill ]] x = 1\n-- line\n"
+	assert.Equal(t, Counts{Files: 1, Comment: 2, Code: 1}, count(t, ".lua", lua))
+
+	assert.Equal(t, Counts{Files: 1, Blank: 1, Comment: 1, Code: 1}, count(t, ".html", "<p>\n\n<!-- x -->"))
+}
+
+// TestDetect tests recognizing languages by extension, name and #! line
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(text), 0644))
+		return path
+	}
+	name := func(path string) string {
+		if lang := detect(path); lang != nil {
+			return lang.name
+		}
+		return ""
+	}
+
+	assert.Equal(t, "Go", name("main.go"))
+	assert.Equal(t, "C++", name("A.HPP"))
+	assert.Equal(t, "Makefile", name("src/Makefile"))
+	assert.Equal(t, "", name("data.bin"))
+	assert.Equal(t, "Python", name(write("tool", "#!/usr/bin/env python3\nprint()\n")))
+	assert.Equal(t, "Shell", name(write("run", "#!/bin/sh -e\n")))
+	assert.Equal(t, "", name(write("notes", "hello\n")))
+}
+
+// TestStats_Write tests the totals of a walk, most code first, as a table
+// and as JSON
+func TestStats_Write(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n\n// x\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "b.go"), []byte("package b\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "c.sh"), []byte("echo\necho\necho\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "image.png"), []byte{0x89, 'P', 'N', 'G'}, 0644))
+
+	stats := &Stats{languages: map[string]*Counts{}}
+	require.NoError(t, countPath(context.Background(), root, &walk.Walker{}, stats))
+
+	var text bytes.Buffer
+	require.NoError(t, stats.write(&text, "text"))
+	assert.Equal(t, `Language                Files      Blank    Comment       Code
+Shell                       1          0          0          3
+Go                          2          1          1          2
+Total                       3          1          1          5
+`, text.String())
+
+	var js bytes.Buffer
+	require.NoError(t, stats.write(&js, "json"))
+	assert.Equal(t, `{"language":"Shell","files":1,"blank":0,"comment":0,"code":3}
+{"language":"Go","files":2,"blank":1,"comment":1,"code":2}
+{"total":true,"files":3,"blank":1,"comment":1,"code":5}
+`, js.String())
+}
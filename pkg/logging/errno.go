@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// errnoNames maps system error numbers to their errno names
+var errnoNames = map[syscall.Errno]string{
+	syscall.EPERM:        "EPERM",
+	syscall.ENOENT:       "ENOENT",
+	syscall.EIO:          "EIO",
+	syscall.EBADF:        "EBADF",
+	syscall.EACCES:       "EACCES",
+	syscall.EBUSY:        "EBUSY",
+	syscall.EEXIST:       "EEXIST",
+	syscall.EXDEV:        "EXDEV",
+	syscall.ENOTDIR:      "ENOTDIR",
+	syscall.EISDIR:       "EISDIR",
+	syscall.EINVAL:       "EINVAL",
+	syscall.EMFILE:       "EMFILE",
+	syscall.ENOSPC:       "ENOSPC",
+	syscall.EROFS:        "EROFS",
+	syscall.EPIPE:        "EPIPE",
+	syscall.ENAMETOOLONG: "ENAMETOOLONG",
+	syscall.ENOTEMPTY:    "ENOTEMPTY",
+	syscall.ELOOP:        "ELOOP",
+}
+
+// Code returns an errno-style code for err, such as "ENOENT", or "" when
+// err does not come from the operating system. Platform errors that Go
+// maps onto the portable fs errors (e.g. Windows' ERROR_FILE_NOT_FOUND)
+// get the matching POSIX name.
+func Code(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if name, ok := errnoNames[errno]; ok {
+			return name
+		}
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "ENOENT"
+	case errors.Is(err, fs.ErrPermission):
+		return "EACCES"
+	case errors.Is(err, fs.ErrExist):
+		return "EEXIST"
+	}
+	return ""
+}
+
+// errorPath returns the file an error is about, if it records one
+func errorPath(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Path
+	}
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return linkErr.Old
+	}
+	return ""
+}
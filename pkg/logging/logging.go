@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the lower-case level name used in output
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Supported values of the --log-format flag
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Settings bound to the global --verbose, --quiet, --log-format and
+// --errors flags. With Errors set to FormatJSON, warnings and errors are
+// written as records with tool, path and code fields whatever Format is.
+var (
+	Verbose = false
+	Quiet   = false
+	Format  = FormatText
+	Errors  = FormatText
+)
+
+// Tool is the name of the running command, reported in error records
+var Tool string
+
+// Output is where messages are written
+var Output io.Writer = os.Stderr
+
+var mu sync.Mutex
+
+// Validate checks the flag settings for conflicts and unknown formats
+func Validate() error {
+	if Verbose && Quiet {
+		return fmt.Errorf("--verbose and --quiet are mutually exclusive")
+	}
+	if Format != FormatText && Format != FormatJSON {
+		return fmt.Errorf("invalid --log-format value %q (want text or json)", Format)
+	}
+	if Errors != FormatText && Errors != FormatJSON {
+		return fmt.Errorf("invalid --errors value %q (want text or json)", Errors)
+	}
+	return nil
+}
+
+// LeadingFlags sets Verbose and Quiet from --verbose and --quiet flags
+// given before the command name, and returns args without them. Commands
+// such as grep (-q) and rm (-v) have flags of the same names, which cobra
+// would give precedence wherever they appear; placed before the command
+// name they are meant for logging. takesValue reports whether another
+// global flag is followed by its value as a separate argument.
+func LeadingFlags(args []string, takesValue func(name string) bool) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return append(out, args[i:]...)
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		var target *bool
+		switch name {
+		case "verbose":
+			target = &Verbose
+		case "quiet":
+			target = &Quiet
+		}
+		if target != nil && strings.HasPrefix(arg, "--") {
+			on, err := strconv.ParseBool(value)
+			if !hasValue || err == nil {
+				*target = !hasValue || on
+				continue
+			}
+		}
+
+		out = append(out, arg)
+		if strings.HasPrefix(arg, "--") && !hasValue && takesValue(name) && i+1 < len(args) {
+			i++
+			out = append(out, args[i])
+		}
+	}
+	return out
+}
+
+// ScanErrors sets Errors from an --errors flag in args ahead of flag
+// parsing, so that usage errors can be reported in the requested format
+func ScanErrors(args []string) {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return
+		case arg == "--errors" && i+1 < len(args):
+			Errors = args[i+1]
+		case strings.HasPrefix(arg, "--errors="):
+			Errors = strings.TrimPrefix(arg, "--errors=")
+		}
+	}
+}
+
+// minLevel returns the lowest level that is written: debug with --verbose,
+// error with --quiet, info otherwise
+func minLevel() Level {
+	switch {
+	case Verbose:
+		return LevelDebug
+	case Quiet:
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// Debug logs details that are only interesting with --verbose
+func Debug(args ...any) {
+	write(LevelDebug, args)
+}
+
+// Info logs a notice such as a skipped file
+func Info(args ...any) {
+	write(LevelInfo, args)
+}
+
+// Warn logs a problem the command worked around
+func Warn(args ...any) {
+	write(LevelWarn, args)
+}
+
+// Error logs a failure, typically for one of several inputs
+func Error(args ...any) {
+	write(LevelError, args)
+}
+
+// PathError logs a failure on path, such as a file that could not be read.
+// The text form is "msg path : err".
+func PathError(msg, path string, err error) {
+	writePath(LevelError, path, err, []any{msg, path, ":", err})
+}
+
+// PathWarn logs a failure on path that the command worked around
+func PathWarn(msg, path string, err error) {
+	writePath(LevelWarn, path, err, []any{msg, path, ":", err})
+}
+
+// write formats args with spaces between them, like fmt.Println, and emits
+// a record if level is enabled
+func write(level Level, args []any) {
+	writePath(level, "", nil, args)
+}
+
+// writePath emits a record for args; path and err are only used by error
+// records and are otherwise taken from an error among args
+func writePath(level Level, path string, err error, args []any) {
+	if level < minLevel() {
+		return
+	}
+	msg := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+
+	var line []byte
+	if Errors == FormatJSON && level >= LevelWarn {
+		line = errorRecord(level, path, err, msg, args)
+	} else if Format == FormatJSON {
+		line, _ = json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{time.Now().Format(time.RFC3339Nano), level.String(), msg})
+	} else if level == LevelInfo {
+		line = []byte(msg)
+	} else {
+		line = []byte(level.String() + ": " + msg)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	Output.Write(append(line, '\n'))
+}
+
+// errorRecord encodes a warning or error for --errors json. The message is
+// the error's own text when there is one, without the surrounding prose.
+func errorRecord(level Level, path string, err error, msg string, args []any) []byte {
+	if err == nil {
+		for _, arg := range args {
+			if e, ok := arg.(error); ok {
+				err = e
+			}
+		}
+	}
+	if err != nil {
+		msg = err.Error()
+		if path == "" {
+			path = errorPath(err)
+		}
+	}
+
+	line, _ := json.Marshal(struct {
+		Tool    string `json:"tool"`
+		Level   string `json:"level"`
+		Path    string `json:"path,omitempty"`
+		Code    string `json:"code,omitempty"`
+		Message string `json:"message"`
+	}{Tool, level.String(), path, Code(err), msg})
+	return line
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capture redirects Output and resets the flag settings after the test
+func capture(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	saved := Output
+	Output = &buf
+	t.Cleanup(func() {
+		Output, Verbose, Quiet, Format, Errors, Tool = saved, false, false, FormatText, FormatText, ""
+	})
+	return &buf
+}
+
+// TestText tests the default text format and level filtering
+func TestText(t *testing.T) {
+	buf := capture(t)
+
+	Debug("hidden")
+	Info("Skipping", "a.txt", "(destination exists)")
+	Warn("careful")
+	Error("Failed to remove", "b.txt", ":", "denied")
+
+	assert.Equal(t, "Skipping a.txt (destination exists)\nwarning: careful\nerror: Failed to remove b.txt : denied\n", buf.String())
+}
+
+// TestVerboseQuiet tests that the flags move the level threshold
+func TestVerboseQuiet(t *testing.T) {
+	buf := capture(t)
+
+	Verbose = true
+	Debug("shown")
+	assert.Equal(t, "debug: shown\n", buf.String())
+
+	buf.Reset()
+	Verbose, Quiet = false, true
+	Info("hidden")
+	Warn("hidden")
+	Error("shown")
+	assert.Equal(t, "error: shown\n", buf.String())
+}
+
+// TestJSON tests the JSON lines format
+func TestJSON(t *testing.T) {
+	buf := capture(t)
+	Format = FormatJSON
+
+	Error("Failed to cat file", "x", ":", "missing")
+
+	var record map[string]string
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "error", record["level"])
+	assert.Equal(t, "Failed to cat file x : missing", record["msg"])
+	assert.NotEmpty(t, record["time"])
+}
+
+// TestValidate tests flag validation
+func TestValidate(t *testing.T) {
+	capture(t)
+
+	assert.NoError(t, Validate())
+
+	Verbose, Quiet = true, true
+	assert.Error(t, Validate())
+
+	Verbose, Quiet, Format = false, false, "xml"
+	assert.Error(t, Validate())
+}
+
+// TestLeadingFlags tests that --verbose and --quiet before the command name
+// are taken for logging, and that those after it are left to the command
+func TestLeadingFlags(t *testing.T) {
+	capture(t)
+	takesValue := func(name string) bool { return name == "max-line-bytes" }
+
+	args := LeadingFlags([]string{"--verbose", "--max-line-bytes", "10", "rm", "--verbose", "x"}, takesValue)
+	assert.Equal(t, []string{"--max-line-bytes", "10", "rm", "--verbose", "x"}, args)
+	assert.True(t, Verbose)
+	assert.False(t, Quiet)
+
+	args = LeadingFlags([]string{"--quiet=true", "grep", "--quiet", "foo"}, takesValue)
+	assert.Equal(t, []string{"grep", "--quiet", "foo"}, args)
+	assert.True(t, Quiet)
+
+	Verbose, Quiet = false, false
+	args = LeadingFlags([]string{"--", "--verbose"}, takesValue)
+	assert.Equal(t, []string{"--", "--verbose"}, args)
+	assert.False(t, Verbose)
+}
+
+// TestErrorsJSON tests error records, including the path and code taken
+// from an *fs.PathError when no path is given
+func TestErrorsJSON(t *testing.T) {
+	buf := capture(t)
+	Errors, Tool = FormatJSON, "cat"
+
+	_, err := os.Open("does-not-exist")
+	Error(err)
+	PathWarn("Skipping", "bin.dat", errors.New("binary file"))
+	Info("not a record")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+
+	var record map[string]string
+	require.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.Equal(t, map[string]string{
+		"tool":    "cat",
+		"level":   "error",
+		"path":    "does-not-exist",
+		"code":    "ENOENT",
+		"message": err.Error(),
+	}, record)
+
+	record = nil
+	require.NoError(t, json.Unmarshal(lines[1], &record))
+	assert.Equal(t, map[string]string{
+		"tool":    "cat",
+		"level":   "warning",
+		"path":    "bin.dat",
+		"message": "binary file",
+	}, record)
+
+	assert.Equal(t, "not a record", string(lines[2]))
+}
+
+// TestScanErrors tests finding --errors before flag parsing
+func TestScanErrors(t *testing.T) {
+	capture(t)
+
+	ScanErrors([]string{"ls", "--errors", "json", "-l"})
+	assert.Equal(t, FormatJSON, Errors)
+
+	Errors = FormatText
+	ScanErrors([]string{"grep", "--", "--errors=json"})
+	assert.Equal(t, FormatText, Errors)
+}
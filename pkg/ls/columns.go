@@ -0,0 +1,122 @@
+package ls
+
+import (
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// layout is how names are placed when not using the long format
+type layout int
+
+const (
+	singleColumn layout = iota // one name per line
+	columnsDown                // columns, filled top to bottom (-C)
+	rowsAcross                 // columns, filled left to right (-x)
+)
+
+// defaultWidth is the width laid out for when neither COLUMNS nor the
+// terminal gives one
+const defaultWidth = 80
+
+// columnGap is the least space between two columns
+const columnGap = 2
+
+// resolveLayout picks the layout from the flags: -1 beats -x, which beats
+// -C, and without any of them names go in columns only on a terminal
+func resolveLayout(opts *Options, interactive bool) layout {
+	switch {
+	case opts.OneColumn:
+		return singleColumn
+	case opts.Across:
+		return rowsAcross
+	case opts.Columns, interactive:
+		return columnsDown
+	}
+	return singleColumn
+}
+
+// gridSize returns the number of rows and the column widths, gaps
+// included but for the last column, of the layout with the most columns
+// that fits names of the given widths in width. Names wider than width
+// get a column of their own.
+func gridSize(widths []int, width int, across bool) (int, []int) {
+	n := len(widths)
+	if n == 0 {
+		return 0, nil
+	}
+	for cols := min(n, max(width/(1+columnGap), 1)); cols > 1; cols-- {
+		rows := (n + cols - 1) / cols
+		if !across {
+			// Filling down may leave the last columns empty
+			cols = (n + rows - 1) / rows
+		}
+		colWidths := make([]int, cols)
+		for i, w := range widths {
+			c := i / rows
+			if across {
+				c = i % cols
+			}
+			if c < cols-1 {
+				w += columnGap
+			}
+			colWidths[c] = max(colWidths[c], w)
+		}
+		total := 0
+		for _, w := range colWidths {
+			total += w
+		}
+		if total <= width {
+			return rows, colWidths
+		}
+	}
+	return n, []int{0}
+}
+
+// printGrid writes the names of entries in columns fitting width, padding
+// by display width so that wide characters and colors line up
+func printGrid(w io.Writer, entries []FileEntry, opts *Options) {
+	prefixes := make([]string, len(entries))
+	widths := make([]int, len(entries))
+	inodes := inodeWidth(entries, opts)
+	for i, entry := range entries {
+		prefixes[i] = inodePrefix(entry.Info, inodes, opts) + contextPrefix(&entry, opts)
+		widths[i] = len(prefixes[i]) + runewidth.StringWidth(entry.Name) + len(classify(entry.Info, opts))
+		// The status is colored, but always two letters and a space
+		if status := gitPrefix(&entry, opts); status != "" {
+			prefixes[i] += status
+			widths[i] += 3
+		}
+	}
+	across := opts.layout == rowsAcross
+	rows, colWidths := gridSize(widths, opts.width, across)
+	cols := len(colWidths)
+
+	var line strings.Builder
+	for r := 0; r < rows; r++ {
+		line.Reset()
+		for c := 0; c < cols; c++ {
+			i := c*rows + r
+			if across {
+				i = r*cols + c
+			}
+			if i >= len(entries) {
+				break
+			}
+			entry := &entries[i]
+			line.WriteString(prefixes[i])
+			line.WriteString(paint(entry.Name, entry.Path, entry.Info, opts) + classify(entry.Info, opts))
+			// Pad only when another name follows on the line
+			next := (c+1)*rows + r
+			if across {
+				next = i + 1
+			}
+			if c < cols-1 && next < len(entries) {
+				line.WriteString(strings.Repeat(" ", colWidths[c]-widths[i]))
+			}
+		}
+		line.WriteByte('\n')
+		io.WriteString(w, line.String())
+	}
+}
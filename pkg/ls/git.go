@@ -0,0 +1,207 @@
+package ls
+
+import (
+	"bytes"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+)
+
+// gitClean is the status shown for a file git has no changes for
+const gitClean = "--"
+
+// gitRepo is the status of the files of one repository, as reported by a
+// single run of git status
+type gitRepo struct {
+	root   string            // top-level directory, with links resolved
+	status map[string]string // XY status by path relative to root; directories end in "/"
+}
+
+// gitState finds the repository of each listed directory, running git
+// status once per repository
+type gitState struct {
+	repos []*gitRepo
+	dirs  map[string]gitDir // by directory as listed
+}
+
+// gitDir is a listed directory's repository, nil outside of one, and its
+// resolved path
+type gitDir struct {
+	repo *gitRepo
+	abs  string
+}
+
+func newGitState() *gitState {
+	return &gitState{dirs: map[string]gitDir{}}
+}
+
+// repoFor returns the repository dir is in, or nil, with the resolved
+// path of dir
+func (g *gitState) repoFor(dir string) (*gitRepo, string) {
+	if d, ok := g.dirs[dir]; ok {
+		return d.repo, d.abs
+	}
+	var repo *gitRepo
+	abs, err := resolveDir(dir)
+	if err == nil {
+		for _, r := range g.repos {
+			if abs == r.root || strings.HasPrefix(abs, r.root+string(filepath.Separator)) {
+				repo = r
+				break
+			}
+		}
+		if repo == nil {
+			repo = loadRepo(abs)
+			if repo != nil {
+				g.repos = append(g.repos, repo)
+			}
+		}
+	}
+	g.dirs[dir] = gitDir{repo, abs}
+	return repo, abs
+}
+
+// resolveDir returns the absolute path of dir with links resolved, to
+// compare with what git reports
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// loadRepo runs git status for the repository dir is in, returning nil if
+// it isn't in one or git is not installed
+func loadRepo(dir string) *gitRepo {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil
+	}
+	root, err := filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil
+	}
+	out, err = exec.Command("git", "-C", root, "status", "--porcelain=v1", "-z", "--ignored").Output()
+	if err != nil {
+		return nil
+	}
+	return &gitRepo{root: root, status: parsePorcelain(out)}
+}
+
+// parsePorcelain reads the output of git status --porcelain=v1 -z into
+// statuses by path. Renamed and copied entries are followed by their
+// original path, which is skipped.
+func parsePorcelain(out []byte) map[string]string {
+	status := map[string]string{}
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if len(f) < 4 {
+			continue
+		}
+		xy := string(f[:2])
+		status[string(f[3:])] = xy
+		if xy[0] == 'R' || xy[0] == 'C' {
+			i++
+		}
+	}
+	return status
+}
+
+// statusOf returns the XY status of the file at rel, a "/" separated path
+// relative to the root. A directory not listed itself sums up the files
+// in it, each column showing the most notable status found there.
+func (r *gitRepo) statusOf(rel string, isDir bool) string {
+	if st, ok := r.status[rel]; ok {
+		return display(st)
+	}
+	if isDir {
+		if st, ok := r.status[rel+"/"]; ok {
+			return display(st)
+		}
+	}
+	// Inside a directory that is untracked or ignored as a whole
+	for p := path.Dir(rel); p != "." && p != "/"; p = path.Dir(p) {
+		if st, ok := r.status[p+"/"]; ok {
+			return display(st)
+		}
+	}
+	if !isDir {
+		return gitClean
+	}
+
+	sum := []byte(gitClean)
+	prefix := rel + "/"
+	if rel == "." {
+		prefix = ""
+	}
+	for p, st := range r.status {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		for i := 0; i < 2; i++ {
+			switch {
+			case rank(st[i]) > rank(sum[i]):
+				sum[i] = st[i]
+			case rank(st[i]) == 3 && st[i] != sum[i]:
+				// Changes of different kinds sum up as modified
+				sum[i] = 'M'
+			}
+		}
+	}
+	return string(sum)
+}
+
+// rank orders status letters by how notable they are: changes over
+// untracked files over ignored ones over none
+func rank(c byte) int {
+	switch c {
+	case ' ', '-':
+		return 0
+	case '!':
+		return 1
+	case '?':
+		return 2
+	}
+	return 3
+}
+
+// display shows an unmodified column as "-", as in --
+func display(st string) string {
+	return strings.ReplaceAll(st, " ", "-")
+}
+
+// gitPrefix returns the status of entry followed by a space, colored as
+// git colors it: staged changes green, others red. It is empty without
+// --git or outside a repository.
+func gitPrefix(entry *FileEntry, opts *Options) string {
+	if opts.git == nil {
+		return ""
+	}
+	repo, dir := opts.git.repoFor(filepath.Dir(entry.Path))
+	if repo == nil {
+		return ""
+	}
+	rel, err := filepath.Rel(repo.root, filepath.Join(dir, filepath.Base(entry.Path)))
+	if err != nil {
+		return ""
+	}
+	st := repo.statusOf(filepath.ToSlash(rel), entry.IsDir)
+
+	x, y := st[:1], st[1:]
+	switch st[0] {
+	case '-':
+	case '?', '!':
+		x = color.Paint(opts.color, x, color.Removed)
+	default:
+		x = color.Paint(opts.color, x, color.Added)
+	}
+	if st[1] != '-' {
+		y = color.Paint(opts.color, y, color.Removed)
+	}
+	return x + y + " "
+}
@@ -1,27 +1,51 @@
 package ls
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
 )
 
 // Options holds ls configuration
 type Options struct {
-	All        bool
-	Long       bool
-	Human      bool
-	Recursive  bool
-	SortByTime bool
-	SortBySize bool
-	Reverse    bool
+	All            bool
+	Long           bool
+	Human          bool
+	Recursive      bool
+	SortByTime     bool
+	SortBySize     bool
+	Reverse        bool
+	Manifest       string // "json" or "jsonl" to write a manifest instead of listing
+	Hash           string // algorithm for file hashes in the manifest, if any
+	Inode          bool   // -i: print each file's inode number first
+	Directory      bool   // -d: list directories themselves, not their contents
+	GroupDirsFirst bool   // list directories before files
+	Git            bool   // show each file's git status
+	Classify       bool   // -F: append an indicator of each file's type
+	Xattrs         bool   // -@: with -l, list extended attributes under each file
+	Context        bool   // -Z: show each file's SELinux security context
+	OneColumn      bool   // -1: one name per line
+	Columns        bool   // -C: names in columns, ordered down
+	Across         bool   // -x: names in columns, ordered across
+
+	color  bool      // resolved from the global --color mode for stdout
+	layout layout    // resolved from -1, -C, -x and whether stdout is a terminal
+	git    *gitState // set with --git
+	width  int       // columns to fit names in
 }
 
 // FileEntry represents a file/directory entry
@@ -41,23 +65,109 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "ls [flags] [paths...]",
 		Short: "List directory contents",
-		Long:  `List information about files and directories. With no paths, list the current directory.`,
-		Args:  cobra.ArbitraryArgs,
+		Long: `List information about files and directories. With no paths, list the current directory.
+File operands are listed together first, then the contents of each
+directory under its name; -d lists directories like files.
+
+--manifest describes everything below a directory as JSON instead: each
+path relative to it, with / separators, its type (file, dir, symlink or
+other), size, permission bits in octal, modification time in UTC and, for
+links, their target. --hash adds a digest of every file, as
+"sha256:...". The default format is a single document; --manifest=jsonl
+writes one entry per line. Hidden names are left out unless -a is given,
+and the configured ignore patterns apply.
+
+Without -l, names are packed into columns that fit the terminal when
+output goes to one, and printed one per line otherwise. -C packs them in
+columns filled top to bottom even when output is piped, -x fills the rows
+left to right instead, and -1 forces one name per line. The width is
+taken from COLUMNS when it is set, then from the terminal, and is 80
+otherwise.
+
+-F appends an indicator of each file's type to its name: "/" for
+directories, "*" for executables, "@" for symbolic links, "|" for named
+pipes and "=" for sockets. With -l, links are shown as "name -> target",
+and -F marks the target instead.
+
+In long format, a "+" after the permissions marks a file with a POSIX
+access control list. -@ lists the extended attributes of each file below
+it, with the size of their values, and -Z shows the SELinux security
+context of each file, or "?" for none. Systems without extended
+attributes show none.`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			paths := args
+			opts.color = color.Enabled(os.Stdout)
+			opts.layout = resolveLayout(opts, color.Interactive(os.Stdout))
+			if opts.Git {
+				opts.git = newGitState()
+			}
+			if opts.width = color.Width(os.Stdout); opts.width <= 0 {
+				opts.width = defaultWidth
+			}
+
+			paths := glob.Expand(args)
 			if len(paths) == 0 {
 				paths = []string{"."}
 			}
 
-			for i, path := range paths {
-				if err := listPath(path, opts, len(paths) > 1); err != nil {
-					eve.Logger.Error("Failed to list", path, ":", err)
+			if opts.Manifest != "" {
+				if err := validateManifest(opts); err != nil {
+					return exitcode.New(2, err)
+				}
+				if len(paths) > 1 {
+					return exitcode.New(2, fmt.Errorf("--manifest takes a single directory"))
+				}
+				failed, err := writeManifest(cmd.Context(), paths[0], opts)
+				if err != nil {
+					return err
+				}
+				if failed {
+					cmd.SilenceErrors = true
+					return exitcode.Status(1)
+				}
+				return nil
+			}
+			if opts.Hash != "" {
+				return exitcode.New(2, fmt.Errorf("--hash requires --manifest"))
+			}
+
+			// Files, and with -d directories, are listed together first, then
+			// the contents of each directory under its name
+			var files []FileEntry
+			var dirs []string
+			for _, path := range paths {
+				info, err := os.Stat(path)
+				if err != nil {
+					logging.PathError("Failed to list", path, fmt.Errorf("failed to stat path: %w", err))
+					continue
+				}
+				if info.IsDir() && !opts.Directory {
+					dirs = append(dirs, path)
+					continue
 				}
+				files = append(files, FileEntry{
+					Name:    path,
+					Info:    info,
+					Path:    path,
+					IsDir:   info.IsDir(),
+					ModTime: info.ModTime(),
+					Size:    info.Size(),
+				})
+			}
+			sortEntries(files, opts)
+			printEntries(files, opts)
 
-				// Add blank line between paths (except after last)
-				if i < len(paths)-1 && len(paths) > 1 {
+			for i, path := range dirs {
+				// Add blank line between paths
+				if i > 0 || len(files) > 0 {
 					fmt.Println()
 				}
+				if err := listPath(cmd.Context(), path, opts, len(paths) > 1); err != nil {
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					logging.PathError("Failed to list", path, err)
+				}
 			}
 
 			return nil
@@ -71,32 +181,27 @@ func Command() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.SortByTime, "time", "t", false, "Sort by modification time, newest first")
 	cmd.Flags().BoolVarP(&opts.SortBySize, "size", "S", false, "Sort by file size, largest first")
 	cmd.Flags().BoolVarP(&opts.Reverse, "reverse", "r", false, "Reverse order while sorting")
+	cmd.Flags().StringVar(&opts.Manifest, "manifest", "", "Describe everything below the directory as JSON: `json` or jsonl")
+	cmd.Flags().Lookup("manifest").NoOptDefVal = "json"
+	cmd.Flags().StringVar(&opts.Hash, "hash", "", "With --manifest, add file digests: md5, sha1, sha256 or sha512")
+	cmd.Flags().BoolVarP(&opts.Directory, "directory", "d", false, "List directories themselves, not their contents")
+	cmd.Flags().BoolVar(&opts.GroupDirsFirst, "group-directories-first", false, "List directories before files")
+	cmd.Flags().BoolVar(&opts.Git, "git", false, "Show each file's git status, such as -M or ??")
+	cmd.Flags().BoolVarP(&opts.Inode, "inode", "i", false, "Print the inode number of each file")
+	cmd.Flags().BoolVarP(&opts.Xattrs, "xattrs", "@", false, "With -l, list the extended attributes of each file and their sizes")
+	cmd.Flags().BoolVarP(&opts.Context, "context", "Z", false, "Print the SELinux security context of each file")
+	cmd.Flags().BoolVarP(&opts.Classify, "classify", "F", false, "Append an indicator of the file type to names (one of /*@|=)")
+	cmd.Flags().BoolVarP(&opts.OneColumn, "one-per-line", "1", false, "List one name per line")
+	cmd.Flags().BoolVarP(&opts.Columns, "columns", "C", false, "List names in columns, ordered down")
+	cmd.Flags().BoolVarP(&opts.Across, "across", "x", false, "List names in columns, ordered across")
 
 	return cmd
 }
 
 // listPath lists files in a path
-func listPath(path string, opts *Options, multiplePaths bool) error {
-	info, err := os.Stat(path)
-	if err != nil {
-		return fmt.Errorf("failed to stat path: %w", err)
-	}
-
-	// If path is a file, just list it
-	if !info.IsDir() {
-		if opts.Long {
-			printLongFormat(&FileEntry{
-				Name:    filepath.Base(path),
-				Info:    info,
-				Path:    path,
-				IsDir:   false,
-				ModTime: info.ModTime(),
-				Size:    info.Size(),
-			}, opts)
-		} else {
-			fmt.Println(path)
-		}
-		return nil
+func listPath(ctx context.Context, path string, opts *Options, multiplePaths bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	// List directory contents
@@ -120,7 +225,7 @@ func listPath(path string, opts *Options, multiplePaths bool) error {
 
 		info, err := entry.Info()
 		if err != nil {
-			eve.Logger.Error("Failed to get info for", entry.Name(), ":", err)
+			logging.PathError("Failed to get info for", filepath.Join(path, entry.Name()), err)
 			continue
 		}
 
@@ -137,22 +242,18 @@ func listPath(path string, opts *Options, multiplePaths bool) error {
 	// Sort entries
 	sortEntries(fileEntries, opts)
 
-	// Print entries
-	for _, entry := range fileEntries {
-		if opts.Long {
-			printLongFormat(&entry, opts)
-		} else {
-			fmt.Println(entry.Name)
-		}
-	}
+	printEntries(fileEntries, opts)
 
 	// Handle recursive listing
 	if opts.Recursive {
 		for _, entry := range fileEntries {
 			if entry.IsDir {
 				fmt.Println()
-				if err := listPath(entry.Path, opts, true); err != nil {
-					eve.Logger.Error("Failed to list", entry.Path, ":", err)
+				if err := listPath(ctx, entry.Path, opts, true); err != nil {
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					logging.PathError("Failed to list", entry.Path, err)
 				}
 			}
 		}
@@ -161,9 +262,38 @@ func listPath(path string, opts *Options, multiplePaths bool) error {
 	return nil
 }
 
-// sortEntries sorts file entries according to options
+// printEntries prints sorted entries in the format the options select
+func printEntries(entries []FileEntry, opts *Options) {
+	switch {
+	case opts.Long:
+		printLongFormat(entries, opts)
+	case opts.layout != singleColumn:
+		printGrid(os.Stdout, entries, opts)
+	default:
+		width := inodeWidth(entries, opts)
+		for _, entry := range entries {
+			fmt.Println(inodePrefix(entry.Info, width, opts) + contextPrefix(&entry, opts) + gitPrefix(&entry, opts) + paint(entry.Name, entry.Path, entry.Info, opts) + classify(entry.Info, opts))
+		}
+	}
+}
+
+// sortEntries sorts file entries according to options. With
+// --group-directories-first, directories and links to them come before
+// everything else, each group sorted on its own.
 func sortEntries(entries []FileEntry, opts *Options) {
-	sort.Slice(entries, func(i, j int) bool {
+	dirs := map[string]bool{}
+	if opts.GroupDirsFirst {
+		for i := range entries {
+			if entries[i].IsDir || isDirLink(&entries[i]) {
+				dirs[entries[i].Path] = true
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if a, b := dirs[entries[i].Path], dirs[entries[j].Path]; a != b {
+			return a
+		}
+
 		// Sort by time if requested
 		if opts.SortByTime {
 			if opts.Reverse {
@@ -188,22 +318,208 @@ func sortEntries(entries []FileEntry, opts *Options) {
 	})
 }
 
-// printLongFormat prints a file entry in long format
-func printLongFormat(entry *FileEntry, opts *Options) {
-	mode := entry.Info.Mode()
-	modTime := entry.ModTime.Format("Jan 02 15:04")
-	size := entry.Size
+// fileStat is what ls -l and -i show of a file beyond its fs.FileInfo
+type fileStat struct {
+	owner, group string
+	links        uint64
+	inode        uint64
+}
+
+// unknownStat stands in where the system has no owners or inodes
+var unknownStat = fileStat{owner: "-", group: "-", links: 1}
+
+// isDirLink reports whether entry is a symbolic link to a directory
+func isDirLink(entry *FileEntry) bool {
+	if entry.Info.Mode()&fs.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(entry.Path)
+	return err == nil && info.IsDir()
+}
+
+// printLongFormat prints file entries in long format, with the link
+// count, owner and group columns as wide as their widest value
+func printLongFormat(entries []FileEntry, opts *Options) {
+	stats := make([]fileStat, len(entries))
+	attrs := make([][]xattr, len(entries))
+	contexts := make([]string, len(entries))
+	inodes := inodeWidth(entries, opts)
+	var linkWidth, ownerWidth, groupWidth, contextWidth int
+	anyACL := false
+	for i := range entries {
+		stats[i] = statOf(entries[i].Info)
+		linkWidth = max(linkWidth, len(strconv.FormatUint(stats[i].links, 10)))
+		ownerWidth = max(ownerWidth, len(stats[i].owner))
+		groupWidth = max(groupWidth, len(stats[i].group))
+		attrs[i], _ = xattrsOf(entries[i].Path)
+		anyACL = anyACL || hasACL(attrs[i])
+		if opts.Context {
+			contexts[i] = securityContext(entries[i].Path) + " "
+			contextWidth = max(contextWidth, len(contexts[i]))
+		}
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		st := &stats[i]
+		mode := entry.Info.Mode()
+		modTime := entry.ModTime.Format("Jan 02 15:04")
+		size := entry.Size
+
+		// Format size
+		sizeStr := fmt.Sprintf("%8d", size)
+		if opts.Human {
+			sizeStr = formatHumanSize(size)
+		}
+
+		// Format permissions, marking files with an ACL as GNU ls does
+		perms := modeString(mode)
+		if hasACL(attrs[i]) {
+			perms += "+"
+		} else if anyACL {
+			perms += " "
+		}
+
+		name := gitPrefix(entry, opts) + paint(entry.Name, entry.Path, entry.Info, opts)
+		if mode&fs.ModeSymlink != 0 {
+			name += linkTarget(entry, opts)
+		} else {
+			name += classify(entry.Info, opts)
+		}
+
+		fmt.Printf("%s%s %*d %-*s %-*s %-*s%s %s %s\n", inodePrefix(entry.Info, inodes, opts), perms,
+			linkWidth, st.links, ownerWidth, st.owner, groupWidth, st.group, contextWidth, contexts[i], sizeStr, modTime, name)
+		if opts.Xattrs {
+			for _, a := range attrs[i] {
+				fmt.Printf("\t%s\t%4d\n", a.name, a.size)
+			}
+		}
+	}
+}
+
+// inodeWidth returns the width of the widest inode number of entries
+// when -i is given
+func inodeWidth(entries []FileEntry, opts *Options) int {
+	width := 0
+	if opts.Inode {
+		for i := range entries {
+			width = max(width, len(strconv.FormatUint(statOf(entries[i].Info).inode, 10)))
+		}
+	}
+	return width
+}
+
+// inodePrefix returns the inode number of a file and a space, right
+// aligned in width, when -i is given
+func inodePrefix(info fs.FileInfo, width int, opts *Options) string {
+	if !opts.Inode {
+		return ""
+	}
+	st := statOf(info)
+	inode := "?"
+	if st.inode != 0 {
+		inode = strconv.FormatUint(st.inode, 10)
+	}
+	return fmt.Sprintf("%*s ", width, inode)
+}
+
+// contextPrefix returns the security context of a file and a space, for
+// -Z outside long format
+func contextPrefix(entry *FileEntry, opts *Options) string {
+	if !opts.Context {
+		return ""
+	}
+	return securityContext(entry.Path) + " "
+}
+
+// classify returns the indicator -F appends to the name of a file
+func classify(info fs.FileInfo, opts *Options) string {
+	if !opts.Classify {
+		return ""
+	}
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		return "/"
+	case mode&fs.ModeSymlink != 0:
+		return "@"
+	case mode&fs.ModeNamedPipe != 0:
+		return "|"
+	case mode&fs.ModeSocket != 0:
+		return "="
+	case mode.IsRegular() && mode&0111 != 0:
+		return "*"
+	}
+	return ""
+}
+
+// linkTarget returns " -> target" for a symbolic link in long format,
+// with the target colored and classified like the file it leads to
+func linkTarget(entry *FileEntry, opts *Options) string {
+	target, err := os.Readlink(entry.Path)
+	if err != nil {
+		return ""
+	}
+	resolved, err := filepath.EvalSymlinks(entry.Path)
+	if err != nil {
+		return " -> " + target
+	}
+	info, err := os.Lstat(resolved)
+	if err != nil {
+		return " -> " + target
+	}
+	return " -> " + paint(target, resolved, info, opts) + classify(info, opts)
+}
 
-	// Format size
-	sizeStr := fmt.Sprintf("%8d", size)
-	if opts.Human {
-		sizeStr = formatHumanSize(size)
+// paint colors name, which shows the file at path, by its type and
+// suffix as LS_COLORS says
+func paint(name, path string, info fs.FileInfo, opts *Options) string {
+	if !opts.color {
+		return name
 	}
+	return color.Paint(true, name, color.LSColors().For(path, info))
+}
 
-	// Format permissions
-	perms := mode.String()
+// modeString formats mode as GNU ls does, as in "drwxr-xr-x": the type
+// letter, then the permissions with setuid, setgid and sticky shown as s
+// and t in the execute positions, upper case when that bit isn't set.
+// Go's FileMode.String puts these bits in front instead, as in "dtrwx...".
+func modeString(mode fs.FileMode) string {
+	b := []byte("-rwxrwxrwx")
+	switch {
+	case mode&fs.ModeDir != 0:
+		b[0] = 'd'
+	case mode&fs.ModeSymlink != 0:
+		b[0] = 'l'
+	case mode&fs.ModeNamedPipe != 0:
+		b[0] = 'p'
+	case mode&fs.ModeSocket != 0:
+		b[0] = 's'
+	case mode&fs.ModeCharDevice != 0:
+		b[0] = 'c'
+	case mode&fs.ModeDevice != 0:
+		b[0] = 'b'
+	}
+	for i := range 9 {
+		if mode&(1<<(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
 
-	fmt.Printf("%s %s %s %s\n", perms, sizeStr, modTime, entry.Name)
+	special := func(bit fs.FileMode, pos int, letter byte) {
+		if mode&bit == 0 {
+			return
+		}
+		if b[pos] == 'x' {
+			b[pos] = letter
+		} else {
+			b[pos] = letter - 'a' + 'A'
+		}
+	}
+	special(fs.ModeSetuid, 3, 's')
+	special(fs.ModeSetgid, 6, 's')
+	special(fs.ModeSticky, 9, 't')
+	return string(b)
 }
 
 // formatHumanSize formats size in human-readable format
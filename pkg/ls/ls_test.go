@@ -0,0 +1,196 @@
+package ls
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entries returns file entries with the given names for layout tests,
+// all described by the info of this file
+func entries(t *testing.T, names ...string) []FileEntry {
+	info, err := os.Stat("ls_test.go")
+	require.NoError(t, err)
+	list := make([]FileEntry, len(names))
+	for i, name := range names {
+		list[i] = FileEntry{Name: name, Info: info}
+	}
+	return list
+}
+
+// TestPrintGrid tests packing names into columns of a given width
+func TestPrintGrid(t *testing.T) {
+	names := entries(t, "a", "bb", "ccc", "dddd", "e")
+
+	tests := []struct {
+		name   string
+		layout layout
+		width  int
+		want   string
+	}{
+		{"down", columnsDown, 12, "a   ccc   e\nbb  dddd\n"},
+		{"down narrower", columnsDown, 9, "a    dddd\nbb   e\nccc\n"},
+		{"across", rowsAcross, 12, "a    bb\nccc  dddd\ne\n"},
+		{"one line", columnsDown, 80, "a  bb  ccc  dddd  e\n"},
+		{"too narrow", columnsDown, 2, "a\nbb\nccc\ndddd\ne\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printGrid(&buf, names, &Options{layout: tt.layout, width: tt.width})
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+// TestPrintGrid_WideNames tests that padding counts display columns
+func TestPrintGrid_WideNames(t *testing.T) {
+	var buf bytes.Buffer
+	printGrid(&buf, entries(t, "日本", "x", "y", "z"), &Options{layout: columnsDown, width: 8})
+	assert.Equal(t, "日本  y\nx     z\n", buf.String())
+}
+
+// TestResolveLayout tests how -1, -C and -x combine with the terminal
+func TestResolveLayout(t *testing.T) {
+	assert.Equal(t, singleColumn, resolveLayout(&Options{}, false))
+	assert.Equal(t, columnsDown, resolveLayout(&Options{}, true))
+	assert.Equal(t, columnsDown, resolveLayout(&Options{Columns: true}, false))
+	assert.Equal(t, rowsAcross, resolveLayout(&Options{Across: true, Columns: true}, true))
+	assert.Equal(t, singleColumn, resolveLayout(&Options{OneColumn: true, Across: true}, true))
+}
+
+// TestStatOf tests the owner, link count and inode shown by -l and -i
+func TestStatOf(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	st := statOf(info)
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, unknownStat, st)
+		return
+	}
+	current, err := user.Current()
+	require.NoError(t, err)
+	assert.Equal(t, current.Username, st.owner)
+	assert.Equal(t, uint64(1), st.links)
+	assert.NotZero(t, st.inode)
+
+	require.NoError(t, os.Link(path, filepath.Join(dir, "link")))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), statOf(info).links)
+}
+
+// TestModeString tests the GNU ls permission strings of -l
+func TestModeString(t *testing.T) {
+	assert.Equal(t, "-rw-r--r--", modeString(0644))
+	assert.Equal(t, "drwxr-xr-x", modeString(fs.ModeDir|0755))
+	assert.Equal(t, "lrwxrwxrwx", modeString(fs.ModeSymlink|0777))
+	assert.Equal(t, "drwxrwxrwt", modeString(fs.ModeDir|fs.ModeSticky|0777))
+	assert.Equal(t, "drwxrwxrwT", modeString(fs.ModeDir|fs.ModeSticky|0776))
+	assert.Equal(t, "-rwsr-Sr-x", modeString(fs.ModeSetuid|fs.ModeSetgid|0745))
+	assert.Equal(t, "crw-rw-rw-", modeString(fs.ModeDevice|fs.ModeCharDevice|0666))
+	assert.Equal(t, "brw-rw----", modeString(fs.ModeDevice|0660))
+	assert.Equal(t, "prw-r--r--", modeString(fs.ModeNamedPipe|0644))
+	assert.Equal(t, "srwxr-xr-x", modeString(fs.ModeSocket|0755))
+}
+
+// TestSortEntries_GroupDirectoriesFirst tests listing directories, and
+// links to them, ahead of files while keeping the chosen order in each
+func TestSortEntries_GroupDirectoriesFirst(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "src"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "docs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Makefile"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), nil, 0644))
+	links := os.Symlink("src", filepath.Join(dir, "lib")) == nil
+
+	var list []FileEntry
+	items, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, item := range items {
+		info, err := item.Info()
+		require.NoError(t, err)
+		list = append(list, FileEntry{Name: item.Name(), Info: info, Path: filepath.Join(dir, item.Name()), IsDir: item.IsDir()})
+	}
+	names := func() []string {
+		var names []string
+		for _, e := range list {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	sortEntries(list, &Options{GroupDirsFirst: true})
+	if links {
+		assert.Equal(t, []string{"docs", "lib", "src", "Makefile", "go.mod"}, names())
+	} else {
+		assert.Equal(t, []string{"docs", "src", "Makefile", "go.mod"}, names())
+	}
+
+	sortEntries(list, &Options{GroupDirsFirst: true, Reverse: true})
+	assert.Equal(t, "src", names()[0])
+	assert.Equal(t, "go.mod", names()[len(list)-2])
+}
+
+// TestGitStatus tests reading git status output and the status shown for
+// files and directories
+func TestGitStatus(t *testing.T) {
+	out := " M src/main.go\x00M  src/util.go\x00R  docs/new.md\x00docs/old.md\x00?? scratch/\x00!! build/\x00?? notes.txt\x00"
+	repo := &gitRepo{status: parsePorcelain([]byte(out))}
+	assert.Len(t, repo.status, 6)
+
+	assert.Equal(t, "-M", repo.statusOf("src/main.go", false))
+	assert.Equal(t, "M-", repo.statusOf("src/util.go", false))
+	assert.Equal(t, "--", repo.statusOf("src/lib.go", false))
+	assert.Equal(t, "MM", repo.statusOf("src", true))
+	assert.Equal(t, "R-", repo.statusOf("docs", true))
+	assert.Equal(t, "??", repo.statusOf("scratch", true))
+	assert.Equal(t, "??", repo.statusOf("scratch/a/b.txt", false))
+	assert.Equal(t, "!!", repo.statusOf("build/out.bin", false))
+	assert.Equal(t, "MM", repo.statusOf(".", true))
+	assert.Equal(t, "--", repo.statusOf("empty", true))
+}
+
+// TestClassify tests the -F indicators and how -l shows link targets
+func TestClassify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs executable bits and symbolic links")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), nil, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes"), nil, 0644))
+	require.NoError(t, os.Symlink("src", filepath.Join(dir, "code")))
+	require.NoError(t, os.Symlink("gone", filepath.Join(dir, "broken")))
+
+	opts := &Options{Classify: true}
+	classified := map[string]string{}
+	for _, name := range []string{"src", "run.sh", "notes", "code"} {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		classified[name] = classify(info, opts)
+	}
+	assert.Equal(t, map[string]string{"src": "/", "run.sh": "*", "notes": "", "code": "@"}, classified)
+
+	info, err := os.Lstat(filepath.Join(dir, "code"))
+	require.NoError(t, err)
+	assert.Equal(t, "", classify(info, &Options{}))
+
+	link := &FileEntry{Name: "code", Path: filepath.Join(dir, "code"), Info: info}
+	assert.Equal(t, " -> src/", linkTarget(link, opts))
+	assert.Equal(t, " -> src", linkTarget(link, &Options{}))
+	broken := &FileEntry{Name: "broken", Path: filepath.Join(dir, "broken"), Info: info}
+	assert.Equal(t, " -> gone", linkTarget(broken, opts))
+}
@@ -0,0 +1,210 @@
+package ls
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// hashes are the algorithms --hash accepts
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ManifestEntry describes one path below the root of a manifest
+type ManifestEntry struct {
+	Path   string    `json:"path"` // relative to the root, with / separators
+	Type   string    `json:"type"` // file, dir, symlink or other
+	Size   int64     `json:"size"`
+	Mode   string    `json:"mode"` // permission bits in octal
+	MTime  time.Time `json:"mtime"`
+	Target string    `json:"target,omitempty"` // of a symbolic link
+	Hash   string    `json:"hash,omitempty"`   // "algorithm:hex digest" of a file
+}
+
+// Manifest is the document --manifest writes in json format
+type Manifest struct {
+	Root    string          `json:"root"`
+	Hash    string          `json:"hash,omitempty"`
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// validateManifest checks the --manifest format and --hash algorithm
+func validateManifest(opts *Options) error {
+	if opts.Manifest != "json" && opts.Manifest != "jsonl" {
+		return fmt.Errorf("invalid manifest format %q: expected json or jsonl", opts.Manifest)
+	}
+	if _, ok := hashes[opts.Hash]; opts.Hash != "" && !ok {
+		return fmt.Errorf("unknown hash %q: expected md5, sha1, sha256 or sha512", opts.Hash)
+	}
+	return nil
+}
+
+// writeManifest walks root and writes its manifest, reporting whether any
+// path could not be read
+func writeManifest(ctx context.Context, root string, opts *Options) (bool, error) {
+	walker := &walk.Walker{}
+	var entries []ManifestEntry
+	var paths []string
+	failed := false
+
+	err := walker.Walk(ctx, root, func(path string, d fs.DirEntry, depth int) error {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if depth == 0 {
+			if d.IsDir() {
+				return nil
+			}
+			rel = filepath.Base(path)
+		}
+		if !opts.All && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			logging.PathError("Failed to get info for", path, err)
+			failed = true
+			return nil
+		}
+		entry := ManifestEntry{
+			Path:  filepath.ToSlash(rel),
+			Type:  entryType(info.Mode()),
+			Size:  info.Size(),
+			Mode:  fmt.Sprintf("%04o", info.Mode().Perm()),
+			MTime: info.ModTime().UTC(),
+		}
+		if entry.Type == "dir" {
+			entry.Size = 0
+		}
+		if entry.Type == "symlink" {
+			entry.Target, _ = os.Readlink(path)
+		}
+		entries = append(entries, entry)
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return failed, err
+	}
+	failed = failed || walker.Failed()
+
+	if opts.Hash != "" {
+		if hashFailed, err := hashEntries(ctx, entries, paths, opts.Hash); err != nil {
+			return failed, err
+		} else if hashFailed {
+			failed = true
+		}
+	}
+
+	if opts.Manifest == "jsonl" {
+		enc := json.NewEncoder(output.Stdout)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return failed, err
+			}
+		}
+		return failed, nil
+	}
+
+	if entries == nil {
+		entries = []ManifestEntry{}
+	}
+	data, err := json.MarshalIndent(Manifest{Root: root, Hash: opts.Hash, Entries: entries}, "", "  ")
+	if err != nil {
+		return failed, err
+	}
+	output.Stdout.Write(append(data, '\n'))
+	return failed, nil
+}
+
+// entryType names the type of a file for a manifest
+func entryType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "dir"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	}
+	return "other"
+}
+
+// hashEntries fills in the hashes of the regular files among entries,
+// reading several at once. Files that can't be read are reported and left
+// without a hash.
+func hashEntries(ctx context.Context, entries []ManifestEntry, paths []string, algorithm string) (bool, error) {
+	var next atomic.Int64
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Go(func() {
+			h := hashes[algorithm]()
+			buf := make([]byte, 256*1024)
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(entries) || ctx.Err() != nil {
+					return
+				}
+				if entries[i].Type != "file" {
+					continue
+				}
+				sum, err := hashFile(ctx, paths[i], h, buf)
+				if err != nil {
+					if !interrupt.Interrupted(err) {
+						logging.PathError("Failed to hash", paths[i], err)
+						failed.Store(true)
+					}
+					continue
+				}
+				entries[i].Hash = algorithm + ":" + sum
+			}
+		})
+	}
+	wg.Wait()
+	return failed.Load(), ctx.Err()
+}
+
+// hashFile returns the hex digest of the file at path
+func hashFile(ctx context.Context, path string, h hash.Hash, buf []byte) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h.Reset()
+	if _, err := io.CopyBuffer(h, interrupt.Reader(ctx, f), buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
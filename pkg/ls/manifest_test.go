@@ -0,0 +1,109 @@
+package ls
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/config"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// manifestOf runs writeManifest on root and returns what it printed
+func manifestOf(t *testing.T, root string, opts *Options) string {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	failed, err := writeManifest(context.Background(), root, opts)
+	require.NoError(t, err)
+	assert.False(t, failed)
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed)
+}
+
+// TestWriteManifest tests the entries of a manifest in both formats
+func TestWriteManifest(t *testing.T) {
+	root := t.TempDir()
+	mtime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub", "deeper"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "hello.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".hidden"), []byte("x"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "sub", "hello.txt"), mtime, mtime))
+
+	var doc Manifest
+	require.NoError(t, json.Unmarshal([]byte(manifestOf(t, root, &Options{Manifest: "json", Hash: "sha256"})), &doc))
+	assert.Equal(t, root, doc.Root)
+	assert.Equal(t, "sha256", doc.Hash)
+
+	var paths []string
+	for _, e := range doc.Entries {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"sub", "sub/deeper", "sub/hello.txt"}, paths)
+
+	file := doc.Entries[2]
+	assert.Equal(t, "file", file.Type)
+	assert.Equal(t, int64(5), file.Size)
+	assert.True(t, mtime.Equal(file.MTime))
+	assert.Equal(t, "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", file.Hash)
+	assert.Equal(t, "dir", doc.Entries[0].Type)
+	assert.Empty(t, doc.Entries[0].Hash)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, "0644", file.Mode)
+	}
+
+	// One entry per line, hidden names included with -a
+	scanner := bufio.NewScanner(strings.NewReader(manifestOf(t, root, &Options{Manifest: "jsonl", All: true})))
+	paths = nil
+	for scanner.Scan() {
+		var e ManifestEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		assert.Empty(t, e.Hash)
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{".hidden", "sub", "sub/deeper", "sub/hello.txt"}, paths)
+}
+
+// TestWriteManifest_ConfigIgnore tests that a manifest lists every file,
+// even names the configuration tells find and tree to skip
+func TestWriteManifest_ConfigIgnore(t *testing.T) {
+	defer func(saved []string) { config.IgnorePatterns = saved }(config.IgnorePatterns)
+	config.IgnorePatterns = []string{"node_modules", "*.log"}
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "node_modules"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "m.js"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "app.log"), nil, 0644))
+
+	var paths []string
+	scanner := bufio.NewScanner(strings.NewReader(manifestOf(t, root, &Options{Manifest: "jsonl"})))
+	for scanner.Scan() {
+		var e ManifestEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"app.log", "node_modules", "node_modules/m.js"}, paths)
+}
+
+// TestValidateManifest tests rejecting unknown formats and hashes
+func TestValidateManifest(t *testing.T) {
+	assert.NoError(t, validateManifest(&Options{Manifest: "jsonl", Hash: "md5"}))
+	assert.Error(t, validateManifest(&Options{Manifest: "xml"}))
+	assert.Error(t, validateManifest(&Options{Manifest: "json", Hash: "crc"}))
+}
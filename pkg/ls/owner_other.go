@@ -0,0 +1,11 @@
+//go:build !unix
+
+package ls
+
+import "io/fs"
+
+// statOf has no owners, link counts or inodes to report where files
+// don't carry Unix ids
+func statOf(info fs.FileInfo) fileStat {
+	return unknownStat
+}
@@ -0,0 +1,58 @@
+//go:build unix
+
+package ls
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// owners caches user and group names by id, as a listing mostly repeats
+// the same few
+var (
+	userNames  = map[uint32]string{}
+	groupNames = map[uint32]string{}
+)
+
+// statOf returns the owner, group, link count and inode of a file, with
+// ids that have no name shown as numbers
+func statOf(info fs.FileInfo) fileStat {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return unknownStat
+	}
+	return fileStat{
+		owner: lookupName(userNames, st.Uid, func(id string) (string, error) {
+			u, err := user.LookupId(id)
+			if err != nil {
+				return "", err
+			}
+			return u.Username, nil
+		}),
+		group: lookupName(groupNames, st.Gid, func(id string) (string, error) {
+			g, err := user.LookupGroupId(id)
+			if err != nil {
+				return "", err
+			}
+			return g.Name, nil
+		}),
+		links: uint64(st.Nlink),
+		inode: uint64(st.Ino),
+	}
+}
+
+// lookupName returns the cached name of id, looking it up the first time
+func lookupName(cache map[uint32]string, id uint32, lookup func(string) (string, error)) string {
+	if name, ok := cache[id]; ok {
+		return name
+	}
+	s := strconv.FormatUint(uint64(id), 10)
+	name, err := lookup(s)
+	if err != nil || name == "" {
+		name = s
+	}
+	cache[id] = name
+	return name
+}
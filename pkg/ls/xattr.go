@@ -0,0 +1,39 @@
+package ls
+
+import (
+	"slices"
+	"strings"
+)
+
+// xattr is an extended attribute of a file, shown by -l@
+type xattr struct {
+	name string
+	size int
+}
+
+// aclAttrs are the attributes in which Linux keeps POSIX ACLs
+var aclAttrs = []string{"system.posix_acl_access", "system.posix_acl_default"}
+
+// selinuxAttr holds a file's SELinux security context
+const selinuxAttr = "security.selinux"
+
+// hasACL reports whether attrs include an access control list, which -l
+// marks with a "+" after the permissions
+func hasACL(attrs []xattr) bool {
+	return slices.ContainsFunc(attrs, func(a xattr) bool {
+		return slices.Contains(aclAttrs, a.name)
+	})
+}
+
+// securityContext returns the SELinux context of the file at path for -Z,
+// or "?" when it has none, as GNU ls shows it
+func securityContext(path string) string {
+	value, err := getXattr(path, selinuxAttr)
+	if err != nil {
+		return "?"
+	}
+	if context := strings.TrimRight(string(value), "\x00"); context != "" {
+		return context
+	}
+	return "?"
+}
@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd)
+
+package ls
+
+import "errors"
+
+// errNoXattrs is returned where the system has no extended attributes
+var errNoXattrs = errors.New("extended attributes are not supported on this system")
+
+// xattrsOf finds no extended attributes where the system has none
+func xattrsOf(path string) ([]xattr, error) {
+	return nil, nil
+}
+
+// getXattr fails where the system has no extended attributes
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errNoXattrs
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package ls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// TestXattrsOf tests listing the extended attributes -l@ shows, and the
+// ACL marker and context that come from them
+func TestXattrsOf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	if err := unix.Setxattr(path, "user.origin", []byte("https://example.com"), 0); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+
+	attrs, err := xattrsOf(path)
+	require.NoError(t, err)
+	assert.Contains(t, attrs, xattr{name: "user.origin", size: 19})
+
+	assert.False(t, hasACL(attrs))
+	assert.True(t, hasACL([]xattr{{name: "system.posix_acl_access", size: 28}}))
+
+	if _, err := getXattr(path, selinuxAttr); err != nil {
+		assert.Equal(t, "?", securityContext(path))
+	}
+}
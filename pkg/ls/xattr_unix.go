@@ -0,0 +1,50 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package ls
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// xattrsOf returns the extended attributes of the file at path, without
+// following a symbolic link, in the order the system lists them
+func xattrsOf(path string) ([]xattr, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []xattr
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n, err := unix.Lgetxattr(path, string(name), nil)
+		if err != nil {
+			continue
+		}
+		attrs = append(attrs, xattr{name: string(name), size: n})
+	}
+	return attrs, nil
+}
+
+// getXattr returns the value of the attribute name of the file at path
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
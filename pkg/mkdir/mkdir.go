@@ -1,19 +1,37 @@
 package mkdir
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
 )
 
 // Options holds mkdir configuration
 type Options struct {
-	Parents bool
-	Mode    os.FileMode
-	Verbose bool
+	Parents     bool
+	Mode        os.FileMode
+	ParentsMode os.FileMode // mode of created parents; 0 means Mode
+	Verbose     bool
+	Format      string // "text" or "json"
+
+	exact   bool      // -m was given, so Mode is applied regardless of the umask
+	created []created // directories made so far, outermost first
+}
+
+// created is a directory mkdir made, as listed by --format json
+type created struct {
+	Path   string `json:"path"`
+	Mode   string `json:"mode"`
+	Parent bool   `json:"parent"` // made as a parent of a named directory
 }
 
 // Command returns the mkdir command
@@ -28,17 +46,34 @@ func Command() *cobra.Command {
 		Long: `Create the DIRECTORY(ies), if they do not already exist.
 
 Creates directories with the specified names. By default, intermediate
-directories must already exist. Use -p to create parent directories as needed.`,
+directories must already exist. Use -p to create parent directories as needed.
+
+-m sets the mode of the named directories and --parents-mode that of the
+parents -p creates; either is applied exactly, whatever the umask, so that
+builds get the same permissions everywhere. Without them the umask applies.
+
+--format json prints a JSON array of the directories created, parents
+included, outermost first, with the path, the resulting mode and whether
+each was made as a parent. It is printed also when an error stops mkdir,
+listing what was created until then.`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Format != "text" && opts.Format != "json" {
+				return exitcode.New(2, fmt.Errorf("invalid format %q: expected text or json", opts.Format))
+			}
+			opts.exact = cmd.Flags().Changed("mode")
+			if opts.Format == "json" {
+				defer writeJSON(opts)
+			}
+
 			for _, dir := range args {
-				if err := createDirectory(dir, opts); err != nil {
-					eve.Logger.Error("Failed to create directory", dir, ":", err)
+				if err := cmd.Context().Err(); err != nil {
 					return err
 				}
 
-				if opts.Verbose {
-					fmt.Printf("created directory '%s'\n", dir)
+				if err := createDirectory(dir, opts); err != nil {
+					logging.PathError("Failed to create directory", dir, err)
+					return err
 				}
 			}
 
@@ -48,11 +83,59 @@ directories must already exist. Use -p to create parent directories as needed.`,
 
 	cmd.Flags().BoolVarP(&opts.Parents, "parents", "p", false, "Create parent directories as needed, no error if existing")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Print a message for each created directory")
-	cmd.Flags().Uint32VarP((*uint32)(&opts.Mode), "mode", "m", 0755, "Set file mode (as in chmod), default 0755")
+	cmd.Flags().VarP(&modeFlag{&opts.Mode}, "mode", "m", "Set the file `MODE`, in octal as chmod takes it")
+	cmd.Flags().Var(&modeFlag{&opts.ParentsMode}, "parents-mode", "Set the `MODE`, in octal, of parent directories created by -p (default: as -m)")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "Output format: text or json (list the directories created)")
 
 	return cmd
 }
 
+// modeFlag is a file mode given in octal, as chmod takes it. The setuid,
+// setgid and sticky bits (04000, 02000, 01000) become the os.FileMode
+// flags for them.
+type modeFlag struct {
+	mode *os.FileMode
+}
+
+// specialBits maps the octal special bits to their os.FileMode flags
+var specialBits = []struct {
+	octal uint64
+	mode  os.FileMode
+}{
+	{04000, os.ModeSetuid},
+	{02000, os.ModeSetgid},
+	{01000, os.ModeSticky},
+}
+
+func (f *modeFlag) String() string {
+	octal := uint64(f.mode.Perm())
+	for _, b := range specialBits {
+		if *f.mode&b.mode != 0 {
+			octal |= b.octal
+		}
+	}
+	return fmt.Sprintf("%#o", octal)
+}
+
+func (f *modeFlag) Set(value string) error {
+	octal, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || octal > 07777 {
+		return fmt.Errorf("invalid mode %q", value)
+	}
+	mode := os.FileMode(octal).Perm()
+	for _, b := range specialBits {
+		if octal&b.octal != 0 {
+			mode |= b.mode
+		}
+	}
+	*f.mode = mode
+	return nil
+}
+
+func (f *modeFlag) Type() string {
+	return "mode"
+}
+
 // createDirectory creates a directory with the specified options
 func createDirectory(path string, opts *Options) error {
 	// Clean the path to normalize it
@@ -79,18 +162,98 @@ func createDirectory(path string, opts *Options) error {
 		return fmt.Errorf("failed to stat '%s': %w", path, err)
 	}
 
-	// Create the directory
+	if dryrun.Enabled {
+		return reportMissing(path, opts)
+	}
+
+	// With -p, create the missing parents first, outermost first
 	if opts.Parents {
-		// MkdirAll creates parent directories as needed
-		if err := os.MkdirAll(path, opts.Mode); err != nil {
-			return fmt.Errorf("failed to create directory '%s': %w", path, err)
+		for _, dir := range missingParents(path) {
+			mode, exact := opts.Mode, opts.exact
+			if opts.ParentsMode != 0 {
+				mode, exact = opts.ParentsMode, true
+			}
+			if err := mkdir(dir, mode, exact, true, opts); err != nil {
+				// Someone else may have created it meanwhile
+				if info, statErr := os.Stat(dir); statErr == nil && info.IsDir() {
+					continue
+				}
+				return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+			}
 		}
-	} else {
-		// Mkdir only creates the final directory
-		if err := os.Mkdir(path, opts.Mode); err != nil {
-			return fmt.Errorf("failed to create directory '%s': %w", path, err)
+	}
+
+	if err := mkdir(path, opts.Mode, opts.exact, false, opts); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// mkdir creates dir with mode, applied as is rather than through the
+// umask if exact, and records it as created
+func mkdir(dir string, mode os.FileMode, exact, parent bool, opts *Options) error {
+	if err := os.Mkdir(dir, mode); err != nil {
+		return err
+	}
+	if exact {
+		if err := os.Chmod(dir, mode); err != nil {
+			return err
 		}
 	}
 
+	if opts.Verbose {
+		fmt.Printf("created directory '%s'\n", dir)
+	}
+	if opts.Format == "json" {
+		c := created{Path: dir, Mode: fmt.Sprintf("%04o", mode.Perm()), Parent: parent}
+		if info, err := os.Stat(dir); err == nil {
+			c.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+		}
+		opts.created = append(opts.created, c)
+	}
+	return nil
+}
+
+// missingParents returns the ancestors of path that don't exist yet,
+// outermost first
+func missingParents(path string) []string {
+	var missing []string
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+	}
+	slices.Reverse(missing)
+	return missing
+}
+
+// writeJSON prints the directories created as a JSON array
+func writeJSON(opts *Options) {
+	list := opts.created
+	if list == nil {
+		list = []created{}
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// reportMissing reports the directories that creating path would make:
+// with -p every missing ancestor, outermost first
+func reportMissing(path string, opts *Options) error {
+	var missing []string
+	if opts.Parents {
+		missing = missingParents(path)
+	} else if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("cannot create directory '%s': %w", path, err)
+	}
+
+	for _, dir := range append(missing, path) {
+		dryrun.Report("create directory '%s'", dir)
+	}
 	return nil
 }
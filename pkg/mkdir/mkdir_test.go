@@ -1,8 +1,11 @@
 package mkdir
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -287,3 +290,63 @@ func BenchmarkCreateDirectory_WithParents(b *testing.B) {
 		_ = createDirectory(path, opts)
 	}
 }
+
+// TestCreateDirectory_ParentsMode tests giving created parents their own
+// mode, applied regardless of the umask, and recording what was created
+func TestCreateDirectory_ParentsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory modes are not supported on Windows")
+	}
+	tempDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "a"), 0755))
+
+	path := filepath.Join(tempDir, "a", "b", "c", "d")
+	opts := &Options{Parents: true, Mode: 0777, ParentsMode: 0750, Format: "json", exact: true}
+	require.NoError(t, createDirectory(path, opts))
+
+	want := []created{
+		{Path: filepath.Join(tempDir, "a", "b"), Mode: "0750", Parent: true},
+		{Path: filepath.Join(tempDir, "a", "b", "c"), Mode: "0750", Parent: true},
+		{Path: path, Mode: "0777"},
+	}
+	assert.Equal(t, want, opts.created)
+
+	for _, c := range want {
+		info, err := os.Stat(c.Path)
+		require.NoError(t, err)
+		assert.Equal(t, c.Mode, fmt.Sprintf("%04o", info.Mode().Perm()), c.Path)
+	}
+}
+
+// TestCommand_OctalModes tests that -m and --parents-mode are read as
+// octal, as chmod reads them, and applied as given
+func TestCommand_OctalModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory modes are not supported on Windows")
+	}
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "a", "b")
+
+	cmd := Command()
+	cmd.SetArgs([]string{"-p", "-m", "750", "--parents-mode", "700", path})
+	require.NoError(t, cmd.Execute())
+
+	for dir, want := range map[string]os.FileMode{filepath.Join(tempDir, "a"): 0700, path: 0750} {
+		info, err := os.Stat(dir)
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Mode().Perm(), dir)
+	}
+
+	cmd = Command()
+	cmd.SetArgs([]string{"-m", "1777", filepath.Join(tempDir, "tmp")})
+	require.NoError(t, cmd.Execute())
+	info, err := os.Stat(filepath.Join(tempDir, "tmp"))
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSticky|0777, info.Mode()&(os.ModeSticky|os.ModePerm))
+
+	cmd = Command()
+	cmd.SetArgs([]string{"-m", "789", filepath.Join(tempDir, "bad")})
+	cmd.SetErr(io.Discard)
+	assert.Error(t, cmd.Execute())
+	assert.NoDirExists(t, filepath.Join(tempDir, "bad"))
+}
@@ -0,0 +1,78 @@
+package mmap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// MinSize is the smallest file worth mapping. Smaller files are read
+// faster than they are mapped and unmapped.
+const MinSize = 256 * 1024
+
+// ErrChanged is returned by Guard when the mapped file shrank while it was
+// being read
+var ErrChanged = errors.New("file changed while being read")
+
+// Mapping is a read-only memory mapping of a whole file
+type Mapping struct {
+	data  []byte
+	unmap func() error
+}
+
+// Map maps the regular file f into memory if it is at least MinSize bytes
+// long. It fails for smaller and special files and wherever the platform or
+// file system doesn't support mapping, in which case callers read f as
+// usual.
+func Map(f *os.File) (*Mapping, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() < MinSize {
+		return nil, errors.ErrUnsupported
+	}
+	if int64(int(info.Size())) != info.Size() {
+		return nil, fmt.Errorf("file too large to map: %d bytes", info.Size())
+	}
+
+	data, unmap, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &Mapping{data: data, unmap: unmap}, nil
+}
+
+// Bytes returns the mapped contents, which must not be modified or used
+// after Close
+func (m *Mapping) Bytes() []byte {
+	return m.data
+}
+
+// Close removes the mapping
+func (m *Mapping) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	m.data = nil
+	return m.unmap()
+}
+
+// Guard runs fn, which reads mapped memory, and returns ErrChanged instead
+// of crashing the process if the file is truncated meanwhile and the memory
+// past its new end can no longer be read
+func Guard(fn func() error) (err error) {
+	old := debug.SetPanicOnFault(true)
+	defer func() {
+		debug.SetPanicOnFault(old)
+		if r := recover(); r != nil {
+			if _, fault := r.(interface{ Addr() uintptr }); fault {
+				err = ErrChanged
+				return
+			}
+			panic(r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package mmap
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile always fails: files are read instead
+func mapFile(f *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -0,0 +1,77 @@
+package mmap
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile creates a file of size bytes in a temporary directory
+func writeFile(t *testing.T, size int) (string, []byte) {
+	data := bytes.Repeat([]byte("0123456789abcde\n"), size/16+1)[:size]
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path, data
+}
+
+// TestMap tests that large files are mapped and small ones left to be read
+func TestMap(t *testing.T) {
+	path, data := writeFile(t, MinSize+100)
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	m, err := Map(f)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("mapping is not supported here")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, data, m.Bytes())
+	require.NoError(t, m.Close())
+	assert.NoError(t, m.Close(), "closing twice is harmless")
+
+	path, _ = writeFile(t, 100)
+	small, err := os.Open(path)
+	require.NoError(t, err)
+	defer small.Close()
+	_, err = Map(small)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+// TestGuard tests that reading past the end of a truncated file is an error
+func TestGuard(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mapped files cannot be truncated on Windows")
+	}
+	path, _ := writeFile(t, 4*MinSize)
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	m, err := Map(f)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("mapping is not supported here")
+	}
+	require.NoError(t, err)
+	defer m.Close()
+
+	require.NoError(t, os.Truncate(path, 0))
+	var last byte
+	err = Guard(func() error {
+		data := m.Bytes()
+		last = data[len(data)-1]
+		return nil
+	})
+	assert.Zero(t, last)
+	assert.ErrorIs(t, err, ErrChanged)
+
+	assert.Panics(t, func() {
+		_ = Guard(func() error { panic("other") })
+	}, "other panics are not swallowed")
+}
@@ -1,13 +1,21 @@
 package mv
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/filecopy"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+	"github.com/evalgo-org/claude-tools/pkg/report"
 )
 
 // Options holds mv configuration
@@ -16,6 +24,9 @@ type Options struct {
 	NoClobber   bool
 	Verbose     bool
 	Interactive bool
+	Report      string // "json" to print a record of each file moved
+
+	report *report.Writer
 }
 
 // Command returns the mv command
@@ -29,25 +40,37 @@ func Command() *cobra.Command {
 
 If the last argument names an existing directory, mv moves each source
 into that directory. Otherwise, if only two files are given, it renames
-the first to the second.`,
+the first to the second.
+
+--report json prints a JSON object per line for each source, with the
+action ("move", or "skip" for one -n left alone), source, destination,
+bytes moved, duration in seconds and any error, in place of -v output.`,
 		Args: cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			args = glob.Expand(args)
 			sources := args[:len(args)-1]
 			dest := args[len(args)-1]
 
-			return moveFiles(sources, dest, opts)
+			rep, err := report.New(opts.Report)
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+			opts.report = rep
+
+			return moveFiles(cmd.Context(), sources, dest, opts)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Overwrite existing files without prompting")
 	cmd.Flags().BoolVarP(&opts.NoClobber, "no-clobber", "n", false, "Do not overwrite existing files")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Explain what is being done")
+	cmd.Flags().StringVar(&opts.Report, "report", "", "Print a record of each file moved in `FORMAT` (json)")
 
 	return cmd
 }
 
 // moveFiles moves source files to destination
-func moveFiles(sources []string, dest string, opts *Options) error {
+func moveFiles(ctx context.Context, sources []string, dest string, opts *Options) error {
 	// Check if -f and -n are both set
 	if opts.Force && opts.NoClobber {
 		return fmt.Errorf("cannot specify both -f and -n")
@@ -62,13 +85,17 @@ func moveFiles(sources []string, dest string, opts *Options) error {
 		return fmt.Errorf("target '%s' is not a directory", dest)
 	}
 
+	var bar *progress.Bar
+	if progress.Shown(opts.Verbose) {
+		bar = progress.New("mv", progress.Items, int64(len(sources)))
+		defer bar.Finish()
+	}
+
 	for _, src := range sources {
-		// Check if source exists
-		srcInfo, err := os.Stat(src)
-		if err != nil {
-			eve.Logger.Error("Failed to stat", src, ":", err)
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		bar.Describe(src)
 
 		var targetPath string
 		if isDestDir {
@@ -76,47 +103,86 @@ func moveFiles(sources []string, dest string, opts *Options) error {
 		} else {
 			targetPath = dest
 		}
+		op := opts.report.Start("move", src, targetPath)
+
+		// Check if source exists
+		srcInfo, err := os.Stat(src)
+		if err != nil {
+			op.Done(0, err)
+			return err
+		}
+		var size int64
+		if srcInfo.Mode().IsRegular() {
+			size = srcInfo.Size()
+		}
 
 		// Check if destination exists
 		if _, err := os.Stat(targetPath); err == nil {
 			if opts.NoClobber {
+				opts.report.Start("skip", src, targetPath).Done(0, nil)
 				if opts.Verbose {
-					eve.Logger.Info("Skipping", src, "(destination exists)")
+					logging.Info("Skipping", src, "(destination exists)")
 				}
 				continue
 			}
 			if !opts.Force {
-				return fmt.Errorf("'%s' already exists (use -f to overwrite)", targetPath)
+				err := fmt.Errorf("'%s' already exists (use -f to overwrite)", targetPath)
+				op.Done(0, err)
+				return err
 			}
 		}
 
-		// Attempt to move using os.Rename (fast for same filesystem)
-		err = os.Rename(src, targetPath)
-		if err != nil {
-			// If rename fails (likely cross-filesystem), fall back to copy+delete
-			if linkErr, ok := err.(*os.LinkError); ok {
-				eve.Logger.Debug("Rename failed, using copy+delete:", linkErr)
-				if err := copyAndDelete(src, targetPath, srcInfo); err != nil {
-					return err
-				}
-			} else {
-				return fmt.Errorf("failed to move '%s' to '%s': %w", src, targetPath, err)
-			}
+		if dryrun.Enabled {
+			dryrun.Report("move '%s' to '%s'", src, targetPath)
+			op.Done(size, nil)
+			continue
 		}
 
-		if opts.Verbose {
+		if err := move(ctx, src, targetPath, srcInfo); err != nil {
+			op.Done(0, err)
+			return err
+		}
+		op.Done(size, nil)
+
+		if opts.Verbose && !opts.report.Enabled() {
 			fmt.Printf("'%s' -> '%s'\n", src, targetPath)
 		}
+		bar.Add(1)
 	}
 
 	return nil
 }
 
+// move renames src to dest, or copies and then deletes it where a rename
+// is not possible, as across file systems
+func move(ctx context.Context, src, dest string, srcInfo os.FileInfo) error {
+	err := os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+	if linkErr, ok := err.(*os.LinkError); ok {
+		logging.Debug("Rename failed, using copy+delete:", linkErr)
+		return copyAndDelete(ctx, src, dest, srcInfo)
+	}
+	return fmt.Errorf("failed to move '%s' to '%s': %w", src, dest, err)
+}
+
 // copyAndDelete copies a file/directory and then deletes the source
-func copyAndDelete(src, dest string, srcInfo os.FileInfo) error {
+func copyAndDelete(ctx context.Context, src, dest string, srcInfo os.FileInfo) error {
 	if srcInfo.IsDir() {
-		// Recursively copy directory
-		if err := copyDir(src, dest, srcInfo); err != nil {
+		_, statErr := os.Lstat(dest)
+		created := os.IsNotExist(statErr)
+
+		// Recursively copy directory; the source is untouched until the
+		// copy completes, so an interrupted copy only needs its partial
+		// destination removed
+		if err := copyDir(ctx, src, dest, srcInfo); err != nil {
+			if interrupt.Interrupted(err) {
+				if created {
+					os.RemoveAll(dest)
+				}
+				return err
+			}
 			return fmt.Errorf("failed to copy directory: %w", err)
 		}
 		// Remove source directory
@@ -125,7 +191,10 @@ func copyAndDelete(src, dest string, srcInfo os.FileInfo) error {
 		}
 	} else {
 		// Copy file
-		if err := copyFile(src, dest, srcInfo); err != nil {
+		if err := copyFile(ctx, src, dest, srcInfo); err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
 			return fmt.Errorf("failed to copy file: %w", err)
 		}
 		// Remove source file
@@ -137,7 +206,7 @@ func copyAndDelete(src, dest string, srcInfo os.FileInfo) error {
 }
 
 // copyFile copies a single file with permissions
-func copyFile(src, dest string, srcInfo os.FileInfo) error {
+func copyFile(ctx context.Context, src, dest string, srcInfo os.FileInfo) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open source: %w", err)
@@ -150,7 +219,16 @@ func copyFile(src, dest string, srcInfo os.FileInfo) error {
 	}
 	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, srcFile); err != nil {
+	var size int64
+	if srcInfo.Mode().IsRegular() {
+		size = srcInfo.Size()
+	}
+	if _, err := filecopy.Copy(destFile, interrupt.Reader(ctx, srcFile), size, false); err != nil {
+		destFile.Close()
+		os.Remove(dest)
+		if interrupt.Interrupted(err) {
+			return err
+		}
 		return fmt.Errorf("failed to copy contents: %w", err)
 	}
 
@@ -163,7 +241,7 @@ func copyFile(src, dest string, srcInfo os.FileInfo) error {
 }
 
 // copyDir recursively copies a directory
-func copyDir(src, dest string, srcInfo os.FileInfo) error {
+func copyDir(ctx context.Context, src, dest string, srcInfo os.FileInfo) error {
 	// Create destination directory
 	if err := os.MkdirAll(dest, srcInfo.Mode()); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
@@ -177,6 +255,10 @@ func copyDir(src, dest string, srcInfo os.FileInfo) error {
 
 	// Copy each entry
 	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		srcPath := filepath.Join(src, entry.Name())
 		destPath := filepath.Join(dest, entry.Name())
 
@@ -186,11 +268,11 @@ func copyDir(src, dest string, srcInfo os.FileInfo) error {
 		}
 
 		if entry.IsDir() {
-			if err := copyDir(srcPath, destPath, info); err != nil {
+			if err := copyDir(ctx, srcPath, destPath, info); err != nil {
 				return err
 			}
 		} else {
-			if err := copyFile(srcPath, destPath, info); err != nil {
+			if err := copyFile(ctx, srcPath, destPath, info); err != nil {
 				return err
 			}
 		}
@@ -1,6 +1,7 @@
 package mv
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -26,7 +27,7 @@ func TestMoveFiles_SimpleRename(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcFile}, destFile, opts)
+	err = moveFiles(context.Background(), []string{srcFile}, destFile, opts)
 	require.NoError(t, err)
 
 	// Verify source was removed
@@ -60,7 +61,7 @@ func TestMoveFiles_ToDirectory(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{src1, src2}, destDir, opts)
+	err = moveFiles(context.Background(), []string{src1, src2}, destDir, opts)
 	require.NoError(t, err)
 
 	// Verify sources were removed
@@ -100,7 +101,7 @@ func TestMoveFiles_ExistingFile_WithoutForce(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcFile}, destFile, opts)
+	err = moveFiles(context.Background(), []string{srcFile}, destFile, opts)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "already exists")
 
@@ -128,7 +129,7 @@ func TestMoveFiles_ExistingFile_WithForce(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcFile}, destFile, opts)
+	err = moveFiles(context.Background(), []string{srcFile}, destFile, opts)
 	require.NoError(t, err)
 
 	// Verify source was removed
@@ -162,7 +163,7 @@ func TestMoveFiles_NoClobber(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcFile}, destFile, opts)
+	err = moveFiles(context.Background(), []string{srcFile}, destFile, opts)
 	require.NoError(t, err) // -n should not error, just skip
 
 	// Verify source still exists
@@ -191,7 +192,7 @@ func TestMoveFiles_ForceAndNoClobber(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcFile}, destFile, opts)
+	err = moveFiles(context.Background(), []string{srcFile}, destFile, opts)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cannot specify both")
 }
@@ -217,7 +218,7 @@ func TestMoveFiles_Directory(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcDir}, destDir, opts)
+	err = moveFiles(context.Background(), []string{srcDir}, destDir, opts)
 	require.NoError(t, err)
 
 	// Verify source directory was removed
@@ -252,7 +253,7 @@ func TestMoveFiles_MultipleToNonDirectory(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{src1, src2}, destFile, opts)
+	err = moveFiles(context.Background(), []string{src1, src2}, destFile, opts)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not a directory")
 }
@@ -277,7 +278,7 @@ func TestMoveFiles_PreservesPermissions(t *testing.T) {
 		Verbose:   false,
 	}
 
-	err = moveFiles([]string{srcFile}, destFile, opts)
+	err = moveFiles(context.Background(), []string{srcFile}, destFile, opts)
 	require.NoError(t, err)
 
 	// Verify permissions were preserved
@@ -301,7 +302,7 @@ func TestCopyAndDelete_CrossFilesystem(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test copyAndDelete directly
-	err = copyAndDelete(srcFile, destFile, srcInfo)
+	err = copyAndDelete(context.Background(), srcFile, destFile, srcInfo)
 	require.NoError(t, err)
 
 	// Verify source was removed
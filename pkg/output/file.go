@@ -0,0 +1,63 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces the file name with what write produces. The output
+// goes to a temporary file next to it that is given mode perm and renamed
+// over name, so the file is either replaced as a whole or left as it was,
+// even if writing fails or ctx is canceled on the way.
+func WriteFile(ctx context.Context, name string, perm fs.FileMode, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), TempPrefix(name)+"*")
+	if err != nil {
+		return fmt.Errorf("cannot write '%s': %w", name, err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	defer tmp.Close()
+
+	w := bufio.NewWriterSize(tmp, bufferSize)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot write '%s': %w", name, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("cannot set mode on '%s': %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write '%s': %w", name, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		return fmt.Errorf("cannot replace '%s': %w", name, err)
+	}
+	return nil
+}
+
+// TempPrefix returns what the name of the temporary file WriteFile writes
+// name through starts with
+func TempPrefix(name string) string {
+	return "." + filepath.Base(name) + ".tmp-"
+}
+
+// ReplaceFile replaces the file name with data as WriteFile does
+func ReplaceFile(ctx context.Context, name string, perm fs.FileMode, data []byte) error {
+	return WriteFile(ctx, name, perm, func(w io.Writer) error {
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("cannot write '%s': %w", name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"sync"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+)
+
+// bufferSize is the amount of output collected before it is written
+const bufferSize = 64 * 1024
+
+// Stdout buffers standard output so that commands printing many short
+// lines don't make a system call for each one. main flushes it before the
+// process exits, including after an interrupt. When standard output is a
+// terminal it is flushed at the end of every line instead, so output shows
+// up as it is produced, in order with messages on standard error.
+var Stdout = &Writer{}
+
+// Writer is a buffered writer to whatever os.Stdout is when the first byte
+// is written. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	once sync.Once
+	buf  *bufio.Writer
+	line bool // flush at every newline
+}
+
+// stdout writes to the current os.Stdout, which tests may replace
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// init sets up the buffer for the current standard output
+func (w *Writer) init() {
+	w.once.Do(func() {
+		w.buf = bufio.NewWriterSize(stdout{}, bufferSize)
+		w.line = color.Interactive(os.Stdout)
+	})
+}
+
+// Write writes p to the buffer
+func (w *Writer) Write(p []byte) (int, error) {
+	w.init()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.buf.Write(p)
+	if err == nil && w.line && bytes.IndexByte(p, '\n') >= 0 {
+		err = w.buf.Flush()
+	}
+	return n, err
+}
+
+// WriteString writes s to the buffer
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush writes out the buffered output
+func (w *Writer) Flush() error {
+	w.init()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Flush()
+}
+
+// Flush writes out everything buffered in Stdout
+func Flush() error {
+	return Stdout.Flush()
+}
@@ -0,0 +1,74 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriter_Flush tests that output is held back until flushed, and goes
+// to the standard output in place at the first write
+func TestWriter_Flush(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	w := &Writer{}
+	_, err = w.WriteString("one\n")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("two\n"))
+	require.NoError(t, err)
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	assert.Empty(t, printed, "a file is not line buffered")
+
+	require.NoError(t, w.Flush())
+	printed, err = os.ReadFile(out.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(printed))
+}
+
+// TestWriteFile tests that a file is replaced with the given mode, and left
+// as it was when writing fails
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(name, []byte("old\n"), 0644))
+
+	require.NoError(t, ReplaceFile(context.Background(), name, 0600, []byte("new\n")))
+	content, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+	info, err := os.Stat(name)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	failure := errors.New("failed")
+	err = WriteFile(context.Background(), name, 0600, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	content, err = os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
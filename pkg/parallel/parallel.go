@@ -0,0 +1,309 @@
+package parallel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// Exit statuses of jobs that could not be run, as shells report them
+const (
+	statusFailed   = 126
+	statusNotFound = 127
+)
+
+// maxStatus is the highest exit status counting failed jobs, as in GNU
+// parallel
+const maxStatus = 101
+
+// Options holds parallel configuration
+type Options struct {
+	Jobs        int  // jobs running at once; 0 is the number of CPUs
+	HaltOnError bool // start no more jobs once one fails
+	Tag         bool // prefix output lines with the job's argument
+	KeepOrder   bool // print output in input order rather than as jobs end
+	JSON        bool // print a JSON object per job instead of its output
+	Null        bool // arguments end in NUL rather than newline
+}
+
+// Result is what a job did, printed as a line of JSON by --json
+type Result struct {
+	Seq            int      `json:"seq"`
+	Arg            string   `json:"arg"`
+	Command        []string `json:"command"`
+	Exit           int      `json:"exit"`
+	Stdout         string   `json:"stdout"`
+	Stderr         string   `json:"stderr"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+
+	skipped bool // not run, because an earlier job failed
+}
+
+// Command returns the parallel command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "parallel [flags] command [args...]",
+		Short: "Run a command for each line of input, several at once",
+		Long: `Run a command once for each line of standard input, several at a time,
+like a small GNU parallel.
+
+The command is a template: {} in its arguments is replaced by the input
+line, {.} by the line without its extension, {/} by its base name, {//} by
+its directory, {/.} by the base name without extension and {#} by the
+job's number, counting from 1. Without any of them, the line is added as
+the last argument. The command is run directly, not by a shell; use
+sh -c 'script' _ {} for pipes and redirections.
+
+Each job's output is collected and printed in one piece when the job
+ends, so that the output of jobs doesn't interleave: standard output to
+standard output and standard error to standard error. -k prints it in the
+order of the input lines instead of the order the jobs end in, and --tag
+starts every line with the input line and a tab.
+
+--json prints one JSON object per job instead of its output, with the
+job's number, input line, command, exit status, output and run time.
+
+--halt-on-error starts no more jobs once one fails, and lets those
+running finish. The exit status is the number of jobs that failed, at
+most 101, so 0 when all succeeded.
+
+Examples:
+  find . -name '*.log' | parallel -j 4 gzip
+  cat hosts.txt | parallel --tag ssh {} uptime
+  ls *.png | parallel convert {} {.}.jpg
+  find . --print0 -name '*.go' | parallel -0 --json gofmt -l`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Jobs < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid -j value %d", opts.Jobs))
+			}
+			if opts.Jobs == 0 {
+				opts.Jobs = runtime.NumCPU()
+			}
+			cmd.SilenceErrors = true
+
+			failed, err := run(cmd.Context(), args, os.Stdin, output.Stdout, os.Stderr, opts)
+			if err != nil {
+				if !interrupt.Interrupted(err) {
+					logging.Error(err)
+				}
+				return err
+			}
+			if failed > 0 {
+				return exitcode.Status(min(failed, maxStatus))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Run `N` jobs at once (default: number of CPUs)")
+	cmd.Flags().BoolVar(&opts.HaltOnError, "halt-on-error", false, "Start no more jobs once one fails")
+	cmd.Flags().BoolVar(&opts.Tag, "tag", false, "Prefix each output line with the job's input line and a tab")
+	cmd.Flags().BoolVarP(&opts.KeepOrder, "keep-order", "k", false, "Print output in the order of the input")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "Print a JSON object with the result of each job")
+	cmd.Flags().BoolVarP(&opts.Null, "null", "0", false, "Input lines end in NUL instead of newline")
+
+	return cmd
+}
+
+// job is an input line to run the template for
+type job struct {
+	seq int
+	arg string
+}
+
+// run runs template for every line of r with opts.Jobs at once, writing
+// their output to stdout and stderr, and returns how many failed
+func run(ctx context.Context, template []string, r io.Reader, stdout, stderr io.Writer, opts *Options) (int, error) {
+	jobs := make(chan job)
+	results := make(chan *Result)
+	stop := make(chan struct{})
+	var halted atomic.Bool
+	halt := sync.OnceFunc(func() {
+		halted.Store(true)
+		close(stop)
+	})
+
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		scanner := lines.NewReader(interrupt.Reader(ctx, r))
+		if opts.Null {
+			scanner.SetDelimiter(0)
+		}
+		for seq := 1; scanner.Scan(); seq++ {
+			select {
+			case jobs <- job{seq: seq, arg: scanner.Text()}:
+			case <-stop:
+				readErr <- nil
+				return
+			case <-ctx.Done():
+				readErr <- ctx.Err()
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	for range opts.Jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if halted.Load() {
+					results <- &Result{Seq: j.seq, skipped: true}
+					return
+				}
+				res := runJob(ctx, template, j)
+				if res.Exit != 0 && opts.HaltOnError {
+					halt()
+				}
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	failed := 0
+	var writeErr error
+	pending := map[int]*Result{}
+	next := 1
+	for res := range results {
+		if res.Exit != 0 {
+			failed++
+		}
+		if writeErr != nil {
+			continue
+		}
+		if !opts.KeepOrder {
+			writeErr = writeResult(stdout, stderr, res, opts)
+			continue
+		}
+		pending[res.Seq] = res
+		for res, ok := pending[next]; ok && writeErr == nil; res, ok = pending[next] {
+			delete(pending, next)
+			next++
+			writeErr = writeResult(stdout, stderr, res, opts)
+		}
+	}
+
+	if writeErr != nil {
+		return failed, writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return failed, err
+	}
+	if halted.Load() {
+		// The reader may still be waiting for input nobody needs
+		return failed, nil
+	}
+	if err := <-readErr; err != nil {
+		return failed, fmt.Errorf("error reading input: %w", err)
+	}
+	return failed, nil
+}
+
+// runJob runs template for one input line and collects its output
+func runJob(ctx context.Context, template []string, j job) *Result {
+	res := &Result{Seq: j.seq, Arg: j.arg, Command: expand(template, j.arg, j.seq)}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, res.Command[0], res.Command[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	res.ElapsedSeconds = time.Since(start).Seconds()
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		res.Exit = exitErr.ExitCode()
+		if res.Exit < 0 {
+			res.Exit = 128 + signal(exitErr)
+		}
+	default:
+		logging.PathError("Cannot run", res.Command[0], err)
+		res.Exit = statusFailed
+		if errors.Is(err, exec.ErrNotFound) {
+			res.Exit = statusNotFound
+		}
+	}
+	return res
+}
+
+// writeResult prints what a job wrote, or its result as JSON with --json
+func writeResult(stdout, stderr io.Writer, res *Result, opts *Options) error {
+	if res.skipped {
+		return nil
+	}
+	if opts.JSON {
+		return json.NewEncoder(stdout).Encode(res)
+	}
+	if err := writeOutput(stdout, res.Stdout, res.Arg, opts); err != nil {
+		return err
+	}
+	// Flush what came before, so that the job's errors follow its output
+	if f, ok := stdout.(interface{ Flush() error }); ok && res.Stderr != "" {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return writeOutput(stderr, res.Stderr, res.Arg, opts)
+}
+
+// writeOutput writes the output of a job, with --tag starting each line
+// with the job's input line
+func writeOutput(w io.Writer, text, arg string, opts *Options) error {
+	if !opts.Tag || text == "" {
+		_, err := io.WriteString(w, text)
+		return err
+	}
+	var b strings.Builder
+	for line := range strings.Lines(text) {
+		b.WriteString(arg)
+		b.WriteByte('\t')
+		b.WriteString(line)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// signal returns the number of the signal that ended the job, or 0 where
+// the system doesn't say
+func signal(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(interface{ Signal() syscall.Signal }); ok {
+		return int(status.Signal())
+	}
+	return 0
+}
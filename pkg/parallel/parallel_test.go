@@ -0,0 +1,30 @@
+package parallel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpand tests each placeholder, and adding the line at the end of a
+// template without any
+func TestExpand(t *testing.T) {
+	arg := "src/img/logo.png"
+	assert.Equal(t, []string{"gzip", "-9", arg}, expand([]string{"gzip", "-9"}, arg, 1))
+	assert.Equal(t, []string{"convert", arg, "src/img/logo.jpg"}, expand([]string{"convert", "{}", "{.}.jpg"}, arg, 1))
+	assert.Equal(t, []string{"logo.png", "src/img", "logo", "job-7"}, expand([]string{"{/}", "{//}", "{/.}", "job-{#}"}, arg, 7))
+	assert.Equal(t, []string{"echo", "{x}", arg}, expand([]string{"echo", "{x}"}, arg, 1))
+}
+
+// TestWriteOutput tests --tag with and without a final newline
+func TestWriteOutput(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, writeOutput(&b, "one\ntwo", "host", &Options{Tag: true}))
+	assert.Equal(t, "host\tone\nhost\ttwo\n", b.String())
+
+	b.Reset()
+	require.NoError(t, writeOutput(&b, "one\n", "host", &Options{}))
+	assert.Equal(t, "one\n", b.String())
+}
@@ -0,0 +1,52 @@
+//go:build unix
+
+package parallel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRun tests running jobs in input order with -k and --tag, and
+// counting failed jobs
+func TestRun(t *testing.T) {
+	var stdout, stderr strings.Builder
+	input := strings.NewReader("3\n1\n2\n")
+	failed, err := run(context.Background(), []string{"sh", "-c", "sleep 0.0$1; echo x$1; [ $1 != 1 ]", "_"},
+		input, &stdout, &stderr, &Options{Jobs: 3, KeepOrder: true, Tag: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, "3\tx3\n1\tx1\n2\tx2\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+// TestRun_JSON tests the results of --json and that --halt-on-error
+// starts no jobs after one fails
+func TestRun_JSON(t *testing.T) {
+	var stdout, stderr strings.Builder
+	input := strings.NewReader("a\x00fail\x00c\x00d\x00")
+	script := `echo "$1"; [ "$1" != fail ] || { echo oops >&2; exit 3; }`
+	failed, err := run(context.Background(), []string{"sh", "-c", script, "_", "{}"},
+		input, &stdout, &stderr, &Options{Jobs: 1, JSON: true, Null: true, HaltOnError: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+
+	var results []Result
+	scanner := bufio.NewScanner(strings.NewReader(stdout.String()))
+	for scanner.Scan() {
+		var res Result
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &res))
+		results = append(results, res)
+	}
+	require.Len(t, results, 2)
+	assert.Equal(t, Result{Seq: 1, Arg: "a", Command: []string{"sh", "-c", script, "_", "a"}, Stdout: "a\n",
+		ElapsedSeconds: results[0].ElapsedSeconds}, results[0])
+	assert.Equal(t, 3, results[1].Exit)
+	assert.Equal(t, "oops\n", results[1].Stderr)
+}
@@ -0,0 +1,73 @@
+package parallel
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// placeholders are the replacement strings of a command template, as in
+// GNU parallel, longest first so that {/.} isn't taken for {/}
+var placeholders = []string{"{//}", "{/.}", "{/}", "{.}", "{#}", "{}"}
+
+// expand returns the command for one input line: template with its
+// placeholders replaced, or with arg added at the end if it has none
+func expand(template []string, arg string, seq int) []string {
+	found := false
+	cmd := make([]string, len(template))
+	for i, word := range template {
+		cmd[i] = replace(word, arg, seq, &found)
+	}
+	if !found {
+		cmd = append(cmd, arg)
+	}
+	return cmd
+}
+
+// replace replaces the placeholders in word, setting *found if it had any
+func replace(word, arg string, seq int, found *bool) string {
+	if !strings.Contains(word, "{") {
+		return word
+	}
+	var b strings.Builder
+	for len(word) > 0 {
+		p := prefix(word)
+		if p == "" {
+			b.WriteByte(word[0])
+			word = word[1:]
+			continue
+		}
+		*found = true
+		b.WriteString(value(p, arg, seq))
+		word = word[len(p):]
+	}
+	return b.String()
+}
+
+// prefix returns the placeholder word starts with, or ""
+func prefix(word string) string {
+	for _, p := range placeholders {
+		if strings.HasPrefix(word, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// value returns what placeholder p stands for
+func value(p, arg string, seq int) string {
+	switch p {
+	case "{.}":
+		return strings.TrimSuffix(arg, filepath.Ext(arg))
+	case "{/}":
+		return filepath.Base(arg)
+	case "{//}":
+		return filepath.Dir(arg)
+	case "{/.}":
+		base := filepath.Base(arg)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	case "{#}":
+		return strconv.Itoa(seq)
+	}
+	return arg
+}
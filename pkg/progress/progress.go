@@ -0,0 +1,329 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// Supported values of the --progress flag
+const (
+	Never  = "never"
+	Auto   = "auto"
+	Always = "always"
+	JSON   = "json"
+)
+
+// Mode controls progress reporting for every command. Set from the global
+// --progress flag.
+var Mode = Auto
+
+// Output is where progress is written
+var Output io.Writer = os.Stderr
+
+// Unit is what a Bar counts
+type Unit int
+
+const (
+	Items Unit = iota
+	Bytes
+)
+
+// String returns the unit name used in JSON events
+func (u Unit) String() string {
+	if u == Bytes {
+		return "bytes"
+	}
+	return "items"
+}
+
+// Rendering intervals. A bar is only drawn once an operation has run for
+// delay, so quick commands print nothing.
+var (
+	delay        = 500 * time.Millisecond
+	drawInterval = 100 * time.Millisecond
+	jsonInterval = time.Second
+)
+
+// barWidth is the number of cells in the drawn bar
+const barWidth = 24
+
+// Validate checks that mode is one of never, auto, always or json
+func Validate(mode string) error {
+	switch mode {
+	case Never, Auto, Always, JSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid --progress value %q (want never, auto, always or json)", mode)
+	}
+}
+
+// Enabled reports whether progress is shown at all, so commands can skip
+// work such as computing totals when it is not
+func Enabled() bool {
+	return resolve() != ""
+}
+
+// Shown reports whether a command should show progress, given whether it
+// was asked to list what it does with its own -v. Those lines would garble
+// a progress line, so they replace it.
+func Shown(verbose bool) bool {
+	return !verbose && Enabled()
+}
+
+// resolve resolves the global Mode to how progress is shown: "" for not at
+// all, Always for a redrawn line or JSON for events. In auto mode a line
+// is only drawn when both stdout and stderr are terminals, so progress
+// never ends up in redirected output, and --quiet or --dry-run turn it off.
+func resolve() string {
+	if logging.Quiet || dryrun.Enabled {
+		return ""
+	}
+	switch Mode {
+	case JSON, Always:
+		return Mode
+	case Auto:
+		if color.Terminal(os.Stdout) && color.Terminal(os.Stderr) {
+			return Always
+		}
+	}
+	return ""
+}
+
+// Bar tracks the progress of one operation. All methods are safe for
+// concurrent use and do nothing on a nil Bar or one that is not shown.
+type Bar struct {
+	mode  string
+	label string
+	unit  Unit
+
+	mu      sync.Mutex
+	total   int64 // 0 when unknown
+	done    int64
+	current string
+	start   time.Time
+	last    time.Time // when progress was last written
+	drawn   bool      // a line is on screen and must be cleared
+}
+
+// New starts tracking an operation called label that will process total
+// units (0 if unknown)
+func New(label string, unit Unit, total int64) *Bar {
+	b := &Bar{mode: resolve(), label: label, unit: unit, total: total, start: time.Now()}
+	if b.mode == JSON {
+		b.event("start")
+	}
+	return b
+}
+
+// Add records n more units as done
+func (b *Bar) Add(n int64) {
+	if b == nil || b.mode == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += n
+	b.update()
+}
+
+// Describe names the item currently being processed
+func (b *Bar) Describe(name string) {
+	if b == nil || b.mode == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = name
+	b.update()
+}
+
+// Finish ends the operation, clearing a drawn line
+func (b *Bar) Finish() {
+	if b == nil || b.mode == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.mode == JSON {
+		b.event("done")
+	} else if b.drawn {
+		fmt.Fprint(Output, "\r\x1b[K")
+		b.drawn = false
+	}
+}
+
+// Reader returns r counting the bytes read from it as done
+func (b *Bar) Reader(r io.Reader) io.Reader {
+	if b == nil || b.mode == "" {
+		return r
+	}
+	return &reader{r: r, b: b}
+}
+
+// reader adds the bytes read through it to a Bar
+type reader struct {
+	r io.Reader
+	b *Bar
+}
+
+// Read reads from the underlying reader and records the bytes read
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.b.Add(int64(n))
+	}
+	return n, err
+}
+
+// update writes progress if it is due, with b.mu held
+func (b *Bar) update() {
+	now := time.Now()
+	if now.Sub(b.start) < delay {
+		return
+	}
+
+	if b.mode == JSON {
+		if now.Sub(b.last) >= jsonInterval {
+			b.last = now
+			b.event("progress")
+		}
+		return
+	}
+	if now.Sub(b.last) >= drawInterval {
+		b.last = now
+		b.draw(now)
+	}
+}
+
+// draw redraws the progress line, e.g.
+// "cp  42% [##########..............] 1.2 MiB/2.9 MiB 3.1 MiB/s ETA 0:01 a.iso"
+func (b *Bar) draw(now time.Time) {
+	var line strings.Builder
+	line.WriteString(b.label)
+
+	if b.total > 0 {
+		fraction := min(float64(b.done)/float64(b.total), 1)
+		filled := int(fraction * barWidth)
+		fmt.Fprintf(&line, " %3d%% [%s%s] %s/%s", int(fraction*100),
+			strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled),
+			b.format(b.done), b.format(b.total))
+	} else {
+		fmt.Fprintf(&line, " %s", b.format(b.done))
+	}
+
+	if rate := b.rate(now); rate > 0 {
+		if b.unit == Bytes {
+			fmt.Fprintf(&line, " %s/s", b.format(int64(rate)))
+		}
+		if eta, ok := b.eta(rate); ok {
+			fmt.Fprintf(&line, " ETA %d:%02d", int(eta.Minutes()), int(eta.Seconds())%60)
+		}
+	}
+	if b.current != "" {
+		line.WriteString(" " + b.current)
+	}
+
+	// Keep to one line on a standard-width terminal
+	text := []rune(line.String())
+	if len(text) > 79 {
+		text = text[:79]
+	}
+	fmt.Fprint(Output, "\r\x1b[K"+string(text))
+	b.drawn = true
+}
+
+// event writes a JSON progress event
+func (b *Bar) event(name string) {
+	now := time.Now()
+	record := struct {
+		Event   string  `json:"event"`
+		Tool    string  `json:"tool"`
+		Label   string  `json:"label"`
+		Unit    string  `json:"unit"`
+		Done    int64   `json:"done"`
+		Total   int64   `json:"total,omitempty"`
+		Rate    float64 `json:"rate,omitempty"`
+		ETA     float64 `json:"eta_seconds,omitempty"`
+		Current string  `json:"current,omitempty"`
+	}{Event: name, Tool: logging.Tool, Label: b.label, Unit: b.unit.String(), Done: b.done, Total: b.total, Current: b.current}
+
+	if rate := b.rate(now); rate > 0 {
+		record.Rate = rate
+		if eta, ok := b.eta(rate); ok && name == "progress" {
+			record.ETA = eta.Seconds()
+		}
+	}
+
+	line, _ := json.Marshal(record)
+	fmt.Fprintln(Output, string(line))
+}
+
+// rate returns the units done per second so far
+func (b *Bar) rate(now time.Time) float64 {
+	elapsed := now.Sub(b.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(b.done) / elapsed
+}
+
+// eta estimates the time left at rate, if the total is known
+func (b *Bar) eta(rate float64) (time.Duration, bool) {
+	if b.total <= 0 || b.done > b.total {
+		return 0, false
+	}
+	return time.Duration(float64(b.total-b.done) / rate * float64(time.Second)), true
+}
+
+// format formats n units, with binary prefixes for bytes
+func (b *Bar) format(n int64) string {
+	if b.unit != Bytes {
+		return fmt.Sprintf("%d", n)
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Size returns the total size of the regular files at and below paths, as
+// the total for a Bar counting bytes. Parts that cannot be read are left
+// out; the operation itself will report them.
+func Size(ctx context.Context, paths ...string) int64 {
+	var total int64
+	for _, path := range paths {
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					total += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	return total
+}
@@ -0,0 +1,123 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capture redirects Output, sets mode and makes progress due immediately
+func capture(t *testing.T, mode string) *bytes.Buffer {
+	var buf bytes.Buffer
+	savedOutput, savedMode, savedDelay := Output, Mode, delay
+	Output, Mode, delay = &buf, mode, 0
+	t.Cleanup(func() {
+		Output, Mode, delay = savedOutput, savedMode, savedDelay
+	})
+	return &buf
+}
+
+// TestBar_JSON tests the start and done events and byte counting through
+// Reader
+func TestBar_JSON(t *testing.T) {
+	buf := capture(t, JSON)
+
+	bar := New("cp", Bytes, 10)
+	bar.Describe("a.txt")
+	_, err := io.Copy(io.Discard, bar.Reader(strings.NewReader("0123456789")))
+	require.NoError(t, err)
+	bar.Finish()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.GreaterOrEqual(t, len(lines), 2)
+
+	var first, last map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+
+	assert.Equal(t, "start", first["event"])
+	assert.Equal(t, "bytes", first["unit"])
+	assert.Equal(t, float64(10), first["total"])
+	assert.Equal(t, "done", last["event"])
+	assert.Equal(t, float64(10), last["done"])
+	assert.Equal(t, "a.txt", last["current"])
+}
+
+// TestBar_Draw tests the drawn line and that Finish clears it
+func TestBar_Draw(t *testing.T) {
+	buf := capture(t, Always)
+
+	bar := New("rm", Items, 4)
+	bar.Add(2)
+	assert.Contains(t, buf.String(), "rm  50% [############............] 2/4")
+
+	bar.Finish()
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\x1b[K"))
+}
+
+// TestBar_Disabled tests that a nil Bar and one created with progress off
+// write nothing
+func TestBar_Disabled(t *testing.T) {
+	buf := capture(t, Never)
+
+	var none *Bar
+	none.Add(1)
+	none.Describe("x")
+	none.Finish()
+	assert.Equal(t, "r", readAll(t, none.Reader(strings.NewReader("r"))))
+
+	bar := New("cp", Bytes, 1)
+	bar.Add(1)
+	bar.Finish()
+	assert.Empty(t, buf.String())
+	assert.False(t, Enabled())
+}
+
+// TestShown tests that a command's own -v turns progress off
+func TestShown(t *testing.T) {
+	capture(t, Always)
+	assert.True(t, Shown(false))
+	assert.False(t, Shown(true))
+
+	capture(t, Never)
+	assert.False(t, Shown(false))
+}
+
+// TestBar_Delay tests that nothing is drawn for quick operations
+func TestBar_Delay(t *testing.T) {
+	buf := capture(t, Always)
+	delay = time.Hour
+
+	bar := New("mv", Items, 1)
+	bar.Add(1)
+	bar.Finish()
+	assert.Empty(t, buf.String())
+}
+
+// TestSize tests summing file sizes below several paths
+func TestSize(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "d", "e"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "d", "a"), []byte("123"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "d", "e", "b"), []byte("45"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "c"), []byte("6"), 0644))
+
+	total := Size(context.Background(), filepath.Join(tempDir, "d"), filepath.Join(tempDir, "c"), filepath.Join(tempDir, "missing"))
+	assert.Equal(t, int64(6), total)
+}
+
+// readAll reads r to the end
+func readAll(t *testing.T, r io.Reader) string {
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,262 @@
+package regex
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Syntax is a regular expression dialect
+type Syntax int
+
+const (
+	// Basic is POSIX basic syntax (BRE), the default of grep and sed:
+	// \( \) \{ \} \| \+ \? are operators and the bare characters literal
+	Basic Syntax = iota
+	// Extended is POSIX extended syntax (ERE), used by grep -E, sed -E and
+	// awk
+	Extended
+	// Perl is PCRE-style syntax with lookaround and back-references, used
+	// by grep -P
+	Perl
+)
+
+// String returns the name of the dialect
+func (s Syntax) String() string {
+	switch s {
+	case Extended:
+		return "extended"
+	case Perl:
+		return "perl"
+	default:
+		return "basic"
+	}
+}
+
+// Regexp is a compiled pattern. It is backed by Go's regexp package where
+// possible and by a backtracking engine when the pattern needs features RE2
+// lacks, such as back-references and lookaround. Offsets are always byte
+// offsets into the input.
+type Regexp struct {
+	re  *regexp.Regexp  // RE2 engine: linear time, used whenever it can be
+	bt  *regexp2.Regexp // backtracking engine, when re is nil
+	src string
+
+	literal   string // the only text the pattern matches, if isLiteral
+	fold      bool   // literal is matched ignoring case
+	isLiteral bool
+}
+
+// Compile compiles pattern in the given dialect, optionally ignoring case.
+// BRE and ERE patterns are translated to the engines' syntax, so GNU
+// extensions such as \< \> \w \s and POSIX classes like [[:digit:]] work in
+// both.
+func Compile(pattern string, syntax Syntax, ignoreCase bool) (*Regexp, error) {
+	return compile(pattern, syntax, ignoreCase, false)
+}
+
+// CompileMultiline is like Compile for patterns matched against text of
+// many lines at once: . matches a newline in every dialect, and ^ and $
+// match at the start and end of each line as well as of the text.
+func CompileMultiline(pattern string, syntax Syntax, ignoreCase bool) (*Regexp, error) {
+	return compile(pattern, syntax, ignoreCase, true)
+}
+
+func compile(pattern string, syntax Syntax, ignoreCase, multiline bool) (*Regexp, error) {
+	expr, backrefs := pattern, false
+	if syntax != Perl {
+		var err error
+		expr, backrefs, err = translate(pattern, syntax)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Translated patterns let . match a newline, as in POSIX, which only
+	// matters for NUL-separated input
+	flags := ""
+	if syntax != Perl || multiline {
+		flags = "s"
+	}
+	if multiline {
+		flags += "m"
+	}
+	if ignoreCase {
+		flags += "i"
+	}
+
+	if !backrefs {
+		goExpr := expr
+		if flags != "" {
+			goExpr = "(?" + flags + ")" + expr
+		}
+		re, err := regexp.Compile(goExpr)
+		if err == nil {
+			r := &Regexp{re: re, src: pattern}
+			r.literal, r.fold, r.isLiteral = literal(goExpr)
+			return r, nil
+		}
+		// Anything RE2 cannot compile in a translated pattern is a genuine
+		// error; a Perl pattern may just need the backtracking engine
+		if syntax != Perl {
+			return nil, err
+		}
+	}
+
+	options := regexp2.None
+	if syntax != Perl || multiline {
+		options |= regexp2.Singleline
+	}
+	if multiline {
+		options |= regexp2.Multiline
+	}
+	if ignoreCase {
+		options |= regexp2.IgnoreCase
+	}
+	bt, err := regexp2.Compile(expr, options)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing regexp: %w", err)
+	}
+	return &Regexp{bt: bt, src: pattern}, nil
+}
+
+// String returns the pattern the Regexp was compiled from
+func (r *Regexp) String() string {
+	return r.src
+}
+
+// Literal returns the text the pattern matches when it is a plain string
+// without operators, and whether case is ignored in matching it (the text
+// may then be in a different case than the pattern). ok is false for every
+// other pattern.
+func (r *Regexp) Literal() (text string, fold bool, ok bool) {
+	return r.literal, r.fold, r.isLiteral
+}
+
+// literal reports whether expr, in RE2 syntax, only matches a fixed string
+func literal(expr string) (string, bool, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil || re.Op != syntax.OpLiteral {
+		return "", false, false
+	}
+	return string(re.Rune), re.Flags&syntax.FoldCase != 0, true
+}
+
+// LiteralPrefix returns a literal string every match must begin with, or ""
+// if there is none or it cannot be determined
+func (r *Regexp) LiteralPrefix() string {
+	if r.re == nil {
+		return ""
+	}
+	prefix, _ := r.re.LiteralPrefix()
+	return prefix
+}
+
+// Match reports whether b contains a match
+func (r *Regexp) Match(b []byte) bool {
+	if r.re != nil {
+		return r.re.Match(b)
+	}
+	return r.MatchString(string(b))
+}
+
+// MatchString reports whether s contains a match
+func (r *Regexp) MatchString(s string) bool {
+	if r.re != nil {
+		return r.re.MatchString(s)
+	}
+	// Without a timeout set the backtracking engine cannot fail
+	matched, _ := r.bt.MatchString(s)
+	return matched
+}
+
+// FindStringIndex returns the location of the first match in s, or nil
+func (r *Regexp) FindStringIndex(s string) []int {
+	if r.re != nil {
+		return r.re.FindStringIndex(s)
+	}
+	if locs := r.findAll(s, 1, false); len(locs) > 0 {
+		return locs[0]
+	}
+	return nil
+}
+
+// FindAllIndex returns the locations of up to n successive matches in b,
+// all of them if n < 0
+func (r *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	if r.re != nil {
+		return r.re.FindAllIndex(b, n)
+	}
+	return r.findAll(string(b), n, false)
+}
+
+// FindAllStringIndex returns the locations of up to n successive matches
+// in s, all of them if n < 0
+func (r *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	if r.re != nil {
+		return r.re.FindAllStringIndex(s, n)
+	}
+	return r.findAll(s, n, false)
+}
+
+// FindAllStringSubmatchIndex returns the locations of up to n successive
+// matches in s and their groups, as pairs of offsets with -1 for groups
+// that did not take part
+func (r *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	if r.re != nil {
+		return r.re.FindAllStringSubmatchIndex(s, n)
+	}
+	return r.findAll(s, n, true)
+}
+
+// ReplaceAll returns a copy of src with every match replaced by template,
+// in which $1, ${1} and ${name} stand for groups and $$ for a dollar sign
+func (r *Regexp) ReplaceAll(src, template []byte) []byte {
+	if r.re != nil {
+		return r.re.ReplaceAll(src, template)
+	}
+	result, err := r.bt.Replace(string(src), string(template), -1, -1)
+	if err != nil {
+		return src
+	}
+	return []byte(result)
+}
+
+// findAll runs the backtracking engine over s, converting its rune offsets
+// to byte offsets
+func (r *Regexp) findAll(s string, n int, groups bool) [][]int {
+	runes := []rune(s)
+
+	// offsets[i] is the byte offset of rune i. Invalid bytes decode to one
+	// rune each, as in the conversion above.
+	offsets := make([]int, 0, len(runes)+1)
+	for i := 0; i < len(s); {
+		offsets = append(offsets, i)
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	offsets = append(offsets, len(s))
+
+	var locs [][]int
+	m, _ := r.bt.FindRunesMatch(runes)
+	for m != nil && (n < 0 || len(locs) < n) {
+		if !groups {
+			locs = append(locs, []int{offsets[m.Index], offsets[m.Index+m.Length]})
+		} else {
+			loc := make([]int, 0, 2*m.GroupCount())
+			for _, g := range m.Groups() {
+				if len(g.Captures) == 0 {
+					loc = append(loc, -1, -1)
+					continue
+				}
+				loc = append(loc, offsets[g.Index], offsets[g.Index+g.Length])
+			}
+			locs = append(locs, loc)
+		}
+		m, _ = r.bt.FindNextMatch(m)
+	}
+	return locs
+}
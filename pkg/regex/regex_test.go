@@ -0,0 +1,161 @@
+package regex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTranslate_Basic tests that BRE operators need a backslash and the bare
+// characters are literal
+func TestTranslate_Basic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{`a|b`, `a\|b`},
+		{`a\|b`, `a|b`},
+		{`\(ab\)*`, `(ab)*`},
+		{`a+b?`, `a\+b\?`},
+		{`a\+b\?`, `a+b?`},
+		{`x\{2,3\}`, `x{2,3}`},
+		{`x\{,3\}`, `x{0,3}`},
+		{`*a`, `\*a`},
+		{`^*a`, `^\*a`},
+		{`a^b$c`, `a\^b\$c`},
+		{`\(^a$\)`, `(^a$)`},
+		{`\<word\>`, `\bword\b`},
+		{`[]a\]`, `[\]a\\]`},
+		{`[[:digit:][:upper:]]`, `[0-9A-Z]`},
+	}
+	for _, tt := range tests {
+		got, _, err := translate(tt.pattern, Basic)
+		require.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.want, got, tt.pattern)
+	}
+}
+
+// TestTranslate_Extended tests ERE, where the operators are bare and a
+// brace that does not start an interval is literal
+func TestTranslate_Extended(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{`a|b`, `a|b`},
+		{`(ab)+`, `(ab)+`},
+		{`\(a\)`, `\(a\)`},
+		{`*a`, `\*a`},
+		{`x{2}`, `x{2}`},
+		{`x{a}`, `x\{a\}`},
+		{`(?i)a`, `(\?i)a`},
+	}
+	for _, tt := range tests {
+		got, _, err := translate(tt.pattern, Extended)
+		require.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.want, got, tt.pattern)
+	}
+}
+
+// TestTranslate_Errors tests patterns GNU rejects
+func TestTranslate_Errors(t *testing.T) {
+	for _, pattern := range []string{`\(a`, `a\)`, `[a`, `a\`, `\1`, `[[:nope:]]`, `[[=a=]]`, `x\{a\}`} {
+		_, _, err := translate(pattern, Basic)
+		assert.Error(t, err, pattern)
+	}
+}
+
+// TestCompile_BackReferences tests that back-references switch to the
+// backtracking engine with byte offsets
+func TestCompile_BackReferences(t *testing.T) {
+	re, err := Compile(`\(ab*\)-\1`, Basic, false)
+	require.NoError(t, err)
+
+	assert.True(t, re.MatchString("abb-abb"))
+	assert.False(t, re.MatchString("abb-ab-"))
+	assert.Equal(t, [][]int{{4, 9}}, re.FindAllIndex([]byte("ééab-ab"), -1))
+	assert.Equal(t, [][]int{{0, 5, 0, 2}}, re.FindAllStringSubmatchIndex("ab-ab", -1))
+	assert.Equal(t, "<ab>", string(re.ReplaceAll([]byte("ab-ab"), []byte("<$1>"))))
+	assert.Empty(t, re.LiteralPrefix())
+}
+
+// TestCompile_Perl tests Perl syntax, falling back to the backtracking
+// engine for lookaround
+func TestCompile_Perl(t *testing.T) {
+	re, err := Compile(`\d+(?=px)`, Perl, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 5}, re.FindStringIndex("10 20px"))
+
+	re, err = Compile(`FOO\d`, Perl, true)
+	require.NoError(t, err)
+	assert.True(t, re.MatchString("foo1"))
+
+	_, err = Compile(`(`, Perl, false)
+	assert.Error(t, err)
+}
+
+// TestCompile_LiteralPrefix tests that translated patterns keep a literal
+// prefix for fast scanning
+func TestCompile_LiteralPrefix(t *testing.T) {
+	re, err := Compile(`foo.*bar`, Basic, false)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", re.LiteralPrefix())
+	assert.Equal(t, `foo.*bar`, re.String())
+}
+
+// TestCompile_Literal tests recognizing patterns that are plain strings
+func TestCompile_Literal(t *testing.T) {
+	for _, tc := range []struct {
+		pattern    string
+		syntax     Syntax
+		ignoreCase bool
+		text       string
+		fold, ok   bool
+	}{
+		{"timeout", Basic, false, "timeout", false, true},
+		{`a\.b`, Basic, false, "a.b", false, true},
+		{"a+b", Basic, false, "a+b", false, true},
+		{"a+b", Extended, false, "", false, false},
+		{"Error", Extended, true, "Error", true, true},
+		{"x.y", Basic, false, "", false, false},
+		{`a\(b\)\1`, Basic, false, "", false, false},
+		{"a(?=b)", Perl, false, "", false, false},
+	} {
+		re, err := Compile(tc.pattern, tc.syntax, tc.ignoreCase)
+		require.NoError(t, err, tc.pattern)
+		text, fold, ok := re.Literal()
+		assert.Equal(t, tc.ok, ok, tc.pattern)
+		if tc.ok {
+			assert.Equal(t, tc.fold, fold, tc.pattern)
+			assert.Equal(t, strings.ToLower(tc.text), strings.ToLower(text), tc.pattern)
+		}
+	}
+}
+
+// TestCompileMultiline tests that . crosses lines in every dialect and that
+// ^ and $ anchor at line boundaries, on both engines
+func TestCompileMultiline(t *testing.T) {
+	text := "func f(a int,\n\tb int) {\n}\n"
+	for _, tc := range []struct {
+		pattern string
+		syntax  Syntax
+		want    []int
+	}{
+		{`func f(.*) {`, Extended, []int{0, 23}},
+		{`f\(a.*b int\)`, Perl, []int{5, 21}},
+		{`^\tb`, Perl, []int{14, 16}},
+		{`{$`, Basic, []int{22, 23}},
+		{`(?<=\()a int,$`, Perl, []int{7, 13}},
+	} {
+		re, err := CompileMultiline(tc.pattern, tc.syntax, false)
+		require.NoError(t, err, tc.pattern)
+		assert.Equal(t, tc.want, re.FindStringIndex(text), tc.pattern)
+	}
+
+	// Without multiline, a Perl . stops at the end of the line
+	re, err := Compile(`f\(a.*b int\)`, Perl, false)
+	require.NoError(t, err)
+	assert.False(t, re.MatchString(text))
+}
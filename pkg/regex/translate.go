@@ -0,0 +1,295 @@
+package regex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// posixClasses spells out the POSIX character classes as ASCII ranges both
+// engines accept inside brackets
+var posixClasses = map[string]string{
+	"alnum":  `0-9A-Za-z`,
+	"alpha":  `A-Za-z`,
+	"blank":  ` \t`,
+	"cntrl":  `\x00-\x1F\x7F`,
+	"digit":  `0-9`,
+	"graph":  `\x21-\x7E`,
+	"lower":  `a-z`,
+	"print":  `\x20-\x7E`,
+	"punct":  `!-/:-@\[-` + "`" + `\{-~`,
+	"space":  ` \t\n\v\f\r`,
+	"upper":  `A-Z`,
+	"xdigit": `0-9A-Fa-f`,
+}
+
+// translator converts a BRE or ERE pattern into the common subset of RE2
+// and .NET syntax understood by both engines
+type translator struct {
+	pattern string
+	syntax  Syntax
+	pos     int
+	out     strings.Builder
+
+	// atStart is set where a repetition operator has nothing to repeat:
+	// at the start of the pattern or a group, after an alternation, and
+	// after a leading ^. There * is literal, as in GNU grep.
+	atStart bool
+	depth   int // open groups
+	groups  int // groups opened so far, for checking back-references
+
+	backrefs bool
+}
+
+// translate converts pattern to engine syntax, reporting whether it uses
+// back-references and so needs the backtracking engine
+func translate(pattern string, syntax Syntax) (string, bool, error) {
+	t := &translator{pattern: pattern, syntax: syntax, atStart: true}
+	for t.pos < len(t.pattern) {
+		if err := t.next(); err != nil {
+			return "", false, err
+		}
+	}
+	if t.depth > 0 {
+		return "", false, fmt.Errorf("unmatched ( or \\(")
+	}
+	return t.out.String(), t.backrefs, nil
+}
+
+// next translates the element at t.pos
+func (t *translator) next() error {
+	c := t.pattern[t.pos]
+	t.pos++
+
+	if c == '\\' {
+		if t.pos == len(t.pattern) {
+			return fmt.Errorf("trailing backslash (\\)")
+		}
+		e := t.pattern[t.pos]
+		t.pos++
+		if t.syntax == Basic {
+			switch e {
+			case '(', ')', '|', '{', '}', '+', '?':
+				return t.operator(e)
+			}
+		}
+		return t.escape(e)
+	}
+
+	if t.syntax == Extended {
+		switch c {
+		case '(', ')', '|', '{', '+', '?', '*':
+			return t.operator(c)
+		case '^':
+			t.out.WriteByte('^')
+			return nil
+		case '$':
+			t.out.WriteByte('$')
+			t.atStart = false
+			return nil
+		}
+	}
+
+	switch c {
+	case '*':
+		if t.atStart {
+			t.literal(c)
+		} else {
+			t.out.WriteByte('*')
+		}
+	case '^':
+		// In BRE ^ is only an anchor where a pattern could begin
+		if t.atStart {
+			t.out.WriteByte('^')
+			return nil
+		}
+		t.literal(c)
+	case '$':
+		// ... and $ only where one could end
+		if t.atEnd() {
+			t.out.WriteByte('$')
+		} else {
+			t.literal(c)
+		}
+	case '.':
+		t.out.WriteByte('.')
+		t.atStart = false
+	case '[':
+		return t.bracket()
+	default:
+		t.literal(c)
+	}
+	return nil
+}
+
+// literal writes c as an ordinary character
+func (t *translator) literal(c byte) {
+	if strings.IndexByte(`\.+*?()|[]{}^$`, c) >= 0 {
+		t.out.WriteByte('\\')
+	}
+	t.out.WriteByte(c)
+	t.atStart = false
+}
+
+// atEnd reports whether t.pos is where a BRE could end: at the end of the
+// pattern or before \) or \|
+func (t *translator) atEnd() bool {
+	rest := t.pattern[t.pos:]
+	return rest == "" || strings.HasPrefix(rest, `\)`) || strings.HasPrefix(rest, `\|`)
+}
+
+// operator translates grouping, alternation and repetition
+func (t *translator) operator(c byte) error {
+	switch c {
+	case '(':
+		t.out.WriteByte('(')
+		t.depth++
+		t.groups++
+		t.atStart = true
+	case ')':
+		if t.depth == 0 {
+			if t.syntax == Extended {
+				// An unmatched ) is an ordinary character in ERE
+				t.literal(c)
+				return nil
+			}
+			return fmt.Errorf("unmatched ) or \\)")
+		}
+		t.out.WriteByte(')')
+		t.depth--
+		t.atStart = false
+	case '|':
+		t.out.WriteByte('|')
+		t.atStart = true
+	case '{':
+		return t.interval()
+	case '}':
+		t.literal(c)
+	default: // + ? *
+		if t.atStart {
+			t.literal(c)
+		} else {
+			t.out.WriteByte(c)
+		}
+	}
+	return nil
+}
+
+// interval translates a bound such as {2}, {2,} or {,3}, with t.pos just
+// past the opening brace
+func (t *translator) interval() error {
+	closing := "}"
+	if t.syntax == Basic {
+		closing = `\}`
+	}
+	end := strings.Index(t.pattern[t.pos:], closing)
+	bounds := ""
+	if end >= 0 {
+		bounds = t.pattern[t.pos : t.pos+end]
+	}
+	valid := end >= 0 && bounds != "" && strings.Trim(bounds, "0123456789,") == "" && strings.Count(bounds, ",") <= 1
+
+	if !valid || t.atStart {
+		// GNU treats a brace that does not start a valid interval as an
+		// ordinary character in ERE
+		if t.syntax == Extended {
+			t.literal('{')
+			return nil
+		}
+		if !valid {
+			return fmt.Errorf("invalid content of \\{\\}")
+		}
+		return fmt.Errorf("invalid preceding regular expression")
+	}
+
+	if strings.HasPrefix(bounds, ",") {
+		bounds = "0" + bounds
+	}
+	t.out.WriteString("{" + bounds + "}")
+	t.pos += end + len(closing)
+	return nil
+}
+
+// escape translates a backslash followed by e
+func (t *translator) escape(e byte) error {
+	switch {
+	case e >= '1' && e <= '9':
+		if int(e-'0') > t.groups {
+			return fmt.Errorf("invalid back reference")
+		}
+		t.out.WriteString(`\` + string(e))
+		t.backrefs = true
+	case e == '<' || e == '>':
+		t.out.WriteString(`\b`)
+		return nil
+	case e == '`':
+		t.out.WriteString(`\A`)
+		return nil
+	case e == '\'':
+		t.out.WriteString(`\z`)
+		return nil
+	case e == 'b' || e == 'B':
+		t.out.WriteString(`\` + string(e))
+		return nil
+	case strings.IndexByte("wWsSdD", e) >= 0:
+		// GNU's \w \W \s \S, and Perl's \d \D as an extension
+		t.out.WriteString(`\` + string(e))
+	case e == 'n':
+		t.out.WriteString(`\n`)
+	case e == 't':
+		t.out.WriteString(`\t`)
+	default:
+		// Anything else stands for itself
+		t.literal(e)
+		return nil
+	}
+	t.atStart = false
+	return nil
+}
+
+// bracket copies a bracket expression, with t.pos just past the [. In POSIX
+// a backslash is literal inside brackets and a ] right after the opening [
+// or [^ is a member rather than the end.
+func (t *translator) bracket() error {
+	start := t.pos - 1
+	t.out.WriteByte('[')
+	if t.pos < len(t.pattern) && t.pattern[t.pos] == '^' {
+		t.out.WriteByte('^')
+		t.pos++
+	}
+	first := true
+	for {
+		if t.pos >= len(t.pattern) {
+			return fmt.Errorf("unmatched [ in %q", t.pattern[start:])
+		}
+		c := t.pattern[t.pos]
+		t.pos++
+
+		switch {
+		case c == ']' && !first:
+			t.out.WriteByte(']')
+			t.atStart = false
+			return nil
+		case c == '[' && t.pos < len(t.pattern) && strings.IndexByte(":=.", t.pattern[t.pos]) >= 0:
+			kind := t.pattern[t.pos]
+			end := strings.Index(t.pattern[t.pos+1:], string(kind)+"]")
+			if end < 0 {
+				return fmt.Errorf("unmatched [ in %q", t.pattern[start:])
+			}
+			name := t.pattern[t.pos+1 : t.pos+1+end]
+			if kind != ':' {
+				return fmt.Errorf("collating elements and equivalence classes are not supported: [%c%s%c]", kind, name, kind)
+			}
+			class, ok := posixClasses[name]
+			if !ok {
+				return fmt.Errorf("invalid character class: %s", name)
+			}
+			t.out.WriteString(class)
+			t.pos += end + 3
+		case c == '\\' || c == '[' || c == ']':
+			t.out.WriteString(`\` + string(c))
+		default:
+			t.out.WriteByte(c)
+		}
+		first = false
+	}
+}
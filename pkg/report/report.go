@@ -0,0 +1,92 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// Record describes what a file command did to one file, for --report json
+type Record struct {
+	Action      string  `json:"action"` // copy, mkdir, move, remove or skip
+	Source      string  `json:"source"`
+	Destination string  `json:"destination,omitempty"`
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"duration_seconds"`
+	Error       string  `json:"error,omitempty"`
+	DryRun      bool    `json:"dry_run,omitempty"`
+}
+
+// Writer writes records as JSON lines. It is safe for concurrent use, and
+// a nil *Writer writes nothing, which is how commands run without
+// --report.
+type Writer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// New returns a Writer for a --report format: nil for none, or one writing
+// JSON lines to standard output. Planned operations of a dry run then go
+// to standard error so that standard output holds only records.
+func New(format string) (*Writer, error) {
+	switch format {
+	case "":
+		return nil, nil
+	case "json":
+		dryrun.Output = os.Stderr
+		return NewTo(output.Stdout), nil
+	}
+	return nil, fmt.Errorf("invalid --report format %q: expected json", format)
+}
+
+// NewTo returns a Writer of JSON lines to w
+func NewTo(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+// Enabled reports whether records are written
+func (w *Writer) Enabled() bool {
+	return w != nil
+}
+
+// Op is an operation under way, timed from Start until Done
+type Op struct {
+	w      *Writer
+	record Record
+	start  time.Time
+}
+
+// Start begins timing an action on source, copied or moved to destination
+func (w *Writer) Start(action, source, destination string) *Op {
+	if w == nil {
+		return nil
+	}
+	return &Op{w: w, record: Record{Action: action, Source: source, Destination: destination}, start: time.Now()}
+}
+
+// Done writes the record of op, with the bytes it processed and the error
+// it ended with, if any
+func (op *Op) Done(bytes int64, err error) {
+	if op == nil {
+		return
+	}
+	op.record.Bytes = bytes
+	op.record.Seconds = time.Since(op.start).Seconds()
+	if err != nil {
+		op.record.Error = err.Error()
+	}
+	op.record.DryRun = dryrun.Enabled
+	op.w.write(op.record)
+}
+
+func (w *Writer) write(r Record) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.enc.Encode(r)
+}
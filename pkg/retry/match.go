@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// matchWriter passes output through to w while looking for a line that
+// matches re. A line split across writes is joined up before matching.
+type matchWriter struct {
+	w       io.Writer
+	re      *regexp.Regexp
+	partial []byte // the start of a line not ended yet
+	matched bool
+}
+
+// newMatchWriter returns a matchWriter writing to w
+func newMatchWriter(w io.Writer, re *regexp.Regexp) *matchWriter {
+	return &matchWriter{w: w, re: re}
+}
+
+// Write writes p to w and matches the lines it ends
+func (m *matchWriter) Write(p []byte) (int, error) {
+	n, err := m.w.Write(p)
+	if m.matched {
+		return n, err
+	}
+	data := p[:n]
+	for len(data) > 0 && !m.matched {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			m.partial = append(m.partial, data...)
+			break
+		}
+		line := data[:i]
+		if len(m.partial) > 0 {
+			line = append(m.partial, line...)
+			m.partial = m.partial[:0]
+		}
+		m.matched = m.re.Match(line)
+		data = data[i+1:]
+	}
+	return n, err
+}
+
+// done reports whether a line matched, matching a last line without a
+// newline as well
+func (m *matchWriter) done() bool {
+	if !m.matched && len(m.partial) > 0 {
+		m.matched = m.re.Match(m.partial)
+		m.partial = nil
+	}
+	return m.matched
+}
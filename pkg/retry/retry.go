@@ -0,0 +1,274 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// Exit statuses of retry itself, as limit and GNU timeout use them
+const (
+	statusTimeout  = 124 // --timeout ran out on the last attempt
+	statusFailed   = 125 // retry could not run the command
+	statusNotFound = 127 // the command wasn't found
+)
+
+// Options holds retry configuration
+type Options struct {
+	Attempts     int    // runs at most, the first included
+	Delay        string // wait after the first failure
+	Backoff      string // fixed, linear or exp
+	MaxDelay     string // longest wait; empty for no cap
+	Timeout      string // wall-clock time of each attempt
+	SuccessCodes []int  // exit statuses that count as success
+	Until        string // regexp the output must match to succeed
+}
+
+// Policy is the parsed configuration
+type Policy struct {
+	Attempts     int
+	Delay        time.Duration
+	Backoff      string
+	MaxDelay     time.Duration
+	Timeout      time.Duration
+	SuccessCodes []int
+	Until        *regexp.Regexp
+}
+
+// Command returns the retry command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "retry [flags] -- command [args...]",
+		Short: "Run a command again until it succeeds",
+		Long: `Run a command, and run it again when it fails, waiting longer between
+attempts, up to --attempts times in all. It replaces shell loops around
+flaky steps of CI scripts, such as downloads and service checks.
+
+An attempt succeeds when its exit status is one of --success-code, 0
+unless given, and, with --until, a line of its output (standard output
+or standard error) matches the regular expression. Output is passed
+through as it comes, so every attempt's output is shown.
+
+The wait after the first failure is --delay. --backoff says how it grows:
+fixed keeps it, linear adds --delay after each failure and exp doubles it.
+--max-delay caps it. --timeout kills an attempt that runs too long, which
+then counts as failed. Durations are Go durations (500ms, 30s, 2m) or
+whole seconds.
+
+The exit status is 0 once an attempt succeeds. When none does, it is that
+of the last attempt: its exit status, 128 plus the signal that ended it,
+124 if it timed out, or 1 if it exited 0 without the output --until
+wants. It is 125 if retry itself failed and 127 if the command wasn't
+found, which is not retried.
+
+Examples:
+  retry --attempts 5 --backoff exp --max-delay 30s -- curl -fsS https://example.com/health
+  retry --delay 2s --until 'database system is ready' -- docker logs db
+  retry --success-code 0 --success-code 3 -- ./check.sh`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policy, err := parsePolicy(opts)
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+			cmd.SilenceErrors = true
+			status, err := run(cmd.Context(), args, policy)
+			if err != nil {
+				return err
+			}
+			if status != 0 {
+				return exitcode.Status(status)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.Attempts, "attempts", 3, "Run the command at most `N` times")
+	cmd.Flags().StringVar(&opts.Delay, "delay", "1s", "Wait `DURATION` after the first failure")
+	cmd.Flags().StringVar(&opts.Backoff, "backoff", "exp", "How the wait grows: fixed, linear or exp")
+	cmd.Flags().StringVar(&opts.MaxDelay, "max-delay", "", "Never wait longer than `DURATION`")
+	cmd.Flags().StringVar(&opts.Timeout, "timeout", "", "Kill an attempt after `DURATION` and count it as failed")
+	cmd.Flags().IntSliceVar(&opts.SuccessCodes, "success-code", []int{0}, "Exit `STATUS` that counts as success (repeatable)")
+	cmd.Flags().StringVar(&opts.Until, "until", "", "Succeed only when a line of output matches `REGEX`")
+
+	return cmd
+}
+
+// parsePolicy checks and converts opts
+func parsePolicy(opts *Options) (*Policy, error) {
+	p := &Policy{Attempts: opts.Attempts, Backoff: opts.Backoff, SuccessCodes: opts.SuccessCodes}
+	if p.Attempts < 1 {
+		return nil, fmt.Errorf("invalid --attempts %d", opts.Attempts)
+	}
+	switch p.Backoff {
+	case "fixed", "linear", "exp":
+	default:
+		return nil, fmt.Errorf("invalid --backoff %q (want fixed, linear or exp)", opts.Backoff)
+	}
+	if len(p.SuccessCodes) == 0 {
+		p.SuccessCodes = []int{0}
+	}
+
+	var err error
+	if p.Delay, err = parseTime(opts.Delay); err != nil {
+		return nil, fmt.Errorf("invalid --delay %q: %w", opts.Delay, err)
+	}
+	if opts.MaxDelay != "" {
+		if p.MaxDelay, err = parseTime(opts.MaxDelay); err != nil {
+			return nil, fmt.Errorf("invalid --max-delay %q: %w", opts.MaxDelay, err)
+		}
+	}
+	if opts.Timeout != "" {
+		if p.Timeout, err = parseTime(opts.Timeout); err != nil || p.Timeout == 0 {
+			return nil, fmt.Errorf("invalid --timeout %q: must be a positive duration", opts.Timeout)
+		}
+	}
+	if opts.Until != "" {
+		if p.Until, err = regexp.Compile(opts.Until); err != nil {
+			return nil, fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// parseTime parses a Go duration or a whole number of seconds, which may
+// be zero but not negative
+func parseTime(value string) (time.Duration, error) {
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		value = strconv.FormatUint(n, 10) + "s"
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New("not a duration such as 30s or 2m")
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
+
+// wait returns how long to wait after the nth failed attempt, counting
+// from 1
+func (p *Policy) wait(n int) time.Duration {
+	d := p.Delay
+	switch p.Backoff {
+	case "linear":
+		d *= time.Duration(n)
+	case "exp":
+		for i := 1; i < n && d < math.MaxInt64/2 && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+			d *= 2
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// run runs args until an attempt succeeds or the attempts run out, and
+// returns the status retry exits with. The error is only set when ctx
+// was canceled.
+func run(ctx context.Context, args []string, p *Policy) (int, error) {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		logging.PathError("Cannot run", args[0], err)
+		return statusNotFound, nil
+	}
+
+	status := 0
+	for n := 1; n <= p.Attempts; n++ {
+		var reason string
+		status, reason = attempt(ctx, args, p)
+		if err := ctx.Err(); err != nil {
+			return status, err
+		}
+		if reason == "" || status == statusFailed {
+			return status, nil
+		}
+		if n == p.Attempts {
+			logging.Error(fmt.Sprintf("%s: attempt %d/%d %s; giving up", args[0], n, p.Attempts, reason))
+			break
+		}
+
+		d := p.wait(n)
+		logging.Info(fmt.Sprintf("%s: attempt %d/%d %s; retrying in %s", args[0], n, p.Attempts, reason, d))
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	return status, nil
+}
+
+// attempt runs args once and returns its status and, if it didn't
+// succeed, why not
+func attempt(ctx context.Context, args []string, p *Policy) (int, string) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.WaitDelay = time.Second
+	var stdout, stderr *matchWriter
+	if p.Until != nil {
+		stdout, stderr = newMatchWriter(os.Stdout, p.Until), newMatchWriter(os.Stderr, p.Until)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	}
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return statusTimeout, "timed out after " + p.Timeout.String()
+	}
+
+	status := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		status = exitErr.ExitCode()
+		if status < 0 {
+			return 128 + signal(exitErr), exitErr.Error()
+		}
+	case err != nil:
+		logging.PathError("Failed to run", args[0], err)
+		return statusFailed, err.Error()
+	}
+
+	if !slices.Contains(p.SuccessCodes, status) {
+		return status, fmt.Sprintf("exited with status %d", status)
+	}
+	if p.Until != nil && !stdout.done() && !stderr.done() {
+		if status == 0 {
+			status = 1
+		}
+		return status, "output didn't match --until"
+	}
+	return status, ""
+}
+
+// signal returns the number of the signal that ended the command, or 0
+// where the system doesn't say
+func signal(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(interface{ Signal() syscall.Signal }); ok {
+		return int(status.Signal())
+	}
+	return 0
+}
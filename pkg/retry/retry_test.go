@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParsePolicy tests defaults, durations in seconds and invalid values
+func TestParsePolicy(t *testing.T) {
+	p, err := parsePolicy(&Options{Attempts: 5, Delay: "2", Backoff: "exp", MaxDelay: "30s", Until: "ready"})
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, p.Delay)
+	assert.Equal(t, 30*time.Second, p.MaxDelay)
+	assert.Equal(t, []int{0}, p.SuccessCodes)
+	assert.NotNil(t, p.Until)
+
+	for _, opts := range []Options{
+		{Attempts: 0, Delay: "1s", Backoff: "exp"},
+		{Attempts: 3, Delay: "-1s", Backoff: "exp"},
+		{Attempts: 3, Delay: "1s", Backoff: "random"},
+		{Attempts: 3, Delay: "1s", Backoff: "fixed", Timeout: "0"},
+		{Attempts: 3, Delay: "1s", Backoff: "fixed", Until: "("},
+	} {
+		_, err := parsePolicy(&opts)
+		assert.Error(t, err, "%+v", opts)
+	}
+}
+
+// TestPolicy_Wait tests how each backoff grows and that --max-delay caps it
+func TestPolicy_Wait(t *testing.T) {
+	waits := func(p Policy) []time.Duration {
+		var d []time.Duration
+		for n := 1; n <= 5; n++ {
+			d = append(d, p.wait(n))
+		}
+		return d
+	}
+	s := time.Second
+	assert.Equal(t, []time.Duration{s, s, s, s, s}, waits(Policy{Delay: s, Backoff: "fixed"}))
+	assert.Equal(t, []time.Duration{s, 2 * s, 3 * s, 4 * s, 5 * s}, waits(Policy{Delay: s, Backoff: "linear"}))
+	assert.Equal(t, []time.Duration{s, 2 * s, 4 * s, 8 * s, 10 * s}, waits(Policy{Delay: s, Backoff: "exp", MaxDelay: 10 * s}))
+	assert.Positive(t, (&Policy{Delay: s, Backoff: "exp"}).wait(200))
+}
+
+// TestMatchWriter tests matching lines split across writes and a last
+// line without a newline
+func TestMatchWriter(t *testing.T) {
+	var out strings.Builder
+	m := newMatchWriter(&out, regexp.MustCompile(`^ready$`))
+	m.Write([]byte("starting\nrea"))
+	assert.False(t, m.matched)
+	m.Write([]byte("dy\n"))
+	assert.True(t, m.done())
+	assert.Equal(t, "starting\nready\n", out.String())
+
+	m = newMatchWriter(&out, regexp.MustCompile(`ready`))
+	m.Write([]byte("not yet\nready"))
+	assert.True(t, m.done())
+}
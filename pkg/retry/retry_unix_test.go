@@ -0,0 +1,63 @@
+//go:build unix
+
+package retry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRun tests that a command is run again until it succeeds, and that
+// the last status is returned when it never does
+func TestRun(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	// Fails twice, then succeeds
+	script := `echo x >> "$0"; [ $(wc -l < "$0") -ge 3 ]`
+	p := &Policy{Attempts: 5, Backoff: "fixed", SuccessCodes: []int{0}}
+
+	status, err := run(context.Background(), []string{"sh", "-c", script, counter}, p)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status)
+	data, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "x\nx\nx\n", string(data))
+
+	p.Attempts = 2
+	status, err = run(context.Background(), []string{"sh", "-c", "exit 7"}, p)
+	require.NoError(t, err)
+	assert.Equal(t, 7, status)
+
+	p.SuccessCodes = []int{0, 7}
+	status, err = run(context.Background(), []string{"sh", "-c", "exit 7"}, p)
+	require.NoError(t, err)
+	assert.Equal(t, 7, status)
+}
+
+// TestRun_Until tests that output must match --until, and that a timed
+// out attempt counts as failed
+func TestRun_Until(t *testing.T) {
+	p := &Policy{Attempts: 2, Backoff: "fixed", SuccessCodes: []int{0}, Until: regexp.MustCompile("^ready")}
+	status, err := run(context.Background(), []string{"sh", "-c", "echo starting"}, p)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status)
+
+	status, err = run(context.Background(), []string{"sh", "-c", "echo ready >&2"}, p)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status)
+
+	p = &Policy{Attempts: 1, Backoff: "fixed", SuccessCodes: []int{0}, Timeout: 50 * time.Millisecond}
+	status, err = run(context.Background(), []string{"sleep", "5"}, p)
+	require.NoError(t, err)
+	assert.Equal(t, statusTimeout, status)
+
+	status, err = run(context.Background(), []string{"no-such-command-here"}, p)
+	require.NoError(t, err)
+	assert.Equal(t, statusNotFound, status)
+}
@@ -2,11 +2,19 @@ package rm
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+	"github.com/evalgo-org/claude-tools/pkg/report"
 )
 
 // Options holds rm configuration
@@ -14,6 +22,9 @@ type Options struct {
 	Recursive bool
 	Force     bool
 	Verbose   bool
+	Report    string // "json" to print a record of each file removed
+
+	report *report.Writer
 }
 
 // Command returns the rm command
@@ -28,22 +39,46 @@ func Command() *cobra.Command {
 By default, rm does not remove directories. Use -r to remove directories
 and their contents recursively.
 
+--report json prints a JSON object per line for each file and directory
+removed, contents before their directory, with the action ("remove"),
+source, bytes freed, duration in seconds and any error, in place of -v
+output.
+
 WARNING: Deleted files cannot be recovered. Use with caution.`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			for _, path := range args {
+			paths := glob.Expand(args)
+
+			rep, err := report.New(opts.Report)
+			if err != nil {
+				return exitcode.New(2, err)
+			}
+			opts.report = rep
+
+			var bar *progress.Bar
+			if progress.Shown(opts.Verbose) {
+				bar = progress.New("rm", progress.Items, int64(len(paths)))
+				defer bar.Finish()
+			}
+
+			for _, path := range paths {
+				if err := cmd.Context().Err(); err != nil {
+					return err
+				}
+				bar.Describe(path)
+
 				if err := removePath(path, opts); err != nil {
 					if !opts.Force {
-						eve.Logger.Error("Failed to remove", path, ":", err)
 						return err
 					}
 					// With -f, continue on errors
 					if opts.Verbose {
-						eve.Logger.Warn("Failed to remove", path, ":", err)
+						logging.PathWarn("Failed to remove", path, err)
 					}
-				} else if opts.Verbose {
+				} else if opts.Verbose && !dryrun.Enabled && !opts.report.Enabled() {
 					fmt.Printf("removed '%s'\n", path)
 				}
+				bar.Add(1)
 			}
 
 			return nil
@@ -53,6 +88,7 @@ WARNING: Deleted files cannot be recovered. Use with caution.`,
 	cmd.Flags().BoolVarP(&opts.Recursive, "recursive", "r", false, "Remove directories and their contents recursively")
 	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Ignore nonexistent files and never prompt")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Explain what is being done")
+	cmd.Flags().StringVar(&opts.Report, "report", "", "Print a record of each file removed in `FORMAT` (json)")
 
 	return cmd
 }
@@ -69,13 +105,24 @@ func removePath(path string, opts *Options) error {
 			// With -f, nonexistent files are not an error
 			return nil
 		}
-		return fmt.Errorf("failed to stat '%s': %w", path, err)
+		err = fmt.Errorf("failed to stat '%s': %w", path, err)
+		opts.report.Start("remove", path, "").Done(0, err)
+		return err
 	}
 
 	// Check if it's a directory
 	if info.IsDir() {
 		if !opts.Recursive {
-			return fmt.Errorf("cannot remove '%s': Is a directory (use -r to remove directories)", path)
+			err := fmt.Errorf("cannot remove '%s': Is a directory (use -r to remove directories)", path)
+			opts.report.Start("remove", path, "").Done(0, err)
+			return err
+		}
+
+		if dryrun.Enabled {
+			return reportTree(path, opts)
+		}
+		if opts.report.Enabled() {
+			return removeTree(path, opts)
 		}
 
 		// Remove directory recursively
@@ -83,11 +130,96 @@ func removePath(path string, opts *Options) error {
 			return fmt.Errorf("failed to remove directory '%s': %w", path, err)
 		}
 	} else {
+		op := opts.report.Start("remove", path, "")
+		if dryrun.Enabled {
+			dryrun.Report("remove '%s'", path)
+			op.Done(fileSize(info), nil)
+			return nil
+		}
+
 		// Remove file
 		if err := os.Remove(path); err != nil {
-			return fmt.Errorf("failed to remove '%s': %w", path, err)
+			err = fmt.Errorf("failed to remove '%s': %w", path, err)
+			op.Done(0, err)
+			return err
 		}
+		op.Done(fileSize(info), nil)
 	}
 
 	return nil
 }
+
+// treeEntry is a file or directory in a tree being removed
+type treeEntry struct {
+	path string
+	dir  bool
+	size int64
+}
+
+// listTree returns the entries of the tree at root, contents before
+// their directory, in the order os.RemoveAll removes them
+func listTree(root string) ([]treeEntry, error) {
+	var entries []treeEntry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		entry := treeEntry{path: path, dir: d.IsDir()}
+		if info, err := d.Info(); err == nil {
+			entry.size = fileSize(info)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", root, err)
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// reportTree reports the removal of a directory tree, contents first, as
+// os.RemoveAll would perform it
+func reportTree(root string, opts *Options) error {
+	entries, err := listTree(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.dir {
+			dryrun.Report("remove directory '%s'", entry.path)
+		} else {
+			dryrun.Report("remove '%s'", entry.path)
+		}
+		opts.report.Start("remove", entry.path, "").Done(entry.size, nil)
+	}
+	return nil
+}
+
+// removeTree removes a directory tree an entry at a time, contents first,
+// so that --report has a record of each
+func removeTree(root string, opts *Options) error {
+	entries, err := listTree(root)
+	if err != nil {
+		opts.report.Start("remove", root, "").Done(0, err)
+		return err
+	}
+	for _, entry := range entries {
+		op := opts.report.Start("remove", entry.path, "")
+		if err := os.Remove(entry.path); err != nil {
+			err = fmt.Errorf("failed to remove '%s': %w", entry.path, err)
+			op.Done(0, err)
+			return err
+		}
+		op.Done(entry.size, nil)
+	}
+	return nil
+}
+
+// fileSize returns the bytes a regular file holds, and 0 for anything else
+func fileSize(info fs.FileInfo) int64 {
+	if info.Mode().IsRegular() {
+		return info.Size()
+	}
+	return 0
+}
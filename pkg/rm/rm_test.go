@@ -1,12 +1,18 @@
 package rm
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/report"
 )
 
 // TestRemovePath_File tests removing a single file
@@ -210,3 +216,55 @@ func TestRemovePath_Symlink(t *testing.T) {
 	_, err = os.Stat(targetFile)
 	assert.NoError(t, err)
 }
+
+// TestRemovePath_DryRun tests that --dry-run lists a directory's contents
+// before the directory and leaves everything in place
+func TestRemovePath_DryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	testDir := filepath.Join(tempDir, "dir")
+	require.NoError(t, os.Mkdir(testDir, 0755))
+	testFile := filepath.Join(testDir, "file.txt")
+	require.NoError(t, os.WriteFile(testFile, []byte("x"), 0644))
+
+	var out bytes.Buffer
+	dryrun.Enabled, dryrun.Output = true, &out
+	defer func() { dryrun.Enabled, dryrun.Output = false, os.Stdout }()
+
+	err := removePath(testDir, &Options{Recursive: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "would remove '"+testFile+"'\nwould remove directory '"+testDir+"'\n", out.String())
+	assert.FileExists(t, testFile)
+}
+
+// TestRemovePath_Report tests that --report records every file and
+// directory of a tree, contents before their directory
+func TestRemovePath_Report(t *testing.T) {
+	tempDir := t.TempDir()
+	testDir := filepath.Join(tempDir, "testdir")
+	require.NoError(t, os.MkdirAll(filepath.Join(testDir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "sub", "a.txt"), []byte("hello"), 0644))
+
+	var out bytes.Buffer
+	opts := &Options{Recursive: true, report: report.NewTo(&out)}
+	require.NoError(t, removePath(testDir, opts))
+	assert.NoDirExists(t, testDir)
+
+	var removed []string
+	var freed int64
+	for line := range strings.Lines(out.String()) {
+		var r report.Record
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		assert.Equal(t, "remove", r.Action)
+		assert.Empty(t, r.Error)
+		removed = append(removed, r.Source)
+		freed += r.Bytes
+	}
+	assert.Equal(t, []string{filepath.Join(testDir, "sub", "a.txt"), filepath.Join(testDir, "sub"), testDir}, removed)
+	assert.Equal(t, int64(5), freed)
+
+	// Failures are recorded too
+	out.Reset()
+	assert.Error(t, removePath(testDir, &Options{report: report.NewTo(&out)}))
+	assert.Contains(t, out.String(), `"error":"failed to stat`)
+}
@@ -1,15 +1,22 @@
 package sed
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/regex"
 )
 
 // Options holds sed configuration
@@ -31,6 +38,10 @@ func Command() *cobra.Command {
 		Long: `Stream editor for filtering and transforming text.
 Supports basic sed commands with simplified syntax.
 
+Patterns are POSIX basic regular expressions unless -E or -r selects
+extended ones. In a replacement, & stands for the matched text and \1 to
+\9 for groups.
+
 Commands:
   s/pattern/replacement/[g]  Substitute
   /pattern/d                 Delete matching lines
@@ -46,15 +57,16 @@ Examples:
   sed -n '/pattern/p' file.txt       Print only matching lines`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 			opts.Expression = args[0]
-			files := args[1:]
+			files := glob.Expand(args[1:])
 
 			if len(files) == 0 {
-				return processInput(os.Stdin, opts, "")
+				return processInput(interrupt.Reader(ctx, os.Stdin), opts, "")
 			}
 
 			for _, file := range files {
-				if err := processFile(file, opts); err != nil {
+				if err := processFile(ctx, file, opts); err != nil {
 					return err
 				}
 			}
@@ -65,12 +77,20 @@ Examples:
 	cmd.Flags().BoolVarP(&opts.InPlace, "in-place", "i", false, "Edit files in place")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "n", false, "Suppress automatic printing")
 	cmd.Flags().BoolVarP(&opts.Extended, "extended", "E", false, "Use extended regex")
+	cmd.Flags().BoolVarP(&opts.Extended, "regexp-extended", "r", false, "Same as -E")
 
 	return cmd
 }
 
 // processFile processes a file
-func processFile(filename string, opts *Options) error {
+func processFile(ctx context.Context, filename string, opts *Options) error {
+	if input.IsStdin(filename) {
+		if opts.InPlace {
+			return fmt.Errorf("cannot edit standard input in place")
+		}
+		return processInput(interrupt.Reader(ctx, os.Stdin), opts, "")
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
 		return fmt.Errorf("cannot open '%s': %w", filename, err)
@@ -78,19 +98,26 @@ func processFile(filename string, opts *Options) error {
 	defer file.Close()
 
 	if opts.InPlace {
-		return processInPlace(file, filename, opts)
+		return processInPlace(ctx, file, filename, opts)
 	}
 
-	return processInput(file, opts, filename)
+	return processInput(interrupt.Reader(ctx, file), opts, filename)
 }
 
 // processInPlace edits file in place
-func processInPlace(file *os.File, filename string, opts *Options) error {
+func processInPlace(ctx context.Context, file *os.File, filename string, opts *Options) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot stat '%s': %w", filename, err)
+	}
+
 	// Read entire file
-	var lines []string
-	scanner := bufio.NewScanner(file)
+	var input []string
+	scanner := lines.NewReader(interrupt.Reader(ctx, file))
+	terminated := true
 	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+		input = append(input, scanner.Text())
+		terminated = scanner.Terminated()
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -99,29 +126,37 @@ func processInPlace(file *os.File, filename string, opts *Options) error {
 	file.Close()
 
 	// Process lines
-	result, err := processLines(lines, opts)
+	result, err := processLines(input, opts)
 	if err != nil {
 		return err
 	}
 
-	// Write back
-	output, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("cannot write '%s': %w", filename, err)
-	}
-	defer output.Close()
-
-	writer := bufio.NewWriter(output)
-	for _, line := range result {
-		fmt.Fprintln(writer, line)
+	if dryrun.Enabled {
+		if changed(input, result) {
+			dryrun.Report("edit '%s'", filename)
+		}
+		return nil
 	}
 
-	return writer.Flush()
+	return output.WriteFile(ctx, filename, info.Mode(), func(w io.Writer) error {
+		for i, line := range result {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Keep a missing final newline missing
+			if i == len(result)-1 && !terminated {
+				fmt.Fprint(w, line)
+				continue
+			}
+			fmt.Fprintln(w, line)
+		}
+		return nil
+	})
 }
 
 // processInput processes input stream
 func processInput(reader io.Reader, opts *Options, filename string) error {
-	scanner := bufio.NewScanner(reader)
+	scanner := lines.NewReader(reader)
 	lineNum := 0
 
 	for scanner.Scan() {
@@ -134,7 +169,10 @@ func processInput(reader io.Reader, opts *Options, filename string) error {
 		}
 
 		if !skip && !opts.Quiet {
-			fmt.Println(output)
+			if scanner.Terminated() {
+				output += "\n"
+			}
+			fmt.Print(output)
 		}
 	}
 
@@ -180,27 +218,65 @@ type SedCommand interface {
 
 // SubstituteCommand - s/pattern/replacement/flags
 type SubstituteCommand struct {
-	Pattern     *regexp.Regexp
+	Pattern     *regex.Regexp
 	Replacement string
 	Global      bool
 }
 
 func (s *SubstituteCommand) Execute(line string, lineNum int) (string, bool, error) {
+	// Replace only first occurrence unless global
+	n := 1
 	if s.Global {
-		result := s.Pattern.ReplaceAllString(line, s.Replacement)
-		return result, false, nil
+		n = -1
 	}
-	// Replace only first occurrence
-	result := line
-	if loc := s.Pattern.FindStringIndex(line); loc != nil {
-		result = line[:loc[0]] + s.Replacement + line[loc[1]:]
+	matches := s.Pattern.FindAllStringSubmatchIndex(line, n)
+	if matches == nil {
+		return line, false, nil
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, loc := range matches {
+		result.WriteString(line[last:loc[0]])
+		expand(&result, s.Replacement, line, loc)
+		last = loc[1]
+	}
+	result.WriteString(line[last:])
+	return result.String(), false, nil
+}
+
+// expand writes replacement for the match at loc in line, substituting the
+// matched text for & and group n for \n
+func expand(dst *strings.Builder, replacement, line string, loc []int) {
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		switch {
+		case c == '&':
+			dst.WriteString(line[loc[0]:loc[1]])
+		case c == '\\' && i+1 < len(replacement):
+			i++
+			c = replacement[i]
+			switch {
+			case c >= '0' && c <= '9':
+				if n := int(c - '0'); 2*n+1 < len(loc) && loc[2*n] >= 0 {
+					dst.WriteString(line[loc[2*n]:loc[2*n+1]])
+				}
+			case c == 'n':
+				dst.WriteByte('\n')
+			case c == 't':
+				dst.WriteByte('\t')
+			default:
+				dst.WriteByte(c)
+			}
+		default:
+			dst.WriteByte(c)
+		}
 	}
-	return result, false, nil
 }
 
 // DeleteCommand - /pattern/d or [line]d
 type DeleteCommand struct {
-	Pattern    *regexp.Regexp
+	Pattern    *regex.Regexp
 	LineNumber int
 }
 
@@ -219,7 +295,7 @@ func (d *DeleteCommand) Execute(line string, lineNum int) (string, bool, error)
 
 // PrintCommand - /pattern/p or [line]p
 type PrintCommand struct {
-	Pattern    *regexp.Regexp
+	Pattern    *regex.Regexp
 	LineNumber int
 }
 
@@ -284,14 +360,9 @@ func parseSubstitute(expr string, opts *Options) (*SubstituteCommand, error) {
 		flags = parts[2]
 	}
 
-	// Compile regex
-	regexFlags := ""
-	if opts.Extended {
-		regexFlags = "(?m)"
-	}
-	re, err := regexp.Compile(regexFlags + pattern)
+	re, err := compileRegex(pattern, opts)
 	if err != nil {
-		return nil, fmt.Errorf("invalid pattern: %w", err)
+		return nil, err
 	}
 
 	return &SubstituteCommand{
@@ -313,10 +384,9 @@ func parseDelete(expr string, opts *Options) (*DeleteCommand, error) {
 
 	// Pattern delete: /pattern/d
 	if strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
-		pattern := expr[1 : len(expr)-1]
-		re, err := regexp.Compile(pattern)
+		re, err := compileRegex(expr[1:len(expr)-1], opts)
 		if err != nil {
-			return nil, fmt.Errorf("invalid pattern: %w", err)
+			return nil, err
 		}
 		return &DeleteCommand{Pattern: re}, nil
 	}
@@ -336,13 +406,39 @@ func parsePrint(expr string, opts *Options) (*PrintCommand, error) {
 
 	// Pattern print: /pattern/p
 	if strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
-		pattern := expr[1 : len(expr)-1]
-		re, err := regexp.Compile(pattern)
+		re, err := compileRegex(expr[1:len(expr)-1], opts)
 		if err != nil {
-			return nil, fmt.Errorf("invalid pattern: %w", err)
+			return nil, err
 		}
 		return &PrintCommand{Pattern: re}, nil
 	}
 
 	return nil, fmt.Errorf("invalid print command: %s", expr)
 }
+
+// compileRegex compiles a pattern as a basic regular expression, or an
+// extended one with -E
+func compileRegex(pattern string, opts *Options) (*regex.Regexp, error) {
+	syntax := regex.Basic
+	if opts.Extended {
+		syntax = regex.Extended
+	}
+	re, err := regex.Compile(pattern, syntax, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
+
+// changed reports whether editing turned input into different lines
+func changed(input, result []string) bool {
+	if len(input) != len(result) {
+		return true
+	}
+	for i := range input {
+		if input[i] != result[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package sed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProcessFile_InPlaceCRLF tests that -i leaves the CRLF line ends of
+// a file and its missing final newline as they were
+func TestProcessFile_InPlaceCRLF(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "win.txt")
+	require.NoError(t, os.WriteFile(file, []byte("a one\r\nb two\r\na three"), 0644))
+
+	opts := &Options{InPlace: true, Expression: "s/a/A/"}
+	require.NoError(t, processFile(context.Background(), file, opts))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "A one\r\nb two\r\nA three", string(data))
+}
@@ -0,0 +1,163 @@
+package selftest
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// testCase is a command line checked by selftest or timed by bench
+type testCase struct {
+	name  string
+	steps [][]string // commands run in order; only the last is checked and timed
+	stdin string     // standard input of the last step
+	want  string     // expected output of the last step
+	paths bool       // the output lists paths, compared with "/" separators
+}
+
+// corpusFiles are the files generated for selftest, by relative path
+var corpusFiles = map[string]string{
+	"words.txt":       "banana\napple\ncherry\napple\ndate\nElderberry\n",
+	"crlf.txt":        "one\r\ntwo\r\n",
+	"fields.csv":      "name,qty\nbolt,12\nnut,30\nscrew,7\n",
+	"data.json":       `{"name": "claude-tools", "tags": ["cli", "go"], "stars": 42}` + "\n",
+	"src/main.go":     "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+	"src/util.go":     "package main\n\n// TODO: more helpers\nfunc helper() {}\n",
+	"src/sub/deep.go": "package sub\n",
+	"docs/README.md":  "# Docs\n\nTODO: write them\n",
+}
+
+// corpus writes the selftest files to dir
+func corpus(dir string) error {
+	for name, content := range corpusFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testCases returns the selftest cases, in the order they must run: the
+// file operations at the end change the corpus
+func testCases() []testCase {
+	return []testCase{
+		{name: "cat", steps: [][]string{{"cat", "-n", "crlf.txt"}}, want: "     1  one\r\n     2  two\r\n"},
+		{name: "cat/stdin", steps: [][]string{{"cat", "-"}}, stdin: "piped\n", want: "piped\n"},
+		{name: "head", steps: [][]string{{"head", "-n", "2", "words.txt"}}, want: "banana\napple\n"},
+		{name: "tail", steps: [][]string{{"tail", "-n", "2", "words.txt"}}, want: "date\nElderberry\n"},
+		{name: "lines", steps: [][]string{{"lines", "words.txt", "2:3"}}, want: "apple\ncherry\n"},
+		{name: "wc", steps: [][]string{{"wc", "-l"}}, stdin: corpusFiles["words.txt"], want: "       6\n"},
+		{name: "sort", steps: [][]string{{"sort", "-u", "words.txt"}}, want: "Elderberry\napple\nbanana\ncherry\ndate\n"},
+		{name: "sort/numeric", steps: [][]string{{"sort", "-n", "-r"}}, stdin: "9\n100\n25\n", want: "100\n25\n9\n"},
+		{name: "sort/top", steps: [][]string{{"sort", "--top", "1", "words.txt"}}, want: "      2 apple\n"},
+		{name: "uniq", steps: [][]string{{"uniq", "-c"}}, stdin: "a\na\nb\n", want: "      2 a\n      1 b\n"},
+		{name: "grep", steps: [][]string{{"grep", "-n", "apple"}}, stdin: corpusFiles["words.txt"], want: "2:apple\n4:apple\n"},
+		{name: "grep/ignore-case", steps: [][]string{{"grep", "-ci", "^e"}}, stdin: corpusFiles["words.txt"], want: "1\n"},
+		{name: "grep/extended", steps: [][]string{{"grep", "-E", "^(bolt|nut),[0-9]+$"}}, stdin: corpusFiles["fields.csv"], want: "bolt,12\nnut,30\n"},
+		{name: "grep/recursive", steps: [][]string{{"grep", "-rl", "TODO", "."}}, want: "docs/README.md\nsrc/util.go\n", paths: true},
+		{name: "find", steps: [][]string{{"find", "src", "--name", "*.go"}}, want: "src/main.go\nsrc/sub/deep.go\nsrc/util.go\n", paths: true},
+		{name: "find/type", steps: [][]string{{"find", ".", "--type", "d"}}, want: "docs\nsrc\nsrc/sub\n", paths: true},
+		{name: "sed", steps: [][]string{{"sed", "s/a/A/g", "words.txt"}}, want: "bAnAnA\nApple\ncherry\nApple\ndAte\nElderberry\n"},
+		{name: "awk", steps: [][]string{{"awk", "-F", ",", "/^(bolt|nut)/ { print $2, $1 }", "fields.csv"}}, want: "12 bolt\n30 nut\n"},
+		{name: "jq", steps: [][]string{{"jq", "-r", ".tags[1]", "data.json"}}, want: "go\n"},
+		{name: "jq/stdin", steps: [][]string{{"jq", "-c", ".stars"}}, stdin: corpusFiles["data.json"], want: "42\n"},
+		{name: "jq/null-input", steps: [][]string{{"jq", "-nc", "range(2) | {id: .}"}}, stdin: corpusFiles["data.json"], want: "{\"id\":0}\n{\"id\":1}\n"},
+		{name: "dos2unix", steps: [][]string{{"dos2unix"}}, stdin: "x\r\ny\r\n", want: "x\ny\n"},
+		{name: "unix2dos", steps: [][]string{{"unix2dos"}}, stdin: "x\ny\n", want: "x\r\ny\r\n"},
+		{name: "ls", steps: [][]string{{"ls", "src"}}, want: "main.go\nsub\nutil.go\n"},
+		{name: "mkdir", steps: [][]string{{"mkdir", "-p", "new/a/b"}, {"find", "new"}}, want: "new/a\nnew/a/b\n", paths: true},
+		{name: "touch", steps: [][]string{{"touch", "new/a/empty.txt"}, {"wc", "-c", "new/a/empty.txt"}}, want: "       0 new/a/empty.txt\n", paths: true},
+		{name: "cp", steps: [][]string{{"cp", "-r", "src", "copy"}, {"cat", "copy/sub/deep.go"}}, want: "package sub\n"},
+		{name: "mv", steps: [][]string{{"mv", "copy", "moved"}, {"find", ".", "--name", "deep.go"}}, want: "moved/sub/deep.go\nsrc/sub/deep.go\n", paths: true},
+		{name: "rm", steps: [][]string{{"rm", "-r", "moved", "new"}, {"ls"}}, want: "crlf.txt\ndata.json\ndocs\nfields.csv\nsrc\nwords.txt\n"},
+	}
+}
+
+// benchWords are the words bench text lines are made of
+var benchWords = []string{
+	"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel",
+	"india", "juliett", "kilo", "lima", "mike", "november", "oscar", "papa",
+	"error", "warning", "info", "debug", "request", "response", "timeout", "retry",
+}
+
+// benchCorpus writes the bench files to dir: a text file of size lines of
+// random words, size/10 JSON objects one per line, and a tree of size/100
+// small files. The same size always gives the same files.
+func benchCorpus(dir string, size int) error {
+	rng := rand.New(rand.NewSource(1))
+
+	if err := writeLines(filepath.Join(dir, "text.txt"), size, func(w *bufio.Writer, i int) {
+		fmt.Fprintf(w, "%d", rng.Intn(100000))
+		for range 4 + rng.Intn(8) {
+			w.WriteByte(' ')
+			w.WriteString(benchWords[rng.Intn(len(benchWords))])
+		}
+	}); err != nil {
+		return err
+	}
+
+	if err := writeLines(filepath.Join(dir, "data.json"), max(size/10, 1), func(w *bufio.Writer, i int) {
+		fmt.Fprintf(w, `{"id": %d, "name": %q, "score": %d}`, i, benchWords[rng.Intn(len(benchWords))], rng.Intn(1000))
+	}); err != nil {
+		return err
+	}
+
+	for i := range max(size/100, 1) {
+		path := filepath.Join(dir, "tree", fmt.Sprintf("d%02d", i%20), fmt.Sprintf("d%02d", i%7), fmt.Sprintf("f%05d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		line := benchWords[rng.Intn(len(benchWords))] + "\n"
+		if err := os.WriteFile(path, []byte(line), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLines creates path and writes n lines produced by line
+func writeLines(path string, n int, line func(w *bufio.Writer, i int)) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for i := range n {
+		line(w, i)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// benchCases returns the bench cases
+func benchCases() []testCase {
+	return []testCase{
+		{name: "cat", steps: [][]string{{"cat", "text.txt"}}},
+		{name: "head", steps: [][]string{{"head", "-n", "1000", "text.txt"}}},
+		{name: "tail", steps: [][]string{{"tail", "-n", "1000", "text.txt"}}},
+		{name: "wc", steps: [][]string{{"wc", "text.txt"}}},
+		{name: "grep/literal", steps: [][]string{{"grep", "-c", "timeout", "text.txt"}}},
+		{name: "grep/regexp", steps: [][]string{{"grep", "-cE", "^[0-9]+ (error|warning) .*retry$", "text.txt"}}},
+		{name: "grep/ignore-case", steps: [][]string{{"grep", "-ci", "ERROR", "text.txt"}}},
+		{name: "grep/recursive", steps: [][]string{{"grep", "-rc", "alpha", "tree"}}},
+		{name: "sort", steps: [][]string{{"sort", "text.txt"}}},
+		{name: "sort/numeric", steps: [][]string{{"sort", "-n", "text.txt"}}},
+		{name: "uniq", steps: [][]string{{"uniq", "-c", "text.txt"}}},
+		{name: "sed", steps: [][]string{{"sed", "s/error/ERROR/g", "text.txt"}}},
+		{name: "awk", steps: [][]string{{"awk", "/error/ { print $1, $2 }", "text.txt"}}},
+		{name: "jq", steps: [][]string{{"jq", "-r", ".name", "data.json"}}},
+		{name: "find", steps: [][]string{{"find", "tree", "--name", "*.txt"}}},
+		{name: "tree", steps: [][]string{{"tree", "tree"}}},
+		{name: "ls", steps: [][]string{{"ls", "-lR", "tree"}}},
+	}
+}
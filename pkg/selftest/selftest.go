@@ -0,0 +1,359 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/progress"
+)
+
+// Options holds selftest and bench configuration
+type Options struct {
+	Bench     bool    // time larger inputs instead of checking outputs
+	Output    string  // report file, "" for standard output
+	Dir       string  // where to generate the corpus, "" for the temp directory
+	Keep      bool    // keep the corpus after the run
+	Run       string  // only run cases whose name matches this expression
+	Baseline  string  // earlier report to compare timings against
+	Tolerance float64 // percentage a case may be slower than its baseline
+	Runs      int     // bench: runs per case, the median is reported
+	Size      int     // bench: lines in the generated text corpus
+}
+
+// Report is the JSON document written by a run
+type Report struct {
+	Command   string    `json:"command"`
+	Version   string    `json:"version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Dir       string    `json:"dir"`
+	Started   time.Time `json:"started"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Regressed int       `json:"regressed"`
+	Results   []Result  `json:"results"`
+}
+
+// Result is the outcome of one case
+type Result struct {
+	Name       string  `json:"name"`
+	Command    string  `json:"command"`
+	Passed     bool    `json:"passed"`
+	Error      string  `json:"error,omitempty"`
+	Millis     float64 `json:"ms"`
+	Baseline   float64 `json:"baseline_ms,omitempty"`
+	Regression bool    `json:"regression,omitempty"`
+}
+
+// minSlack is how much slower than its baseline any case may be, so that
+// scheduling noise on cases taking a few milliseconds is not reported
+const minSlack = 20 * time.Millisecond
+
+// runner runs claude-tools with args in dir and returns its standard output
+type runner func(ctx context.Context, dir string, args []string, stdin string) ([]byte, error)
+
+// Command returns the selftest command
+func Command() *cobra.Command {
+	return newCommand(false)
+}
+
+// BenchCommand returns the bench command
+func BenchCommand() *cobra.Command {
+	return newCommand(true)
+}
+
+// newCommand builds either the selftest or the bench command
+func newCommand(bench bool) *cobra.Command {
+	opts := &Options{Bench: bench}
+
+	cmd := &cobra.Command{
+		Use:   "selftest [flags]",
+		Short: "Check every tool against known outputs",
+		Long: `Generate a small corpus of files, run each tool on it and compare the
+output with the expected one. A JSON report with the outcome and timing of
+every case is written to standard output or the -o file, and the command
+exits with status 1 if any case failed.
+
+Run it with --dir on the filesystem to be validated, such as a network
+share or a container volume, before relying on the tools in automation.`,
+		Args: cobra.NoArgs,
+	}
+	if bench {
+		cmd.Use = "bench [flags]"
+		cmd.Short = "Time every tool on generated inputs"
+		cmd.Long = `Generate a large corpus of files and time each tool on it, reporting the
+median of --runs runs per case as JSON. Only the exit status of each run is
+checked; use selftest to check outputs.
+
+With --baseline, timings are compared to an earlier report, and cases more
+than --tolerance percent slower are marked as regressions, which makes the
+command exit with status 1.`
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		var filter *regexp.Regexp
+		if opts.Run != "" {
+			re, err := regexp.Compile(opts.Run)
+			if err != nil {
+				return exitcode.New(2, fmt.Errorf("invalid --run expression: %w", err))
+			}
+			filter = re
+		}
+		if opts.Runs < 1 {
+			return exitcode.New(2, fmt.Errorf("--runs must be at least 1"))
+		}
+
+		var baseline *Report
+		if opts.Baseline != "" {
+			var err error
+			if baseline, err = readReport(opts.Baseline); err != nil {
+				return err
+			}
+		}
+
+		self, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("cannot locate claude-tools: %w", err)
+		}
+
+		dir, err := os.MkdirTemp(opts.Dir, "claude-tools-"+cmd.Name()+"-")
+		if err != nil {
+			return fmt.Errorf("cannot create corpus directory: %w", err)
+		}
+		if opts.Keep {
+			logging.Info("corpus kept in", dir)
+		} else {
+			defer os.RemoveAll(dir)
+		}
+
+		var cases []testCase
+		if opts.Bench {
+			err = benchCorpus(dir, opts.Size)
+			cases = benchCases()
+		} else {
+			err = corpus(dir)
+			cases = testCases()
+		}
+		if err != nil {
+			return fmt.Errorf("cannot generate corpus: %w", err)
+		}
+		if filter != nil {
+			cases = slices.DeleteFunc(cases, func(c testCase) bool { return !filter.MatchString(c.name) })
+		}
+
+		report := &Report{
+			Command: cmd.Name(),
+			Version: cmd.Root().Version,
+			OS:      runtime.GOOS,
+			Arch:    runtime.GOARCH,
+			Dir:     dir,
+			Started: time.Now().UTC(),
+		}
+		if err := runCases(ctx, report, cases, execRunner(self), opts); err != nil {
+			return err
+		}
+		if baseline != nil {
+			compare(report, baseline, opts.Tolerance)
+		}
+
+		if err := writeReport(report, opts.Output); err != nil {
+			return err
+		}
+		for _, r := range report.Results {
+			switch {
+			case !r.Passed:
+				logging.Error(r.Name+":", r.Error)
+			case r.Regression:
+				logging.Warn(fmt.Sprintf("%s: %.1fms, baseline %.1fms", r.Name, r.Millis, r.Baseline))
+			}
+		}
+		if report.Failed > 0 || report.Regressed > 0 {
+			cmd.SilenceErrors = true
+			return exitcode.Status(1)
+		}
+		return nil
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Write the report to `FILE` instead of standard output")
+	cmd.Flags().StringVar(&opts.Dir, "dir", "", "Generate the corpus below `DIR` (default: the temp directory)")
+	cmd.Flags().BoolVar(&opts.Keep, "keep", false, "Keep the generated corpus")
+	cmd.Flags().StringVar(&opts.Run, "run", "", "Only run cases whose name matches `REGEXP`")
+	cmd.Flags().StringVar(&opts.Baseline, "baseline", "", "Compare timings with the report in `FILE`")
+	cmd.Flags().Float64Var(&opts.Tolerance, "tolerance", 50, "Percentage a case may be slower than its baseline")
+	if bench {
+		cmd.Flags().IntVar(&opts.Runs, "runs", 3, "Runs per case")
+		cmd.Flags().IntVar(&opts.Size, "size", 200000, "Lines in the generated text files")
+	} else {
+		opts.Runs = 1
+	}
+
+	return cmd
+}
+
+// execRunner returns a runner starting the claude-tools executable at self.
+// Configuration files, colors, progress and wildcard expansion are turned
+// off so that results only depend on the corpus.
+func execRunner(self string) runner {
+	return func(ctx context.Context, dir string, args []string, stdin string) ([]byte, error) {
+		args = append([]string{"--no-config", "--no-glob", "--color=never", "--progress=never"}, args...)
+		cmd := exec.CommandContext(ctx, self, args...)
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(stdin)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return out, fmt.Errorf("%w: %s", err, msg)
+			}
+			return out, err
+		}
+		return out, nil
+	}
+}
+
+// runCases runs each case and adds its result to report
+func runCases(ctx context.Context, report *Report, cases []testCase, run runner, opts *Options) error {
+	bar := progress.New(report.Command, progress.Items, int64(len(cases)))
+	defer bar.Finish()
+
+	for _, c := range cases {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bar.Describe(c.name)
+
+		r := runCase(ctx, c, report.Dir, run, opts)
+		if r.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, r)
+		bar.Add(1)
+	}
+	return ctx.Err()
+}
+
+// runCase runs the steps of c and checks the output of the last one, unless
+// benchmarking. The reported time is that of the last step, the median of
+// opts.Runs runs.
+func runCase(ctx context.Context, c testCase, dir string, run runner, opts *Options) Result {
+	last := c.steps[len(c.steps)-1]
+	r := Result{Name: c.name, Command: strings.Join(last, " ")}
+
+	for _, step := range c.steps[:len(c.steps)-1] {
+		if _, err := run(ctx, dir, step, ""); err != nil {
+			r.Error = fmt.Sprintf("%s: %v", strings.Join(step, " "), err)
+			return r
+		}
+	}
+
+	var times []time.Duration
+	var out []byte
+	for range opts.Runs {
+		start := time.Now()
+		var err error
+		out, err = run(ctx, dir, last, c.stdin)
+		times = append(times, time.Since(start))
+		if err != nil {
+			r.Error = err.Error()
+			return r
+		}
+	}
+	slices.Sort(times)
+	r.Millis = millis(times[len(times)/2])
+
+	if !opts.Bench {
+		got := string(out)
+		if c.paths {
+			got = strings.ReplaceAll(got, `\`, "/")
+		}
+		if got != c.want {
+			r.Error = fmt.Sprintf("output %q, want %q", got, c.want)
+			return r
+		}
+	}
+	r.Passed = true
+	return r
+}
+
+// compare marks the passed cases that are more than tolerance percent, and
+// minSlack, slower than the case of the same name in baseline
+func compare(report, baseline *Report, tolerance float64) {
+	before := make(map[string]float64, len(baseline.Results))
+	for _, r := range baseline.Results {
+		if r.Passed {
+			before[r.Name] = r.Millis
+		}
+	}
+
+	for i := range report.Results {
+		r := &report.Results[i]
+		ms, ok := before[r.Name]
+		if !ok || !r.Passed {
+			continue
+		}
+		r.Baseline = ms
+		if r.Millis > ms*(1+tolerance/100) && r.Millis-ms > millis(minSlack) {
+			r.Regression = true
+			report.Regressed++
+		}
+	}
+}
+
+// millis converts d to fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// readReport reads a report written by an earlier run
+func readReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read baseline: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// writeReport writes report as indented JSON to path, or standard output
+// if path is empty
+func writeReport(report *Report, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,113 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRunCases tests checking outputs, failed setup steps and stdin
+func TestRunCases(t *testing.T) {
+	cases := []testCase{
+		{name: "ok", steps: [][]string{{"echo", "hi"}}, want: "hi\n"},
+		{name: "wrong", steps: [][]string{{"echo", "bye"}}, want: "hi\n"},
+		{name: "setup", steps: [][]string{{"fail"}, {"echo", "hi"}}, want: "hi\n"},
+		{name: "stdin", steps: [][]string{{"cat"}}, stdin: "piped", want: "piped"},
+		{name: "paths", steps: [][]string{{"echo", `a\b`}}, want: "a/b\n", paths: true},
+	}
+	run := func(ctx context.Context, dir string, args []string, stdin string) ([]byte, error) {
+		switch args[0] {
+		case "echo":
+			return []byte(strings.Join(args[1:], " ") + "\n"), nil
+		case "cat":
+			return []byte(stdin), nil
+		}
+		return nil, errors.New("exit status 1")
+	}
+
+	report := &Report{Command: "selftest", Dir: t.TempDir()}
+	require.NoError(t, runCases(context.Background(), report, cases, run, &Options{Runs: 1}))
+
+	assert.Equal(t, 3, report.Passed)
+	assert.Equal(t, 2, report.Failed)
+	require.Len(t, report.Results, 5)
+	assert.True(t, report.Results[0].Passed)
+	assert.Equal(t, "echo hi", report.Results[0].Command)
+	assert.Contains(t, report.Results[1].Error, `want "hi\n"`)
+	assert.Equal(t, "fail: exit status 1", report.Results[2].Error)
+	assert.True(t, report.Results[3].Passed)
+	assert.True(t, report.Results[4].Passed)
+
+	// Benchmarks only check the exit status
+	report = &Report{Command: "bench", Dir: t.TempDir()}
+	require.NoError(t, runCases(context.Background(), report, cases[:2], run, &Options{Bench: true, Runs: 3}))
+	assert.Equal(t, 2, report.Passed)
+}
+
+// TestCompare tests marking cases slower than their baseline
+func TestCompare(t *testing.T) {
+	baseline := &Report{Results: []Result{
+		{Name: "fast", Passed: true, Millis: 100},
+		{Name: "slow", Passed: true, Millis: 100},
+		{Name: "tiny", Passed: true, Millis: 1},
+		{Name: "failed", Passed: false, Millis: 1},
+	}}
+	report := &Report{Results: []Result{
+		{Name: "fast", Passed: true, Millis: 140},
+		{Name: "slow", Passed: true, Millis: 200},
+		{Name: "tiny", Passed: true, Millis: 5},
+		{Name: "failed", Passed: true, Millis: 100},
+		{Name: "new", Passed: true, Millis: 100},
+	}}
+
+	compare(report, baseline, 50)
+
+	assert.Equal(t, 1, report.Regressed)
+	assert.False(t, report.Results[0].Regression)
+	assert.Equal(t, 100.0, report.Results[0].Baseline)
+	assert.True(t, report.Results[1].Regression)
+	assert.False(t, report.Results[2].Regression, "within the minimum slack")
+	assert.Zero(t, report.Results[3].Baseline)
+	assert.Zero(t, report.Results[4].Baseline)
+}
+
+// TestReport_RoundTrip tests that a written report can be read back as a
+// baseline
+func TestReport_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "linux.json")
+	report := &Report{Command: "bench", Passed: 1, Results: []Result{{Name: "cat", Passed: true, Millis: 1.5}}}
+
+	require.NoError(t, writeReport(report, path))
+	read, err := readReport(path)
+	require.NoError(t, err)
+	assert.Equal(t, report.Results, read.Results)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0644))
+	_, err = readReport(path)
+	assert.Error(t, err)
+}
+
+// TestBenchCorpus tests that the bench corpus only depends on its size
+func TestBenchCorpus(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	require.NoError(t, benchCorpus(a, 500))
+	require.NoError(t, benchCorpus(b, 500))
+
+	for _, name := range []string{"text.txt", "data.json", "tree/d01/d01/f00001.txt"} {
+		want, err := os.ReadFile(filepath.Join(a, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(b, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+
+	text, err := os.ReadFile(filepath.Join(a, "text.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, 500, strings.Count(string(text), "\n"))
+}
@@ -0,0 +1,143 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// complete completes the word before the cursor: a command name where a
+// command starts, a path anywhere else. It has the signature of a
+// liner.WordCompleter.
+func (sh *shell) complete(line string, pos int) (head string, completions []string, tail string) {
+	before := line[:pos]
+	start := wordStart(before)
+	head, prefix, tail := line[:start], unescape(before[start:]), line[pos:]
+
+	var candidates []string
+	if commandPosition(head) && !strings.ContainsAny(prefix, `/\`) {
+		candidates = sh.completeCommand(prefix)
+	} else {
+		candidates = completePath(prefix)
+	}
+
+	for _, c := range candidates {
+		completions = append(completions, escape(c))
+	}
+	return head, completions, tail
+}
+
+// wordStart returns where the word ending at the end of s starts. Escaped
+// spaces belong to the word.
+func wordStart(s string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if strings.IndexByte(" \t|;&<>", s[i]) >= 0 && (i == 0 || s[i-1] != '\\') {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// commandPosition reports whether a word following head is a command name
+func commandPosition(head string) bool {
+	head = strings.TrimRight(head, " \t")
+	return head == "" || strings.HasSuffix(head, "|") || strings.HasSuffix(head, ";") || strings.HasSuffix(head, "&")
+}
+
+// completeCommand returns the built-ins and subcommands starting with
+// prefix, each followed by a space
+func (sh *shell) completeCommand(prefix string) []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for _, c := range sh.root.Commands() {
+		if !c.Hidden {
+			names = append(names, c.Name())
+		}
+	}
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name+" ")
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// completePath returns the paths starting with prefix. Directories end in
+// "/" so completion can continue below them, and files in a space. Hidden
+// entries are only offered once the prefix starts with a dot.
+func completePath(prefix string) []string {
+	dir, base := "", prefix
+	if i := strings.LastIndexAny(prefix, `/`+string(filepath.Separator)); i >= 0 {
+		dir, base = prefix[:i+1], prefix[i+1:]
+	}
+
+	read := dir
+	if read == "" {
+		read = "."
+	}
+	entries, err := os.ReadDir(read)
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if isDir(filepath.Join(read, name), entry) {
+			matches = append(matches, dir+name+"/")
+		} else {
+			matches = append(matches, dir+name+" ")
+		}
+	}
+	return matches
+}
+
+// isDir reports whether an entry is a directory or a link to one
+func isDir(path string, entry os.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err := os.Stat(path)
+		return err == nil && info.IsDir()
+	}
+	return false
+}
+
+// escape backslash-escapes the characters the lexer would otherwise split
+// or expand on, leaving a trailing space in place as the word separator
+func escape(s string) string {
+	body, end := s, ""
+	if strings.HasSuffix(s, " ") {
+		body, end = s[:len(s)-1], " "
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		if strings.IndexByte(escapable, body[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(body[i])
+	}
+	return b.String() + end
+}
+
+// unescape removes backslash escapes from a partly typed word
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(escapable, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,302 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/config"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+)
+
+// Exit statuses set by the shell itself, as in POSIX shells
+const (
+	statusUsage    = 2
+	statusNotFound = 127
+)
+
+// builtin runs inside the shell process, reading and writing the given
+// streams, and returns an exit status
+type builtin func(sh *shell, args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// builtins are the commands that must run in the shell process itself
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"cd":   (*shell).cd,
+		"pwd":  (*shell).pwd,
+		"echo": (*shell).echo,
+		"exit": (*shell).exitBuiltin,
+	}
+}
+
+// shell holds the state of a session
+type shell struct {
+	root    *cobra.Command // the claude-tools command, whose subcommands are run
+	self    string         // executable that runs subcommands
+	aliases map[string]config.Args
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+
+	status int    // exit status of the last pipeline
+	oldDir string // previous directory, for "cd -"
+	exit   bool   // set by the exit builtin
+}
+
+// run parses and runs one line, returning its exit status
+func (sh *shell) run(ctx context.Context, line string) int {
+	l, err := parse(line)
+	if err != nil {
+		fmt.Fprintf(sh.stderr, "shell: %v\n", err)
+		sh.status = statusUsage
+		return sh.status
+	}
+
+	for i, p := range l.pipelines {
+		if i > 0 {
+			// "a && b" runs b only if a succeeded, "a || b" only if it failed
+			switch l.ops[i-1] {
+			case "&&":
+				if sh.status != 0 {
+					continue
+				}
+			case "||":
+				if sh.status == 0 {
+					continue
+				}
+			}
+		}
+		if ctx.Err() != nil || sh.exit {
+			break
+		}
+		sh.status = sh.runPipeline(p)
+	}
+	return sh.status
+}
+
+// stage is one running command of a pipeline
+type stage struct {
+	cmd    *exec.Cmd
+	done   chan int // status of a builtin running in the background
+	status int      // status of a builtin that already finished, or a failed start
+	files  []*os.File
+}
+
+// runPipeline runs the commands of p concurrently, each one's output
+// feeding the next one's input, and returns the status of the last
+func (sh *shell) runPipeline(p *pipeline) int {
+	n := len(p.commands)
+	stages := make([]*stage, n)
+
+	var stdin io.Reader = sh.stdin
+	var pipeIn *os.File
+	for i, c := range p.commands {
+		st := &stage{}
+		stages[i] = st
+
+		// Read from the previous command's pipe; the child gets its own copy
+		// of the read end, so ours is closed once it has started
+		if pipeIn != nil {
+			stdin = pipeIn
+			st.files = append(st.files, pipeIn)
+			pipeIn = nil
+		}
+
+		// Connect this command's output to the next one's input
+		var stdout io.Writer = sh.stdout
+		if i < n-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				fmt.Fprintf(sh.stderr, "shell: %v\n", err)
+				sh.closeFiles(st)
+				return sh.finish(stages[:i], 1)
+			}
+			stdout, pipeIn = w, r
+			st.files = append(st.files, w)
+		}
+
+		st.status = sh.start(c, st, stdin, stdout, sh.stderr, n > 1)
+	}
+	return sh.finish(stages, -1)
+}
+
+// start starts one command. It returns a status for commands that are
+// already done: builtins run in the foreground and commands that failed to
+// start.
+func (sh *shell) start(c *command, st *stage, stdin io.Reader, stdout, stderr io.Writer, inPipeline bool) int {
+	streams := []any{stdin, stdout, stderr}
+	for _, r := range c.redirects {
+		if err := sh.redirect(r, streams, st); err != nil {
+			fmt.Fprintf(sh.stderr, "shell: %v\n", err)
+			sh.closeFiles(st)
+			return 1
+		}
+	}
+	stdin, stdout, stderr = streams[0].(io.Reader), streams[1].(io.Writer), streams[2].(io.Writer)
+
+	args := expand(c.args)
+	if len(args) == 0 {
+		// Only redirections, such as "> file" to truncate a file
+		sh.closeFiles(st)
+		return 0
+	}
+
+	if b, ok := builtins[args[0]]; ok {
+		if !inPipeline {
+			defer sh.closeFiles(st)
+			return b(sh, args[1:], stdin, stdout, stderr)
+		}
+		// Changing the shell's own state from inside a pipeline is ambiguous
+		if args[0] == "cd" || args[0] == "exit" {
+			fmt.Fprintf(stderr, "shell: %s: cannot be used in a pipeline\n", args[0])
+			sh.closeFiles(st)
+			return 1
+		}
+		st.done = make(chan int, 1)
+		go func() {
+			defer sh.closeFiles(st)
+			st.done <- b(sh, args[1:], stdin, stdout, stderr)
+		}()
+		return 0
+	}
+
+	cmd, err := sh.command(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "shell: %s: %v\n", args[0], err)
+		sh.closeFiles(st)
+		return statusNotFound
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(stderr, "shell: %s: %v\n", args[0], err)
+		sh.closeFiles(st)
+		return statusNotFound
+	}
+	// The child holds its own copies of pipe ends and redirected files
+	sh.closeFiles(st)
+	st.cmd = cmd
+	return 0
+}
+
+// finish waits for the started stages and returns the status of the last
+// one, or status if it is not negative
+func (sh *shell) finish(stages []*stage, status int) int {
+	last := 0
+	for _, st := range stages {
+		last = st.status
+		switch {
+		case st.cmd != nil:
+			last = exitStatus(st.cmd.Wait())
+		case st.done != nil:
+			last = <-st.done
+		}
+	}
+	if status >= 0 {
+		return status
+	}
+	return last
+}
+
+// exitStatus converts the result of waiting for a process to a status
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		// Killed by a signal, most likely the user's Ctrl+C
+		return interrupt.ExitCode
+	}
+	return 1
+}
+
+// redirect opens the file of r and puts it in place of a stream
+func (sh *shell) redirect(r redirect, streams []any, st *stage) error {
+	if r.op == ">&" {
+		fd, _ := strconv.Atoi(r.target)
+		streams[r.fd] = streams[fd]
+		return nil
+	}
+
+	var file *os.File
+	var err error
+	switch r.op {
+	case "<":
+		file, err = os.Open(r.target)
+	case ">":
+		file, err = os.Create(r.target)
+	case ">>":
+		file, err = os.OpenFile(r.target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	}
+	if err != nil {
+		return err
+	}
+	st.files = append(st.files, file)
+	streams[r.fd] = file
+	return nil
+}
+
+// closeFiles closes the pipe ends and files a stage was given
+func (sh *shell) closeFiles(st *stage) {
+	for _, f := range st.files {
+		f.Close()
+	}
+	st.files = nil
+}
+
+// command resolves a command name: claude-tools subcommands and configured
+// aliases run through the claude-tools executable, anything else as an
+// external program
+func (sh *shell) command(args []string) (*exec.Cmd, error) {
+	name := args[0]
+	if _, alias := sh.aliases[name]; !alias && !sh.subcommand(name) {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			if filepath.Base(name) == name {
+				return nil, errors.New("command not found")
+			}
+			return nil, err
+		}
+		return exec.Command(path, args[1:]...), nil
+	}
+
+	// The shell has already expanded wildcards, so the command must not
+	// expand what was quoted
+	return exec.Command(sh.self, append([]string{"--no-glob"}, args...)...), nil
+}
+
+// subcommand reports whether name is a claude-tools subcommand
+func (sh *shell) subcommand(name string) bool {
+	for _, c := range sh.root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand expands unquoted wildcards in words, leaving the rest as they are
+func expand(words []word) []string {
+	args := make([]string, 0, len(words))
+	for _, w := range words {
+		if w.glob {
+			args = append(args, glob.ExpandAll([]string{w.text})...)
+		} else {
+			args = append(args, w.text)
+		}
+	}
+	return args
+}
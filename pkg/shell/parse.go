@@ -0,0 +1,210 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// word is a command-line word after quote removal
+type word struct {
+	text string
+	glob bool // has unquoted wildcards, so it is expanded against the file system
+}
+
+// redirect sends one of a command's standard streams to or from a file
+type redirect struct {
+	fd     int    // 0, 1 or 2
+	op     string // "<", ">" or ">>", or ">&" to duplicate another stream
+	target string // file name, or "1"/"2" for ">&"
+}
+
+// command is a simple command with its redirections
+type command struct {
+	args      []word
+	redirects []redirect
+}
+
+// pipeline is a sequence of commands joined by "|"
+type pipeline struct {
+	commands []*command
+}
+
+// list is a sequence of pipelines joined by ";", "&&" or "||"
+type list struct {
+	pipelines []*pipeline
+	ops       []string // ops[i] joins pipelines[i] and pipelines[i+1]
+}
+
+// token is a word or an operator
+type token struct {
+	op   string // operator, or "" for a word
+	word word
+}
+
+// operators lists the recognized operators, longest first so that e.g.
+// "2>&1" is not read as "2>" followed by "&1"
+var operators = []string{"2>&1", "1>&2", "2>>", "&&", "||", ">>", "2>", ">&2", "|", ";", "<", ">", "&"}
+
+// escapable are the characters a backslash escapes outside single quotes.
+// Elsewhere a backslash is literal, so Windows paths such as C:\Users and
+// \\server\share can be typed as they are.
+const escapable = " \t'\"|;&<>#*?[{"
+
+// lex splits a line into words and operators. Quoting follows POSIX shells:
+// single quotes keep everything literal, double quotes allow \" inside.
+func lex(line string) ([]token, error) {
+	var tokens []token
+	var text strings.Builder
+	inWord, glob := false, false
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{word: word{text: text.String(), glob: glob}})
+		}
+		text.Reset()
+		inWord, glob = false, false
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+
+		case c == '#' && !inWord:
+			// A comment runs to the end of the line
+			flush()
+			return tokens, nil
+
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ' quote")
+			}
+			text.WriteString(line[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+
+		case c == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '"' {
+					i++
+				}
+				text.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, fmt.Errorf("unterminated \" quote")
+			}
+			inWord = true
+
+		case c == '\\' && i+1 < len(line) && strings.IndexByte(escapable, line[i+1]) >= 0:
+			i++
+			text.WriteByte(line[i])
+			inWord = true
+
+		default:
+			// "2>" is only an operator at the start of a word
+			if op := operatorAt(line[i:]); op != "" && (!inWord || op[0] != '2' && op[0] != '1') {
+				flush()
+				tokens = append(tokens, token{op: op})
+				i += len(op) - 1
+				continue
+			}
+			if strings.IndexByte("*?[{", c) >= 0 {
+				glob = true
+			}
+			text.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return tokens, nil
+}
+
+// operatorAt returns the operator s starts with, if any
+func operatorAt(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// parse parses a command line. An empty line gives an empty list.
+func parse(line string) (*list, error) {
+	tokens, err := lex(line)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &list{}
+	p := &pipeline{}
+	c := &command{}
+
+	// endCommand finishes the current command, which must not be empty
+	endCommand := func(op string) error {
+		if len(c.args) == 0 {
+			if len(c.redirects) == 0 || op == "|" || len(p.commands) > 0 {
+				return fmt.Errorf("syntax error near %q", op)
+			}
+		}
+		p.commands = append(p.commands, c)
+		c = &command{}
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.op {
+		case "":
+			c.args = append(c.args, t.word)
+		case "|":
+			if err := endCommand(t.op); err != nil {
+				return nil, err
+			}
+		case ";", "&&", "||":
+			if err := endCommand(t.op); err != nil {
+				return nil, err
+			}
+			l.pipelines = append(l.pipelines, p)
+			l.ops = append(l.ops, t.op)
+			p = &pipeline{}
+		case "&":
+			return nil, fmt.Errorf("background jobs are not supported")
+		case "2>&1":
+			c.redirects = append(c.redirects, redirect{fd: 2, op: ">&", target: "1"})
+		case "1>&2", ">&2":
+			c.redirects = append(c.redirects, redirect{fd: 1, op: ">&", target: "2"})
+		default: // <, >, >>, 2>, 2>>
+			if i+1 == len(tokens) || tokens[i+1].op != "" {
+				return nil, fmt.Errorf("syntax error: %s needs a file name", t.op)
+			}
+			i++
+			r := redirect{fd: 1, op: strings.TrimPrefix(t.op, "2"), target: tokens[i].word.text}
+			if t.op == "<" {
+				r.fd = 0
+			} else if t.op[0] == '2' {
+				r.fd = 2
+			}
+			c.redirects = append(c.redirects, r)
+		}
+	}
+
+	// A trailing ";" is allowed, a trailing "|", "&&" or "||" is not
+	if len(c.args) == 0 && len(c.redirects) == 0 && len(p.commands) == 0 {
+		if n := len(l.ops); n > 0 && l.ops[n-1] != ";" {
+			return nil, fmt.Errorf("syntax error: unexpected end of line after %q", l.ops[n-1])
+		}
+		if n := len(l.ops); n > 0 {
+			l.ops = l.ops[:n-1]
+		}
+		return l, nil
+	}
+	if err := endCommand("end of line"); err != nil {
+		return nil, err
+	}
+	l.pipelines = append(l.pipelines, p)
+	return l, nil
+}
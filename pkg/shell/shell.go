@@ -0,0 +1,291 @@
+package shell
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/config"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+)
+
+// Options holds shell configuration
+type Options struct {
+	Command   string // -c: run this line and exit
+	NoHistory bool
+}
+
+// Command returns the shell command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "shell [flags] [script]",
+		Short: "Interactive shell running claude-tools commands",
+		Long: `A minimal portable shell for systems without a usable one.
+
+Lines are split into words like in a POSIX shell, with single and double
+quotes. A backslash only escapes spaces, quotes, wildcards and the
+operators below, so Windows paths can be typed as they are. Unquoted
+wildcards (*, ?, [...], {a,b} and **) are expanded on every platform.
+
+  cmd1 | cmd2        pipe the output of cmd1 into cmd2
+  cmd1 ; cmd2        run cmd2 after cmd1
+  cmd1 && cmd2       run cmd2 if cmd1 succeeded
+  cmd1 || cmd2       run cmd2 if cmd1 failed
+  < file             read standard input from file
+  > file, >> file    write or append standard output to file
+  2> file, 2>&1      redirect standard error
+
+claude-tools commands (grep, ls, ...) are run directly, other names as
+programs found in PATH. The built-ins are cd, pwd, echo and exit.
+
+Interactively, the up and down keys recall earlier lines, which are kept
+across sessions, and Tab completes command names and paths. With a script
+file, -c or a non-terminal input, lines are read and run without a prompt.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			self, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("cannot locate claude-tools: %w", err)
+			}
+			sh := &shell{root: cmd.Root(), self: self, stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+			if !config.Skip(os.Args[1:]) {
+				// Problems with the files were reported before the command ran
+				cfg, _ := config.Load()
+				sh.aliases = cfg.Aliases
+			}
+
+			var status int
+			switch {
+			case cmd.Flags().Changed("command"):
+				status = sh.run(ctx, opts.Command)
+			case len(args) == 1:
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				status, err = sh.script(ctx, file)
+				if err != nil {
+					return err
+				}
+			case color.Terminal(os.Stdin) && color.Terminal(os.Stdout):
+				status, err = sh.interactive(ctx, opts)
+				if err != nil {
+					return err
+				}
+			default:
+				status, err = sh.script(ctx, os.Stdin)
+				if err != nil {
+					return err
+				}
+			}
+
+			if status != 0 {
+				cmd.SilenceErrors = true
+				return exitcode.Status(status)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Command, "command", "c", "", "Run `LINE` and exit with its status")
+	cmd.Flags().BoolVar(&opts.NoHistory, "no-history", false, "Don't read or save the history file")
+
+	return cmd
+}
+
+// script runs the lines read from r until the end or an exit
+func (sh *shell) script(ctx context.Context, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() && !sh.exit && ctx.Err() == nil {
+		sh.run(ctx, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return sh.status, err
+	}
+	if err := ctx.Err(); err != nil {
+		return sh.status, err
+	}
+	return sh.status, nil
+}
+
+// interactive prompts for lines until end of input or an exit
+func (sh *shell) interactive(ctx context.Context, opts *Options) (int, error) {
+	// Ctrl+C belongs to the command in the foreground, which receives it
+	// too, so it must neither stop the shell nor cancel its context. At the
+	// prompt it only discards the line being typed.
+	signal.Reset(os.Interrupt)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		for range sigs {
+		}
+	}()
+
+	state := liner.NewLiner()
+	defer state.Close()
+	state.SetCtrlCAborts(true)
+	state.SetTabCompletionStyle(liner.TabPrints)
+	state.SetWordCompleter(sh.complete)
+
+	history := ""
+	if !opts.NoHistory {
+		history = historyPath()
+		if file, err := os.Open(history); err == nil {
+			state.ReadHistory(file)
+			file.Close()
+		}
+	}
+
+	for !sh.exit {
+		line, err := state.Prompt(sh.prompt())
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(sh.stdout)
+			break
+		}
+		if err != nil {
+			return sh.status, err
+		}
+
+		if strings.TrimSpace(line) != "" {
+			state.AppendHistory(line)
+		}
+		sh.run(ctx, line)
+	}
+
+	if history != "" {
+		if err := saveHistory(state, history); err != nil {
+			fmt.Fprintf(sh.stderr, "shell: cannot save history: %v\n", err)
+		}
+	}
+	return sh.status, nil
+}
+
+// prompt shows the working directory, with the home directory as ~
+func (sh *shell) prompt() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "$ "
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if dir == home {
+			dir = "~"
+		} else if rel, ok := strings.CutPrefix(dir, home+string(filepath.Separator)); ok {
+			dir = "~" + string(filepath.Separator) + rel
+		}
+	}
+	return dir + " $ "
+}
+
+// historyPath returns the history file, next to the user configuration
+func historyPath() string {
+	path, err := config.UserPath()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "history")
+}
+
+// saveHistory writes the history to path
+func saveHistory(state *liner.State, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := state.WriteHistory(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// cd changes the working directory: to the home directory without an
+// argument, and back to the previous one with "-"
+func (sh *shell) cd(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 1 {
+		fmt.Fprintln(stderr, "cd: too many arguments")
+		return 1
+	}
+
+	var dir string
+	switch {
+	case len(args) == 0:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Fprintf(stderr, "cd: %v\n", err)
+			return 1
+		}
+		dir = home
+	case args[0] == "-":
+		if sh.oldDir == "" {
+			fmt.Fprintln(stderr, "cd: no previous directory")
+			return 1
+		}
+		dir = sh.oldDir
+		fmt.Fprintln(stdout, dir)
+	default:
+		dir = args[0]
+	}
+
+	current, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintf(stderr, "cd: %v\n", err)
+		return 1
+	}
+	sh.oldDir = current
+	return 0
+}
+
+// pwd prints the working directory
+func (sh *shell) pwd(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(stderr, "pwd: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, dir)
+	return 0
+}
+
+// echo prints its arguments separated by spaces
+func (sh *shell) echo(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fmt.Fprintln(stdout, strings.Join(args, " "))
+	return 0
+}
+
+// exitBuiltin ends the shell with the given status, or that of the last
+// command
+func (sh *shell) exitBuiltin(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	sh.exit = true
+	if len(args) == 0 {
+		return sh.status
+	}
+	status, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "exit: %s: numeric argument required\n", args[0])
+		return statusUsage
+	}
+	return status
+}
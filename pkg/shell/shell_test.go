@@ -0,0 +1,147 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newShell returns a shell writing to buffers, with grep and ls as
+// subcommands
+func newShell() (*shell, *bytes.Buffer, *bytes.Buffer) {
+	root := &cobra.Command{Use: "claude-tools"}
+	root.AddCommand(&cobra.Command{Use: "grep"}, &cobra.Command{Use: "ls"})
+
+	var stdout, stderr bytes.Buffer
+	return &shell{root: root, stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr}, &stdout, &stderr
+}
+
+// words returns the text of a command's words
+func words(c *command) []string {
+	var texts []string
+	for _, w := range c.args {
+		texts = append(texts, w.text)
+	}
+	return texts
+}
+
+// TestParse_Quoting tests quote removal and which words are globbed
+func TestParse_Quoting(t *testing.T) {
+	l, err := parse(`grep -n 'a b' "c \"d\"" e\ f C:\Users\me *.go '*.md' # comment`)
+	require.NoError(t, err)
+	require.Len(t, l.pipelines, 1)
+
+	c := l.pipelines[0].commands[0]
+	assert.Equal(t, []string{"grep", "-n", "a b", `c "d"`, "e f", `C:\Users\me`, "*.go", "*.md"}, words(c))
+	assert.True(t, c.args[6].glob)
+	assert.False(t, c.args[7].glob)
+}
+
+// TestParse_Operators tests pipelines, lists and redirections
+func TestParse_Operators(t *testing.T) {
+	l, err := parse("cat < in.txt | grep x 2>&1 > out.txt && ls || echo no;")
+	require.NoError(t, err)
+
+	require.Len(t, l.pipelines, 3)
+	assert.Equal(t, []string{"&&", "||"}, l.ops)
+	require.Len(t, l.pipelines[0].commands, 2)
+	assert.Equal(t, []redirect{{fd: 0, op: "<", target: "in.txt"}}, l.pipelines[0].commands[0].redirects)
+	assert.Equal(t, []redirect{{fd: 2, op: ">&", target: "1"}, {fd: 1, op: ">", target: "out.txt"}}, l.pipelines[0].commands[1].redirects)
+
+	// "2>" only redirects at the start of a word
+	l, err = parse("echo a2>b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "a2"}, words(l.pipelines[0].commands[0]))
+}
+
+// TestParse_Errors tests malformed lines
+func TestParse_Errors(t *testing.T) {
+	for _, line := range []string{"| a", "a |", "a &&", "a >", "'open", "a &", "; a"} {
+		_, err := parse(line)
+		assert.Error(t, err, line)
+	}
+
+	l, err := parse("   ")
+	require.NoError(t, err)
+	assert.Empty(t, l.pipelines)
+}
+
+// TestRun_Builtins tests cd, pwd, echo and redirections, which all run in
+// the shell process
+func TestRun_Builtins(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	t.Chdir(dir)
+
+	start, err := os.Getwd()
+	require.NoError(t, err)
+
+	sh, stdout, _ := newShell()
+	ctx := context.Background()
+
+	assert.Equal(t, 0, sh.run(ctx, "echo one > out.txt; echo two >> out.txt"))
+	data, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(data))
+
+	assert.Equal(t, 0, sh.run(ctx, "cd sub && pwd"))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, wd+"\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 0, sh.run(ctx, "cd -"))
+	assert.Equal(t, start+"\n", stdout.String())
+}
+
+// TestRun_Status tests && and || and the status of unknown commands
+func TestRun_Status(t *testing.T) {
+	sh, stdout, stderr := newShell()
+	ctx := context.Background()
+
+	assert.Equal(t, 0, sh.run(ctx, "no-such-command-xyz || echo fallback && echo after"))
+	assert.Equal(t, "fallback\nafter\n", stdout.String())
+	assert.Contains(t, stderr.String(), "no-such-command-xyz: command not found")
+
+	assert.Equal(t, statusNotFound, sh.run(ctx, "no-such-command-xyz && echo skipped"))
+	assert.Equal(t, statusUsage, sh.run(ctx, "echo |"))
+
+	assert.Equal(t, 1, sh.run(ctx, "echo x | cd /"))
+	assert.Equal(t, 3, sh.run(ctx, "exit 3; echo unreachable"))
+	assert.True(t, sh.exit)
+	assert.NotContains(t, stdout.String(), "unreachable")
+}
+
+// TestComplete tests completing commands where a command starts and paths
+// elsewhere
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "src dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "script.sh"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644))
+	t.Chdir(dir)
+
+	sh, _, _ := newShell()
+
+	head, completions, tail := sh.complete("cat x | gr", 10)
+	assert.Equal(t, "cat x | ", head)
+	assert.Equal(t, []string{"grep "}, completions)
+	assert.Empty(t, tail)
+
+	head, completions, _ = sh.complete("ls s", 4)
+	assert.Equal(t, "ls ", head)
+	assert.Equal(t, []string{"script.sh ", `src\ dir/`}, completions)
+
+	_, completions, _ = sh.complete(`ls src\ d`, 9)
+	assert.Equal(t, []string{`src\ dir/`}, completions)
+
+	_, completions, _ = sh.complete("ls .h", 5)
+	assert.Equal(t, []string{".hidden "}, completions)
+}
@@ -0,0 +1,46 @@
+package sort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+)
+
+// checkFile checks that file is sorted, as -c does, returning status 1
+// with the first line out of order. With -u a line the same as the one
+// before it is out of order too. Only as much of the file is read as it
+// takes to find that line.
+func checkFile(ctx context.Context, file string, opts *Options) error {
+	var r io.Reader = os.Stdin
+	if !input.IsStdin(file) {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	keys := orderKeys(opts)
+	coll := opts.newCollator()
+	scanner := newLineReader(ctx, r, opts)
+	var prev sortKey
+	for n := 1; scanner.Scan(); n++ {
+		cur := newSortKey(scanner.Text(), keys, opts, coll)
+		if n > 1 {
+			c := compareLines(&prev, &cur, keys, opts)
+			if c > 0 || (opts.Unique && uniqueKey(prev.line, opts) == uniqueKey(cur.line, opts)) {
+				return exitcode.New(1, fmt.Errorf("%s:%d: disorder: %s", file, n, cur.line))
+			}
+		}
+		prev = cur
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	return nil
+}
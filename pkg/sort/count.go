@@ -0,0 +1,174 @@
+package sort
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// countEntry is a distinct line, or key with -k, and how often it occurred
+type countEntry struct {
+	text  string
+	count int
+}
+
+// counter tallies lines for --count and --top in a single pass, without
+// keeping or sorting the lines themselves
+type counter struct {
+	opts    *Options
+	index   map[string]int // compared key -> position in entries
+	entries []countEntry
+}
+
+func newCounter(opts *Options) *counter {
+	return &counter{opts: opts, index: map[string]int{}}
+}
+
+// add counts a line. With -k the key is what is counted and printed, the
+// keys joined by the field separator if there are several, and with -f
+// lines that differ only in case count together, printed as the first of
+// them was.
+func (c *counter) add(line string) {
+	text := line
+	if keys := c.opts.sortKeys(); len(keys) > 0 {
+		texts := make([]string, len(keys))
+		for i := range keys {
+			texts[i] = keys[i].text(line, c.opts.FieldSeparator)
+		}
+		text = strings.Join(texts, c.opts.FieldSeparator)
+	}
+	key := text
+	if c.opts.IgnoreCase {
+		key = strings.ToUpper(key)
+	}
+	if i, ok := c.index[key]; ok {
+		c.entries[i].count++
+		return
+	}
+	c.index[key] = len(c.entries)
+	c.entries = append(c.entries, countEntry{text: text, count: 1})
+}
+
+// addReader counts the lines of reader
+func (c *counter) addReader(ctx context.Context, reader io.Reader) error {
+	scanner := newLineReader(ctx, reader, c.opts)
+	for scanner.Scan() {
+		c.add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	return nil
+}
+
+// before reports whether a is printed before b: most common first, and
+// in order of their text when the counts are equal. -r reverses it.
+func (c *counter) before(a, b *countEntry) bool {
+	if c.opts.Reverse {
+		a, b = b, a
+	}
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	return a.text < b.text
+}
+
+// result returns the entries to print in order: all of them, or with
+// --top the first N, picked with a heap of N entries rather than by
+// sorting them all
+func (c *counter) result() []*countEntry {
+	var kept []*countEntry
+	if n := c.opts.Top; n > 0 && n < len(c.entries) {
+		h := &lastFirst{before: c.before}
+		for i := range c.entries {
+			e := &c.entries[i]
+			switch {
+			case len(h.entries) < n:
+				heap.Push(h, e)
+			case c.before(e, h.entries[0]):
+				h.entries[0] = e
+				heap.Fix(h, 0)
+			}
+		}
+		kept = h.entries
+	} else {
+		kept = make([]*countEntry, len(c.entries))
+		for i := range c.entries {
+			kept[i] = &c.entries[i]
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return c.before(kept[i], kept[j])
+	})
+	return kept
+}
+
+// write prints the counts as uniq -c does
+func (c *counter) write(w io.Writer) {
+	for _, e := range c.result() {
+		fmt.Fprintf(w, "%7d %s%c", e.count, e.text, c.opts.delim())
+	}
+}
+
+// lastFirst is a heap of entries whose root is the one printed last, so
+// that it is the one to drop when a better entry comes along
+type lastFirst struct {
+	entries []*countEntry
+	before  func(a, b *countEntry) bool
+}
+
+func (h *lastFirst) Len() int           { return len(h.entries) }
+func (h *lastFirst) Less(i, j int) bool { return h.before(h.entries[j], h.entries[i]) }
+func (h *lastFirst) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *lastFirst) Push(x any)         { h.entries = append(h.entries, x.(*countEntry)) }
+func (h *lastFirst) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// countFiles counts the lines of files and prints the tally, returning
+// errUnreadable after it if some files could not be read
+func countFiles(ctx context.Context, files []string, w io.Writer, opts *Options) error {
+	c := newCounter(opts)
+	failed := false
+	for _, file := range files {
+		var err error
+		if input.IsStdin(file) {
+			err = c.addReader(ctx, os.Stdin)
+		} else {
+			err = countFile(ctx, c, file)
+		}
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			logging.PathError("Failed to read", file, err)
+			failed = true
+		}
+	}
+	c.write(w)
+	if failed {
+		return errUnreadable
+	}
+	return nil
+}
+
+// countFile counts the lines of a file
+func countFile(ctx context.Context, c *counter, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return c.addReader(ctx, file)
+}
@@ -0,0 +1,298 @@
+package sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// keyDef is a sort key in GNU's KEYDEF syntax, F[.C][OPTS][,F[.C][OPTS]]:
+// the text from character C of field F up to character C of the second
+// field, or the end of the line without one, compared as OPTS say
+type keyDef struct {
+	startField, startChar int // 1-based; startChar 0 is the start of the field
+	endField, endChar     int // endField 0 is the end of the line, endChar 0 the end of the field
+
+	skipStartBlanks bool // b on the start: leading blanks don't count
+	skipEndBlanks   bool // b on the end
+	dictionary      bool // d: only blanks and alphanumerics count
+	fold            bool // f: case is ignored
+	printable       bool // i: only printing characters count
+	numeric         bool // n or g: compared as numbers
+	version         bool // V: compared as version numbers
+	reverse         bool // r
+}
+
+// wholeLine is the key of sorts without -k: the whole line, compared as
+// the global flags say
+func wholeLine(opts *Options) keyDef {
+	return keyDef{fold: opts.IgnoreCase, numeric: opts.Numeric, version: opts.VersionSort, reverse: opts.Reverse}
+}
+
+// compileKeys parses -k into opts.keys. A key without ordering options of
+// its own takes the global -n, -V, -f and -r, as in GNU sort; one with any
+// of them uses only its own. --locale is parsed along with them.
+func compileKeys(opts *Options) error {
+	var keys []keyDef
+	for _, spec := range opts.Keys {
+		k, err := parseKey(spec)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 && opts.Key > 0 {
+		keys = append(keys, keyDef{startField: opts.Key, endField: opts.Key})
+	}
+
+	global := wholeLine(opts)
+	for i := range keys {
+		if !keys[i].ordered() {
+			keys[i].fold, keys[i].numeric, keys[i].version, keys[i].reverse = global.fold, global.numeric, global.version, global.reverse
+		}
+	}
+	opts.keys = keys
+
+	locale, err := parseLocale(opts.Locale)
+	if err != nil {
+		return err
+	}
+	opts.locale = locale
+	opts.compiled = true
+	return nil
+}
+
+// sortKeys returns the compiled keys, compiling them for options built in
+// code rather than by Command, which has validated them already
+func (opts *Options) sortKeys() []keyDef {
+	if !opts.compiled {
+		compileKeys(opts)
+	}
+	return opts.keys
+}
+
+// ordered reports whether the key has ordering options of its own
+func (k *keyDef) ordered() bool {
+	return k.skipStartBlanks || k.skipEndBlanks || k.dictionary || k.fold || k.printable || k.numeric || k.version || k.reverse
+}
+
+// parseKey parses a KEYDEF such as "2", "2,3", "3n,3" or "1.2b,1.4"
+func parseKey(spec string) (keyDef, error) {
+	var k keyDef
+	start, end, hasEnd := strings.Cut(spec, ",")
+
+	field, char, flags, err := parsePosition(start)
+	if err == nil && field == 0 {
+		err = fmt.Errorf("field number is zero")
+	}
+	if err == nil && char == 0 && strings.Contains(start, ".") {
+		err = fmt.Errorf("character offset is zero")
+	}
+	if err != nil {
+		return k, fmt.Errorf("invalid key %q: %w", spec, err)
+	}
+	k.startField, k.startChar = field, char
+	if err := k.setFlags(flags, &k.skipStartBlanks); err != nil {
+		return k, fmt.Errorf("invalid key %q: %w", spec, err)
+	}
+
+	if hasEnd {
+		field, char, flags, err := parsePosition(end)
+		if err == nil && field == 0 {
+			err = fmt.Errorf("field number is zero")
+		}
+		if err != nil {
+			return k, fmt.Errorf("invalid key %q: %w", spec, err)
+		}
+		k.endField, k.endChar = field, char
+		if err := k.setFlags(flags, &k.skipEndBlanks); err != nil {
+			return k, fmt.Errorf("invalid key %q: %w", spec, err)
+		}
+	}
+	return k, nil
+}
+
+// parsePosition splits F[.C][OPTS] into its numbers and options
+func parsePosition(pos string) (field, char int, flags string, err error) {
+	digits := func(s string) (int, string, error) {
+		i := 0
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, s, fmt.Errorf("missing number in %q", pos)
+		}
+		n, err := strconv.Atoi(s[:i])
+		return n, s[i:], err
+	}
+
+	field, rest, err := digits(pos)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if after, ok := strings.CutPrefix(rest, "."); ok {
+		if char, rest, err = digits(after); err != nil {
+			return 0, 0, "", err
+		}
+	}
+	return field, char, rest, nil
+}
+
+// setFlags applies the ordering options of one end of a key; b only skips
+// the blanks at that end
+func (k *keyDef) setFlags(flags string, skipBlanks *bool) error {
+	for _, c := range flags {
+		switch c {
+		case 'b':
+			*skipBlanks = true
+		case 'd':
+			k.dictionary = true
+		case 'f':
+			k.fold = true
+		case 'i':
+			k.printable = true
+		case 'n', 'g':
+			k.numeric = true
+		case 'V':
+			k.version = true
+		case 'r':
+			k.reverse = true
+		default:
+			return fmt.Errorf("unsupported option %q", c)
+		}
+	}
+	return nil
+}
+
+// text returns the part of line the key covers, with sep separating the
+// fields. Fields the line doesn't have are empty.
+func (k *keyDef) text(line, sep string) string {
+	if k.startField == 0 {
+		return line
+	}
+
+	start, _ := fieldBounds(line, k.startField, sep)
+	if k.skipStartBlanks {
+		start = skipBlanks(line, start)
+	}
+	if k.startChar > 0 {
+		start = advance(line, start, k.startChar-1)
+	}
+
+	end := len(line)
+	if k.endField > 0 {
+		fieldStart, fieldEnd := fieldBounds(line, k.endField, sep)
+		end = fieldEnd
+		if k.endChar > 0 {
+			if k.skipEndBlanks {
+				fieldStart = skipBlanks(line, fieldStart)
+			}
+			end = advance(line, fieldStart, k.endChar)
+		}
+	}
+	if end <= start {
+		return ""
+	}
+	return line[start:end]
+}
+
+// fieldBounds returns the offsets of the nth field of line, or the end of
+// the line twice if it has fewer fields. An empty separator makes every
+// character a field.
+func fieldBounds(line string, n int, sep string) (int, int) {
+	if sep == "" {
+		start := advance(line, 0, n-1)
+		return start, advance(line, start, 1)
+	}
+
+	// Skip to the field without splitting the rest of the line
+	start := 0
+	for i := 1; i < n; i++ {
+		j := strings.Index(line[start:], sep)
+		if j < 0 {
+			return len(line), len(line)
+		}
+		start += j + len(sep)
+	}
+	if j := strings.Index(line[start:], sep); j >= 0 {
+		return start, start + j
+	}
+	return start, len(line)
+}
+
+// advance returns the offset n characters after offset i of line, or the
+// end of the line
+func advance(line string, i, n int) int {
+	for ; n > 0 && i < len(line); n-- {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	return i
+}
+
+// skipBlanks returns the offset of the first non-blank at or after i
+func skipBlanks(line string, i int) int {
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// keyValue is the text of a key prepared for comparison
+type keyValue struct {
+	text  string  // with the key's folding and filtering applied
+	num   float64 // text as a number with n
+	isNum bool    // whether text parsed as a number
+}
+
+// value prepares the text of the key in line for comparison, turning it
+// into a collation key with coll unless it is compared as a number
+func (k *keyDef) value(line, sep string, coll *collator) keyValue {
+	v := keyValue{text: k.text(line, sep)}
+	if k.dictionary || k.printable {
+		v.text = strings.Map(func(r rune) rune {
+			if k.dictionary && !(r == ' ' || r == '\t' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return -1
+			}
+			if k.printable && !unicode.IsPrint(r) {
+				return -1
+			}
+			return r
+		}, v.text)
+	}
+	if k.fold {
+		v.text = strings.ToUpper(v.text)
+	}
+	if k.numeric {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.text), 64)
+		v.num, v.isNum = n, err == nil
+	}
+	if !k.version {
+		v.text = coll.key(v.text)
+	}
+	return v
+}
+
+// compare orders two values of the key, reversed with r
+func (k *keyDef) compare(a, b *keyValue) int {
+	c := 0
+	if k.version {
+		c = versionCompare(a.text, b.text)
+	} else if k.numeric && a.isNum && b.isNum {
+		// Fall back to string comparison if not valid numbers
+		switch {
+		case a.num < b.num:
+			c = -1
+		case a.num > b.num:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(a.text, b.text)
+	}
+	if k.reverse {
+		return -c
+	}
+	return c
+}
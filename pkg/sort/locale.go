@@ -0,0 +1,51 @@
+package sort
+
+import (
+	"fmt"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// parseLocale parses --locale into the language whose collation rules
+// strings are compared by, or nil for byte order
+func parseLocale(name string) (*language.Tag, error) {
+	switch name {
+	case "", "C", "POSIX":
+		return nil, nil
+	}
+	tag, err := language.Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --locale %q: %w", name, err)
+	}
+	return &tag, nil
+}
+
+// collator turns strings into keys that compare byte by byte the way a
+// locale orders the strings. A nil *collator keeps strings as they are,
+// which is how sorting in byte order skips the work.
+type collator struct {
+	c   *collate.Collator
+	buf collate.Buffer
+}
+
+// newCollator returns a collator for the locale of opts, or nil without
+// one. A collator is not safe for concurrent use, so each goroutine
+// computing keys makes its own.
+func (opts *Options) newCollator() *collator {
+	opts.sortKeys() // parses the locale as well
+	if opts.locale == nil {
+		return nil
+	}
+	return &collator{c: collate.New(*opts.locale)}
+}
+
+// key returns the collation key of s
+func (c *collator) key(s string) string {
+	if c == nil {
+		return s
+	}
+	k := string(c.c.KeyFromString(&c.buf, s))
+	c.buf.Reset()
+	return k
+}
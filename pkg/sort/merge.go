@@ -0,0 +1,140 @@
+package sort
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// orderKeys returns the keys lines are ordered by: those of -k, or the
+// whole line compared as the global flags say
+func orderKeys(opts *Options) []keyDef {
+	if keys := opts.sortKeys(); len(keys) > 0 {
+		return keys
+	}
+	return []keyDef{wholeLine(opts)}
+}
+
+// newSortKey computes the values of the keys of a line, collating text
+// with coll
+func newSortKey(line string, keys []keyDef, opts *Options, coll *collator) sortKey {
+	k := sortKey{line: line, last: coll.key(line), values: make([]keyValue, len(keys))}
+	for i := range keys {
+		k.values[i] = keys[i].value(line, opts.FieldSeparator, coll)
+	}
+	return k
+}
+
+// mergeSource is an input of -m with its current line
+type mergeSource struct {
+	index   int // position among the inputs, which breaks ties
+	scanner *lines.Reader
+	closer  io.Closer
+	key     sortKey
+}
+
+// next reads the following line of the source, reporting false at the
+// end of the input
+func (s *mergeSource) next(keys []keyDef, opts *Options, coll *collator) bool {
+	if !s.scanner.Scan() {
+		return false
+	}
+	s.key = newSortKey(s.scanner.Text(), keys, opts, coll)
+	return true
+}
+
+// mergeHeap holds the sources with lines left, the one whose line comes
+// first on top
+type mergeHeap struct {
+	opts    *Options
+	keys    []keyDef
+	sources []*mergeSource
+}
+
+func (h *mergeHeap) Len() int      { return len(h.sources) }
+func (h *mergeHeap) Swap(i, j int) { h.sources[i], h.sources[j] = h.sources[j], h.sources[i] }
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.sources[i], h.sources[j]
+	if c := compareLines(&a.key, &b.key, h.keys, h.opts); c != 0 {
+		return c < 0
+	}
+	return a.index < b.index
+}
+func (h *mergeHeap) Push(x any) { h.sources = append(h.sources, x.(*mergeSource)) }
+func (h *mergeHeap) Pop() any {
+	last := h.sources[len(h.sources)-1]
+	h.sources = h.sources[:len(h.sources)-1]
+	return last
+}
+
+// mergeFiles merges files that are each sorted already into w, as -m
+// does, holding only the current line of each in memory. Lines that
+// compare equal even as a last resort, or with -s, come out in the order
+// of their files. Files that cannot be opened are reported and left out,
+// as for a full sort, and errUnreadable is returned at the end.
+func mergeFiles(ctx context.Context, files []string, w io.Writer, opts *Options) error {
+	h := &mergeHeap{opts: opts, keys: orderKeys(opts)}
+	coll := opts.newCollator()
+	defer func() {
+		for _, s := range h.sources {
+			s.closer.Close()
+		}
+	}()
+
+	failed := false
+	for i, file := range files {
+		var r io.ReadCloser = io.NopCloser(os.Stdin)
+		if !input.IsStdin(file) {
+			f, err := os.Open(file)
+			if err != nil {
+				logging.PathError("Failed to read", file, fmt.Errorf("failed to open file: %w", err))
+				failed = true
+				continue
+			}
+			r = f
+		}
+		s := &mergeSource{index: i, scanner: newLineReader(ctx, r, opts), closer: r}
+		if !s.next(h.keys, opts, coll) {
+			r.Close()
+			if err := s.scanner.Err(); err != nil {
+				return fmt.Errorf("error reading input: %w", err)
+			}
+			continue
+		}
+		h.sources = append(h.sources, s)
+	}
+	heap.Init(h)
+
+	var last string
+	written := false
+	for h.Len() > 0 {
+		s := h.sources[0]
+		line := s.key.line
+		if !opts.Unique || !written || uniqueKey(line, opts) != last {
+			if err := writeLine(w, line, opts); err != nil {
+				return err
+			}
+			last, written = uniqueKey(line, opts), true
+		}
+
+		if s.next(h.keys, opts, coll) {
+			heap.Fix(h, 0)
+			continue
+		}
+		heap.Pop(h)
+		s.closer.Close()
+		if err := s.scanner.Err(); err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+	}
+	if failed {
+		return errUnreadable
+	}
+	return nil
+}
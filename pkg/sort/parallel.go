@@ -0,0 +1,103 @@
+package sort
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// minChunk is the fewest lines worth sorting in a goroutine of their own;
+// below it the goroutines cost more than they save
+const minChunk = 16 * 1024
+
+// workers returns how many goroutines --parallel allows for n lines
+func workers(n int, opts *Options) int {
+	w := opts.Parallel
+	if w <= 0 {
+		w = runtime.GOMAXPROCS(0)
+	}
+	return max(1, min(w, n/minChunk))
+}
+
+// chunks calls fn for up to w consecutive ranges of n items at once, and
+// waits for all of them
+func chunks(n, w int, fn func(start, end int)) {
+	if w <= 1 {
+		fn(0, n)
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range w {
+		start, end := i*n/w, (i+1)*n/w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(start, end)
+		}()
+	}
+	wg.Wait()
+}
+
+// sortStable sorts items stably with w goroutines: each sorts a chunk,
+// and then neighbouring runs are merged in pairs, the pairs of a round
+// at once, until one run is left. A merge takes from the left run on
+// ties, so equal items keep their order.
+func sortStable[T any](items []T, cmp func(a, b T) int, w int) {
+	if w <= 1 {
+		slices.SortStableFunc(items, cmp)
+		return
+	}
+
+	chunks(len(items), w, func(start, end int) {
+		slices.SortStableFunc(items[start:end], cmp)
+	})
+	var bounds []int // start of each run, and the end of the last
+	for i := range w + 1 {
+		bounds = append(bounds, i*len(items)/w)
+	}
+
+	src, dst := items, make([]T, len(items))
+	for len(bounds) > 2 {
+		var next []int
+		var wg sync.WaitGroup
+		for i := 0; i+1 < len(bounds); i += 2 {
+			start, end := bounds[i], bounds[i+1]
+			next = append(next, start)
+			if i+2 >= len(bounds) {
+				// An odd run out goes to the next round as it is
+				copy(dst[start:end], src[start:end])
+				continue
+			}
+			mid := end
+			end = bounds[i+2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				merge(dst[start:end], src[start:mid], src[mid:end], cmp)
+			}()
+		}
+		wg.Wait()
+		bounds = append(next, len(items))
+		src, dst = dst, src
+	}
+	if &src[0] != &items[0] {
+		copy(items, src)
+	}
+}
+
+// merge merges the sorted runs a and b into dst, taking from a on ties
+func merge[T any](dst, a, b []T, cmp func(a, b T) int) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if cmp(b[j], a[i]) < 0 {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
@@ -1,26 +1,50 @@
 package sort
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"golang.org/x/text/language"
 	"io"
+	"io/fs"
 	"os"
-	"sort"
-	"strconv"
 	"strings"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
 )
 
 // Options holds sort configuration
 type Options struct {
 	Reverse        bool
 	Numeric        bool
+	VersionSort    bool // compare as version numbers, as in GNU sort -V
 	Unique         bool
+	Stable         bool // keep lines with equal keys in input order, without the last-resort comparison
 	IgnoreCase     bool
-	Key            int
+	Keys           []string // -k KEYDEFs, compared in order
+	Key            int      // a single field to compare, as -kN,N; for callers in code
 	FieldSeparator string
+	Count          bool   // print distinct lines with their counts, most common first
+	Top            int    // print only the N most common lines; implies Count
+	Output         string // write to this file instead of standard output
+	Check          bool   // only check that the input is sorted
+	Merge          bool   // merge inputs that are sorted already
+	ZeroTerminated bool   // lines end in NUL rather than newline
+	Parallel       int    // goroutines sorting at once; 0 is GOMAXPROCS
+	Locale         string // compare text as this locale orders it; empty for byte order
+
+	keys     []keyDef      // compiled Keys
+	locale   *language.Tag // parsed Locale, nil for byte order
+	compiled bool          // whether keys and locale have been set
 }
 
 // Command returns the sort command
@@ -32,82 +56,261 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sort [flags] [files...]",
 		Short: "Sort lines of text files",
-		Long:  `Sort lines of text files. With no files, or when file is -, read standard input.`,
-		Args:  cobra.ArbitraryArgs,
+		Long: `Sort lines of text files. With no files, or when file is -, read standard input.
+
+-k takes GNU's KEYDEF, F[.C][OPTS][,F[.C][OPTS]]: the key runs from
+character C of field F to character C of the second field, or to the end
+of the line without one, so -k2 is field 2 to the end and -k2,2 field 2
+alone. A C of 0 at the end, or none, means the end of the field. OPTS are
+any of b (ignore leading blanks), d (only blanks and alphanumerics), f
+(ignore case), i (only printing characters), n or g (numeric), V
+(version) and r (reverse). -k may be repeated: later keys break ties of
+earlier ones. A key without options of its own takes the global -f, -n,
+-V and -r.
+
+-V compares version numbers the way GNU sort does: runs of digits compare
+as numbers, so v1.2.10 sorts after v1.2.9, "~" sorts before everything, so
+1.0~rc1 comes before 1.0, and suffixes like .tar.gz only break ties.
+
+Lines whose keys are all equal are put in order by comparing the whole
+lines byte by byte, as a last resort, so the output doesn't depend on the
+order of the input; -r turns that around too. -s leaves it out and keeps
+such lines in the order they were read, as does -u.
+
+--count prints each distinct line once, prefixed by how often it occurs,
+most common first: what sort | uniq -c | sort -rn gives, in one pass that
+counts lines in a hash table rather than sorting them all. With -k the
+field is counted instead of the whole line, and with -f lines that differ
+only in case count together. --top N prints just the N most common, and -r
+turns either around to start with the least common.
+
+-o FILE writes the result to FILE rather than standard output. It is
+written to a temporary file first and renamed into place at the end, so
+FILE may also be one of the inputs, as in sort -o data.txt data.txt.
+
+-c checks that one input is sorted instead of sorting it: at the first
+line out of order it reports the line and exits with status 1, without
+reading the rest. With -u, a line equal to the one before also counts.
+
+-m merges inputs that are each sorted already, reading a line of each at a
+time, so that it needs little memory however large they are. The inputs
+must be sorted by the same options given to -m.
+
+Text compares byte by byte by default, as GNU sort does with LC_ALL=C, so
+"Z" sorts before "a" and accented letters after "z". --locale NAME orders
+it by the Unicode collation rules of a language instead, such as de, sv
+or fr-CA: letters with accents next to those without, lower case before
+upper case where the words are otherwise equal, and letters the language
+treats specially, like Swedish "å", "ä" and "ö", in their place.
+C or POSIX is byte order. Numbers and version numbers aren't affected.
+
+Large inputs are sorted in chunks by several goroutines at once, which are
+then merged; --parallel N sets how many, and defaults to the number of
+CPUs Go may use. Inputs too small to gain from it are sorted in one.
+
+-z reads and writes lines that end in NUL bytes instead of newlines, for
+file names that may contain any character:
+
+  find . --print0 | sort -z | xargs -0 ls -ld
+
+Examples:
+  sort -t, -k3n,3 -k1,1 data.csv   By the third column as a number, then the first
+  sort --top 10 -k1,1 access.log    The 10 busiest client addresses
+  git tag | sort -V                 Release tags in version order
+  grep -o 'ERROR [A-Z_]*' app.log | sort --count`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			files := args
+			files := glob.Expand(args)
 			if len(files) == 0 {
-				files = []string{"-"}
+				files = []string{input.Stdin}
 			}
 
-			// Collect all lines from all files
-			var allLines []string
-
-			for _, file := range files {
-				var lines []string
-				var err error
+			ctx := cmd.Context()
 
-				if file == "-" {
-					lines, err = readLines(os.Stdin)
-				} else {
-					lines, err = readFile(file)
+			if err := compileKeys(opts); err != nil {
+				return exitcode.New(2, err)
+			}
+			if opts.Top < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --top value %d", opts.Top))
+			}
+			if opts.Parallel < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --parallel value %d", opts.Parallel))
+			}
+			if opts.Check {
+				if len(files) > 1 {
+					return exitcode.New(2, fmt.Errorf("extra operand '%s' not allowed with -c", files[1]))
 				}
+				return checkFile(ctx, files[0], opts)
+			}
 
-				if err != nil {
-					eve.Logger.Error("Failed to read", file, ":", err)
-					continue
+			// Files that can't be read are reported and left out, and
+			// the result is still written before sort exits with 1
+			failed := false
+			write := func(w io.Writer) error {
+				var err error
+				switch {
+				case opts.Count || opts.Top > 0:
+					err = countFiles(ctx, files, w, opts)
+				case opts.Merge:
+					err = mergeFiles(ctx, files, w, opts)
+				default:
+					err = sortFiles(ctx, files, w, opts)
 				}
-
-				allLines = append(allLines, lines...)
+				if errors.Is(err, errUnreadable) {
+					failed = true
+					return nil
+				}
+				return err
 			}
 
-			// Sort the lines
-			sortedLines := sortLines(allLines, opts)
-
-			// Print sorted lines
-			for _, line := range sortedLines {
-				fmt.Println(line)
+			var err error
+			switch {
+			case opts.Output != "" && dryrun.Enabled:
+				dryrun.Report("write sorted output to '%s'", opts.Output)
+				err = write(io.Discard)
+			case opts.Output != "":
+				err = output.WriteFile(ctx, opts.Output, outputMode(opts.Output), write)
+			default:
+				err = write(output.Stdout)
 			}
-
-			return nil
+			if err != nil {
+				return err
+			}
+			return exitcode.Failed(cmd, failed)
 		},
 	}
 
+	cmd.SetFlagErrorFunc(exitcode.Usage)
+
 	cmd.Flags().BoolVarP(&opts.Reverse, "reverse", "r", false, "Reverse the result of comparisons")
 	cmd.Flags().BoolVarP(&opts.Numeric, "numeric-sort", "n", false, "Compare according to string numerical value")
+	cmd.Flags().BoolVarP(&opts.VersionSort, "version-sort", "V", false, "Natural sort of (version) numbers within text")
 	cmd.Flags().BoolVarP(&opts.Unique, "unique", "u", false, "Output only the first of an equal run")
+	cmd.Flags().BoolVarP(&opts.Stable, "stable", "s", false, "Stabilize sort by disabling last-resort comparison")
 	cmd.Flags().BoolVarP(&opts.IgnoreCase, "ignore-case", "f", false, "Fold lower case to upper case characters")
-	cmd.Flags().IntVarP(&opts.Key, "key", "k", 0, "Sort via a key; 1-indexed field number")
+	cmd.Flags().StringArrayVarP(&opts.Keys, "key", "k", nil, "Sort via a key `KEYDEF`: F[.C][OPTS][,F[.C][OPTS]] (repeatable)")
 	cmd.Flags().StringVarP(&opts.FieldSeparator, "field-separator", "t", " ", "Use SEP instead of non-blank to blank transition")
+	cmd.Flags().BoolVar(&opts.Count, "count", false, "Print each distinct line once with its count, most common first")
+	cmd.Flags().IntVar(&opts.Top, "top", 0, "Print only the `N` most common lines with their counts")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Write the result to `FILE` instead of standard output")
+	cmd.Flags().BoolVarP(&opts.Check, "check", "c", false, "Check that the input is sorted; don't sort")
+	cmd.Flags().BoolVarP(&opts.Merge, "merge", "m", false, "Merge already sorted files; don't sort")
+	cmd.Flags().BoolVarP(&opts.ZeroTerminated, "zero-terminated", "z", false, "Lines are terminated by NUL instead of newline")
+	cmd.Flags().StringVar(&opts.Locale, "locale", "", "Compare text as the locale `NAME` orders it (e.g. de, sv, fr-CA) instead of by bytes")
+	cmd.Flags().IntVar(&opts.Parallel, "parallel", 0, "Sort with up to `N` goroutines at once (default GOMAXPROCS)")
 
 	return cmd
 }
 
+// errUnreadable is returned once the output is written when some of the
+// files could not be read
+var errUnreadable = errors.New("some files could not be read")
+
+// outputMode returns the mode for the file -o writes: that of the file it
+// replaces, or 0644 for a new one
+func outputMode(name string) fs.FileMode {
+	if info, err := os.Stat(name); err == nil {
+		return info.Mode().Perm()
+	}
+	return 0644
+}
+
+// sortFiles reads every line of files and writes them to w sorted. Files
+// that cannot be read are reported and left out, and errUnreadable is
+// returned after the rest.
+func sortFiles(ctx context.Context, files []string, w io.Writer, opts *Options) error {
+	// Collect all lines from all files
+	var allLines []string
+	failed := false
+
+	for _, file := range files {
+		var lines []string
+		var err error
+
+		if input.IsStdin(file) {
+			lines, err = readLines(ctx, os.Stdin, opts)
+		} else {
+			lines, err = readFile(ctx, file, opts)
+		}
+
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			logging.PathError("Failed to read", file, err)
+			failed = true
+			continue
+		}
+
+		allLines = append(allLines, lines...)
+	}
+
+	// Print sorted lines
+	for _, line := range sortLines(allLines, opts) {
+		if err := writeLine(w, line, opts); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errUnreadable
+	}
+	return nil
+}
+
+// delim returns the byte lines end in
+func (opts *Options) delim() byte {
+	if opts.ZeroTerminated {
+		return 0
+	}
+	return '\n'
+}
+
+// newLineReader returns a reader of the lines of r, ending as -z says
+func newLineReader(ctx context.Context, r io.Reader, opts *Options) *lines.Reader {
+	scanner := lines.NewReader(interrupt.Reader(ctx, r))
+	scanner.SetDelimiter(opts.delim())
+	return scanner
+}
+
+// writeLine writes a line to w with its terminator
+func writeLine(w io.Writer, line string, opts *Options) error {
+	_, err := io.WriteString(w, line+string(opts.delim()))
+	return err
+}
+
 // readFile reads all lines from a file
-func readFile(filename string) ([]string, error) {
+func readFile(ctx context.Context, filename string, opts *Options) ([]string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	return readLines(file)
+	return readLines(ctx, file, opts)
 }
 
 // readLines reads all lines from a reader
-func readLines(reader io.Reader) ([]string, error) {
-	var lines []string
-	scanner := bufio.NewScanner(reader)
+func readLines(ctx context.Context, reader io.Reader, opts *Options) ([]string, error) {
+	var result []string
+	scanner := newLineReader(ctx, reader, opts)
 
 	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+		result = append(result, scanner.Text())
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error reading input: %w", err)
 	}
 
-	return lines, nil
+	return result, nil
+}
+
+// sortKey is a line with what comparing it takes worked out once, rather
+// than the line being split, folded and parsed again in every comparison
+type sortKey struct {
+	line   string
+	last   string     // the line as the last-resort comparison sees it
+	values []keyValue // one for each key
 }
 
 // sortLines sorts lines according to options
@@ -116,44 +319,28 @@ func sortLines(lines []string, opts *Options) []string {
 	sorted := make([]string, len(lines))
 	copy(sorted, lines)
 
-	sort.SliceStable(sorted, func(i, j int) bool {
-		line1 := sorted[i]
-		line2 := sorted[j]
-
-		// Extract key fields if specified
-		if opts.Key > 0 {
-			line1 = extractKey(line1, opts.Key, opts.FieldSeparator)
-			line2 = extractKey(line2, opts.Key, opts.FieldSeparator)
-		}
-
-		// Apply case folding if requested
-		if opts.IgnoreCase {
-			line1 = strings.ToUpper(line1)
-			line2 = strings.ToUpper(line2)
-		}
-
-		// Compare
-		var result bool
-		if opts.Numeric {
-			num1, err1 := strconv.ParseFloat(strings.TrimSpace(line1), 64)
-			num2, err2 := strconv.ParseFloat(strings.TrimSpace(line2), 64)
-
-			if err1 == nil && err2 == nil {
-				result = num1 < num2
-			} else {
-				// Fall back to string comparison if not valid numbers
-				result = line1 < line2
+	w := workers(len(sorted), opts)
+	keys := opts.sortKeys()
+	if len(keys) == 0 && !opts.IgnoreCase && !opts.Numeric && !opts.VersionSort && opts.locale == nil {
+		// Whole lines compare as they are, so there is nothing to cache
+		sortStable(sorted, func(a, b string) int {
+			if opts.Reverse {
+				return strings.Compare(b, a)
 			}
-		} else {
-			result = line1 < line2
+			return strings.Compare(a, b)
+		}, w)
+	} else {
+		if len(keys) == 0 {
+			keys = []keyDef{wholeLine(opts)}
 		}
-
-		// Reverse if requested
-		if opts.Reverse {
-			return !result
+		lineKeys := makeKeys(sorted, keys, opts, w)
+		sortStable(lineKeys, func(a, b sortKey) int {
+			return compareLines(&a, &b, keys, opts)
+		}, w)
+		for i := range lineKeys {
+			sorted[i] = lineKeys[i].line
 		}
-		return result
-	})
+	}
 
 	// Apply unique filter if requested
 	if opts.Unique {
@@ -163,17 +350,49 @@ func sortLines(lines []string, opts *Options) []string {
 	return sorted
 }
 
-// extractKey extracts the Nth field from a line
-func extractKey(line string, keyNum int, separator string) string {
-	fields := strings.Split(line, separator)
+// makeKeys computes the values of the keys of every line, with w
+// goroutines each taking a share of the lines
+func makeKeys(lines []string, keys []keyDef, opts *Options, w int) []sortKey {
+	lineKeys := make([]sortKey, len(lines))
+	values := make([]keyValue, len(lines)*len(keys))
+	chunks(len(lines), w, func(start, end int) {
+		coll := opts.newCollator()
+		for i := start; i < end; i++ {
+			k := &lineKeys[i]
+			k.line, k.last = lines[i], coll.key(lines[i])
+			k.values = values[i*len(keys) : (i+1)*len(keys)]
+			for j := range keys {
+				k.values[j] = keys[j].value(lines[i], opts.FieldSeparator, coll)
+			}
+		}
+	})
+	return lineKeys
+}
 
-	// Adjust for 1-indexed keys
-	index := keyNum - 1
-	if index < 0 || index >= len(fields) {
-		return line
+// compareKeys orders two lines by the first of their keys that differ
+func compareKeys(a, b *sortKey, keys []keyDef) int {
+	for i := range keys {
+		if c := keys[i].compare(&a.values[i], &b.values[i]); c != 0 {
+			return c
+		}
 	}
+	return 0
+}
 
-	return fields[index]
+// compareLines orders two lines as GNU sort does: by their keys, and
+// when those are equal by the whole lines, reversed only by the global
+// -r. -s and -u leave out that last resort, so equal keys keep the order
+// of the input.
+func compareLines(a, b *sortKey, keys []keyDef, opts *Options) int {
+	c := compareKeys(a, b, keys)
+	if c != 0 || opts.Stable || opts.Unique {
+		return c
+	}
+	c = strings.Compare(a.last, b.last)
+	if opts.Reverse {
+		return -c
+	}
+	return c
 }
 
 // uniqueLines removes consecutive duplicate lines
@@ -183,19 +402,11 @@ func uniqueLines(lines []string, opts *Options) []string {
 	}
 
 	unique := []string{lines[0]}
-	lastLine := lines[0]
-
-	if opts.IgnoreCase {
-		lastLine = strings.ToUpper(lastLine)
-	}
+	lastLine := uniqueKey(lines[0], opts)
 
 	for i := 1; i < len(lines); i++ {
 		currentLine := lines[i]
-		compareLine := currentLine
-
-		if opts.IgnoreCase {
-			compareLine = strings.ToUpper(compareLine)
-		}
+		compareLine := uniqueKey(currentLine, opts)
 
 		if compareLine != lastLine {
 			unique = append(unique, currentLine)
@@ -205,3 +416,12 @@ func uniqueLines(lines []string, opts *Options) []string {
 
 	return unique
 }
+
+// uniqueKey returns what -u compares of a line: the line itself, or with
+// -f the line in upper case
+func uniqueKey(line string, opts *Options) string {
+	if opts.IgnoreCase {
+		return strings.ToUpper(line)
+	}
+	return line
+}
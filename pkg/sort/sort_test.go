@@ -0,0 +1,305 @@
+package sort
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+)
+
+// TestSortLines tests each ordering against the cached keys
+func TestSortLines(t *testing.T) {
+	lines := []string{"b 10", "a 9", "C 100", "a 9x", "B 2"}
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"plain", Options{}, []string{"B 2", "C 100", "a 9", "a 9x", "b 10"}},
+		{"reverse", Options{Reverse: true}, []string{"b 10", "a 9x", "a 9", "C 100", "B 2"}},
+		{"ignore case", Options{IgnoreCase: true}, []string{"a 9", "a 9x", "b 10", "B 2", "C 100"}},
+		{"numeric key", Options{Numeric: true, Key: 2, FieldSeparator: " "}, []string{"B 2", "a 9", "b 10", "C 100", "a 9x"}},
+		{"key", Options{Key: 2, FieldSeparator: " "}, []string{"b 10", "C 100", "B 2", "a 9", "a 9x"}},
+		{"unique ignoring case", Options{IgnoreCase: true, Unique: true, Key: 1, FieldSeparator: " "}, []string{"a 9", "a 9x", "b 10", "B 2", "C 100"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sortLines(lines, &tt.opts))
+		})
+	}
+}
+
+// TestSortLines_ReverseStable tests that -r reverses the last-resort
+// comparison of lines with equal keys too, and that -s keeps them in
+// input order instead
+func TestSortLines_ReverseStable(t *testing.T) {
+	lines := []string{"x 1", "y 2", "z 1"}
+	got := sortLines(lines, &Options{Reverse: true, Numeric: true, Key: 2, FieldSeparator: " "})
+	assert.Equal(t, []string{"y 2", "z 1", "x 1"}, got)
+	got = sortLines(lines, &Options{Reverse: true, Numeric: true, Stable: true, Key: 2, FieldSeparator: " "})
+	assert.Equal(t, []string{"y 2", "x 1", "z 1"}, got)
+}
+
+// TestKeyText tests picking fields and characters without splitting the
+// whole line; fields a line doesn't have are empty, as in GNU sort
+func TestKeyText(t *testing.T) {
+	text := func(spec, line, sep string) string {
+		k, err := parseKey(spec)
+		require.NoError(t, err)
+		return k.text(line, sep)
+	}
+	assert.Equal(t, "b", text("2,2", "a:b:c", ":"))
+	assert.Equal(t, "b:c", text("2", "a:b:c", ":"))
+	assert.Equal(t, "c", text("3,3", "a:b:c", ":"))
+	assert.Equal(t, "", text("4,4", "a:b:c", ":"))
+	assert.Equal(t, "", text("2,2", "a  b", " "))
+	assert.Equal(t, "b", text("2,2", "a::b", "::"))
+	assert.Equal(t, "é", text("2,2", "hé", ""))
+
+	// Character offsets, counted in characters, and blanks with b
+	assert.Equal(t, "llo:wo", text("1.3,2.2", "hello:world", ":"))
+	assert.Equal(t, "ér", text("1.2,1.3", "vérité", ":"))
+	assert.Equal(t, "x", text("2.1b,2.1b", "a:  x", ":"))
+	assert.Equal(t, " ", text("2.1,2.1", "a:  x", ":"))
+	assert.Equal(t, "", text("1.9,1.2", "hello", ":"))
+}
+
+// TestParseKey tests the KEYDEF syntax and its errors
+func TestParseKey(t *testing.T) {
+	k, err := parseKey("3.2nr,4.5b")
+	require.NoError(t, err)
+	assert.Equal(t, keyDef{startField: 3, startChar: 2, endField: 4, endChar: 5, numeric: true, reverse: true, skipEndBlanks: true}, k)
+
+	for _, spec := range []string{"", "0", "x", "1.0", "1,0", "2.", "1M", "1,2z"} {
+		_, err := parseKey(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// TestSortLines_Keys tests several keys with options of their own, and
+// global options taken only by keys without any
+func TestSortLines_Keys(t *testing.T) {
+	lines := []string{"b,10,x", "a,9,y", "c,10,w", "a,100,z"}
+	sorted := func(opts Options) []string {
+		require.NoError(t, compileKeys(&opts))
+		return sortLines(lines, &opts)
+	}
+
+	assert.Equal(t, []string{"c,10,w", "b,10,x", "a,100,z", "a,9,y"},
+		sorted(Options{Keys: []string{"2,2", "1,1r"}, FieldSeparator: ","}))
+	assert.Equal(t, []string{"a,9,y", "b,10,x", "c,10,w", "a,100,z"},
+		sorted(Options{Keys: []string{"2n,2", "1,1"}, FieldSeparator: ","}))
+	assert.Equal(t, []string{"a,100,z", "c,10,w", "b,10,x", "a,9,y"},
+		sorted(Options{Keys: []string{"2,2"}, Numeric: true, Reverse: true, FieldSeparator: ","}))
+	// -r doesn't reach a key with options of its own, only the last resort
+	assert.Equal(t, []string{"a,9,y", "c,10,w", "b,10,x", "a,100,z"},
+		sorted(Options{Keys: []string{"2n,2"}, Reverse: true, FieldSeparator: ","}))
+
+	assert.Error(t, compileKeys(&Options{Keys: []string{"1,2q"}}))
+}
+
+// TestVersionCompare tests -V against the order GNU sort -V gives
+func TestVersionCompare(t *testing.T) {
+	want := []string{
+		"", ".", "..", ".hidden", "1.0~rc1", "1.0", "1.0.tar.gz", "1.0a", "1.0-rc1", "1.0.1",
+		"9", "10", "a-1.9", "a-1.10", "foo.tar.gz", "foo-1.tar.gz", "v1.2.9", "v1.2.10",
+	}
+	lines := append([]string(nil), want...)
+	slices.Reverse(lines)
+	assert.Equal(t, want, sortLines(lines, &Options{VersionSort: true}))
+
+	assert.Zero(t, versionCompare("a-1.09", "a-1.9"))
+	assert.Negative(t, versionCompare("1.2.tar.gz", "1.2.zip"))
+
+	opts := Options{Keys: []string{"2V,2"}, FieldSeparator: " "}
+	require.NoError(t, compileKeys(&opts))
+	assert.Equal(t, []string{"b 1.9", "a 1.10"}, sortLines([]string{"a 1.10", "b 1.9"}, &opts))
+}
+
+// TestCounter tests --count and --top ordering, with and without a heap
+func TestCounter(t *testing.T) {
+	count := func(opts Options, lines ...string) string {
+		c := newCounter(&opts)
+		for _, line := range lines {
+			c.add(line)
+		}
+		var b strings.Builder
+		c.write(&b)
+		return b.String()
+	}
+
+	logLines := []string{"b", "a", "B", "c", "b", "a", "b"}
+	assert.Equal(t, "      3 b\n      2 a\n      1 B\n      1 c\n", count(Options{Count: true}, logLines...))
+	assert.Equal(t, "      3 b\n      2 a\n", count(Options{Top: 2}, logLines...))
+	assert.Equal(t, "      4 b\n      2 a\n", count(Options{Top: 2, IgnoreCase: true}, logLines...))
+	assert.Equal(t, "      1 c\n      1 B\n", count(Options{Top: 2, Reverse: true}, logLines...))
+	assert.Equal(t, count(Options{Count: true}, logLines...), count(Options{Top: 10}, logLines...))
+
+	// With -k the field is counted and printed
+	assert.Equal(t, "      2 404\n      1 200\n", count(Options{Count: true, Key: 2, FieldSeparator: " "}, "/a 404", "/b 200", "/c 404"))
+}
+
+// TestMergeFiles tests merging sorted inputs with keys, with -s keeping
+// equal lines in the order of their files, and with -u
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	require.NoError(t, os.WriteFile(a, []byte("1 a\n5 a\n10 a\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("2 b\n5 b\n"), 0644))
+
+	merge := func(opts Options, files ...string) string {
+		require.NoError(t, compileKeys(&opts))
+		var buf strings.Builder
+		require.NoError(t, mergeFiles(context.Background(), files, &buf, &opts))
+		return buf.String()
+	}
+	assert.Equal(t, "1 a\n2 b\n5 a\n5 b\n10 a\n", merge(Options{Keys: []string{"1n,1"}, FieldSeparator: " "}, a, b))
+	assert.Equal(t, "1 a\n2 b\n5 a\n5 b\n10 a\n", merge(Options{Keys: []string{"1n,1"}, FieldSeparator: " "}, b, a))
+	assert.Equal(t, "1 a\n2 b\n5 b\n5 a\n10 a\n", merge(Options{Keys: []string{"1n,1"}, FieldSeparator: " ", Stable: true}, b, a))
+	assert.Equal(t, "2 b\n5 b\n", merge(Options{Unique: true}, b, b))
+}
+
+// TestCheckFile tests -c, with and without -u
+func TestCheckFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in")
+	check := func(text string, opts Options) error {
+		require.NoError(t, os.WriteFile(path, []byte(text), 0644))
+		return checkFile(context.Background(), path, &opts)
+	}
+
+	assert.NoError(t, check("a\nb\nb\n", Options{}))
+	assert.NoError(t, check("9\n10\n", Options{Numeric: true}))
+
+	err := check("a\nc\nb\n", Options{})
+	assert.EqualError(t, err, path+":3: disorder: b")
+	assert.Equal(t, 1, exitcode.From(err))
+	assert.Error(t, check("a\nb\nb\n", Options{Unique: true}))
+}
+
+// TestCommand_Output tests that -o can name one of the inputs and keeps
+// its mode
+func TestCommand_Output(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("b\na\n"), 0600))
+
+	cmd := Command()
+	cmd.SetArgs([]string{"-o", path, path})
+	require.NoError(t, cmd.Execute())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// TestSortFiles_Zero tests that -z splits and joins lines on NUL bytes,
+// so that newlines are part of a line
+func TestSortFiles_Zero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names")
+	require.NoError(t, os.WriteFile(path, []byte("b\nline\x00a\nline\x00c"), 0644))
+
+	var buf strings.Builder
+	require.NoError(t, sortFiles(context.Background(), []string{path}, &buf, &Options{ZeroTerminated: true}))
+	assert.Equal(t, "a\nline\x00b\nline\x00c\x00", buf.String())
+
+	buf.Reset()
+	require.NoError(t, mergeFiles(context.Background(), []string{path}, &buf, &Options{ZeroTerminated: true}))
+	assert.Equal(t, "b\nline\x00a\nline\x00c\x00", buf.String())
+}
+
+// TestSortStable tests that sorting in parallel chunks gives what one
+// stable sort does, for odd numbers of chunks too
+func TestSortStable(t *testing.T) {
+	items := make([]string, 10007)
+	for i := range items {
+		items[i] = fmt.Sprintf("%d %d", (i*7919)%97, i)
+	}
+	byField := func(a, b string) int {
+		return strings.Compare(strings.Fields(a)[0], strings.Fields(b)[0])
+	}
+	want := slices.Clone(items)
+	slices.SortStableFunc(want, byField)
+
+	for _, w := range []int{1, 2, 3, 8} {
+		got := slices.Clone(items)
+		sortStable(got, byField, w)
+		assert.Equal(t, want, got, "%d workers", w)
+	}
+}
+
+// TestSortLines_Parallel tests that large inputs sort the same with keys
+// whatever --parallel is
+func TestSortLines_Parallel(t *testing.T) {
+	lines := make([]string, 3*minChunk+1)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("x %d", (i*7919)%1000)
+	}
+	want := sortLines(lines, &Options{Keys: []string{"2n"}, FieldSeparator: " ", Parallel: 1})
+	got := sortLines(lines, &Options{Keys: []string{"2n"}, FieldSeparator: " ", Parallel: 4})
+	assert.Equal(t, want, got)
+	assert.Equal(t, "x 0", got[0])
+	assert.Equal(t, "x 999", got[len(got)-1])
+}
+
+// TestCommand_Golden tests the output of sort against that of GNU sort in
+// the C locale, kept in testdata/NAME.golden, for flags that decide the
+// order of lines with equal keys
+func TestCommand_Golden(t *testing.T) {
+	for name, args := range map[string][]string{
+		"default":      {},
+		"fold":         {"-f"},
+		"reverse":      {"-r"},
+		"key2":         {"-t,", "-k2,2"},
+		"key2-stable":  {"-t,", "-k2,2", "-s"},
+		"key2-reverse": {"-t,", "-k2,2", "-r"},
+		"key2n":        {"-t,", "-k2n,2"},
+		"key2nr-key1":  {"-t,", "-k2nr,2", "-k1,1"},
+		"key1f":        {"-t,", "-k1f,1"},
+		"key1f-stable": {"-t,", "-k1f,1", "-s"},
+		"key3V":        {"-t,", "-k3V,3"},
+	} {
+		out := filepath.Join(t.TempDir(), "out")
+		cmd := Command()
+		cmd.SetArgs(append(args, "-o", out, filepath.Join("testdata", "input.csv")))
+		require.NoError(t, cmd.ExecuteContext(context.Background()), name)
+
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		want, err := os.ReadFile(filepath.Join("testdata", name+".golden"))
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "sort %s", strings.Join(args, " "))
+	}
+}
+
+// TestSortLines_Locale tests collating with --locale, with keys and as
+// the last resort, and that byte order stays the default
+func TestSortLines_Locale(t *testing.T) {
+	lines := []string{"Zebra", "öl", "apple", "Äpfel", "ort", "Apple"}
+	assert.Equal(t, []string{"Apple", "Zebra", "apple", "ort", "Äpfel", "öl"}, sortLines(lines, &Options{}))
+	assert.Equal(t, []string{"Äpfel", "apple", "Apple", "öl", "ort", "Zebra"}, sortLines(lines, &Options{Locale: "de"}))
+	assert.Equal(t, []string{"apple", "Apple", "ort", "Zebra", "Äpfel", "öl"}, sortLines(lines, &Options{Locale: "sv"}))
+
+	// Equal first fields fall back to the whole lines, collated too
+	lines = []string{"x Zebra", "x äpfel", "x apple"}
+	assert.Equal(t, []string{"x äpfel", "x apple", "x Zebra"},
+		sortLines(lines, &Options{Locale: "de", Key: 1, FieldSeparator: " "}))
+
+	assert.Error(t, compileKeys(&Options{Locale: "not a locale"}))
+}
@@ -0,0 +1,123 @@
+package sort
+
+// versionCompare orders a and b as GNU sort -V does, with the filevercmp
+// algorithm: runs of digits compare as numbers, so v1.2.10 comes after
+// v1.2.9, and other characters compare with letters before punctuation
+// and "~" before anything, even the end, so 1.0~rc1 comes before 1.0.
+// File suffixes such as ".tar.gz" only break ties between the rest.
+func versionCompare(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+
+	// "." sorts first, then "..", then other names starting with ".", then
+	// the rest
+	if a[0] == '.' || b[0] == '.' {
+		for _, special := range []string{".", ".."} {
+			if a == special {
+				return -1
+			}
+			if b == special {
+				return 1
+			}
+		}
+		if b[0] != '.' {
+			return -1
+		}
+		if a[0] != '.' {
+			return 1
+		}
+	}
+
+	aPrefix, bPrefix := a[:prefixLen(a)], b[:prefixLen(b)]
+	if c := verrevcmp(aPrefix, bPrefix); c != 0 || (len(aPrefix) == len(a) && len(bPrefix) == len(b)) {
+		return c
+	}
+	return verrevcmp(a, b)
+}
+
+// prefixLen returns the length of name without its file suffix, the
+// longest run of ".X..." at its end where X is a letter or "~" and the
+// rest letters, digits or "~". The first character is never part of it.
+func prefixLen(name string) int {
+	prefix := 0
+	for i := 0; i < len(name); {
+		i++
+		prefix = i
+		for i+1 < len(name) && name[i] == '.' && (isAlpha(name[i+1]) || name[i+1] == '~') {
+			for i += 2; i < len(name) && (isAlpha(name[i]) || isDigit(name[i]) || name[i] == '~'); i++ {
+			}
+		}
+	}
+	return prefix
+}
+
+// verrevcmp is the Debian version comparison filevercmp builds on:
+// alternating runs of non-digits, compared a character at a time by
+// versionOrder, and digits, compared as numbers
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			if c := versionOrder(a, i) - versionOrder(b, j); c != 0 {
+				return c
+			}
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && j < len(b) && isDigit(a[i]) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		// The longer number is the larger one
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+// versionOrder ranks the character at offset i of s within a run of
+// non-digits: "~" first, then the end of the string and digits, then
+// letters, then everything else
+func versionOrder(s string, i int) int {
+	if i >= len(s) {
+		return -1
+	}
+	switch c := s[i]; {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -2
+	default:
+		return int(c) + 256
+	}
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isAlpha(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
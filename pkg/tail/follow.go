@@ -0,0 +1,85 @@
+package tail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/evalgo-org/claude-tools/pkg/follow"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// appended is data read from one of the files being followed, or the error
+// that ended following it
+type appended struct {
+	file int
+	data []byte
+	err  error
+}
+
+// followFiles prints what is appended to files after their tails, until
+// ctx is canceled or, with --pid, the process has ended and every file has
+// been read to its end. As in GNU tail, a header names the file whenever
+// the output switches to another one; current is the file printed last.
+func followFiles(ctx context.Context, files []*follow.File, current int, opts *Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	reads := make(chan appended)
+	for i, f := range files {
+		f.Interval = time.Duration(opts.SleepInterval * float64(time.Second))
+		if opts.Pid > 0 {
+			f.Done = func() bool { return !follow.Alive(opts.Pid) }
+		}
+		wg.Go(func() {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := f.Read(buf)
+				read := appended{file: i, data: append([]byte(nil), buf[:n]...), err: err}
+				select {
+				case reads <- read:
+				case <-ctx.Done():
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		})
+	}
+
+	for open := len(files); open > 0; {
+		read := <-reads
+		if len(read.data) > 0 {
+			if read.file != current && len(files) > 1 && !opts.Quiet {
+				fmt.Fprintf(output.Stdout, "\n==> %s <==\n", files[read.file].Name())
+			}
+			current = read.file
+			if _, err := output.Stdout.Write(read.data); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+			// Show the new data now rather than when the buffer fills
+			if err := output.Flush(); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+		}
+
+		switch {
+		case read.err == nil:
+		case interrupt.Interrupted(read.err):
+			return read.err
+		case read.err == io.EOF:
+			open--
+		default:
+			logging.PathError("Failed to follow file", files[read.file].Name(), read.err)
+			open--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package tail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// blockSize is how much tailSeek reads at a time while searching backwards
+const blockSize = 64 * 1024
+
+// seekable returns the start and end offsets of the part of f still to be
+// read, or ok=false if f can't be read backwards: pipes, terminals and
+// other special files, and input that lines.Reader would transform
+func seekable(f *os.File) (start, end int64, ok bool) {
+	if lines.DecodeBOM || lines.StripCR {
+		return 0, 0, false
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, 0, false
+	}
+	start, err = f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, info.Size(), start <= info.Size()
+}
+
+// tailSeek prints the last lines or bytes of f between start and end,
+// finding where they begin by reading backwards from end, so that only
+// the printed part and the blocks before it are read
+func tailSeek(ctx context.Context, f *os.File, start, end int64, opts *Options) error {
+	var offset int64
+	switch {
+	case opts.FromStart:
+		offset = min(end, start+int64(opts.Bytes)-1)
+	case opts.Bytes > 0:
+		offset = max(start, end-int64(opts.Bytes))
+	default:
+		var err error
+		offset, err = lastLines(ctx, f, start, end, opts.Lines, opts.delim())
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+	}
+
+	section := io.NewSectionReader(f, offset, end-offset)
+	if _, err := io.Copy(output.Stdout, interrupt.Reader(ctx, section)); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	// Leave f where a sequential read would have, as head does for stdin
+	_, err := f.Seek(end, io.SeekStart)
+	return err
+}
+
+// lastLines returns the offset at which the last n lines ending in delim
+// between start and end begin. A delimiter as the very last byte ends the
+// final line rather than starting another one.
+func lastLines(ctx context.Context, f *os.File, start, end int64, n int, delim byte) (int64, error) {
+	if n <= 0 {
+		return end, nil
+	}
+
+	limit := end
+	if end > start {
+		// Skip the final line's terminator
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, end-1); err != nil {
+			return 0, err
+		}
+		if last[0] == delim {
+			limit--
+		}
+	}
+
+	buf := make([]byte, blockSize)
+	for pos := limit; pos > start; {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		size := min(int64(blockSize), pos-start)
+		pos -= size
+		block := buf[:size]
+		if _, err := f.ReadAt(block, pos); err != nil {
+			return 0, err
+		}
+		for i := len(block); i > 0; {
+			i = bytes.LastIndexByte(block[:i], delim)
+			if i < 0 {
+				break
+			}
+			if n--; n == 0 {
+				return pos + int64(i) + 1, nil
+			}
+		}
+	}
+	return start, nil
+}
@@ -1,20 +1,36 @@
 package tail
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/follow"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
 )
 
 // Options holds tail configuration
 type Options struct {
-	Lines int
-	Bytes int
-	Quiet bool
+	Lines          int
+	Bytes          int
+	FromStart      bool // print from line Lines or byte Bytes on rather than the last ones
+	Quiet          bool
+	ZeroTerminated bool // lines end in NUL rather than newline
+
+	Follow        bool    // keep printing what is appended to the files
+	Pid           int     // with Follow, stop once this process has ended
+	SleepInterval float64 // with Follow, seconds between checks for new data
 }
 
 // Command returns the tail command
@@ -26,77 +42,213 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "tail [flags] [files...]",
 		Short: "Output the last part of files",
-		Long:  `Print the last N lines (default 10) of each file to standard output. With no files, or when file is -, read standard input.`,
-		Args:  cobra.ArbitraryArgs,
+		Long: `Print the last N lines (default 10) of each file to standard output. With no files, or when file is -, read standard input.
+
+A count with a leading "+" gives the line or byte to start printing from
+instead, as in GNU tail: "tail -n +2" prints everything but the first line.
+
+-f keeps printing what is appended to the files until interrupted, naming
+the file before its data whenever there are several. A truncated file is
+printed again from the start and a replaced one, as after log rotation, is
+reopened. With --pid, following ends once that process has exited and
+everything it wrote has been printed, so a CI job can show a build log
+while the build runs:
+
+  build > build.log & claude-tools tail -f --pid $! build.log`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			files := args
+			ctx := cmd.Context()
+			files := glob.Expand(args)
+
+			if opts.SleepInterval < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --sleep-interval %g", opts.SleepInterval))
+			}
+			if opts.Pid != 0 && !opts.Follow {
+				logging.Warn("--pid is ignored without --follow")
+			}
 
 			// If no files specified, read from stdin
 			if len(files) == 0 {
-				return tailReader(os.Stdin, opts, "", len(files) > 1)
+				return tailReader(ctx, os.Stdin, opts, "", len(files) > 1)
 			}
 
-			// Process each file
+			// Process each file, keeping those to follow open
+			failed := false
+			var followed []*follow.File
+			current := -1
 			for i, file := range files {
-				if file == "-" {
-					if err := tailReader(os.Stdin, opts, "standard input", len(files) > 1); err != nil {
-						eve.Logger.Error("Failed to read stdin:", err)
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				if input.IsStdin(file) {
+					if err := tailReader(ctx, os.Stdin, opts, "standard input", len(files) > 1); err != nil {
+						logging.Error("Failed to read stdin:", err)
+						failed = true
 					}
 				} else {
-					if err := tailFile(file, opts, len(files) > 1); err != nil {
-						eve.Logger.Error("Failed to read file", file, ":", err)
+					f, err := tailFile(ctx, file, opts, len(files) > 1)
+					if err != nil {
+						logging.PathError("Failed to read file", file, err)
+						failed = true
+					}
+					if f != nil {
+						followed = append(followed, f)
+						current = len(followed) - 1
 					}
 				}
 
 				// Add blank line between files (except after last)
 				if i < len(files)-1 && len(files) > 1 {
-					fmt.Println()
+					fmt.Fprintln(output.Stdout)
+				}
+				if i < len(files)-1 {
+					current = -1
 				}
 			}
 
-			return nil
+			if len(followed) == 0 {
+				return exitcode.Failed(cmd, failed)
+			}
+			defer func() {
+				for _, f := range followed {
+					f.Close()
+				}
+			}()
+			// Following only ends with an interrupt, which isn't an error
+			if err := followFiles(ctx, followed, current, opts); err != nil && !interrupt.Interrupted(err) {
+				return err
+			}
+			return exitcode.Failed(cmd, failed)
 		},
 	}
 
-	cmd.Flags().IntVarP(&opts.Lines, "lines", "n", 10, "Output the last N lines")
-	cmd.Flags().IntVarP(&opts.Bytes, "bytes", "c", 0, "Output the last N bytes")
+	cmd.SetFlagErrorFunc(exitcode.Usage)
+
+	cmd.Flags().VarP(&countFlag{&opts.Lines, &opts.FromStart}, "lines", "n", "Output the last N lines; with a leading \"+\", start at line N")
+	cmd.Flags().VarP(&countFlag{&opts.Bytes, &opts.FromStart}, "bytes", "c", "Output the last N bytes; with a leading \"+\", start at byte N")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Never print headers giving file names")
+	cmd.Flags().BoolVarP(&opts.ZeroTerminated, "zero-terminated", "z", false, "Lines are terminated by NUL instead of newline")
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "Keep printing data appended to the files")
+	cmd.Flags().IntVar(&opts.Pid, "pid", 0, "With -f, stop once process `PID` has exited")
+	cmd.Flags().Float64VarP(&opts.SleepInterval, "sleep-interval", "s", follow.Interval.Seconds(), "With -f, check for new data every `SECONDS`")
 
 	return cmd
 }
 
-// tailFile reads and displays the last part of a file
-func tailFile(filename string, opts *Options, multipleFiles bool) error {
+// delim returns the byte lines end in
+func (opts *Options) delim() byte {
+	if opts.ZeroTerminated {
+		return 0
+	}
+	return '\n'
+}
+
+// countFlag is the value of -n or -c: a count from the end, or with a
+// leading "+" the line or byte to start from. A leading "-" is allowed and
+// means the same as none.
+type countFlag struct {
+	n         *int
+	fromStart *bool
+}
+
+func (f *countFlag) String() string {
+	if *f.fromStart {
+		return "+" + strconv.Itoa(*f.n)
+	}
+	return strconv.Itoa(*f.n)
+}
+
+func (f *countFlag) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid count %q", value)
+	}
+	*f.fromStart = strings.HasPrefix(value, "+")
+	if *f.fromStart {
+		// Like line 1, line 0 is the start of the input
+		n = max(n, 1)
+	}
+	*f.n = max(n, -n)
+	return nil
+}
+
+func (f *countFlag) Type() string {
+	return "int"
+}
+
+// tailFile reads and displays the last part of a file. With --follow, the
+// file is returned ready to follow from the end of what was displayed.
+func tailFile(ctx context.Context, filename string, opts *Options, multipleFiles bool) (*follow.File, error) {
+	// A URL is read through to the end, and can't be followed
+	if input.IsRemote(filename) {
+		r, err := input.OpenContext(ctx, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer r.Close()
+		if opts.Follow {
+			logging.Warn(fmt.Sprintf("'%s' is a URL and cannot be followed", filename))
+		}
+		return nil, tailReader(ctx, r, opts, filename, multipleFiles)
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	return tailReader(file, opts, filename, multipleFiles)
+	if err := tailReader(ctx, file, opts, filename, multipleFiles); err != nil || !opts.Follow {
+		file.Close()
+		return nil, err
+	}
+	f, err := follow.FromFile(ctx, filename, file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to follow file: %w", err)
+	}
+	return f, nil
 }
 
-// tailReader reads and displays the last part from a reader
-func tailReader(reader io.Reader, opts *Options, filename string, multipleFiles bool) error {
+// tailReader reads and displays the last part from a reader. A regular
+// file is read backwards from its end; anything else is read through to
+// the end, keeping the last lines in a ring buffer.
+func tailReader(ctx context.Context, reader io.Reader, opts *Options, filename string, multipleFiles bool) error {
 	// Print header if multiple files and not quiet
 	if multipleFiles && !opts.Quiet && filename != "" {
-		fmt.Printf("==> %s <==\n", filename)
+		fmt.Fprintf(output.Stdout, "==> %s <==\n", filename)
 	}
 
-	// Handle byte mode
-	if opts.Bytes > 0 {
+	if f, ok := reader.(*os.File); ok && !(opts.FromStart && opts.Bytes == 0) {
+		if start, end, ok := seekable(f); ok {
+			return tailSeek(ctx, f, start, end, opts)
+		}
+	}
+	reader = interrupt.Reader(ctx, reader)
+
+	switch {
+	case opts.FromStart && opts.Bytes > 0:
+		return tailBytesFrom(reader, opts.Bytes)
+	case opts.FromStart:
+		return tailLinesFrom(reader, opts.Lines, opts.delim())
+	case opts.Bytes > 0:
 		return tailBytes(reader, opts.Bytes)
+	case opts.Lines <= 0:
+		return nil
 	}
 
 	// Handle line mode (default)
 	// Read all lines into a circular buffer
-	lines := make([]string, opts.Lines)
-	scanner := bufio.NewScanner(reader)
+	ring := make([]string, opts.Lines)
+	scanner := lines.NewReader(reader)
+	scanner.SetDelimiter(opts.delim())
 	index := 0
 	count := 0
+	terminated := true
 
 	for scanner.Scan() {
-		lines[index%opts.Lines] = scanner.Text()
+		ring[index%opts.Lines] = scanner.Text()
+		terminated = scanner.Terminated()
 		index++
 		count++
 	}
@@ -117,7 +269,12 @@ func tailReader(reader io.Reader, opts *Options, filename string, multipleFiles
 	}
 
 	for i := 0; i < numLines; i++ {
-		fmt.Println(lines[(start+i)%opts.Lines])
+		line := ring[(start+i)%opts.Lines]
+		// Only the final line can lack a terminator; reproduce it exactly
+		fmt.Fprint(output.Stdout, line)
+		if i < numLines-1 || terminated {
+			output.Stdout.Write([]byte{opts.delim()})
+		}
 	}
 
 	return nil
@@ -138,9 +295,43 @@ func tailBytes(reader io.Reader, n int) error {
 	}
 
 	// Write the last N bytes
-	if _, err := os.Stdout.Write(content[start:]); err != nil {
+	if _, err := output.Stdout.Write(content[start:]); err != nil {
 		return fmt.Errorf("error writing output: %w", err)
 	}
 
 	return nil
 }
+
+// tailLinesFrom displays the lines from line n on
+func tailLinesFrom(reader io.Reader, n int, delim byte) error {
+	scanner := lines.NewReader(reader)
+	scanner.SetDelimiter(delim)
+	for line := 1; scanner.Scan(); line++ {
+		if line < n {
+			continue
+		}
+		fmt.Fprint(output.Stdout, scanner.Text())
+		if scanner.Terminated() {
+			output.Stdout.Write([]byte{delim})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	return nil
+}
+
+// tailBytesFrom displays the bytes from byte n on
+func tailBytesFrom(reader io.Reader, n int) error {
+	if _, err := io.CopyN(io.Discard, reader, int64(n-1)); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	if _, err := io.Copy(output.Stdout, reader); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package tail
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/follow"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// capture runs fn and returns what it printed
+func capture(t *testing.T, fn func()) string {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed)
+}
+
+// runTail runs tailReader over reader and returns what it printed
+func runTail(t *testing.T, reader io.Reader, opts *Options) string {
+	return capture(t, func() {
+		require.NoError(t, tailReader(context.Background(), reader, opts, "", false))
+	})
+}
+
+// TestTailSeek tests that reading a file backwards prints the same as
+// reading it through a pipe
+func TestTailSeek(t *testing.T) {
+	long := strings.Repeat("x", blockSize+10)
+	inputs := map[string]string{
+		"empty":          "",
+		"one line":       "only\n",
+		"unterminated":   "a\nb\nc",
+		"blank lines":    "\n\n\n",
+		"fewer lines":    "1\n2\n",
+		"spans blocks":   strings.Repeat("line of text\n", 20000),
+		"long line":      "first\n" + long + "\nlast",
+		"newline at end": long + "\n",
+		"nul separated":  "a\x00b\nc\x00d\x00",
+	}
+	options := []*Options{
+		{Lines: 3},
+		{Lines: 1},
+		{Lines: 0},
+		{Lines: 10000},
+		{Bytes: 5},
+		{Bytes: blockSize * 2},
+		{Lines: 2, ZeroTerminated: true},
+	}
+
+	for name, input := range inputs {
+		path := filepath.Join(t.TempDir(), "input")
+		require.NoError(t, os.WriteFile(path, []byte(input), 0o644))
+
+		for _, opts := range options {
+			if opts.Lines == 0 && opts.Bytes == 0 {
+				// The ring buffer needs at least one line
+				f, err := os.Open(path)
+				require.NoError(t, err)
+				assert.Empty(t, runTail(t, f, opts), name)
+				f.Close()
+				continue
+			}
+
+			piped := runTail(t, io.MultiReader(strings.NewReader(input)), opts)
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			assert.Equal(t, piped, runTail(t, f, opts), "%s %+v", name, *opts)
+			f.Close()
+		}
+	}
+}
+
+// TestTailSeek_Offset tests that a file already partly read is only
+// searched from its current position
+func TestTailSeek_Offset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input")
+	require.NoError(t, os.WriteFile(path, []byte("1\n2\n3\n4\n"), 0o644))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+
+	assert.Equal(t, "3\n4\n", runTail(t, f, &Options{Lines: 10}))
+	pos, err := f.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), pos)
+}
+
+// TestTail_FromStart tests counts with a leading "+", which give the line
+// or byte to start from, for files and pipes alike
+func TestTail_FromStart(t *testing.T) {
+	content := "1\n2\n3\n4"
+	path := filepath.Join(t.TempDir(), "input")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	tests := []struct {
+		lines, bytes string
+		want         string
+	}{
+		{"+3", "", "3\n4"},
+		{"+0", "", content},
+		{"+9", "", ""},
+		{"-2", "", "3\n4"},
+		{"", "+5", "3\n4"},
+		{"", "+0", content},
+		{"", "+20", ""},
+	}
+
+	for _, tt := range tests {
+		opts := &Options{Lines: 10}
+		if tt.lines != "" {
+			require.NoError(t, (&countFlag{&opts.Lines, &opts.FromStart}).Set(tt.lines))
+		}
+		if tt.bytes != "" {
+			require.NoError(t, (&countFlag{&opts.Bytes, &opts.FromStart}).Set(tt.bytes))
+		}
+
+		assert.Equal(t, tt.want, runTail(t, io.MultiReader(strings.NewReader(content)), opts), "%+v", tt)
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, runTail(t, f, opts), "%+v", tt)
+		f.Close()
+	}
+
+	assert.Error(t, (&countFlag{new(int), new(bool)}).Set("ten"))
+}
+
+// TestFollowFiles tests that following prints appended data under the name
+// of the file it came from and ends once the --pid process is gone
+func TestFollowFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	require.NoError(t, os.WriteFile(a, []byte("1\n2\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("x\n"), 0o644))
+
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	require.NoError(t, exited.Run())
+	opts := &Options{Lines: 1, Follow: true, Pid: exited.Process.Pid, SleepInterval: 0.01}
+
+	printed := capture(t, func() {
+		fa, err := tailFile(context.Background(), a, opts, true)
+		require.NoError(t, err)
+		defer fa.Close()
+		fb, err := tailFile(context.Background(), b, opts, true)
+		require.NoError(t, err)
+		defer fb.Close()
+
+		appendTo(t, a, "3\n")
+		require.NoError(t, followFiles(context.Background(), []*follow.File{fa, fb}, 1, opts))
+	})
+	assert.Equal(t, "==> "+a+" <==\n2\n==> "+b+" <==\nx\n\n==> "+a+" <==\n3\n", printed)
+}
+
+// appendTo appends data to the file name
+func appendTo(t *testing.T, name, data string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
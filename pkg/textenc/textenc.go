@@ -0,0 +1,227 @@
+package textenc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// BOM identifies a byte order mark at the start of a text stream
+type BOM int
+
+const (
+	NoBOM BOM = iota
+	UTF8
+	UTF16LE
+	UTF16BE
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// String returns a human-readable name for the BOM
+func (b BOM) String() string {
+	switch b {
+	case UTF8:
+		return "UTF-8"
+	case UTF16LE:
+		return "UTF-16LE"
+	case UTF16BE:
+		return "UTF-16BE"
+	default:
+		return "none"
+	}
+}
+
+// Bytes returns the encoded byte order mark
+func (b BOM) Bytes() []byte {
+	switch b {
+	case UTF8:
+		return bomUTF8
+	case UTF16LE:
+		return bomUTF16LE
+	case UTF16BE:
+		return bomUTF16BE
+	default:
+		return nil
+	}
+}
+
+// DetectBOM inspects the first bytes of a stream for a byte order mark
+func DetectBOM(prefix []byte) BOM {
+	switch {
+	case bytes.HasPrefix(prefix, bomUTF8):
+		return UTF8
+	case bytes.HasPrefix(prefix, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(prefix, bomUTF16BE):
+		return UTF16BE
+	default:
+		return NoBOM
+	}
+}
+
+// NewDecoder returns a reader yielding UTF-8 without a BOM.
+//
+// A UTF-8 BOM is dropped, and UTF-16 input (which Windows tools such as
+// PowerShell's Out-File produce) is transcoded to UTF-8. Input without a
+// BOM is passed through untouched.
+func NewDecoder(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(3)
+
+	switch bom := DetectBOM(prefix); bom {
+	case UTF8:
+		br.Discard(len(bomUTF8))
+		return br
+	case UTF16LE:
+		br.Discard(len(bomUTF16LE))
+		return &utf16Reader{r: br, order: binary.LittleEndian}
+	case UTF16BE:
+		br.Discard(len(bomUTF16BE))
+		return &utf16Reader{r: br, order: binary.BigEndian}
+	default:
+		return br
+	}
+}
+
+// utf16Reader transcodes a UTF-16 stream to UTF-8
+type utf16Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	buf   [32 * 1024]byte
+	in    []byte // undecoded bytes, possibly half a code unit or pair
+	out   []byte // decoded UTF-8 waiting to be read
+	err   error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		n, err := u.r.Read(u.buf[:])
+		u.in = append(u.in, u.buf[:n]...)
+		u.err = err
+		u.decode(err != nil)
+	}
+
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// decode converts complete code units from in to out. When final is false,
+// a trailing high surrogate is kept until its pair arrives.
+func (u *utf16Reader) decode(final bool) {
+	i := 0
+	for ; i+1 < len(u.in); i += 2 {
+		r1 := rune(u.order.Uint16(u.in[i:]))
+
+		if utf16.IsSurrogate(r1) && r1 < 0xDC00 {
+			if i+3 >= len(u.in) {
+				if !final {
+					break
+				}
+				u.out = utf8.AppendRune(u.out, utf8.RuneError)
+				continue
+			}
+			r2 := rune(u.order.Uint16(u.in[i+2:]))
+			if r := utf16.DecodeRune(r1, r2); r != utf8.RuneError {
+				u.out = utf8.AppendRune(u.out, r)
+				i += 2
+				continue
+			}
+			u.out = utf8.AppendRune(u.out, utf8.RuneError)
+			continue
+		}
+
+		if utf16.IsSurrogate(r1) {
+			r1 = utf8.RuneError
+		}
+		u.out = utf8.AppendRune(u.out, r1)
+	}
+
+	u.in = u.in[i:]
+	if final && len(u.in) > 0 {
+		u.out = utf8.AppendRune(u.out, utf8.RuneError)
+		u.in = nil
+	}
+}
+
+// ToLF converts CRLF line endings to LF. Lone carriage returns are kept.
+func ToLF(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+// ToCRLF converts LF line endings to CRLF, leaving existing CRLF untouched
+func ToCRLF(data []byte) []byte {
+	out := make([]byte, 0, len(data)+bytes.Count(data, []byte("\n")))
+	for i, b := range data {
+		if b == '\n' && (i == 0 || data[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// LineEnding describes the line terminators used in a text
+type LineEnding int
+
+const (
+	NoLineEnding LineEnding = iota
+	LF
+	CRLF
+	Mixed
+)
+
+// String returns a human-readable name for the line ending
+func (e LineEnding) String() string {
+	switch e {
+	case LF:
+		return "LF"
+	case CRLF:
+		return "CRLF"
+	case Mixed:
+		return "mixed"
+	default:
+		return "none"
+	}
+}
+
+// DetectLineEnding reports which line terminators data uses
+func DetectLineEnding(data []byte) LineEnding {
+	crlf := bytes.Count(data, []byte("\r\n"))
+	lf := bytes.Count(data, []byte("\n")) - crlf
+
+	switch {
+	case crlf == 0 && lf == 0:
+		return NoLineEnding
+	case crlf == 0:
+		return LF
+	case lf == 0:
+		return CRLF
+	default:
+		return Mixed
+	}
+}
+
+// IsBinary guesses whether data is binary by looking for NUL bytes in the
+// first 8000 bytes, the same heuristic git uses. UTF-16 text with a BOM is
+// not considered binary.
+func IsBinary(data []byte) bool {
+	if bom := DetectBOM(data); bom == UTF16LE || bom == UTF16BE {
+		return false
+	}
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
@@ -0,0 +1,111 @@
+package textenc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeUTF16 encodes s as UTF-16 with a BOM in the given byte order
+func encodeUTF16(s string, bigEndian bool) []byte {
+	var out []byte
+	if bigEndian {
+		out = append(out, bomUTF16BE...)
+	} else {
+		out = append(out, bomUTF16LE...)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+// TestDetectBOM tests byte order mark detection
+func TestDetectBOM(t *testing.T) {
+	assert.Equal(t, UTF8, DetectBOM([]byte("\xEF\xBB\xBFhi")))
+	assert.Equal(t, UTF16LE, DetectBOM([]byte("\xFF\xFEh\x00")))
+	assert.Equal(t, UTF16BE, DetectBOM([]byte("\xFE\xFF\x00h")))
+	assert.Equal(t, NoBOM, DetectBOM([]byte("hi")))
+	assert.Equal(t, NoBOM, DetectBOM(nil))
+}
+
+// TestNewDecoder_UTF8BOM tests that a UTF-8 BOM is dropped
+func TestNewDecoder_UTF8BOM(t *testing.T) {
+	data, err := io.ReadAll(NewDecoder(bytes.NewReader([]byte("\xEF\xBB\xBF{\"a\":1}\n"))))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n", string(data))
+}
+
+// TestNewDecoder_UTF16 tests transcoding UTF-16 in both byte orders,
+// including characters outside the BMP that need surrogate pairs
+func TestNewDecoder_UTF16(t *testing.T) {
+	text := "héllo wörld 😀\r\nline two\r\n"
+
+	for _, bigEndian := range []bool{false, true} {
+		data, err := io.ReadAll(NewDecoder(bytes.NewReader(encodeUTF16(text, bigEndian))))
+		require.NoError(t, err)
+		assert.Equal(t, text, string(data))
+	}
+}
+
+// TestNewDecoder_UTF16SplitReads tests surrogate pairs split across reads
+func TestNewDecoder_UTF16SplitReads(t *testing.T) {
+	text := "a😀b😀c"
+	r := NewDecoder(&oneByteReader{data: encodeUTF16(text, false)})
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, text, string(data))
+}
+
+// TestNewDecoder_NoBOM tests that plain input passes through untouched
+func TestNewDecoder_NoBOM(t *testing.T) {
+	data, err := io.ReadAll(NewDecoder(bytes.NewReader([]byte("plain\r\n"))))
+	require.NoError(t, err)
+	assert.Equal(t, "plain\r\n", string(data))
+}
+
+// TestLineEndingConversion tests ToLF, ToCRLF and DetectLineEnding
+func TestLineEndingConversion(t *testing.T) {
+	dos := []byte("a\r\nb\r\n")
+	unix := []byte("a\nb\n")
+
+	assert.Equal(t, unix, ToLF(dos))
+	assert.Equal(t, dos, ToCRLF(unix))
+	assert.Equal(t, dos, ToCRLF(dos))
+	assert.Equal(t, []byte("a\rb\n"), ToLF([]byte("a\rb\r\n")))
+
+	assert.Equal(t, CRLF, DetectLineEnding(dos))
+	assert.Equal(t, LF, DetectLineEnding(unix))
+	assert.Equal(t, Mixed, DetectLineEnding([]byte("a\r\nb\n")))
+	assert.Equal(t, NoLineEnding, DetectLineEnding([]byte("a")))
+}
+
+// TestIsBinary tests the NUL-byte binary heuristic
+func TestIsBinary(t *testing.T) {
+	assert.True(t, IsBinary([]byte("a\x00b")))
+	assert.True(t, !IsBinary([]byte("text\n")))
+	assert.True(t, !IsBinary(encodeUTF16("text", false)))
+}
+
+// oneByteReader returns its data one byte per Read call
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
@@ -1,12 +1,24 @@
 package touch
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/dryrun"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
 )
 
 // Options holds touch configuration
@@ -16,6 +28,9 @@ type Options struct {
 	ModifyOnly bool
 	Timestamp  string
 	Verbose    bool
+	FilesFrom  string // file listing more files to touch, "-" for stdin
+	Null       bool   // names in FilesFrom end in NUL bytes
+	Jobs       int    // files from FilesFrom touched at once; 0 means one per CPU
 }
 
 // Command returns the touch command
@@ -27,8 +42,21 @@ func Command() *cobra.Command {
 		Short: "Create empty files or update file timestamps",
 		Long: `Update the access and modification times of each file to the current time.
 
-If a file does not exist, it is created empty, unless -c is specified.`,
-		Args: cobra.MinimumNArgs(1),
+If a file does not exist, it is created empty, unless -c is specified.
+
+--files-from FILE touches the files named in FILE, one per line, after
+those given as arguments; - reads the names from standard input, so that
+any number of them can be passed on from find without running into the
+command line length limit. With -0 the names end in NUL bytes instead, as
+find -print0 writes them. These files are touched several at a time (see
+--jobs); one that can't be touched is reported and the others are still
+touched.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.FilesFrom == "" {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Validate options
 			if opts.AccessOnly && opts.ModifyOnly {
@@ -47,17 +75,38 @@ If a file does not exist, it is created empty, unless -c is specified.`,
 				timestamp = time.Now()
 			}
 
-			for _, path := range args {
+			for _, path := range glob.Expand(args) {
+				if err := cmd.Context().Err(); err != nil {
+					return err
+				}
+
 				if err := touchFile(path, timestamp, opts); err != nil {
-					eve.Logger.Error("Failed to touch", path, ":", err)
+					logging.PathError("Failed to touch", path, err)
 					return err
 				}
 
-				if opts.Verbose {
+				if opts.Verbose && !dryrun.Enabled {
 					fmt.Printf("touched '%s'\n", path)
 				}
 			}
 
+			if opts.FilesFrom != "" {
+				list, err := input.Open(opts.FilesFrom)
+				if err != nil {
+					return fmt.Errorf("failed to open file list: %w", err)
+				}
+				defer list.Close()
+
+				failed, err := touchFrom(cmd.Context(), list, timestamp, opts)
+				if err != nil {
+					return err
+				}
+				if failed {
+					cmd.SilenceErrors = true
+					return exitcode.Status(1)
+				}
+			}
+
 			return nil
 		},
 	}
@@ -67,10 +116,70 @@ If a file does not exist, it is created empty, unless -c is specified.`,
 	cmd.Flags().BoolVarP(&opts.ModifyOnly, "modify", "m", false, "Change only the modification time")
 	cmd.Flags().StringVarP(&opts.Timestamp, "time", "t", "", "Use specified time instead of current time (format: YYYYMMDDhhmm[.ss])")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Explain what is being done")
+	cmd.Flags().StringVar(&opts.FilesFrom, "files-from", "", "Also touch the files named in `FILE`, one per line (- for standard input)")
+	cmd.Flags().BoolVarP(&opts.Null, "null", "0", false, "Names in the --files-from list end in NUL bytes")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Touch up to `N` listed files at once (default: number of CPUs)")
 
 	return cmd
 }
 
+// touchFrom touches the files named in list, with up to opts.Jobs at
+// once. Names are handed to the workers as they are read, so the list
+// never has to be held in memory. It reports whether any file could not be
+// touched.
+func touchFrom(ctx context.Context, list io.Reader, timestamp time.Time, opts *Options) (bool, error) {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+
+	names := make(chan string, 4*jobs)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Go(func() {
+			for path := range names {
+				if err := touchFile(path, timestamp, opts); err != nil {
+					logging.PathError("Failed to touch", path, err)
+					failed.Store(true)
+					continue
+				}
+				if opts.Verbose && !dryrun.Enabled {
+					fmt.Printf("touched '%s'\n", path)
+				}
+			}
+		})
+	}
+
+	reader := lines.NewReader(interrupt.Reader(ctx, list))
+	if opts.Null {
+		reader.SetDelimiter(0)
+	}
+	for reader.Scan() {
+		path := reader.Text()
+		if path == "" {
+			continue
+		}
+		select {
+		case names <- path:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(names)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return failed.Load(), err
+	}
+	if err := reader.Err(); err != nil {
+		return failed.Load(), fmt.Errorf("failed to read file list: %w", err)
+	}
+	return failed.Load(), nil
+}
+
 // touchFile creates or updates a file's timestamp
 func touchFile(path string, timestamp time.Time, opts *Options) error {
 	// Check if file exists
@@ -84,6 +193,11 @@ func touchFile(path string, timestamp time.Time, opts *Options) error {
 				return nil
 			}
 
+			if dryrun.Enabled {
+				dryrun.Report("create '%s'", path)
+				return nil
+			}
+
 			// Create empty file
 			file, err := os.Create(path)
 			if err != nil {
@@ -113,6 +227,11 @@ func touchFile(path string, timestamp time.Time, opts *Options) error {
 		accessTime = info.ModTime()
 	}
 
+	if dryrun.Enabled {
+		dryrun.Report("update timestamps of '%s'", path)
+		return nil
+	}
+
 	if err := os.Chtimes(path, accessTime, modifyTime); err != nil {
 		return fmt.Errorf("failed to update timestamps: %w", err)
 	}
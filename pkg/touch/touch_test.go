@@ -1,8 +1,11 @@
 package touch
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -335,3 +338,37 @@ func TestTouchFile_PreservesPermissions(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, originalMode.Perm(), info.Mode().Perm())
 }
+
+// TestTouchFrom tests touching the files of a list, separated by newlines
+// or NUL bytes, going on past ones that fail
+func TestTouchFrom(t *testing.T) {
+	tempDir := t.TempDir()
+	timestamp := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var names []string
+	for i := range 50 {
+		names = append(names, filepath.Join(tempDir, fmt.Sprintf("file %d.txt", i)))
+	}
+	missing := filepath.Join(tempDir, "no such dir", "file.txt")
+
+	list := strings.Join(names[:25], "\n") + "\n" + missing + "\n\n" + strings.Join(names[25:], "\n")
+	failed, err := touchFrom(context.Background(), strings.NewReader(list), timestamp, &Options{Jobs: 4})
+	require.NoError(t, err)
+	assert.True(t, failed)
+	for _, name := range names {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, timestamp.Unix(), info.ModTime().Unix())
+	}
+
+	newer := timestamp.Add(time.Hour)
+	list = strings.Join(names, "\x00") + "\x00"
+	failed, err = touchFrom(context.Background(), strings.NewReader(list), newer, &Options{Null: true})
+	require.NoError(t, err)
+	assert.False(t, failed)
+	for _, name := range names {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, newer.Unix(), info.ModTime().Unix())
+	}
+}
@@ -0,0 +1,76 @@
+package tree
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// measure reads the directory at path, at depth, and everything below it
+// for --du, which must know what a directory holds before printing it. It
+// returns the total size of the regular files below, however deep, that
+// aren't hidden or --ignore'd, and the entries the tree lists, each
+// directory with its own total and entries. The walker reports a
+// directory that can't be read, and the error is returned for renderers
+// that show it.
+func measure(ctx context.Context, path string, depth int, opts *Options) (int64, []*Node, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	entries, err := opts.walker.ReadDir(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	kept := entries[:0]
+	descend := map[string]bool{}
+	for _, entry := range entries {
+		if hidden(entry, opts) {
+			continue
+		}
+		entry, descend[entry.Name()] = opts.walker.Entry(path, entry)
+		kept = append(kept, entry)
+	}
+	infos, errs := opts.walker.Infos(ctx, kept)
+	for i := range kept {
+		if errs[i] == nil && infos[i] != nil {
+			kept[i] = fs.FileInfoToDirEntry(infos[i])
+		}
+	}
+	sortEntries(kept, opts)
+
+	listed := opts.Level < 0 || depth <= opts.Level
+	var total int64
+	var nodes []*Node
+	for _, entry := range kept {
+		fullPath := filepath.Join(path, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			logging.PathError("Failed to get info for", fullPath, err)
+			continue
+		}
+		n := &Node{Name: entry.Name(), Path: fullPath, Info: info}
+		if opts.FullPath {
+			n.Name = fullPath
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			n.Target, _ = os.Readlink(fullPath)
+		}
+		switch {
+		case info.IsDir() && descend[entry.Name()]:
+			n.Size, n.children, n.Err = measure(ctx, fullPath, depth+1, opts)
+			if ctx.Err() != nil {
+				return 0, nil, ctx.Err()
+			}
+		case info.Mode().IsRegular():
+			n.Size = info.Size()
+		}
+		total += n.Size
+		if listed && shown(entry, opts) {
+			nodes = append(nodes, n)
+		}
+	}
+	return total, nodes, nil
+}
@@ -0,0 +1,165 @@
+package tree
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+)
+
+// Node is an entry of the tree as renderers see it
+type Node struct {
+	Name   string // the base name, or with --full-path the path
+	Path   string
+	Info   fs.FileInfo
+	Target string // what a symbolic link points to
+	Err    error  // why a directory couldn't be read
+	Size   int64  // with --du, the size of a file or the total of the files below a directory
+
+	children []*Node // with --du, the entries of a directory, read ahead
+}
+
+// size returns the size to show for n: a directory's total with --du
+func (n *Node) size(opts *Options) int64 {
+	if opts.DU && n.Info.IsDir() {
+		return n.Size
+	}
+	return n.Info.Size()
+}
+
+// sizeShown reports whether the text and HTML trees show n's size
+func sizeShown(n *Node, opts *Options) bool {
+	return opts.DU || opts.ShowSize && !n.Info.IsDir()
+}
+
+// summary returns the report line of the text and HTML trees
+func summary(stats *Stats, opts *Options) string {
+	s := fmt.Sprintf("%d directories", stats.Dirs)
+	if !opts.DirsOnly {
+		s += fmt.Sprintf(", %d files", stats.Files)
+	}
+	if opts.DU {
+		s = formatSize(stats.Size) + " used in " + s
+	}
+	return s
+}
+
+// Type returns the kind of entry n is, in the words tree -J and -X use
+func (n *Node) Type() string {
+	switch mode := n.Info.Mode(); {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "link"
+	case mode&fs.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "char"
+	case mode&fs.ModeDevice != 0:
+		return "block"
+	}
+	return "file"
+}
+
+// renderer prints the tree as walkTree goes through it: the root, then
+// every entry at its depth, from 1, with each directory's contents after
+// it and leave called when they are done
+type renderer interface {
+	root(n *Node) error
+	entry(n *Node, depth int, last bool) error
+	leave(n *Node, depth int, last bool) error
+	report(stats *Stats) error
+}
+
+// newRenderer returns the renderer for the output format opts ask for
+func newRenderer(w io.Writer, root string, opts *Options) renderer {
+	switch {
+	case opts.JSON:
+		return &jsonRenderer{w: w, opts: opts}
+	case opts.XML:
+		return &xmlRenderer{w: w, opts: opts}
+	case opts.HTML != "":
+		return &htmlRenderer{w: w, dir: root, opts: opts}
+	}
+	return &textRenderer{w: w, opts: opts}
+}
+
+// branches draws the lines that lead from a directory to its entries
+type branches struct {
+	lasts []bool // whether each directory being listed is the last in its own
+}
+
+// prefix returns what goes before the name of an entry at depth, and
+// remembers whether it is the last for the entries below it
+func (b *branches) prefix(depth int, last bool) string {
+	b.lasts = b.lasts[:depth-1]
+	var s strings.Builder
+	for _, l := range b.lasts {
+		if l {
+			s.WriteString("    ")
+		} else {
+			s.WriteString("│   ")
+		}
+	}
+	if last {
+		s.WriteString("└── ")
+	} else {
+		s.WriteString("├── ")
+	}
+	b.lasts = append(b.lasts, last)
+	return s.String()
+}
+
+// textRenderer prints the tree as lines of text, as tree does by default
+type textRenderer struct {
+	w    io.Writer
+	opts *Options
+	branches
+}
+
+func (r *textRenderer) root(n *Node) error {
+	name := n.Name
+	if r.opts.color {
+		name = color.Paint(true, name, color.LSColors().For(n.Path, n.Info))
+	}
+	_, err := fmt.Fprintln(r.w, name)
+	return err
+}
+
+func (r *textRenderer) entry(n *Node, depth int, last bool) error {
+	displayName := n.Name
+	if r.opts.color {
+		displayName = color.Paint(true, displayName, color.LSColors().For(n.Path, n.Info))
+	}
+	if n.Info.IsDir() {
+		displayName += "/"
+	}
+
+	// Add size if requested
+	if sizeShown(n, r.opts) {
+		displayName = fmt.Sprintf("%s (%s)", displayName, formatSize(n.size(r.opts)))
+	}
+
+	// Add permissions if requested
+	if r.opts.ShowPerms {
+		displayName = fmt.Sprintf("[%s] %s", n.Info.Mode().String(), displayName)
+	}
+	_, err := fmt.Fprintf(r.w, "%s%s\n", r.prefix(depth, last), displayName)
+	return err
+}
+
+func (r *textRenderer) leave(*Node, int, bool) error {
+	return nil
+}
+
+func (r *textRenderer) report(stats *Stats) error {
+	if r.opts.NoIndent {
+		return nil
+	}
+	_, err := fmt.Fprintln(r.w, "\n"+summary(stats, r.opts))
+	return err
+}
@@ -0,0 +1,264 @@
+package tree
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// attr is a property of an entry in JSON, XML and HTML output
+type attr struct {
+	key   string
+	value any // string or number
+}
+
+// attrs returns what -J and -X print about n after its type, in order: its
+// name, the target of a link, size, modification time and with --perms
+// its mode
+func attrs(n *Node, opts *Options) []attr {
+	a := []attr{{"name", n.Name}}
+	if n.Target != "" {
+		a = append(a, attr{"target", n.Target})
+	}
+	a = append(a,
+		attr{"size", n.size(opts)},
+		attr{"time", n.Info.ModTime().Format(time.RFC3339)},
+	)
+	if opts.ShowPerms {
+		a = append(a,
+			attr{"mode", fmt.Sprintf("%04o", n.Info.Mode().Perm())},
+			attr{"prot", n.Info.Mode().String()},
+		)
+	}
+	return a
+}
+
+// indent returns the indentation of an entry at depth, the root being at 0
+func indent(depth int) string {
+	return strings.Repeat("  ", depth+1)
+}
+
+// jsonRenderer prints the tree as tree -J does: an array of the root
+// directory, holding its entries in "contents", and a report, with one
+// entry on each line
+type jsonRenderer struct {
+	w    io.Writer
+	opts *Options
+}
+
+// object returns n as the start of a JSON object, without its closing
+// brace
+func (r *jsonRenderer) object(n *Node) string {
+	var b strings.Builder
+	b.WriteString(`{"type":"` + n.Type() + `"`)
+	for _, a := range attrs(n, r.opts) {
+		fmt.Fprintf(&b, `,"%s":%s`, a.key, jsonValue(a.value))
+	}
+	if n.Err != nil {
+		fmt.Fprintf(&b, `,"error":%s`, jsonValue(n.Err.Error()))
+	}
+	return b.String()
+}
+
+// jsonValue returns v in JSON, leaving characters such as & that are only
+// special in HTML as they are
+func jsonValue(v any) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (r *jsonRenderer) root(n *Node) error {
+	_, err := fmt.Fprintf(r.w, "[\n%s%s,\"contents\":[\n", indent(0), r.object(n))
+	return err
+}
+
+func (r *jsonRenderer) entry(n *Node, depth int, last bool) error {
+	end := "}" + comma(last)
+	if n.Info.IsDir() {
+		end = `,"contents":[`
+	}
+	_, err := fmt.Fprintf(r.w, "%s%s%s\n", indent(depth), r.object(n), end)
+	return err
+}
+
+func (r *jsonRenderer) leave(n *Node, depth int, last bool) error {
+	_, err := fmt.Fprintf(r.w, "%s]}%s\n", indent(depth), comma(last))
+	return err
+}
+
+func (r *jsonRenderer) report(stats *Stats) error {
+	if r.opts.NoIndent {
+		_, err := io.WriteString(r.w, "]\n")
+		return err
+	}
+	files := ""
+	if !r.opts.DirsOnly {
+		files = fmt.Sprintf(`,"files":%d`, stats.Files)
+	}
+	if r.opts.DU {
+		files += fmt.Sprintf(`,"size":%d`, stats.Size)
+	}
+	_, err := fmt.Fprintf(r.w, ",\n%s{\"type\":\"report\",\"directories\":%d%s}\n]\n", indent(0), stats.Dirs, files)
+	return err
+}
+
+// comma returns the comma that follows an entry unless it is the last
+func comma(last bool) string {
+	if last {
+		return ""
+	}
+	return ","
+}
+
+// xmlRenderer prints the tree as tree -X does: a <tree> holding the root
+// <directory> and a <report>, an element for each entry named after its
+// type
+type xmlRenderer struct {
+	w    io.Writer
+	opts *Options
+}
+
+// start returns the start tag of n's element
+func (r *xmlRenderer) start(n *Node) string {
+	var b strings.Builder
+	b.WriteString("<" + n.Type())
+	for _, a := range attrs(n, r.opts) {
+		b.WriteString(" " + a.key + `="`)
+		xml.EscapeText(&b, []byte(fmt.Sprint(a.value)))
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// error returns the element saying why a directory at depth couldn't be
+// read, or ""
+func (r *xmlRenderer) error(n *Node, depth int) string {
+	if n.Err == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(indent(depth+1) + "<error>")
+	xml.EscapeText(&b, []byte(n.Err.Error()))
+	b.WriteString("</error>\n")
+	return b.String()
+}
+
+func (r *xmlRenderer) root(n *Node) error {
+	_, err := fmt.Fprintf(r.w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<tree>\n%s%s\n%s", indent(0), r.start(n), r.error(n, 0))
+	return err
+}
+
+func (r *xmlRenderer) entry(n *Node, depth int, last bool) error {
+	if n.Info.IsDir() {
+		_, err := fmt.Fprintf(r.w, "%s%s\n%s", indent(depth), r.start(n), r.error(n, depth))
+		return err
+	}
+	_, err := fmt.Fprintf(r.w, "%s%s</%s>\n", indent(depth), r.start(n), n.Type())
+	return err
+}
+
+func (r *xmlRenderer) leave(n *Node, depth int, last bool) error {
+	_, err := fmt.Fprintf(r.w, "%s</directory>\n", indent(depth))
+	return err
+}
+
+func (r *xmlRenderer) report(stats *Stats) error {
+	if !r.opts.NoIndent {
+		report := indent(0) + "<report>\n" + fmt.Sprintf("%s<directories>%d</directories>\n", indent(1), stats.Dirs)
+		if !r.opts.DirsOnly {
+			report += fmt.Sprintf("%s<files>%d</files>\n", indent(1), stats.Files)
+		}
+		if r.opts.DU {
+			report += fmt.Sprintf("%s<size>%d</size>\n", indent(1), stats.Size)
+		}
+		report += indent(0) + "</report>\n"
+		if _, err := io.WriteString(r.w, report); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(r.w, "</tree>\n")
+	return err
+}
+
+// htmlRenderer prints the tree as an HTML page, drawn as the text tree is
+// with each name a link to the entry below the base URL given to -H
+type htmlRenderer struct {
+	w    io.Writer
+	dir  string // the root of the tree, which links are relative to
+	opts *Options
+	branches
+}
+
+// link returns an <a> element for n
+func (r *htmlRenderer) link(n *Node) string {
+	href := strings.TrimSuffix(r.opts.HTML, "/") + "/"
+	if rel, err := filepath.Rel(r.dir, n.Path); err == nil && rel != "." {
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		href += strings.Join(segments, "/")
+		if n.Info.IsDir() {
+			href += "/"
+		}
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(n.Name))
+}
+
+func (r *htmlRenderer) root(n *Node) error {
+	_, err := fmt.Fprintf(r.w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Directory Tree</title>
+<style>
+body { font-family: sans-serif; }
+pre { line-height: 1.3; }
+a { text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>Directory Tree</h1>
+<pre>
+%s
+`, r.link(n))
+	return err
+}
+
+func (r *htmlRenderer) entry(n *Node, depth int, last bool) error {
+	name := r.link(n)
+	if n.Info.IsDir() {
+		name += "/"
+	}
+	if sizeShown(n, r.opts) {
+		name = fmt.Sprintf("%s (%s)", name, formatSize(n.size(r.opts)))
+	}
+	if r.opts.ShowPerms {
+		name = fmt.Sprintf("[%s] %s", n.Info.Mode().String(), name)
+	}
+	_, err := fmt.Fprintf(r.w, "%s%s\n", r.prefix(depth, last), name)
+	return err
+}
+
+func (r *htmlRenderer) leave(*Node, int, bool) error {
+	return nil
+}
+
+func (r *htmlRenderer) report(stats *Stats) error {
+	footer := "</pre>\n"
+	if !r.opts.NoIndent {
+		footer += "<hr>\n<p>" + summary(stats, r.opts) + "</p>\n"
+	}
+	_, err := io.WriteString(r.w, footer+"</body>\n</html>\n")
+	return err
+}
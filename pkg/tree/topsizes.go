@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/evalgo-org/claude-tools/pkg/largest"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// topSizes prints the largest entries below root for --top-sizes instead
+// of the tree. Hidden and --ignore'd entries are left out as the tree
+// leaves them out; every other regular file counts toward the totals of
+// its directories, however deep, while only the entries the tree would
+// show within --level, --pattern and --dirs-only are ranked.
+func topSizes(ctx context.Context, root string, opts *Options) error {
+	top := largest.New(opts.TopSizes)
+	err := opts.walker.Walk(ctx, root, func(path string, entry fs.DirEntry, depth int) error {
+		if depth == 0 {
+			return nil
+		}
+		if hidden(entry, opts) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// The tree lists the entries of root at level 0
+		rank := (opts.Level < 0 || depth-1 <= opts.Level) && shown(entry, opts)
+		if entry.IsDir() {
+			if rank {
+				top.Dir(path)
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		top.File(root, path, info.Size(), rank && !opts.DirsOnly)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return top.Write(output.Stdout)
+}
+
+// hidden reports whether the tree leaves an entry and everything below it
+// out: dot files without --all, and names matching --ignore
+func hidden(entry fs.DirEntry, opts *Options) bool {
+	name := entry.Name()
+	if !opts.AllFiles && strings.HasPrefix(name, ".") {
+		return true
+	}
+	if opts.IgnorePattern != "" {
+		if matched, _ := filepath.Match(opts.IgnorePattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// shown reports whether --pattern and --dirs-only let the tree list an
+// entry
+func shown(entry fs.DirEntry, opts *Options) bool {
+	if opts.DirsOnly && !entry.IsDir() {
+		return false
+	}
+	if opts.Pattern != "" {
+		matched, _ := filepath.Match(opts.Pattern, entry.Name())
+		return matched
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package tree
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -9,6 +11,12 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/color"
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
 )
 
 // Options holds tree configuration
@@ -24,13 +32,25 @@ type Options struct {
 	SortReverse   bool
 	NoIndent      bool
 	ShowSize      bool
+	DU            bool // size directories by the files below them
 	ShowPerms     bool
+	FollowLinks   bool
+	Gitignore     bool // skip paths ignored by .gitignore/.ignore files and .git itself
+	Jobs          int
+	TopSizes      int    // print the N largest entries instead of the tree
+	JSON          bool   // print the tree as JSON
+	XML           bool   // print the tree as XML
+	HTML          string // print the tree as HTML, linking below this base URL
+
+	color  bool // resolved from the global --color mode for stdout
+	walker *walk.Walker
 }
 
 // Stats holds tree statistics
 type Stats struct {
 	Dirs  int
 	Files int
+	Size  int64 // with --du, the total size of the files below the root
 }
 
 // Command returns the tree command
@@ -41,14 +61,68 @@ func Command() *cobra.Command {
 		Use:   "tree [directory]",
 		Short: "Display directory tree structure",
 		Long: `Display directory contents in a tree-like format.
-Shows files and directories in a hierarchical view.`,
+Shows files and directories in a hierarchical view. --gitignore leaves
+out what .gitignore and .ignore files exclude, such as node_modules and
+build output, and .git itself, for an overview of a project.
+
+--du shows the size of each directory as the total of the regular files
+below it, however deep --level lets the tree go, and the total of the
+whole tree in the report. Hidden and --ignore'd files don't count. The
+whole tree is read before anything is printed.
+
+--top-sizes N prints the N largest files and directories instead, with each
+directory sized by the regular files below it, however deep. --level,
+--pattern and --dirs-only limit which entries are ranked:
+
+  tree --top-sizes 10
+  tree -d -L 1 --top-sizes 5 /var
+
+-J, -X and -H print the tree as JSON, XML or an HTML page instead, as
+tree does, with the type, size and modification time of each entry and
+its permissions with --perms. -H takes the base URL that the HTML links
+to entries are made from:
+
+  tree -J -L 2 > tree.json
+  tree -H https://example.com/files /srv/files > index.html`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			dir := "."
 			if len(args) > 0 {
 				dir = args[0]
 			}
-			return treeDir(dir, opts)
+			if opts.TopSizes < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid --top-sizes %d", opts.TopSizes))
+			}
+			formats := 0
+			for _, set := range []bool{opts.JSON, opts.XML, cmd.Flags().Changed("html")} {
+				if set {
+					formats++
+				}
+			}
+			if formats > 1 {
+				return exitcode.New(2, errors.New("-J, -X and -H cannot be used together"))
+			}
+			if formats > 0 && opts.TopSizes > 0 {
+				return exitcode.New(2, errors.New("--top-sizes cannot be used with -J, -X or -H"))
+			}
+			if opts.DU && opts.TopSizes > 0 {
+				return exitcode.New(2, errors.New("--du cannot be used with --top-sizes"))
+			}
+			if cmd.Flags().Changed("html") && opts.HTML == "" {
+				// An empty base still makes the links relative to the root
+				opts.HTML = "."
+			}
+			opts.color = formats == 0 && color.Enabled(os.Stdout)
+			if err := treeDir(cmd.Context(), dir, opts); err != nil {
+				return err
+			}
+
+			// Unreadable directories were reported where they were found
+			if opts.walker.Failed() {
+				cmd.SilenceErrors = true
+				return exitcode.Status(1)
+			}
+			return nil
 		},
 	}
 
@@ -63,13 +137,21 @@ Shows files and directories in a hierarchical view.`,
 	cmd.Flags().BoolVarP(&opts.SortReverse, "reverse", "r", false, "Reverse sort order")
 	cmd.Flags().BoolVar(&opts.NoIndent, "noreport", false, "Don't print summary report")
 	cmd.Flags().BoolVarP(&opts.ShowSize, "size", "s", false, "Show file sizes")
+	cmd.Flags().BoolVar(&opts.DU, "du", false, "Show each directory's size as the total of the files below it, and the total in the report (implies -s)")
 	cmd.Flags().BoolVarP(&opts.ShowPerms, "perms", "p", false, "Show file permissions")
+	cmd.Flags().BoolVarP(&opts.FollowLinks, "follow", "l", false, "Follow symbolic links to directories")
+	cmd.Flags().BoolVar(&opts.Gitignore, "gitignore", false, "Skip paths ignored by .gitignore and .ignore files, and .git itself")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 0, "Look up file information with up to `N` calls at once (default: number of CPUs)")
+	cmd.Flags().IntVar(&opts.TopSizes, "top-sizes", 0, "Print the `N` largest files and directories instead of the tree")
+	cmd.Flags().BoolVarP(&opts.JSON, "json", "J", false, "Print the tree as JSON")
+	cmd.Flags().BoolVarP(&opts.XML, "xml", "X", false, "Print the tree as XML")
+	cmd.Flags().StringVarP(&opts.HTML, "html", "H", "", "Print the tree as HTML with links below base `URL`")
 
 	return cmd
 }
 
 // treeDir displays directory tree
-func treeDir(root string, opts *Options) error {
+func treeDir(ctx context.Context, root string, opts *Options) error {
 	// Verify directory exists
 	info, err := os.Stat(root)
 	if err != nil {
@@ -79,123 +161,130 @@ func treeDir(root string, opts *Options) error {
 		return fmt.Errorf("'%s' is not a directory", root)
 	}
 
+	opts.walker = &walk.Walker{Ignore: true, Gitignore: opts.Gitignore, FollowLinks: opts.FollowLinks, Jobs: opts.Jobs}
+	if opts.TopSizes > 0 {
+		return topSizes(ctx, root, opts)
+	}
 	stats := &Stats{}
 	fileCount := 0
-
-	// Print root
-	fmt.Println(root)
-
-	// Walk directory tree
-	err = walkTree(root, "", true, 0, opts, stats, &fileCount)
-	if err != nil {
+	r := newRenderer(output.Stdout, root, opts)
+
+	rootNode := &Node{Name: root, Path: root, Info: info}
+	var children []*Node
+	if opts.DU {
+		// Sizes are added up from the bottom, so the whole tree is read
+		// before any of it is printed
+		rootNode.Size, children, rootNode.Err = measure(ctx, root, 0, opts)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stats.Size = rootNode.Size
+	} else {
+		children, rootNode.Err = readNodes(ctx, root, 0, opts, fileCount)
+	}
+	if err := r.root(rootNode); err != nil {
+		return err
+	}
+	if err := walkTree(ctx, children, 1, opts, r, stats, &fileCount); err != nil {
 		return err
 	}
+	if err := r.leave(rootNode, 0, true); err != nil {
+		return err
+	}
+	return r.report(stats)
+}
 
-	// Print summary
-	if !opts.NoIndent {
-		fmt.Printf("\n%d directories", stats.Dirs)
-		if !opts.DirsOnly {
-			fmt.Printf(", %d files", stats.Files)
+// walkTree hands the nodes of a directory at depth to the renderer, each
+// directory followed by its contents
+func walkTree(ctx context.Context, nodes []*Node, depth int, opts *Options, r renderer, stats *Stats, fileCount *int) error {
+	for i, n := range nodes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.FileLimit > 0 && *fileCount >= opts.FileLimit {
+			break
+		}
+		last := i == len(nodes)-1
+
+		// Read a directory before handing it on, so that renderers
+		// know whether it could be
+		children := n.children
+		if n.Info.IsDir() && !opts.DU {
+			children, n.Err = readNodes(ctx, n.Path, depth, opts, *fileCount)
+		}
+		if err := r.entry(n, depth, last); err != nil {
+			return err
 		}
-		fmt.Println()
-	}
 
+		if !n.Info.IsDir() {
+			stats.Files++
+			*fileCount++
+			continue
+		}
+		stats.Dirs++
+		if err := walkTree(ctx, children, depth+1, opts, r, stats, fileCount); err != nil {
+			return err
+		}
+		if err := r.leave(n, depth, last); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// walkTree recursively walks directory tree
-func walkTree(path string, prefix string, isLast bool, depth int, opts *Options, stats *Stats, fileCount *int) error {
-	// Check depth limit
+// readNodes returns the entries of the directory at path, at depth,
+// filtered and sorted as the tree lists them: none beyond --level or once
+// --filelimit files have been listed. The walker reports a directory that
+// can't be read, and the error is returned for renderers that show it.
+func readNodes(ctx context.Context, path string, depth int, opts *Options, fileCount int) ([]*Node, error) {
 	if opts.Level >= 0 && depth > opts.Level {
-		return nil
+		return nil, nil
 	}
-
-	// Check file limit
-	if opts.FileLimit > 0 && *fileCount >= opts.FileLimit {
-		return nil
+	if opts.FileLimit > 0 && fileCount >= opts.FileLimit {
+		return nil, nil
 	}
 
-	// Read directory entries
-	entries, err := os.ReadDir(path)
+	entries, err := opts.walker.ReadDir(path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for i, entry := range entries {
+		entries[i], _ = opts.walker.Entry(path, entry)
 	}
 
 	// Filter entries
 	filtered := filterEntries(entries, opts)
 
-	// Sort entries
-	sortEntries(filtered, opts)
-
-	// Process each entry
-	for i, entry := range filtered {
-		if opts.FileLimit > 0 && *fileCount >= opts.FileLimit {
-			break
+	// Describe what is left all at once, so that sorting and printing
+	// don't wait on one lstat after another
+	infos, errs := opts.walker.Infos(ctx, filtered)
+	for i := range filtered {
+		if errs[i] == nil && infos[i] != nil {
+			filtered[i] = fs.FileInfoToDirEntry(infos[i])
 		}
+	}
 
-		isLastEntry := i == len(filtered)-1
-		name := entry.Name()
-		fullPath := filepath.Join(path, name)
-
-		// Build display name
-		displayName := name
-		if opts.FullPath {
-			displayName = fullPath
-		}
+	// Sort entries
+	sortEntries(filtered, opts)
 
-		// Get entry info for size/perms
+	nodes := make([]*Node, 0, len(filtered))
+	for _, entry := range filtered {
+		fullPath := filepath.Join(path, entry.Name())
 		info, err := entry.Info()
 		if err != nil {
+			logging.PathError("Failed to get info for", fullPath, err)
 			continue
 		}
-
-		// Add size if requested
-		if opts.ShowSize && !entry.IsDir() {
-			displayName = fmt.Sprintf("%s (%s)", displayName, formatSize(info.Size()))
-		}
-
-		// Add permissions if requested
-		if opts.ShowPerms {
-			displayName = fmt.Sprintf("[%s] %s", info.Mode().String(), displayName)
-		}
-
-		// Print entry
-		connector := "├── "
-		if isLastEntry {
-			connector = "└── "
-		}
-
-		if entry.IsDir() {
-			displayName += "/"
-		}
-
-		fmt.Printf("%s%s%s\n", prefix, connector, displayName)
-
-		// Update stats
-		if entry.IsDir() {
-			stats.Dirs++
-		} else {
-			stats.Files++
-			*fileCount++
+		n := &Node{Name: entry.Name(), Path: fullPath, Info: info}
+		if opts.FullPath {
+			n.Name = fullPath
 		}
-
-		// Recurse into directories
-		if entry.IsDir() {
-			newPrefix := prefix
-			if isLastEntry {
-				newPrefix += "    "
-			} else {
-				newPrefix += "│   "
-			}
-			err = walkTree(fullPath, newPrefix, isLastEntry, depth+1, opts, stats, fileCount)
-			if err != nil {
-				// Continue on error
-				continue
-			}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			n.Target, _ = os.Readlink(fullPath)
 		}
+		nodes = append(nodes, n)
 	}
-
-	return nil
+	return nodes, nil
 }
 
 // filterEntries filters directory entries based on options
@@ -0,0 +1,198 @@
+package tree
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/output"
+)
+
+// makeTree creates dir/a/b/c&d.txt and dir/a/e.txt with a fixed
+// modification time, and returns dir
+func makeTree(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b", "c&d.txt"), []byte("cd\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "e.txt"), []byte("hello\n"), 0644))
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, p := range []string{"a/b/c&d.txt", "a/e.txt", "a/b", "a"} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, p), mtime, mtime))
+	}
+	return dir
+}
+
+// runTree prints the tree of root and returns what was printed
+func runTree(t *testing.T, root string, opts *Options) string {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	require.NoError(t, treeDir(context.Background(), root, opts))
+	require.NoError(t, output.Flush())
+
+	printed, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(printed)
+}
+
+// TestTreeDir_Text tests the default text output
+func TestTreeDir_Text(t *testing.T) {
+	dir := makeTree(t)
+	assert.Equal(t, dir+`
+└── a/
+    ├── b/
+    │   └── c&d.txt
+    └── e.txt
+
+2 directories, 2 files
+`, runTree(t, dir, &Options{Level: -1, FileLimit: -1}))
+}
+
+// TestTreeDir_JSON tests that -J prints the hierarchy with each entry's
+// type, size and time, and the report
+func TestTreeDir_JSON(t *testing.T) {
+	dir := makeTree(t)
+	printed := runTree(t, dir, &Options{Level: -1, FileLimit: -1, JSON: true})
+
+	type entry struct {
+		Type        string  `json:"type"`
+		Name        string  `json:"name"`
+		Size        int64   `json:"size"`
+		Time        string  `json:"time"`
+		Contents    []entry `json:"contents"`
+		Directories int     `json:"directories"`
+		Files       int     `json:"files"`
+	}
+	var entries []entry
+	require.NoError(t, json.Unmarshal([]byte(printed), &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, entry{Type: "report", Directories: 2, Files: 2}, entries[1])
+
+	root := entries[0]
+	assert.Equal(t, "directory", root.Type)
+	assert.Equal(t, dir, root.Name)
+	require.Len(t, root.Contents, 1)
+	a := root.Contents[0]
+	assert.Equal(t, "a", a.Name)
+	require.Len(t, a.Contents, 2)
+	assert.Equal(t, "b", a.Contents[0].Name)
+	assert.Equal(t, []entry{{
+		Type: "file", Name: "c&d.txt", Size: 3,
+		Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Local().Format(time.RFC3339),
+	}}, a.Contents[0].Contents)
+	assert.Equal(t, "e.txt", a.Contents[1].Name)
+	assert.Equal(t, int64(6), a.Contents[1].Size)
+
+	assert.Contains(t, printed, `{"type":"file","name":"c&d.txt","size":3,`, "one entry per line, without HTML escapes")
+}
+
+// TestTreeDir_XML tests that -X prints an element for each entry, named
+// after its type
+func TestTreeDir_XML(t *testing.T) {
+	dir := makeTree(t)
+	printed := runTree(t, dir, &Options{Level: -1, FileLimit: -1, XML: true, ShowPerms: true})
+
+	type element struct {
+		XMLName  xml.Name
+		Name     string    `xml:"name,attr"`
+		Size     int64     `xml:"size,attr"`
+		Mode     string    `xml:"mode,attr"`
+		Children []element `xml:",any"`
+	}
+	var doc struct {
+		Directory element `xml:"directory"`
+		Report    struct {
+			Directories int `xml:"directories"`
+			Files       int `xml:"files"`
+		} `xml:"report"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(printed), &doc))
+	assert.Equal(t, 2, doc.Report.Directories)
+	assert.Equal(t, 2, doc.Report.Files)
+
+	a := doc.Directory.Children[0]
+	assert.Equal(t, "directory", a.XMLName.Local)
+	assert.Equal(t, "a", a.Name)
+	require.Len(t, a.Children, 2)
+	c := a.Children[0].Children[0]
+	assert.Equal(t, "file", c.XMLName.Local)
+	assert.Equal(t, "c&d.txt", c.Name)
+	assert.Equal(t, int64(3), c.Size)
+	assert.Equal(t, "0644", c.Mode)
+}
+
+// TestTreeDir_HTML tests that -H draws the tree with links below the base
+// URL
+func TestTreeDir_HTML(t *testing.T) {
+	dir := makeTree(t)
+	printed := runTree(t, dir, &Options{Level: -1, FileLimit: -1, HTML: "https://example.com/files/", NoIndent: true})
+
+	assert.Contains(t, printed, "<pre>\n")
+	assert.Contains(t, printed, `└── <a href="https://example.com/files/a/">a</a>/`)
+	assert.Contains(t, printed, `│   └── <a href="https://example.com/files/a/b/c&amp;d.txt">c&amp;d.txt</a>`)
+	assert.Contains(t, printed, `    └── <a href="https://example.com/files/a/e.txt">e.txt</a>`)
+	assert.NotContains(t, printed, "directories")
+}
+
+// TestTreeDir_Gitignore tests that --gitignore leaves out ignored paths
+// and .git, and that directories below them aren't counted
+func TestTreeDir_Gitignore(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{".git", "node_modules/pkg", "src"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, d), 0755))
+	}
+	for name, content := range map[string]string{
+		".gitignore":            "node_modules/\n*.o\n",
+		"node_modules/pkg/x.js": "",
+		"src/main.go":           "",
+		"src/main.o":            "",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	assert.Equal(t, dir+`
+├── .gitignore
+└── src/
+    └── main.go
+
+1 directories, 2 files
+`, runTree(t, dir, &Options{Level: -1, FileLimit: -1, AllFiles: true, Gitignore: true}))
+}
+
+// TestTreeDir_DU tests that --du sizes directories by the files below
+// them, including those beyond --level, and totals the tree in the report
+func TestTreeDir_DU(t *testing.T) {
+	dir := makeTree(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), make([]byte, 5000), 0644))
+
+	assert.Equal(t, dir+`
+└── a/ (9B)
+    ├── b/ (3B)
+    │   └── c&d.txt (3B)
+    └── e.txt (6B)
+
+9B used in 2 directories, 2 files
+`, runTree(t, dir, &Options{Level: -1, FileLimit: -1, DU: true}))
+
+	assert.Equal(t, dir+`
+└── a/ (9B)
+
+9B used in 1 directories
+`, runTree(t, dir, &Options{Level: 0, FileLimit: -1, DU: true, DirsOnly: true}))
+
+	printed := runTree(t, dir, &Options{Level: -1, FileLimit: -1, DU: true, JSON: true})
+	assert.Contains(t, printed, `{"type":"directory","name":"a","size":9,`)
+	assert.Contains(t, printed, `{"type":"report","directories":2,"files":2,"size":9}`)
+}
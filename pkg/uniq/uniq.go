@@ -2,12 +2,19 @@ package uniq
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
 )
 
 // Options holds uniq configuration
@@ -16,6 +23,21 @@ type Options struct {
 	Repeated   bool
 	Unique     bool
 	IgnoreCase bool
+	Key        int    // compare only this 1-based field
+	Delimiter  string // field separator for Key; blank runs if empty
+	SkipFields int    // ignore the first N blank-separated fields
+	SkipChars  int    // then ignore N characters
+	CheckChars int    // then compare at most N characters; 0 for all
+
+	ZeroTerminated bool // lines end in NUL rather than newline
+}
+
+// delim returns the byte lines end in
+func (opts *Options) delim() byte {
+	if opts.ZeroTerminated {
+		return 0
+	}
+	return '\n'
 }
 
 // Command returns the uniq command
@@ -25,33 +47,67 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "uniq [flags] [input [output]]",
 		Short: "Report or omit repeated lines",
-		Long:  `Filter adjacent matching lines from input (or standard input), writing to output (or standard output).`,
-		Args:  cobra.MaximumNArgs(2),
+		Long: `Filter adjacent matching lines from input (or standard input), writing to output (or standard output).
+
+With -k N only field N is compared, and the first line of each run of
+lines with the same field is printed. Fields are separated by runs of
+blanks, or by the string given with -t. As with whole lines, only adjacent
+lines are compared, so sort on the same field first:
+
+  sort -t, -k 1 rows.csv | uniq -t, -k 1
+
+-f N, -s N and -w N work as in coreutils: -f skips the first N fields,
+each a run of blanks and the non-blanks after it, -s then skips N more
+characters, and -w compares at most N characters of what is left. To
+drop duplicate log messages whatever their timestamps:
+
+  uniq -f 2 app.log          # "2024-05-01 12:00:01 message"
+
+-s and -w apply to the field of -k too, which can't be used with -f.
+
+-z reads and writes lines ending in NUL, for lists of file names from
+find --print0 or sort -z:
+
+  find . --name '*.log' --print0 | sort -z | uniq -z`,
+		Args: cobra.MaximumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var input io.Reader = os.Stdin
-			var output io.Writer = os.Stdout
+			if opts.Key < 0 {
+				return exitcode.New(2, fmt.Errorf("invalid field number %d", opts.Key))
+			}
+			if opts.Delimiter != "" && opts.Key == 0 {
+				return exitcode.New(2, errors.New("--delimiter needs --key"))
+			}
+			if opts.SkipFields < 0 || opts.SkipChars < 0 || opts.CheckChars < 0 {
+				return exitcode.New(2, errors.New("-f, -s and -w take a number that isn't negative"))
+			}
+			if opts.SkipFields > 0 && opts.Key > 0 {
+				return exitcode.New(2, errors.New("--skip-fields cannot be used with --key"))
+			}
+
+			var in io.Reader = os.Stdin
+			var out io.Writer = os.Stdout
 
 			// Open input file if specified
-			if len(args) >= 1 && args[0] != "-" {
+			if len(args) >= 1 && !input.IsStdin(args[0]) {
 				file, err := os.Open(args[0])
 				if err != nil {
 					return fmt.Errorf("failed to open input file: %w", err)
 				}
 				defer file.Close()
-				input = file
+				in = file
 			}
 
 			// Open output file if specified
-			if len(args) >= 2 {
+			if len(args) >= 2 && !input.IsStdin(args[1]) {
 				file, err := os.Create(args[1])
 				if err != nil {
 					return fmt.Errorf("failed to create output file: %w", err)
 				}
 				defer file.Close()
-				output = file
+				out = file
 			}
 
-			return processUniq(input, output, opts)
+			return processUniq(interrupt.Reader(cmd.Context(), in), out, opts)
 		},
 	}
 
@@ -59,13 +115,20 @@ func Command() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Repeated, "repeated", "d", false, "Only print duplicate lines, one for each group")
 	cmd.Flags().BoolVarP(&opts.Unique, "unique", "u", false, "Only print unique lines")
 	cmd.Flags().BoolVarP(&opts.IgnoreCase, "ignore-case", "i", false, "Ignore differences in case when comparing")
+	cmd.Flags().IntVarP(&opts.Key, "key", "k", 0, "Compare only field `N`, counting from 1")
+	cmd.Flags().StringVarP(&opts.Delimiter, "delimiter", "t", "", "Separate fields with `SEP` instead of blanks")
+	cmd.Flags().IntVarP(&opts.SkipFields, "skip-fields", "f", 0, "Avoid comparing the first `N` fields")
+	cmd.Flags().IntVarP(&opts.SkipChars, "skip-chars", "s", 0, "Avoid comparing the first `N` characters")
+	cmd.Flags().IntVarP(&opts.CheckChars, "check-chars", "w", 0, "Compare no more than `N` characters in lines")
+	cmd.Flags().BoolVarP(&opts.ZeroTerminated, "zero-terminated", "z", false, "Lines are terminated by NUL instead of newline")
 
 	return cmd
 }
 
 // processUniq processes input and writes unique lines to output
 func processUniq(input io.Reader, output io.Writer, opts *Options) error {
-	scanner := bufio.NewScanner(input)
+	scanner := lines.NewReader(input)
+	scanner.SetDelimiter(opts.delim())
 	writer := bufio.NewWriter(output)
 	defer writer.Flush()
 
@@ -112,12 +175,69 @@ func processUniq(input io.Reader, output io.Writer, opts *Options) error {
 
 // getCompareLine returns the line to use for comparison
 func getCompareLine(line string, opts *Options) string {
+	if opts.Key > 0 {
+		line = field(line, opts.Key, opts.Delimiter)
+	}
+	line = skipFields(line, opts.SkipFields)
+	line = line[advance(line, opts.SkipChars):]
+	if opts.CheckChars > 0 {
+		line = line[:advance(line, opts.CheckChars)]
+	}
 	if opts.IgnoreCase {
 		return strings.ToLower(line)
 	}
 	return line
 }
 
+// field returns the nth field of line, or "" if it has fewer
+func field(line string, n int, delimiter string) string {
+	if delimiter == "" {
+		fields := strings.Fields(line)
+		if n > len(fields) {
+			return ""
+		}
+		return fields[n-1]
+	}
+
+	for i := 1; i < n; i++ {
+		j := strings.Index(line, delimiter)
+		if j < 0 {
+			return ""
+		}
+		line = line[j+len(delimiter):]
+	}
+	if j := strings.Index(line, delimiter); j >= 0 {
+		return line[:j]
+	}
+	return line
+}
+
+// skipFields returns line without its first n fields, each blanks and
+// the non-blanks after them, as uniq -f counts them. The blanks before
+// the next field are kept.
+func skipFields(line string, n int) string {
+	i := 0
+	for ; n > 0 && i < len(line); n-- {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+	}
+	return line[i:]
+}
+
+// advance returns the offset n characters into line, or its length
+func advance(line string, n int) int {
+	i := 0
+	for ; n > 0 && i < len(line); n-- {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	return i
+}
+
 // outputLine outputs a line according to options
 func outputLine(writer io.Writer, line string, count int, opts *Options) error {
 	// Apply filtering
@@ -133,9 +253,9 @@ func outputLine(writer io.Writer, line string, count int, opts *Options) error {
 	// Format output
 	var output string
 	if opts.Count {
-		output = fmt.Sprintf("%7d %s\n", count, line)
+		output = fmt.Sprintf("%7d %s%c", count, line, opts.delim())
 	} else {
-		output = fmt.Sprintf("%s\n", line)
+		output = line + string(opts.delim())
 	}
 
 	if _, err := fmt.Fprint(writer, output); err != nil {
@@ -0,0 +1,62 @@
+package uniq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runUniq runs uniq over input and returns what it wrote
+func runUniq(t *testing.T, input string, opts *Options) string {
+	var out strings.Builder
+	require.NoError(t, processUniq(strings.NewReader(input), &out, opts))
+	return out.String()
+}
+
+// TestProcessUniq_Key tests comparing a single field, separated by blanks
+// or by a delimiter
+func TestProcessUniq_Key(t *testing.T) {
+	rows := "1,alice,x\n1,alice,y\n2,bob,x\n3,bob,x\n3,Bob,z\n"
+	assert.Equal(t, "1,alice,x\n2,bob,x\n3,bob,x\n", runUniq(t, rows, &Options{Key: 1, Delimiter: ","}))
+	assert.Equal(t, "      2 1,alice,x\n      3 2,bob,x\n", runUniq(t, rows, &Options{Key: 2, Delimiter: ",", Count: true, IgnoreCase: true}))
+
+	// Runs of blanks separate fields, and a missing field compares as empty
+	assert.Equal(t, "a  1\nc 2\nd\n", runUniq(t, "a  1\nb\t1\nc 2\nd\ne\n", &Options{Key: 2}))
+}
+
+// TestField tests picking a field by number
+func TestField(t *testing.T) {
+	assert.Equal(t, "b", field("a::b::c", 2, "::"))
+	assert.Equal(t, "c", field("a,b,c", 3, ","))
+	assert.Equal(t, "", field("a,b,c", 4, ","))
+	assert.Equal(t, "", field("a,,c", 2, ","))
+	assert.Equal(t, "b", field("  a   b ", 2, ""))
+}
+
+// TestProcessUniq_Skip tests -f, -s and -w against what coreutils uniq
+// prints for the same input
+func TestProcessUniq_Skip(t *testing.T) {
+	log := "2024-05-01 12:00:01 disk full\n2024-05-01 12:00:09 disk full\n2024-05-01 12:01:00 Disk full\n2024-05-02 08:00:00 started\n"
+	assert.Equal(t, "2024-05-01 12:00:01 disk full\n2024-05-01 12:01:00 Disk full\n2024-05-02 08:00:00 started\n",
+		runUniq(t, log, &Options{SkipFields: 2}))
+	assert.Equal(t, "      3 2024-05-01 12:00:01 disk full\n      1 2024-05-02 08:00:00 started\n",
+		runUniq(t, log, &Options{SkipFields: 2, IgnoreCase: true, Count: true}))
+
+	// -s skips characters after the fields, -w limits what is compared
+	assert.Equal(t, "x-apple\nz-banana\n", runUniq(t, "x-apple\ny-apple\nz-banana\n", &Options{SkipChars: 2}))
+	assert.Equal(t, "ab1\nac1\n", runUniq(t, "ab1\nab2\nac1\n", &Options{CheckChars: 2}))
+	assert.Equal(t, "a xy1\nb zz1\n", runUniq(t, "a xy1\nb xy2\nb zz1\n", &Options{SkipFields: 1, SkipChars: 1, CheckChars: 2}))
+
+	// Characters, not bytes
+	assert.Equal(t, "äx\n", runUniq(t, "äx\nöx\n", &Options{SkipChars: 1}))
+}
+
+// TestProcessUniq_ZeroTerminated tests NUL-terminated lines, in which a
+// newline is an ordinary character, against what coreutils uniq -z prints
+func TestProcessUniq_ZeroTerminated(t *testing.T) {
+	input := "a b\x00a b\x00c\nd\x00c\nd\x00e"
+	assert.Equal(t, "a b\x00c\nd\x00e\x00", runUniq(t, input, &Options{ZeroTerminated: true}))
+	assert.Equal(t, "      2 a b\x00      2 c\nd\x00      1 e\x00", runUniq(t, input, &Options{ZeroTerminated: true, Count: true}))
+}
@@ -0,0 +1,323 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/evalgo-org/claude-tools/pkg/jsonpos"
+)
+
+// document is one value of an input file, as JSON Schema sees it
+type document struct {
+	value any
+	err   error // why the document couldn't be read, on a line of JSON Lines
+
+	// locate returns the line and column of the value at path, or false
+	// where the format doesn't say
+	locate func(path []string) (int, int, bool)
+}
+
+// parseError is input that isn't valid in its format. line is 0 where
+// the parser doesn't say where.
+type parseError struct {
+	line, col int
+	msg       string
+}
+
+func (e *parseError) Error() string {
+	return e.msg
+}
+
+// parse returns the documents of data, read as format
+func parse(data []byte, format string) ([]document, error) {
+	switch format {
+	case "json":
+		return parseJSON(data)
+	case "jsonl":
+		return parseJSONLines(data)
+	case "toml":
+		return parseTOML(data)
+	}
+	return parseYAML(data)
+}
+
+// parseJSON returns the JSON values of data, one after the other
+func parseJSON(data []byte) ([]document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var docs []document
+	for {
+		start := jsonpos.SkipSpace(data, dec.InputOffset())
+		var v any
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, jsonError(data, dec, err)
+		}
+		docs = append(docs, document{value: v, locate: func(path []string) (int, int, bool) {
+			offset, ok := locateJSON(data[start:], path)
+			line, col := jsonpos.Position(data, start+offset)
+			return line, col, ok
+		}})
+	}
+}
+
+// parseJSONLines returns the JSON value on each line of data, skipping
+// blank lines. A line that isn't one JSON value is a document with an
+// error, so that the lines after it are still checked.
+func parseJSONLines(data []byte) ([]document, error) {
+	var docs []document
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		lineDocs, err := parseJSON(line)
+		if err != nil {
+			var perr *parseError
+			if errors.As(err, &perr) {
+				perr.line = i + 1
+			}
+			docs = append(docs, document{err: err})
+			continue
+		}
+		if len(lineDocs) > 1 {
+			docs = append(docs, document{err: &parseError{line: i + 1, col: 1, msg: "more than one JSON value on the line"}})
+			continue
+		}
+		for _, doc := range lineDocs {
+			locate := doc.locate
+			doc.locate = func(path []string) (int, int, bool) {
+				_, col, ok := locate(path)
+				return i + 1, col, ok
+			}
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// yamlLine matches the position yaml.v3 puts in its error messages
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// parseYAML returns the documents of a YAML stream. JSON is YAML too.
+func parseYAML(data []byte) ([]document, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []document
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			perr := &parseError{msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+			if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+				perr.line, _ = strconv.Atoi(m[1])
+				perr.msg = err.Error()[len(m[0]):]
+			}
+			return nil, perr
+		}
+		if len(node.Content) == 0 || isEmpty(node.Content[0]) {
+			continue
+		}
+		var v any
+		if err := node.Decode(&v); err != nil {
+			return nil, &parseError{line: node.Line, col: node.Column, msg: err.Error()}
+		}
+		root := node.Content[0]
+		docs = append(docs, document{value: jsonValue(v), locate: func(path []string) (int, int, bool) {
+			n, ok := locateYAML(root, path)
+			return n.Line, n.Column, ok
+		}})
+	}
+}
+
+// isEmpty reports whether n is the null of a document with nothing in
+// it, as after a trailing ---
+func isEmpty(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null" && n.Value == "" && n.Style == 0
+}
+
+// parseTOML returns the single document of a TOML file
+func parseTOML(data []byte) ([]document, error) {
+	var v map[string]any
+	if _, err := toml.Decode(string(data), &v); err != nil {
+		var terr toml.ParseError
+		if errors.As(err, &terr) {
+			return nil, &parseError{line: terr.Position.Line, col: terr.Position.Col, msg: terr.Message}
+		}
+		return nil, &parseError{msg: err.Error()}
+	}
+	return []document{{value: jsonValue(v), locate: func([]string) (int, int, bool) {
+		return 0, 0, false
+	}}}, nil
+}
+
+// jsonValue converts what the YAML and TOML decoders return to the types
+// JSON decoding gives: objects with string keys, and strings for dates
+// and times
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	case []map[string]any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			a[i] = jsonValue(e)
+		}
+		return a
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		// TOML's local dates and times
+		return v.String()
+	}
+	return v
+}
+
+// locateYAML returns the node at path below n, or the deepest node on the
+// way there and false if there isn't one
+func locateYAML(n *yaml.Node, path []string) (*yaml.Node, bool) {
+	for _, key := range path {
+		for n.Kind == yaml.AliasNode && n.Alias != nil {
+			n = n.Alias
+		}
+		next := childYAML(n, key)
+		if next == nil {
+			return n, false
+		}
+		n = next
+	}
+	return n, true
+}
+
+// childYAML returns the value of key in a mapping, or an element of a
+// sequence, or nil
+func childYAML(n *yaml.Node, key string) *yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				return n.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(key); err == nil && 0 <= i && i < len(n.Content) {
+			return n.Content[i]
+		}
+	}
+	return nil
+}
+
+// locateJSON returns the offset in data, which starts with a JSON value,
+// of the value at path within it. If there is none, it returns the offset
+// of the deepest value on the way there and false.
+func locateJSON(data []byte, path []string) (int64, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var stack []*container
+	best := int64(0)
+
+	for {
+		before := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return best, false
+		}
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].expectKey {
+			if key, ok := tok.(string); ok {
+				stack[n-1].key = key
+				stack[n-1].expectKey = false
+				continue
+			}
+		}
+
+		if tok != json.Delim('}') && tok != json.Delim(']') {
+			// A value starts: see whether it is on the path
+			depth := len(stack)
+			if depth <= len(path) && onPath(stack, path) {
+				best = jsonpos.SkipSpace(data, before)
+				if depth == len(path) {
+					return best, true
+				}
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &container{object: true, expectKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &container{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+		// A value ended, so the next one in the container holding it
+		// comes next
+		if len(stack) == 0 {
+			return best, false
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			top.expectKey = true
+		} else {
+			top.index++
+		}
+	}
+}
+
+// container is an object or array that locateJSON is inside of, with the
+// key or index of the value in it being read
+type container struct {
+	object    bool
+	key       string
+	index     int
+	expectKey bool
+}
+
+// onPath reports whether the values being read in each open container
+// are those path leads through
+func onPath(stack []*container, path []string) bool {
+	for i, c := range stack {
+		name := c.key
+		if !c.object {
+			name = strconv.Itoa(c.index)
+		}
+		if name != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonError describes a JSON decoding error where it happened
+func jsonError(data []byte, dec *json.Decoder, err error) error {
+	line, col, msg := jsonpos.Error(data, dec, err)
+	return &parseError{line: line, col: col, msg: msg}
+}
@@ -0,0 +1,343 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/spf13/cobra"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/output"
+	"github.com/evalgo-org/claude-tools/pkg/textenc"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// Options holds validate configuration
+type Options struct {
+	Schema string // the JSON Schema file
+	Format string // auto, json, jsonl, yaml or toml
+}
+
+// formats are the values of --format
+var formats = []string{"auto", "json", "jsonl", "yaml", "toml"}
+
+// extensions are the formats of the files validate looks for in
+// directories, and reads when --format is auto
+var extensions = map[string]string{
+	".json":   "json",
+	".jsonl":  "jsonl",
+	".ndjson": "jsonl",
+	".yaml":   "yaml",
+	".yml":    "yaml",
+	".toml":   "toml",
+}
+
+// Command returns the validate command
+func Command() *cobra.Command {
+	opts := &Options{}
+
+	cmd := &cobra.Command{
+		Use:   "validate --schema SCHEMA [flags] [file|dir...]",
+		Short: "Check YAML, JSON and TOML files against a JSON Schema",
+		Long: `Check YAML, JSON and TOML documents against a JSON Schema, as in CI
+checks of a directory of configuration files. With no files, or when
+file is -, read standard input. Files may be glob patterns, and a
+directory stands for the .json, .jsonl, .ndjson, .yaml, .yml and .toml
+files below it other than the schema.
+
+The schema is a JSON or YAML file, or a URL, of any JSON Schema draft
+from draft 4 to 2020-12; the draft is taken from its $schema and is
+2020-12 without one. References to other schema files are resolved
+relative to it.
+
+A YAML file may hold several documents separated by ---, a JSON file
+several values one after another, and a .jsonl or .ndjson file one value
+on each line; each is checked on its own. The format comes from the
+file's extension, and is YAML, of which JSON is a part, for others and
+for standard input. --format reads every file in one format instead.
+
+Each problem is printed as FILE:LINE:COLUMN: PATH: message, where PATH
+is the jq path of the value that is wrong, such as .spec.ports[0].name.
+TOML files have no line and column. The exit status is 1 if any
+document was invalid or could not be read, and 2 if the schema could
+not be used.
+
+Examples:
+  validate --schema deploy.schema.json k8s/*.yaml
+  validate -s config.schema.yaml config/
+  kubectl get deploy -o json | validate -s deploy.schema.json`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if opts.Schema == "" {
+				return exitcode.New(2, errors.New("no schema given; use --schema"))
+			}
+			if !slices.Contains(formats, opts.Format) {
+				return exitcode.New(2, fmt.Errorf("invalid --format %q: must be one of %s", opts.Format, strings.Join(formats, ", ")))
+			}
+			schema, err := compile(ctx, opts.Schema)
+			if err != nil {
+				if interrupt.Interrupted(err) {
+					return err
+				}
+				return exitcode.New(2, err)
+			}
+			cmd.SilenceErrors = true
+
+			files, err := (&walk.Walker{}).ExpandDirs(ctx, glob.Expand(args), keepFile(opts.Schema))
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				files = []string{input.Stdin}
+			}
+			return validateFiles(ctx, schema, files, output.Stdout, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Schema, "schema", "s", "", "Check against the JSON Schema in `FILE`")
+	cmd.Flags().StringVar(&opts.Format, "format", "auto", "Read files as `FORMAT`: auto, json, jsonl, yaml or toml")
+
+	return cmd
+}
+
+// keepFile returns which files below a directory operand are checked:
+// those with the extension of a format validate reads, except the schema
+// itself when it lies in the directory
+func keepFile(schema string) func(path string) bool {
+	schemaInfo, _ := os.Stat(schema)
+	return func(path string) bool {
+		if _, ok := extensions[strings.ToLower(filepath.Ext(path))]; !ok {
+			return false
+		}
+		if schemaInfo != nil {
+			if info, err := os.Stat(path); err == nil && os.SameFile(info, schemaInfo) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// compile reads and compiles the schema in file
+func compile(ctx context.Context, file string) (*jsonschema.Schema, error) {
+	data, err := readFile(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema '%s': %w", file, err)
+	}
+	docs, err := parse(data, formatOf(file, "auto"))
+	if err == nil && len(docs) != 1 {
+		err = errors.New("want exactly one document")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema %s", parseProblem(err).format(file))
+	}
+
+	loc := file
+	if !input.IsRemote(file) {
+		if loc, err = filepath.Abs(file); err != nil {
+			return nil, err
+		}
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(loc, docs[0].value); err != nil {
+		return nil, fmt.Errorf("invalid schema '%s': %w", file, err)
+	}
+	schema, err := c.Compile(loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema '%s': %w", file, err)
+	}
+	return schema, nil
+}
+
+// problem is a way a document breaks the schema, or a reason it can't
+// be read
+type problem struct {
+	line, col int    // 0 where unknown
+	path      string // "" for documents that can't be read
+	msg       string
+}
+
+// parseProblem returns a parse error as a problem
+func parseProblem(err error) problem {
+	var perr *parseError
+	if errors.As(err, &perr) && perr.line > 0 {
+		return problem{line: perr.line, col: max(perr.col, 1), msg: perr.msg}
+	}
+	return problem{msg: err.Error()}
+}
+
+// validateFiles checks each file against schema, printing the problems
+// found to w, and returns status 1 if there were any
+func validateFiles(ctx context.Context, schema *jsonschema.Schema, files []string, w io.Writer, opts *Options) error {
+	failed := false
+	for _, file := range files {
+		data, err := readFile(ctx, file)
+		if err != nil {
+			if interrupt.Interrupted(err) {
+				return err
+			}
+			logging.PathError("Cannot read", file, err)
+			failed = true
+			continue
+		}
+
+		docs, err := parse(data, formatOf(file, opts.Format))
+		if err != nil {
+			failed = true
+			if _, err := fmt.Fprintln(w, parseProblem(err).format(file)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, doc := range docs {
+			var problems []problem
+			if doc.err != nil {
+				problems = []problem{parseProblem(doc.err)}
+			} else {
+				problems = check(schema, doc)
+			}
+			for _, p := range problems {
+				failed = true
+				if _, err := fmt.Fprintln(w, p.format(file)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if failed {
+		return exitcode.Status(1)
+	}
+	return nil
+}
+
+// format returns the problem as a line of output about file
+func (p problem) format(file string) string {
+	var b strings.Builder
+	b.WriteString(file)
+	if p.line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", p.line, p.col)
+	}
+	if p.path != "" {
+		b.WriteString(": " + p.path)
+	}
+	b.WriteString(": " + p.msg)
+	return b.String()
+}
+
+// printer words the validation errors
+var printer = message.NewPrinter(language.English)
+
+// check validates doc against schema and returns its problems in the
+// order they are found in the document
+func check(schema *jsonschema.Schema, doc document) []problem {
+	err := schema.Validate(doc.value)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		if err != nil {
+			return []problem{{path: ".", msg: err.Error()}}
+		}
+		return nil
+	}
+
+	var problems []problem
+	var leaves func(e *jsonschema.ValidationError)
+	leaves = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, c := range e.Causes {
+				leaves(c)
+			}
+			return
+		}
+		p := problem{path: jqPath(doc.value, e.InstanceLocation), msg: e.ErrorKind.LocalizedString(printer)}
+		p.line, p.col, _ = doc.locate(e.InstanceLocation)
+		problems = append(problems, p)
+	}
+	leaves(verr)
+
+	// The errors come in the order of the schema's keywords, some of them
+	// from maps
+	slices.SortStableFunc(problems, func(a, b problem) int {
+		if c := a.line - b.line; c != 0 {
+			return c
+		}
+		if c := a.col - b.col; c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.path, b.path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.msg, b.msg)
+	})
+	return slices.Compact(problems)
+}
+
+// identifier matches the object keys jq allows after a dot
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jqPath returns the location of a value in v as a jq path, such as
+// .spec.ports[0] or ."a key"
+func jqPath(v any, loc []string) string {
+	if len(loc) == 0 {
+		return "."
+	}
+	var b strings.Builder
+	for _, key := range loc {
+		switch val := v.(type) {
+		case []any:
+			b.WriteString("[" + key + "]")
+			if i, err := strconv.Atoi(key); err == nil && i < len(val) {
+				v = val[i]
+			}
+			continue
+		case map[string]any:
+			v = val[key]
+		}
+		if identifier.MatchString(key) {
+			b.WriteString("." + key)
+		} else {
+			b.WriteString("." + strconv.Quote(key))
+		}
+	}
+	return b.String()
+}
+
+// formatOf returns the format to read file in
+func formatOf(file, format string) string {
+	if format != "auto" {
+		return format
+	}
+	if f, ok := extensions[strings.ToLower(filepath.Ext(file))]; ok {
+		return f
+	}
+	return "yaml"
+}
+
+// readFile returns the contents of a file, or standard input
+func readFile(ctx context.Context, file string) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if !input.IsStdin(file) {
+		f, err := input.OpenContext(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return io.ReadAll(textenc.NewDecoder(interrupt.Reader(ctx, r)))
+}
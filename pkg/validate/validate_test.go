@@ -0,0 +1,117 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+)
+
+const testSchema = `type: object
+required: [name, port]
+properties:
+  name: {type: string}
+  port: {type: integer, minimum: 1}
+  tags: {type: array, items: {type: string}}
+additionalProperties: false
+`
+
+// TestValidateFiles tests problems in each format, with their positions
+// and paths, and documents that can't be read
+func TestValidateFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"schema.yaml": testSchema,
+		"a.yaml":      "name: web\nport: 0\ntags: [x, 2]\n---\nname: ok\nport: 1\n---\n",
+		"b.json":      "{\n  \"name\": \"x\",\n  \"port\": \"80\"\n}\n{\"name\": \"y\", \"port\": 1, \"extra\": true}\n",
+		"c.toml":      "name = \"z\"\nport = 3\ntags = [\"a\", 1]\n",
+		"d.jsonl":     "{\"name\":\"a\",\"port\":1}\n{bad\n\n{\"name\":\"a\",\"port\":-1}\n",
+		"e.yaml":      "name: [\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	ctx := context.Background()
+	schema, err := compile(ctx, filepath.Join(dir, "schema.yaml"))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	var paths []string
+	for _, name := range []string{"a.yaml", "b.json", "c.toml", "d.jsonl", "e.yaml"} {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	err = validateFiles(ctx, schema, paths, &out, &Options{Format: "auto"})
+	assert.Equal(t, 1, exitcode.From(err))
+
+	want := []string{
+		"a.yaml:2:7: .port: minimum: got 0, want 1",
+		"a.yaml:3:11: .tags[1]: got number, want string",
+		"b.json:3:11: .port: got string, want integer",
+		"b.json:5:1: .: additional properties 'extra' not allowed",
+		"c.toml: .tags[1]: got number, want string",
+		"d.jsonl:2:2: invalid character 'b' looking for beginning of object key string",
+		"d.jsonl:4:20: .port: minimum: got -1, want 1",
+		"e.yaml:1:1: did not find expected node content",
+	}
+	var b strings.Builder
+	for _, line := range want {
+		b.WriteString(filepath.Join(dir, line) + "\n")
+	}
+	assert.Equal(t, b.String(), out.String())
+
+	out.Reset()
+	err = validateFiles(ctx, schema, paths[:0], &out, &Options{Format: "auto"})
+	assert.NoError(t, err)
+}
+
+// TestKeepFile tests that a directory stands for the files of the formats
+// validate reads, but not for the schema itself
+func TestKeepFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"schema.json", "a.yaml", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+	t.Chdir(dir)
+
+	keep := keepFile("schema.json")
+	assert.False(t, keep(filepath.Join(dir, "schema.json")))
+	assert.True(t, keep(filepath.Join(dir, "a.yaml")))
+	assert.False(t, keep(filepath.Join(dir, "notes.txt")))
+	assert.True(t, keepFile("https://example.com/schema.json")(filepath.Join(dir, "schema.json")))
+}
+
+// TestLocateJSON tests finding the offset of a value by its path, and of
+// the deepest value on the way to one that isn't there
+func TestLocateJSON(t *testing.T) {
+	data := []byte(`{"a": [1, {"b": 2}], "c": {"a": 3}}`)
+	for _, tc := range []struct {
+		path   []string
+		offset int64
+		found  bool
+	}{
+		{nil, 0, true},
+		{[]string{"a"}, 6, true},
+		{[]string{"a", "1", "b"}, 16, true},
+		{[]string{"c", "a"}, 32, true},
+		{[]string{"c", "x"}, 26, false},
+	} {
+		offset, found := locateJSON(data, tc.path)
+		assert.Equal(t, tc.offset, offset, tc.path)
+		assert.Equal(t, tc.found, found, tc.path)
+	}
+}
+
+// TestJQPath tests paths through arrays, and keys that need quoting
+func TestJQPath(t *testing.T) {
+	v := map[string]any{"spec": map[string]any{"ports": []any{map[string]any{}}}}
+	assert.Equal(t, ".", jqPath(v, nil))
+	assert.Equal(t, ".spec.ports[0].name", jqPath(v, []string{"spec", "ports", "0", "name"}))
+	assert.Equal(t, `."a b"`, jqPath(v, []string{"a b"}))
+}
@@ -0,0 +1,44 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"os"
+
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// ExpandDirs replaces the directories among files with the regular files
+// below them that w visits, in walk order, for the commands that take a
+// directory as all the files in it. With keep, only the paths it accepts
+// are taken. Standard input, URLs and operands that aren't directories
+// are kept as they are, so that reading them reports any error;
+// unreadable directories are reported and skipped.
+func (w *Walker) ExpandDirs(ctx context.Context, files []string, keep func(path string) bool) ([]string, error) {
+	var expanded []string
+	for _, file := range files {
+		if input.IsStdin(file) || input.IsRemote(file) {
+			expanded = append(expanded, file)
+			continue
+		}
+		if info, err := os.Stat(file); err != nil || !info.IsDir() {
+			expanded = append(expanded, file)
+			continue
+		}
+
+		err := w.Walk(ctx, file, func(path string, entry fs.DirEntry, depth int) error {
+			if entry.Type().IsRegular() && (keep == nil || keep(path)) {
+				expanded = append(expanded, path)
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			logging.PathError("Failed to search", file, err)
+		}
+	}
+	return expanded, nil
+}
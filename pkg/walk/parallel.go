@@ -0,0 +1,214 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// ParallelFunc is called for each path visited by WalkParallel, possibly
+// from several goroutines at once, and returns the text to emit for the
+// path ("" for none). Its errors have the same meaning as for Func.
+type ParallelFunc func(path string, d fs.DirEntry, depth int) (string, error)
+
+// queueSize is how many results a subtree may produce ahead of the output
+const queueSize = 256
+
+// item is a result of a parallel walk or, in ordered mode, a subtree whose
+// results come at this point of the output
+type item struct {
+	text string
+	sub  chan item
+}
+
+// parallelWalk is the state of one WalkParallel call
+type parallelWalk struct {
+	w         *Walker
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	fn        ParallelFunc
+	unordered bool
+	slots     chan struct{} // goroutines beyond the first
+	wg        sync.WaitGroup
+}
+
+// WalkParallel visits the same paths as Walk, with up to Jobs goroutines
+// each working through a different subtree, and passes the text returned
+// by fn to emit. By default texts are emitted in the order Walk would
+// visit their paths; with unordered set they are emitted as soon as they
+// are produced. emit is only called from the calling goroutine, and an
+// error from it ends the walk.
+func (w *Walker) WalkParallel(ctx context.Context, root string, unordered bool, fn ParallelFunc, emit func(string) error) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := os.Stat(root); err == nil && target.IsDir() {
+			info = target
+		}
+	}
+
+	text, err := fn(root, fs.FileInfoToDirEntry(info), 0)
+	if text != "" {
+		if err := emit(text); err != nil {
+			return err
+		}
+	}
+	if err != nil || !info.IsDir() {
+		if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+			return nil
+		}
+		return err
+	}
+
+	jobs := w.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	walkCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	p := &parallelWalk{
+		w:         w,
+		ctx:       walkCtx,
+		cancel:    cancel,
+		fn:        fn,
+		unordered: unordered,
+		slots:     make(chan struct{}, jobs-1),
+	}
+
+	// The root's subtree runs in a goroutine of its own like any other, so
+	// that this one is free to emit
+	out := make(chan item, queueSize)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.dir(root, 0, out)
+		if !unordered {
+			close(out)
+		}
+	}()
+	if unordered {
+		// Every subtree sends to out, which is done once they all are
+		go func() {
+			p.wg.Wait()
+			close(out)
+		}()
+	}
+
+	emitErr := p.drain(out, emit)
+	if emitErr != nil {
+		cancel(emitErr)
+	}
+	p.wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if emitErr != nil {
+		return emitErr
+	}
+	if cause := context.Cause(walkCtx); cause != nil && !errors.Is(cause, fs.SkipAll) {
+		return cause
+	}
+	return nil
+}
+
+// drain emits the results read from out, descending into subtrees in
+// ordered mode. After an error the remaining results are discarded.
+func (p *parallelWalk) drain(out chan item, emit func(string) error) error {
+	var err error
+	for it := range out {
+		if err != nil {
+			continue
+		}
+		if it.sub != nil {
+			err = p.drain(it.sub, emit)
+		} else {
+			err = emit(it.text)
+		}
+		if err != nil {
+			// Stop the producers, which are blocked or about to block on
+			// a queue nobody reads any more
+			p.cancel(err)
+		}
+	}
+	return err
+}
+
+// send queues a result unless the walk has ended
+func (p *parallelWalk) send(out chan item, it item) bool {
+	select {
+	case out <- it:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// dir visits the entries of dir, sending their results to out
+func (p *parallelWalk) dir(dir string, depth int, out chan item) {
+	entries, err := p.w.readDir(dir)
+	if err != nil {
+		p.w.fail("Failed to read directory", dir, err)
+		return
+	}
+
+	for _, entry := range entries {
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		entry, descend := p.w.Entry(dir, entry)
+
+		text, err := p.fn(path, entry, depth+1)
+		if text != "" && !p.send(out, item{text: text}) {
+			return
+		}
+		if errors.Is(err, fs.SkipDir) {
+			continue
+		}
+		if err != nil {
+			p.cancel(err)
+			return
+		}
+
+		if descend {
+			p.descend(path, depth+1, out)
+		}
+	}
+}
+
+// descend visits a subdirectory in a new goroutine if one is free, and in
+// this one otherwise
+func (p *parallelWalk) descend(dir string, depth int, out chan item) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.dir(dir, depth, out)
+		return
+	}
+
+	sub := out
+	if !p.unordered {
+		sub = make(chan item, queueSize)
+		if !p.send(out, item{sub: sub}) {
+			<-p.slots
+			return
+		}
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.slots }()
+		p.dir(dir, depth, sub)
+		if !p.unordered {
+			close(sub)
+		}
+	}()
+}
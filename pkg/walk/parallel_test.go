@@ -0,0 +1,114 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectParallel walks root in parallel and returns the emitted paths
+// relative to it
+func collectParallel(t *testing.T, w *Walker, root string, unordered bool, fn Func) ([]string, error) {
+	var paths []string
+	err := w.WalkParallel(context.Background(), root, unordered, func(path string, d fs.DirEntry, depth int) (string, error) {
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		if fn != nil {
+			return filepath.ToSlash(rel), fn(path, d, depth)
+		}
+		return filepath.ToSlash(rel), nil
+	}, func(text string) error {
+		paths = append(paths, text)
+		return nil
+	})
+	return paths, err
+}
+
+// wideTree creates enough directories for several goroutines to be busy
+func wideTree(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{}
+	for i := range 20 {
+		for j := range 5 {
+			files[fmt.Sprintf("d%02d/e%d/f", i, j)] = ""
+		}
+	}
+	makeTree(t, root, files)
+	return root
+}
+
+// TestWalkParallel_Order tests that ordered output matches a sequential
+// walk whatever the number of jobs, and that unordered output has the same
+// paths
+func TestWalkParallel_Order(t *testing.T) {
+	root := wideTree(t)
+	want := collect(t, &Walker{}, root, nil)
+
+	for _, jobs := range []int{1, 2, 16} {
+		paths, err := collectParallel(t, &Walker{Jobs: jobs}, root, false, nil)
+		require.NoError(t, err)
+		assert.Equal(t, want, paths, "jobs=%d", jobs)
+
+		paths, err = collectParallel(t, &Walker{Jobs: jobs}, root, true, nil)
+		require.NoError(t, err)
+		sort.Strings(paths)
+		assert.Equal(t, want, paths, "unordered jobs=%d", jobs)
+	}
+}
+
+// TestWalkParallel_Skip tests SkipDir, SkipAll and errors returned from
+// concurrent callbacks
+func TestWalkParallel_Skip(t *testing.T) {
+	root := wideTree(t)
+
+	paths, err := collectParallel(t, &Walker{Jobs: 4}, root, false, func(path string, d fs.DirEntry, depth int) error {
+		if depth == 1 && d.Name() != "d03" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, paths, 1+20+5+5)
+
+	_, err = collectParallel(t, &Walker{Jobs: 4}, root, true, func(path string, d fs.DirEntry, depth int) error {
+		return fs.SkipAll
+	})
+	assert.NoError(t, err)
+
+	boom := errors.New("boom")
+	for _, unordered := range []bool{false, true} {
+		_, err = collectParallel(t, &Walker{Jobs: 4}, root, unordered, func(path string, d fs.DirEntry, depth int) error {
+			if d.Name() == "e3" {
+				return boom
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, boom)
+	}
+}
+
+// TestWalkParallel_EmitError tests that the walk stops when emitting fails
+func TestWalkParallel_EmitError(t *testing.T) {
+	root := wideTree(t)
+	full := errors.New("no space left")
+
+	count := 0
+	err := (&Walker{Jobs: 8}).WalkParallel(context.Background(), root, false, func(path string, d fs.DirEntry, depth int) (string, error) {
+		return path, nil
+	}, func(string) error {
+		count++
+		if count == 10 {
+			return full
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, full)
+	assert.Equal(t, 10, count)
+}
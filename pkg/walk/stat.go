@@ -0,0 +1,41 @@
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Infos returns the file information of entries, with up to Jobs lstat
+// calls in flight at once. On network file systems and cold caches each
+// call is a round trip, so making them one after another dominates the
+// time a listing takes. errs[i] is set where entries[i] couldn't be
+// described; entries not reached because ctx ended have neither.
+func (w *Walker) Infos(ctx context.Context, entries []fs.DirEntry) (infos []fs.FileInfo, errs []error) {
+	infos = make([]fs.FileInfo, len(entries))
+	errs = make([]error, len(entries))
+
+	jobs := w.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	jobs = min(jobs, len(entries))
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Go(func() {
+			for ctx.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= len(entries) {
+					return
+				}
+				infos[i], errs[i] = entries[i].Info()
+			}
+		})
+	}
+	wg.Wait()
+	return infos, errs
+}
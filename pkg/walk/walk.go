@@ -0,0 +1,364 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/evalgo-org/claude-tools/pkg/config"
+	"github.com/evalgo-org/claude-tools/pkg/gitignore"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+)
+
+// Func is called for each path visited, with depth 0 for the root.
+// Returning fs.SkipDir for a directory skips its contents, returning
+// fs.SkipAll ends the walk without an error, and any other error aborts it.
+type Func func(path string, d fs.DirEntry, depth int) error
+
+// Walker walks directory trees the same way for every command that
+// recurses: the configured ignore patterns and ignore files are honored on
+// request, symbolic links are only followed on request and never into a
+// loop, and unreadable directories are reported and skipped rather than
+// ending the walk.
+//
+// Only commands that search or list set Ignore. Commands that copy, check
+// or count every file must see names like node_modules too.
+//
+// A Walker may be used for several walks, and its methods may be called
+// concurrently.
+type Walker struct {
+	Ignore      bool // skip names matching the configured ignore patterns
+	Gitignore   bool // skip paths ignored by .gitignore/.ignore files and .git itself
+	FollowLinks bool // descend into symbolic links to directories
+	Jobs        int  // directories read concurrently ahead of the walk; 0 means GOMAXPROCS
+
+	mu     sync.Mutex
+	rules  map[string]*gitignore.Matcher // by absolute directory
+	repos  map[string]bool               // whether a directory lies in a git work tree
+	slots  chan struct{}
+	failed atomic.Bool
+}
+
+// Failed reports whether a directory could not be read or a link loop was
+// found during any walk so far
+func (w *Walker) Failed() bool {
+	return w.failed.Load()
+}
+
+// Walk visits root and, if it is a directory, everything below it in
+// lexical order, calling fn for each path. A root that is a symbolic link
+// to a directory is always followed, as the user named it explicitly.
+func (w *Walker) Walk(ctx context.Context, root string, fn Func) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if target, err := os.Stat(root); err == nil && target.IsDir() {
+			info = target
+		}
+	}
+
+	err = fn(root, fs.FileInfoToDirEntry(info), 0)
+	if err == nil && info.IsDir() {
+		err = w.walkDir(ctx, root, 0, w.list(root), fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkDir visits the entries of dir, whose listing may still be in progress
+func (w *Walker) walkDir(ctx context.Context, dir string, depth int, l *listing, fn Func) error {
+	entries, err := l.wait()
+	if err != nil {
+		w.fail("Failed to read directory", dir, err)
+		return nil
+	}
+
+	// Start listing the subdirectories so they are ready when reached
+	pending := make([]*listing, len(entries))
+	for i, entry := range entries {
+		if entry.IsDir() {
+			pending[i] = w.list(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		entry, descend := w.Entry(dir, entry)
+
+		err := fn(path, entry, depth+1)
+		if errors.Is(err, fs.SkipDir) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if descend {
+			l := pending[i]
+			if l == nil {
+				l = w.list(path)
+			}
+			if err := w.walkDir(ctx, path, depth+1, l, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadDir returns the entries of dir that are not ignored, sorted by name.
+// Errors are reported here, so callers only need to skip the directory.
+func (w *Walker) ReadDir(dir string) ([]fs.DirEntry, error) {
+	entries, err := w.readDir(dir)
+	if err != nil {
+		w.fail("Failed to read directory", dir, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// readDir lists dir and drops ignored entries
+func (w *Walker) readDir(dir string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules *gitignore.Matcher
+	var abs string
+	if w.Gitignore {
+		abs, _ = filepath.Abs(dir)
+		rules = w.matcher(abs)
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		name := entry.Name()
+		if w.Ignore && config.Ignored(name) {
+			continue
+		}
+		if rules != nil {
+			if name == ".git" || (rules.Len() > 0 && rules.Match(filepath.Join(abs, name), entry.IsDir())) {
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
+	return kept, nil
+}
+
+// Entry returns entry, found in dir, as the walk sees it: with FollowLinks
+// a symbolic link is described by its target. The result reports whether
+// the entry is a directory to descend into; links that lead back to one of
+// their own ancestors are reported and not descended.
+func (w *Walker) Entry(dir string, entry fs.DirEntry) (fs.DirEntry, bool) {
+	if entry.Type()&fs.ModeSymlink == 0 || !w.FollowLinks {
+		return entry, entry.IsDir()
+	}
+
+	path := filepath.Join(dir, entry.Name())
+	info, err := os.Stat(path)
+	if err != nil {
+		// Dangling links are still listed as links
+		return entry, false
+	}
+	target := fs.FileInfoToDirEntry(renamed{info, entry.Name()})
+	if !info.IsDir() {
+		return target, false
+	}
+
+	if w.loops(dir, path) {
+		w.failed.Store(true)
+		logging.PathWarn("Not following", path, errors.New("file system loop detected"))
+		return entry, false
+	}
+	return target, true
+}
+
+// loops reports whether the directory link at path points to dir or one of
+// its ancestors
+func (w *Walker) loops(dir, path string) bool {
+	resolved, err := realPath(dir)
+	if err != nil {
+		return false
+	}
+	target, err := realPath(path)
+	if err != nil {
+		return false
+	}
+	sep := string(filepath.Separator)
+	return resolved == target || strings.HasPrefix(resolved, strings.TrimSuffix(target, sep)+sep)
+}
+
+// realPath returns the absolute path of name with all links resolved
+func realPath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// fail reports an error on path and remembers that the walk was incomplete
+func (w *Walker) fail(msg, path string, err error) {
+	w.failed.Store(true)
+	logging.PathError(msg, path, err)
+}
+
+// renamed gives the information about a link target the link's name
+type renamed struct {
+	fs.FileInfo
+	name string
+}
+
+// Name returns the link's name
+func (r renamed) Name() string {
+	return r.name
+}
+
+// listing is a directory listing that may be read in the background
+type listing struct {
+	dir     string
+	w       *Walker
+	done    chan struct{} // nil when the listing is read on demand
+	entries []fs.DirEntry
+	err     error
+}
+
+// list starts reading dir in the background if a job slot is free and
+// otherwise leaves it to be read when needed
+func (w *Walker) list(dir string) *listing {
+	l := &listing{dir: dir, w: w}
+
+	w.mu.Lock()
+	if w.slots == nil {
+		jobs := w.Jobs
+		if jobs <= 0 {
+			jobs = runtime.GOMAXPROCS(0)
+		}
+		w.slots = make(chan struct{}, jobs-1)
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.slots <- struct{}{}:
+		l.done = make(chan struct{})
+		go func() {
+			defer func() { <-w.slots }()
+			l.entries, l.err = w.readDir(dir)
+			close(l.done)
+		}()
+	default:
+	}
+	return l
+}
+
+// wait returns the entries once they have been read
+func (l *listing) wait() ([]fs.DirEntry, error) {
+	if l.done == nil {
+		return l.w.readDir(l.dir)
+	}
+	<-l.done
+	return l.entries, l.err
+}
+
+// matcher returns the ignore rules that apply in the absolute directory
+// dir: those of its own ignore files and of every directory above it up to
+// the top of the git work tree, plus the repository's info/exclude file.
+// Outside a work tree the directories above it that were walked through
+// take the place of the work tree, so rules at the top of a walk apply all
+// the way down.
+func (w *Walker) matcher(dir string) *gitignore.Matcher {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.matcherLocked(dir)
+}
+
+// matcherLocked implements matcher with w.mu held
+func (w *Walker) matcherLocked(dir string) *gitignore.Matcher {
+	if m, ok := w.rules[dir]; ok {
+		return m
+	}
+	if w.rules == nil {
+		w.rules = make(map[string]*gitignore.Matcher)
+	}
+
+	var m *gitignore.Matcher
+	parent := filepath.Dir(dir)
+	switch {
+	case isRepo(dir):
+		exclude, err := readExclude(dir)
+		if err != nil {
+			logging.PathWarn("Failed to read", filepath.Join(dir, ".git", "info", "exclude"), err)
+		}
+		m = gitignore.New(exclude...)
+	case parent != dir && w.inRepoLocked(parent):
+		m = w.matcherLocked(parent)
+	case w.rules[parent] != nil:
+		// Directories are listed after their parent, whose rules are
+		// therefore known if the walk came through it
+		m = w.rules[parent]
+	default:
+		m = gitignore.New()
+	}
+
+	own, err := gitignore.ReadDir(dir)
+	if err != nil {
+		logging.PathWarn("Failed to read ignore files in", dir, err)
+	}
+	m = m.Add(own...)
+
+	w.rules[dir] = m
+	return m
+}
+
+// inRepoLocked reports whether dir is inside a git work tree
+func (w *Walker) inRepoLocked(dir string) bool {
+	if in, ok := w.repos[dir]; ok {
+		return in
+	}
+	if w.repos == nil {
+		w.repos = make(map[string]bool)
+	}
+
+	parent := filepath.Dir(dir)
+	in := isRepo(dir) || (parent != dir && w.inRepoLocked(parent))
+	w.repos[dir] = in
+	return in
+}
+
+// isRepo reports whether dir is the top of a git work tree
+func isRepo(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// readExclude reads the repository-wide .git/info/exclude patterns, which
+// apply relative to the top of the work tree
+func readExclude(top string) ([]gitignore.Pattern, error) {
+	file, err := os.Open(filepath.Join(top, ".git", "info", "exclude"))
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return gitignore.Parse(file, top)
+}
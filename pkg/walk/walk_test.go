@@ -0,0 +1,191 @@
+package walk
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/config"
+)
+
+// makeTree creates files (and their directories) below root
+func makeTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// collect walks root and returns the visited paths relative to it
+func collect(t *testing.T, w *Walker, root string, fn Func) []string {
+	var paths []string
+	err := w.Walk(context.Background(), root, func(path string, d fs.DirEntry, depth int) error {
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		paths = append(paths, filepath.ToSlash(rel))
+		if fn != nil {
+			return fn(path, d, depth)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return paths
+}
+
+// TestWalk_Order tests that paths are visited in lexical, pre-order
+// sequence whatever the number of jobs
+func TestWalk_Order(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"b/2": "", "b/1": "", "a/x/y": "", "c": ""})
+
+	want := []string{".", "a", "a/x", "a/x/y", "b", "b/1", "b/2", "c"}
+	for _, jobs := range []int{1, 8} {
+		assert.Equal(t, want, collect(t, &Walker{Jobs: jobs}, root, nil))
+	}
+}
+
+// TestWalk_SkipDir tests pruning a directory from the callback
+func TestWalk_SkipDir(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"skip/f": "", "keep/f": ""})
+
+	paths := collect(t, &Walker{}, root, func(path string, d fs.DirEntry, depth int) error {
+		if d.Name() == "skip" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	assert.Equal(t, []string{".", "keep", "keep/f", "skip"}, paths)
+}
+
+// TestWalk_Ignore tests that the configured ignore patterns are only
+// applied when the walker asks for them
+func TestWalk_Ignore(t *testing.T) {
+	defer func(saved []string) { config.IgnorePatterns = saved }(config.IgnorePatterns)
+	config.IgnorePatterns = []string{"node_modules", "*.log"}
+
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"node_modules/m.js": "", "a.log": "", "b.txt": ""})
+
+	assert.Equal(t, []string{".", "a.log", "b.txt", "node_modules", "node_modules/m.js"},
+		collect(t, &Walker{}, root, nil))
+	assert.Equal(t, []string{".", "b.txt"}, collect(t, &Walker{Ignore: true}, root, nil))
+}
+
+// TestExpandDirs tests replacing directories with the files below them,
+// which ignore patterns don't apply to, and keeping other operands
+func TestExpandDirs(t *testing.T) {
+	defer func(saved []string) { config.IgnorePatterns = saved }(config.IgnorePatterns)
+	config.IgnorePatterns = []string{"node_modules"}
+
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"a.go": "", "b.md": "", "node_modules/m.go": ""})
+	missing := filepath.Join(root, "missing")
+
+	files, err := (&Walker{}).ExpandDirs(context.Background(), []string{"-", root, missing}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-", filepath.Join(root, "a.go"), filepath.Join(root, "b.md"), filepath.Join(root, "node_modules", "m.go"), missing}, files)
+
+	files, err = (&Walker{}).ExpandDirs(context.Background(), []string{root}, func(path string) bool { return filepath.Ext(path) == ".go" })
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "a.go"), filepath.Join(root, "node_modules", "m.go")}, files)
+}
+
+// TestWalk_Gitignore tests ignore files at several levels, including one
+// above the walk root inside the same work tree
+func TestWalk_Gitignore(t *testing.T) {
+	repo := t.TempDir()
+	makeTree(t, repo, map[string]string{
+		".git/info/exclude": "secret\n",
+		".gitignore":        "*.log\n",
+		"src/.ignore":       "gen/\n",
+		"src/main.go":       "",
+		"src/app.log":       "",
+		"src/gen/x.go":      "",
+		"src/secret":        "",
+	})
+
+	paths := collect(t, &Walker{Gitignore: true}, filepath.Join(repo, "src"), nil)
+	assert.Equal(t, []string{".", ".ignore", "main.go"}, paths)
+
+	// Without the option everything is walked
+	paths = collect(t, &Walker{}, filepath.Join(repo, "src"), nil)
+	assert.Len(t, paths, 7)
+}
+
+// TestWalk_GitignoreNoRepo tests that outside a git work tree the ignore
+// files of the walk root and the directories below it apply all the way
+// down, whatever the number of jobs
+func TestWalk_GitignoreNoRepo(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{
+		".ignore":          "*.log\n",
+		"a.log":            "",
+		"sub/.gitignore":   "tmp/\n",
+		"sub/b.log":        "",
+		"sub/main.go":      "",
+		"sub/deep/c.log":   "",
+		"sub/deep/tmp/x":   "",
+		"sub/deep/keep.go": "",
+	})
+
+	want := []string{".", ".ignore", "sub", "sub/.gitignore", "sub/deep", "sub/deep/keep.go", "sub/main.go"}
+	for _, jobs := range []int{1, 8} {
+		assert.Equal(t, want, collect(t, &Walker{Gitignore: true, Jobs: jobs}, root, nil))
+	}
+}
+
+// TestWalk_FollowLinks tests following directory links while refusing to
+// loop back into an ancestor
+func TestWalk_FollowLinks(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"real/f": "", "dir/g": ""})
+	if err := os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "dir", "link")); err != nil {
+		t.Skip("symbolic links not supported:", err)
+	}
+	require.NoError(t, os.Symlink("..", filepath.Join(root, "dir", "loop")))
+
+	w := &Walker{}
+	assert.Equal(t, []string{".", "dir", "dir/g", "dir/link", "dir/loop", "real", "real/f"}, collect(t, w, root, nil))
+	assert.False(t, w.Failed())
+
+	w = &Walker{FollowLinks: true}
+	assert.Equal(t, []string{".", "dir", "dir/g", "dir/link", "dir/link/f", "dir/loop", "real", "real/f"}, collect(t, w, root, nil))
+	assert.True(t, w.Failed())
+}
+
+// TestInfos tests fetching the information of entries concurrently
+func TestInfos(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for i := range 50 {
+		files[fmt.Sprintf("f%02d", i)] = strings.Repeat("x", i)
+	}
+	makeTree(t, root, files)
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(root, "f07")))
+
+	for _, jobs := range []int{1, 8} {
+		w := &Walker{Jobs: jobs}
+		infos, errs := w.Infos(context.Background(), entries)
+		for i, entry := range entries {
+			if entry.Name() == "f07" {
+				assert.Error(t, errs[i])
+				continue
+			}
+			require.NoError(t, errs[i])
+			assert.Equal(t, entry.Name(), infos[i].Name())
+			assert.Equal(t, int64(i), infos[i].Size())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package wc
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/evalgo-org/claude-tools/pkg/input"
+)
+
+// groupKey returns the group --group-by puts a file in: its extension,
+// or "(none)" without one, or the directory it is in. Standard input is
+// a group of its own.
+func groupKey(file, by string) string {
+	switch {
+	case input.IsStdin(file):
+		return input.Stdin
+	case by == "dir":
+		return filepath.Dir(file)
+	}
+	if ext := filepath.Ext(file); ext != "" && ext != filepath.Base(file) {
+		return ext
+	}
+	return "(none)"
+}
+
+// groups sums the counts of the files in each group
+type groups map[string]*Counts
+
+// add adds the counts of a file to its group
+func (g groups) add(file string, counts *Counts, by string) {
+	key := groupKey(file, by)
+	if g[key] == nil {
+		g[key] = &Counts{}
+	}
+	addCounts(g[key], counts)
+}
+
+// keys returns the groups ordered by their first selected count, largest
+// first, so that a breakdown by lines starts where most of the code is
+func (g groups) keys(opts *Options) []string {
+	keys := make([]string, 0, len(g))
+	for key := range g {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		_, a := columns(g[keys[i]], opts)
+		_, b := columns(g[keys[j]], opts)
+		// The files column comes first; rank by the count after it
+		if a[1] != b[1] {
+			return a[1] > b[1]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// addCounts adds counts to total; the longest line is the longest of any
+func addCounts(total, counts *Counts) {
+	total.Files += counts.Files
+	total.Lines += counts.Lines
+	total.Words += counts.Words
+	total.Chars += counts.Chars
+	total.Bytes += counts.Bytes
+	total.MaxLineLen = max(total.MaxLineLen, counts.MaxLineLen)
+}
@@ -1,14 +1,28 @@
 package wc
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
-	eve "eve.evalgo.org/common"
 	"github.com/spf13/cobra"
+
+	"github.com/evalgo-org/claude-tools/pkg/exitcode"
+	"github.com/evalgo-org/claude-tools/pkg/glob"
+	"github.com/evalgo-org/claude-tools/pkg/input"
+	"github.com/evalgo-org/claude-tools/pkg/interrupt"
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/logging"
+	"github.com/evalgo-org/claude-tools/pkg/mmap"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
 )
 
 // Options holds wc configuration
@@ -18,10 +32,15 @@ type Options struct {
 	Chars      bool
 	Bytes      bool
 	MaxLineLen bool
+	NoMmap     bool   // read large files instead of mapping them
+	Format     string // "text", "json" or "tsv"
+	Recursive  bool   // count the files below directory operands
+	GroupBy    string // "ext" or "dir": print sums per group instead of per file
 }
 
 // Counts holds the counts for a file
 type Counts struct {
+	Files      int64 // files counted, for totals and groups
 	Lines      int64
 	Words      int64
 	Chars      int64
@@ -36,9 +55,34 @@ func Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "wc [flags] [files...]",
 		Short: "Print newline, word, and byte counts for each file",
-		Long:  `Print newline, word, and byte counts for each file. With no files, or when file is -, read standard input.`,
-		Args:  cobra.ArbitraryArgs,
+		Long: `Print newline, word, and byte counts for each file. With no files, or when file is -, read standard input.
+
+--format json prints a JSON object per line for each file, with its name
+as "file" and the selected counts as "lines", "words", "chars", "bytes"
+and "max_line_length", and for several files a last one with "total":
+true. --format tsv prints a header row naming the columns, then a row of
+counts and the name for each file. Standard input is named "-" in both.
+
+Bytes are those of the file as stored. -L prints the widest line in
+terminal columns, where wide East Asian characters take two, combining
+marks none, and tabs stop every 8 columns.
+
+-r counts every regular file below directory operands, skipping the
+configured ignore patterns. --group-by ext or --group-by dir prints the
+sums of the files with the same extension, or in the same directory, in
+place of a line per file, largest first, with the number of files as an
+extra first column, then the total:
+
+  wc -rl --group-by ext .`,
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.Format != "text" && opts.Format != "json" && opts.Format != "tsv" {
+				return exitcode.New(2, fmt.Errorf("invalid format %q: expected text, json or tsv", opts.Format))
+			}
+			if opts.GroupBy != "" && opts.GroupBy != "ext" && opts.GroupBy != "dir" {
+				return exitcode.New(2, fmt.Errorf("invalid --group-by %q: expected ext or dir", opts.GroupBy))
+			}
+
 			// If no flags specified, default to lines, words, and bytes
 			if !opts.Lines && !opts.Words && !opts.Chars && !opts.Bytes && !opts.MaxLineLen {
 				opts.Lines = true
@@ -46,127 +90,289 @@ func Command() *cobra.Command {
 				opts.Bytes = true
 			}
 
-			files := args
+			files := glob.Expand(args)
 			if len(files) == 0 {
-				files = []string{"-"}
+				files = []string{input.Stdin}
+			}
+
+			ctx := cmd.Context()
+			if opts.Recursive {
+				var err error
+				if files, err = (&walk.Walker{}).ExpandDirs(ctx, files, nil); err != nil {
+					return err
+				}
 			}
 
 			totalCounts := &Counts{}
-			multipleFiles := len(files) > 1
+			multipleFiles := len(files) > 1 || opts.GroupBy != ""
+			grouped := groups{}
+			if opts.Format == "tsv" {
+				printHeader(opts)
+			}
 
 			// Process each file
+			failed := false
 			for _, file := range files {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
 				var counts *Counts
 				var err error
 				var name string
 
-				if file == "-" {
-					counts, err = countReader(os.Stdin, opts)
+				if input.IsStdin(file) {
+					counts, err = countReader(ctx, os.Stdin, opts)
 					name = ""
 				} else {
-					counts, err = countFile(file, opts)
+					counts, err = countFile(ctx, file, opts)
 					name = file
 				}
 
 				if err != nil {
-					eve.Logger.Error("Failed to count", file, ":", err)
+					if interrupt.Interrupted(err) {
+						return err
+					}
+					logging.PathError("Failed to count", file, err)
+					failed = true
 					continue
 				}
 
-				printCounts(counts, opts, name)
-
-				// Add to totals
-				if multipleFiles {
-					totalCounts.Lines += counts.Lines
-					totalCounts.Words += counts.Words
-					totalCounts.Chars += counts.Chars
-					totalCounts.Bytes += counts.Bytes
-					if counts.MaxLineLen > totalCounts.MaxLineLen {
-						totalCounts.MaxLineLen = counts.MaxLineLen
-					}
+				counts.Files = 1
+				if opts.GroupBy != "" {
+					grouped.add(file, counts, opts.GroupBy)
+				} else {
+					printCounts(counts, opts, name, false)
 				}
+				addCounts(totalCounts, counts)
+			}
+
+			for _, key := range grouped.keys(opts) {
+				printCounts(grouped[key], opts, key, false)
 			}
 
 			// Print totals if multiple files
 			if multipleFiles {
-				printCounts(totalCounts, opts, "total")
+				printCounts(totalCounts, opts, "total", true)
 			}
 
-			return nil
+			return exitcode.Failed(cmd, failed)
 		},
 	}
 
+	cmd.SetFlagErrorFunc(exitcode.Usage)
+
 	cmd.Flags().BoolVarP(&opts.Lines, "lines", "l", false, "Print the newline counts")
 	cmd.Flags().BoolVarP(&opts.Words, "words", "w", false, "Print the word counts")
 	cmd.Flags().BoolVarP(&opts.Chars, "chars", "m", false, "Print the character counts")
 	cmd.Flags().BoolVarP(&opts.Bytes, "bytes", "c", false, "Print the byte counts")
 	cmd.Flags().BoolVarP(&opts.MaxLineLen, "max-line-length", "L", false, "Print the maximum display width")
+	cmd.Flags().BoolVar(&opts.NoMmap, "no-mmap", false, "Read large files instead of memory-mapping them")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "Output format: text, json (one object per line) or tsv")
+	cmd.Flags().BoolVarP(&opts.Recursive, "recursive", "r", false, "Count the files below directory operands")
+	cmd.Flags().StringVar(&opts.GroupBy, "group-by", "", "Print sums per file extension (ext) or directory (dir) instead of per file")
 
 	return cmd
 }
 
 // countFile counts lines, words, and bytes in a file
-func countFile(filename string, opts *Options) (*Counts, error) {
+func countFile(ctx context.Context, filename string, opts *Options) (*Counts, error) {
+	if input.IsRemote(filename) {
+		r, err := input.OpenContext(ctx, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer r.Close()
+		return countReader(ctx, r, opts)
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	return countReader(file, opts)
+	// Large files are counted in place through a mapping
+	if !opts.NoMmap {
+		if mapping, err := mmap.Map(file); err == nil {
+			defer mapping.Close()
+			var counts *Counts
+			err := mmap.Guard(func() (err error) {
+				counts, err = countChunks(ctx, lines.NewChunkReaderBytes(mapping.Bytes()), opts)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			counts.Bytes = int64(len(mapping.Bytes()))
+			return counts, nil
+		}
+	}
+
+	return countReader(ctx, file, opts)
 }
 
 // countReader counts lines, words, and bytes from a reader
-func countReader(reader io.Reader, opts *Options) (*Counts, error) {
-	counts := &Counts{}
-	scanner := bufio.NewScanner(reader)
+func countReader(ctx context.Context, reader io.Reader, opts *Options) (*Counts, error) {
+	raw := &byteCounter{r: interrupt.Reader(ctx, reader)}
+	counts, err := countChunks(ctx, lines.NewChunkReader(raw), opts)
+	if err != nil {
+		return nil, err
+	}
+	counts.Bytes = raw.n
+	return counts, nil
+}
 
-	inWord := false
+// byteCounter counts the bytes read through it, which are the bytes of the
+// file even where --strip-bom or --strip-cr change what the lines hold
+type byteCounter struct {
+	r io.Reader
+	n int64
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		counts.Lines++
+func (b *byteCounter) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	return n, err
+}
 
-		// Count bytes (including newline)
-		counts.Bytes += int64(len(line)) + 1 // +1 for newline
+// countChunks counts lines, words, and characters in the input of chunks.
+// Lines are counted a chunk at a time; only words, characters and line
+// widths need the lines to be decoded. Bytes are left to the caller, which
+// knows the size of the input before any decoding.
+func countChunks(ctx context.Context, chunks *lines.ChunkReader, opts *Options) (*Counts, error) {
+	counts := &Counts{}
+	perLine := opts.Words || opts.Chars || opts.MaxLineLen
 
-		// Count characters
-		lineLen := int64(0)
-		for _, r := range line {
-			counts.Chars++
-			lineLen++
+	for {
+		chunk, err := chunks.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading input: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			continue
+		}
 
-			// Count words
-			if unicode.IsSpace(r) {
-				inWord = false
+		// An unterminated final line counts as a line too
+		counts.Lines += int64(bytes.Count(chunk, []byte{'\n'}))
+		if chunk[len(chunk)-1] != '\n' {
+			counts.Lines++
+		}
+
+		for perLine && len(chunk) > 0 {
+			line := chunk
+			if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+				line, chunk = chunk[:i], chunk[i+1:]
 			} else {
-				if !inWord {
-					counts.Words++
-					inWord = true
-				}
+				chunk = nil
 			}
+			countLine(counts, line, opts.MaxLineLen)
 		}
+	}
 
-		// Track max line length
-		if lineLen > counts.MaxLineLen {
-			counts.MaxLineLen = lineLen
+	return counts, nil
+}
+
+// countLine adds the characters and words of a line without its newline,
+// and with width set, its display width
+func countLine(counts *Counts, line []byte, width bool) {
+	chars := int64(0)
+	inWord := false
+	var col, maxCol int64
+	for len(line) > 0 {
+		r, size := rune(line[0]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRune(line)
+		}
+		line = line[size:]
+		chars++
+
+		if unicode.IsSpace(r) {
+			inWord = false
+		} else if !inWord {
+			counts.Words++
+			inWord = true
 		}
 
-		// Reset word state for next line
-		inWord = false
+		if width {
+			col = advance(col, r)
+			if r == '\r' || r == '\f' {
+				maxCol = max(maxCol, col)
+				col = 0
+			}
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading input: %w", err)
+	counts.Chars += chars
+	counts.MaxLineLen = max(counts.MaxLineLen, maxCol, col)
+}
+
+// columns returns the names and values of the selected counts, in the
+// order they are printed
+func columns(counts *Counts, opts *Options) ([]string, []int64) {
+	var names []string
+	var values []int64
+	add := func(selected bool, name string, value int64) {
+		if selected {
+			names = append(names, name)
+			values = append(values, value)
+		}
 	}
+	add(opts.GroupBy != "", "files", counts.Files)
+	add(opts.Lines, "lines", counts.Lines)
+	add(opts.Words, "words", counts.Words)
+	add(opts.Chars, "chars", counts.Chars)
+	add(opts.Bytes, "bytes", counts.Bytes)
+	add(opts.MaxLineLen, "max_line_length", counts.MaxLineLen)
+	return names, values
+}
 
-	return counts, nil
+// printHeader prints the header row of --format tsv
+func printHeader(opts *Options) {
+	names, _ := columns(&Counts{}, opts)
+	fmt.Println(strings.Join(append(names, nameColumn(opts)), "\t"))
+}
+
+// nameColumn is what the JSON and TSV output call the name of a row: the
+// file, or with --group-by the extension or directory
+func nameColumn(opts *Options) string {
+	if opts.GroupBy != "" {
+		return opts.GroupBy
+	}
+	return "file"
 }
 
-// printCounts prints the counts according to options
-func printCounts(counts *Counts, opts *Options, filename string) {
+// printCounts prints the counts of a file, or the total of several
+// files, according to options. Standard input has no filename.
+func printCounts(counts *Counts, opts *Options, filename string, total bool) {
+	switch opts.Format {
+	case "json":
+		printJSON(counts, opts, filename, total)
+		return
+	case "tsv":
+		if filename == "" {
+			filename = input.Stdin
+		}
+		_, values := columns(counts, opts)
+		var b strings.Builder
+		for _, v := range values {
+			b.WriteString(strconv.FormatInt(v, 10) + "\t")
+		}
+		fmt.Println(b.String() + filename)
+		return
+	}
+
 	output := ""
 
+	if opts.GroupBy != "" {
+		output += fmt.Sprintf("%8d", counts.Files)
+	}
 	if opts.Lines {
 		output += fmt.Sprintf("%8d", counts.Lines)
 	}
@@ -189,3 +395,24 @@ func printCounts(counts *Counts, opts *Options, filename string) {
 
 	fmt.Println(output)
 }
+
+// printJSON prints the counts as a JSON object on one line
+func printJSON(counts *Counts, opts *Options, filename string, total bool) {
+	var b strings.Builder
+	b.WriteByte('{')
+	if total {
+		b.WriteString(`"total":true`)
+	} else {
+		if filename == "" {
+			filename = input.Stdin
+		}
+		name, _ := json.Marshal(filename)
+		b.WriteString(`"` + nameColumn(opts) + `":` + string(name))
+	}
+	names, values := columns(counts, opts)
+	for i, name := range names {
+		fmt.Fprintf(&b, `,"%s":%d`, name, values[i])
+	}
+	b.WriteByte('}')
+	fmt.Println(b.String())
+}
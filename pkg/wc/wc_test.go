@@ -0,0 +1,143 @@
+package wc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evalgo-org/claude-tools/pkg/lines"
+	"github.com/evalgo-org/claude-tools/pkg/mmap"
+	"github.com/evalgo-org/claude-tools/pkg/walk"
+)
+
+// TestCountReader tests line, word, character and length counts
+func TestCountReader(t *testing.T) {
+	opts := &Options{Lines: true, Words: true, Chars: true, Bytes: true, MaxLineLen: true}
+	counts, err := countReader(context.Background(), strings.NewReader("héllo  wörld\n\none two three\nlast"), opts)
+	require.NoError(t, err)
+	assert.Equal(t, &Counts{Lines: 4, Words: 6, Chars: 29, Bytes: 34, MaxLineLen: 13}, counts)
+}
+
+// TestCountFile_Mapped tests that a mapped file counts the same as a read one
+func TestCountFile_Mapped(t *testing.T) {
+	text := strings.Repeat("the quick brown fox\njumps  over\tthe lazy dög\n\n", mmap.MinSize/40) + "end"
+	path := filepath.Join(t.TempDir(), "big.txt")
+	require.NoError(t, os.WriteFile(path, []byte(text), 0644))
+
+	opts := &Options{Lines: true, Words: true, Chars: true, Bytes: true, MaxLineLen: true}
+	want, err := countReader(context.Background(), strings.NewReader(text), opts)
+	require.NoError(t, err)
+
+	mapped, err := countFile(context.Background(), path, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, mapped)
+
+	opts.NoMmap = true
+	read, err := countFile(context.Background(), path, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, read)
+	assert.Equal(t, int64(len(text)), read.Bytes)
+}
+
+// TestCountReader_Width tests that -L measures display columns and that
+// bytes are those of the input, also with CRLF line ends
+func TestCountReader_Width(t *testing.T) {
+	opts := &Options{Bytes: true, MaxLineLen: true}
+	count := func(text string) (bytes, width int64) {
+		counts, err := countReader(context.Background(), strings.NewReader(text), opts)
+		require.NoError(t, err)
+		return counts.Bytes, counts.MaxLineLen
+	}
+	check := func(text string, bytes, width int64) {
+		gotBytes, gotWidth := count(text)
+		assert.Equal(t, bytes, gotBytes, "bytes of %q", text)
+		assert.Equal(t, width, gotWidth, "width of %q", text)
+	}
+
+	check("日本語\r\nab", 13, 6)
+	check("e\u0301x", 4, 2)
+	check("a\tb\tcd\n", 7, 18)
+	check("no newline", 10, 10)
+
+	// Bytes are counted before --strip-cr takes the CRs out
+	lines.StripCR = true
+	defer func() { lines.StripCR = false }()
+	check("ab\r\ncd\r\n", 8, 2)
+}
+
+// TestPrintCounts_Formats tests the JSON and TSV rows for files, standard
+// input and the total
+func TestPrintCounts_Formats(t *testing.T) {
+	capture := func(print func()) string {
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		require.NoError(t, err)
+		defer out.Close()
+		stdout := os.Stdout
+		os.Stdout = out
+		defer func() { os.Stdout = stdout }()
+
+		print()
+		data, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		return string(data)
+	}
+	counts := &Counts{Lines: 2, Words: 3, Bytes: 6}
+
+	opts := &Options{Lines: true, Words: true, Bytes: true, Format: "json"}
+	assert.Equal(t, `{"file":"a \"b\".txt","lines":2,"words":3,"bytes":6}`+"\n"+`{"file":"-","lines":2,"words":3,"bytes":6}`+"\n"+`{"total":true,"lines":2,"words":3,"bytes":6}`+"\n", capture(func() {
+		printCounts(counts, opts, `a "b".txt`, false)
+		printCounts(counts, opts, "", false)
+		printCounts(counts, opts, "total", true)
+	}))
+
+	opts = &Options{Lines: true, MaxLineLen: true, Format: "tsv"}
+	assert.Equal(t, "lines\tmax_line_length\tfile\n2\t0\ta.txt\n", capture(func() {
+		printHeader(opts)
+		printCounts(counts, opts, "a.txt", false)
+	}))
+}
+
+// TestGroups tests that -r finds the files below a directory and that
+// --group-by sums them by extension or directory, largest first
+func TestGroups(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	for name, text := range map[string]string{
+		"a.go": "1\n2\n", "sub/b.go": "1\n2\n3\n", "sub/c.md": "1\n", "Makefile": "1\n2\n3\n4\n5\n6\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(text), 0644))
+	}
+
+	files, err := (&walk.Walker{}).ExpandDirs(context.Background(), []string{root}, nil)
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	group := func(by string) (keys []string, lines []int64) {
+		opts := &Options{Lines: true, GroupBy: by}
+		g := groups{}
+		for _, file := range files {
+			counts, err := countFile(context.Background(), file, opts)
+			require.NoError(t, err)
+			counts.Files = 1
+			g.add(file, counts, by)
+		}
+		for _, key := range g.keys(opts) {
+			keys = append(keys, key)
+			lines = append(lines, g[key].Lines)
+		}
+		return keys, lines
+	}
+
+	keys, lines := group("ext")
+	assert.Equal(t, []string{"(none)", ".go", ".md"}, keys)
+	assert.Equal(t, []int64{6, 5, 1}, lines)
+
+	keys, lines = group("dir")
+	assert.Equal(t, []string{root, filepath.Join(root, "sub")}, keys)
+	assert.Equal(t, []int64{8, 4}, lines)
+}
@@ -0,0 +1,27 @@
+package wc
+
+import (
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// narrow measures characters as a terminal in a UTF-8 locale does,
+// counting East Asian ambiguous characters as one column
+var narrow = &runewidth.Condition{EastAsianWidth: false}
+
+// advance returns the column after r is written at col, as wc -L measures
+// it: a tab moves to the next multiple of 8, control characters take no
+// room, wide East Asian characters and emoji take two columns, and
+// combining marks none
+func advance(col int64, r rune) int64 {
+	switch {
+	case r == '\t':
+		return col + 8 - col%8
+	case r < ' ' || r == utf8.RuneSelf-1:
+		return col
+	case r < utf8.RuneSelf:
+		return col + 1
+	}
+	return col + int64(narrow.RuneWidth(r))
+}